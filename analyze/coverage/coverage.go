@@ -0,0 +1,241 @@
+// Package coverage reports which productions of a grammar and which lexer
+// rules are exercised by a set of sample inputs. A production or rule that no
+// sample uses is either dead or untested.
+package coverage
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Coverage runs inputs through parlex.Run and records the productions in each
+// parse tree and the kinds of the lexemes. The parse tree is recorded before
+// it is reduced.
+type Coverage struct {
+	grmr    parlex.Grammar
+	lxr     parlex.Lexer
+	prsr    parlex.Parser
+	rdcr    parlex.Reducer
+	prods   map[string][]int
+	kinds   map[string]int
+	nonterm map[string]bool
+	// Inputs is the number of inputs that have been run
+	Inputs int
+	// Failed holds the error for each file passed to RunDir that could not be
+	// parsed, by path
+	Failed map[string]error
+}
+
+// New Coverage for a grammar and the lexer, parser and reducer that are used
+// with it. The reducer can be nil.
+func New(grmr parlex.Grammar, lxr parlex.Lexer, prsr parlex.Parser, rdcr parlex.Reducer) *Coverage {
+	c := &Coverage{
+		grmr:    grmr,
+		lxr:     lxr,
+		prsr:    prsr,
+		rdcr:    rdcr,
+		prods:   make(map[string][]int),
+		kinds:   make(map[string]int),
+		nonterm: make(map[string]bool),
+		Failed:  make(map[string]error),
+	}
+	for _, nt := range grmr.NonTerminals() {
+		c.nonterm[nt.String()] = true
+		c.prods[nt.String()] = make([]int, grmr.Productions(nt).Productions())
+	}
+	return c
+}
+
+// Run the input through parlex.Run and record what it used. The kinds of the
+// lexemes are recorded even if the parse fails.
+func (c *Coverage) Run(input string) (parlex.ParseNode, error) {
+	c.Inputs++
+	return parlex.Run(input, recordLexer{c}, recordParser{c}, c.rdcr)
+}
+
+// RunDir runs every file in the directory and its sub-directories. A file that
+// cannot be parsed is recorded in Failed, only an error reading the files is
+// returned.
+func (c *Coverage) RunDir(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if _, err := c.Run(string(b)); err != nil {
+			c.Failed[path] = err
+		}
+		return nil
+	})
+}
+
+type recordLexer struct{ *Coverage }
+
+func (l recordLexer) Lex(input string) []parlex.Lexeme {
+	lxs := l.lxr.Lex(input)
+	for _, lx := range lxs {
+		l.kinds[lx.Kind().String()]++
+	}
+	return lxs
+}
+
+type recordParser struct{ *Coverage }
+
+func (p recordParser) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := p.ParseErr(lexemes)
+	return pn
+}
+
+func (p recordParser) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	var pn parlex.ParseNode
+	var err error
+	if ep, ok := p.prsr.(parlex.ErrParser); ok {
+		pn, err = ep.ParseErr(lexemes)
+	} else if pn = p.prsr.Parse(lexemes); pn == nil {
+		err = parlex.ErrCouldNotParse
+	}
+	if pn != nil {
+		p.record(pn)
+	}
+	return pn, err
+}
+
+// record finds the production used by each non-terminal in the tree by the
+// kinds of its children.
+func (c *Coverage) record(pn parlex.ParseNode) {
+	kind := pn.Kind().String()
+	if c.nonterm[kind] {
+		for i := c.grmr.Productions(pn.Kind()).Iter(); i.Next(); {
+			if matches(i.Production, pn) {
+				c.prods[kind][i.Idx]++
+				break
+			}
+		}
+	}
+	for i := 0; i < pn.Children(); i++ {
+		c.record(pn.Child(i))
+	}
+}
+
+func matches(prod parlex.Production, pn parlex.ParseNode) bool {
+	if prod.Symbols() != pn.Children() {
+		return false
+	}
+	for i := prod.Iter(); i.Next(); {
+		if i.String() != pn.Child(i.Idx).Kind().String() {
+			return false
+		}
+	}
+	return true
+}
+
+// Production returns the number of times a production of a non-terminal was
+// used.
+func (c *Coverage) Production(nonterminal parlex.Symbol, production int) int {
+	counts := c.prods[nonterminal.String()]
+	if production < 0 || production >= len(counts) {
+		return 0
+	}
+	return counts[production]
+}
+
+// Kind returns the number of lexemes of a kind that were lexed.
+func (c *Coverage) Kind(kind parlex.Symbol) int {
+	return c.kinds[kind.String()]
+}
+
+// UnusedProductions returns the productions that were never used, in the order
+// of the grammar, as in "E -> E op E".
+func (c *Coverage) UnusedProductions() []string {
+	var unused []string
+	for _, nt := range c.grmr.NonTerminals() {
+		for i := c.grmr.Productions(nt).Iter(); i.Next(); {
+			if c.prods[nt.String()][i.Idx] == 0 {
+				unused = append(unused, productionString(nt, i.Production))
+			}
+		}
+	}
+	return unused
+}
+
+func productionString(nt parlex.Symbol, prod parlex.Production) string {
+	strs := []string{nt.String(), "->"}
+	for i := prod.Iter(); i.Next(); {
+		strs = append(strs, i.String())
+	}
+	return strings.Join(strs, " ")
+}
+
+// Terminals returns the kinds the lexer can produce. If the lexer has a Symbols
+// method, those are used without the ones it Discards, otherwise they are the
+// terminals in the grammar.
+func (c *Coverage) Terminals() []parlex.Symbol {
+	if s, ok := c.lxr.(interface{ Symbols() []parlex.Symbol }); ok {
+		discarded := make(map[string]bool)
+		if d, ok := c.lxr.(interface{ Discards() []parlex.Symbol }); ok {
+			for _, sym := range d.Discards() {
+				discarded[sym.String()] = true
+			}
+		}
+		var terminals []parlex.Symbol
+		for _, sym := range s.Symbols() {
+			if !discarded[sym.String()] {
+				terminals = append(terminals, sym)
+			}
+		}
+		return terminals
+	}
+	return parlex.Terminals(c.grmr)
+}
+
+// UnusedTerminals returns the terminals that were never lexed.
+func (c *Coverage) UnusedTerminals() []string {
+	var unused []string
+	for _, t := range c.Terminals() {
+		if c.kinds[t.String()] == 0 {
+			unused = append(unused, t.String())
+		}
+	}
+	return unused
+}
+
+// String reports how many productions and lexer rules were used and lists the
+// ones that were not.
+func (c *Coverage) String() string {
+	var buf strings.Builder
+	var prods int
+	for _, counts := range c.prods {
+		prods += len(counts)
+	}
+	unused := c.UnusedProductions()
+	fmt.Fprintf(&buf, "productions: %d of %d used\n", prods-len(unused), prods)
+	for _, u := range unused {
+		fmt.Fprintf(&buf, "  unused: %s\n", u)
+	}
+	terminals := len(c.Terminals())
+	unused = c.UnusedTerminals()
+	fmt.Fprintf(&buf, "lexer rules: %d of %d used\n", terminals-len(unused), terminals)
+	for _, u := range unused {
+		fmt.Fprintf(&buf, "  unused: %s\n", u)
+	}
+	if len(c.Failed) > 0 {
+		fmt.Fprintf(&buf, "%d of %d inputs failed\n", len(c.Failed), c.Inputs)
+		paths := make([]string, 0, len(c.Failed))
+		for path := range c.Failed {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Fprintf(&buf, "  %s: %s\n", path, c.Failed[path])
+		}
+	}
+	return buf.String()
+}
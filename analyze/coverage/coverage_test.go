@@ -0,0 +1,92 @@
+package coverage
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    - /-/
+    * /\*/
+    int /\d+/
+    space /\s+/ -
+  `))
+
+var grmr = parlex.MustGrammar(grammar.New(`
+    E -> E + T
+      -> E - T
+      -> T
+    T -> T * F
+      -> F
+    F -> ( E )
+      -> int
+  `))
+
+func TestCoverage(t *testing.T) {
+	c := New(grmr, lxr, packrat.New(grmr), tree.Reducer{"F": tree.PromoteSingleChild})
+	pn, err := c.Run("1 + (2 + 3)")
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	_, err = c.Run("1 + - 2")
+	assert.Error(t, err)
+
+	E := stringsymbol.Symbol("E")
+	assert.Equal(t, 2, c.Production(E, 0))
+	assert.Equal(t, 0, c.Production(E, 1))
+	assert.Equal(t, 2, c.Production(E, 2))
+	assert.Equal(t, 0, c.Production(E, 3))
+	// the lexemes of the input that failed are still counted
+	assert.Equal(t, 1, c.Kind(stringsymbol.Symbol("-")))
+
+	assert.Equal(t, []string{"E -> E - T", "T -> T * F"}, c.UnusedProductions())
+	assert.Equal(t, []string{"*"}, c.UnusedTerminals())
+	assert.Equal(t, `productions: 5 of 7 used
+  unused: E -> E - T
+  unused: T -> T * F
+lexer rules: 5 of 6 used
+  unused: *
+`, c.String())
+}
+
+func TestRunDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.calc"), []byte("1 - 2 * 3"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b.calc"), []byte("(1 + 2"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "c.calc"), []byte("(1)"), 0644))
+
+	c := New(grmr, lxr, packrat.New(grmr), nil)
+	assert.NoError(t, c.RunDir(dir))
+	assert.Equal(t, 3, c.Inputs)
+	assert.Equal(t, []string{"E -> E + T"}, c.UnusedProductions())
+	assert.Nil(t, c.UnusedTerminals())
+	if assert.Len(t, c.Failed, 1) {
+		assert.Error(t, c.Failed[filepath.Join(dir, "sub", "b.calc")])
+	}
+
+	assert.Error(t, c.RunDir(filepath.Join(dir, "missing")))
+}
+
+func TestGrammarTerminals(t *testing.T) {
+	// a lexer without a Symbols method uses the terminals of the grammar
+	c := New(grmr, struct{ parlex.Lexer }{lxr}, packrat.New(grmr), nil)
+	_, err := c.Run("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"+", "-", "*", "(", ")"}, c.UnusedTerminals())
+}
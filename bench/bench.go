@@ -0,0 +1,182 @@
+// Package bench holds representative grammars with inputs of any size for
+// benchmarking lexers, parsers and reducers. The fixtures can be shared by the
+// parser packages so a new parser can be compared to packrat on the same
+// grammars and inputs.
+//
+//   func BenchmarkJSON(b *testing.B) {
+//     bench.Run(b, bench.JSON, lalr.Constructor)
+//   }
+//
+// Measure and Compare are a harness for catching performance regressions; the
+// results of a run can be kept as a baseline and later runs compared to it.
+package bench
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"sort"
+	"testing"
+)
+
+// Fixture is a grammar with the lexer and reducer that go with it and a way to
+// generate inputs. Input returns a source made of n repetitions of a
+// representative piece of the language, so the size of the input grows
+// linearly with n.
+type Fixture struct {
+	Name    string
+	Lexer   parlex.Lexer
+	Grammar parlex.Grammar
+	Reducer parlex.Reducer
+	Input   func(n int) string
+}
+
+// Sizes are the values of n used by Run and Measure.
+var Sizes = []int{1, 10, 100}
+
+// Stages that are benchmarked. Run is the whole pipeline.
+const (
+	Lex    = "lex"
+	Parse  = "parse"
+	Reduce = "reduce"
+	All    = "run"
+)
+
+// Stages lists the stages in the order they are run.
+var Stages = []string{Lex, Parse, Reduce, All}
+
+// Run benchmarks each stage of the fixture for each of the Sizes as
+// sub-benchmarks named stage/size, like parse/100.
+func Run(b *testing.B, f *Fixture, c parlex.ParserConstructor) {
+	p, err := c(f.Grammar)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, size := range Sizes {
+		src := f.Input(size)
+		for _, stage := range Stages {
+			fn, err := f.bench(stage, p, src)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("%s/%d", stage, size), fn)
+		}
+	}
+}
+
+// bench returns the benchmark of a stage. The input of the stage is prepared
+// and checked first so only the stage is timed.
+func (f *Fixture) bench(stage string, p parlex.Parser, src string) (func(*testing.B), error) {
+	lxs := f.Lexer.Lex(src)
+	pn, err := parlex.Run(src, f.Lexer, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	var fn func()
+	switch stage {
+	case Lex:
+		fn = func() { f.Lexer.Lex(src) }
+	case Parse:
+		fn = func() { p.Parse(lxs) }
+	case Reduce:
+		fn = func() { f.Reducer.Reduce(pn) }
+	case All:
+		fn = func() { parlex.Run(src, f.Lexer, p, f.Reducer) }
+	default:
+		return nil, fmt.Errorf("Unknown Stage: %s", stage)
+	}
+	return func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fn()
+		}
+	}, nil
+}
+
+// Result is the measure of one stage of a fixture at one size.
+type Result struct {
+	Fixture     string
+	Stage       string
+	Size        int
+	NsPerOp     int64
+	AllocsPerOp int64
+}
+
+// Key identifies the measurement, like json/parse/100.
+func (r Result) Key() string {
+	return fmt.Sprintf("%s/%s/%d", r.Fixture, r.Stage, r.Size)
+}
+
+// Measure runs every stage of the fixture for each of the Sizes with
+// testing.Benchmark, so it can be used outside of go test.
+func Measure(f *Fixture, c parlex.ParserConstructor) ([]Result, error) {
+	p, err := c(f.Grammar)
+	if err != nil {
+		return nil, err
+	}
+	var out []Result
+	for _, size := range Sizes {
+		src := f.Input(size)
+		for _, stage := range Stages {
+			fn, err := f.bench(stage, p, src)
+			if err != nil {
+				return nil, err
+			}
+			br := testing.Benchmark(fn)
+			out = append(out, Result{
+				Fixture:     f.Name,
+				Stage:       stage,
+				Size:        size,
+				NsPerOp:     br.NsPerOp(),
+				AllocsPerOp: br.AllocsPerOp(),
+			})
+		}
+	}
+	return out, nil
+}
+
+// Regression is a result that is slower than its baseline or allocates more.
+type Regression struct {
+	Baseline, Current Result
+}
+
+// String fulfills Stringer
+//   json/parse/100: 120000 ns/op -> 150000 ns/op (+25.0%), 900 allocs/op -> 900 allocs/op
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %d ns/op -> %d ns/op (%+.1f%%), %d allocs/op -> %d allocs/op",
+		r.Current.Key(),
+		r.Baseline.NsPerOp, r.Current.NsPerOp, change(r.Baseline.NsPerOp, r.Current.NsPerOp),
+		r.Baseline.AllocsPerOp, r.Current.AllocsPerOp)
+}
+
+func change(from, to int64) float64 {
+	if from == 0 {
+		return 0
+	}
+	return float64(to-from) * 100 / float64(from)
+}
+
+// Compare returns the results that take more than tolerance longer than the
+// baseline result with the same key, or that allocate more. A tolerance of 0.1
+// allows each result to be 10% slower, since timings are noisy. Results that
+// are not in the baseline are ignored. The regressions are sorted by key.
+func Compare(baseline, current []Result, tolerance float64) []Regression {
+	base := make(map[string]Result, len(baseline))
+	for _, r := range baseline {
+		base[r.Key()] = r
+	}
+	var out []Regression
+	for _, r := range current {
+		b, ok := base[r.Key()]
+		if !ok {
+			continue
+		}
+		if float64(r.NsPerOp) > float64(b.NsPerOp)*(1+tolerance) || r.AllocsPerOp > b.AllocsPerOp {
+			out = append(out, Regression{Baseline: b, Current: r})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Current.Key() < out[j].Current.Key()
+	})
+	return out
+}
@@ -0,0 +1,129 @@
+package bench
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/parlextest"
+	"github.com/adamcolton/parlex/parser/cyk"
+	"github.com/adamcolton/parlex/parser/earley"
+	"github.com/adamcolton/parlex/parser/glr"
+	"github.com/adamcolton/parlex/parser/lalr"
+	"github.com/adamcolton/parlex/parser/ll1"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/parser/peg"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var constructors = map[string]parlex.ParserConstructor{
+	"packrat": packrat.Constructor,
+	"cyk":     cyk.Constructor,
+	"earley":  earley.Constructor,
+	"glr":     glr.Constructor,
+	"lalr":    lalr.Constructor,
+	"ll1":     ll1.Constructor,
+	"peg":     peg.Constructor,
+}
+
+func TestFixtures(t *testing.T) {
+	for _, f := range Fixtures() {
+		src := f.Input(3)
+		expected, err := parlex.Run(src, f.Lexer, packrat.New(f.Grammar), f.Reducer)
+		if !assert.NoError(t, err, f.Name) {
+			continue
+		}
+		for name, c := range constructors {
+			p, err := c(f.Grammar)
+			if !assert.NoError(t, err, f.Name, name) {
+				continue
+			}
+			pn, err := parlex.Run(src, f.Lexer, p, f.Reducer)
+			if assert.NoError(t, err, f.Name, name) {
+				assert.True(t, parlextest.Equal(expected, pn), f.Name, name)
+			}
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	pn, err := parlex.Run(`{"a": [1, true], "b": {}}`, JSON.Lexer, packrat.New(JSON.Grammar), JSON.Reducer)
+	if assert.NoError(t, err) {
+		parlextest.AssertTree(t, pn, `
+      (Object
+        (Member "\"a\"" (Array (number "1") (true "true")))
+        (Member "\"b\"" Object))
+    `)
+	}
+
+	pn, err = parlex.Run("1 - (2 * 3)", Arithmetic.Lexer, packrat.New(Arithmetic.Grammar), Arithmetic.Reducer)
+	if assert.NoError(t, err) {
+		parlextest.AssertTree(t, pn, `
+      (E
+        (int "1")
+        (- "-")
+        (T (int "2") (* "*") (int "3")))
+    `)
+	}
+
+	pn, err = parlex.Run("let x = 1; if x < 2 { print x; }", Toy.Lexer, packrat.New(Toy.Grammar), Toy.Reducer)
+	if assert.NoError(t, err) {
+		parlextest.AssertTree(t, pn, `
+      (Prog
+        (Stmt (name "x") (int "1"))
+        (Stmt
+          (if "if")
+          (Expr (name "x") (< "<") (int "2"))
+          (Prog (Stmt (print "print") (name "x")))
+          Else))
+    `)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := []Result{
+		{Fixture: "json", Stage: Parse, Size: 10, NsPerOp: 1000, AllocsPerOp: 10},
+		{Fixture: "json", Stage: Parse, Size: 100, NsPerOp: 10000, AllocsPerOp: 100},
+		{Fixture: "json", Stage: Lex, Size: 10, NsPerOp: 500, AllocsPerOp: 5},
+	}
+	current := []Result{
+		{Fixture: "json", Stage: Parse, Size: 10, NsPerOp: 1050, AllocsPerOp: 10},
+		{Fixture: "json", Stage: Parse, Size: 100, NsPerOp: 12500, AllocsPerOp: 100},
+		{Fixture: "json", Stage: Lex, Size: 10, NsPerOp: 400, AllocsPerOp: 6},
+		{Fixture: "toy", Stage: Lex, Size: 10, NsPerOp: 400, AllocsPerOp: 6},
+	}
+	rs := Compare(baseline, current, 0.1)
+	if assert.Len(t, rs, 2) {
+		assert.Equal(t, "json/lex/10", rs[0].Current.Key())
+		assert.Equal(t, "json/parse/100: 10000 ns/op -> 12500 ns/op (+25.0%), 100 allocs/op -> 100 allocs/op", rs[1].String())
+	}
+	assert.Empty(t, Compare(baseline, baseline, 0))
+}
+
+func BenchmarkPackrat(b *testing.B) {
+	for _, f := range Fixtures() {
+		b.Run(f.Name, func(b *testing.B) { Run(b, f, packrat.Constructor) })
+	}
+}
+
+func BenchmarkLALR(b *testing.B) {
+	for _, f := range Fixtures() {
+		b.Run(f.Name, func(b *testing.B) { Run(b, f, lalr.Constructor) })
+	}
+}
+
+func BenchmarkLL1(b *testing.B) {
+	for _, f := range Fixtures() {
+		b.Run(f.Name, func(b *testing.B) { Run(b, f, ll1.Constructor) })
+	}
+}
+
+func BenchmarkEarley(b *testing.B) {
+	for _, f := range Fixtures() {
+		b.Run(f.Name, func(b *testing.B) { Run(b, f, earley.Constructor) })
+	}
+}
+
+func BenchmarkCYK(b *testing.B) {
+	for _, f := range Fixtures() {
+		b.Run(f.Name, func(b *testing.B) { Run(b, f, cyk.Constructor) })
+	}
+}
@@ -0,0 +1,201 @@
+package bench
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"strings"
+)
+
+// The grammars of the fixtures are LL(1) and LALR(1) so every parser can be
+// compared on all of them.
+
+// JSON is a fixture for JSON documents. The input is an array of n objects.
+var JSON = &Fixture{
+	Name: "json",
+	Lexer: parlex.MustLexer(simplelexer.New(`
+    true   /true/
+    false  /false/
+    null   /null/
+    string /"([^"\\]|\\.)*"/
+    number /-?\d+(\.\d+)?([eE][+-]?\d+)?/
+    {      /\{/
+    }      /\}/
+    [      /\[/
+    ]      /\]/
+    ,      /,/
+    :      /:/
+    space  /\s+/ -
+  `)),
+	Grammar: parlex.MustGrammar(grammar.New(`
+    Value        -> Object
+                 -> Array
+                 -> string
+                 -> number
+                 -> true
+                 -> false
+                 -> null
+    Object       -> { Members }
+    Members      -> Member MoreMembers
+                 ->
+    MoreMembers  -> , Member MoreMembers
+                 ->
+    Member       -> string : Value
+    Array        -> [ Elements ]
+    Elements     -> Value MoreElements
+                 ->
+    MoreElements -> , Value MoreElements
+                 ->
+  `)),
+	Reducer: tree.Reducer{
+		"Value":        tree.PromoteSingleChild,
+		"Object":       tree.RemoveChildren(0, -1).PromoteChildrenOf(0),
+		"Members":      tree.PromoteChildrenOf(1),
+		"MoreMembers":  tree.RemoveChild(0).PromoteChildrenOf(1),
+		"Member":       tree.RemoveChild(1).PromoteChildValue(0),
+		"Array":        tree.RemoveChildren(0, -1).PromoteChildrenOf(0),
+		"Elements":     tree.PromoteChildrenOf(1),
+		"MoreElements": tree.RemoveChild(0).PromoteChildrenOf(1),
+	},
+	Input: func(n int) string {
+		items := make([]string, n)
+		for i := range items {
+			items[i] = fmt.Sprintf(`  {"id": %d, "name": "item \"%d\"", "tags": ["a", "b"], "score": %d.5e-1, "ok": true, "next": null}`, i, i, i)
+		}
+		return "[\n" + strings.Join(items, ",\n") + "\n]"
+	},
+}
+
+// Arithmetic is a fixture for arithmetic expressions. The input is n terms
+// with parentheses and all four operators.
+var Arithmetic = &Fixture{
+	Name: "arithmetic",
+	Lexer: parlex.MustLexer(simplelexer.New(`
+    int   /\d+/
+    +     /\+/
+    -     /-/
+    *     /\*/
+    div   /\//
+    (     /\(/
+    )     /\)/
+    space /\s+/ -
+  `)),
+	Grammar: parlex.MustGrammar(grammar.New(`
+    E  -> T E'
+    E' -> + T E'
+       -> - T E'
+       ->
+    T  -> F T'
+    T' -> * F T'
+       -> div F T'
+       ->
+    F  -> ( E )
+       -> - F
+       -> int
+  `)),
+	Reducer: exprReducer("E", "T", "F"),
+	Input: func(n int) string {
+		return strings.Repeat("(1 + 2 * 3) / -4 - ", n) + "5"
+	},
+}
+
+// Toy is a fixture for a small imperative language with blocks, statements and
+// expressions. The input is n groups of statements.
+var Toy = &Fixture{
+	Name: "toy",
+	Lexer: parlex.MustLexer(simplelexer.New(`
+    keywords let print if else while
+    name    /[A-Za-z_]\w*/
+    int     /\d+/
+    ==      /==/
+    =       /=/
+    <       /</
+    ;       /;/
+    {       /\{/
+    }       /\}/
+    +       /\+/
+    -       /-/
+    *       /\*/
+    div     /\//
+    (       /\(/
+    )       /\)/
+    comment /\/\/[^\n]*/ -
+    space   /\s+/ -
+  `)),
+	Grammar: parlex.MustGrammar(grammar.New(`
+    Prog    -> Stmt Prog
+            ->
+    Stmt    -> let name = Expr ;
+            -> name = Expr ;
+            -> print Expr ;
+            -> if Expr Block Else
+            -> while Expr Block
+    Block   -> { Prog }
+    Else    -> else Block
+            ->
+    Expr    -> Sum Cmp
+    Cmp     -> < Sum
+            -> == Sum
+            ->
+    Sum     -> Term Sum'
+    Sum'    -> + Term Sum'
+            -> - Term Sum'
+            ->
+    Term    -> Factor Term'
+    Term'   -> * Factor Term'
+            -> div Factor Term'
+            ->
+    Factor  -> ( Expr )
+            -> - Factor
+            -> int
+            -> name
+  `)),
+	Reducer: toyReducer(),
+	Input: func(n int) string {
+		var buf strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&buf, `// group %d
+let x%d = (1 + 2) * %d;
+if x%d < 10 {
+  print x%d;
+} else {
+  x%d = x%d - 1;
+}
+while x%d == 0 {
+  x%d = x%d / 2 + -1;
+}
+`, i, i, i, i, i, i, i, i, i, i)
+		}
+		return buf.String()
+	},
+}
+
+// exprReducer flattens the LL(1) form of an expression, where sum, term and
+// factor are the kinds of each level. The tails become siblings of the first
+// operand and a level with a single operand is replaced by it.
+func exprReducer(sum, term, factor string) tree.Reducer {
+	return tree.Reducer{
+		sum:        tree.PromoteChildrenOf(1).PromoteSingleChild(),
+		sum + "'":  tree.PromoteChildrenOf(2),
+		term:       tree.PromoteChildrenOf(1).PromoteSingleChild(),
+		term + "'": tree.PromoteChildrenOf(2),
+		factor:     tree.If(tree.ChildIs(0, "("), tree.ReplaceWithChild(1), tree.PromoteSingleChild),
+	}
+}
+
+func toyReducer() tree.Reducer {
+	r := exprReducer("Sum", "Term", "Factor")
+	r["Prog"] = tree.PromoteChildrenOf(1)
+	r["Stmt"] = tree.RemoveAll("let", "=", ";")
+	r["Block"] = tree.ReplaceWithChild(1)
+	r["Else"] = tree.ReplaceWithChild(1)
+	r["Expr"] = tree.PromoteChildrenOf(1).PromoteSingleChild()
+	return r
+}
+
+// Fixtures returns every fixture.
+func Fixtures() []*Fixture {
+	return []*Fixture{JSON, Arithmetic, Toy}
+}
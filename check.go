@@ -0,0 +1,163 @@
+package parlex
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Inconsistency lists the problems found by Check. Undefined holds the
+// terminals the grammar uses that the lexer does not produce. Unused holds the
+// kinds the lexer produces that no production reachable from the start symbol
+// uses. UnknownKeys holds the reducer keys that do not name a symbol of the
+// grammar.
+type Inconsistency struct {
+	Undefined   []string
+	Unused      []string
+	UnknownKeys []string
+}
+
+// Error fulfills error and lists all the problems.
+func (in *Inconsistency) Error() string {
+	var segs []string
+	add := func(name string, strs []string) {
+		if len(strs) > 0 {
+			segs = append(segs, name+": "+strings.Join(strs, ", "))
+		}
+	}
+	add("undefined terminals", in.Undefined)
+	add("unused lexer symbols", in.Unused)
+	add("unknown reducer keys", in.UnknownKeys)
+	return "Inconsistent) " + strings.Join(segs, "; ")
+}
+
+// Check confirms that a lexer, grammar and reducer agree with each other. The
+// lexer is checked if it has a Symbols() []Symbol method, like simplelexer;
+// the symbols from its Discards() []Symbol method, if it has one, are not
+// expected in the grammar. The reducer is checked if it has a Keys() []string
+// method, like tree.Reducer; a key that is a pattern must match some symbol.
+// Any of them can be nil. If there are any problems, the returned error is an
+// *Inconsistency.
+func Check(lexer Lexer, grammar Grammar, reducer Reducer) error {
+	if grammar == nil {
+		return nil
+	}
+	nts := make(map[string]bool)
+	for _, nt := range grammar.NonTerminals() {
+		nts[nt.String()] = true
+	}
+	// the terminals used by the productions reachable from the start symbol
+	used := make(map[string]bool)
+	var terminals []string
+	if start := StartSymbol(grammar); start != nil {
+		reached := map[string]bool{start.String(): true}
+		queue := []Symbol{start}
+		for len(queue) > 0 {
+			nt := queue[0]
+			queue = queue[1:]
+			for i := grammar.Productions(nt).Iter(); i.Next(); {
+				for j := i.Iter(); j.Next(); {
+					str := j.String()
+					if nts[str] {
+						if !reached[str] {
+							reached[str] = true
+							queue = append(queue, j.Symbol)
+						}
+					} else if !used[str] {
+						used[str] = true
+						terminals = append(terminals, str)
+					}
+				}
+			}
+		}
+	}
+	symbols := make(map[string]bool)
+	for nt := range nts {
+		symbols[nt] = true
+	}
+	for _, t := range Terminals(grammar) {
+		symbols[t.String()] = true
+	}
+
+	in := &Inconsistency{}
+	if sl, ok := lexer.(interface{ Symbols() []Symbol }); ok {
+		produced := make(map[string]bool)
+		discarded := make(map[string]bool)
+		if dl, ok := lexer.(interface{ Discards() []Symbol }); ok {
+			for _, s := range dl.Discards() {
+				discarded[s.String()] = true
+			}
+		}
+		for _, s := range sl.Symbols() {
+			str := s.String()
+			produced[str] = true
+			if !used[str] && !nts[str] && !discarded[str] {
+				in.Unused = append(in.Unused, str)
+			}
+		}
+		for _, t := range terminals {
+			if !produced[t] {
+				in.Undefined = append(in.Undefined, t)
+			}
+		}
+	}
+	if kr, ok := reducer.(interface{ Keys() []string }); ok {
+		for _, k := range kr.Keys() {
+			if !symbols[k] && !matchesAny(k, symbols) {
+				in.UnknownKeys = append(in.UnknownKeys, k)
+			}
+		}
+	}
+
+	if len(in.Undefined) == 0 && len(in.Unused) == 0 && len(in.UnknownKeys) == 0 {
+		return nil
+	}
+	sort.Strings(in.UnknownKeys)
+	return in
+}
+
+func matchesAny(pattern string, symbols map[string]bool) bool {
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		return false
+	}
+	for s := range symbols {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MustOption changes what MustRunner does.
+type MustOption func(*mustOp)
+
+type mustOp struct {
+	check      bool
+	middleware []Middleware
+}
+
+// Checked makes MustRunner panic if Check finds a problem.
+func Checked() MustOption {
+	return func(op *mustOp) { op.check = true }
+}
+
+// WithMiddleware adds middleware to the Runner made by MustRunner.
+func WithMiddleware(middleware ...Middleware) MustOption {
+	return func(op *mustOp) { op.middleware = append(op.middleware, middleware...) }
+}
+
+// MustRunner builds the parser for the grammar with the constructor and returns
+// a Runner. It panics if the parser cannot be built or, with the Checked
+// option, if the lexer, grammar and reducer are not consistent.
+func MustRunner(lexer Lexer, grammar Grammar, constructor ParserConstructor, reducer Reducer, opts ...MustOption) *Runner {
+	op := &mustOp{}
+	for _, o := range opts {
+		o(op)
+	}
+	if op.check {
+		if err := Check(lexer, grammar, reducer); err != nil {
+			panic(err)
+		}
+	}
+	return New(lexer, MustParser(constructor(grammar)), reducer, op.middleware...)
+}
@@ -0,0 +1,60 @@
+package parlex
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type symbolLexer struct {
+	wordLexer
+	symbols, discards []Symbol
+}
+
+func (l symbolLexer) Symbols() []Symbol  { return l.symbols }
+func (l symbolLexer) Discards() []Symbol { return l.discards }
+
+type keyReducer []string
+
+func (r keyReducer) Reduce(node ParseNode) ParseNode { return node }
+func (r keyReducer) Can(ParseNode) bool              { return true }
+func (r keyReducer) Keys() []string                  { return r }
+
+type grammarParser struct{}
+
+func (grammarParser) Parse([]Lexeme) ParseNode { return nil }
+
+func TestCheck(t *testing.T) {
+	g := &testGrammar{}
+	g.reset()
+	g.new("E")
+	g.add("E", "op", "T")
+	g.add("T")
+	g.new("T")
+	g.add("int")
+	g.add("lp", "E", "rp")
+	g.new("Unreached")
+	g.add("word")
+
+	lxr := symbolLexer{
+		symbols:  []Symbol{symbol("int"), symbol("op"), symbol("lp"), symbol("rp"), symbol("space")},
+		discards: []Symbol{symbol("space")},
+	}
+	assert.NoError(t, Check(lxr, g, keyReducer{"E", "T", "op", "*"}))
+	assert.NoError(t, Check(nil, g, nil))
+	assert.NoError(t, Check(wordLexer{}, nil, nil))
+
+	lxr.symbols = []Symbol{symbol("int"), symbol("op"), symbol("lp"), symbol("word"), symbol("space")}
+	err := Check(lxr, g, keyReducer{"E", "Expr", "x*"})
+	if in, ok := err.(*Inconsistency); assert.True(t, ok) {
+		assert.Equal(t, []string{"rp"}, in.Undefined)
+		assert.Equal(t, []string{"word"}, in.Unused)
+		assert.Equal(t, []string{"Expr", "x*"}, in.UnknownKeys)
+		assert.Equal(t, "Inconsistent) undefined terminals: rp; unused lexer symbols: word; unknown reducer keys: Expr, x*", err.Error())
+	}
+
+	constructor := func(Grammar) (Parser, error) { return grammarParser{}, nil }
+	assert.NotNil(t, MustRunner(lxr, g, constructor, nil))
+	assert.Panics(t, func() { MustRunner(lxr, g, constructor, nil, Checked()) })
+	lxr.symbols[3] = symbol("rp")
+	assert.NotNil(t, MustRunner(lxr, g, constructor, keyReducer{"T"}, Checked(), WithMiddleware(Middleware{})))
+}
@@ -0,0 +1,74 @@
+// parlex-doc renders a grammar, and the lexer for its terminals, as Markdown or
+// HTML documentation. Each non-terminal gets its productions, FIRST set and
+// example sentences generated from the grammar, with every symbol linked to
+// where it is defined.
+//
+//   parlex-doc --grammar calc.grammar --lexer calc.lexer > calc.md
+//   parlex-doc --grammar calc.grammar --lexer calc.lexer --html > calc.html
+package main
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/docgen"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "parlex-doc"
+	app.Usage = "Render a grammar as Markdown or HTML documentation"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "grammar, g", Usage: "file holding the grammar rules"},
+		cli.StringFlag{Name: "lexer, l", Usage: "file holding the lexer rules, optional"},
+		cli.StringFlag{Name: "title, t", Usage: "title of the document, defaults to the grammar file name"},
+		cli.BoolFlag{Name: "html", Usage: "write HTML instead of Markdown"},
+		cli.IntFlag{Name: "examples, e", Value: 3, Usage: "number of example sentences per non-terminal"},
+		cli.Int64Flag{Name: "seed", Value: 1, Usage: "seed for the example sentences"},
+	}
+	app.Action = func(c *cli.Context) error {
+		out, err := render(c.String("grammar"), c.String("lexer"), c.String("title"), c.Bool("html"), c.Int("examples"), c.Int64("seed"))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Print(out)
+		return err
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func render(grammarFile, lexerFile, title string, asHTML bool, examples int, seed int64) (string, error) {
+	if grammarFile == "" {
+		return "", fmt.Errorf("A grammar file is required")
+	}
+	d := docgen.Doc{
+		Title:    title,
+		Examples: examples,
+		Seed:     seed,
+	}
+	if d.Title == "" {
+		base := filepath.Base(grammarFile)
+		d.Title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	grmr, err := grammar.Load(grammarFile)
+	if err != nil {
+		return "", err
+	}
+	d.Grammar = grmr
+	if lexerFile != "" {
+		if d.Lexer, err = simplelexer.Load(lexerFile); err != nil {
+			return "", err
+		}
+	}
+	if asHTML {
+		return d.HTML()
+	}
+	return d.Markdown()
+}
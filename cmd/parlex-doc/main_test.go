@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parlex-doc")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	grmrFile := filepath.Join(dir, "calc.grammar")
+	assert.NoError(t, ioutil.WriteFile(grmrFile, []byte(`
+    E -> E op T
+      -> T
+    T -> int
+  `), 0644))
+	lxrFile := filepath.Join(dir, "calc.lexer")
+	assert.NoError(t, ioutil.WriteFile(lxrFile, []byte(`
+    op    /[+-]/
+    int   /\d+/
+    space /\s+/ -
+  `), 0644))
+
+	md, err := render(grmrFile, lxrFile, "", false, 2, 1)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(md, "# calc\n"))
+	assert.Contains(t, md, "* E → [E](#nt-E) [`op`](#lex-op) [T](#nt-T)\n")
+	assert.Contains(t, md, "## Lexer")
+
+	h, err := render(grmrFile, "", "Calc", true, 2, 1)
+	assert.NoError(t, err)
+	assert.Contains(t, h, "<title>Calc</title>")
+	assert.NotContains(t, h, "<h2>Lexer</h2>")
+
+	_, err = render("", lxrFile, "", false, 2, 1)
+	assert.Error(t, err)
+	_, err = render(grmrFile, filepath.Join(dir, "missing.lexer"), "", false, 2, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+// parlex-railroad renders the rules of a grammar as SVG railroad diagrams.
+// Without --out it writes an HTML page with every diagram to stdout, with --out
+// it writes one SVG file per non-terminal to that directory.
+//
+//   parlex-railroad --grammar calc.grammar > calc.html
+//   parlex-railroad --grammar calc.grammar --out diagrams
+package main
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/diagram"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "parlex-railroad"
+	app.Usage = "Render a grammar as SVG railroad diagrams"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "grammar, g", Usage: "file holding the grammar rules"},
+		cli.StringFlag{Name: "out, o", Usage: "directory to write an SVG file per non-terminal to"},
+	}
+	app.Action = func(c *cli.Context) error {
+		return render(c.String("grammar"), c.String("out"))
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func render(grammarFile, out string) error {
+	if grammarFile == "" {
+		return fmt.Errorf("A grammar file is required")
+	}
+	grmr, err := grammar.Load(grammarFile)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = fmt.Print(diagram.HTML(grmr))
+		return err
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	for _, nt := range grmr.NonTerminals() {
+		name := filepath.Join(out, fileName(nt.String())+".svg")
+		if err := ioutil.WriteFile(name, []byte(diagram.SVG(grmr, nt)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileName replaces anything in a symbol that is not a letter, digit, - or _
+// so it can be used as a file name.
+func fileName(symbol string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, symbol)
+}
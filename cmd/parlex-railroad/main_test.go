@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parlex-railroad")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	grmrFile := filepath.Join(dir, "calc.grammar")
+	assert.NoError(t, ioutil.WriteFile(grmrFile, []byte(`
+    E   -> E + T
+        -> T
+    T   -> int
+    E.2 -> T
+  `), 0644))
+
+	out := filepath.Join(dir, "out")
+	assert.NoError(t, render(grmrFile, out))
+	for _, name := range []string{"E.svg", "T.svg", "E_2.svg"} {
+		b, err := ioutil.ReadFile(filepath.Join(out, name))
+		assert.NoError(t, err, name)
+		assert.True(t, strings.HasPrefix(string(b), "<svg"), name)
+	}
+
+	assert.Error(t, render("", out))
+}
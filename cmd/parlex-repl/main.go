@@ -0,0 +1,36 @@
+// parlex-repl loads lexer, grammar and reducer rules and prints the tree for
+// each line that is typed. The rule files are reloaded when they change, so a
+// grammar can be edited in one window and tried in another.
+//
+//   parlex-repl --lexer calc.lex --grammar calc.grammar --reducer calc.reduce
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli"
+	"os"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "parlex-repl"
+	app.Usage = "Try inputs against a grammar as it is edited"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "lexer, l", Usage: "file holding the lexer rules"},
+		cli.StringFlag{Name: "grammar, g", Usage: "file holding the grammar rules"},
+		cli.StringFlag{Name: "reducer, r", Usage: "file holding the reducer rules"},
+		cli.BoolFlag{Name: "no-color", Usage: "do not color the output, also set by the NO_COLOR environment variable"},
+	}
+	app.Action = func(c *cli.Context) error {
+		s, err := newSession(c.String("lexer"), c.String("grammar"), c.String("reducer"))
+		if err != nil {
+			return err
+		}
+		s.color = !c.Bool("no-color") && os.Getenv("NO_COLOR") == ""
+		return s.loop(os.Stdin, os.Stdout)
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/regexgram"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/adamcolton/parlex/tree/reducer"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// ANSI escape codes
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	blue   = "\x1b[34m"
+)
+
+// Stages that can be shown
+const (
+	tokensStage  = "tokens"
+	treeStage    = "tree"
+	reducedStage = "reduced"
+)
+
+const help = `Type an input to see its tree. Commands:
+  :tokens   show the lexemes
+  :tree     show the parse tree
+  :reduced  show the reduced tree
+  :help     show this message
+  :quit     exit
+`
+
+// ruleFile is a file of rules and the time it was modified when it was loaded.
+type ruleFile struct {
+	name    string
+	modTime time.Time
+}
+
+// changed checks if the file was modified since it was last loaded.
+func (f *ruleFile) changed() (bool, error) {
+	if f.name == "" {
+		return false, nil
+	}
+	info, err := os.Stat(f.name)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return false, nil
+	}
+	f.modTime = info.ModTime()
+	return true, nil
+}
+
+type session struct {
+	lexer, grammar, reducer ruleFile
+	lxr                     parlex.Lexer
+	grmr                    *grammar.Grammar
+	gramRdcr, rdcr          tree.Reducer
+	stage                   string
+	color                   bool
+	// pending holds the lines of an input that was incomplete
+	pending string
+}
+
+func newSession(lexerFile, grammarFile, reducerFile string) (*session, error) {
+	if lexerFile == "" || grammarFile == "" {
+		return nil, fmt.Errorf("A lexer file and a grammar file are required")
+	}
+	s := &session{
+		lexer:   ruleFile{name: lexerFile},
+		grammar: ruleFile{name: grammarFile},
+		reducer: ruleFile{name: reducerFile},
+		stage:   treeStage,
+	}
+	if reducerFile != "" {
+		s.stage = reducedStage
+	}
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload loads any of the rule files that changed and returns their names. If
+// a file has an error, the rules from before it changed are kept.
+func (s *session) reload() ([]string, error) {
+	var loaded []string
+	if ok, err := s.lexer.changed(); err != nil {
+		return loaded, err
+	} else if ok {
+		lxr, err := simplelexer.Load(s.lexer.name)
+		if err != nil {
+			return loaded, fmt.Errorf("%s: %s", s.lexer.name, err)
+		}
+		s.lxr = lxr
+		loaded = append(loaded, s.lexer.name)
+	}
+	if ok, err := s.grammar.changed(); err != nil {
+		return loaded, err
+	} else if ok {
+		src, err := ioutil.ReadFile(s.grammar.name)
+		if err != nil {
+			return loaded, err
+		}
+		grmr, rdcr, err := regexgram.New(string(src))
+		if err != nil {
+			return loaded, fmt.Errorf("%s: %s", s.grammar.name, err)
+		}
+		s.grmr, s.gramRdcr = grmr, rdcr
+		loaded = append(loaded, s.grammar.name)
+	}
+	if ok, err := s.reducer.changed(); err != nil {
+		return loaded, err
+	} else if ok {
+		rdcr, err := reducer.ParseFile(s.reducer.name)
+		if err != nil {
+			return loaded, err
+		}
+		s.rdcr = rdcr
+		loaded = append(loaded, s.reducer.name)
+	}
+	return loaded, nil
+}
+
+// loop reads lines from r until it ends or :quit is entered.
+func (s *session) loop(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	fmt.Fprint(w, "> ")
+	for scanner.Scan() {
+		if !s.eval(scanner.Text(), w) {
+			return nil
+		}
+		if s.pending != "" {
+			fmt.Fprint(w, "... ")
+		} else {
+			fmt.Fprint(w, "> ")
+		}
+	}
+	return scanner.Err()
+}
+
+// eval handles one line. It returns false if the session should end. If the
+// input is incomplete, it is held until the next line and an empty line ends
+// it.
+func (s *session) eval(line string, w io.Writer) bool {
+	switch strings.TrimSpace(line) {
+	case ":quit":
+		return false
+	case ":help":
+		io.WriteString(w, help)
+		return true
+	case ":tokens", ":tree", ":reduced":
+		stage := strings.TrimPrefix(strings.TrimSpace(line), ":")
+		if stage == reducedStage && s.reducer.name == "" {
+			s.error(w, fmt.Errorf("No reducer file was given"))
+		} else {
+			s.stage = stage
+		}
+		return true
+	}
+
+	loaded, err := s.reload()
+	for _, name := range loaded {
+		fmt.Fprintf(w, "reloaded %s\n", name)
+	}
+	if err != nil {
+		s.error(w, err)
+	}
+
+	more := true
+	if s.pending != "" {
+		more = strings.TrimSpace(line) != ""
+		line, s.pending = s.pending+"\n"+line, ""
+	}
+
+	lxms := s.lxr.Lex(line)
+	if s.stage == tokensStage {
+		for _, lx := range lxms {
+			s.writeNode(w, "", lx, false)
+		}
+		return true
+	}
+	if errs := parlex.LexErrors(lxms); len(errs) > 0 {
+		s.error(w, errs[0])
+		return true
+	}
+	pn, err := packrat.New(s.grmr).ParseErr(lxms)
+	if more && parlex.IsIncomplete(err) {
+		s.pending = line
+		return true
+	}
+	if err != nil {
+		s.error(w, err)
+		return true
+	}
+	rdcr := s.gramRdcr
+	if s.stage == reducedStage {
+		rdcr = tree.Merge(rdcr, s.rdcr)
+	}
+	s.writeTree(w, "", rdcr.Reduce(pn))
+	return true
+}
+
+func (s *session) error(w io.Writer, err error) {
+	fmt.Fprintln(w, s.paint(red, err.Error()))
+}
+
+func (s *session) paint(code, str string) string {
+	if !s.color {
+		return str
+	}
+	return code + str + reset
+}
+
+func (s *session) writeTree(w io.Writer, pad string, pn parlex.ParseNode) {
+	if pn == nil {
+		fmt.Fprintln(w, pad+"NIL")
+		return
+	}
+	s.writeNode(w, pad, pn, pn.Children() > 0)
+	for i := 0; i < pn.Children(); i++ {
+		s.writeTree(w, pad+"  ", pn.Child(i))
+	}
+}
+
+// writeNode writes the kind and value of a node on one line. Nodes with
+// children are blue and leaves are green.
+func (s *session) writeNode(w io.Writer, pad string, lx parlex.Lexeme, branch bool) {
+	kind := s.paint(green, lx.Kind().String())
+	if branch {
+		kind = s.paint(bold+blue, lx.Kind().String())
+	}
+	if v := lx.Value(); v != "" {
+		fmt.Fprintf(w, "%s%s: %s\n", pad, kind, s.paint(yellow, fmt.Sprintf("%q", v)))
+	} else {
+		fmt.Fprintf(w, "%s%s\n", pad, kind)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parlex-repl")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, src string, modTime time.Time) string {
+		name = filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(name, []byte(src), 0644))
+		assert.NoError(t, os.Chtimes(name, modTime, modTime))
+		return name
+	}
+	start := time.Now().Add(-time.Hour)
+	lxrFile := write("calc.lex", `
+    plus /\+/
+    int /\d+/
+    space /\s+/ -
+  `, start)
+	grmrFile := write("calc.grammar", "E -> int (plus int)*", start)
+	rdcrFile := write("calc.reduce", "E RemoveAll(\"plus\")", start)
+
+	s, err := newSession(lxrFile, grmrFile, rdcrFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var buf bytes.Buffer
+	in := strings.NewReader("1 + 2\n:tree\n1 + 2\n:tokens\n1\n1 +\n:quit\n3\n")
+	assert.NoError(t, s.loop(in, &buf))
+	assert.Equal(t, `> E
+  int: "1"
+  int: "2"
+> > E
+  int: "1"
+  plus: "+"
+  int: "2"
+> > int: "1"
+> int: "1"
+plus: "+"
+> `, buf.String())
+
+	// a change to a rule file is picked up by the next input
+	write("calc.grammar", "E -> int plus int", start.Add(time.Minute))
+	buf.Reset()
+	s.stage = treeStage
+	assert.True(t, s.eval("1+2+3", &buf))
+	assert.Equal(t, "reloaded "+grmrFile+"\nCould Not Parse 1:4) found plus: +, expected end of input\n", buf.String())
+
+	// an error in a rule file keeps the previous rules
+	write("calc.grammar", "E -> (", start.Add(2*time.Minute))
+	buf.Reset()
+	s.color = true
+	assert.True(t, s.eval("1+2", &buf))
+	assert.True(t, strings.HasPrefix(buf.String(), red+grmrFile+": "))
+	assert.Contains(t, buf.String(), bold+blue+"E"+reset+"\n  "+green+"int"+reset+": "+yellow+`"1"`+reset)
+
+	// an incomplete input is continued on the next line
+	buf.Reset()
+	s.color = false
+	in = strings.NewReader("1 +\n2\n1 +\n\n")
+	assert.NoError(t, s.loop(in, &buf))
+	assert.Equal(t, `> ... E
+  int: "1"
+  plus: "+"
+  int: "2"
+> ... Could Not Parse) found end of input, expected int
+> `, buf.String())
+
+	_, err = newSession(lxrFile, "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+// parlex lexes, parses and reduces input files with rules read from files, so a
+// grammar can be tried without writing a Go program.
+//
+//   parlex --lexer rules.lex --grammar rules.grammar --reducer rules.reduce input.txt
+//
+// The lexer rules are read by simplelexer, the grammar by regexgram and the
+// reducer by tree/reducer. The input is parsed with the packrat parser.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/regexgram"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/adamcolton/parlex/tree/reducer"
+	"github.com/urfave/cli"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Stages that can be printed
+const (
+	tokensStage  = "tokens"
+	treeStage    = "tree"
+	reducedStage = "reduced"
+)
+
+type config struct {
+	lexer, grammar, reducer string
+	stage, format           string
+}
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "parlex"
+	app.Usage = "Lex, parse and reduce files"
+	app.ArgsUsage = "[input files, stdin if none]"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "lexer, l", Usage: "file holding the lexer rules"},
+		cli.StringFlag{Name: "grammar, g", Usage: "file holding the grammar rules"},
+		cli.StringFlag{Name: "reducer, r", Usage: "file holding the reducer rules"},
+		cli.StringFlag{Name: "stage, s", Usage: "what to print: tokens, tree or reduced, defaults to the last stage with rules"},
+		cli.StringFlag{Name: "format, f", Value: "text", Usage: "format of a tree: text, json or dot"},
+	}
+	app.Action = func(c *cli.Context) error {
+		cfg := config{
+			lexer:   c.String("lexer"),
+			grammar: c.String("grammar"),
+			reducer: c.String("reducer"),
+			stage:   c.String("stage"),
+			format:  c.String("format"),
+		}
+		if c.NArg() == 0 {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			return cfg.run(string(b), os.Stdout)
+		}
+		for _, name := range c.Args() {
+			b, err := ioutil.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			if err := cfg.run(string(b), os.Stdout); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		return nil
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func readFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	return string(b), err
+}
+
+// run processes one input and writes the chosen stage to w. The tree stage has
+// the reductions regexgram adds for its operators applied, but not the ones
+// from the reducer file.
+func (cfg config) run(input string, w io.Writer) error {
+	if cfg.lexer == "" {
+		return fmt.Errorf("A lexer file is required")
+	}
+	stage := cfg.stage
+	if stage == "" {
+		switch {
+		case cfg.reducer != "":
+			stage = reducedStage
+		case cfg.grammar != "":
+			stage = treeStage
+		default:
+			stage = tokensStage
+		}
+	}
+	if stage != tokensStage && stage != treeStage && stage != reducedStage {
+		return fmt.Errorf("Unknown Stage: %s", stage)
+	}
+
+	lxr, err := simplelexer.Load(cfg.lexer)
+	if err != nil {
+		return err
+	}
+	// the grammar is read first when there is one so its quoted terminals are
+	// added to the lexer
+	var src string
+	if cfg.grammar != "" {
+		if src, err = readFile(cfg.grammar); err != nil {
+			return err
+		}
+		literals, err := regexgram.Literals(src)
+		if err != nil {
+			return err
+		}
+		if err = lxr.AddLiterals(literals...); err != nil {
+			return err
+		}
+	}
+	lxms := lxr.Lex(input)
+	if stage == tokensStage {
+		_, err = fmt.Fprintln(w, parlex.LexemeList(lxms))
+		return err
+	}
+	if errs := parlex.LexErrors(lxms); len(errs) > 0 {
+		return errs[0]
+	}
+
+	if cfg.grammar == "" {
+		return fmt.Errorf("A grammar file is required for the %s stage", stage)
+	}
+	grmr, rdcr, err := regexgram.New(src)
+	if err != nil {
+		return err
+	}
+	if stage == reducedStage {
+		if cfg.reducer == "" {
+			return fmt.Errorf("A reducer file is required for the %s stage", stage)
+		}
+		r, err := reducer.ParseFile(cfg.reducer)
+		if err != nil {
+			return err
+		}
+		rdcr = tree.Merge(rdcr, r)
+	}
+
+	pn, err := packrat.New(grmr).ParseErr(lxms)
+	if err != nil {
+		return err
+	}
+	return cfg.write(rdcr.RawReduce(pn), w)
+}
+
+func (cfg config) write(pn *tree.PN, w io.Writer) error {
+	var err error
+	switch cfg.format {
+	case "", "text":
+		_, err = io.WriteString(w, pn.String())
+	case "json":
+		var b []byte
+		if b, err = json.MarshalIndent(pn, "", "  "); err == nil {
+			_, err = fmt.Fprintf(w, "%s\n", b)
+		}
+	case "dot":
+		_, err = io.WriteString(w, tree.ToDot(pn))
+	default:
+		err = fmt.Errorf("Unknown Format: %s", cfg.format)
+	}
+	return err
+}
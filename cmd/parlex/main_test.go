@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parlex")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, src string) string {
+		name = filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(name, []byte(src), 0644))
+		return name
+	}
+	cfg := config{
+		lexer: write("calc.lex", `
+      ( /\(/
+      ) /\)/
+      plus /\+/
+      int /\d+/
+      space /\s+/ -
+    `),
+		grammar: write("calc.grammar", `
+      E -> T (plus T)*
+      T -> ( E )
+        -> int
+    `),
+		reducer: write("calc.reduce", `
+      T PromoteSingleChild
+    `),
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, cfg.run("1 + 2", &buf))
+	assert.Equal(t, "E {\n\tint: \"1\"\n\tplus: \"+\"\n\tint: \"2\"\n}\n", buf.String())
+
+	buf.Reset()
+	tokens := cfg
+	tokens.stage = tokensStage
+	assert.NoError(t, tokens.run("1 +", &buf))
+	assert.Equal(t, "int: \"1\" (1, 1)\nplus: \"+\" (1, 3)\n", buf.String())
+
+	buf.Reset()
+	parsed := cfg
+	parsed.stage = treeStage
+	assert.NoError(t, parsed.run("1", &buf))
+	assert.Equal(t, "E {\n\tT {\n\t\tint: \"1\"\n\t}\n}\n", buf.String())
+
+	buf.Reset()
+	parsed.format = "json"
+	assert.NoError(t, parsed.run("1", &buf))
+	assert.True(t, strings.HasPrefix(buf.String(), "{"))
+
+	buf.Reset()
+	parsed.format = "dot"
+	assert.NoError(t, parsed.run("1", &buf))
+	assert.True(t, strings.HasPrefix(buf.String(), "digraph {"))
+
+	errs := map[string]config{
+		"A lexer file is required":                      {},
+		"Unknown Stage: ast":                            {lexer: cfg.lexer, stage: "ast"},
+		"A grammar file is required for the tree stage": {lexer: cfg.lexer, stage: treeStage},
+		"Unknown Format: xml":                           {lexer: cfg.lexer, grammar: cfg.grammar, format: "xml"},
+	}
+	for msg, c := range errs {
+		err := c.run("1", &buf)
+		if assert.Error(t, err, msg) {
+			assert.Equal(t, msg, err.Error())
+		}
+	}
+	assert.Error(t, cfg.run("1 +", &buf))
+
+	// quoted terminals in the grammar do not need lexer rules
+	buf.Reset()
+	literals := config{
+		lexer: write("let.lex", `
+      word  /[a-z]+/
+      int   /\d+/
+      space /\s+/ -
+    `),
+		grammar: write("let.grammar", `
+      Let -> "let" word "=" int
+    `),
+		stage: tokensStage,
+	}
+	assert.NoError(t, literals.run("let x = 1", &buf))
+	assert.Equal(t, "let: \"let\" (1, 1)\nword: \"x\" (1, 5)\n=: \"=\" (1, 7)\nint: \"1\" (1, 9)\n", buf.String())
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/adamcolton/parlex/grammar/regexgram"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/lalr"
+	"github.com/adamcolton/parlex/tree/reducer"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// compiled holds everything that is written into the generated package.
+type compiled struct {
+	lexer   *simplelexer.Tables
+	parser  *lalr.Tables
+	repeats map[string][]string
+	reducer string
+}
+
+// compile builds the lexer and parser tables from the definitions. The reducer
+// definition can be empty. The quoted terminals in the grammar are added to the
+// lexer.
+func compile(lexerRules, grammarRules, reducerRules string) (*compiled, error) {
+	lxr, err := simplelexer.New(lexerRules)
+	if err != nil {
+		return nil, err
+	}
+	literals, err := regexgram.Literals(grammarRules)
+	if err != nil {
+		return nil, err
+	}
+	if err = lxr.AddLiterals(literals...); err != nil {
+		return nil, err
+	}
+	c := &compiled{}
+	if c.lexer, err = lxr.Tables(); err != nil {
+		return nil, err
+	}
+
+	grmr, _, err := regexgram.New(grammarRules)
+	if err != nil {
+		return nil, err
+	}
+	prsr, err := lalr.New(grmr)
+	if err != nil {
+		return nil, err
+	}
+	c.parser = prsr.Tables()
+	if c.repeats, err = regexgram.Repeats(grammarRules); err != nil {
+		return nil, err
+	}
+
+	c.reducer = "tree.Reducer{}"
+	if strings.TrimSpace(reducerRules) != "" {
+		if c.reducer, err = reducer.GoCode(reducerRules); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Generate returns the source for a Go file in the package that lexes, parses
+// and reduces with the definitions. The regular expressions and parse tables
+// are compiled when the file is generated, so the package only reads tables
+// when it runs.
+func Generate(pkg, lexerRules, grammarRules, reducerRules string) ([]byte, error) {
+	c, err := compile(lexerRules, grammarRules, reducerRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Pkg                    string
+		Lexer, Parser, Reducer string
+		Repeats                string
+	}{
+		Pkg:     pkg,
+		Lexer:   c.lexer.GoString(),
+		Parser:  c.parser.GoString(),
+		Reducer: c.reducer,
+		Repeats: repeatsCode(c.repeats),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// repeatsCode writes the reductions regexgram adds for the operators in the
+// grammar.
+func repeatsCode(repeats map[string][]string) string {
+	nts := make([]string, 0, len(repeats))
+	for nt := range repeats {
+		nts = append(nts, nt)
+	}
+	sort.Strings(nts)
+
+	var buf strings.Builder
+	buf.WriteString("tree.Reducer{\n")
+	for _, nt := range nts {
+		var args []string
+		seen := make(map[string]bool)
+		for _, s := range repeats[nt] {
+			if !seen[s] {
+				seen[s] = true
+				args = append(args, strconv.Quote(s))
+			}
+		}
+		fmt.Fprintf(&buf, "%q: parlexcRepeat(%s),\n", nt, strings.Join(args, ", "))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+var tmpl = template.Must(template.New("gen").Parse(`// Code generated by parlexc. DO NOT EDIT.
+
+package {{.Pkg}}
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/lalr"
+	"github.com/adamcolton/parlex/tree"
+)
+
+var (
+	parlexcLexer   = simplelexer.FromTables(parlexcLexerTables)
+	parlexcParser  = lalr.FromTables(parlexcParserTables)
+	parlexcReducer = tree.Merge(parlexcRepeats, parlexcReductions)
+	parlexcRunner  = parlex.New(parlexcLexer, parlexcParser, parlexcReducer)
+)
+
+// Lexer returns the lexer. It is safe for concurrent use.
+func Lexer() parlex.Lexer {
+	return parlexcLexer
+}
+
+// Parser returns the LALR parser. It is safe for concurrent use.
+func Parser() parlex.Parser {
+	return parlexcParser
+}
+
+// Reducer returns the reducer, including the reductions for the operators in
+// the grammar.
+func Reducer() parlex.Reducer {
+	return parlexcReducer
+}
+
+// Run lexes, parses and reduces the input.
+func Run(input string) (parlex.ParseNode, error) {
+	return parlexcRunner.Run(input)
+}
+
+// parlexcRepeat promotes the children of the symbols added for operators like
+// E* into the node.
+func parlexcRepeat(symbols ...string) tree.Reduction {
+	return func(node *tree.PN) {
+		for i := 0; i < len(node.C); i++ {
+			if node.ChildAt(i, symbols...) {
+				node.PromoteChildrenOf(i)
+				i--
+			}
+		}
+	}
+}
+
+var parlexcRepeats = {{.Repeats}}
+
+var parlexcReductions = {{.Reducer}}
+
+var parlexcLexerTables = {{.Lexer}}
+
+var parlexcParserTables = {{.Parser}}
+`))
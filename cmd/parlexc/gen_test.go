@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/regexgram"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/lalr"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/adamcolton/parlex/tree/reducer"
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	calcLexer = `
+    lp   /\(/
+    rp   /\)/
+    plus /\+/
+    mul  /\*/
+    semi /;/
+    int /\d+/
+    space /\s+/ -
+  `
+	calcGrammar = `
+    L -> (E semi)*
+    E -> E plus T
+      -> T
+    T -> T mul F
+      -> F
+    F -> lp E rp
+      -> int
+  `
+	calcReducer = `
+    E  If(ChildCount(3), PromoteChildValue(1), PromoteSingleChild)
+    T  If(ChildCount(3), PromoteChildValue(1), PromoteSingleChild)
+    F  If(ChildCount(3), ReplaceWithChild(1), PromoteSingleChild)
+    L  RemoveAll("semi")
+  `
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate("calc", calcLexer, calcGrammar, calcReducer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	str := string(src)
+
+	expected := []string{
+		"// Code generated by parlexc. DO NOT EDIT.\n\npackage calc\n",
+		"var parlexcRepeats = tree.Reducer{\n\t\"L\": parlexcRepeat(\"(E_semi)*\"),\n}",
+		"\"E\": tree.Reduction(nil).If(tree.ChildCount(3), tree.Reduction(nil).PromoteChildValue(1), tree.Reduction(nil).PromoteSingleChild()),",
+		"var parlexcLexerTables = &simplelexer.Tables{",
+		"var parlexcParserTables = &lalr.Tables{",
+		"func Run(input string) (parlex.ParseNode, error) {",
+	}
+	for _, e := range expected {
+		assert.True(t, strings.Contains(str, e), e)
+	}
+	assert.False(t, strings.Contains(str, "regexgram"))
+
+	assert.NoError(t, typeCheck(src))
+
+	src, err = Generate("calc", calcLexer, calcGrammar, "")
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(src), "var parlexcReductions = tree.Reducer{}"))
+}
+
+// typeCheck checks the generated code against the packages it imports. Like
+// go vet, it does not allow an unkeyed literal of a struct from another
+// package.
+func typeCheck(src []byte) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "calc.go", src, 0)
+	if err != nil {
+		return err
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err = conf.Check("calc", fset, []*ast.File{f}, info); err != nil {
+		return err
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok || err != nil || len(lit.Elts) == 0 {
+			return err == nil
+		}
+		if _, keyed := lit.Elts[0].(*ast.KeyValueExpr); keyed {
+			return true
+		}
+		typ := info.Types[lit].Type
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		named, ok := typ.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Name() == "calc" {
+			return true
+		}
+		if _, ok := named.Underlying().(*types.Struct); ok {
+			err = fmt.Errorf("%s: %s struct literal uses unkeyed fields", fset.Position(lit.Pos()), named)
+		}
+		return true
+	})
+	return err
+}
+
+// repeat is parlexcRepeat from the generated code.
+func repeat(symbols ...string) tree.Reduction {
+	return func(node *tree.PN) {
+		for i := 0; i < len(node.C); i++ {
+			if node.ChildAt(i, symbols...) {
+				node.PromoteChildrenOf(i)
+				i--
+			}
+		}
+	}
+}
+
+// TestCompile checks that the tables in the generated code give the same
+// result as the definitions.
+func TestCompile(t *testing.T) {
+	c, err := compile(calcLexer, calcGrammar, calcReducer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	repeats := tree.Reducer{}
+	for nt, symbols := range c.repeats {
+		repeats[nt] = repeat(symbols...)
+	}
+	compiled := parlex.New(
+		simplelexer.FromTables(c.lexer),
+		lalr.FromTables(c.parser),
+		tree.Merge(repeats, reducer.Must(calcReducer)),
+	)
+
+	lxr := parlex.MustLexer(simplelexer.New(calcLexer))
+	grmr, rdcr := regexgram.Must(calcGrammar)
+	interpreted := parlex.New(lxr, packrat.New(grmr), tree.Merge(rdcr, reducer.Must(calcReducer)))
+
+	for _, in := range []string{"1+2*3; (1+2)*3;", "", "1+;"} {
+		expected, expectedErr := interpreted.Run(in)
+		pn, err := compiled.Run(in)
+		if expectedErr != nil {
+			assert.Error(t, err, in)
+			continue
+		}
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String(), in)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	_, err := Generate("calc", calcLexer, "E -> E plus E\n  -> int", "")
+	_, ok := err.(lalr.ConflictError)
+	assert.True(t, ok)
+
+	_, err = Generate("calc", `word /\w+\b/`, calcGrammar, "")
+	assert.Equal(t, `Unsupported by DFA: /\w+\b/`, err.Error())
+}
+
+func TestGenerateFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parlexc")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	lxrFile := filepath.Join(dir, "calc.lex")
+	grmrFile := filepath.Join(dir, "calc.grammar")
+	rdcrFile := filepath.Join(dir, "calc.reduce")
+	assert.NoError(t, ioutil.WriteFile(lxrFile, []byte(calcLexer), 0644))
+	assert.NoError(t, ioutil.WriteFile(grmrFile, []byte(calcGrammar), 0644))
+	assert.NoError(t, ioutil.WriteFile(rdcrFile, []byte("E RemoveChild("), 0644))
+
+	_, err = generate(lxrFile, grmrFile, rdcrFile, "calc")
+	if e, ok := err.(*reducer.Error); assert.True(t, ok) {
+		assert.Equal(t, rdcrFile, e.File)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(rdcrFile, []byte(calcReducer), 0644))
+	src, err := generate(lxrFile, grmrFile, rdcrFile, "calc")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(src), "// Code generated by parlexc. DO NOT EDIT."))
+
+	_, err = generate("", grmrFile, "", "calc")
+	assert.Error(t, err)
+	_, err = generate(lxrFile, "", "", "calc")
+	assert.Error(t, err)
+}
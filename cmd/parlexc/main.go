@@ -0,0 +1,70 @@
+// parlexc compiles lexer, grammar and reducer definitions into a Go package
+// that lexes, parses and reduces without compiling regular expressions or
+// building parse tables when it runs.
+//
+//   parlexc --lexer calc.lex --grammar calc.grammar --reducer calc.reduce --package calc --out calc.go
+package main
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/tree/reducer"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "parlexc"
+	app.Usage = "Compile a lexer, grammar and reducer into a Go package"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "lexer, l", Usage: "file holding the lexer rules"},
+		cli.StringFlag{Name: "grammar, g", Usage: "file holding the grammar rules"},
+		cli.StringFlag{Name: "reducer, r", Usage: "file holding the reducer rules, optional"},
+		cli.StringFlag{Name: "package, p", Value: "parser", Usage: "package of the generated file"},
+		cli.StringFlag{Name: "out, o", Usage: "output file, defaults to stdout"},
+	}
+	app.Action = func(c *cli.Context) error {
+		src, err := generate(c.String("lexer"), c.String("grammar"), c.String("reducer"), c.String("package"))
+		if err != nil {
+			return err
+		}
+		if out := c.String("out"); out != "" {
+			return ioutil.WriteFile(out, src, 0644)
+		}
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func generate(lexerFile, grammarFile, reducerFile, pkg string) ([]byte, error) {
+	if lexerFile == "" {
+		return nil, fmt.Errorf("A lexer file is required")
+	}
+	if grammarFile == "" {
+		return nil, fmt.Errorf("A grammar file is required")
+	}
+	lexerRules, err := ioutil.ReadFile(lexerFile)
+	if err != nil {
+		return nil, err
+	}
+	grammarRules, err := ioutil.ReadFile(grammarFile)
+	if err != nil {
+		return nil, err
+	}
+	var reducerRules []byte
+	if reducerFile != "" {
+		// ParseFile names the file in an error in the definition
+		if _, err := reducer.ParseFile(reducerFile); err != nil {
+			return nil, err
+		}
+		if reducerRules, err = ioutil.ReadFile(reducerFile); err != nil {
+			return nil, err
+		}
+	}
+	return Generate(pkg, string(lexerRules), string(grammarRules), string(reducerRules))
+}
@@ -0,0 +1,63 @@
+// parlexcover runs a directory of sample inputs through a grammar and reports
+// the productions and lexer rules that none of them used.
+//
+//   parlexcover --grammar calc.grammar --lexer calc.lexer testdata/
+package main
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/analyze/coverage"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/urfave/cli"
+	"os"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "parlexcover"
+	app.Usage = "Report the productions and lexer rules a directory of inputs does not use"
+	app.ArgsUsage = "dir..."
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "grammar, g", Usage: "file holding the grammar rules"},
+		cli.StringFlag{Name: "lexer, l", Usage: "file holding the lexer rules"},
+	}
+	app.Action = func(c *cli.Context) error {
+		cvr, err := cover(c.String("grammar"), c.String("lexer"), c.Args())
+		if err != nil {
+			return err
+		}
+		fmt.Print(cvr)
+		return nil
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func cover(grammarFile, lexerFile string, dirs []string) (*coverage.Coverage, error) {
+	if grammarFile == "" || lexerFile == "" {
+		return nil, fmt.Errorf("A grammar file and a lexer file are required")
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("At least one directory of inputs is required")
+	}
+	grmr, err := grammar.Load(grammarFile)
+	if err != nil {
+		return nil, err
+	}
+	lxr, err := simplelexer.Load(lexerFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cvr := coverage.New(grmr, lxr, packrat.New(grmr), nil)
+	for _, dir := range dirs {
+		if err := cvr.RunDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	return cvr, nil
+}
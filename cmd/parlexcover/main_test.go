@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parlexcover")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	grmrFile := filepath.Join(dir, "calc.grammar")
+	lxrFile := filepath.Join(dir, "calc.lexer")
+	inputs := filepath.Join(dir, "inputs")
+	assert.NoError(t, ioutil.WriteFile(grmrFile, []byte(`
+    E -> E + int
+      -> E * int
+      -> int
+  `), 0644))
+	assert.NoError(t, ioutil.WriteFile(lxrFile, []byte(`
+    + /\+/
+    * /\*/
+    int /\d+/
+    space /\s+/ -
+  `), 0644))
+	assert.NoError(t, os.Mkdir(inputs, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(inputs, "a"), []byte("1 + 2"), 0644))
+
+	cvr, err := cover(grmrFile, lxrFile, []string{inputs})
+	assert.NoError(t, err)
+	assert.Equal(t, `productions: 2 of 3 used
+  unused: E -> E * int
+lexer rules: 2 of 3 used
+  unused: *
+`, cvr.String())
+
+	_, err = cover(grmrFile, lxrFile, nil)
+	assert.Error(t, err)
+	_, err = cover("", lxrFile, []string{inputs})
+	assert.Error(t, err)
+}
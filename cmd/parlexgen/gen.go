@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"github.com/adamcolton/parlex"
+	"go/format"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// punctuation gives names to common terminals that are not valid identifiers.
+var punctuation = map[rune]string{
+	'(': "LParen", ')': "RParen", '[': "LBrack", ']': "RBrack", '{': "LBrace",
+	'}': "RBrace", '+': "Plus", '-': "Minus", '*': "Star", '/': "Slash",
+	'%': "Percent", '^': "Caret", '=': "Eq", '<': "Lt", '>': "Gt", '!': "Bang",
+	'?': "Question", ':': "Colon", ';': "Semi", ',': "Comma", '.': "Dot",
+	'&': "Amp", '|': "Pipe", '~': "Tilde", '@': "At", '#': "Hash", '$': "Dollar",
+	'\'': "Quote", '"': "DQuote", '\\': "Backslash",
+}
+
+// ident converts a symbol to an exported Go identifier.
+func ident(symbol string) string {
+	var buf strings.Builder
+	upper := true
+	for _, r := range symbol {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			buf.WriteRune(r)
+		case punctuation[r] != "":
+			buf.WriteString(punctuation[r])
+			upper = true
+		default:
+			upper = true
+		}
+	}
+	str := buf.String()
+	if str == "" || !unicode.IsLetter([]rune(str)[0]) {
+		str = "S" + str
+	}
+	return str
+}
+
+type genType struct {
+	Name, Kind  string
+	Fields      []*genField
+	Productions []*genProduction
+}
+
+type genField struct {
+	Name, Type string
+	NonTerm    bool
+}
+
+type genProduction struct {
+	Idx    int
+	String string
+	Kinds  string
+	Fields []*genChild
+}
+
+type genChild struct {
+	Idx   int
+	Field *genField
+}
+
+type genOp struct {
+	grmr  parlex.Grammar
+	names map[string]string
+	used  map[string]bool
+}
+
+// Generate returns the source for a Go file in the package that defines a
+// struct for each non-terminal in the grammar and a function converting a
+// parse node to that struct.
+func Generate(pkg string, grmr parlex.Grammar) ([]byte, error) {
+	op := &genOp{
+		grmr:  grmr,
+		names: make(map[string]string),
+		used:  make(map[string]bool),
+	}
+	nts := grmr.NonTerminals()
+	for _, nt := range nts {
+		op.names[nt.String()] = op.unique(ident(nt.String()))
+	}
+	types := make([]*genType, len(nts))
+	for i, nt := range nts {
+		types[i] = op.genType(nt)
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, struct {
+		Pkg   string
+		Types []*genType
+	}{pkg, types})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func (op *genOp) unique(name string) string {
+	base := name
+	for i := 2; op.used[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	op.used[name] = true
+	return name
+}
+
+func (op *genOp) genType(nt parlex.Symbol) *genType {
+	t := &genType{
+		Name: op.names[nt.String()],
+		Kind: nt.String(),
+	}
+	fields := make(map[string]*genField)
+	used := map[string]bool{"Node": true, "Production": true}
+	seenKinds := make(map[string]bool)
+	prods := op.grmr.Productions(nt)
+	for i := 0; i < prods.Productions(); i++ {
+		p := prods.Production(i)
+		gp := &genProduction{Idx: i}
+		strs := []string{nt.String(), "->"}
+		kinds := make([]string, p.Symbols())
+		count := make(map[string]int)
+		for j := 0; j < p.Symbols(); j++ {
+			s := p.Symbol(j).String()
+			strs = append(strs, s)
+			kinds[j] = s
+			count[s]++
+			key := s + "#" + strconv.Itoa(count[s])
+			f, ok := fields[key]
+			if !ok {
+				name := ident(s)
+				if count[s] > 1 {
+					name += strconv.Itoa(count[s])
+				}
+				for base, k := name, 2; used[name]; k++ {
+					name = base + "_" + strconv.Itoa(k)
+				}
+				used[name] = true
+				f = &genField{Name: name, Type: "string"}
+				if tn, isNT := op.names[s]; isNT {
+					f.Type, f.NonTerm = "*"+tn, true
+				}
+				fields[key] = f
+				t.Fields = append(t.Fields, f)
+			}
+			gp.Fields = append(gp.Fields, &genChild{Idx: j, Field: f})
+		}
+		gp.String = strings.Join(strs, " ")
+		gp.Kinds = strings.Join(kinds, " ")
+		if seenKinds[gp.Kinds] {
+			continue
+		}
+		seenKinds[gp.Kinds] = true
+		t.Productions = append(t.Productions, gp)
+	}
+	return t
+}
+
+var tmpl = template.Must(template.New("gen").Parse(`// Code generated by parlexgen. DO NOT EDIT.
+
+package {{.Pkg}}
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"strings"
+)
+{{range .Types}}{{$t := .}}
+// {{.Name}} is the {{.Kind}} non-terminal. Production is the index of the
+// production used to derive it:
+//
+{{- range .Productions}}
+//	{{.Idx}}: {{.String}}
+{{- end}}
+type {{.Name}} struct {
+	Node       parlex.ParseNode
+	Production int
+{{- range .Fields}}
+	{{.Name}} {{.Type}}
+{{- end}}
+}
+
+// To{{.Name}} converts a parse node of kind {{.Kind}} to *{{.Name}}.
+func To{{.Name}}(node parlex.ParseNode) (*{{.Name}}, error) {
+	if node == nil {
+		return nil, fmt.Errorf("Expected {{.Kind}}: got nil")
+	}
+	if k := node.Kind().String(); k != {{printf "%q" .Kind}} {
+		return nil, fmt.Errorf("Expected {{.Kind}}: got %s", k)
+	}
+	out := &{{.Name}}{Node: node}
+	{{- if .Fields}}
+	var err error
+	{{- end}}
+	switch kinds := parlexgenKinds(node); kinds {
+	{{- range .Productions}}
+	case {{printf "%q" .Kinds}}:
+		out.Production = {{.Idx}}
+		{{- range .Fields}}
+		{{- if .Field.NonTerm}}
+		if out.{{.Field.Name}}, err = To{{slice .Field.Type 1}}(node.Child({{.Idx}})); err != nil {
+			return nil, err
+		}
+		{{- else}}
+		out.{{.Field.Name}} = node.Child({{.Idx}}).Value()
+		{{- end}}
+		{{- end}}
+	{{- end}}
+	default:
+		return nil, fmt.Errorf("Unknown Production: {{.Kind}} -> %s", kinds)
+	}
+	return out, nil
+}
+{{end}}
+// parlexgenKinds returns the kinds of the children of the node separated by
+// spaces.
+func parlexgenKinds(node parlex.ParseNode) string {
+	strs := make([]string, node.Children())
+	for i := range strs {
+		strs[i] = node.Child(i).Kind().String()
+	}
+	return strings.Join(strs, " ")
+}
+`))
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const calcGrammar = `
+  E -> E + T
+    -> T
+  T -> T * F
+    -> F
+  F -> ( E )
+    -> int
+    -> - F
+`
+
+func TestIdent(t *testing.T) {
+	tests := map[string]string{
+		"int":      "Int",
+		"Stack":    "Stack",
+		"(":        "LParen",
+		"==":       "EqEq",
+		"long_ish": "LongIsh",
+		"1st":      "S1st",
+		"":         "S",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, ident(in), in)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	grmr, err := grammar.New(calcGrammar)
+	assert.NoError(t, err)
+	src, err := Generate("calc", grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	str := string(src)
+
+	expected := []string{
+		"package calc",
+		"type E struct {\n\tNode       parlex.ParseNode\n\tProduction int\n\tE          *E\n\tPlus       string\n\tT          *T\n}",
+		"//\n//\t0: F -> ( E )\n//\t1: F -> int\n//\t2: F -> - F",
+		"func ToF(node parlex.ParseNode) (*F, error) {",
+		"case \"( E )\":\n\t\tout.Production = 0\n\t\tout.LParen = node.Child(0).Value()\n\t\tif out.E, err = ToE(node.Child(1)); err != nil {",
+		"case \"- F\":\n\t\tout.Production = 2\n\t\tout.Minus = node.Child(0).Value()\n\t\tif out.F, err = ToF(node.Child(1)); err != nil {",
+		"return nil, fmt.Errorf(\"Unknown Production: T -> %s\", kinds)",
+	}
+	for _, e := range expected {
+		assert.True(t, strings.Contains(str, e), e)
+	}
+}
+
+func TestRepeatedSymbols(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+      ->
+  `)
+	assert.NoError(t, err)
+	src, err := Generate("calc", grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	str := string(src)
+	assert.True(t, strings.Contains(str, "\tE          *E\n\tOp         string\n\tE2         *E\n"))
+	assert.True(t, strings.Contains(str, "if out.E2, err = ToE(node.Child(2)); err != nil {"))
+	assert.True(t, strings.Contains(str, "case \"\":\n\t\tout.Production = 2\n\tdefault:"))
+}
+
+func TestGenerateFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parlexgen")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	grmrFile := filepath.Join(dir, "calc.grammar")
+	lxrFile := filepath.Join(dir, "calc.lexer")
+	assert.NoError(t, ioutil.WriteFile(grmrFile, []byte(calcGrammar), 0644))
+	assert.NoError(t, ioutil.WriteFile(lxrFile, []byte(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    * /\*/
+    int /\d+/
+  `), 0644))
+
+	_, err = generate(grmrFile, lxrFile, "calc")
+	assert.Equal(t, "Invalid Grammar) undefined terminals: -", err.Error())
+
+	assert.NoError(t, ioutil.WriteFile(lxrFile, []byte(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    - /-/
+    * /\*/
+    int /\d+/
+  `), 0644))
+	src, err := generate(grmrFile, lxrFile, "calc")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(src), "// Code generated by parlexgen. DO NOT EDIT."))
+
+	_, err = generate("", "", "calc")
+	assert.Error(t, err)
+}
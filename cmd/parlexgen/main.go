@@ -0,0 +1,64 @@
+// parlexgen generates typed AST structs from a grammar along with functions
+// that convert a parse tree into them.
+//
+//   parlexgen --grammar calc.grammar --lexer calc.lexer --package calc --out ast.go
+package main
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "parlexgen"
+	app.Usage = "Generate typed AST structs from a grammar"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "grammar, g", Usage: "file holding the grammar rules"},
+		cli.StringFlag{Name: "lexer, l", Usage: "file holding the lexer rules, used to check the terminals"},
+		cli.StringFlag{Name: "package, p", Value: "ast", Usage: "package of the generated file"},
+		cli.StringFlag{Name: "out, o", Usage: "output file, defaults to stdout"},
+	}
+	app.Action = func(c *cli.Context) error {
+		src, err := generate(c.String("grammar"), c.String("lexer"), c.String("package"))
+		if err != nil {
+			return err
+		}
+		if out := c.String("out"); out != "" {
+			return ioutil.WriteFile(out, src, 0644)
+		}
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func generate(grammarFile, lexerFile, pkg string) ([]byte, error) {
+	if grammarFile == "" {
+		return nil, fmt.Errorf("A grammar file is required")
+	}
+	grmr, err := grammar.Load(grammarFile)
+	if err != nil {
+		return nil, err
+	}
+	var terminals []parlex.Symbol
+	if lexerFile != "" {
+		lxr, err := simplelexer.Load(lexerFile)
+		if err != nil {
+			return nil, err
+		}
+		terminals = lxr.Symbols()
+		if err := grammar.Validate(grmr, terminals...); err != nil {
+			return nil, err
+		}
+	}
+	return Generate(pkg, grmr)
+}
@@ -0,0 +1,32 @@
+// scalc is an interactive stack calculator over examples/scalc. The stack is
+// kept from one input to the next, an input that is not finished continues on
+// the next line and an error in the input is pointed to with a caret.
+//
+//   scalc --mode rat
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli"
+	"os"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "scalc"
+	app.Usage = "Reverse Polish Notation calculator"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "mode, m", Value: "float", Usage: "arithmetic to use; float, bigfloat or rat"},
+	}
+	app.Action = func(c *cli.Context) error {
+		s, err := newSession(c.String("mode"))
+		if err != nil {
+			return err
+		}
+		return s.loop(os.Stdin, os.Stdout)
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
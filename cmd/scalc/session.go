@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/examples/scalc"
+	"github.com/adamcolton/parlex/lexeme"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const help = `Type values and operators in Reverse Polish Notation. The stack is kept
+between inputs. Commands:
+  :history  list the inputs
+  !n        run input n from the history again
+  :clear    clear the stack
+  :help     show this message
+  :quit     exit
+`
+
+type session struct {
+	env     *scalc.Env
+	stack   []scalc.Value
+	history []string
+	// pending holds the lines of an input that was incomplete
+	pending string
+}
+
+func newSession(mode string) (*session, error) {
+	m, err := scalc.ModeByName(mode)
+	if err != nil {
+		return nil, err
+	}
+	env := scalc.NewEnv()
+	env.Mode = m
+	return &session{env: env}, nil
+}
+
+// loop reads lines from r until it ends or :quit is entered.
+func (s *session) loop(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	fmt.Fprint(w, "> ")
+	for scanner.Scan() {
+		if !s.eval(scanner.Text(), w) {
+			return nil
+		}
+		if s.pending != "" {
+			fmt.Fprint(w, "... ")
+		} else {
+			fmt.Fprint(w, "> ")
+		}
+	}
+	return scanner.Err()
+}
+
+// eval handles one line. It returns false if the session should end. If the
+// input is incomplete, it is held until the next line and an empty line ends
+// it.
+func (s *session) eval(line string, w io.Writer) bool {
+	if s.pending == "" {
+		switch cmd := strings.TrimSpace(line); {
+		case cmd == "":
+			return true
+		case cmd == ":quit":
+			return false
+		case cmd == ":help":
+			io.WriteString(w, help)
+			return true
+		case cmd == ":clear":
+			s.stack = nil
+			return true
+		case cmd == ":history":
+			for i, h := range s.history {
+				fmt.Fprintf(w, "%3d  %s\n", i+1, strings.Replace(h, "\n", "\n     ", -1))
+			}
+			return true
+		case strings.HasPrefix(cmd, "!"):
+			n, err := strconv.Atoi(cmd[1:])
+			if err != nil || n < 1 || n > len(s.history) {
+				fmt.Fprintf(w, "No input %s in the history\n", cmd[1:])
+				return true
+			}
+			line = s.history[n-1]
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	more := true
+	if s.pending != "" {
+		more = strings.TrimSpace(line) != ""
+		line, s.pending = s.pending+"\n"+line, ""
+	}
+
+	stack, err := s.run(line)
+	if more && parlex.IsIncomplete(err) {
+		s.pending = line
+		return true
+	}
+	s.history = append(s.history, line)
+	if err != nil {
+		s.error(w, line, err)
+		return true
+	}
+	s.stack = stack
+	fmt.Fprintln(w, scalc.Format(stack))
+	return true
+}
+
+// run evaluates the input on top of the stack. The values on the stack are
+// stored in the variables _0, _1 and so on and recalled on a line before the
+// input, so they keep all of their precision in every mode. Positions in the
+// error are moved back to the input.
+func (s *session) run(input string) ([]scalc.Value, error) {
+	var prefix string
+	if len(s.stack) > 0 {
+		strs := make([]string, len(s.stack))
+		for i, v := range s.stack {
+			name := fmt.Sprintf("_%d", i)
+			s.env.Vars[name] = v
+			strs[i] = name + " rcl"
+		}
+		prefix = strings.Join(strs, " ") + "\n"
+	}
+	stack, err := s.env.Eval(prefix + input)
+	var pe *parlex.ParseError
+	if prefix == "" || !errors.As(err, &pe) || pe.Lexeme == nil {
+		return stack, err
+	}
+	moved := *pe
+	line, col := pe.Lexeme.Pos()
+	moved.Lexeme = lexeme.Copy(pe.Lexeme).At(line-1, col).AtOffset(parlex.Offset(pe.Lexeme) - len(prefix))
+	return nil, &moved
+}
+
+// error writes the error. If it is a ParseError at a lexeme, the line of the
+// input with the lexeme is written first with a caret under it.
+func (s *session) error(w io.Writer, input string, err error) {
+	var pe *parlex.ParseError
+	if errors.As(err, &pe) && pe.Lexeme != nil {
+		if line, col := pe.Lexeme.Pos(); line > 0 {
+			src := strings.Split(input, "\n")[line-1]
+			n := utf8.RuneCountInString(pe.Lexeme.Value())
+			if n < 1 {
+				n = 1
+			}
+			fmt.Fprintln(w, "  "+src)
+			fmt.Fprintln(w, "  "+strings.Repeat(" ", col-1)+strings.Repeat("^", n))
+		}
+	}
+	fmt.Fprintln(w, err)
+}
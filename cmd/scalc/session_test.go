@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	s, err := newSession("float")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var buf bytes.Buffer
+	in := strings.NewReader("1 2\n+\n(3 4\n5 sum)\n:history\n!1\n:clear\n:quit\n3\n")
+	assert.NoError(t, s.loop(in, &buf))
+	assert.Equal(t, `> 1 2
+> 3
+> ... 3 12
+>   1  1 2
+  2  +
+  3  (3 4
+     5 sum)
+> 1 2
+3 12 1 2
+> > `, buf.String())
+	assert.Len(t, s.stack, 0)
+}
+
+func TestSessionErrors(t *testing.T) {
+	s, err := newSession("rat")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var buf bytes.Buffer
+	assert.True(t, s.eval("1 3 /", &buf))
+	assert.True(t, s.eval("1 ) 2", &buf))
+	assert.True(t, s.eval("(2", &buf))
+	assert.True(t, s.eval("3 sum)", &buf))
+	assert.True(t, s.eval("(1", &buf))
+	assert.True(t, s.eval("2 ) )", &buf))
+	assert.True(t, s.eval("(1", &buf))
+	assert.True(t, s.eval("", &buf))
+	assert.True(t, s.eval("1 0 /", &buf))
+	assert.True(t, s.eval("3 *", &buf))
+	assert.True(t, s.eval("2 $$ +", &buf))
+	assert.Equal(t, `0
+  1 ) 2
+    ^
+Could Not Parse 1:3) found ): ), expected (, bin, bop, dec, dup, hex, int, name, sci, smp, sop, uop
+0 5
+  2 ) )
+      ^
+Could Not Parse 2:5) found ): ), expected (, bin, dup, hex, int, name, quote, sci, smp, sop
+Could Not Parse) found end of input, expected (, ), bin, dec, dup, hex, int, name, sci, smp, sop, uop
+Divide By Zero
+0 15
+  2 $$ +
+    ^^
+Could Not Parse 1:3) found Error: $$, expected (, ?, bin, bop, dec, dup, hex, int, name, sci, smp, sop, uop
+`, buf.String())
+
+	_, err = newSession("decimal")
+	assert.EqualError(t, err, "Unknown Mode: decimal")
+}
@@ -0,0 +1,51 @@
+package parlex
+
+import (
+	"context"
+)
+
+// ParseContext parses the lexemes with the parser. If the parser is a
+// ContextParser, the parse will stop when the context is done. Otherwise the
+// context is only checked before parsing starts. If the parse fails, the error
+// from ParseErr is returned if the parser is an ErrParser, otherwise
+// ErrCouldNotParse is returned.
+func ParseContext(ctx context.Context, parser Parser, lexemes []Lexeme) (ParseNode, error) {
+	if cp, ok := parser.(ContextParser); ok {
+		return cp.ParseContext(ctx, lexemes)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return parseErr(parser, lexemes)
+}
+
+// interruptInterval is the number of calls to Interrupt.Check between checks
+// of the context.
+const interruptInterval = 256
+
+// Interrupt is used by parsers to stop when a context is done. Checking the
+// context on every step of a parse is expensive, so it is only checked on the
+// first call to Check and every few hundred calls after that. The zero value
+// is never interrupted.
+type Interrupt struct {
+	Ctx context.Context
+	Err error
+	n   int
+}
+
+// Check returns true if the context is done. Once it returns true, it will
+// always return true and Err will hold the context's error.
+func (i *Interrupt) Check() bool {
+	if i.Ctx == nil {
+		return false
+	}
+	if i.Err != nil {
+		return true
+	}
+	i.n++
+	if i.n%interruptInterval != 1 {
+		return false
+	}
+	i.Err = i.Ctx.Err()
+	return i.Err != nil
+}
@@ -0,0 +1,61 @@
+package parlex
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ctxParser struct {
+	ctx context.Context
+}
+
+func (p *ctxParser) Parse(lexemes []Lexeme) ParseNode { return nil }
+
+func (p *ctxParser) ParseContext(ctx context.Context, lexemes []Lexeme) (ParseNode, error) {
+	p.ctx = ctx
+	return nil, ctx.Err()
+}
+
+type nilParser struct{}
+
+func (nilParser) Parse(lexemes []Lexeme) ParseNode { return nil }
+
+func TestParseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ctxParser{}
+	_, err := ParseContext(ctx, p, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ctx, p.ctx)
+
+	_, err = ParseContext(ctx, nilParser{}, nil)
+	assert.Equal(t, ErrCouldNotParse, err)
+
+	cancel()
+	_, err = ParseContext(ctx, p, nil)
+	assert.Equal(t, context.Canceled, err)
+	_, err = ParseContext(ctx, nilParser{}, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestInterrupt(t *testing.T) {
+	var zero Interrupt
+	for i := 0; i < 2*interruptInterval; i++ {
+		assert.False(t, zero.Check())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	intr := Interrupt{Ctx: ctx}
+	assert.False(t, intr.Check())
+	cancel()
+	// the context is not checked again until the interval has passed
+	for i := 1; i < interruptInterval; i++ {
+		assert.False(t, intr.Check())
+	}
+	assert.True(t, intr.Check())
+	assert.True(t, intr.Check())
+	assert.Equal(t, context.Canceled, intr.Err)
+
+	intr = Interrupt{Ctx: ctx}
+	assert.True(t, intr.Check())
+}
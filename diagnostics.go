@@ -0,0 +1,175 @@
+package parlex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stage is the step of a run that found a problem.
+type Stage byte
+
+// Stages of a run
+const (
+	StageLex Stage = iota
+	StageParse
+	StageReduce
+)
+
+var stageStrs = []string{"lex", "parse", "reduce"}
+
+func (s Stage) String() string {
+	if int(s) < len(stageStrs) {
+		return stageStrs[s]
+	}
+	return fmt.Sprintf("Stage(%d)", s)
+}
+
+// Diagnostic is one problem found by RunDiagnostics. Line and Col start at 1
+// and are 0 if the position is not known. Offset is the byte offset of the
+// problem in the input, or -1 if it is not known, and Len is the number of bytes
+// it covers, so an editor can underline it. Err is the error that was found.
+type Diagnostic struct {
+	Stage       Stage
+	Line, Col   int
+	Offset, Len int
+	Msg         string
+	Err         error
+}
+
+// String describes the diagnostic on one line, as in
+// "2:5: parse: found op: *, expected int".
+func (d Diagnostic) String() string {
+	if d.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Col, d.Stage, d.Msg)
+	}
+	return fmt.Sprintf("%s: %s", d.Stage, d.Msg)
+}
+
+// Diagnostics are all the problems found by RunDiagnostics in the order they
+// were found. A non-empty Diagnostics can be returned as an error.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	strs := make([]string, len(ds))
+	for i, d := range ds {
+		strs[i] = d.String()
+	}
+	return strings.Join(strs, "\n")
+}
+
+// Err returns the Diagnostics as an error or nil if there are none.
+func (ds Diagnostics) Err() error {
+	if len(ds) == 0 {
+		return nil
+	}
+	return ds
+}
+
+// RunDiagnostics performs the lexing, parsing and reducing for an input the
+// same as Run, but instead of stopping at the first error it collects every
+// problem it can find. Every LexError is reported and the other lexemes are
+// still parsed, so a parse error can be reported too. A reducer that panics is
+// reported instead of crashing. The tree is returned if the parse succeeded,
+// even if there are lex errors.
+func RunDiagnostics(input string, lexer Lexer, parser Parser, reducer Reducer) (ParseNode, Diagnostics) {
+	return newStages(lexer, parser, reducer, nil).diagnostics(input)
+}
+
+// RunDiagnostics using the Lexer, Parser and Reducer in the Runner, including
+// its middleware.
+func (r *Runner) RunDiagnostics(input string) (ParseNode, Diagnostics) {
+	return r.stages.diagnostics(input)
+}
+
+func (s stages) diagnostics(input string) (ParseNode, Diagnostics) {
+	lexemes := s.lex(input)
+	if lexemes == nil {
+		return nil, Diagnostics{{Stage: StageLex, Offset: -1, Msg: ErrCouldNotLex.Error(), Err: ErrCouldNotLex}}
+	}
+
+	var ds Diagnostics
+	kept := make([]Lexeme, 0, len(lexemes))
+	for _, lx := range lexemes {
+		le, ok := lx.(LexError)
+		if !ok {
+			kept = append(kept, lx)
+			continue
+		}
+		d := lexemeDiagnostic(StageLex, lx)
+		d.Msg = fmt.Sprintf("unexpected %q", le.Value())
+		d.Err = le
+		ds = append(ds, d)
+	}
+
+	parseTree, err := s.parse(kept)
+	if err == nil && parseTree == nil {
+		err = ErrCouldNotParse
+	}
+	if err != nil {
+		return nil, append(ds, parseDiagnostic(err, input))
+	}
+
+	parseTree, d := s.reduceDiagnostic(parseTree, input)
+	if d != nil {
+		ds = append(ds, *d)
+	}
+	return parseTree, ds
+}
+
+func lexemeDiagnostic(stage Stage, lx Lexeme) Diagnostic {
+	d := Diagnostic{
+		Stage:  stage,
+		Offset: Offset(lx),
+	}
+	d.Line, d.Col = lx.Pos()
+	if d.Offset >= 0 {
+		d.Len = len(lx.Value())
+	}
+	return d
+}
+
+func parseDiagnostic(err error, input string) Diagnostic {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		return Diagnostic{Stage: StageParse, Offset: -1, Msg: err.Error(), Err: err}
+	}
+	expected := "end of input"
+	if len(pe.Expected) > 0 {
+		strs := make([]string, len(pe.Expected))
+		for i, s := range pe.Expected {
+			strs[i] = s.String()
+		}
+		expected = strings.Join(strs, ", ")
+	}
+	var d Diagnostic
+	found := "end of input"
+	if pe.Lexeme != nil {
+		d = lexemeDiagnostic(StageParse, pe.Lexeme)
+		found = LexemeString(pe.Lexeme)
+	} else {
+		// the input ended, so the problem is at the end
+		d = Diagnostic{
+			Stage:  StageParse,
+			Line:   strings.Count(input, "\n") + 1,
+			Col:    len(input) - strings.LastIndexByte(input, '\n'),
+			Offset: len(input),
+		}
+	}
+	d.Msg = fmt.Sprintf("found %s, expected %s", found, expected)
+	d.Err = err
+	return d
+}
+
+func (s stages) reduceDiagnostic(node ParseNode, input string) (pn ParseNode, d *Diagnostic) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("Reducer Panic: %v", r)
+			pn = nil
+			d = &Diagnostic{Stage: StageReduce, Offset: -1, Msg: err.Error(), Err: err}
+		}
+	}()
+	if pn = s.reduce(node, input); pn == nil {
+		d = &Diagnostic{Stage: StageReduce, Offset: -1, Msg: ErrCouldNotReduce.Error(), Err: ErrCouldNotReduce}
+	}
+	return
+}
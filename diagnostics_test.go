@@ -0,0 +1,102 @@
+package parlex
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// posLx is a lexeme that knows its position.
+type posLx struct {
+	lx
+	line, col, offset int
+}
+
+func (l *posLx) Pos() (int, int) { return l.line, l.col }
+func (l *posLx) Offset() int     { return l.offset }
+
+type badLx struct{ posLx }
+
+func (l *badLx) Error() string { return "bad " + l.v }
+
+// fieldLexer makes a lexeme for each field on one line, a field starting with
+// ! is a LexError.
+type fieldLexer struct{}
+
+func (fieldLexer) Lex(str string) []Lexeme {
+	lxs := []Lexeme{}
+	offset := 0
+	for _, f := range strings.Fields(str) {
+		offset += strings.Index(str[offset:], f)
+		p := posLx{lx: lx{k: "word", v: f}, line: 1, col: offset + 1, offset: offset}
+		if f[0] == '!' {
+			lxs = append(lxs, &badLx{p})
+		} else {
+			lxs = append(lxs, &p)
+		}
+		offset += len(f)
+	}
+	return lxs
+}
+
+// oddParser fails at the last lexeme if there is an odd number of them.
+type oddParser struct{}
+
+func (oddParser) Parse(lexemes []Lexeme) ParseNode {
+	pn, _ := oddParser{}.ParseErr(lexemes)
+	return pn
+}
+
+func (oddParser) ParseErr(lexemes []Lexeme) (ParseNode, error) {
+	if len(lexemes)%2 != 0 {
+		return nil, NewParseError(len(lexemes)-1, lexemes, []Symbol{symbol("word")})
+	}
+	if len(lexemes) == 0 {
+		return nil, NewParseError(0, lexemes, []Symbol{symbol("word")})
+	}
+	return &testNode{}, nil
+}
+
+type panicReducer struct{}
+
+func (panicReducer) Reduce(ParseNode) ParseNode { panic("oops") }
+func (panicReducer) Can(ParseNode) bool         { return true }
+
+func TestRunDiagnostics(t *testing.T) {
+	r := New(fieldLexer{}, oddParser{}, nil)
+
+	node, ds := r.RunDiagnostics("a !b c")
+	assert.NotNil(t, node)
+	if assert.Len(t, ds, 1) {
+		assert.Equal(t, Diagnostic{Stage: StageLex, Line: 1, Col: 3, Offset: 2, Len: 2, Msg: `unexpected "!b"`, Err: ds[0].Err}, ds[0])
+		assert.Equal(t, `1:3: lex: unexpected "!b"`, ds[0].String())
+	}
+
+	node, ds = r.RunDiagnostics("a !b !c d e")
+	assert.Nil(t, node)
+	if assert.Len(t, ds, 3) {
+		assert.Equal(t, StageParse, ds[2].Stage)
+		assert.Equal(t, 10, ds[2].Offset)
+		assert.Equal(t, 1, ds[2].Len)
+		assert.Equal(t, "found word: e, expected word", ds[2].Msg)
+		var pe *ParseError
+		assert.True(t, errors.As(ds[2].Err, &pe))
+		assert.Equal(t, "1:3: lex: unexpected \"!b\"\n1:6: lex: unexpected \"!c\"\n1:11: parse: found word: e, expected word", ds.Error())
+	}
+
+	_, ds = r.RunDiagnostics("x y\n  ")
+	if assert.Len(t, ds, 0) {
+		assert.NoError(t, ds.Err())
+	}
+
+	_, ds = r.RunDiagnostics("\n !a")
+	if assert.Len(t, ds, 2) {
+		assert.Equal(t, Diagnostic{Stage: StageParse, Line: 2, Col: 4, Offset: 4, Msg: "found end of input, expected word", Err: ds[1].Err}, ds[1])
+	}
+
+	_, ds = RunDiagnostics("a b", fieldLexer{}, oddParser{}, panicReducer{})
+	if assert.Len(t, ds, 1) {
+		assert.Equal(t, "reduce: Reducer Panic: oops", ds[0].String())
+	}
+}
@@ -1,5 +1,12 @@
 package parlex
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type strErr string
 
 func (err strErr) Error() string { return string(err) }
@@ -11,3 +18,128 @@ const (
 	ErrCouldNotReduce = strErr("Could Not Reduce")
 	ErrBadGrammar     = strErr("Bad Grammar")
 )
+
+// ParseError describes why a parse failed. Pos is the index of the lexeme
+// furthest into the input that the parser could not get past and Lexeme is the
+// lexeme at that position. If the parser reached the end of the input, Pos
+// will be the number of lexemes and Lexeme will be nil. Expected holds the
+// kinds of lexemes that would have allowed the parse to continue; if it is
+// empty, the parser expected the input to end.
+type ParseError struct {
+	Pos      int
+	Lexeme   Lexeme
+	Expected []Symbol
+}
+
+// NewParseError creates a ParseError for a parse that failed at pos. The
+// expected symbols are sorted and duplicates are removed.
+func NewParseError(pos int, lexemes []Lexeme, expected []Symbol) *ParseError {
+	e := &ParseError{
+		Pos: pos,
+	}
+	if pos < len(lexemes) {
+		e.Lexeme = lexemes[pos]
+	}
+	seen := make(map[string]bool)
+	for _, s := range expected {
+		if str := s.String(); !seen[str] {
+			seen[str] = true
+			e.Expected = append(e.Expected, s)
+		}
+	}
+	sort.Slice(e.Expected, func(i, j int) bool {
+		return e.Expected[i].String() < e.Expected[j].String()
+	})
+	return e
+}
+
+func (e *ParseError) Error() string {
+	found := "end of input"
+	pos := ""
+	if e.Lexeme != nil {
+		found = LexemeString(e.Lexeme)
+		if pl, pc := e.Lexeme.Pos(); pl > 0 {
+			pos = fmt.Sprintf(" %d:%d", pl, pc)
+		}
+	}
+	expected := "end of input"
+	if len(e.Expected) > 0 {
+		strs := make([]string, len(e.Expected))
+		for i, s := range e.Expected {
+			strs[i] = s.String()
+		}
+		expected = strings.Join(strs, ", ")
+	}
+	return fmt.Sprintf("%s%s) found %s, expected %s", ErrCouldNotParse, pos, found, expected)
+}
+
+// Incomplete returns true if the parse failed because the input ended while
+// more lexemes were expected. A REPL can use this to ask for more input
+// instead of reporting a syntax error.
+func (e *ParseError) Incomplete() bool {
+	return e.Lexeme == nil && len(e.Expected) > 0
+}
+
+// IsIncomplete returns true if err is a *ParseError for input that ended while
+// more lexemes were expected. Any other error, including a ParseError for a
+// lexeme that could not be parsed, is a syntax error and will not be fixed by
+// more input.
+func IsIncomplete(err error) bool {
+	var pe *ParseError
+	return errors.As(err, &pe) && pe.Incomplete()
+}
+
+// Unwrap allows errors.Is(err, ErrCouldNotParse) to identify a ParseError.
+func (e *ParseError) Unwrap() error {
+	return ErrCouldNotParse
+}
+
+// DefinitionError is an error in a line of a definition, like a lexer rule or
+// a grammar production. File is the name of the file the definition was read
+// from, if there is one, Line starts at 1 and Text is the line itself. Err is
+// the cause, like the error from compiling a regular expression.
+type DefinitionError struct {
+	File string
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *DefinitionError) Error() string {
+	pos := fmt.Sprintf("line %d", e.Line)
+	if e.File != "" {
+		pos = e.File + " " + pos
+	}
+	return fmt.Sprintf("%s at %s (%s)", e.Err, pos, strings.TrimSpace(e.Text))
+}
+
+// Unwrap returns the cause of the DefinitionError.
+func (e *DefinitionError) Unwrap() error {
+	return e.Err
+}
+
+// DefinitionErrorAt returns a *DefinitionError for an error in a line of a
+// definition. If err is already a *DefinitionError, because it came from a
+// file the line included, it is returned as it is.
+func DefinitionErrorAt(file string, line int, text string, err error) error {
+	if _, ok := err.(*DefinitionError); ok {
+		return err
+	}
+	return &DefinitionError{
+		File: file,
+		Line: line,
+		Text: text,
+		Err:  err,
+	}
+}
+
+// DefinitionErrors holds every error found in a definition.
+type DefinitionErrors []error
+
+func (errs DefinitionErrors) Error() string {
+	strs := make([]string, len(errs))
+	for i, err := range errs {
+		strs[i] = err.Error()
+	}
+	return strings.Join(strs, "\n")
+}
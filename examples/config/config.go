@@ -0,0 +1,325 @@
+// Package config is a configuration language in the style of INI and TOML,
+// built end to end with parlex. It has sections, key/value entries, arrays and
+// comments, and the comments survive formatting.
+//
+//   # the name shown to users
+//   title = "My App"
+//
+//   [server.main]
+//   host  = "localhost" # or an address
+//   ports = [8080, 8081]
+//
+// The lexer keeps comments as trivia with KeepTrivia and tree.AttachComments
+// attaches them to the nodes of the reduced tree, so Format can write each
+// comment back next to the entry, section or array element it was written
+// with.
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/lalr"
+	"github.com/adamcolton/parlex/tree"
+	"strconv"
+	"strings"
+)
+
+const (
+	lexerRules = `
+    keywords true false
+    key     /[A-Za-z_][A-Za-z0-9_-]*/
+    string  /"(?:[^"\\\n]|\\.)*"/
+    float   /[+-]?\d+(?:\.\d+)?[eE][+-]?\d+|[+-]?\d+\.\d+/
+    int     /[+-]?\d+/
+    =       /=/
+    [       /\[/
+    ]       /\]/
+    ,       /,/
+    .       /\./
+    comment /#[^\n]*/ -
+    space   /\s+/ -
+  `
+	grammarRules = `
+    File     -> Entries Sections
+    Entries  -> Entries Entry
+             ->
+    Sections -> Sections Section
+             ->
+    Section  -> [ Name ] Entries
+    Name     -> Name . key
+             -> key
+    Entry    -> key = Value
+    Value    -> string
+             -> int
+             -> float
+             -> true
+             -> false
+             -> Array
+    Array    -> [ ]
+             -> [ Values ]
+             -> [ Values , ]
+    Values   -> Values , Value
+             -> Value
+  `
+)
+
+var (
+	// Lexer produces the tokens and keeps comments as trivia.
+	Lexer = parlex.MustLexer(simplelexer.New(lexerRules)).(*simplelexer.Lexer).KeepTrivia()
+	// Grammar is the grammar of the language.
+	Grammar = parlex.MustGrammar(grammar.New(grammarRules))
+	// Parser is the LALR parser for the Grammar.
+	Parser = parlex.MustParser(lalr.New(Grammar))
+	// Reducer reduces the parse tree to a File with an Entry for each entry
+	// before the first section and a Section for each section. The value of
+	// a Section is its dotted name and the value of an Entry is its key. The
+	// commas in an Array are kept so the comments after them have a node.
+	Reducer = tree.Reducer{
+		"File":     tree.PromoteChildrenOf(1).PromoteChildrenOf(0),
+		"Entries":  tree.PromoteChildrenOf(0),
+		"Sections": tree.PromoteChildrenOf(0),
+		"Section":  tree.RemoveChildren(0, 1).PromoteChildValue(0).PromoteChildrenOf(0),
+		"Name":     joinName,
+		"Entry":    tree.RemoveChild(1).PromoteChildValue(0),
+		"Value":    tree.PromoteSingleChild,
+		"Array":    tree.RemoveChildren(0, -1).PromoteChildrenOf(0),
+		"Values":   tree.If(tree.ChildIs(0, "Values"), tree.PromoteChildrenOf(0), nil),
+	}
+)
+
+// joinName replaces the children of a Name with its dotted value.
+func joinName(node *tree.PN) {
+	var parts []string
+	for _, c := range node.C {
+		parts = append(parts, c.Value())
+	}
+	node.Lexeme = lexeme.New(node.Kind()).Set(strings.Join(parts, "")).At(node.C[0].Pos())
+	node.C = nil
+}
+
+// Document is a parsed configuration with its comments.
+type Document struct {
+	Root     *tree.PN
+	Comments *tree.Comments
+}
+
+// Parse a configuration.
+func Parse(src string) (*Document, error) {
+	lxs := Lexer.Lex(src)
+	if errs := parlex.LexErrors(lxs); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	pn, err := parlex.ParseContext(context.Background(), Parser, lxs)
+	if err != nil {
+		return nil, err
+	}
+	root := Reducer.RawReduce(pn)
+	return &Document{
+		Root:     root,
+		Comments: tree.AttachComments(root, lxs, "comment"),
+	}, nil
+}
+
+// Format parses the configuration and writes it back in the standard layout;
+// one entry per line, a blank line before each section and arrays on one line
+// unless they hold comments. Every comment is kept.
+func Format(src string) (string, error) {
+	d, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+// String writes the document in the standard layout.
+func (d *Document) String() string {
+	var buf strings.Builder
+	for i, c := range d.Root.C {
+		if c.Kind().String() == "Section" {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			d.leading(&buf, c, "")
+			buf.WriteString("[" + c.Value() + "]")
+			d.trailing(&buf, c)
+			buf.WriteString("\n")
+			for _, e := range c.C {
+				d.entry(&buf, e)
+			}
+			continue
+		}
+		d.entry(&buf, c)
+	}
+	if cs := d.Comments.Trailing[d.Root]; len(cs) > 0 {
+		if len(d.Root.C) > 0 {
+			buf.WriteString("\n")
+		}
+		for _, c := range cs {
+			buf.WriteString(c.Value() + "\n")
+		}
+	}
+	return buf.String()
+}
+
+func (d *Document) entry(buf *strings.Builder, e *tree.PN) {
+	d.leading(buf, e, "")
+	buf.WriteString(e.Value() + " = ")
+	d.value(buf, e.C[0], "")
+	d.trailing(buf, e)
+	buf.WriteString("\n")
+}
+
+func (d *Document) value(buf *strings.Builder, v *tree.PN, indent string) {
+	if v.Kind().String() != "Array" {
+		buf.WriteString(v.Value())
+		return
+	}
+	values := elements(v)
+	if !d.hasComments(v) {
+		buf.WriteString("[")
+		for i, e := range values {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			d.value(buf, e, indent)
+		}
+		buf.WriteString("]")
+		return
+	}
+	// an array with comments has an element on each line, followed by the
+	// comments on the element and the comma after it
+	inner := indent + "  "
+	buf.WriteString("[")
+	d.trailing(buf, v)
+	for i, c := range v.C {
+		if c.Kind().String() == "," {
+			continue
+		}
+		buf.WriteString("\n")
+		d.leading(buf, c, inner)
+		buf.WriteString(inner)
+		d.value(buf, c, inner)
+		buf.WriteString(",")
+		d.trailing(buf, c)
+		if i+1 < len(v.C) && v.C[i+1].Kind().String() == "," {
+			d.trailing(buf, v.C[i+1])
+		}
+	}
+	buf.WriteString("\n" + indent + "]")
+}
+
+// elements returns the values of an array without the commas.
+func elements(array *tree.PN) []*tree.PN {
+	var out []*tree.PN
+	for _, c := range array.C {
+		if c.Kind().String() != "," {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (d *Document) hasComments(v *tree.PN) bool {
+	if len(d.Comments.Leading[v]) > 0 || len(d.Comments.Trailing[v]) > 0 {
+		return true
+	}
+	for _, c := range v.C {
+		if d.hasComments(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Document) leading(buf *strings.Builder, n *tree.PN, indent string) {
+	for _, c := range d.Comments.Leading[n] {
+		buf.WriteString(indent + c.Value() + "\n")
+	}
+}
+
+// trailing writes the comments after a node on the same line. A comment runs
+// to the end of the line, so only one fits; any others are joined to it.
+func (d *Document) trailing(buf *strings.Builder, n *tree.PN) {
+	cs := d.Comments.Trailing[n]
+	if len(cs) == 0 {
+		return
+	}
+	strs := make([]string, len(cs))
+	for i, c := range cs {
+		strs[i] = strings.TrimSpace(strings.TrimPrefix(c.Value(), "#"))
+	}
+	buf.WriteString(" # " + strings.Join(strs, " "))
+}
+
+// Map converts the document to nested maps. A section is a map inside the
+// maps of the parts of its dotted name. Strings are unquoted, int is int64,
+// float is float64, true and false are bool and an array is []interface{}.
+// A key that is set twice is an error.
+func (d *Document) Map() (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	for _, c := range d.Root.C {
+		if c.Kind().String() != "Section" {
+			if err := set(out, "", c); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		m := out
+		for _, part := range strings.Split(c.Value(), ".") {
+			next, ok := m[part]
+			if !ok {
+				next = make(map[string]interface{})
+				m[part] = next
+			}
+			if m, ok = next.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("Duplicate Key: %s", c.Value())
+			}
+		}
+		for _, e := range c.C {
+			if err := set(m, c.Value()+".", e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+func set(m map[string]interface{}, prefix string, e *tree.PN) error {
+	if _, ok := m[e.Value()]; ok {
+		return fmt.Errorf("Duplicate Key: %s%s", prefix, e.Value())
+	}
+	v, err := value(e.C[0])
+	if err != nil {
+		return err
+	}
+	m[e.Value()] = v
+	return nil
+}
+
+func value(v *tree.PN) (interface{}, error) {
+	switch kind := v.Kind().String(); kind {
+	case "string":
+		return strconv.Unquote(v.Value())
+	case "int":
+		return strconv.ParseInt(v.Value(), 10, 64)
+	case "float":
+		return strconv.ParseFloat(v.Value(), 64)
+	case "true", "false":
+		return kind == "true", nil
+	case "Array":
+		values := elements(v)
+		out := make([]interface{}, len(values))
+		for i, e := range values {
+			var err error
+			if out[i], err = value(e); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("Unknown Kind: %s", v.Kind().String())
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"github.com/adamcolton/parlex/parlextest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const src = `# the name shown to users
+title="My App"   # required
+debug = true
+  ratio = 1.5e-2
+# servers
+[server.main]   # the first one
+host = "localhost"
+ports = [ 8080,
+  # the backup
+  8081, # not used
+]
+[empty]
+[client]
+tags = [["a", "b"], [] ,]
+count = -3
+# the end
+`
+
+func TestParse(t *testing.T) {
+	d, err := Parse(src)
+	if !assert.NoError(t, err) {
+		return
+	}
+	parlextest.AssertTree(t, d.Root, `
+    (File
+      (Entry "title" (string "\"My App\""))
+      (Entry "debug" (true "true"))
+      (Entry "ratio" (float "1.5e-2"))
+      (Section "server.main"
+        (Entry "host" (string "\"localhost\""))
+        (Entry "ports" (Array (int "8080") (, ",") (int "8081") (, ","))))
+      (Section "empty")
+      (Section "client"
+        (Entry "tags" (Array
+          (Array (string "\"a\"") (, ",") (string "\"b\""))
+          (, ",")
+          Array
+          (, ",")))
+        (Entry "count" (int "-3"))))
+  `)
+}
+
+func TestFormat(t *testing.T) {
+	out, err := Format(src)
+	assert.NoError(t, err)
+	assert.Equal(t, `# the name shown to users
+title = "My App" # required
+debug = true
+ratio = 1.5e-2
+
+# servers
+[server.main] # the first one
+host = "localhost"
+ports = [
+  8080,
+  # the backup
+  8081, # not used
+]
+
+[empty]
+
+[client]
+tags = [["a", "b"], []]
+count = -3
+
+# the end
+`, out)
+
+	// formatting is stable
+	again, err := Format(out)
+	assert.NoError(t, err)
+	assert.Equal(t, out, again)
+
+	_, err = Format("a = [1 2]")
+	assert.EqualError(t, err, "Could Not Parse 1:8) found int: 2, expected ,, [, ], key")
+	_, err = Format("a = 'b'")
+	assert.EqualError(t, err, "Lex Error 1:5) '")
+}
+
+func TestMap(t *testing.T) {
+	d, err := Parse(src)
+	if !assert.NoError(t, err) {
+		return
+	}
+	m, err := d.Map()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"title": "My App",
+		"debug": true,
+		"ratio": 0.015,
+		"server": map[string]interface{}{
+			"main": map[string]interface{}{
+				"host":  "localhost",
+				"ports": []interface{}{int64(8080), int64(8081)},
+			},
+		},
+		"empty": map[string]interface{}{},
+		"client": map[string]interface{}{
+			"tags":  []interface{}{[]interface{}{"a", "b"}, []interface{}{}},
+			"count": int64(-3),
+		},
+	}, m)
+
+	for _, bad := range []string{
+		"a = 1\na = 2",
+		"a = 1\n[a]",
+		"[a]\nb = 1\n[a]\nb = 2",
+	} {
+		d, err := Parse(bad)
+		if assert.NoError(t, err) {
+			_, err = d.Map()
+			assert.Error(t, err, bad)
+		}
+	}
+	d, _ = Parse("[a]\nb = 1\n[a]\nb = 2")
+	_, err = d.Map()
+	assert.EqualError(t, err, "Duplicate Key: a.b")
+}
@@ -0,0 +1,228 @@
+// Package dsv reads delimiter separated values, like CSV and TSV, with the
+// stream parser. The records are handed over one at a time as the input is
+// read, so a file of any size is read in constant memory.
+//
+// A Dialect describes the format. The lexer rules are built from it and the
+// grammar is the same for every dialect.
+//   File    -> Record Records
+//   Records -> eol Record Records
+//           ->
+//   Record  -> Field Fields
+//   Fields  -> sep Field Fields
+//           ->
+//   Field   -> quoted
+//           -> field
+//           ->
+package dsv
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/stream"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Dialect describes a format. Fields are separated by the Delimiter and
+// records by a line break, either \n or \r\n. A field that starts with the
+// Quote can hold the delimiter and line breaks until the closing Quote. Inside
+// a quoted field, the Quote is written twice or, if Escape is set, the Escape
+// comes before any character to take it as it is. If Quote is 0, fields cannot
+// be quoted.
+type Dialect struct {
+	Delimiter rune
+	Quote     rune
+	Escape    rune
+}
+
+// CSV is the format of RFC 4180.
+var CSV = Dialect{Delimiter: ',', Quote: '"'}
+
+// TSV is tab separated values, which are not quoted.
+var TSV = Dialect{Delimiter: '\t'}
+
+var grmr = parlex.MustGrammar(grammar.New(`
+  File    -> Record Records
+  Records -> eol Record Records
+          ->
+  Record  -> Field Fields
+  Fields  -> sep Field Fields
+          ->
+  Field   -> quoted
+          -> field
+          ->
+`))
+
+var prsr = func() *stream.Stream {
+	s, err := stream.New(grmr)
+	if err != nil {
+		panic(err)
+	}
+	return s.InlineSymbols("Records", "Fields")
+}()
+
+// Reader reads one dialect.
+type Reader struct {
+	Dialect
+	lxr *simplelexer.Lexer
+}
+
+// New returns a Reader for the dialect. The Delimiter, Quote and Escape must
+// be different from each other and from the line breaks.
+func New(d Dialect) (*Reader, error) {
+	used := map[rune]bool{'\r': true, '\n': true}
+	for _, r := range []rune{d.Delimiter, d.Quote, d.Escape} {
+		if r == 0 {
+			continue
+		}
+		if used[r] {
+			return nil, fmt.Errorf("Bad Dialect: %q is used twice", r)
+		}
+		used[r] = true
+	}
+	if d.Delimiter == 0 {
+		return nil, fmt.Errorf("Bad Dialect: no Delimiter")
+	}
+	if d.Escape != 0 && d.Quote == 0 {
+		return nil, fmt.Errorf("Bad Dialect: Escape without Quote")
+	}
+
+	lxr, err := simplelexer.New("")
+	if err != nil {
+		return nil, err
+	}
+	sep, not := esc(d.Delimiter), esc(d.Delimiter)
+	rules := [][2]string{
+		{"sep", sep},
+		{"eol", `\r?\n`},
+	}
+	if d.Quote != 0 {
+		q := esc(d.Quote)
+		not += q
+		inner := `[^` + q + `]|` + q + q
+		if d.Escape != 0 {
+			e := esc(d.Escape)
+			inner = `[^` + q + e + `]|` + e + `(?s:.)`
+		}
+		rules = append(rules, [2]string{"quoted", q + `(?:` + inner + `)*` + q})
+	}
+	rules = append(rules, [2]string{"field", `[^` + not + `\r\n]+`})
+	for _, r := range rules {
+		if err := lxr.Add(stringsymbol.Symbol(r[0]), regexp.MustCompile(r[1]), false); err != nil {
+			return nil, err
+		}
+	}
+	return &Reader{
+		Dialect: d,
+		lxr:     lxr,
+	}, nil
+}
+
+// esc writes a rune so it is taken as itself in a regexp, including in a
+// character class.
+func esc(r rune) string {
+	return fmt.Sprintf(`\x{%x}`, r)
+}
+
+// Read calls fn with each record in the input as it is read. The slice is
+// reused for the next record, so it must be copied to be kept. Blank lines
+// are skipped. If fn returns an error, reading stops and it is returned.
+func (r *Reader) Read(in io.Reader, fn func(record []string) error) error {
+	var record []string
+	// tokens counts the lexemes in the record to tell a blank line from a
+	// record with an empty field
+	var field string
+	tokens := 0
+	src := &source{Stream: r.lxr.LexReader(in)}
+	return prsr.Run(src, func(e stream.Event) error {
+		switch e.Kind {
+		case stream.Open:
+			switch e.Symbol {
+			case "Record":
+				record, tokens = record[:0], 0
+			case "Field":
+				field = ""
+			}
+		case stream.Token:
+			tokens++
+			switch e.Symbol {
+			case "field":
+				field = e.Lexeme.Value()
+			case "quoted":
+				field = r.unquote(e.Lexeme.Value())
+			}
+		case stream.Close:
+			switch e.Symbol {
+			case "Field":
+				record = append(record, field)
+			case "Record":
+				if tokens > 0 {
+					return fn(record)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ReadAll reads every record in the input.
+func (r *Reader) ReadAll(in io.Reader) ([][]string, error) {
+	var out [][]string
+	err := r.Read(in, func(record []string) error {
+		out = append(out, append([]string(nil), record...))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *Reader) unquote(s string) string {
+	s = s[len(string(r.Quote)) : len(s)-len(string(r.Quote))]
+	if r.Escape == 0 {
+		q := string(r.Quote)
+		return strings.Replace(s, q+q, q, -1)
+	}
+	var buf strings.Builder
+	escaped := false
+	for _, c := range s {
+		if c == r.Escape && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+// source stops the stream at a lexeme that could not be lexed and returns it
+// as the error.
+type source struct {
+	*simplelexer.Stream
+	err error
+}
+
+func (s *source) Next() parlex.Lexeme {
+	if s.err != nil {
+		return nil
+	}
+	lx := s.Stream.Next()
+	if le, ok := lx.(parlex.LexError); ok {
+		s.err = le
+		return nil
+	}
+	return lx
+}
+
+func (s *source) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.Stream.Err()
+}
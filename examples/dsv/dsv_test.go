@@ -0,0 +1,117 @@
+package dsv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	r, err := New(CSV)
+	if !assert.NoError(t, err) {
+		return
+	}
+	src := "name,quote\r\nAda,\"said \"\"hi, there\"\"\"\n\n\"multi\nline\",\nlast,\"\""
+	records, err := r.ReadAll(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"name", "quote"},
+		{"Ada", `said "hi, there"`},
+		{"multi\nline", ""},
+		{"last", ""},
+	}, records)
+
+	records, err = r.ReadAll(strings.NewReader("a,b\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}}, records)
+
+	_, err = r.ReadAll(strings.NewReader("a,\"b\nc,d"))
+	assert.EqualError(t, err, "Lex Error 1:3) \"")
+
+	_, err = r.ReadAll(strings.NewReader("a,\"b\"c\n"))
+	assert.EqualError(t, err, "Could Not Parse 1:6) found field: c, expected eol, sep")
+}
+
+func TestDialects(t *testing.T) {
+	r, err := New(TSV)
+	if assert.NoError(t, err) {
+		records, err := r.ReadAll(strings.NewReader("a\t\"b\"\tc,d\n\t\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"a", `"b"`, "c,d"}, {"", ""}}, records)
+	}
+
+	r, err = New(Dialect{Delimiter: ';', Quote: '\'', Escape: '\\'})
+	if assert.NoError(t, err) {
+		records, err := r.ReadAll(strings.NewReader(`'it\'s';'a\\b';c`))
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"it's", `a\b`, "c"}}, records)
+	}
+
+	_, err = New(Dialect{Delimiter: '"', Quote: '"'})
+	assert.EqualError(t, err, "Bad Dialect: '\"' is used twice")
+	_, err = New(Dialect{Delimiter: '\n'})
+	assert.EqualError(t, err, "Bad Dialect: '\\n' is used twice")
+	_, err = New(Dialect{Quote: '"'})
+	assert.EqualError(t, err, "Bad Dialect: no Delimiter")
+	_, err = New(Dialect{Delimiter: ',', Escape: '\\'})
+	assert.EqualError(t, err, "Bad Dialect: Escape without Quote")
+}
+
+// rows is an io.Reader of n generated records, so a large input never needs
+// to be held in memory
+type rows struct {
+	n, i int
+	buf  []byte
+}
+
+func (r *rows) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) && r.i < r.n {
+		r.buf = append(r.buf, fmt.Sprintf("%d,\"row, %d\",%d.5\n", r.i, r.i, r.i*2)...)
+		r.i++
+	}
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestStream(t *testing.T) {
+	r, err := New(CSV)
+	if !assert.NoError(t, err) {
+		return
+	}
+	count := 0
+	err = r.Read(&rows{n: 20000}, func(record []string) error {
+		if assert.Len(t, record, 3) && count == 12345 {
+			assert.Equal(t, []string{"12345", "row, 12345", "24690.5"}, record)
+		}
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 20000, count)
+
+	stop := errors.New("stop")
+	count = 0
+	err = r.Read(&rows{n: 100}, func(record []string) error {
+		count++
+		if count == 10 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 10, count)
+}
+
+func BenchmarkRead(b *testing.B) {
+	r, _ := New(CSV)
+	for i := 0; i < b.N; i++ {
+		r.Read(&rows{n: 1000}, func([]string) error { return nil })
+	}
+}
@@ -0,0 +1,219 @@
+// Package json is a complete JSON parser built on parlex, following RFC 8259.
+// It is meant as a template for a language built with parlex: a simplelexer
+// for the tokens, a grammar for the structure, the LALR parser and a reducer
+// that turns the parse tree into a clean tree.
+//
+// The reduced tree has an Object node with a Member child for each member, the
+// value of a Member is its key and its child is its value. An Array node has a
+// child for each element. The leaves are string, number, true, false and null
+// with the source text as their value.
+//   {"a": [1, true]}
+// reduces to
+//   (Object (Member "\"a\"" (Array (number "1") (true "true"))))
+//
+// Interface converts a reduced tree to the same values encoding/json decodes
+// into an interface{}.
+package json
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/lalr"
+	"github.com/adamcolton/parlex/tree"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	lexerRules = `
+    true   /true/
+    false  /false/
+    null   /null/
+    string /"(?:[^"\\\x00-\x1f]|\\["\\\/bfnrt]|\\u[0-9a-fA-F]{4})*"/
+    number /-?(?:0|[1-9]\d*)(?:\.\d+)?(?:[eE][+-]?\d+)?/
+    {      /\{/
+    }      /\}/
+    [      /\[/
+    ]      /\]/
+    ,      /,/
+    :      /:/
+    space  /[ \t\r\n]+/ -
+  `
+	grammarRules = `
+    Value    -> Object
+             -> Array
+             -> string
+             -> number
+             -> true
+             -> false
+             -> null
+    Object   -> { }
+             -> { Members }
+    Members  -> Members , Member
+             -> Member
+    Member   -> string : Value
+    Array    -> [ ]
+             -> [ Elements ]
+    Elements -> Elements , Value
+             -> Value
+  `
+)
+
+var (
+	// Lexer produces the JSON tokens.
+	Lexer = parlex.MustLexer(simplelexer.New(lexerRules))
+	// Grammar is the JSON grammar.
+	Grammar = parlex.MustGrammar(grammar.New(grammarRules))
+	// Parser is the LALR parser for the Grammar.
+	Parser = parlex.MustParser(lalr.New(Grammar))
+	// Reducer reduces a parse tree to the tree described by the package.
+	Reducer = tree.Reducer{
+		"Value":    tree.PromoteSingleChild,
+		"Object":   tree.RemoveChildren(0, -1).PromoteChildrenOf(0),
+		"Members":  tree.If(tree.ChildIs(0, "Members"), tree.RemoveChild(1).PromoteChildrenOf(0), nil),
+		"Member":   tree.RemoveChild(1).PromoteChildValue(0),
+		"Array":    tree.RemoveChildren(0, -1).PromoteChildrenOf(0),
+		"Elements": tree.If(tree.ChildIs(0, "Elements"), tree.RemoveChild(1).PromoteChildrenOf(0), nil),
+	}
+
+	runner = parlex.New(Lexer, Parser, Reducer)
+)
+
+// Parse a JSON document into a reduced tree.
+func Parse(src string) (parlex.ParseNode, error) {
+	return runner.Run(src)
+}
+
+// Unmarshal parses a JSON document and converts it with Interface.
+func Unmarshal(src string) (interface{}, error) {
+	pn, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return Interface(pn)
+}
+
+// Interface converts a reduced tree to the values encoding/json uses for an
+// interface{}; map[string]interface{}, []interface{}, string, float64, bool
+// and nil. When an object has the same key more than once, the last value is
+// kept.
+func Interface(node parlex.ParseNode) (interface{}, error) {
+	switch kind := node.Kind().String(); kind {
+	case "Object":
+		m := make(map[string]interface{}, node.Children())
+		for i := 0; i < node.Children(); i++ {
+			member := node.Child(i)
+			key, err := Unquote(member.Value())
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = Interface(member.Child(0)); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case "Array":
+		s := make([]interface{}, node.Children())
+		for i := range s {
+			var err error
+			if s[i], err = Interface(node.Child(i)); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case "string":
+		return Unquote(node.Value())
+	case "number":
+		f, err := strconv.ParseFloat(node.Value(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Number Out Of Range: %s", node.Value())
+		}
+		return f, nil
+	case "true", "false":
+		return kind == "true", nil
+	case "null":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unknown Kind: %s", kind)
+	}
+}
+
+// Unquote returns the value of a JSON string literal with its quotes. A \u
+// escape of half of a surrogate pair that is not part of a pair becomes
+// U+FFFD, as it does in encoding/json.
+func Unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("Bad String: %s", s)
+	}
+	s = s[1 : len(s)-1]
+	if !strings.ContainsRune(s, '\\') {
+		return s, nil
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("Bad String: %q", s)
+		}
+		switch s[i] {
+		case '"', '\\', '/':
+			buf.WriteByte(s[i])
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'u':
+			r, ok := hex4(s[i+1:])
+			if !ok {
+				return "", fmt.Errorf("Bad String: %q", s)
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				if r = pair(r, s[i+1:]); r != utf8.RuneError {
+					i += 6
+				}
+			}
+			buf.WriteRune(r)
+		default:
+			return "", fmt.Errorf("Bad String: %q", s)
+		}
+	}
+	return buf.String(), nil
+}
+
+// hex4 reads the 4 hex digits at the start of s.
+func hex4(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	r, err := strconv.ParseUint(s[:4], 16, 32)
+	return rune(r), err == nil
+}
+
+// pair decodes the surrogate r with the \u escape at the start of s. If s does
+// not start with the other half of the pair, it returns U+FFFD.
+func pair(r rune, s string) rune {
+	if !strings.HasPrefix(s, `\u`) {
+		return utf8.RuneError
+	}
+	r2, ok := hex4(s[2:])
+	if !ok {
+		return utf8.RuneError
+	}
+	return utf16.DecodeRune(r, r2)
+}
@@ -0,0 +1,114 @@
+package json
+
+import (
+	stdjson "encoding/json"
+	"github.com/adamcolton/parlex/parlextest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	pn, err := Parse(`{"a": [1, true, {}], "b": null}`)
+	if assert.NoError(t, err) {
+		parlextest.AssertTree(t, pn, `
+      (Object
+        (Member "\"a\"" (Array (number "1") (true "true") Object))
+        (Member "\"b\"" (null "null")))
+    `)
+	}
+
+	pn, err = Parse(`[]`)
+	if assert.NoError(t, err) {
+		parlextest.AssertTree(t, pn, `Array`)
+	}
+}
+
+// valid documents are decoded the same as encoding/json
+var valid = []string{
+	`{}`,
+	`[]`,
+	`0`,
+	`-0.5e+10`,
+	`1E-2`,
+	`"x"`,
+	`true`,
+	`null`,
+	` [ 1 , 2 ] `,
+	"\t{\r\n\"a\" : {\"b\": [[], [{}]]}}\n",
+	`{"a": 1, "a": 2}`,
+	`"\"\\\/\b\f\n\r\t"`,
+	`"é€"`,
+	`"😀"`,
+	`"\ud83d"`,
+	`"\ud83dx"`,
+	`"\ude00\ud83d"`,
+	`"é 😀"`,
+	`{"test": "test", "pi": [3, 1, 4, 1, 5], "sub": {"one": 1, "two": 2.5}, "nested": [["A"], [true, false, null]]}`,
+}
+
+// invalid documents are rejected by both
+var invalid = []string{
+	``,
+	` `,
+	`{`,
+	`[1,]`,
+	`[,1]`,
+	`{"a":1,}`,
+	`{"a"}`,
+	`{a: 1}`,
+	`{1: 1}`,
+	`01`,
+	`+1`,
+	`.5`,
+	`1.`,
+	`1e`,
+	`0x10`,
+	`'x'`,
+	`"\x"`,
+	`"\u12"`,
+	"\"\t\"",
+	`"x`,
+	`True`,
+	`nul`,
+	`[] []`,
+	`1 2`,
+	`NaN`,
+	`1e400`,
+	"\f[]",
+}
+
+func TestConformance(t *testing.T) {
+	for _, src := range valid {
+		var expected interface{}
+		if !assert.NoError(t, stdjson.Unmarshal([]byte(src), &expected), src) {
+			continue
+		}
+		v, err := Unmarshal(src)
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, expected, v, src)
+		}
+	}
+
+	for _, src := range invalid {
+		var v interface{}
+		assert.Error(t, stdjson.Unmarshal([]byte(src), &v), src)
+		_, err := Unmarshal(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Unmarshal(`{"a": [1, 2}`)
+	assert.EqualError(t, err, "Could Not Parse 1:12) found }: }, expected ,, ]")
+	_, err = Unmarshal(`[1e400]`)
+	assert.EqualError(t, err, "Number Out Of Range: 1e400")
+	_, err = Unquote(`x`)
+	assert.EqualError(t, err, "Bad String: x")
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	src := `{"id": 1, "name": "item \"1\"", "tags": ["a", "b"], "score": 1.5e-1, "ok": true, "next": null}`
+	for i := 0; i < b.N; i++ {
+		Unmarshal(src)
+	}
+}
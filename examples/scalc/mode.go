@@ -0,0 +1,344 @@
+package scalc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Value is a number on the stack. The precision is the number of decimal
+// places it is printed with.
+type Value interface {
+	String() string
+	Prec() int
+	Float64() float64
+}
+
+// Mode is the arithmetic used to evaluate a parse tree. Each Mode has its own
+// type of Value and is only given Values of that type. Float uses float64,
+// BigFloat uses math/big.Float and Rat uses math/big.Rat.
+type Mode interface {
+	// Number returns the value of a literal like -1.25 with precision p.
+	Number(lit string, p int) Value
+	// Int returns n with precision p.
+	Int(n int64, p int) Value
+	// Neg returns -a.
+	Neg(a Value) Value
+	// Cmp returns -1 if a < b, 0 if a == b and 1 if a > b.
+	Cmp(a, b Value) int
+	// Arith applies one of the operators +, -, *, /, ^ or % and returns a value
+	// with precision p.
+	Arith(op string, a, b Value, p int) (Value, error)
+}
+
+// Errors returned by BigFloat and Rat. Float never returns an error, it gives
+// an infinity or NaN the same as float64.
+var (
+	ErrDivideByZero = errors.New("Divide By Zero")
+	ErrOutOfRange   = errors.New("Out Of Range")
+)
+
+// ModeByName returns the Mode with a name as it is given on the command line;
+// float, bigfloat or rat. The bigfloat Mode uses the default precision.
+func ModeByName(name string) (Mode, error) {
+	switch name {
+	case "float":
+		return Float, nil
+	case "bigfloat":
+		return BigFloat(0), nil
+	case "rat":
+		return Rat, nil
+	}
+	return nil, fmt.Errorf("Unknown Mode: %s", name)
+}
+
+// Pfloat or precision float represents a value and a precision. It is the Value
+// of Float.
+type Pfloat struct {
+	V float64
+	P int
+}
+
+// String fulfills stringer and prints the Pfloat to the correct precision.
+func (p Pfloat) String() string {
+	f := fmt.Sprintf("%%.%df", p.P)
+	return fmt.Sprintf(f, p.V)
+}
+
+// Prec fulfills Value.
+func (p Pfloat) Prec() int { return p.P }
+
+// Float64 fulfills Value.
+func (p Pfloat) Float64() float64 { return p.V }
+
+// Float is the Mode of float64 arithmetic.
+var Float Mode = floatMode{}
+
+type floatMode struct{}
+
+func (floatMode) Number(lit string, p int) Value {
+	f, _ := strconv.ParseFloat(lit, 64)
+	return Pfloat{f, p}
+}
+
+func (floatMode) Int(n int64, p int) Value {
+	return Pfloat{float64(n), p}
+}
+
+func (floatMode) Neg(a Value) Value {
+	pf := a.(Pfloat)
+	pf.V = -pf.V
+	return pf
+}
+
+func (floatMode) Cmp(a, b Value) int {
+	av, bv := a.(Pfloat).V, b.(Pfloat).V
+	if av < bv {
+		return -1
+	}
+	if av > bv {
+		return 1
+	}
+	return 0
+}
+
+func (floatMode) Arith(op string, a, b Value, p int) (Value, error) {
+	av, bv := a.(Pfloat).V, b.(Pfloat).V
+	var v float64
+	switch op {
+	case "+":
+		v = av + bv
+	case "-":
+		v = av - bv
+	case "*":
+		v = av * bv
+	case "/":
+		v = av / bv
+	case "^":
+		v = math.Pow(av, bv)
+	case "%":
+		v = math.Mod(av, bv)
+	default:
+		return nil, fmt.Errorf("Unknown Operator: %s", op)
+	}
+	return Pfloat{v, p}, nil
+}
+
+// Pbig is the Value of BigFloat.
+type Pbig struct {
+	V *big.Float
+	P int
+}
+
+// String fulfills stringer and prints the Pbig to the correct precision.
+func (p Pbig) String() string { return p.V.Text('f', p.P) }
+
+// Prec fulfills Value.
+func (p Pbig) Prec() int { return p.P }
+
+// Float64 fulfills Value.
+func (p Pbig) Float64() float64 {
+	f, _ := p.V.Float64()
+	return f
+}
+
+// BigFloat returns the Mode of math/big.Float arithmetic where the mantissa of
+// every value has prec bits, or 256 if prec is 0. A power with an exponent that
+// is not an integer is computed with float64.
+func BigFloat(prec uint) Mode {
+	if prec == 0 {
+		prec = 256
+	}
+	return bigFloatMode(prec)
+}
+
+type bigFloatMode uint
+
+func (m bigFloatMode) new() *big.Float {
+	return new(big.Float).SetPrec(uint(m))
+}
+
+func (m bigFloatMode) Number(lit string, p int) Value {
+	f, _ := m.new().SetString(lit)
+	return Pbig{f, p}
+}
+
+func (m bigFloatMode) Int(n int64, p int) Value {
+	return Pbig{m.new().SetInt64(n), p}
+}
+
+func (m bigFloatMode) Neg(a Value) Value {
+	pb := a.(Pbig)
+	return Pbig{m.new().Neg(pb.V), pb.P}
+}
+
+func (bigFloatMode) Cmp(a, b Value) int {
+	return a.(Pbig).V.Cmp(b.(Pbig).V)
+}
+
+func (m bigFloatMode) Arith(op string, a, b Value, p int) (Value, error) {
+	av, bv := a.(Pbig).V, b.(Pbig).V
+	v := m.new()
+	switch op {
+	case "+":
+		v.Add(av, bv)
+	case "-":
+		v.Sub(av, bv)
+	case "*":
+		v.Mul(av, bv)
+	case "/":
+		if bv.Sign() == 0 {
+			return nil, ErrDivideByZero
+		}
+		v.Quo(av, bv)
+	case "^":
+		if !bv.IsInt() {
+			af, _ := av.Float64()
+			bf, _ := bv.Float64()
+			f := math.Pow(af, bf)
+			if math.IsInf(f, 0) || math.IsNaN(f) {
+				return nil, ErrOutOfRange
+			}
+			v.SetFloat64(f)
+			break
+		}
+		e, acc := bv.Int64()
+		if acc != big.Exact {
+			return nil, ErrOutOfRange
+		}
+		if e < 0 && av.Sign() == 0 {
+			return nil, ErrDivideByZero
+		}
+		v.SetInt64(1)
+		x := m.new().Set(av)
+		for n := abs(e); n > 0; n >>= 1 {
+			if n&1 == 1 {
+				v.Mul(v, x)
+			}
+			x.Mul(x, x)
+		}
+		if e < 0 {
+			v.Quo(m.new().SetInt64(1), v)
+		}
+	case "%":
+		if bv.Sign() == 0 {
+			return nil, ErrDivideByZero
+		}
+		q, _ := m.new().Quo(av, bv).Int(nil)
+		v.Sub(av, v.Mul(bv, m.new().SetInt(q)))
+	default:
+		return nil, fmt.Errorf("Unknown Operator: %s", op)
+	}
+	if v.IsInf() {
+		return nil, ErrOutOfRange
+	}
+	return Pbig{v, p}, nil
+}
+
+// Prat is the Value of Rat.
+type Prat struct {
+	V *big.Rat
+	P int
+}
+
+// String fulfills stringer and prints the Prat to the correct precision. The
+// last digit is rounded half away from zero.
+func (p Prat) String() string { return p.V.FloatString(p.P) }
+
+// Prec fulfills Value.
+func (p Prat) Prec() int { return p.P }
+
+// Float64 fulfills Value.
+func (p Prat) Float64() float64 {
+	f, _ := p.V.Float64()
+	return f
+}
+
+// Rat is the Mode of exact math/big.Rat arithmetic. A power with an exponent
+// that is not an integer is computed with float64 and an integer exponent can
+// be at most MaxRatExp.
+var Rat Mode = ratMode{}
+
+// MaxRatExp is the largest exponent Rat will raise a number to, so a power
+// cannot use up all the memory.
+const MaxRatExp = 1 << 16
+
+type ratMode struct{}
+
+func (ratMode) Number(lit string, p int) Value {
+	r, _ := new(big.Rat).SetString(lit)
+	return Prat{r, p}
+}
+
+func (ratMode) Int(n int64, p int) Value {
+	return Prat{new(big.Rat).SetInt64(n), p}
+}
+
+func (ratMode) Neg(a Value) Value {
+	pr := a.(Prat)
+	return Prat{new(big.Rat).Neg(pr.V), pr.P}
+}
+
+func (ratMode) Cmp(a, b Value) int {
+	return a.(Prat).V.Cmp(b.(Prat).V)
+}
+
+func (ratMode) Arith(op string, a, b Value, p int) (Value, error) {
+	av, bv := a.(Prat).V, b.(Prat).V
+	v := new(big.Rat)
+	switch op {
+	case "+":
+		v.Add(av, bv)
+	case "-":
+		v.Sub(av, bv)
+	case "*":
+		v.Mul(av, bv)
+	case "/":
+		if bv.Sign() == 0 {
+			return nil, ErrDivideByZero
+		}
+		v.Quo(av, bv)
+	case "^":
+		if !bv.IsInt() {
+			af, _ := av.Float64()
+			bf, _ := bv.Float64()
+			if v.SetFloat64(math.Pow(af, bf)) == nil {
+				return nil, ErrOutOfRange
+			}
+			break
+		}
+		e := bv.Num()
+		if !e.IsInt64() || abs(e.Int64()) > MaxRatExp {
+			return nil, ErrOutOfRange
+		}
+		if e.Sign() < 0 && av.Sign() == 0 {
+			return nil, ErrDivideByZero
+		}
+		n := big.NewInt(abs(e.Int64()))
+		num := new(big.Int).Exp(av.Num(), n, nil)
+		den := new(big.Int).Exp(av.Denom(), n, nil)
+		if e.Sign() < 0 {
+			num, den = den, num
+		}
+		v.SetFrac(num, den)
+	case "%":
+		if bv.Sign() == 0 {
+			return nil, ErrDivideByZero
+		}
+		q := new(big.Rat).Quo(av, bv)
+		q.SetInt(new(big.Int).Quo(q.Num(), q.Denom()))
+		v.Sub(av, q.Mul(q, bv))
+	default:
+		return nil, fmt.Errorf("Unknown Operator: %s", op)
+	}
+	return Prat{v, p}, nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package scalc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		expr                 string
+		float, bigfloat, rat string
+	}{
+		{"0.1 0.2 + 0.3 =", "0.0", "1.0", "1.0"},
+		{"3 40 ^", "12157665459056928768", "12157665459056928801", "12157665459056928801"},
+		{"1 3 / 3 * 1 =", "1", "1", "1"},
+		{"2.00 -2 ^", "0.25", "0.25", "0.25"},
+		{"2.0 0.5 ^", "1.4", "1.4", "1.4"},
+		{"7.5 2 %", "1.5", "1.5", "1.5"},
+		{"-7 3 %", "-1", "-1", "-1"},
+		{"1.000 3 /", "0.333", "0.333", "0.333"},
+		{"(0.1 0.2 0.3) avg", "0.2", "0.2", "0.2"},
+		{"1 0 /", "+Inf", "", ""},
+	}
+	for _, tt := range tests {
+		for mode, expect := range map[Mode]string{Float: tt.float, BigFloat(0): tt.bigfloat, Rat: tt.rat} {
+			stack, err := EvalMode(tt.expr, mode)
+			if expect == "" {
+				assert.Equal(t, ErrDivideByZero, err, tt.expr)
+				continue
+			}
+			if assert.NoError(t, err, tt.expr) && assert.Len(t, stack, 1, tt.expr) {
+				assert.Equal(t, expect, stack[0].String(), tt.expr)
+			}
+		}
+	}
+}
+
+func TestModeErrors(t *testing.T) {
+	for _, mode := range []Mode{BigFloat(64), Rat} {
+		_, err := EvalMode("1 0 %", mode)
+		assert.Equal(t, ErrDivideByZero, err)
+		_, err = EvalMode("0 -1 ^", mode)
+		assert.Equal(t, ErrDivideByZero, err)
+		_, err = EvalMode("-1 0.5 ^", mode)
+		assert.Equal(t, ErrOutOfRange, err)
+	}
+	_, err := EvalMode("2 100000 ^", Rat)
+	assert.Equal(t, ErrOutOfRange, err)
+}
+
+func TestEvalEnvMode(t *testing.T) {
+	env := NewEnv()
+	env.Mode = Rat
+	_, err := EvalEnv("1 3 / x sto", env)
+	assert.NoError(t, err)
+	assert.IsType(t, Prat{}, env.Vars["x"])
+
+	stack, err := EvalEnv("x rcl 3 *", env)
+	assert.NoError(t, err)
+	assert.Equal(t, []Pfloat{{1, 0}}, stack)
+}
+
+func TestModeByName(t *testing.T) {
+	for name, mode := range map[string]Mode{"float": Float, "rat": Rat} {
+		m, err := ModeByName(name)
+		assert.NoError(t, err)
+		assert.Equal(t, mode, m)
+	}
+	m, err := ModeByName("bigfloat")
+	if assert.NoError(t, err) {
+		stack, err := EvalMode("1 3 /", m)
+		assert.NoError(t, err)
+		assert.Equal(t, "0", Format(stack))
+	}
+	_, err = ModeByName("decimal")
+	assert.EqualError(t, err, "Unknown Mode: decimal")
+}
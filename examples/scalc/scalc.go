@@ -4,21 +4,32 @@ import (
 	"fmt"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexeme"
 	"github.com/adamcolton/parlex/lexer/simplelexer"
 	"github.com/adamcolton/parlex/parser/packrat"
 	"github.com/adamcolton/parlex/tree"
-	"math"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 const lexerRules = `
   space /\s+/ -
-  int   /(\+|-)?\d+/
-  dec   /\.\d+/
+  hex   /(\+|-)?0[xX][0-9A-Fa-f](_?[0-9A-Fa-f])*/
+  bin   /(\+|-)?0[bB][01](_?[01])*/
+  sci   /(\+|-)?\d(_?\d)*(\.\d(_?\d)*)?[eE][+-]?\d+/
+  int   /(\+|-)?\d(_?\d)*/
+  dec   /\.\d(_?\d)*/
   uop   /(--)|(abs)/
   bop   /(cmpr)|[\*\/+\-\^%><=]/
   sop   /(len)|(sum)|(avg)|(min)|(max)|(first)|(last)/
   smp   /(swap)|(drop)|(clear)/
+  sto   /sto/
+  rcl   /rcl/
+  dup   /dup/
+  def   /def/
+  quote /'[A-Za-z_]\w*/
+  name  /[A-Za-z_]\w*/ priority(-1)
   ?     /\?/
   (     /\(/
   )     /\)/
@@ -27,16 +38,25 @@ const lexerRules = `
 const grammarRules = `
   Stack  -> Stack Smp
          -> E Stack
+         -> Def Stack
          -> Stack P Stack
          ->
   E      -> Stack Sop
          -> E E E ?
          -> E Uop
          -> E E Bop
+         -> E name sto
+         -> name rcl
+         -> E name
+         -> dup
          -> Number
   Number -> int
          -> int dec
+         -> hex
+         -> bin
+         -> sci
   P      -> ( Stack )
+  Def    -> P quote def
   Bop    -> bop
          -> Bop Bop E ?
   Uop    -> uop
@@ -48,13 +68,15 @@ const grammarRules = `
 `
 
 var rdcr = tree.Reducer{
-	"Stack": stack,
-	"E":     tree.PromoteChild(-1),
-	"P":     tree.ReplaceWithChild(1),
-	"Bop":   tree.PromoteChild(-1),
-	"Uop":   tree.PromoteChild(-1),
-	"Sop":   tree.PromoteChild(-1),
-	"Smp":   tree.PromoteChild(-1),
+	"Stack":  stack,
+	"Number": number,
+	"E":      tree.PromoteChild(-1),
+	"P":      tree.ReplaceWithChild(1),
+	"Bop":    tree.PromoteChild(-1),
+	"Uop":    tree.PromoteChild(-1),
+	"Sop":    tree.PromoteChild(-1),
+	"Smp":    tree.PromoteChild(-1),
+	"Def":    tree.PromoteChild(-1),
 }
 
 func stack(node *tree.PN) {
@@ -71,6 +93,37 @@ func stack(node *tree.PN) {
 	}
 }
 
+// number replaces the children of a Number with its value as a decimal, so
+// 0xFF is 255, 1_000 is 1000 and 1.5e-3 is 0.0015. The precision is the number
+// of digits after the decimal point; a number in scientific notation has as
+// many as it needs to be exact.
+func number(node *tree.PN) {
+	lit := strings.Replace(node.C[0].Value(), "_", "", -1)
+	switch node.C[0].Kind().String() {
+	case "int":
+		if len(node.C) == 2 {
+			lit += strings.Replace(node.C[1].Value(), "_", "", -1)
+		}
+	case "hex", "bin":
+		i, _ := new(big.Int).SetString(lit, 0)
+		lit = i.String()
+	case "sci":
+		e := strings.IndexAny(lit, "eE")
+		exp, _ := strconv.Atoi(lit[e+1:])
+		p := -exp
+		if d := strings.IndexByte(lit, '.'); d >= 0 {
+			p += e - d - 1
+		}
+		if p < 0 {
+			p = 0
+		}
+		r, _ := new(big.Rat).SetString(lit)
+		lit = r.FloatString(p)
+	}
+	node.Lexeme = lexeme.New(node.Kind()).Set(lit).At(node.C[0].Pos())
+	node.C = nil
+}
+
 var lxr = parlex.MustLexer(simplelexer.New(lexerRules))
 var grmr = parlex.MustGrammar(grammar.New(grammarRules))
 var prsr = packrat.New(grmr)
@@ -82,174 +135,320 @@ func Parse(str string) parlex.ParseNode {
 
 // Eval will evaluate a string and return a stack of Pfloats.
 func Eval(str string) []Pfloat {
-	t := Parse(str)
-	if t == nil {
-		return nil
+	stack, _ := EvalErr(str)
+	return stack
+}
+
+// EvalErr is the same as Eval but returns the error if the string cannot be
+// parsed. parlex.IsIncomplete can be used to check if more input could finish
+// it, for instance a sub stack that has not been closed.
+func EvalErr(str string) ([]Pfloat, error) {
+	return EvalEnv(str, NewEnv())
+}
+
+// EvalEnv is the same as EvalErr but evaluates in env, so the variables it
+// holds can be recalled and the variables stored are kept in it. Recalling a
+// variable that was never stored is an error. The values are converted to
+// Pfloats if env does not use Float.
+func EvalEnv(str string, env *Env) ([]Pfloat, error) {
+	vs, err := env.Eval(str)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Pfloat, len(vs))
+	for i, v := range vs {
+		if pf, ok := v.(Pfloat); ok {
+			out[i] = pf
+		} else {
+			out[i] = Pfloat{v.Float64(), v.Prec()}
+		}
+	}
+	return out, nil
+}
+
+// EvalMode is the same as EvalErr but evaluates with the arithmetic of mode.
+// The same parse tree is evaluated by every Mode.
+func EvalMode(str string, mode Mode) ([]Value, error) {
+	env := NewEnv()
+	env.Mode = mode
+	return env.Eval(str)
+}
+
+// Format joins the values of a stack with spaces, the way the calculator
+// prints it.
+func Format(stack []Value) string {
+	strs := make([]string, len(stack))
+	for i, v := range stack {
+		strs[i] = v.String()
+	}
+	return strings.Join(strs, " ")
+}
+
+// Env holds the variables and functions of an evaluation. "3 x sto" stores 3
+// in x and leaves it on the stack, "x rcl" puts the value of x on the stack.
+//
+// "(dup dup *) 'square def" defines the function square and "3 square" applies
+// it. A function is kept as the tree of its body. When it is applied, the body
+// is copied with the tree of the argument in place of each dup and the copy is
+// evaluated, like a macro. The body must leave one value on the stack.
+//
+// Mode is the arithmetic used by the Env, Float unless it is set. The values
+// of the variables belong to the Mode, so it should not be changed once a
+// variable is stored.
+type Env struct {
+	Mode  Mode
+	Vars  map[string]Value
+	Funcs map[string]*tree.PN
+	depth int
+	err   error
+}
+
+// NewEnv returns an Env that uses Float with no variables or functions.
+func NewEnv() *Env {
+	return &Env{
+		Mode:  Float,
+		Vars:  make(map[string]Value),
+		Funcs: make(map[string]*tree.PN),
+	}
+}
+
+// Eval evaluates a string in the Env and returns the stack.
+func (env *Env) Eval(str string) ([]Value, error) {
+	pn, err := prsr.ParseErr(lxr.Lex(str))
+	if err != nil {
+		return nil, err
 	}
-	return evalStack(t.(*tree.PN))
+	env.err = nil
+	stack := evalStack(rdcr.Reduce(pn).(*tree.PN), env)
+	if env.err != nil {
+		return nil, env.err
+	}
+	return stack, nil
+}
+
+// maxDepth limits how deep functions can be applied inside each other, so a
+// function that applies itself without end is an error.
+const maxDepth = 1000
+
+func (env *Env) fail(format string, args ...interface{}) {
+	if env.err == nil {
+		env.err = fmt.Errorf(format, args...)
+	}
+}
+
+// zero is returned when evaluation fails.
+func (env *Env) zero() Value {
+	return env.Mode.Int(0, 0)
 }
 
-// Pfloat or precision float represents a value and a precision.
-type Pfloat struct {
-	V float64
-	P int
+// def stores the body of a def node under the quoted name.
+func (env *Env) def(node *tree.PN) {
+	env.Funcs[node.C[1].Value()[1:]] = node.C[0]
 }
 
-// String fulfills stringer and prints the Pfloat to the correct precision.
-func (p Pfloat) String() string {
-	f := fmt.Sprintf("%%.%df", p.P)
-	return fmt.Sprintf(f, p.V)
+// call applies the function a node names to the node's child.
+func (env *Env) call(node *tree.PN) Value {
+	name := node.Value()
+	body, ok := env.Funcs[name]
+	if !ok {
+		env.fail("Undefined Function: %s", name)
+		return env.zero()
+	}
+	if env.depth == maxDepth {
+		env.fail("Too Deep: %s", name)
+		return env.zero()
+	}
+	arg := node.C[0]
+	body = tree.Substitute(body, func(n parlex.ParseNode) parlex.ParseNode {
+		if n.Kind().String() == "dup" {
+			return arg
+		}
+		return nil
+	})
+	env.depth++
+	stack := evalStack(body, env)
+	env.depth--
+	if len(stack) != 1 {
+		env.fail("Bad Function: %s leaves %d values", name, len(stack))
+		return env.zero()
+	}
+	return stack[0]
 }
 
-func evalStack(node *tree.PN) []Pfloat {
+func (env *Env) rcl(name string) Value {
+	v, ok := env.Vars[name]
+	if !ok {
+		env.fail("Undefined Variable: %s", name)
+		return env.zero()
+	}
+	return v
+}
+
+func evalStack(node *tree.PN, env *Env) []Value {
 	kind := node.Kind().String()
 
 	switch kind {
 	case "?":
-		v := evalE(node.Child(-1).(*tree.PN)).V
+		v := evalE(node.Child(-1).(*tree.PN), env)
 		node.RemoveChild(-1)
-		if v > 0 {
+		if env.Mode.Cmp(v, env.zero()) > 0 {
 			node.RemoveChild(-2)
 			node.PromoteChild(-1)
 		} else {
 			node.RemoveChild(-1)
 			node.PromoteChild(-1)
 		}
-		return evalStack(node)
-	case "smp":
-		evalSmp(node)
-		fallthrough
-	case "Stack":
-		out := make([]Pfloat, len(node.C))
-		for i, ch := range node.C {
-			out[i] = evalE(ch)
+		return evalStack(node, env)
+	case "def":
+		env.def(node)
+		return nil
+	case "smp", "Stack":
+		out := make([]Value, 0, len(node.C))
+		for _, ch := range node.C {
+			if ch.Kind().String() == "def" {
+				env.def(ch)
+				continue
+			}
+			out = append(out, evalE(ch, env))
+		}
+		if kind == "smp" {
+			out = evalSmp(out, node)
 		}
 		return out
 	default:
-		return []Pfloat{evalE(node)}
+		return []Value{evalE(node, env)}
 	}
 	return nil
 }
 
-func evalSmp(op *tree.PN) {
+// evalSmp applies a stack manipulation to the values of the stack. They are
+// evaluated first, so a value that is dropped is still stored if it is a sto.
+func evalSmp(stack []Value, op *tree.PN) []Value {
 	switch op.Value() {
 	case "swap":
-		ln := len(op.C)
+		ln := len(stack)
 		if ln > 1 {
-			op.C[ln-1], op.C[ln-2] = op.C[ln-2], op.C[ln-1]
+			stack[ln-1], stack[ln-2] = stack[ln-2], stack[ln-1]
 		}
 	case "drop":
-		if len(op.C) > 0 {
-			op.C = op.C[:len(op.C)-1]
+		if len(stack) > 0 {
+			stack = stack[:len(stack)-1]
 		}
 	case "clear":
-		op.C = nil
+		stack = nil
 	}
+	return stack
 }
 
-func evalE(node *tree.PN) Pfloat {
+func evalE(node *tree.PN, env *Env) Value {
 	switch node.Kind().String() {
 	case "Number":
-		if c := node.Children(); c == 2 {
-			c1 := node.C[1].Value()
-			f, _ := strconv.ParseFloat(node.C[0].Value()+c1, 64)
-			return Pfloat{f, len(c1) - 1}
-		} else if c == 1 {
-			f, _ := strconv.ParseFloat(node.C[0].Value(), 64)
-			return Pfloat{f, 0}
+		lit, p := node.Value(), 0
+		if d := strings.IndexByte(lit, '.'); d >= 0 {
+			p = len(lit) - d - 1
 		}
+		return env.Mode.Number(lit, p)
 	case "uop":
-		return evalUop(node.C[0], node)
+		return evalUop(node.C[0], node, env)
 	case "bop":
-		return evalBop(node.C[0], node.C[1], node)
+		return evalBop(node.C[0], node.C[1], node, env)
 	case "sop":
-		return evalSop(evalStack(node.C[0]), node)
+		return evalSop(evalStack(node.C[0], env), node, env)
+	case "sto":
+		v := evalE(node.C[0], env)
+		env.Vars[node.C[1].Value()] = v
+		return v
+	case "rcl":
+		return env.rcl(node.C[0].Value())
+	case "name":
+		return env.call(node)
+	case "dup":
+		env.fail("Bad Dup: dup is only allowed in a function")
 	}
-	return Pfloat{}
+	return env.zero()
 }
 
-func evalUop(a, op *tree.PN) Pfloat {
-	ae := evalE(a)
+func evalUop(a, op *tree.PN, env *Env) Value {
+	ae := evalE(a, env)
 	switch op.Value() {
 	case "--":
-		ae.V = -ae.V
+		return env.Mode.Neg(ae)
 	case "abs":
-		if ae.V < 0 {
-			ae.V = -ae.V
+		if env.Mode.Cmp(ae, env.zero()) < 0 {
+			return env.Mode.Neg(ae)
 		}
 	}
 	return ae
 }
 
-func evalBop(a, b, op *tree.PN) Pfloat {
-	ae := evalE(a)
-	be := evalE(b)
+func evalBop(a, b, op *tree.PN, env *Env) Value {
+	ae := evalE(a, env)
+	be := evalE(b, env)
 	p := maxPrecision(ae, be)
-	var v float64
-	switch op.Value() {
-	case "+":
-		v = ae.V + be.V
-	case "*":
-		v = ae.V * be.V
-	case "/":
-		v = ae.V / be.V
-	case "-":
-		v = ae.V - be.V
-	case "^":
-		v = math.Pow(ae.V, be.V)
-	case "%":
-		v = math.Mod(ae.V, be.V)
+	var v int64
+	switch op := op.Value(); op {
+	case "+", "*", "/", "-", "^", "%":
+		r, err := env.Mode.Arith(op, ae, be, p)
+		if err != nil {
+			env.fail("%s", err)
+			return env.zero()
+		}
+		return r
 	case ">":
-		if ae.V > be.V {
+		if env.Mode.Cmp(ae, be) > 0 {
 			v = 1
 		}
 	case "<":
-		if ae.V < be.V {
+		if env.Mode.Cmp(ae, be) < 0 {
 			v = 1
 		}
 	case "=":
-		if ae.V == be.V {
+		if env.Mode.Cmp(ae, be) == 0 {
 			v = 1
 		}
 	case "cmpr":
-		if ae.V > be.V {
-			v = 1
-		} else if ae.V < be.V {
-			v = -1
-		}
+		v = int64(env.Mode.Cmp(ae, be))
 	}
 
-	return Pfloat{v, p}
+	return env.Mode.Int(v, p)
 }
 
-func evalSop(stack []Pfloat, op *tree.PN) Pfloat {
-	var v Pfloat
+func evalSop(stack []Value, op *tree.PN, env *Env) Value {
+	v := env.zero()
 	switch op := op.Value(); op {
 	case "sum", "avg":
-		v.P = maxPrecision(stack...)
-		for _, p := range stack {
-			v.V += p.V
+		p := maxPrecision(stack...)
+		v = env.Mode.Int(0, p)
+		var err error
+		for _, s := range stack {
+			if v, err = env.Mode.Arith("+", v, s, p); err != nil {
+				break
+			}
+		}
+		if op == "avg" && len(stack) > 0 && err == nil {
+			v, err = env.Mode.Arith("/", v, env.Mode.Int(int64(len(stack)), 0), p)
 		}
-		if op == "avg" && len(stack) > 0 {
-			v.V /= float64(len(stack))
+		if err != nil {
+			env.fail("%s", err)
+			return env.zero()
 		}
 	case "len":
-		v.V = float64(len(stack))
+		v = env.Mode.Int(int64(len(stack)), 0)
 	case "min":
 		if len(stack) > 0 {
 			v = stack[0]
-			for _, p := range stack[1:] {
-				if p.V < v.V {
-					v = p
+			for _, s := range stack[1:] {
+				if env.Mode.Cmp(s, v) < 0 {
+					v = s
 				}
 			}
 		}
 	case "max":
 		if len(stack) > 0 {
 			v = stack[0]
-			for _, p := range stack[1:] {
-				if p.V > v.V {
-					v = p
+			for _, s := range stack[1:] {
+				if env.Mode.Cmp(s, v) > 0 {
+					v = s
 				}
 			}
 		}
@@ -266,11 +465,11 @@ func evalSop(stack []Pfloat, op *tree.PN) Pfloat {
 	return v
 }
 
-func maxPrecision(pfs ...Pfloat) int {
+func maxPrecision(vs ...Value) int {
 	m := 0
-	for _, p := range pfs {
-		if p.P > m {
-			m = p.P
+	for _, v := range vs {
+		if p := v.Prec(); p > m {
+			m = p
 		}
 	}
 	return m
@@ -1,10 +1,10 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"github.com/adamcolton/parlex/examples/scalc"
 	"github.com/urfave/cli"
+	"io"
 	"os"
 	"strings"
 )
@@ -13,15 +13,35 @@ func main() {
 	app := cli.NewApp()
 	app.Name = "scalc"
 	app.Usage = "Reverse Polish Notation"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "mode",
+			Value: "float",
+			Usage: "arithmetic to use; float, bigfloat or rat",
+		},
+	}
 	app.Action = func(c *cli.Context) error {
+		mode, err := scalc.ModeByName(c.String("mode"))
+		if err != nil {
+			return err
+		}
+		env := scalc.NewEnv()
+		env.Mode = mode
+		// the interactive calculator is cmd/scalc, without arguments the input
+		// is read from stdin
+		input := strings.Join(c.Args(), " ")
 		if len(c.Args()) == 0 {
-			return interactive()
+			b, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			input = string(b)
 		}
-		r := scalc.Eval(strings.Join(c.Args(), " "))
-		if r == nil {
-			return fmt.Errorf("Failed to parse")
+		r, err := env.Eval(input)
+		if err != nil {
+			return err
 		}
-		fmt.Println(format(r))
+		fmt.Println(scalc.Format(r))
 		return nil
 	}
 
@@ -45,31 +65,3 @@ func main() {
 		fmt.Fprint(os.Stderr, err, "\n")
 	}
 }
-
-func interactive() error {
-	var stack string
-	for {
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Print("> ")
-		input, _ := reader.ReadString('\n')
-		if input == "exit\n" {
-			return nil
-		}
-		r := scalc.Eval(stack + " " + input)
-		if r == nil {
-			fmt.Println("Bad input")
-			continue
-		}
-		stack = format(r)
-		fmt.Println(stack)
-	}
-	return nil
-}
-
-func format(stack []scalc.Pfloat) string {
-	strs := make([]string, len(stack))
-	for i, p := range stack {
-		strs[i] = p.String()
-	}
-	return strings.Join(strs, " ")
-}
@@ -1,6 +1,7 @@
 package scalc
 
 import (
+	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/tree"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -15,6 +16,37 @@ func TestLexer(t *testing.T) {
 	assert.Equal(t, "int", lxm[0].Kind().String())
 	assert.Equal(t, "int", lxm[1].Kind().String())
 	assert.Equal(t, "bop", lxm[2].Kind().String())
+
+	// the longest match makes each of these one lexeme instead of an int and a
+	// name, and a word that is an operator is not a name
+	for str, kind := range map[string]string{
+		"0xFF":   "hex",
+		"0b1010": "bin",
+		"1e5":    "sci",
+		"1_000":  "int",
+		"sum":    "sop",
+		"sums":   "name",
+	} {
+		lxm = lxr.Lex(str)
+		if assert.Len(t, lxm, 1, str) {
+			assert.Equal(t, kind, lxm[0].Kind().String(), str)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := map[string]string{
+		"0xFF":     `(Number "255")`,
+		"1_000.5":  `(Number "1000.5")`,
+		"1.5e-3 2": `(Stack (Number "0.0015") (Number "2"))`,
+		"0b12":     `(Stack (Number "1") (Number "2"))`,
+	}
+	for str, expect := range tests {
+		pn := Parse(str)
+		if assert.NotNil(t, pn, str) {
+			assert.Equal(t, expect, tree.ToSExpr(pn), str)
+		}
+	}
 }
 
 type testCase struct {
@@ -33,7 +65,13 @@ func makeCase(expr string, expect ...string) testCase {
 }
 
 func eval(node *tree.PN) []string {
-	stack := evalStack(node)
+	return evalMode(node, Float)
+}
+
+func evalMode(node *tree.PN, mode Mode) []string {
+	env := NewEnv()
+	env.Mode = mode
+	stack := evalStack(node, env)
 	out := make([]string, len(stack))
 	for i, s := range stack {
 		out[i] = s.String()
@@ -100,20 +138,46 @@ func TestCases(t *testing.T) {
 		makeCase("2 3 swap drop 1 ?", "2"),
 		makeCase("2 3 swap drop 0 ?", "3", "2"),
 		makeCase("-5--+6+*1+2++3=?", "30"),
+		makeCase("3 x sto", "3"),
+		makeCase("3 x sto x rcl +", "6"),
+		makeCase("1.2 x sto x rcl x rcl *", "1.2", "1.4"),
+		makeCase("(1 2 3 sum n sto) n rcl", "6", "6"),
+		makeCase("2 r sto r rcl r rcl * 3 *", "2", "12"),
+		makeCase("0xFF", "255"),
+		makeCase("-0x1_0 0b1010 +", "-6"),
+		makeCase("0B11 0Xff *", "765"),
+		makeCase("1_000_000 1 +", "1000001"),
+		makeCase("1_0.2_5", "10.25"),
+		makeCase("1.5e-3", "0.0015"),
+		makeCase("1.5e3 1 +", "1501"),
+		makeCase("-2E2", "-200"),
+		makeCase("1e0 1.0 +", "2.0"),
+		makeCase("(dup dup *) 'square def"),
+		makeCase("(dup dup *) 'square def 3 square", "9"),
+		makeCase("(dup dup *) 'square def 2 3 + square 1 -", "24"),
+		makeCase("(dup 2 *) 'double def (dup double double) 'quad def 3 quad", "12"),
+		makeCase("(1 dup dup 1 - fact * dup 1 > ?) 'fact def 5 fact", "120"),
 	}
 
+	modes := map[string]Mode{
+		"float":    Float,
+		"bigfloat": BigFloat(0),
+		"rat":      Rat,
+	}
 	var tt testCase
-	for _, tt = range tests {
-		pn := Parse(tt.expr)
-		if pn == nil {
-			t.Error("Could not parse", tt.expr)
-			continue
-		}
-		tpn := pn.(*tree.PN)
-		str := tpn.String()
-		if !assert.Equal(t, tt.expect, eval(tpn), tt.expr) {
-			t.Error(str)
-			t.Error(pn)
+	for name, mode := range modes {
+		for _, tt = range tests {
+			pn := Parse(tt.expr)
+			if pn == nil {
+				t.Error("Could not parse", tt.expr)
+				continue
+			}
+			tpn := pn.(*tree.PN)
+			str := tpn.String()
+			if !assert.Equal(t, tt.expect, evalMode(tpn, mode), name+": "+tt.expr) {
+				t.Error(str)
+				t.Error(pn)
+			}
 		}
 	}
 }
@@ -123,6 +187,64 @@ func TestParseFailsAsNil(t *testing.T) {
 	assert.True(t, pn == nil)
 }
 
+func TestEvalErr(t *testing.T) {
+	stack, err := EvalErr("1 (2 3 sum)")
+	assert.NoError(t, err)
+	assert.Len(t, stack, 2)
+
+	_, err = EvalErr("1 (2 3")
+	assert.True(t, parlex.IsIncomplete(err))
+
+	_, err = EvalErr("1 ) 2")
+	assert.Error(t, err)
+	assert.False(t, parlex.IsIncomplete(err))
+}
+
+func TestEvalEnv(t *testing.T) {
+	env := NewEnv()
+	stack, err := EvalEnv("3 x sto drop", env)
+	assert.NoError(t, err)
+	assert.Len(t, stack, 0)
+	assert.Equal(t, Pfloat{3, 0}, env.Vars["x"])
+
+	stack, err = EvalEnv("x rcl 2 *", env)
+	assert.NoError(t, err)
+	assert.Equal(t, []Pfloat{{6, 0}}, stack)
+
+	_, err = EvalEnv("y rcl", env)
+	assert.EqualError(t, err, "Undefined Variable: y")
+
+	_, err = EvalErr("x rcl")
+	assert.Error(t, err)
+}
+
+func TestFunctions(t *testing.T) {
+	env := NewEnv()
+	_, err := EvalEnv("(dup dup *) 'square def", env)
+	assert.NoError(t, err)
+	assert.Contains(t, env.Funcs, "square")
+
+	stack, err := EvalEnv("4 square", env)
+	assert.NoError(t, err)
+	assert.Equal(t, []Pfloat{{16, 0}}, stack)
+
+	// applying a function does not change its body
+	stack, err = EvalEnv("5 square", env)
+	assert.NoError(t, err)
+	assert.Equal(t, []Pfloat{{25, 0}}, stack)
+
+	tests := map[string]string{
+		"3 cube":                      "Undefined Function: cube",
+		"dup":                         "Bad Dup: dup is only allowed in a function",
+		"(dup dup) 'two def 1 two":    "Bad Function: two leaves 2 values",
+		"(dup loop) 'loop def 1 loop": "Too Deep: loop",
+	}
+	for expr, msg := range tests {
+		_, err := EvalErr(expr)
+		assert.EqualError(t, err, msg, expr)
+	}
+}
+
 func TestPad(t *testing.T) {
 	t.Skip()
 	pn := prsr.Parse(lxr.Lex("2 3 swap drop 1 ?"))
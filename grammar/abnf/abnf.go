@@ -0,0 +1,426 @@
+// Package abnf builds a grammar and a lexer from an ABNF rule list, the format
+// RFC 5234 defines and most protocol specifications use.
+//
+// ABNF describes the input one character at a time but parlex parses lexemes,
+// so the rules are split. A rule is a token if it is one of the core rules,
+// like ALPHA, DIGIT or CRLF, or if it is named as a token; a token becomes a
+// lexer rule with everything it references inlined. The other rules become
+// productions in a regexgram grammar, and any literal or number value they
+// use becomes a lexer rule named T__0, T__1... unless a token matches exactly
+// the same thing. The first rule is the start symbol and only the rules that
+// can be reached from it are included.
+//
+// The lexer chooses the longest match and, on a tie, the first rule: the
+// literals, then the tokens in the order they are defined. Unlike ABNF, the
+// lexer cannot know which token the parser expects, so tokens that overlap,
+// like ALPHA and HEXDIG, should be combined into a larger token.
+//
+// Literals are case insensitive unless they use the %s prefix from RFC 7405.
+// Number values are unicode code points. Rule names are case insensitive.
+// Prose values cannot be represented and return an error.
+package abnf
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/regexgram"
+	"github.com/adamcolton/parlex/internal/textscan"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Grammar is an ABNF rule list converted to a grammar and a lexer.
+type Grammar struct {
+	Grammar *grammar.Grammar
+	// Reducer removes the symbols regexgram adds for groups and repetition
+	Reducer tree.Reducer
+	Lexer   *simplelexer.Lexer
+	// GrammarRules and LexerRules are the definitions the Grammar and Lexer
+	// were built from.
+	GrammarRules, LexerRules string
+}
+
+// New converts an ABNF rule list. The tokens name rules, in addition to the
+// core rules, that should be matched by the lexer.
+func New(src string, tokens ...string) (*Grammar, error) {
+	rules, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	op := &convertOp{
+		rules:    make(map[string]*rule),
+		tokens:   make(map[*rule]bool),
+		regexes:  make(map[*rule]string),
+		nullable: make(map[*rule]bool),
+		literals: make(map[string]string),
+		stack:    make(map[*rule]bool),
+	}
+	for _, r := range rules {
+		key := strings.ToLower(r.name)
+		prev, ok := op.rules[key]
+		switch {
+		case r.incremental && !ok:
+			return nil, fmt.Errorf("Undefined Rule: %s has no definition before =/ at %d:%d", r.name, r.line, r.col)
+		case r.incremental:
+			prev.alt.subs = append(prev.alt.subs, r.alt.subs...)
+		case ok:
+			return nil, fmt.Errorf("Duplicate Rule: %s at %d:%d", r.name, r.line, r.col)
+		default:
+			op.rules[key] = r
+			op.order = append(op.order, r)
+		}
+	}
+	if len(op.order) == 0 {
+		return nil, fmt.Errorf("Bad Grammar: no rules")
+	}
+	start := op.order[0]
+
+	// the rule list can redefine a core rule
+	for _, r := range core() {
+		key := strings.ToLower(r.name)
+		if _, ok := op.rules[key]; !ok {
+			op.rules[key] = r
+			op.order = append(op.order, r)
+			op.tokens[r] = true
+		}
+	}
+	for _, t := range tokens {
+		r, ok := op.rules[strings.ToLower(t)]
+		if !ok {
+			return nil, fmt.Errorf("Undefined Rule: %s", t)
+		}
+		op.tokens[r] = true
+	}
+	if op.tokens[start] {
+		return nil, fmt.Errorf("Bad Grammar: the first rule %s is a token", start.name)
+	}
+
+	if err := op.reach(start); err != nil {
+		return nil, err
+	}
+	for _, r := range op.order {
+		if !op.tokens[r] {
+			continue
+		}
+		if op.regexes[r], err = op.regex(r.alt); err != nil {
+			return nil, err
+		}
+		op.nullable[r] = regexp.MustCompile("^(?:" + op.regexes[r] + ")$").MatchString("")
+	}
+
+	g := &Grammar{}
+	if g.GrammarRules, err = op.grammarRules(); err != nil {
+		return nil, err
+	}
+	g.LexerRules = op.lexerRules()
+	if g.Grammar, g.Reducer, err = regexgram.New(g.GrammarRules); err != nil {
+		return nil, err
+	}
+	if g.Lexer, err = simplelexer.New(g.LexerRules); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+type convertOp struct {
+	// rules are keyed by their lower case name
+	rules  map[string]*rule
+	order  []*rule
+	tokens map[*rule]bool
+	// reached holds the rules that become productions and used holds the
+	// tokens they reference, only the used tokens become lexer rules
+	reached, used map[*rule]bool
+	regexes       map[*rule]string
+	nullable      map[*rule]bool
+	// literals maps the regular expression of a literal to the token that
+	// matches it and implicit holds the regular expressions that need a token
+	literals map[string]string
+	implicit []string
+	// stack holds the tokens being converted to catch recursion
+	stack map[*rule]bool
+}
+
+func (op *convertOp) lookup(e *node) (*rule, error) {
+	r, ok := op.rules[strings.ToLower(e.text)]
+	if !ok {
+		return nil, fmt.Errorf("Undefined Rule: %s at %d:%d", e.text, e.line, e.col)
+	}
+	return r, nil
+}
+
+// reach finds the rules and the tokens that can be reached from the start
+// rule.
+func (op *convertOp) reach(start *rule) error {
+	op.reached = map[*rule]bool{start: true}
+	op.used = make(map[*rule]bool)
+	queue := []*rule{start}
+	var visit func(e *node) error
+	visit = func(e *node) error {
+		if e.kind != nRef {
+			for _, s := range e.subs {
+				if err := visit(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		r, err := op.lookup(e)
+		if err != nil {
+			return err
+		}
+		if op.tokens[r] {
+			op.used[r] = true
+		} else if !op.reached[r] {
+			op.reached[r] = true
+			queue = append(queue, r)
+		}
+		return nil
+	}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		if err := visit(r.alt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// symbolName quotes names that regexgram would not read as a single symbol.
+func symbolName(name string) string {
+	if strings.ContainsRune(name, '-') {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+func (op *convertOp) grammarRules() (string, error) {
+	var buf strings.Builder
+	for _, r := range op.order {
+		if !op.reached[r] {
+			continue
+		}
+		name := symbolName(r.name)
+		for i, cat := range r.alt.subs {
+			syms, err := op.symbols(cat)
+			if err != nil {
+				return "", err
+			}
+			if i == 0 {
+				buf.WriteString(name)
+			} else {
+				buf.WriteString(strings.Repeat(" ", len(name)))
+			}
+			buf.WriteString(strings.TrimRight(" -> "+strings.Join(syms, " "), " "))
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String(), nil
+}
+
+func (op *convertOp) symbols(cat *node) ([]string, error) {
+	var syms []string
+	for _, e := range cat.subs {
+		s, opt, err := op.symbol(e)
+		if err != nil {
+			return nil, err
+		}
+		if opt {
+			s += "?"
+		}
+		if s != "" {
+			syms = append(syms, s)
+		}
+	}
+	return syms, nil
+}
+
+// symbol converts an element to a regexgram symbol or group. If opt is true,
+// the element can be empty and the caller should make it optional.
+func (op *convertOp) symbol(e *node) (s string, opt bool, err error) {
+	switch e.kind {
+	case nRef:
+		r, err := op.lookup(e)
+		if err != nil {
+			return "", false, err
+		}
+		return symbolName(r.name), op.nullable[r], nil
+	case nChar, nNum:
+		re, err := op.regex(e)
+		if err != nil || re == "" {
+			return "", false, err
+		}
+		return op.literal(re), false, nil
+	case nAlt:
+		var alts []string
+		for _, cat := range e.subs {
+			syms, err := op.symbols(cat)
+			if err != nil {
+				return "", false, err
+			}
+			switch len(syms) {
+			case 0:
+				opt = true
+			case 1:
+				alts = append(alts, syms[0])
+			default:
+				alts = append(alts, "("+strings.Join(syms, " ")+")")
+			}
+		}
+		switch len(alts) {
+		case 0:
+			return "", false, nil
+		case 1:
+			// an optional symbol like A? is made optional by the caller
+			if s = alts[0]; strings.HasSuffix(s, "?") {
+				s, opt = s[:len(s)-1], true
+			}
+			return s, opt, nil
+		}
+		return "(" + strings.Join(alts, " | ") + ")", opt, nil
+	case nRep:
+		if e.max == 0 {
+			return "", false, nil
+		}
+		s, opt, err := op.symbol(e.subs[0])
+		if err != nil || s == "" {
+			return "", false, err
+		}
+		min := e.min
+		if opt {
+			// repeating an optional element n to m times is the same as
+			// repeating the element 0 to m times
+			min = 0
+		}
+		if last := s[len(s)-1]; last == '*' || (last == '+' && min == 0) {
+			// A* repeated any number of times is A*, so is A+ repeated zero
+			// or more times
+			return s[:len(s)-1] + "*", false, nil
+		}
+		suffix := quantifier(min, e.max)
+		if suffix == "" {
+			return s, false, nil
+		}
+		if strings.ContainsAny(s[len(s)-1:], "+}") {
+			s = "(" + s + ")"
+		}
+		return s + suffix, false, nil
+	}
+	return "", false, fmt.Errorf("Unsupported Construct: prose value <%s> at %d:%d", e.text, e.line, e.col)
+}
+
+// literal returns the token for the regular expression of a literal.
+func (op *convertOp) literal(re string) string {
+	if name, ok := op.literals[re]; ok {
+		return name
+	}
+	name := ""
+	for _, r := range op.order {
+		if op.tokens[r] && op.regexes[r] == re {
+			name = r.name
+			op.used[r] = true
+			break
+		}
+	}
+	if name == "" {
+		name = fmt.Sprintf("T__%d", len(op.implicit))
+		op.implicit = append(op.implicit, re)
+	}
+	op.literals[re] = name
+	return symbolName(name)
+}
+
+func (op *convertOp) lexerRules() string {
+	var buf strings.Builder
+	for i, re := range op.implicit {
+		fmt.Fprintf(&buf, "T__%d /%s/\n", i, re)
+	}
+	for _, r := range op.order {
+		if op.used[r] {
+			fmt.Fprintf(&buf, "%s /%s/\n", r.name, op.regexes[r])
+		}
+	}
+	return buf.String()
+}
+
+// quantifier returns the regular expression suffix for a repetition, it is
+// empty if the element occurs exactly once.
+func quantifier(min, max int) string {
+	switch {
+	case min == 1 && max == 1:
+		return ""
+	case min == 0 && max == 1:
+		return "?"
+	case min == 0 && max == -1:
+		return "*"
+	case min == 1 && max == -1:
+		return "+"
+	case min == max:
+		return "{" + strconv.Itoa(min) + "}"
+	case max == -1:
+		return "{" + strconv.Itoa(min) + ",}"
+	}
+	return "{" + strconv.Itoa(min) + "," + strconv.Itoa(max) + "}"
+}
+
+// regex converts an element of a token to a regular expression. References
+// are inlined.
+func (op *convertOp) regex(e *node) (string, error) {
+	switch e.kind {
+	case nRef:
+		r, err := op.lookup(e)
+		if err != nil {
+			return "", err
+		}
+		if op.stack[r] {
+			return "", fmt.Errorf("Unsupported Construct: recursive token %s at %d:%d", r.name, e.line, e.col)
+		}
+		op.stack[r] = true
+		defer delete(op.stack, r)
+		return op.regex(r.alt)
+	case nChar:
+		re := textscan.QuoteString(e.text)
+		if !e.sensitive && strings.IndexFunc(e.text, unicode.IsLetter) >= 0 {
+			re = "(?i:" + re + ")"
+		}
+		return re, nil
+	case nNum:
+		if e.isRange {
+			return "[" + textscan.QuoteRune(e.runes[0], true) + "-" + textscan.QuoteRune(e.runes[1], true) + "]", nil
+		}
+		var buf strings.Builder
+		for _, r := range e.runes {
+			buf.WriteString(textscan.QuoteRune(r, false))
+		}
+		return buf.String(), nil
+	case nCat, nAlt:
+		strs := make([]string, len(e.subs))
+		for i, s := range e.subs {
+			var err error
+			if strs[i], err = op.regex(s); err != nil {
+				return "", err
+			}
+		}
+		if e.kind == nCat {
+			return strings.Join(strs, ""), nil
+		}
+		if len(strs) == 1 {
+			return strs[0], nil
+		}
+		return "(?:" + strings.Join(strs, "|") + ")", nil
+	case nRep:
+		re, err := op.regex(e.subs[0])
+		if err != nil || e.max == 0 {
+			return "", err
+		}
+		if q := quantifier(e.min, e.max); q != "" {
+			re = "(?:" + re + ")" + q
+		}
+		return re, nil
+	}
+	return "", fmt.Errorf("Unsupported Construct: prose value <%s> at %d:%d", e.text, e.line, e.col)
+}
@@ -0,0 +1,124 @@
+package abnf
+
+import (
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const requestLine = `
+; from RFC 7230, simplified
+request-line   = method SP request-target SP HTTP-version CRLF
+method         = "GET" / "POST" / %s"HEAD"
+request-target = "/" *( ALPHA / DIGIT / "/" / "." )
+HTTP-version   = %s"HTTP/" DIGIT "." DIGIT
+unused         = "x"
+`
+
+func TestNew(t *testing.T) {
+	g, err := New(requestLine, "request-target", "http-version")
+	assert.NoError(t, err)
+
+	assert.Equal(t, `"request-line" -> method SP "request-target" SP "HTTP-version" CRLF
+method -> T__0
+       -> T__1
+       -> T__2
+`, g.GrammarRules)
+	assert.Equal(t, `T__0 /(?i:GET)/
+T__1 /(?i:POST)/
+T__2 /HEAD/
+request-target /\/(?:(?:(?:[A-Z]|[a-z])|[0-9]|\/|\.))*/
+HTTP-version /HTTP\/[0-9]\.[0-9]/
+CRLF /\r\n/
+SP / /
+`, g.LexerRules)
+
+	pn := g.Reducer.Reduce(packrat.New(g.Grammar).Parse(g.Lexer.Lex("get /a/b.html HTTP/1.1\r\n")))
+	if assert.NotNil(t, pn) && assert.Equal(t, 6, pn.Children()) {
+		kinds := []string{"method", "SP", "request-target", "SP", "HTTP-version", "CRLF"}
+		values := []string{"", " ", "/a/b.html", " ", "HTTP/1.1", "\r\n"}
+		for i, k := range kinds {
+			assert.Equal(t, k, pn.Child(i).Kind().String())
+			assert.Equal(t, values[i], pn.Child(i).Value())
+		}
+		assert.Equal(t, "get", pn.Child(0).Child(0).Value())
+	}
+
+	// HEAD is case sensitive
+	pn = g.Reducer.Reduce(packrat.New(g.Grammar).Parse(g.Lexer.Lex("head / HTTP/1.1\r\n")))
+	assert.Nil(t, pn)
+}
+
+func TestElements(t *testing.T) {
+	g, err := New(`
+list   = elem *( "," elem ) [";"]
+LIST   =/ 2*3DIGIT
+elem   = ( "a" / "b" "c" ) / 0*1elem-x / LWSP "x" / 0"y"
+Elem-X = %x41.42 / %d67-69 / *(1*"z")
+`)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `list -> elem (T__0 elem)* T__1?
+     -> DIGIT{2,3}
+elem -> (T__2 | (T__3 T__4))
+     -> "Elem-X"?
+     -> LWSP? T__5
+     ->
+"Elem-X" -> T__6
+         -> T__7
+         -> T__8*
+`, g.GrammarRules)
+	assert.Equal(t, `T__0 /,/
+T__1 /;/
+T__2 /(?i:a)/
+T__3 /(?i:b)/
+T__4 /(?i:c)/
+T__5 /(?i:x)/
+T__6 /AB/
+T__7 /[C-E]/
+T__8 /(?i:z)/
+DIGIT /[0-9]/
+LWSP /(?:(?:(?: |\t)|\r\n(?: |\t)))*/
+`, g.LexerRules)
+}
+
+func TestLiteralToken(t *testing.T) {
+	// a literal that is the same as a token uses the token
+	g, err := New(`
+pair  = key %x3D value
+key   = 1*ALPHA
+value = 1*DIGIT
+EQ    = "="
+`, "key", "value", "EQ")
+	assert.NoError(t, err)
+	assert.Equal(t, "pair -> key EQ value\n", g.GrammarRules)
+}
+
+func TestErrors(t *testing.T) {
+	tt := []struct {
+		src, msg string
+		tokens   []string
+	}{
+		{"", "Bad Grammar: no rules", nil},
+		{"x\na = b", `Bad Grammar: expected a rule, got 'x' at 1:1`, nil},
+		{"a = b\nb = c )", `Bad Grammar: expected "/" or the end of the rule, got ')' at 2:7`, nil},
+		{"a = (b", `Bad Grammar: expected ')', got end of rule at 1:7`, nil},
+		{"a = \"b\n  c", `Bad Grammar: expected '"', got '\n' at 1:7`, nil},
+		{"a = %q41", `Bad Grammar: expected "b", "d" or "x", got 'q' at 1:6`, nil},
+		{"a = %x41-30", "Bad Range: the end is before the start at 1:5", nil},
+		{"a = 3*2b", "Bad Repetition: 3*2 at 1:5", nil},
+		{"a = b\nA = c", "Duplicate Rule: A at 2:1", nil},
+		{"a =/ b", "Undefined Rule: a has no definition before =/ at 1:1", nil},
+		{"a = b", "Undefined Rule: b at 1:5", nil},
+		{"a = <prose>", "Unsupported Construct: prose value <prose> at 1:5", nil},
+		{"a = b\nb = \"x\" b", "Unsupported Construct: recursive token b at 2:9", []string{"b"}},
+		{"a = DIGIT", "Bad Grammar: the first rule a is a token", []string{"a"}},
+		{"a = DIGIT", "Undefined Rule: c", []string{"c"}},
+	}
+	for _, tc := range tt {
+		_, err := New(tc.src, tc.tokens...)
+		if assert.Error(t, err, tc.src) {
+			assert.Equal(t, tc.msg, err.Error(), tc.src)
+		}
+	}
+}
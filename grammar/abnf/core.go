@@ -0,0 +1,33 @@
+package abnf
+
+// coreRules are the core rules from appendix B.1 of RFC 5234. They can be used
+// by any rule list and are always tokens.
+const coreRules = `
+ALPHA  = %x41-5A / %x61-7A   ; A-Z / a-z
+BIT    = "0" / "1"
+CHAR   = %x01-7F             ; any 7-bit US-ASCII character, excluding NUL
+CR     = %x0D                ; carriage return
+CRLF   = CR LF               ; Internet standard newline
+CTL    = %x00-1F / %x7F      ; controls
+DIGIT  = %x30-39             ; 0-9
+DQUOTE = %x22                ; " (Double Quote)
+HEXDIG = DIGIT / "A" / "B" / "C" / "D" / "E" / "F"
+HTAB   = %x09                ; horizontal tab
+LF     = %x0A                ; linefeed
+LWSP   = *(WSP / CRLF WSP)   ; linear white space
+OCTET  = %x00-FF             ; 8 bits of data
+SP     = %x20
+VCHAR  = %x21-7E             ; visible (printing) characters
+WSP    = SP / HTAB           ; white space
+`
+
+func core() []*rule {
+	rules, err := parse(coreRules)
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range rules {
+		r.core = true
+	}
+	return rules
+}
@@ -0,0 +1,363 @@
+package abnf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type nodeKind byte
+
+const (
+	nRef nodeKind = iota
+	nChar
+	nNum
+	nProse
+	nCat
+	nAlt
+	nRep
+)
+
+type node struct {
+	kind nodeKind
+	// text is the name of a reference or the contents of a char-val or a
+	// prose-val
+	text string
+	// sensitive is set for a %s char-val
+	sensitive bool
+	// runes are the values of a num-val, if isRange is set they are the bounds
+	// of a range
+	runes   []rune
+	isRange bool
+	// min and max bound a repetition, max is -1 if there is no upper bound
+	min, max  int
+	subs      []*node
+	line, col int
+}
+
+type rule struct {
+	name string
+	// alt always has the kind nAlt
+	alt *node
+	// incremental is set for =/ which adds alternatives to a rule
+	incremental bool
+	core        bool
+	line, col   int
+}
+
+// ruleStart matches the start of a line that defines a rule. Rules are
+// usually indented when they are copied out of an RFC, so the indentation is
+// ignored.
+var ruleStart = regexp.MustCompile(`^[ \t]*[A-Za-z][A-Za-z0-9-]*[ \t]*=`)
+
+type parser struct {
+	src        []rune
+	lineStarts []int
+	cur, end   int
+}
+
+// parse returns the rules in the order they are defined, the =/ form is
+// returned as a separate rule with incremental set.
+func parse(src string) ([]*rule, error) {
+	p := &parser{
+		src: []rune(src),
+	}
+	var starts []int
+	for i, lineStart := 0, 0; i <= len(p.src); i++ {
+		if i < len(p.src) && p.src[i] != '\n' {
+			continue
+		}
+		p.lineStarts = append(p.lineStarts, lineStart)
+		line := string(p.src[lineStart:i])
+		if m := ruleStart.FindString(line); m != "" {
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			starts = append(starts, lineStart+indent)
+		}
+		lineStart = i + 1
+	}
+	starts = append(starts, len(p.src))
+
+	// only comments can come before the first rule
+	p.end = starts[0]
+	if p.skip(); p.cur < p.end {
+		return nil, p.expected("a rule")
+	}
+
+	var rules []*rule
+	for i := 0; i+1 < len(starts); i++ {
+		p.cur, p.end = starts[i], starts[i+1]
+		r, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// pos returns the line and column of an offset.
+func (p *parser) pos(offset int) (int, int) {
+	line := sort.Search(len(p.lineStarts), func(i int) bool { return p.lineStarts[i] > offset })
+	return line, offset - p.lineStarts[line-1] + 1
+}
+
+func (p *parser) peek() rune {
+	if p.cur >= p.end {
+		return 0
+	}
+	return p.src[p.cur]
+}
+
+func (p *parser) expected(what string) error {
+	got := "end of rule"
+	if p.cur < p.end {
+		got = strconv.QuoteRune(p.src[p.cur])
+	}
+	line, col := p.pos(p.cur)
+	return fmt.Errorf("Bad Grammar: expected %s, got %s at %d:%d", what, got, line, col)
+}
+
+// skip advances past white space, line breaks and comments. A rule can
+// continue on the following lines so line breaks are the same as spaces.
+func (p *parser) skip() {
+	for p.cur < p.end {
+		switch r := p.peek(); {
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			p.cur++
+		case r == ';':
+			for p.cur < p.end && p.peek() != '\n' {
+				p.cur++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) node(kind nodeKind) *node {
+	line, col := p.pos(p.cur)
+	return &node{
+		kind: kind,
+		line: line,
+		col:  col,
+	}
+}
+
+func isNameRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-')
+}
+
+func (p *parser) name() string {
+	start := p.cur
+	for p.cur < p.end && isNameRune(p.peek()) {
+		p.cur++
+	}
+	return string(p.src[start:p.cur])
+}
+
+func (p *parser) rule() (*rule, error) {
+	p.skip()
+	line, col := p.pos(p.cur)
+	r := &rule{
+		name: p.name(),
+		line: line,
+		col:  col,
+	}
+	p.skip()
+	// ruleStart guarantees the =
+	p.cur++
+	if r.incremental = p.peek() == '/'; r.incremental {
+		p.cur++
+	}
+	var err error
+	if r.alt, err = p.alternation(); err != nil {
+		return nil, err
+	}
+	if p.skip(); p.cur < p.end {
+		return nil, p.expected(`"/" or the end of the rule`)
+	}
+	return r, nil
+}
+
+func (p *parser) alternation() (*node, error) {
+	p.skip()
+	alt := p.node(nAlt)
+	for {
+		cat, err := p.concatenation()
+		if err != nil {
+			return nil, err
+		}
+		alt.subs = append(alt.subs, cat)
+		if p.skip(); p.peek() != '/' {
+			return alt, nil
+		}
+		p.cur++
+		p.skip()
+	}
+}
+
+func (p *parser) concatenation() (*node, error) {
+	cat := p.node(nCat)
+	for {
+		rep, err := p.repetition()
+		if err != nil {
+			return nil, err
+		}
+		cat.subs = append(cat.subs, rep)
+		p.skip()
+		if r := p.peek(); p.cur >= p.end || r == '/' || r == ')' || r == ']' {
+			return cat, nil
+		}
+	}
+}
+
+func (p *parser) digits() string {
+	start := p.cur
+	for p.cur < p.end && p.peek() >= '0' && p.peek() <= '9' {
+		p.cur++
+	}
+	return string(p.src[start:p.cur])
+}
+
+func (p *parser) repetition() (*node, error) {
+	rep := p.node(nRep)
+	min := p.digits()
+	isRep := min != ""
+	max := min
+	if p.peek() == '*' {
+		p.cur++
+		isRep = true
+		max = p.digits()
+	}
+	e, err := p.element()
+	if err != nil || !isRep {
+		return e, err
+	}
+	rep.subs = []*node{e}
+	rep.max = -1
+	if min != "" {
+		rep.min, _ = strconv.Atoi(min)
+	}
+	if max != "" {
+		rep.max, _ = strconv.Atoi(max)
+		if rep.max < rep.min {
+			return nil, fmt.Errorf("Bad Repetition: %s*%s at %d:%d", min, max, rep.line, rep.col)
+		}
+	}
+	return rep, nil
+}
+
+func (p *parser) element() (*node, error) {
+	r := p.peek()
+	switch {
+	case p.cur < p.end && r < unicode.MaxASCII && unicode.IsLetter(r):
+		e := p.node(nRef)
+		e.text = p.name()
+		return e, nil
+	case r == '(' || r == '[':
+		start := p.node(nRep)
+		p.cur++
+		alt, err := p.alternation()
+		if err != nil {
+			return nil, err
+		}
+		end := ')'
+		if r == '[' {
+			end = ']'
+		}
+		if p.peek() != end {
+			return nil, p.expected(strconv.QuoteRune(end))
+		}
+		p.cur++
+		if r == '(' {
+			return alt, nil
+		}
+		start.min, start.max, start.subs = 0, 1, []*node{alt}
+		return start, nil
+	case r == '"':
+		return p.charVal(false)
+	case r == '<':
+		e := p.node(nProse)
+		text, err := p.until('>')
+		e.text = text
+		return e, err
+	case r == '%':
+		return p.numVal()
+	}
+	return nil, p.expected("an element")
+}
+
+// until reads from the opening rune to the closing rune and returns the text
+// between them. The text cannot contain a line break.
+func (p *parser) until(end rune) (string, error) {
+	p.cur++
+	start := p.cur
+	for p.peek() != end {
+		if p.cur >= p.end || p.peek() == '\n' {
+			return "", p.expected(strconv.QuoteRune(end))
+		}
+		p.cur++
+	}
+	p.cur++
+	return string(p.src[start : p.cur-1]), nil
+}
+
+func (p *parser) charVal(sensitive bool) (*node, error) {
+	e := p.node(nChar)
+	e.sensitive = sensitive
+	var err error
+	e.text, err = p.until('"')
+	return e, err
+}
+
+var bases = map[rune]int{
+	'b': 2,
+	'd': 10,
+	'x': 16,
+}
+
+// numVal reads a num-val like %x41, %x41-5A or %x41.42.43, or a char-val
+// with the %s or %i prefix from RFC 7405.
+func (p *parser) numVal() (*node, error) {
+	e := p.node(nNum)
+	p.cur++
+	r := unicode.ToLower(p.peek())
+	if (r == 's' || r == 'i') && p.cur+1 < p.end && p.src[p.cur+1] == '"' {
+		p.cur++
+		n, err := p.charVal(r == 's')
+		n.line, n.col = e.line, e.col
+		return n, err
+	}
+	base, ok := bases[r]
+	if !ok {
+		return nil, p.expected(`"b", "d" or "x"`)
+	}
+	p.cur++
+	for {
+		start := p.cur
+		for p.cur < p.end && strings.ContainsRune("0123456789abcdefABCDEF", p.peek()) {
+			p.cur++
+		}
+		v, err := strconv.ParseUint(string(p.src[start:p.cur]), base, 32)
+		if err != nil {
+			p.cur = start
+			return nil, p.expected("a number")
+		}
+		e.runes = append(e.runes, rune(v))
+
+		switch {
+		case p.peek() == '-' && len(e.runes) == 1:
+			e.isRange = true
+		case p.peek() == '.' && !e.isRange:
+		default:
+			if e.isRange && e.runes[1] < e.runes[0] {
+				return nil, fmt.Errorf("Bad Range: the end is before the start at %d:%d", e.line, e.col)
+			}
+			return e, nil
+		}
+		p.cur++
+	}
+}
@@ -0,0 +1,284 @@
+// Package analysis computes the nullable, FIRST and FOLLOW sets of a grammar.
+// These are used by table driven parsers and are helpful for producing error
+// messages that list what was expected. It also finds the shortest string of
+// terminals each symbol derives, which shows why a grammar matches nothing when
+// a non-terminal derives no string at all.
+package analysis
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+)
+
+// EOF is the symbol used in FOLLOW sets for the end of the input.
+const EOF = "$end"
+
+// Analysis holds the nullable, FIRST and FOLLOW sets of a grammar. A symbol is
+// nullable if it can derive an empty string. The FIRST set of a symbol is the
+// terminals that can begin a string it derives and the FOLLOW set of a
+// non-terminal is the terminals that can come directly after it.
+type Analysis struct {
+	parlex.Grammar
+	set      *setsymbol.Set
+	end      int
+	isNT     []bool
+	nullable []bool
+	first    [][]bool
+	follow   [][]bool
+	// shortest is nil for a symbol that derives no string of terminals
+	shortest [][]int
+}
+
+// New analyzes a grammar. The grammar is embedded so the Analysis can be used
+// as a parlex.Grammar.
+func New(grammar parlex.Grammar) *Analysis {
+	set := setsymbol.New()
+	set.LoadGrammar(grammar)
+	a := &Analysis{
+		Grammar: grammar,
+		set:     set,
+		end:     set.Str(EOF).Idx(),
+	}
+	ln := set.Size()
+	a.isNT = make([]bool, ln)
+	for _, nt := range grammar.NonTerminals() {
+		a.isNT[set.Symbol(nt).Idx()] = true
+	}
+	a.nullable = make([]bool, ln)
+	a.first = make([][]bool, ln)
+	a.follow = make([][]bool, ln)
+	for i := range a.first {
+		a.first[i] = make([]bool, ln)
+		a.follow[i] = make([]bool, ln)
+		if !a.isNT[i] {
+			a.first[i][i] = true
+		}
+	}
+	a.findFirsts()
+	a.findFollows()
+	a.findShortest()
+	return a
+}
+
+// prods calls fn with each non-terminal and the symbol indexes of each of its
+// productions.
+func (a *Analysis) prods(fn func(nt int, symbols []int)) {
+	for _, nt := range a.NonTerminals() {
+		ntIdx := a.set.Symbol(nt).Idx()
+		for i := a.Productions(nt).Iter(); i.Next(); {
+			symbols := make([]int, 0, i.Symbols())
+			for j := i.Iter(); j.Next(); {
+				symbols = append(symbols, a.set.Symbol(j.Symbol).Idx())
+			}
+			fn(ntIdx, symbols)
+		}
+	}
+}
+
+// union adds every symbol in from to to and returns true if to changed.
+func union(to, from []bool) bool {
+	changed := false
+	for i, in := range from {
+		if in && !to[i] {
+			to[i] = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (a *Analysis) findFirsts() {
+	for changed := true; changed; {
+		changed = false
+		a.prods(func(nt int, symbols []int) {
+			allNullable := true
+			for _, s := range symbols {
+				changed = union(a.first[nt], a.first[s]) || changed
+				if !a.nullable[s] {
+					allNullable = false
+					break
+				}
+			}
+			if allNullable && !a.nullable[nt] {
+				a.nullable[nt] = true
+				changed = true
+			}
+		})
+	}
+}
+
+func (a *Analysis) findFollows() {
+	nts := a.NonTerminals()
+	if len(nts) == 0 {
+		return
+	}
+	a.follow[a.set.Symbol(nts[0]).Idx()][a.end] = true
+	for changed := true; changed; {
+		changed = false
+		a.prods(func(nt int, symbols []int) {
+			// walk backwards, trailer holds FOLLOW of the current position
+			trailer := make([]bool, len(a.follow))
+			copy(trailer, a.follow[nt])
+			for i := len(symbols) - 1; i >= 0; i-- {
+				s := symbols[i]
+				if a.isNT[s] {
+					changed = union(a.follow[s], trailer) || changed
+				}
+				if !a.nullable[s] {
+					trailer = make([]bool, len(a.follow))
+				}
+				union(trailer, a.first[s])
+			}
+		})
+	}
+}
+
+// findShortest finds the shortest string of terminals for each non-terminal.
+// Like Dijkstra's algorithm, on each pass the non-terminal with the shortest
+// string, using only productions whose symbols are all done, is done. So a
+// string is only built from strings that are already done and a non-terminal
+// that is never done derives no string. Ties go to the first production in the
+// grammar.
+func (a *Analysis) findShortest() {
+	a.shortest = make([][]int, len(a.isNT))
+	for i, nt := range a.isNT {
+		if !nt {
+			a.shortest[i] = []int{i}
+		}
+	}
+	type prod struct {
+		nt      int
+		symbols []int
+	}
+	var prods []prod
+	a.prods(func(nt int, symbols []int) {
+		prods = append(prods, prod{nt, symbols})
+	})
+	for {
+		best, bestLn := -1, 0
+		for i, p := range prods {
+			if a.shortest[p.nt] != nil {
+				continue
+			}
+			ln := 0
+			for _, s := range p.symbols {
+				if a.shortest[s] == nil {
+					ln = -1
+					break
+				}
+				ln += len(a.shortest[s])
+			}
+			if ln >= 0 && (best == -1 || ln < bestLn) {
+				best, bestLn = i, ln
+			}
+		}
+		if best == -1 {
+			return
+		}
+		str := make([]int, 0, bestLn)
+		for _, s := range prods[best].symbols {
+			str = append(str, a.shortest[s]...)
+		}
+		a.shortest[prods[best].nt] = str
+	}
+}
+
+func (a *Analysis) symbols(in []bool) []parlex.Symbol {
+	var out []parlex.Symbol
+	for i, ok := range in {
+		if ok {
+			out = append(out, a.set.ByIdx(i))
+		}
+	}
+	return out
+}
+
+// IsNonTerminal returns true if the symbol is a non-terminal in the grammar.
+func (a *Analysis) IsNonTerminal(symbol parlex.Symbol) bool {
+	s := a.set.HasSymbol(symbol)
+	return s != nil && a.isNT[s.Idx()]
+}
+
+// Nullable returns true if the symbol can derive an empty string.
+func (a *Analysis) Nullable(symbol parlex.Symbol) bool {
+	s := a.set.HasSymbol(symbol)
+	return s != nil && a.nullable[s.Idx()]
+}
+
+// First returns the FIRST set of a symbol. The FIRST set of a terminal is
+// itself.
+func (a *Analysis) First(symbol parlex.Symbol) []parlex.Symbol {
+	s := a.set.HasSymbol(symbol)
+	if s == nil {
+		return []parlex.Symbol{symbol}
+	}
+	return a.symbols(a.first[s.Idx()])
+}
+
+// FirstOf returns the FIRST set of a sequence of symbols, like the tail of a
+// production, and whether the whole sequence is nullable.
+func (a *Analysis) FirstOf(production parlex.Production) ([]parlex.Symbol, bool) {
+	first := make([]bool, len(a.first))
+	for i := production.Iter(); i.Next(); {
+		s := a.set.HasSymbol(i.Symbol)
+		if s == nil {
+			// a symbol that is not in the grammar is a terminal
+			return append(a.symbols(first), i.Symbol), false
+		}
+		union(first, a.first[s.Idx()])
+		if !a.nullable[s.Idx()] {
+			return a.symbols(first), false
+		}
+	}
+	return a.symbols(first), true
+}
+
+// Follow returns the FOLLOW set of a non-terminal. If the non-terminal can end
+// the input, the set will include EOF.
+func (a *Analysis) Follow(symbol parlex.Symbol) []parlex.Symbol {
+	s := a.set.HasSymbol(symbol)
+	if s == nil {
+		return nil
+	}
+	return a.symbols(a.follow[s.Idx()])
+}
+
+// Productive returns true if the symbol derives at least one string of
+// terminals. A terminal is productive. A non-terminal that is not productive can
+// never be matched, nor can any production that uses it.
+func (a *Analysis) Productive(symbol parlex.Symbol) bool {
+	s := a.set.HasSymbol(symbol)
+	return s == nil || a.shortest[s.Idx()] != nil
+}
+
+// Unproductive returns the non-terminals that derive no string of terminals, in
+// the order of the grammar.
+func (a *Analysis) Unproductive() []parlex.Symbol {
+	var out []parlex.Symbol
+	for _, nt := range a.NonTerminals() {
+		if a.shortest[a.set.Symbol(nt).Idx()] == nil {
+			out = append(out, nt)
+		}
+	}
+	return out
+}
+
+// Shortest returns the shortest string of terminals the symbol derives and true.
+// If the symbol derives no string, it returns false. A terminal derives itself.
+// When there are several shortest strings, the one built from the earliest
+// productions is returned. The string for a nullable symbol may be empty.
+func (a *Analysis) Shortest(symbol parlex.Symbol) ([]parlex.Symbol, bool) {
+	s := a.set.HasSymbol(symbol)
+	if s == nil {
+		return []parlex.Symbol{symbol}, true
+	}
+	str := a.shortest[s.Idx()]
+	if str == nil {
+		return nil, false
+	}
+	out := make([]parlex.Symbol, len(str))
+	for i, idx := range str {
+		out[i] = a.set.ByIdx(idx)
+	}
+	return out, true
+}
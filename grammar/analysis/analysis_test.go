@@ -0,0 +1,137 @@
+package analysis_test
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func strs(symbols []parlex.Symbol) []string {
+	out := make([]string, len(symbols))
+	for i, s := range symbols {
+		out[i] = s.String()
+	}
+	return out
+}
+
+func TestAnalysis(t *testing.T) {
+	g, err := grammar.New(`
+    E  -> T E'
+    E' -> + T E'
+       ->
+    T  -> F T'
+    T' -> * F T'
+       ->
+    F  -> ( E )
+       -> id
+  `)
+	assert.NoError(t, err)
+	a := analysis.New(g)
+
+	assert.True(t, a.IsNonTerminal(stringsymbol.Symbol("E'")))
+	assert.False(t, a.IsNonTerminal(stringsymbol.Symbol("id")))
+
+	nullable := map[string]bool{"E": false, "E'": true, "T": false, "T'": true, "F": false, "id": false}
+	for s, n := range nullable {
+		assert.Equal(t, n, a.Nullable(stringsymbol.Symbol(s)), s)
+	}
+
+	first := map[string][]string{
+		"E":  {"(", "id"},
+		"E'": {"+"},
+		"T":  {"(", "id"},
+		"T'": {"*"},
+		"F":  {"(", "id"},
+		"id": {"id"},
+	}
+	for s, f := range first {
+		assert.Equal(t, f, strs(a.First(stringsymbol.Symbol(s))), s)
+	}
+
+	follow := map[string][]string{
+		"E":  {")", analysis.EOF},
+		"E'": {")", analysis.EOF},
+		"T":  {"+", ")", analysis.EOF},
+		"T'": {"+", ")", analysis.EOF},
+		"F":  {"+", "*", ")", analysis.EOF},
+	}
+	for s, f := range follow {
+		assert.Equal(t, f, strs(a.Follow(stringsymbol.Symbol(s))), s)
+	}
+
+	f, n := a.FirstOf(stringsymbol.Production{"T'", "E'"})
+	assert.Equal(t, []string{"+", "*"}, strs(f))
+	assert.True(t, n)
+
+	f, n = a.FirstOf(stringsymbol.Production{"T'", "x"})
+	assert.Equal(t, []string{"*", "x"}, strs(f))
+	assert.False(t, n)
+}
+
+func TestShortest(t *testing.T) {
+	g, err := grammar.New(`
+    S -> A B
+      -> C
+    A -> ( A )
+      -> a a
+      -> B
+    B -> b
+      ->
+    C -> C c
+      -> D
+    D -> d D
+  `)
+	assert.NoError(t, err)
+	a := analysis.New(g)
+
+	shortest := map[string][]string{
+		"S": {},
+		"A": {},
+		"B": {},
+		"b": {"b"},
+	}
+	for s, expected := range shortest {
+		str, ok := a.Shortest(stringsymbol.Symbol(s))
+		assert.True(t, ok, s)
+		assert.Equal(t, expected, strs(str), s)
+		assert.True(t, a.Productive(stringsymbol.Symbol(s)), s)
+	}
+
+	for _, s := range []string{"C", "D"} {
+		str, ok := a.Shortest(stringsymbol.Symbol(s))
+		assert.False(t, ok, s)
+		assert.Nil(t, str, s)
+		assert.False(t, a.Productive(stringsymbol.Symbol(s)), s)
+	}
+	assert.Equal(t, []string{"C", "D"}, strs(a.Unproductive()))
+
+	g, err = grammar.New(`
+    E -> E + T
+      -> T
+    T -> ( E )
+      -> id
+  `)
+	assert.NoError(t, err)
+	a = analysis.New(g)
+	str, ok := a.Shortest(stringsymbol.Symbol("E"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"id"}, strs(str))
+	str, ok = a.Shortest(stringsymbol.Symbol("x"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"x"}, strs(str))
+	assert.Len(t, a.Unproductive(), 0)
+
+	g, err = grammar.New(`
+    P -> ( Q )
+    Q -> x y
+      -> z w
+      -> P
+  `)
+	assert.NoError(t, err)
+	a = analysis.New(g)
+	str, _ = a.Shortest(stringsymbol.Symbol("P"))
+	assert.Equal(t, []string{"(", "x", "y", ")"}, strs(str))
+}
@@ -0,0 +1,279 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+	"strings"
+)
+
+type cnfRule struct {
+	symbols []string
+	t       *shape
+}
+
+type cnfOp struct {
+	order []string
+	rules map[string][]cnfRule
+	used  map[string]bool
+	// empty holds an empty derivation for each nullable non-terminal
+	empty map[string]*shape
+}
+
+// ToCNF converts a grammar to Chomsky normal form; every production is either
+// two non-terminals or a single terminal. If the start symbol is nullable, a new
+// start symbol is added that is the only one with an empty production.
+//   E -> E + E
+//     -> int
+// becomes
+//   E  -> E E'
+//      -> int
+//   E' -> +' E
+//   +' -> +
+// Empty productions are removed by adding a copy of each production without
+// the nullable symbols, a non-terminal that is just another non-terminal takes
+// its productions, a terminal in a longer production is replaced with a helper
+// for the terminal and a long production is split into a chain of helpers. The
+// helpers are named by adding ' to a symbol until the name is not used.
+//
+// The reducer will restore trees parsed with the new grammar to the shape they
+// would have with the original grammar, removing the helper non-terminals and
+// putting back the empty derivations and the non-terminals that were taken
+// out.
+func ToCNF(grammar parlex.Grammar) (*Grammar, tree.Reducer) {
+	op := &cnfOp{
+		rules: make(map[string][]cnfRule),
+		used:  make(map[string]bool),
+		empty: make(map[string]*shape),
+	}
+	for _, nt := range grammar.NonTerminals() {
+		kind := nt.String()
+		op.order = append(op.order, kind)
+		op.used[kind] = true
+		for i := grammar.Productions(nt).Iter(); i.Next(); {
+			symbols := make([]string, i.Symbols())
+			for j := i.Iter(); j.Next(); {
+				symbols[j.Idx] = j.Symbol.String()
+				op.used[symbols[j.Idx]] = true
+			}
+			op.rules[kind] = append(op.rules[kind], cnfRule{symbols, identityShape(kind, len(symbols))})
+		}
+	}
+	if len(op.order) == 0 {
+		return Empty(), tree.Reducer{}
+	}
+
+	op.findEmpty()
+	if start := op.order[0]; op.empty[start] != nil {
+		s := op.fresh(start)
+		op.order = append([]string{s}, op.order...)
+		op.rules[s] = []cnfRule{{[]string{start}, &shape{slot: 0}}}
+	}
+	op.removeEmpty()
+	op.removeUnits()
+	op.prune()
+	return op.build()
+}
+
+// fresh returns a symbol that is not used by adding ' to the name.
+func (op *cnfOp) fresh(name string) string {
+	name += "'"
+	for op.used[name] {
+		name += "'"
+	}
+	op.used[name] = true
+	return name
+}
+
+func (op *cnfOp) isNT(symbol string) bool {
+	_, ok := op.rules[symbol]
+	return ok
+}
+
+func (op *cnfOp) findEmpty() {
+	for changed := true; changed; {
+		changed = false
+		for _, nt := range op.order {
+			if op.empty[nt] != nil {
+				continue
+			}
+			for _, r := range op.rules[nt] {
+				t := &shape{slot: -1, kind: nt}
+				for _, s := range r.symbols {
+					e := op.empty[s]
+					if e == nil {
+						t = nil
+						break
+					}
+					t.c = append(t.c, e)
+				}
+				if t != nil {
+					op.empty[nt] = t
+					changed = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// add appends a rule unless the non-terminal already has a rule with the same
+// symbols.
+func add(rules []cnfRule, r cnfRule) []cnfRule {
+	key := strings.Join(r.symbols, " ")
+	for _, cur := range rules {
+		if len(cur.symbols) == len(r.symbols) && strings.Join(cur.symbols, " ") == key {
+			return rules
+		}
+	}
+	return append(rules, r)
+}
+
+// removeEmpty replaces each rule with a copy for each way of leaving out its
+// nullable symbols. Only the start symbol keeps an empty rule.
+func (op *cnfOp) removeEmpty() {
+	for _, nt := range op.order {
+		var rules []cnfRule
+		for _, r := range op.rules[nt] {
+			var opt []int
+			for i, s := range r.symbols {
+				if op.empty[s] != nil {
+					opt = append(opt, i)
+				}
+			}
+			for mask := 0; mask < 1<<uint(len(opt)); mask++ {
+				omit := make(map[int]bool, len(opt))
+				for b, i := range opt {
+					omit[i] = mask&(1<<uint(b)) != 0
+				}
+				var symbols []string
+				m := make([]*shape, len(r.symbols))
+				for i, s := range r.symbols {
+					if omit[i] {
+						m[i] = op.empty[s]
+						continue
+					}
+					m[i] = &shape{slot: len(symbols)}
+					symbols = append(symbols, s)
+				}
+				if (len(symbols) == 0 && nt != op.order[0]) || (len(symbols) == 1 && symbols[0] == nt) {
+					continue
+				}
+				rules = add(rules, cnfRule{symbols, r.t.remap(m)})
+			}
+		}
+		op.rules[nt] = rules
+	}
+}
+
+// removeUnits replaces each rule that is a single non-terminal with the rules
+// of that non-terminal.
+func (op *cnfOp) removeUnits() {
+	type unit struct {
+		nt string
+		// wrap holds the node of nt in slot 0, nil means the node is the rule
+		wrap *shape
+	}
+	compose := func(wrap, t *shape) *shape {
+		if wrap == nil {
+			return t
+		}
+		return wrap.remap([]*shape{t})
+	}
+	out := make(map[string][]cnfRule, len(op.rules))
+	for _, nt := range op.order {
+		var rules []cnfRule
+		seen := map[string]bool{nt: true}
+		queue := []unit{{nt: nt}}
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			for _, r := range op.rules[u.nt] {
+				t := compose(u.wrap, r.t)
+				if len(r.symbols) == 1 && op.isNT(r.symbols[0]) {
+					if s := r.symbols[0]; !seen[s] {
+						seen[s] = true
+						queue = append(queue, unit{s, t})
+					}
+					continue
+				}
+				rules = add(rules, cnfRule{r.symbols, t})
+			}
+		}
+		out[nt] = rules
+	}
+	op.rules = out
+}
+
+// prune removes the rules that use a non-terminal that has no rules left,
+// which happens to a non-terminal that only derives the empty string.
+func (op *cnfOp) prune() {
+	for changed := true; changed; {
+		changed = false
+		for _, nt := range op.order {
+			var rules []cnfRule
+			for _, r := range op.rules[nt] {
+				keep := true
+				for _, s := range r.symbols {
+					if op.isNT(s) && len(op.rules[s]) == 0 {
+						keep = false
+						break
+					}
+				}
+				if keep {
+					rules = append(rules, r)
+				} else {
+					changed = true
+				}
+			}
+			op.rules[nt] = rules
+		}
+	}
+}
+
+// build the CNF grammar and the reducer that undoes it.
+func (op *cnfOp) build() (*Grammar, tree.Reducer) {
+	g := Empty()
+	sr := newShapeReducer()
+	r := tree.Reducer{}
+	terms := make(map[string]string)
+	var termOrder []string
+	for _, nt := range op.order {
+		needed := false
+		for _, rule := range op.rules[nt] {
+			sr.shapes[shapeKey(nt, rule.symbols)] = rule.t
+			if len(rule.symbols) > 2 || !rule.t.isIdentity(nt) {
+				needed = true
+			}
+			symbols := make([]string, len(rule.symbols))
+			for i, s := range rule.symbols {
+				symbols[i] = s
+				if len(rule.symbols) == 1 || op.isNT(s) {
+					continue
+				}
+				if terms[s] == "" {
+					terms[s] = op.fresh(s)
+					sr.helpers[terms[s]] = true
+					termOrder = append(termOrder, s)
+				}
+				symbols[i] = terms[s]
+				needed = true
+			}
+			from := nt
+			for len(symbols) > 2 {
+				h := op.fresh(nt)
+				sr.helpers[h] = true
+				g.Add(stringsymbol.Symbol(from), production(symbols[0], h))
+				from, symbols = h, symbols[1:]
+			}
+			g.Add(stringsymbol.Symbol(from), production(symbols...))
+		}
+		if needed {
+			r[nt] = sr.restore
+		}
+	}
+	for _, s := range termOrder {
+		g.Add(stringsymbol.Symbol(terms[s]), production(s))
+	}
+	return g, r
+}
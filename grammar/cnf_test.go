@@ -0,0 +1,104 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/earley"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestToCNF(t *testing.T) {
+	grmr, err := New(`
+    S -> A b A
+      ->
+    A -> a
+      -> S
+      -> x y z
+  `)
+	assert.NoError(t, err)
+	cnf, _ := ToCNF(grmr)
+
+	expected, err := New(`
+    S'   ->
+         -> A S''
+         -> b' A
+         -> A b'
+         -> b
+    S''  -> b' A
+    S    -> A S'''
+         -> b' A
+         -> A b'
+         -> b
+    S''' -> b' A
+    A    -> a
+         -> x' A'
+         -> A A''
+         -> b' A
+         -> A b'
+         -> b
+    A'   -> y' z'
+    A''  -> b' A
+    b'   -> b
+    x'   -> x
+    y'   -> y
+    z'   -> z
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), cnf.String())
+}
+
+func TestToCNFReducer(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    int /\d+/
+    op  /[+\-\*]/
+    (   /\(/
+    )   /\)/
+    ,   /,/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		grammar string
+		inputs  []string
+	}{
+		{
+			grammar: `
+        E -> E op T
+          -> T
+        T -> ( E )
+          -> int
+      `,
+			inputs: []string{"1 + 2 * (3 - 4)", "1"},
+		},
+		{
+			grammar: `
+        L     -> ( Items )
+              ->
+        Items -> Item , Items
+              -> Item
+        Item  -> int
+              -> L
+              ->
+      `,
+			inputs: []string{"", "()", "(,)", "(1, (2, ()), 3)"},
+		},
+	}
+
+	for _, tc := range tests {
+		grmr, err := New(tc.grammar)
+		assert.NoError(t, err)
+		cnf, rdcr := ToCNF(grmr)
+		for _, input := range tc.inputs {
+			expected, err := earley.New(grmr).ParseErr(lxr.Lex(input))
+			if !assert.NoError(t, err, input) {
+				continue
+			}
+			pn, err := earley.New(cnf).ParseErr(lxr.Lex(input))
+			if assert.NoError(t, err, input) {
+				assert.Equal(t, expected.(*tree.PN).String(), rdcr.RawReduce(pn).String(), input)
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package diagram renders the rules of a grammar as SVG railroad diagrams, one
+// per non-terminal. The productions of a non-terminal are drawn as branches of
+// a choice and each production is a track through its symbols. Terminals are
+// drawn in rounded boxes and non-terminals in square boxes.
+package diagram
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"html"
+	"strings"
+)
+
+// Sizes used in the layout, in pixels.
+const (
+	charWidth = 8
+	boxPad    = 10
+	boxHeight = 24
+	gap       = 16
+	radius    = 10
+	branchGap = 8
+	margin    = 10
+	titleH    = 24
+)
+
+// element is a part of a diagram. The track enters on the left and leaves on
+// the right at axis, measured from the top of the element.
+type element interface {
+	size() (w, h, axis int)
+	draw(buf *strings.Builder, x, y int)
+}
+
+type box struct {
+	text    string
+	nonterm bool
+}
+
+func (b box) size() (int, int, int) {
+	return len([]rune(b.text))*charWidth + 2*boxPad, boxHeight, boxHeight / 2
+}
+
+func (b box) draw(buf *strings.Builder, x, y int) {
+	w, h, _ := b.size()
+	rx := h / 2
+	class := "terminal"
+	if b.nonterm {
+		rx = 0
+		class = "nonterminal"
+	}
+	fmt.Fprintf(buf, `<rect class="%s" x="%d" y="%d" width="%d" height="%d" rx="%d"/>`+"\n", class, x, y, w, h, rx)
+	fmt.Fprintf(buf, `<text x="%d" y="%d">%s</text>`+"\n", x+w/2, y+h/2+4, html.EscapeString(b.text))
+}
+
+// sequence is a production, its elements are joined by lines. An empty
+// sequence is a plain line.
+type sequence []element
+
+func (s sequence) size() (int, int, int) {
+	w, above, below := gap, 0, 0
+	for _, e := range s {
+		ew, eh, ea := e.size()
+		w += ew + gap
+		if ea > above {
+			above = ea
+		}
+		if eh-ea > below {
+			below = eh - ea
+		}
+	}
+	return w, above + below, above
+}
+
+func (s sequence) draw(buf *strings.Builder, x, y int) {
+	_, _, axis := s.size()
+	line(buf, x, y+axis, x+gap, y+axis)
+	x += gap
+	for _, e := range s {
+		ew, _, ea := e.size()
+		e.draw(buf, x, y+axis-ea)
+		line(buf, x+ew, y+axis, x+ew+gap, y+axis)
+		x += ew + gap
+	}
+}
+
+// choice is the productions of a non-terminal. The first branch is on the
+// axis and the rest are stacked below it.
+type choice []element
+
+func (c choice) size() (int, int, int) {
+	var w, h int
+	for i, e := range c {
+		ew, eh, _ := e.size()
+		if ew > w {
+			w = ew
+		}
+		if i > 0 {
+			h += branchGap
+		}
+		h += eh
+	}
+	_, _, axis := c[0].size()
+	return w + 4*radius, h, axis
+}
+
+func (c choice) draw(buf *strings.Builder, x, y int) {
+	w, _, axis := c.size()
+	inner := w - 4*radius
+	top := y
+	for i, e := range c {
+		ew, eh, ea := e.size()
+		by := top + ea
+		bx := x + 2*radius
+		if i == 0 {
+			line(buf, x, y+axis, bx, by)
+			line(buf, bx+ew, by, x+w, y+axis)
+		} else {
+			// down from the axis on the left and back up on the right
+			fmt.Fprintf(buf, `<path d="M%d %d Q%d %d %d %d L%d %d Q%d %d %d %d"/>`+"\n",
+				x, y+axis, x+radius, y+axis, x+radius, y+axis+radius,
+				x+radius, by-radius, x+radius, by, bx, by)
+			line(buf, bx+ew, by, bx+inner, by)
+			fmt.Fprintf(buf, `<path d="M%d %d Q%d %d %d %d L%d %d Q%d %d %d %d"/>`+"\n",
+				bx+inner, by, x+w-radius, by, x+w-radius, by-radius,
+				x+w-radius, y+axis+radius, x+w-radius, y+axis, x+w, y+axis)
+		}
+		e.draw(buf, bx, top)
+		top += eh + branchGap
+	}
+}
+
+func line(buf *strings.Builder, x1, y1, x2, y2 int) {
+	if x1 == x2 && y1 == y2 {
+		return
+	}
+	fmt.Fprintf(buf, `<path d="M%d %d L%d %d"/>`+"\n", x1, y1, x2, y2)
+}
+
+const style = `<style>
+path { fill: none; stroke: #333; stroke-width: 2; }
+rect { fill: #eef; stroke: #333; stroke-width: 2; }
+rect.nonterminal { fill: #efe; }
+circle { fill: #333; }
+text { font-family: monospace; font-size: 13px; text-anchor: middle; }
+text.title { font-weight: bold; text-anchor: start; }
+</style>
+`
+
+// SVG renders the productions of a non-terminal as a railroad diagram. If the
+// symbol is not a non-terminal in the grammar, an empty string is returned.
+func SVG(g parlex.Grammar, nonterminal parlex.Symbol) string {
+	prods := g.Productions(nonterminal)
+	if prods == nil || prods.Productions() == 0 {
+		return ""
+	}
+	c := make(choice, 0, prods.Productions())
+	for i := prods.Iter(); i.Next(); {
+		s := make(sequence, 0, i.Symbols())
+		for j := i.Iter(); j.Next(); {
+			s = append(s, box{text: j.String(), nonterm: !parlex.IsTerminal(g, j.Symbol)})
+		}
+		c = append(c, s)
+	}
+
+	w, h, axis := c.size()
+	width, height := w+2*margin+2*gap, h+2*margin+titleH
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	buf.WriteString(style)
+	fmt.Fprintf(&buf, `<text class="title" x="%d" y="%d">%s</text>`+"\n", margin, margin+titleH/2, html.EscapeString(nonterminal.String()))
+	x, y := margin, margin+titleH+axis
+	fmt.Fprintf(&buf, `<circle cx="%d" cy="%d" r="4"/>`+"\n", x, y)
+	line(&buf, x, y, x+gap, y)
+	c.draw(&buf, x+gap, y-axis)
+	line(&buf, x+gap+w, y, x+2*gap+w, y)
+	fmt.Fprintf(&buf, `<circle cx="%d" cy="%d" r="4"/>`+"\n", x+2*gap+w, y)
+	buf.WriteString("</svg>\n")
+	return buf.String()
+}
+
+// HTML renders a page with the diagram of every non-terminal in the grammar, in
+// the order of the grammar.
+func HTML(g parlex.Grammar) string {
+	var buf strings.Builder
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<body>\n")
+	for _, nt := range g.NonTerminals() {
+		fmt.Fprintf(&buf, "<div id=\"%s\">\n", html.EscapeString(nt.String()))
+		buf.WriteString(SVG(g, nt))
+		buf.WriteString("</div>\n")
+	}
+	buf.WriteString("</body>\n</html>\n")
+	return buf.String()
+}
@@ -0,0 +1,76 @@
+package diagram
+
+import (
+	"encoding/xml"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+// elements counts the elements in an XML document by name and the text of the
+// text elements.
+func elements(t *testing.T, doc string) (map[string]int, []string) {
+	counts := make(map[string]int)
+	var texts []string
+	d := xml.NewDecoder(strings.NewReader(doc))
+	var inText bool
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			break
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			counts[tok.Name.Local]++
+			inText = tok.Name.Local == "text"
+		case xml.CharData:
+			if inText {
+				texts = append(texts, string(tok))
+			}
+		case xml.EndElement:
+			inText = false
+		}
+	}
+	return counts, texts
+}
+
+func TestSVG(t *testing.T) {
+	g, err := grammar.New(`
+    E -> E < T
+      -> T
+      ->
+    T -> int
+  `)
+	assert.NoError(t, err)
+
+	svg := SVG(g, stringsymbol.Symbol("E"))
+	counts, texts := elements(t, svg)
+	assert.Equal(t, 1, counts["svg"])
+	assert.Equal(t, 4, counts["rect"])
+	assert.Equal(t, 2, counts["circle"])
+	assert.Equal(t, []string{"E", "E", "<", "T", "T"}, texts)
+	assert.Contains(t, svg, `<rect class="terminal"`)
+	assert.Contains(t, svg, `<rect class="nonterminal"`)
+
+	assert.Equal(t, "", SVG(g, stringsymbol.Symbol("int")))
+}
+
+func TestHTML(t *testing.T) {
+	g, err := grammar.New(`
+    S -> ( S )
+      -> x
+    X -> S
+  `)
+	assert.NoError(t, err)
+	page := HTML(g)
+	assert.True(t, strings.HasPrefix(page, "<!DOCTYPE html>\n"))
+	counts, _ := elements(t, strings.TrimPrefix(page, "<!DOCTYPE html>\n"))
+	assert.Equal(t, 2, counts["svg"])
+	assert.Equal(t, 5, counts["rect"])
+}
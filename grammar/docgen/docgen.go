@@ -0,0 +1,327 @@
+// Package docgen renders a grammar, and optionally the lexer for its terminals,
+// as documentation in Markdown or HTML. Each non-terminal gets a section with
+// its productions, its FIRST set and example sentences generated from the
+// grammar. Symbols link to the section that defines them, so the document can
+// be read like the grammar itself.
+//
+//	md, err := docgen.Doc{
+//	  Title:    "Calc",
+//	  Grammar:  grmr,
+//	  Lexer:    lxr,
+//	  Examples: 3,
+//	}.Markdown()
+package docgen
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"github.com/adamcolton/parlex/grammar/diagram"
+	"github.com/adamcolton/parlex/grammar/gen"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Doc describes the documentation for a grammar. The Lexer can be nil; if it
+// is set, its rules are listed and used for the values of the terminals in the
+// examples. Examples is the number of example sentences for each
+// non-terminal, and Seed seeds the generator so the output is the same each
+// time.
+type Doc struct {
+	Title    string
+	Grammar  parlex.Grammar
+	Lexer    *simplelexer.Lexer
+	Examples int
+	Seed     int64
+}
+
+// ExampleDepth is the MaxDepth used to generate examples, which keeps them short
+// enough to read.
+const ExampleDepth = 4
+
+// section is what is known about a non-terminal.
+type section struct {
+	symbol   parlex.Symbol
+	name     string
+	prods    [][]parlex.Symbol
+	first    []string
+	nullable bool
+	examples []string
+}
+
+// rule is a lexer rule, pattern is empty for a kind without a rule.
+type rule struct {
+	kind, pattern string
+	discard       bool
+}
+
+// page is what a Doc renders.
+type page struct {
+	title    string
+	sections []section
+	rules    []rule
+	nts      map[string]bool
+	kinds    map[string]bool
+}
+
+func (d Doc) page() (*page, error) {
+	if d.Grammar == nil {
+		return nil, parlex.ErrBadGrammar
+	}
+	p := &page{
+		title: d.Title,
+		nts:   make(map[string]bool),
+		kinds: make(map[string]bool),
+	}
+	if p.title == "" {
+		p.title = "Grammar"
+	}
+	a := analysis.New(d.Grammar)
+	g := gen.New(d.Grammar, d.Seed)
+	g.MaxDepth = ExampleDepth
+	if d.Lexer != nil {
+		if err := g.UseLexer(d.Lexer); err != nil {
+			return nil, err
+		}
+		discards := make(map[string]bool)
+		for _, s := range d.Lexer.Discards() {
+			discards[s.String()] = true
+		}
+		for _, s := range d.Lexer.Symbols() {
+			r := rule{kind: s.String(), discard: discards[s.String()]}
+			if re := d.Lexer.Pattern(s); re != nil {
+				r.pattern = re.String()
+			}
+			p.rules = append(p.rules, r)
+			p.kinds[r.kind] = true
+		}
+	}
+
+	for _, nt := range d.Grammar.NonTerminals() {
+		p.nts[nt.String()] = true
+	}
+	for _, nt := range d.Grammar.NonTerminals() {
+		s := section{
+			symbol:   nt,
+			name:     nt.String(),
+			nullable: a.Nullable(nt),
+		}
+		for i := d.Grammar.Productions(nt).Iter(); i.Next(); {
+			var prod []parlex.Symbol
+			for j := i.Iter(); j.Next(); {
+				prod = append(prod, j.Symbol)
+			}
+			s.prods = append(s.prods, prod)
+		}
+		for _, f := range a.First(nt) {
+			s.first = append(s.first, f.String())
+		}
+		seen := make(map[string]bool)
+		// a few more tries than examples, so duplicates can be skipped
+		for i := 0; i < 3*d.Examples && len(s.examples) < d.Examples; i++ {
+			lxs, err := g.SentenceOf(nt)
+			if err != nil {
+				// a non-terminal that never finishes has no examples
+				break
+			}
+			if ex := text(lxs); !seen[ex] {
+				seen[ex] = true
+				s.examples = append(s.examples, ex)
+			}
+		}
+		p.sections = append(p.sections, s)
+	}
+	return p, nil
+}
+
+// text joins the values of the lexemes with spaces, using the kind for a
+// lexeme without a value.
+func text(lxs []parlex.Lexeme) string {
+	strs := make([]string, len(lxs))
+	for i, lx := range lxs {
+		if strs[i] = lx.Value(); strs[i] == "" {
+			strs[i] = lx.Kind().String()
+		}
+	}
+	return strings.Join(strs, " ")
+}
+
+// anchor replaces anything in a symbol that is not a letter, digit, - or _ so
+// it can be used as the id of a section.
+func anchor(prefix, symbol string) string {
+	return prefix + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, symbol)
+}
+
+// Markdown renders the documentation as Markdown.
+func (d Doc) Markdown() (string, error) {
+	p, err := d.page()
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "# %s\n\n", p.title)
+	buf.WriteString("## Non-Terminals\n\n")
+	for _, s := range p.sections {
+		fmt.Fprintf(&buf, "* [%s](#%s)\n", s.name, anchor("nt-", s.name))
+	}
+	for _, s := range p.sections {
+		fmt.Fprintf(&buf, "\n<a id=\"%s\"></a>\n### %s\n\n", anchor("nt-", s.name), s.name)
+		for _, prod := range s.prods {
+			fmt.Fprintf(&buf, "* %s → %s\n", s.name, p.mdProduction(prod))
+		}
+		buf.WriteString("\nFIRST: ")
+		if len(s.first) == 0 {
+			buf.WriteString("*none*")
+		}
+		for i, f := range s.first {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(p.mdSymbol(f))
+		}
+		if s.nullable {
+			buf.WriteString(" (nullable)")
+		}
+		buf.WriteString("\n")
+		if len(s.examples) > 0 {
+			buf.WriteString("\nExamples:\n\n")
+			for _, ex := range s.examples {
+				fmt.Fprintf(&buf, "    %s\n", ex)
+			}
+		}
+	}
+	if len(p.rules) > 0 {
+		buf.WriteString("\n## Lexer\n\n| Kind | Pattern |\n| --- | --- |\n")
+		for _, r := range p.rules {
+			pattern := "*none*"
+			if r.pattern != "" {
+				pattern = "`" + strings.Replace(r.pattern, "|", `\|`, -1) + "`"
+			}
+			if r.discard {
+				pattern += " (discarded)"
+			}
+			fmt.Fprintf(&buf, "| <a id=\"%s\"></a>`%s` | %s |\n", anchor("lex-", r.kind), r.kind, pattern)
+		}
+	}
+	return buf.String(), nil
+}
+
+func (p *page) mdProduction(prod []parlex.Symbol) string {
+	if len(prod) == 0 {
+		return "*empty*"
+	}
+	strs := make([]string, len(prod))
+	for i, s := range prod {
+		strs[i] = p.mdSymbol(s.String())
+	}
+	return strings.Join(strs, " ")
+}
+
+func (p *page) mdSymbol(s string) string {
+	if p.nts[s] {
+		return fmt.Sprintf("[%s](#%s)", s, anchor("nt-", s))
+	}
+	if p.kinds[s] {
+		return fmt.Sprintf("[`%s`](#%s)", s, anchor("lex-", s))
+	}
+	return "`" + s + "`"
+}
+
+const style = `<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+code, pre { background: #f4f4f4; }
+a.nt { font-weight: bold; }
+td, th { border-bottom: 1px solid #ddd; padding: 2px 8px; text-align: left; }
+</style>
+`
+
+// HTML renders the documentation as a page of HTML. Each non-terminal also has
+// the railroad diagram from the diagram package.
+func (d Doc) HTML() (string, error) {
+	p, err := d.page()
+	if err != nil {
+		return "", err
+	}
+	esc := html.EscapeString
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html>\n<head>\n<title>%s</title>\n%s</head>\n<body>\n", esc(p.title), style)
+	fmt.Fprintf(&buf, "<h1>%s</h1>\n<h2>Non-Terminals</h2>\n<ul>\n", esc(p.title))
+	for _, s := range p.sections {
+		fmt.Fprintf(&buf, "<li><a href=\"#%s\">%s</a></li>\n", anchor("nt-", s.name), esc(s.name))
+	}
+	buf.WriteString("</ul>\n")
+	for _, s := range p.sections {
+		fmt.Fprintf(&buf, "<h3 id=\"%s\">%s</h3>\n", anchor("nt-", s.name), esc(s.name))
+		buf.WriteString(diagram.SVG(d.Grammar, s.symbol))
+		buf.WriteString("<ul>\n")
+		for _, prod := range s.prods {
+			fmt.Fprintf(&buf, "<li>%s → %s</li>\n", esc(s.name), p.htmlProduction(prod))
+		}
+		buf.WriteString("</ul>\n<p>FIRST: ")
+		if len(s.first) == 0 {
+			buf.WriteString("<em>none</em>")
+		}
+		for i, f := range s.first {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(p.htmlSymbol(f))
+		}
+		if s.nullable {
+			buf.WriteString(" (nullable)")
+		}
+		buf.WriteString("</p>\n")
+		if len(s.examples) > 0 {
+			buf.WriteString("<p>Examples:</p>\n<pre>\n")
+			for _, ex := range s.examples {
+				buf.WriteString(esc(ex) + "\n")
+			}
+			buf.WriteString("</pre>\n")
+		}
+	}
+	if len(p.rules) > 0 {
+		buf.WriteString("<h2>Lexer</h2>\n<table>\n<tr><th>Kind</th><th>Pattern</th></tr>\n")
+		for _, r := range p.rules {
+			pattern := "<em>none</em>"
+			if r.pattern != "" {
+				pattern = "<code>" + esc(r.pattern) + "</code>"
+			}
+			if r.discard {
+				pattern += " (discarded)"
+			}
+			fmt.Fprintf(&buf, "<tr id=\"%s\"><td><code>%s</code></td><td>%s</td></tr>\n", anchor("lex-", r.kind), esc(r.kind), pattern)
+		}
+		buf.WriteString("</table>\n")
+	}
+	buf.WriteString("</body>\n</html>\n")
+	return buf.String(), nil
+}
+
+func (p *page) htmlProduction(prod []parlex.Symbol) string {
+	if len(prod) == 0 {
+		return "<em>empty</em>"
+	}
+	strs := make([]string, len(prod))
+	for i, s := range prod {
+		strs[i] = p.htmlSymbol(s.String())
+	}
+	return strings.Join(strs, " ")
+}
+
+func (p *page) htmlSymbol(s string) string {
+	esc := html.EscapeString(s)
+	if p.nts[s] {
+		return fmt.Sprintf("<a class=\"nt\" href=\"#%s\">%s</a>", anchor("nt-", s), esc)
+	}
+	if p.kinds[s] {
+		return fmt.Sprintf("<a href=\"#%s\"><code>%s</code></a>", anchor("lex-", s), esc)
+	}
+	return "<code>" + esc + "</code>"
+}
@@ -0,0 +1,89 @@
+package docgen
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    keywords let
+    lp    /\(/
+    rp    /\)/
+    op    /[+*]|-/
+    int   /\d+/
+    ident /[a-z]\w*/
+    space /\s+/ -
+  `)).(*simplelexer.Lexer)
+
+var grmr = parlex.MustGrammar(grammar.New(`
+    S    -> let ident E
+         -> E
+    E    -> E op E
+         -> lp E rp
+         -> int
+    Opt  -> int
+         ->
+  `))
+
+func TestMarkdown(t *testing.T) {
+	d := Doc{
+		Title:    "Calc",
+		Grammar:  grmr,
+		Lexer:    lxr,
+		Examples: 2,
+		Seed:     1,
+	}
+	md, err := d.Markdown()
+	assert.NoError(t, err)
+	for _, expect := range []string{
+		"# Calc\n",
+		"* [E](#nt-E)\n",
+		"<a id=\"nt-S\"></a>\n### S\n",
+		"* S → [`let`](#lex-let) [`ident`](#lex-ident) [E](#nt-E)\n",
+		"* E → [`lp`](#lex-lp) [E](#nt-E) [`rp`](#lex-rp)\n",
+		"* Opt → *empty*\n",
+		" (nullable)\n",
+		"\nExamples:\n\n    ",
+		"| <a id=\"lex-op\"></a>`op` | `[+*]\\|-` |\n",
+		"| <a id=\"lex-let\"></a>`let` | `let` |\n",
+		"`\\s+` (discarded) |\n",
+	} {
+		assert.Contains(t, md, expect)
+	}
+	i := strings.Index(md, "### E")
+	assert.Contains(t, md[i:], "FIRST: [`lp`](#lex-lp), [`int`](#lex-int)\n")
+
+	// examples are kept short
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(line, "    ") {
+			assert.True(t, len(line) < 80, line)
+		}
+	}
+
+	again, _ := d.Markdown()
+	assert.Equal(t, md, again)
+
+	// without a lexer, terminals are not linked and examples use the kinds
+	d.Lexer = nil
+	md, err = d.Markdown()
+	assert.NoError(t, err)
+	assert.Contains(t, md, "* E → `int`\n")
+	assert.NotContains(t, md, "## Lexer")
+
+	_, err = Doc{}.Markdown()
+	assert.Error(t, err)
+}
+
+func TestHTML(t *testing.T) {
+	h, err := Doc{Grammar: grmr, Lexer: lxr, Examples: 1}.HTML()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(h, "<!DOCTYPE html>"))
+	assert.Contains(t, h, "<title>Grammar</title>")
+	assert.Contains(t, h, "<h3 id=\"nt-E\">E</h3>\n<svg")
+	assert.Contains(t, h, "<li>S → <a href=\"#lex-let\"><code>let</code></a>")
+	assert.Contains(t, h, "<tr id=\"lex-int\"><td><code>int</code></td><td><code>\\d+</code></td></tr>")
+}
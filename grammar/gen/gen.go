@@ -0,0 +1,236 @@
+// Package gen generates random sentences from a grammar. The sentences can be
+// used with Go's native fuzzing to check that a parser accepts everything a
+// grammar derives and never panics.
+//
+//   func FuzzCalc(f *testing.F) {
+//     g := gen.New(grmr, 0)
+//     g.UseLexer(lxr)
+//     f.Add(int64(1))
+//     f.Fuzz(func(t *testing.T, seed int64) {
+//       g.Seed(seed)
+//       lxs, err := g.Sentence()
+//       if err != nil {
+//         t.Fatal(err)
+//       }
+//       if err := gen.Check(prsr, lxs); err != nil {
+//         t.Fatal(gen.Text(lxs, " "), err)
+//       }
+//     })
+//   }
+//
+// Only the context free part of a grammar is used; predicates and precedence
+// are not considered, so a parser that uses them may reject a sentence.
+package gen
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"math/rand"
+	"strings"
+)
+
+// DefaultMaxDepth is the MaxDepth of a Generator returned by New.
+const DefaultMaxDepth = 10
+
+// Value generates the value of a terminal.
+type Value func(r *rand.Rand) string
+
+// Generator creates random sentences from a grammar. Once the depth of a
+// derivation reaches MaxDepth, only the productions that finish it in the
+// fewest steps are chosen. If the grammar is a parlex.WeightedGrammar, each
+// production is chosen in proportion to its weight. Values holds the
+// generators for the values of terminals by kind, a terminal without one has
+// its kind as its value.
+type Generator struct {
+	parlex.Grammar
+	MaxDepth int
+	Values   map[string]Value
+	rand     *rand.Rand
+	// heights of the shortest derivation of each non-terminal and production,
+	// -1 if there is none
+	heights  map[string]int
+	pHeights map[string][]int
+}
+
+// New returns a Generator for the grammar using the seed.
+func New(grmr parlex.Grammar, seed int64) *Generator {
+	return &Generator{
+		Grammar:  grmr,
+		MaxDepth: DefaultMaxDepth,
+		Values:   make(map[string]Value),
+		rand:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Seed resets the random source so the same seed always produces the same
+// sentences.
+func (g *Generator) Seed(seed int64) {
+	g.rand.Seed(seed)
+}
+
+// Regexp sets the value of a terminal to strings that match a regexp.
+func (g *Generator) Regexp(kind, re string) error {
+	v, err := RegexpValue(re)
+	if err != nil {
+		return err
+	}
+	g.Values[kind] = v
+	return nil
+}
+
+// UseLexer sets the values of the terminals from the rules and keywords of the
+// lexer.
+func (g *Generator) UseLexer(lxr *simplelexer.Lexer) error {
+	for _, kind := range lxr.Symbols() {
+		re := lxr.Pattern(kind)
+		if re == nil {
+			continue
+		}
+		if err := g.Regexp(kind.String(), re.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sentence returns the lexemes of a random sentence derived from the start
+// symbol.
+func (g *Generator) Sentence() ([]parlex.Lexeme, error) {
+	nts := g.NonTerminals()
+	if len(nts) == 0 {
+		return nil, parlex.ErrBadGrammar
+	}
+	return g.SentenceOf(nts[0])
+}
+
+// SentenceOf returns the lexemes of a random sentence derived from a symbol of
+// the grammar. A terminal derives itself.
+func (g *Generator) SentenceOf(symbol parlex.Symbol) ([]parlex.Lexeme, error) {
+	if g.heights == nil {
+		g.findHeights()
+	}
+	return g.derive(symbol, 0, nil)
+}
+
+func (g *Generator) derive(symbol parlex.Symbol, depth int, lxs []parlex.Lexeme) ([]parlex.Lexeme, error) {
+	name := symbol.String()
+	prods := g.Productions(symbol)
+	if prods == nil || prods.Productions() == 0 {
+		lx := lexeme.New(symbol).Set(name)
+		if v, ok := g.Values[name]; ok {
+			lx.V = v(g.rand)
+		}
+		return append(lxs, lx), nil
+	}
+	if g.heights[name] < 0 {
+		return nil, fmt.Errorf("Infinite Non-Terminal: %s", name)
+	}
+
+	// choose from the productions that fit under MaxDepth, or if none do,
+	// from the ones that finish soonest
+	var choices []int
+	for i, h := range g.pHeights[name] {
+		if h >= 0 && depth+h <= g.MaxDepth {
+			choices = append(choices, i)
+		}
+	}
+	if choices == nil {
+		for i, h := range g.pHeights[name] {
+			if h == g.heights[name] {
+				choices = append(choices, i)
+			}
+		}
+	}
+
+	var err error
+	for i := prods.Production(g.choose(symbol, choices)).Iter(); i.Next(); {
+		if lxs, err = g.derive(i.Symbol, depth+1, lxs); err != nil {
+			return nil, err
+		}
+	}
+	return lxs, nil
+}
+
+// choose picks one of the productions by weight. If the grammar has no
+// weights or none of the productions has any weight, they are equally likely.
+func (g *Generator) choose(nt parlex.Symbol, choices []int) int {
+	wg, ok := g.Grammar.(parlex.WeightedGrammar)
+	if !ok {
+		return choices[g.rand.Intn(len(choices))]
+	}
+	ws := make([]float64, len(choices))
+	var total float64
+	for i, c := range choices {
+		ws[i] = wg.Weight(nt, c)
+		total += ws[i]
+	}
+	if total <= 0 {
+		return choices[g.rand.Intn(len(choices))]
+	}
+	r := g.rand.Float64() * total
+	for i, w := range ws {
+		if r < w {
+			return choices[i]
+		}
+		r -= w
+	}
+	return choices[len(choices)-1]
+}
+
+// findHeights finds the height of the shortest derivation of every
+// non-terminal and production. A terminal has a height of 0.
+func (g *Generator) findHeights() {
+	nts := g.NonTerminals()
+	g.heights = make(map[string]int, len(nts))
+	g.pHeights = make(map[string][]int, len(nts))
+	for _, nt := range nts {
+		g.heights[nt.String()] = -1
+		g.pHeights[nt.String()] = make([]int, g.Productions(nt).Productions())
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, nt := range nts {
+			name := nt.String()
+			for i := g.Productions(nt).Iter(); i.Next(); {
+				h := 1
+				for j := i.Iter(); j.Next() && h > 0; {
+					sh, ok := g.heights[j.String()]
+					if ok && sh < 0 {
+						h = -1
+					} else if ok && sh+1 > h {
+						h = sh + 1
+					}
+				}
+				g.pHeights[name][i.Idx] = h
+				if h > 0 && (g.heights[name] < 0 || h < g.heights[name]) {
+					g.heights[name] = h
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// Text joins the values of the lexemes with a separator.
+func Text(lxs []parlex.Lexeme, sep string) string {
+	strs := make([]string, len(lxs))
+	for i, lx := range lxs {
+		strs[i] = lx.Value()
+	}
+	return strings.Join(strs, sep)
+}
+
+// Check parses the lexemes and returns an error if the parse fails or the
+// parser panics.
+func Check(prsr parlex.Parser, lxs []parlex.Lexeme) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Parser Panic: %v", r)
+		}
+	}()
+	_, err = parlex.ParseContext(context.Background(), prsr, lxs)
+	return err
+}
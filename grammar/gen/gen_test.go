@@ -0,0 +1,143 @@
+package gen_test
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/gen"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    keywords let -> let
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    ident /[a-z]\w*/
+    space /\s+/ -
+  `)).(*simplelexer.Lexer)
+
+var grmr = parlex.MustGrammar(grammar.New(`
+    S -> let ident E
+      -> E
+    E -> E op E
+      -> ( E )
+      -> int
+  `))
+
+func TestSentence(t *testing.T) {
+	g := gen.New(grmr, 1)
+	assert.NoError(t, g.UseLexer(lxr))
+	g.MaxDepth = 4
+	prsr := packrat.New(grmr)
+	for i := 0; i < 50; i++ {
+		lxs, err := g.Sentence()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, gen.Check(prsr, lxs), gen.Text(lxs, " "))
+		// the text lexes back to the same kinds
+		text := gen.Text(lxs, " ")
+		relexed := lxr.Lex(text)
+		if assert.Len(t, relexed, len(lxs), text) {
+			for j, lx := range lxs {
+				assert.Equal(t, lx.Kind().String(), relexed[j].Kind().String(), text)
+			}
+		}
+	}
+
+	// a sentence of E never has let
+	for i := 0; i < 20; i++ {
+		lxs, err := g.SentenceOf(grmr.NonTerminals()[1])
+		if assert.NoError(t, err) {
+			for _, lx := range lxs {
+				assert.NotEqual(t, "let", lx.Kind().String())
+			}
+		}
+	}
+
+	g.Seed(7)
+	a, _ := g.Sentence()
+	g.Seed(7)
+	b, _ := g.Sentence()
+	assert.Equal(t, gen.Text(a, " "), gen.Text(b, " "))
+}
+
+func TestInfinite(t *testing.T) {
+	g := gen.New(parlex.MustGrammar(grammar.New(`
+    S -> A
+      -> x
+    A -> A y
+  `)), 1)
+	for i := 0; i < 20; i++ {
+		lxs, err := g.Sentence()
+		if err != nil {
+			assert.EqualError(t, err, "Infinite Non-Terminal: A")
+			return
+		}
+		assert.Equal(t, "x", lxs[0].Kind().String())
+	}
+	_, err := gen.New(parlex.MustGrammar(grammar.New(``)), 1).Sentence()
+	assert.Equal(t, parlex.ErrBadGrammar, err)
+}
+
+func TestWeights(t *testing.T) {
+	g := gen.New(parlex.MustGrammar(grammar.New(`
+    A -> x @9
+      -> y
+  `)), 1)
+	xs := 0
+	for i := 0; i < 1000; i++ {
+		lxs, err := g.Sentence()
+		if !assert.NoError(t, err) {
+			return
+		}
+		// a terminal without a value has its kind as its value
+		if gen.Text(lxs, " ") == "x" {
+			xs++
+		}
+	}
+	assert.InDelta(t, 900, xs, 50)
+}
+
+func TestRegexpValue(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, re := range []string{`[a-z]\w*`, `"([^"\\]|\\.)*"`, `\d{2,4}(\.\d+)?`, `(?i)select`, `a|b|c`} {
+		v, err := gen.RegexpValue(re)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		check := regexp.MustCompile(`^(?:` + re + `)$`)
+		for i := 0; i < 20; i++ {
+			s := v(rnd)
+			assert.True(t, check.MatchString(s), "%s %q", re, s)
+		}
+	}
+	_, err := gen.RegexpValue("(")
+	assert.Error(t, err)
+}
+
+func FuzzSentence(f *testing.F) {
+	g := gen.New(grmr, 0)
+	if err := g.UseLexer(lxr); err != nil {
+		f.Fatal(err)
+	}
+	prsr := packrat.New(grmr)
+	f.Add(int64(1))
+	f.Add(int64(2))
+	f.Fuzz(func(t *testing.T, seed int64) {
+		g.Seed(seed)
+		lxs, err := g.Sentence()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gen.Check(prsr, lxs); err != nil {
+			t.Fatal(gen.Text(lxs, " "), err)
+		}
+	})
+}
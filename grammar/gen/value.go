@@ -0,0 +1,94 @@
+package gen
+
+import (
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// maxRepeat is the most extra times a repetition like * or + is repeated.
+const maxRepeat = 3
+
+// RegexpValue returns a Value that generates strings matching a regexp.
+// Characters are chosen from printable ASCII when the regexp allows it.
+func RegexpValue(re string) (Value, error) {
+	r, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	r = r.Simplify()
+	return func(rnd *rand.Rand) string {
+		var b strings.Builder
+		generate(&b, r, rnd)
+		return b.String()
+	}, nil
+}
+
+func generate(b *strings.Builder, r *syntax.Regexp, rnd *rand.Rand) {
+	switch r.Op {
+	case syntax.OpLiteral:
+		for _, c := range r.Rune {
+			if r.Flags&syntax.FoldCase != 0 && rnd.Intn(2) == 0 {
+				c = unicode.SimpleFold(c)
+			}
+			b.WriteRune(c)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(charFrom(r.Rune, rnd))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteRune(rune(' ' + rnd.Intn('~'-' '+1)))
+	case syntax.OpCapture:
+		generate(b, r.Sub[0], rnd)
+	case syntax.OpStar:
+		repeat(b, r.Sub[0], 0, maxRepeat, rnd)
+	case syntax.OpPlus:
+		repeat(b, r.Sub[0], 1, 1+maxRepeat, rnd)
+	case syntax.OpQuest:
+		repeat(b, r.Sub[0], 0, 1, rnd)
+	case syntax.OpRepeat:
+		most := r.Max
+		if most < 0 {
+			most = r.Min + maxRepeat
+		}
+		repeat(b, r.Sub[0], r.Min, most, rnd)
+	case syntax.OpConcat:
+		for _, sub := range r.Sub {
+			generate(b, sub, rnd)
+		}
+	case syntax.OpAlternate:
+		generate(b, r.Sub[rnd.Intn(len(r.Sub))], rnd)
+	}
+}
+
+func repeat(b *strings.Builder, r *syntax.Regexp, least, most int, rnd *rand.Rand) {
+	n := least + rnd.Intn(most-least+1)
+	for i := 0; i < n; i++ {
+		generate(b, r, rnd)
+	}
+}
+
+// charFrom picks a rune from pairs of ranges, preferring printable ASCII.
+func charFrom(ranges []rune, rnd *rand.Rand) rune {
+	var ascii []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo < ' ' {
+			lo = ' '
+		}
+		if hi > '~' {
+			hi = '~'
+		}
+		if lo <= hi {
+			ascii = append(ascii, lo, hi)
+		}
+	}
+	if len(ascii) > 0 {
+		ranges = ascii
+	}
+	if len(ranges) == 0 {
+		return 0
+	}
+	i := 2 * rnd.Intn(len(ranges)/2)
+	return ranges[i] + rune(rnd.Int63n(int64(ranges[i+1]-ranges[i]+1)))
+}
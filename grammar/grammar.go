@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -64,22 +67,119 @@ type Grammar struct {
 	longest     int
 	totalCount  int
 	set         *setsymbol.Set
+	precedence  map[int]precedence
+	levels      [][]parlex.Symbol
+	assocs      []parlex.Assoc
+	predicates  map[int][]parlex.Predicate
+	weights     map[int][]float64
+}
+
+type precedence struct {
+	level int
+	assoc parlex.Assoc
+}
+
+var assocStrs = map[string]parlex.Assoc{
+	"%left":     parlex.Left,
+	"%right":    parlex.Right,
+	"%nonassoc": parlex.NonAssoc,
 }
 
 // New Grammar. The productions string should have one rule per line. A rule
 // has the form "NonTerminal -> A B C" where A,B and C are symbols for either
 // terminals or non-terminals. If there are multiple productions for a non-
 // terminal, each row after the first can omit the non-terminal, as in "-> D E".
+//
+// Operator precedence can be declared with lines like "%left + -", "%right ^"
+// or "%nonassoc ==". Each declaration binds more tightly than the ones before
+// it.
+//
+// A production can end with a weight like "@3", used when sentences are
+// generated from the grammar; see Sample.
+//   E -> E + E @3
+//     -> int   @10
+//
+// Another grammar file can be imported with a line like `import "expr.grammar"`,
+// see Load. Files imported by New are relative to the working directory.
+//
+// An error in a line is a *parlex.DefinitionError with the line and the
+// reason.
 func New(productions string) (*Grammar, error) {
+	return newLoadOp().definition(productions, "")
+}
+
+// Compile is the same as New but does not stop at the first bad line. If there
+// are any errors, the returned error is a parlex.DefinitionErrors with a
+// *parlex.DefinitionError for each bad line.
+func Compile(productions string) (*Grammar, error) {
+	op := newLoadOp()
+	op.collect = true
+	g, _ := op.definition(productions, "")
+	if len(op.errs) > 0 {
+		return nil, op.errs
+	}
+	return g, nil
+}
+
+// definition reads the lines of a grammar. The file is empty if the grammar
+// was not read from a file, otherwise the files it imports are relative to it.
+// An error is a *parlex.DefinitionError; if the errors are being collected for
+// Compile, it is added to errs and reading continues.
+func (op *loadOp) definition(productions, file string) (*Grammar, error) {
 	g := &Grammar{
 		longest: -1,
 		set:     setsymbol.New(),
 	}
+	dir := ""
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+	lineErr := func(n int, line string, err error) error {
+		err = parlex.DefinitionErrorAt(file, n, line, err)
+		if !op.collect {
+			return err
+		}
+		op.errs = append(op.errs, err)
+		return nil
+	}
+	var imports []*importLine
 	cur := -1
-	for _, line := range strings.Split(productions, "\n") {
-		nt, prod, err := g.productionFromLine(line)
+	for i, line := range strings.Split(productions, "\n") {
+		if im, err := parseImport(line); err != nil {
+			if err = lineErr(i+1, line, err); err != nil {
+				return nil, err
+			}
+			continue
+		} else if im != nil {
+			im.line, im.text = i+1, line
+			imports = append(imports, im)
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 && strings.HasPrefix(fields[0], "%") {
+			assoc, ok := assocStrs[fields[0]]
+			if !ok {
+				if err := lineErr(i+1, line, ErrBadGrammar); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			symbols := make([]parlex.Symbol, len(fields)-1)
+			for i, f := range fields[1:] {
+				symbols[i] = g.set.Str(f)
+			}
+			g.AddPrecedence(assoc, symbols...)
+			continue
+		}
+		prodLine, weight, hasWeight := splitWeight(line)
+		nt, prod, err := g.productionFromLine(prodLine)
+		if err == nil && hasWeight && prod == nil {
+			err = ErrBadGrammar
+		}
 		if err != nil {
-			return nil, err
+			if err = lineErr(i+1, line, err); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		if prod == nil {
 			continue
@@ -101,6 +201,18 @@ func New(productions string) (*Grammar, error) {
 		} else {
 			prods.AddProductions(prod)
 		}
+		if hasWeight {
+			g.SetWeight(g.set.ByIdx(cur), prod, weight)
+		}
+	}
+	// imports are merged last so the first non-terminal of this grammar is
+	// the start symbol
+	for _, im := range imports {
+		if err := op.merge(g, im, dir); err != nil {
+			if err = lineErr(im.line, im.text, err); err != nil {
+				return nil, err
+			}
+		}
 	}
 	return g, nil
 }
@@ -120,7 +232,13 @@ func Empty() *Grammar {
 // not a non-terminal in the Grammar, nil is returned. It is part of the
 // parlex.Grammer interface.
 func (g *Grammar) Productions(symbol parlex.Symbol) parlex.Productions {
-	idx := g.set.Symbol(symbol).Idx()
+	// HasSymbol does not add to the set, so a Grammar can be read from many
+	// goroutines
+	sym := g.set.HasSymbol(symbol)
+	if sym == nil {
+		return nil
+	}
+	idx := sym.Idx()
 	if idx >= len(g.productions) {
 		return nil
 	}
@@ -143,6 +261,183 @@ func (g *Grammar) NonTerminals() []parlex.Symbol {
 	return r
 }
 
+// Start returns the start symbol, which is the first non-terminal. If there
+// are no non-terminals, nil is returned. It is part of the
+// parlex.IntrospectGrammar interface.
+func (g *Grammar) Start() parlex.Symbol {
+	if len(g.order) == 0 {
+		return nil
+	}
+	return g.set.ByIdx(g.order[0])
+}
+
+// IsTerminal returns true if the symbol is not a non-terminal in the Grammar.
+// It is part of the parlex.IntrospectGrammar interface.
+func (g *Grammar) IsTerminal(symbol parlex.Symbol) bool {
+	return g.Productions(symbol) == nil
+}
+
+// Terminals returns the terminals used in the productions in the order they
+// first appear. It is part of the parlex.IntrospectGrammar interface.
+func (g *Grammar) Terminals() []parlex.Symbol {
+	var terminals []parlex.Symbol
+	seen := make(map[int]bool)
+	for i := parlex.IterGrammar(g); i.Next(); {
+		for j := i.Iter(); j.Next(); {
+			if idx := g.set.Symbol(j.Symbol).Idx(); !seen[idx] && g.IsTerminal(j.Symbol) {
+				seen[idx] = true
+				terminals = append(terminals, j.Symbol)
+			}
+		}
+	}
+	return terminals
+}
+
+// AddPrecedence declares a new precedence level for the symbols. The level
+// binds more tightly than all the levels added before it.
+func (g *Grammar) AddPrecedence(assoc parlex.Assoc, symbols ...parlex.Symbol) {
+	if g.precedence == nil {
+		g.precedence = make(map[int]precedence)
+	}
+	level := len(g.levels) + 1
+	strs := make([]parlex.Symbol, len(symbols))
+	for i, s := range symbols {
+		ss := g.set.Symbol(s)
+		strs[i] = ss
+		g.precedence[ss.Idx()] = precedence{level, assoc}
+	}
+	g.levels = append(g.levels, strs)
+	g.assocs = append(g.assocs, assoc)
+}
+
+// Precedence returns the precedence level and associativity of a symbol. If
+// the symbol has no precedence, the level is 0. It fulfills
+// parlex.PrecedenceGrammar.
+func (g *Grammar) Precedence(symbol parlex.Symbol) (int, parlex.Assoc) {
+	s := g.set.HasSymbol(symbol)
+	if s == nil {
+		return 0, parlex.NonAssoc
+	}
+	p := g.precedence[s.Idx()]
+	return p.level, p.assoc
+}
+
+// AddPredicate attaches a predicate to a production that is already in the
+// grammar. The production is found by its symbols, if the non-terminal does not
+// have that production an error is returned.
+func (g *Grammar) AddPredicate(from parlex.Symbol, to parlex.Production, p parlex.Predicate) error {
+	if to == nil {
+		to = g.set.Production()
+	}
+	if prods := g.Productions(from); prods != nil {
+		for i := prods.Iter(); i.Next(); {
+			if !sameProduction(i.Production, to) {
+				continue
+			}
+			if g.predicates == nil {
+				g.predicates = make(map[int][]parlex.Predicate)
+			}
+			f := g.set.Symbol(from).Idx()
+			preds := g.predicates[f]
+			if len(preds) <= i.Idx {
+				preds = append(preds, make([]parlex.Predicate, 1+i.Idx-len(preds))...)
+			}
+			preds[i.Idx] = p
+			g.predicates[f] = preds
+			return nil
+		}
+	}
+	strs := make([]string, 0, to.Symbols())
+	for i := to.Iter(); i.Next(); {
+		strs = append(strs, i.String())
+	}
+	return fmt.Errorf("Unknown Production: %s -> %s", from, strings.Join(strs, " "))
+}
+
+func sameProduction(p1, p2 parlex.Production) bool {
+	if p1.Symbols() != p2.Symbols() {
+		return false
+	}
+	for i := p1.Iter(); i.Next(); {
+		if i.String() != p2.Symbol(i.Idx).String() {
+			return false
+		}
+	}
+	return true
+}
+
+// Predicate returns the predicate attached to a production of a non-terminal
+// or nil if there is not one. It fulfills parlex.PredicateGrammar.
+func (g *Grammar) Predicate(nonterminal parlex.Symbol, production int) parlex.Predicate {
+	s := g.set.HasSymbol(nonterminal)
+	if s == nil {
+		return nil
+	}
+	preds := g.predicates[s.Idx()]
+	if production < 0 || production >= len(preds) {
+		return nil
+	}
+	return preds[production]
+}
+
+var reWeight = regexp.MustCompile(`\s@(\d+(?:\.\d+)?)\s*$`)
+
+// splitWeight removes a weight like "@3" from the end of a line.
+func splitWeight(line string) (string, float64, bool) {
+	m := reWeight.FindStringSubmatchIndex(line)
+	if m == nil {
+		return line, 0, false
+	}
+	w, _ := strconv.ParseFloat(line[m[2]:m[3]], 64)
+	return line[:m[0]], w, true
+}
+
+// SetWeight sets the weight of a production that is already in the grammar.
+// The production is found by its symbols, if the non-terminal does not have
+// that production an error is returned.
+func (g *Grammar) SetWeight(from parlex.Symbol, to parlex.Production, weight float64) error {
+	if to == nil {
+		to = g.set.Production()
+	}
+	if prods := g.Productions(from); prods != nil {
+		for i := prods.Iter(); i.Next(); {
+			if !sameProduction(i.Production, to) {
+				continue
+			}
+			if g.weights == nil {
+				g.weights = make(map[int][]float64)
+			}
+			f := g.set.Symbol(from).Idx()
+			ws := g.weights[f]
+			for len(ws) <= i.Idx {
+				ws = append(ws, 1)
+			}
+			ws[i.Idx] = weight
+			g.weights[f] = ws
+			return nil
+		}
+	}
+	strs := make([]string, 0, to.Symbols())
+	for i := to.Iter(); i.Next(); {
+		strs = append(strs, i.String())
+	}
+	return fmt.Errorf("Unknown Production: %s -> %s", from, strings.Join(strs, " "))
+}
+
+// Weight returns the weight of a production of a non-terminal, which is 1 if
+// it was not set. It fulfills parlex.WeightedGrammar.
+func (g *Grammar) Weight(nonterminal parlex.Symbol, production int) float64 {
+	s := g.set.HasSymbol(nonterminal)
+	if s == nil {
+		return 1
+	}
+	ws := g.weights[s.Idx()]
+	if production < 0 || production >= len(ws) {
+		return 1
+	}
+	return ws[production]
+}
+
 // Add a production to the grammar.
 func (g *Grammar) Add(from parlex.Symbol, to parlex.Production) {
 	f := g.set.Symbol(from).Idx()
@@ -180,14 +475,31 @@ func (g *Grammar) String() string {
 	}
 
 	format := fmt.Sprintf("%%-%ds -> %%s", longest)
-	segs := make([]string, 0, totalCount)
+	segs := make([]string, 0, totalCount+len(g.levels))
+	for i, symbols := range g.levels {
+		strs := make([]string, len(symbols)+1)
+		for assocStr, assoc := range assocStrs {
+			if assoc == g.assocs[i] {
+				strs[0] = assocStr
+			}
+		}
+		for j, s := range symbols {
+			strs[j+1] = s.String()
+		}
+		segs = append(segs, strings.Join(strs, " "))
+	}
 	for _, nt := range nonTerminals {
 		prods := g.Productions(nt)
-		iter := prods.Iter()
-		iter.Next()
-		segs = append(segs, fmt.Sprintf(format, nt, iter.Production))
-		for iter.Next() {
-			segs = append(segs, fmt.Sprintf(format, "", iter.Production))
+		for iter := prods.Iter(); iter.Next(); {
+			from := ""
+			if iter.Idx == 0 {
+				from = nt.String()
+			}
+			seg := fmt.Sprintf(format, from, iter.Production)
+			if w := g.Weight(nt, iter.Idx); w != 1 {
+				seg += " @" + strconv.FormatFloat(w, 'f', -1, 64)
+			}
+			segs = append(segs, seg)
 		}
 	}
 	return strings.Join(segs, "\n")
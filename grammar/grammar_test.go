@@ -1,6 +1,8 @@
 package grammar
 
 import (
+	"errors"
+	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/symbol/stringsymbol"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -58,3 +60,152 @@ func TestBasic(t *testing.T) {
 	assert.Len(t, grmr.NonTerminals(), 1)
 	assert.NoError(t, err)
 }
+
+func TestPrecedence(t *testing.T) {
+	g, err := New(`
+    %left + -
+    %left * /
+    %right ^
+    E -> E + E
+      -> E ^ E
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		symbol string
+		level  int
+		assoc  parlex.Assoc
+	}{
+		{"+", 1, parlex.Left},
+		{"-", 1, parlex.Left},
+		{"/", 2, parlex.Left},
+		{"^", 3, parlex.Right},
+		{"int", 0, parlex.NonAssoc},
+		{"undefined", 0, parlex.NonAssoc},
+	}
+	for _, tc := range tests {
+		level, assoc := g.Precedence(stringsymbol.Symbol(tc.symbol))
+		assert.Equal(t, tc.level, level, tc.symbol)
+		assert.Equal(t, tc.assoc, assoc, tc.symbol)
+	}
+
+	prods := g.Productions(stringsymbol.Symbol("E"))
+	level, assoc := parlex.ProductionPrecedence(g, prods.Production(1))
+	assert.Equal(t, 3, level)
+	assert.Equal(t, parlex.Right, assoc)
+
+	g2, err := New(g.String())
+	assert.NoError(t, err)
+	assert.Equal(t, g.String(), g2.String())
+
+	_, err = New(`
+    %bad +
+    E -> int
+  `)
+	assert.True(t, errors.Is(err, ErrBadGrammar))
+	assert.Equal(t, "Bad Grammar at line 2 (%bad +)", err.Error())
+}
+
+func TestPredicate(t *testing.T) {
+	g, err := New(`
+    A -> x
+      -> x y
+      ->
+  `)
+	assert.NoError(t, err)
+
+	never := func([]parlex.ParseNode) bool { return false }
+	xy := stringsymbol.Production{x, stringsymbol.Symbol("y")}
+	assert.NoError(t, g.AddPredicate(A, xy, never))
+	assert.NoError(t, g.AddPredicate(A, nil, never))
+	assert.Nil(t, g.Predicate(A, 0))
+	assert.NotNil(t, g.Predicate(A, 1))
+	assert.NotNil(t, g.Predicate(A, 2))
+	assert.Nil(t, g.Predicate(A, 3))
+	assert.Nil(t, g.Predicate(x, 0))
+
+	err = g.AddPredicate(A, stringsymbol.Production{x, x}, never)
+	assert.Equal(t, "Unknown Production: A -> x x", err.Error())
+
+	var pg parlex.PredicateGrammar = g
+	assert.NotNil(t, pg)
+}
+
+func TestWeight(t *testing.T) {
+	g, err := New(`
+    A -> x @3
+      -> x y
+      -> @0.5
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, g.Productions(A).Productions())
+	assert.Equal(t, 3.0, g.Weight(A, 0))
+	assert.Equal(t, 1.0, g.Weight(A, 1))
+	assert.Equal(t, 0.5, g.Weight(A, 2))
+	assert.Equal(t, 1.0, g.Weight(A, 3))
+	assert.Equal(t, 1.0, g.Weight(x, 0))
+
+	xy := stringsymbol.Production{x, stringsymbol.Symbol("y")}
+	assert.NoError(t, g.SetWeight(A, xy, 2))
+	assert.Equal(t, 2.0, g.Weight(A, 1))
+	err = g.SetWeight(A, stringsymbol.Production{x, x}, 2)
+	assert.Equal(t, "Unknown Production: A -> x x", err.Error())
+
+	g2, err := New(g.String())
+	assert.NoError(t, err)
+	assert.Equal(t, g.String(), g2.String())
+	assert.Equal(t, 0.5, g2.Weight(A, 2))
+
+	_, err = New(`
+    A -> x
+      @3
+  `)
+	assert.True(t, errors.Is(err, ErrBadGrammar))
+
+	var wg parlex.WeightedGrammar = g
+	assert.NotNil(t, wg)
+}
+
+func TestIntrospect(t *testing.T) {
+	assert.Nil(t, Empty().Start())
+
+	g, err := New(`
+    E -> E op E
+      -> ( E )
+      -> int
+      -> NIL
+    NIL ->
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var ig parlex.IntrospectGrammar = g
+	assert.Equal(t, "E", ig.Start().String())
+	assert.True(t, ig.IsTerminal(stringsymbol.Symbol("op")))
+	assert.False(t, ig.IsTerminal(stringsymbol.Symbol("NIL")))
+	var terminals []string
+	for _, sym := range ig.Terminals() {
+		terminals = append(terminals, sym.String())
+	}
+	assert.Equal(t, []string{"op", "(", ")", "int"}, terminals)
+}
+
+func TestCompile(t *testing.T) {
+	_, err := Compile(`
+    E -> E + T -> x
+      -> T
+    %bad *
+    T -> int
+  `)
+	errs, ok := err.(parlex.DefinitionErrors)
+	if assert.True(t, ok) && assert.Len(t, errs, 2) {
+		assert.Equal(t, "Bad Grammar at line 2 (E -> E + T -> x)", errs[0].Error())
+		assert.Equal(t, 4, errs[1].(*parlex.DefinitionError).Line)
+	}
+
+	g, err := Compile("E -> int")
+	assert.NoError(t, err)
+	assert.Equal(t, "E", g.Start().String())
+}
+
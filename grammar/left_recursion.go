@@ -2,7 +2,10 @@ package grammar
 
 import (
 	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
 	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
 )
 
 type lrOp struct {
@@ -12,23 +15,96 @@ type lrOp struct {
 	cur       *setsymbol.Symbol
 	hasDirect bool
 	set       *setsymbol.Set
+	// templates holds a template for each production in out, in the same order
+	templates map[int][]*template
+	helpers   map[int]bool
+}
+
+// template describes how to rebuild part of the original tree from the
+// children of a node in the tree parsed with the new grammar. A template is
+// either a slot, which is one of the children, a node of the original grammar
+// or a fold, which rebuilds the left recursion from a chain of helper nodes.
+type template struct {
+	slot  int
+	kind  string
+	c     []*template
+	chain *template
+}
+
+func slot(i int) *template { return &template{slot: i} }
+
+func identity(kind string, ln int) *template {
+	t := &template{kind: kind, slot: -1}
+	for i := 0; i < ln; i++ {
+		t.c = append(t.c, slot(i))
+	}
+	return t
+}
+
+// isIdentity is true if the template rebuilds a node with the children
+// unchanged.
+func (t *template) isIdentity(kind string) bool {
+	if t.kind != kind || t.chain != nil {
+		return false
+	}
+	for i, c := range t.c {
+		if c.slot != i {
+			return false
+		}
+	}
+	return true
+}
+
+// substitute replaces slot 0 with lead, which has ln slots of it's own. The
+// other slots are shifted to follow lead.
+func (t *template) substitute(lead *template, ln int) *template {
+	if t.slot == 0 {
+		return lead
+	}
+	if t.slot > 0 {
+		return slot(t.slot + ln - 1)
+	}
+	cp := &template{
+		slot: -1,
+		kind: t.kind,
+		c:    make([]*template, len(t.c)),
+	}
+	for i, c := range t.c {
+		cp.c[i] = c.substitute(lead, ln)
+	}
+	if t.chain != nil {
+		cp.chain = t.chain.substitute(lead, ln)
+	}
+	return cp
 }
 
 // RemoveLeftRecursion will convert a grammar with left recursion into one
-// without.
-func RemoveLeftRecursion(grammar parlex.Grammar) *Grammar {
+// without. Left recursion is replaced with right recursion using helper
+// non-terminals, so
+//   E -> E op E
+//     -> int
+// becomes
+//   E  -> int E'
+//   E' -> op E E'
+//      ->
+// The reducer will restore trees parsed with the new grammar to the shape they
+// would have with the original grammar, removing the helper non-terminals.
+func RemoveLeftRecursion(grammar parlex.Grammar) (*Grammar, tree.Reducer) {
 	nts := grammar.NonTerminals()
 	op := &lrOp{
-		in:   grammar,
-		out:  Empty(),
-		done: make([]bool, len(nts)),
-		set:  setsymbol.New(),
+		in:        grammar,
+		out:       Empty(),
+		done:      make([]bool, len(nts)),
+		set:       setsymbol.New(),
+		templates: make(map[int][]*template),
+		helpers:   make(map[int]bool),
 	}
 	for _, s := range nts {
 		op.cur = op.set.Symbol(s)
 		op.hasDirect = false
 		for i := grammar.Productions(op.cur).Iter(); i.Next(); {
-			op.safeAdd(op.set.CastProduction(i.Production))
+			prod := op.set.CastProduction(i.Production)
+			op.safeAdd(prod, identity(op.cur.String(), prod.Symbols()))
 		}
 		if op.hasDirect {
 			op.removeDirectLeftRecursion()
@@ -39,10 +115,10 @@ func RemoveLeftRecursion(grammar parlex.Grammar) *Grammar {
 		}
 		op.done[op.cur.Idx()] = true
 	}
-	return op.out
+	return op.out, op.reducer()
 }
 
-func (op *lrOp) safeAdd(prod *setsymbol.Production) {
+func (op *lrOp) safeAdd(prod *setsymbol.Production, t *template) {
 	var first *setsymbol.Symbol
 	if prod.Symbols() > 0 {
 		first = prod.Symbol(0).(*setsymbol.Symbol)
@@ -53,10 +129,14 @@ func (op *lrOp) safeAdd(prod *setsymbol.Production) {
 	if first == nil || first.Idx() >= len(op.done) || !op.done[first.Idx()] {
 		op.hasDirect = op.hasDirect || (first != nil && first.Idx() == op.cur.Idx())
 		op.out.Add(op.cur, prod)
+		op.templates[op.cur.Idx()] = append(op.templates[op.cur.Idx()], t)
 		return
 	}
 
-	for i := op.in.Productions(first).Iter(); i.Next(); {
+	// The productions of a symbol that has been processed no longer contain
+	// left recursion, so they can be substituted for the first symbol.
+	leadTemplates := op.templates[first.Idx()]
+	for i := op.out.productions[first.Idx()].Iter(); i.Next(); {
 		newProd := op.set.Production()
 		for lead := i.Production.Iter(); lead.Next(); {
 			newProd.AddSymbols(lead.Symbol)
@@ -64,7 +144,7 @@ func (op *lrOp) safeAdd(prod *setsymbol.Production) {
 		for tail := op.getTail(prod).Iter(); tail.Next(); {
 			newProd.AddSymbols(tail.Symbol)
 		}
-		op.safeAdd(newProd)
+		op.safeAdd(newProd, t.substitute(leadTemplates[i.Idx], i.Symbols()))
 	}
 }
 
@@ -83,28 +163,39 @@ func (op *lrOp) removeDirectLeftRecursion() {
 	if nsIdx >= len(op.out.productions) || op.out.productions[nsIdx] == nil {
 		op.out.order = append(op.out.order, nsIdx)
 	}
+	op.helpers[nsIdx] = true
 
 	prods := op.out.productions[op.cur.Idx()]
+	templates := op.templates[op.cur.Idx()]
 	op.out.productions[op.cur.Idx()] = nil
+	op.templates[op.cur.Idx()] = nil
 	for i := prods.Iter(); i.Next(); {
 		prod := i.Production.(*setsymbol.Production)
+		t := templates[i.Idx]
 		if prod.Symbols() == 0 || prod.Symbol(0).(*setsymbol.Symbol).Idx() != op.cur.Idx() {
+			// the helper chain is folded onto the node built from prod
+			fold := &template{
+				slot:  -1,
+				kind:  op.cur.String(),
+				c:     []*template{t},
+				chain: slot(prod.Symbols()),
+			}
 			prod.AddSymbols(newSym)
-			op.directAdd(op.cur, prod)
+			op.directAdd(op.cur, prod, fold)
 		} else {
-			for prod.Symbols() > 0 && prod.Symbol(0).(*setsymbol.Symbol).Idx() == op.cur.Idx() {
-				prod = op.getTail(prod)
-			}
+			// in the template, slot 0 will be the node built so far, which
+			// lines up with the children of the helper
+			prod = op.getTail(prod)
 			if prod.Symbols() > 0 {
 				prod.AddSymbols(newSym)
-				op.directAdd(newSym, prod)
+				op.directAdd(newSym, prod, t)
 			}
 		}
 	}
-	op.directAdd(newSym, op.set.Production())
+	op.directAdd(newSym, op.set.Production(), nil)
 }
 
-func (op *lrOp) directAdd(from *setsymbol.Symbol, to *setsymbol.Production) {
+func (op *lrOp) directAdd(from *setsymbol.Symbol, to *setsymbol.Production, t *template) {
 	f := from.Idx()
 	var prods *setsymbol.Productions
 	if f < len(op.out.productions) {
@@ -118,4 +209,111 @@ func (op *lrOp) directAdd(from *setsymbol.Symbol, to *setsymbol.Production) {
 	} else {
 		prods.AddProductions(to)
 	}
+	op.templates[f] = append(op.templates[f], t)
+}
+
+// lrProd is a production of the new grammar and the template to restore it.
+type lrProd struct {
+	symbols []string
+	t       *template
+}
+
+type lrReducer map[string][]lrProd
+
+// reducer creates a reduction for every non-terminal with a production that
+// does not match the original grammar.
+func (op *lrOp) reducer() tree.Reducer {
+	lr := make(lrReducer)
+	needed := make(map[string]bool)
+	for nt, templates := range op.templates {
+		kind := op.set.ByIdx(nt).String()
+		prods := op.out.productions[nt]
+		for i := prods.Iter(); i.Next(); {
+			t := templates[i.Idx]
+			symbols := make([]string, i.Symbols())
+			for j := i.Iter(); j.Next(); {
+				symbols[j.Idx] = j.Symbol.String()
+			}
+			lr[kind] = append(lr[kind], lrProd{symbols, t})
+			if !op.helpers[nt] && !t.isIdentity(kind) {
+				needed[kind] = true
+			}
+		}
+	}
+
+	r := tree.Reducer{}
+	for kind := range needed {
+		r[kind] = lr.restore
+	}
+	return r
+}
+
+// find the template for the production that produced a node.
+func (lr lrReducer) find(node *tree.PN) (*template, bool) {
+	kind := node.Kind().String()
+	for _, p := range lr[kind] {
+		if len(p.symbols) != len(node.C) {
+			continue
+		}
+		match := true
+		for i, s := range p.symbols {
+			if node.C[i].Kind().String() != s {
+				match = false
+				break
+			}
+		}
+		if match {
+			return p.t, true
+		}
+	}
+	return nil, false
+}
+
+func (lr lrReducer) restore(node *tree.PN) {
+	t, ok := lr.find(node)
+	if !ok {
+		return
+	}
+	restored := lr.build(t, node.C)
+	node.C = restored.C
+	for _, c := range node.C {
+		c.P = node
+	}
+}
+
+// build a node from a template using the slots.
+func (lr lrReducer) build(t *template, slots []*tree.PN) *tree.PN {
+	if t.slot >= 0 {
+		return slots[t.slot]
+	}
+	if t.chain != nil {
+		acc := lr.build(t.c[0], slots)
+		for chain := lr.build(t.chain, slots); len(chain.C) > 0; chain = chain.C[len(chain.C)-1] {
+			ct, ok := lr.find(chain)
+			if !ok || ct == nil {
+				break
+			}
+			// slot 0 is the node built so far, followed by the children of the
+			// helper except the last, which continues the chain
+			chainSlots := append([]*tree.PN{acc}, chain.C[:len(chain.C)-1]...)
+			acc = lr.build(ct, chainSlots)
+		}
+		return acc
+	}
+	pn := &tree.PN{
+		Lexeme: lexeme.New(stringsymbol.Symbol(t.kind)),
+		C:      make([]*tree.PN, len(t.c)),
+	}
+	for i, c := range t.c {
+		pn.C[i] = lr.build(c, slots)
+		pn.C[i].P = pn
+	}
+	if len(pn.C) > 0 {
+		if l, c := pn.C[0].Pos(); l >= 0 {
+			pn.Lexeme.(*lexeme.Lexeme).At(l, c)
+		}
+	}
+	pn.UpdateSpan()
+	pn.Lexeme.(*lexeme.Lexeme).AtOffset(pn.Offset())
+	return pn
 }
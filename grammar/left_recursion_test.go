@@ -1,6 +1,10 @@
 package grammar
 
 import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -14,7 +18,7 @@ func TestRemoveLeftRecursion(t *testing.T) {
   `)
 	assert.Len(t, grmr.NonTerminals(), 1)
 	assert.NoError(t, err)
-	noRecur := RemoveLeftRecursion(grmr)
+	noRecur, _ := RemoveLeftRecursion(grmr)
 	expected, err := New(`
     E  -> ( E ) E'
        -> int E'
@@ -32,7 +36,7 @@ func TestRemoveLeftRecursion(t *testing.T) {
       -> y
   `)
 	assert.NoError(t, err)
-	noRecur = RemoveLeftRecursion(grmr)
+	noRecur, _ = RemoveLeftRecursion(grmr)
 	expected, err = New(`
     A  -> B C
     B  -> x
@@ -44,3 +48,58 @@ func TestRemoveLeftRecursion(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected.String(), noRecur.String())
 }
+
+func TestRemoveLeftRecursionReducer(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    int /\d+/
+    op  /[+\-]/
+    *   /\*/
+    (   /\(/
+    )   /\)/
+    ,   /,/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		grammar string
+		input   string
+	}{
+		{
+			grammar: `
+        E -> E op T
+          -> T
+        T -> T * F
+          -> F
+        F -> ( E )
+          -> int
+      `,
+			input: "1 + 2 * 3 * (4 - 5) - 6",
+		},
+		{
+			// indirect left recursion
+			grammar: `
+        L -> I , int
+          -> int
+        I -> L
+      `,
+			input: "1, 2, 3, 4",
+		},
+	}
+
+	for _, tc := range tests {
+		grmr, err := New(tc.grammar)
+		assert.NoError(t, err)
+		expected := packrat.New(grmr).Parse(lxr.Lex(tc.input))
+		if !assert.NotNil(t, expected) {
+			continue
+		}
+
+		noRecur, rdcr := RemoveLeftRecursion(grmr)
+		assert.False(t, parlex.IsLeftRecursive(noRecur))
+		pn := packrat.New(noRecur).Parse(lxr.Lex(tc.input))
+		if assert.NotNil(t, pn, tc.input) {
+			assert.Equal(t, expected.(*tree.PN).String(), rdcr.RawReduce(pn).String())
+		}
+	}
+}
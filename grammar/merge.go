@@ -0,0 +1,264 @@
+package grammar
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Merge returns a grammar with the productions of both grammars. The start
+// symbol is the start symbol of a, or of b if a is empty. A non-terminal that
+// both grammars define is an error, unless they give it the same productions
+// or it is listed in conflicts; then the productions from b that a does not
+// have are added after the ones from a.
+//
+// Precedence and predicates are kept if the grammars are *Grammars. The levels
+// of b come after, and bind more tightly than, the levels of a. A symbol given
+// a precedence by both is an error.
+func Merge(a, b parlex.Grammar, conflicts ...string) (*Grammar, error) {
+	combine := make(map[string]bool, len(conflicts))
+	for _, c := range conflicts {
+		combine[c] = true
+	}
+	g := Empty()
+	if err := g.merge(a, nil, nil); err != nil {
+		return nil, err
+	}
+	if err := g.merge(b, nil, combine); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// merge adds the productions of src to the grammar. The non-terminals of src
+// are renamed by rename if it is not nil. If src defines a non-terminal that
+// the grammar already has, its productions are combined if they are the same
+// or the non-terminal is in combine. The grammar is not changed if there is an
+// error.
+func (g *Grammar) merge(src parlex.Grammar, rename map[string]string, combine map[string]bool) error {
+	nts := src.NonTerminals()
+	names := make(map[string]string, len(nts))
+	for _, nt := range nts {
+		name := nt.String()
+		if r, ok := rename[name]; ok {
+			name = r
+		}
+		names[nt.String()] = name
+	}
+	symbol := func(s parlex.Symbol) parlex.Symbol {
+		if name, ok := names[s.String()]; ok {
+			return g.set.Str(name)
+		}
+		return g.set.Symbol(s)
+	}
+	symbols := func(p parlex.Production) []string {
+		strs := make([]string, 0, p.Symbols())
+		for i := p.Iter(); i.Next(); {
+			strs = append(strs, symbol(i.Symbol).String())
+		}
+		return strs
+	}
+
+	existing := make(map[string]bool)
+	for _, nt := range nts {
+		name := names[nt.String()]
+		have := g.Productions(g.set.Str(name))
+		if have == nil {
+			continue
+		}
+		existing[name] = true
+		if combine[name] {
+			continue
+		}
+		same := have.Productions() == src.Productions(nt).Productions()
+		for i := src.Productions(nt).Iter(); same && i.Next(); {
+			same = g.hasProduction(have, symbols(i.Production))
+		}
+		if !same {
+			return fmt.Errorf("Duplicate Non-Terminal: %s", name)
+		}
+	}
+	var levels [][]parlex.Symbol
+	var assocs []parlex.Assoc
+	if sg, ok := src.(*Grammar); ok {
+		for i, level := range sg.levels {
+			if g.hasLevel(level, sg.assocs[i]) {
+				continue
+			}
+			for _, s := range level {
+				if l, _ := g.Precedence(s); l > 0 {
+					return fmt.Errorf("Duplicate Precedence: %s", s)
+				}
+			}
+			levels = append(levels, level)
+			assocs = append(assocs, sg.assocs[i])
+		}
+	}
+
+	preds, _ := src.(parlex.PredicateGrammar)
+	weights, _ := src.(parlex.WeightedGrammar)
+	for _, nt := range nts {
+		from := symbol(nt)
+		for i := src.Productions(nt).Iter(); i.Next(); {
+			strs := symbols(i.Production)
+			if existing[from.String()] && g.hasProduction(g.Productions(from), strs) {
+				continue
+			}
+			to := g.set.Production()
+			for _, s := range strs {
+				to.AddSymbols(g.set.Str(s))
+			}
+			g.Add(from, to)
+			if preds != nil {
+				if p := preds.Predicate(nt, i.Idx); p != nil {
+					g.AddPredicate(from, to, p)
+				}
+			}
+			if weights != nil {
+				if w := weights.Weight(nt, i.Idx); w != 1 {
+					g.SetWeight(from, to, w)
+				}
+			}
+		}
+	}
+	for i, level := range levels {
+		g.AddPrecedence(assocs[i], level...)
+	}
+	return nil
+}
+
+// hasLevel checks if the grammar already has a precedence level with exactly
+// these symbols, as happens when a file is imported twice.
+func (g *Grammar) hasLevel(level []parlex.Symbol, assoc parlex.Assoc) bool {
+	if len(level) == 0 {
+		return false
+	}
+	l, a := g.Precedence(level[0])
+	if l == 0 || a != assoc || len(g.levels[l-1]) != len(level) {
+		return false
+	}
+	for _, s := range level[1:] {
+		if sl, _ := g.Precedence(s); sl != l {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Grammar) hasProduction(prods parlex.Productions, symbols []string) bool {
+	for i := prods.Iter(); i.Next(); {
+		if i.Symbols() != len(symbols) {
+			continue
+		}
+		same := true
+		for j := i.Iter(); same && j.Next(); {
+			same = j.String() == symbols[j.Idx]
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads a grammar from a file. A grammar can import the rules of other
+// files, which are found relative to the file that imports them:
+//
+//   import "expr.grammar"
+//   import "expr.grammar" as expr
+//   import "expr.grammar" E T -> Expr Term
+//
+// The first form adds the rules as they are. The second puts the
+// non-terminals of the file in a namespace, so E becomes expr.E, and the third
+// renames the listed non-terminals. Terminals are never renamed. The imported
+// rules are merged as Merge does, so a file imported twice is only added once.
+func Load(filename string) (*Grammar, error) {
+	return newLoadOp().load(filename, "")
+}
+
+// loadOp tracks the files being imported to catch a cycle. If collect is true,
+// errors are added to errs instead of stopping.
+type loadOp struct {
+	importing map[string]bool
+	collect   bool
+	errs      parlex.DefinitionErrors
+}
+
+func newLoadOp() *loadOp {
+	return &loadOp{
+		importing: make(map[string]bool),
+	}
+}
+
+func (op *loadOp) load(filename, dir string) (*Grammar, error) {
+	if dir != "" && !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
+	filename = filepath.Clean(filename)
+	if op.importing[filename] {
+		return nil, fmt.Errorf("Import Cycle: %s", filename)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	op.importing[filename] = true
+	g, err := op.definition(string(b), filename)
+	delete(op.importing, filename)
+	return g, err
+}
+
+// importLine is an import and the line of the definition it is on.
+type importLine struct {
+	file      string
+	namespace string
+	from, to  []string
+	line      int
+	text      string
+}
+
+var importStr = regexp.MustCompile(`^\s*import\s+"([^"]+)"(?:\s+as\s+(\S+)|((?:\s+[^\s>-]\S*)+)\s*->((?:\s+\S+)+))?\s*$`)
+
+// parseImport returns nil if the line is not an import.
+func parseImport(line string) (*importLine, error) {
+	if fields := strings.Fields(line); len(fields) == 0 || fields[0] != "import" {
+		return nil, nil
+	}
+	m := importStr.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("Bad Import: expected a quoted file name, then as and a name or a rename")
+	}
+	im := &importLine{
+		file:      m[1],
+		namespace: m[2],
+		from:      strings.Fields(m[3]),
+		to:        strings.Fields(m[4]),
+	}
+	if len(im.from) != len(im.to) {
+		return nil, fmt.Errorf("Bad Import: %d non-terminals renamed to %d names", len(im.from), len(im.to))
+	}
+	return im, nil
+}
+
+func (op *loadOp) merge(g *Grammar, im *importLine, dir string) error {
+	src, err := op.load(im.file, dir)
+	if err != nil {
+		return err
+	}
+	rename := make(map[string]string)
+	if im.namespace != "" {
+		for _, nt := range src.NonTerminals() {
+			rename[nt.String()] = im.namespace + "." + nt.String()
+		}
+	}
+	for i, from := range im.from {
+		if src.Productions(src.set.Str(from)) == nil {
+			return fmt.Errorf("Bad Import: %s is not a non-terminal in %s", from, im.file)
+		}
+		rename[from] = im.to[i]
+	}
+	return g.merge(src, rename, nil)
+}
@@ -0,0 +1,189 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	a, err := New(`
+    S -> E semi
+    E -> E + T
+      -> T
+    %left +
+  `)
+	assert.NoError(t, err)
+	b, err := New(`
+    T -> T * F
+      -> F
+    F -> int
+      -> ( E )
+    %left *
+  `)
+	assert.NoError(t, err)
+
+	g, err := Merge(a, b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "S", g.Start().String())
+	assert.Equal(t, []string{"S", "E", "T", "F"}, symbolStrs(g.NonTerminals()))
+	plus, _ := g.Precedence(stringsymbol.Symbol("+"))
+	mul, _ := g.Precedence(stringsymbol.Symbol("*"))
+	assert.Equal(t, 1, plus)
+	assert.Equal(t, 2, mul)
+
+	// the same productions are not a conflict
+	again, err := Merge(g, a)
+	if assert.NoError(t, err) {
+		assert.Equal(t, g.String(), again.String())
+	}
+
+	c, err := New(`
+    F -> id
+    %left +
+  `)
+	assert.NoError(t, err)
+	_, err = Merge(g, c)
+	assert.Equal(t, "Duplicate Non-Terminal: F", err.Error())
+
+	g, err = Merge(g, c, "F")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, g.Productions(stringsymbol.Symbol("F")).Productions())
+	}
+
+	d, err := New(`
+    X -> x
+    %right +
+  `)
+	assert.NoError(t, err)
+	_, err = Merge(a, d)
+	assert.Equal(t, "Duplicate Precedence: +", err.Error())
+}
+
+func TestMergePredicate(t *testing.T) {
+	a, err := New(`A -> B`)
+	assert.NoError(t, err)
+	b, err := New(`
+    B -> x
+      -> y
+  `)
+	assert.NoError(t, err)
+	pred := func([]parlex.ParseNode) bool { return true }
+	assert.NoError(t, b.AddPredicate(stringsymbol.Symbol("B"), b.set.Production(stringsymbol.Symbol("y")), pred))
+
+	g, err := Merge(a, b)
+	assert.NoError(t, err)
+	assert.Nil(t, g.Predicate(stringsymbol.Symbol("B"), 0))
+	assert.NotNil(t, g.Predicate(stringsymbol.Symbol("B"), 1))
+}
+
+func TestMergeWeight(t *testing.T) {
+	a, err := New(`A -> B`)
+	assert.NoError(t, err)
+	b, err := New(`
+    B -> x
+      -> y @4
+  `)
+	assert.NoError(t, err)
+
+	g, err := Merge(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, g.Weight(stringsymbol.Symbol("B"), 0))
+	assert.Equal(t, 4.0, g.Weight(stringsymbol.Symbol("B"), 1))
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grammar")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	write := func(name, src string) string {
+		name = filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(name, []byte(src), 0644))
+		return name
+	}
+	write("expr.grammar", `
+    E -> E + T
+      -> T
+    T -> int
+      -> ( E )
+    %left +
+  `)
+	write("sub/value.grammar", `
+    import "../expr.grammar"
+    V -> E
+      -> str
+  `)
+
+	g, err := Load(write("plain.grammar", `
+    S -> E semi
+    import "expr.grammar"
+  `))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"S", "E", "T"}, symbolStrs(g.NonTerminals()))
+		l, _ := g.Precedence(stringsymbol.Symbol("+"))
+		assert.Equal(t, 1, l)
+	}
+
+	g, err = Load(write("ns.grammar", `
+    S -> expr.E semi
+    import "expr.grammar" as expr
+  `))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"S", "expr.E", "expr.T"}, symbolStrs(g.NonTerminals()))
+		assert.Equal(t, "expr.E", g.Productions(stringsymbol.Symbol("expr.E")).Production(0).Symbol(0).String())
+	}
+
+	g, err = Load(write("rename.grammar", `
+    S -> Expr semi
+    import "expr.grammar" E T -> Expr Term
+  `))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"S", "Expr", "Term"}, symbolStrs(g.NonTerminals()))
+	}
+
+	// expr.grammar is reached twice
+	g, err = Load(write("diamond.grammar", `
+    S -> V semi
+    import "sub/value.grammar"
+    import "expr.grammar"
+  `))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"S", "V", "E", "T"}, symbolStrs(g.NonTerminals()))
+		assert.Equal(t, 2, g.Productions(stringsymbol.Symbol("E")).Productions())
+	}
+
+	write("a.grammar", "A -> B\nimport \"b.grammar\"")
+	write("b.grammar", "B -> A\nimport \"a.grammar\"")
+	_, err = Load(filepath.Join(dir, "a.grammar"))
+	assert.Equal(t, "Import Cycle: "+filepath.Join(dir, "a.grammar")+" at "+filepath.Join(dir, "b.grammar")+` line 2 (import "a.grammar")`, err.Error())
+
+	tests := map[string]string{
+		`import "expr.grammar" E T -> Expr`:        "Bad Import: 2 non-terminals renamed to 1 names",
+		`import "expr.grammar" X -> Y`:             "Bad Import: X is not a non-terminal in expr.grammar",
+		`import expr.grammar`:                      "Bad Import: expected a quoted file name, then as and a name or a rename",
+		"S -> E\nimport \"expr.grammar\"\nE -> id": "Duplicate Non-Terminal: E",
+	}
+	for src, expected := range tests {
+		_, err := Load(write("bad.grammar", src))
+		if de, ok := err.(*parlex.DefinitionError); assert.True(t, ok, src) {
+			assert.Equal(t, expected, de.Err.Error(), src)
+		}
+	}
+}
+
+func symbolStrs(symbols []parlex.Symbol) []string {
+	strs := make([]string, len(symbols))
+	for i, s := range symbols {
+		strs[i] = s.String()
+	}
+	return strs
+}
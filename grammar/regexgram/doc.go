@@ -8,7 +8,24 @@
 //
 // A symbol or group is marked as repeating using *
 //
+// A symbol or group is marked as repeating at least once using +
+//
+// A symbol or group can be repeated a bounded number of times with {n}, {n,m}
+// or {n,} for at least n.
+//
+// A separated list is written as A % sep, which is the same as A (sep A)*
+//
+// A template is a non-terminal with parameters, like List<X, Sep> -> X (Sep X)*.
+// Each use, like List<Arg, comma>, adds a copy of its productions with the
+// parameters replaced by the arguments. An argument can be any sequence of
+// symbols, including another template.
+//
+// Symbols that are not words, like punctuation, can be quoted as in ","
+//
 // A set of symbols or groups can be OR'd together with |
 //
+// Operators that require helper non-terminals, like * and +, add entries to
+// the returned reducer that flatten the helper nodes into their parent.
+//
 // The grammar also allows for full comments with //
 package regexgram
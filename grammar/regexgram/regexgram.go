@@ -1,6 +1,7 @@
 package regexgram
 
 import (
+	"fmt"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/grammar"
 	"github.com/adamcolton/parlex/lexeme"
@@ -8,16 +9,23 @@ import (
 	"github.com/adamcolton/parlex/parser/packrat"
 	"github.com/adamcolton/parlex/symbol/setsymbol"
 	"github.com/adamcolton/parlex/tree"
+	"strconv"
 	"strings"
 )
 
 const lexerProductions = `
   rarr     /->/
-  symbol   /\w+/
+  symbol   /\w+|"[^"\s]+"/
   repeats  /\*/
+  plus     /\+/
+  count    /\{\d+(?:,\d*)?\}/
+  sep      /%/
   optional /\?/
   or       /\|/
   (        /\(/
+  lt       /</
+  gt       />/
+  comma    /,/
   )        /\)/
   comment  /[\n\r]\s*\/\/[^\n\r]*/ -
   nl       /[\n\r]\s*/
@@ -26,34 +34,63 @@ const lexerProductions = `
 
 const grammarProductions = `
   Grammar      -> NL Production Productions NL 
+               -> NL Template Productions NL
   Productions  -> Production Productions
+               -> Template Productions
                -> ContinueProd Productions
                -> 
   Production   -> nl symbol rarr Symbols
+  Template     -> nl symbol lt Params gt rarr Symbols
+  Params       -> symbol MoreParams
+  MoreParams   -> comma symbol MoreParams
+               ->
   ContinueProd -> nl rarr Symbols
   Symbols      -> Symbol Symbols
                ->
-  Symbol       -> Group
+  Symbol       -> Atom
                -> OrSymbol
+               -> SepSymbol
                -> RepSymbol
+               -> PlusSymbol
+               -> CountSymbol
                -> OptSymbol
                -> symbol
+  Atom         -> Group
+               -> Instance
   Group        -> ( Symbols )
-  OptSymbol    -> Group optional
+  Instance     -> symbol lt Args gt
+  Args         -> Arg MoreArgs
+  MoreArgs     -> comma Arg MoreArgs
+               ->
+  Arg          -> Symbol Symbols
+  Unit         -> Atom
+               -> symbol
+  OptSymbol    -> Atom optional
                -> symbol optional
-  RepSymbol    -> Group repeats
+  RepSymbol    -> Atom repeats
                -> symbol repeats
-  OrSymbol     -> Group or MoreOr
+  PlusSymbol   -> Atom plus
+               -> symbol plus
+  CountSymbol  -> Atom count
+               -> symbol count
+  SepSymbol    -> Unit sep Unit
+  OrSymbol     -> Atom or MoreOr
                -> OptSymbol or MoreOr
                -> RepSymbol or MoreOr
+               -> PlusSymbol or MoreOr
+               -> CountSymbol or MoreOr
                -> symbol or MoreOr
-  MoreOr       -> Group or MoreOr
+  MoreOr       -> Atom or MoreOr
                -> OptSymbol or MoreOr
                -> RepSymbol or MoreOr
+               -> PlusSymbol or MoreOr
+               -> CountSymbol or MoreOr
                -> symbol or MoreOr
-               -> Group
+               -> Atom
                -> OptSymbol
                -> RepSymbol
+               -> PlusSymbol
+               -> CountSymbol
                -> symbol
   NL           -> nl
                ->
@@ -78,6 +115,15 @@ var rdcr = tree.Reducer{
 		RemoveChildren(0, 1). // remove new-line and rarr
 		PromoteChildValue(0). // promote the non-terminal to be the production value
 		PromoteChildrenOf(0), // replace Symbols with it's children
+	"Template": tree.
+		RemoveChildren(0, 1, 2, 2). // remove new-line, lt, gt and rarr
+		PromoteChildValue(0).       // promote the template name to be the value
+		PromoteChildrenOf(1),       // replace Symbols with it's children
+	"Params": tree.
+		PromoteChildrenOf(-1), // promote the rest of the params
+	"MoreParams": tree.
+		RemoveChild(0).        // remove comma
+		PromoteChildrenOf(-1), // promote the rest of the params
 	"ContinueProd": tree.
 		RemoveChildren(0, 0). // remove new-line and rarr
 		PromoteChildrenOf(0), // replace Symbols with it's children
@@ -88,6 +134,25 @@ var rdcr = tree.Reducer{
 		RemoveChild(-1), // Remove ?
 	"RepSymbol": tree.
 		RemoveChild(-1), // Remove *
+	"PlusSymbol": tree.
+		RemoveChild(-1), // Remove +
+	"CountSymbol": tree.
+		PromoteChildValue(-1), // The count, like {2,5}, becomes the value
+	"SepSymbol": tree.
+		RemoveChild(1), // Remove %
+	"Unit": tree.PromoteSingleChild,
+	"Atom": tree.PromoteSingleChild,
+	"Instance": tree.
+		PromoteChildValue(0).  // promote the template name to be the value
+		RemoveChildren(0, -1). // remove lt and gt
+		PromoteChildrenOf(0),  // replace Args with the Arg nodes
+	"Args": tree.
+		PromoteChildrenOf(-1), // promote the rest of the args
+	"MoreArgs": tree.
+		RemoveChild(0).        // remove comma
+		PromoteChildrenOf(-1), // promote the rest of the args
+	"Arg": tree.
+		PromoteChildrenOf(-1), // replace Symbols with it's children
 	"OrSymbol": tree.
 		RemoveChild(1).       // Remove |
 		PromoteChildrenOf(1), // promote the rest of the or condition
@@ -107,6 +172,7 @@ var prsr = packrat.New(grmr)
 var runner = parlex.New(lxr, prsr, rdcr)
 
 type evalOp struct {
+	err       error
 	grammar   *grammar.Grammar
 	set       *setsymbol.Set
 	rdcr      tree.Reducer
@@ -114,36 +180,75 @@ type evalOp struct {
 	nonterm   string
 	bludgeons map[string][]string
 	done      map[string]rules
+	templates map[string]*template
+	literals  []string
+}
+
+// template is a non-terminal with parameters, like List<X, Sep>. Each body is
+// a production node with the symbols of one of its productions.
+type template struct {
+	params []string
+	bodies []*tree.PN
 }
 
-func evalGrammar(node *tree.PN) (*grammar.Grammar, tree.Reducer) {
+// maxInstanceName limits the length of the name of a template instance. A
+// template that uses itself with growing arguments would otherwise expand
+// forever, each instance having a longer name than the last.
+const maxInstanceName = 1000
+
+func evalGrammar(node *tree.PN) (*evalOp, error) {
 	op := &evalOp{
 		grammar:   grammar.Empty(),
 		set:       setsymbol.New(),
 		rdcr:      tree.Reducer{},
 		bludgeons: make(map[string][]string),
 		done:      make(map[string]rules),
+		templates: make(map[string]*template),
 	}
+	var tmpl *template
 	for _, c := range node.C {
-		if c.Kind().String() == "Production" {
-			op.nonterm = c.Value()
-		} else {
-			c.Lexeme.(*lexeme.Lexeme).V = op.nonterm
+		switch c.Kind().String() {
+		case "Template":
+			name := symbolName(c)
+			if _, ok := op.templates[name]; ok {
+				return nil, fmt.Errorf("Duplicate Template: %s", name)
+			}
+			tmpl = &template{}
+			for _, p := range c.C[0].C {
+				tmpl.params = append(tmpl.params, symbolName(p))
+			}
+			op.templates[name] = tmpl
+			c.C = c.C[1:]
+			tmpl.bodies = append(tmpl.bodies, c)
+			continue
+		case "Production":
+			tmpl = nil
+			// a quoted non-terminal is the same symbol as the unquoted one
+			op.nonterm = symbolName(c)
+		case "ContinueProd":
+			if tmpl != nil {
+				tmpl.bodies = append(tmpl.bodies, c)
+				continue
+			}
 		}
+		c.Lexeme.(*lexeme.Lexeme).V = op.nonterm
 		op.stack = append(op.stack, c)
 	}
 
-	for len(op.stack) > 0 {
+	for len(op.stack) > 0 && op.err == nil {
 		node := op.stack[0]
 		op.stack = op.stack[1:]
 		op.evalProd(node)
 	}
+	if op.err != nil {
+		return nil, op.err
+	}
 
 	for nonterm, symbols := range op.bludgeons {
 		op.rdcr[nonterm] = bludgeon(symbols)
 	}
 
-	return op.grammar, op.rdcr
+	return op, nil
 }
 
 func (op *evalOp) evalProd(node *tree.PN) {
@@ -162,9 +267,21 @@ func (op *evalOp) evalProd(node *tree.PN) {
 func (op *evalOp) evalSymbol(node *tree.PN) rules {
 	switch node.Kind().String() {
 	case "symbol":
-		return rules{rule{node.Value()}}
+		if name := symbolName(node); name != node.Value() {
+			op.literals = append(op.literals, name)
+		}
+		return rules{rule{symbolName(node)}}
 	case "OptSymbol":
 		return append(op.evalSymbol(node.C[0]), rule{})
+	case "PlusSymbol":
+		// A+ is the same as A A*
+		return mergeRules(op.evalSymbol(node.C[0]), op.addRepeatAsProduction(op.node("RepSymbol", node.C[0])))
+	case "SepSymbol":
+		// A % sep is the same as A (sep A)*
+		group := op.node("Group", node.C[1], node.C[0])
+		return mergeRules(op.evalSymbol(node.C[0]), op.addRepeatAsProduction(op.node("RepSymbol", group)))
+	case "CountSymbol":
+		return op.evalCount(node)
 	case "OrSymbol":
 		var rs rules
 		for _, c := range node.C {
@@ -179,10 +296,137 @@ func (op *evalOp) evalSymbol(node *tree.PN) rules {
 		return rc.reduce()
 	case "RepSymbol":
 		return op.addRepeatAsProduction(node)
+	case "Instance":
+		return op.instance(node)
 	}
 	return nil
 }
 
+// instance adds the productions of a template with the parameters replaced by
+// the arguments. Given
+//   List<X, Sep> -> X (Sep X)*
+// the symbol List<int, comma> adds
+//   List<int,comma> -> int (comma int)*
+// The instance is flattened into its parent by the reducer, the same as E*.
+func (op *evalOp) instance(node *tree.PN) rules {
+	tmpl, ok := op.templates[symbolName(node)]
+	if !ok {
+		op.err = fmt.Errorf("Unknown Template: %s", symbolName(node))
+		return nil
+	}
+	if len(node.C) != len(tmpl.params) {
+		op.err = fmt.Errorf("Bad Template: %s takes %d arguments, got %d", symbolName(node), len(tmpl.params), len(node.C))
+		return nil
+	}
+	args := make(map[string]*tree.PN, len(tmpl.params))
+	for i, p := range tmpl.params {
+		args[p] = op.argNode(node.C[i])
+	}
+	symName := op.getName(node)
+	if len(symName) > maxInstanceName {
+		op.err = fmt.Errorf("Bad Template: %s expands forever", symbolName(node))
+		return nil
+	}
+	op.bludgeons[op.nonterm] = append(op.bludgeons[op.nonterm], symName)
+	if rs, ok := op.done[symName]; ok {
+		return rs
+	}
+
+	for _, body := range tmpl.bodies {
+		prod := &tree.PN{
+			Lexeme: &lexeme.Lexeme{
+				K: op.set.Str("Production"),
+				V: symName,
+			},
+		}
+		for _, c := range body.C {
+			c = substitute(tree.Clone(c), args)
+			c.P = prod
+			prod.C = append(prod.C, c)
+		}
+		op.stack = append(op.stack, prod)
+	}
+
+	rs := rules{rule{symName}}
+	op.done[symName] = rs
+	return rs
+}
+
+// argNode returns the node that replaces a parameter. An argument of more than
+// one symbol is treated as a group.
+func (op *evalOp) argNode(arg *tree.PN) *tree.PN {
+	if len(arg.C) == 1 {
+		return arg.C[0]
+	}
+	return op.node("Group", arg.C...)
+}
+
+// substitute replaces the symbols in node that name parameters with a copy of
+// their argument.
+func substitute(node *tree.PN, args map[string]*tree.PN) *tree.PN {
+	if node.Kind().String() == "symbol" {
+		if arg, ok := args[symbolName(node)]; ok {
+			return tree.Clone(arg)
+		}
+		return node
+	}
+	for i, c := range node.C {
+		c = substitute(c, args)
+		c.P = node
+		node.C[i] = c
+	}
+	return node
+}
+
+// node creates a node to desugar an operator into other operators.
+func (op *evalOp) node(kind string, children ...*tree.PN) *tree.PN {
+	return &tree.PN{
+		Lexeme: &lexeme.Lexeme{
+			K: op.set.Str(kind),
+		},
+		C: children,
+	}
+}
+
+func symbolName(node *tree.PN) string {
+	return strings.Trim(node.Value(), `"`)
+}
+
+// evalCount handles bounded repetition. Given A{2,4} the rules are
+//   A A A A
+//   A A A
+//   A A
+// and given A{2,} the rule is A A A*
+func (op *evalOp) evalCount(node *tree.PN) rules {
+	bounds := strings.Split(strings.Trim(node.Value(), "{}"), ",")
+	min, _ := strconv.Atoi(bounds[0])
+	max := min
+	if len(bounds) == 2 {
+		max = -1
+		if bounds[1] != "" {
+			max, _ = strconv.Atoi(bounds[1])
+		}
+	}
+	if max != -1 && max < min {
+		op.err = fmt.Errorf("Bad Count: %s", node.Value())
+		return nil
+	}
+
+	unit := op.evalSymbol(node.C[0])
+	rs := rules{rule{}}
+	for i := 0; i < min; i++ {
+		rs = mergeRules(rs, unit)
+	}
+	if max == -1 {
+		return mergeRules(rs, op.addRepeatAsProduction(op.node("RepSymbol", node.C[0])))
+	}
+	tail := rules{rule{}}
+	for i := min; i < max; i++ {
+		tail = append(mergeRules(unit, tail), rule{})
+	}
+	return mergeRules(rs, tail)
+}
+
 // addRepeatAsProduction creates two productions
 // given:
 // E*
@@ -243,11 +487,17 @@ func bludgeon(symbols []string) func(*tree.PN) {
 func (op *evalOp) getName(node *tree.PN) string {
 	switch node.Kind().String() {
 	case "symbol":
-		return node.Value()
+		return symbolName(node)
 	case "OptSymbol":
 		return op.getName(node.C[0]) + "?"
 	case "RepSymbol":
 		return op.getName(node.C[0]) + "*"
+	case "PlusSymbol":
+		return op.getName(node.C[0]) + "+"
+	case "CountSymbol":
+		return op.getName(node.C[0]) + node.Value()
+	case "SepSymbol":
+		return op.getName(node.C[0]) + "%" + op.getName(node.C[1])
 	case "OrSymbol":
 		var strs []string
 		for _, c := range node.C {
@@ -260,6 +510,12 @@ func (op *evalOp) getName(node *tree.PN) string {
 			strs = append(strs, op.getName(c))
 		}
 		return "(" + strings.Join(strs, "_") + ")"
+	case "Instance":
+		strs := make([]string, len(node.C))
+		for i, c := range node.C {
+			strs[i] = op.getName(op.argNode(c))
+		}
+		return symbolName(node) + "<" + strings.Join(strs, ",") + ">"
 	}
 
 	return ""
@@ -281,7 +537,8 @@ func mergeRules(a, b rules) rules {
 	var out rules
 	for _, ra := range a {
 		for _, rb := range b {
-			out = append(out, append(ra, rb...))
+			r := make(rule, 0, len(ra)+len(rb))
+			out = append(out, append(append(r, ra...), rb...))
 		}
 	}
 	return out
@@ -300,12 +557,51 @@ func (rc ruleComb) reduce() rules {
 
 // New takes a grammar string and returns a grammar, reducer and error.
 func New(grammarString string) (*grammar.Grammar, tree.Reducer, error) {
-	parseTree, err := runner.Run(grammarString)
+	op, err := eval(grammarString)
 	if err != nil {
 		return nil, nil, err
 	}
-	g, r := evalGrammar(parseTree.(*tree.PN))
-	return g, r, nil
+	return op.grammar, op.rdcr, nil
+}
+
+// Repeats returns the symbols added for the operators in the grammar, like E*,
+// and for the instances of templates by the non-terminal that uses them. The reducer returned by New promotes the
+// children of those symbols into the non-terminal. A code generator can use
+// them to write the reducer as code.
+func Repeats(grammarString string) (map[string][]string, error) {
+	op, err := eval(grammarString)
+	if err != nil {
+		return nil, err
+	}
+	return op.bludgeons, nil
+}
+
+// Literals returns the quoted terminals in the grammar, like "if" or ",", in
+// the order they first appear. Passing them to a simplelexer's AddLiterals
+// keeps the lexer in step with the grammar without listing them twice. Quoted
+// non-terminals are not included.
+func Literals(grammarString string) ([]string, error) {
+	op, err := eval(grammarString)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, lit := range op.literals {
+		if !seen[lit] && op.grammar.IsTerminal(op.set.Str(lit)) {
+			seen[lit] = true
+			out = append(out, lit)
+		}
+	}
+	return out, nil
+}
+
+func eval(grammarString string) (*evalOp, error) {
+	parseTree, err := runner.Run(grammarString)
+	if err != nil {
+		return nil, err
+	}
+	return evalGrammar(parseTree.(*tree.PN))
 }
 
 // Must returns a grammar and a reducer. If it fails to parse the grammar string
@@ -222,3 +222,176 @@ func TestDontRepeatRepeat(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectGrmr.String(), grmr.String())
 }
+
+func TestRepeats(t *testing.T) {
+	repeats, err := Repeats(`
+    rule1 -> lexeme1* lexeme2
+    rule2 -> lexeme1+ (A B)*
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"rule1": {"lexeme1*"},
+		"rule2": {"lexeme1*", "(A_B)*"},
+	}, repeats)
+
+	_, err = Repeats("rule1 -> (")
+	assert.Error(t, err)
+}
+
+func TestEBNF(t *testing.T) {
+	grmr, _, err := New(`
+    rule1 -> (A | B) C?
+    rule2 -> A+
+    rule3 -> A{2,4}
+    rule4 -> A{2,} B
+    rule5 -> value % ","
+    rule6 -> (A B){2}
+    "rule-7" -> "rule-7"? A
+  `)
+	assert.NoError(t, err)
+	expected, err := grammar.New(`
+    rule1       -> A C
+                -> A
+                -> B C
+                -> B
+    rule2       -> A A*
+    rule3       -> A A A A
+                -> A A A
+                -> A A
+    rule4       -> A A A* B
+    rule5       -> value (,_value)*
+    rule6       -> A B A B
+    rule-7      -> rule-7 A
+                -> A
+    A*          -> A A*
+                ->
+    (,_value)*  -> , value (,_value)*
+                ->
+  `)
+	assert.NoError(t, err)
+	if expected.String() != grmr.String() {
+		t.Error("\n" + grmr.String() + "====\n" + expected.String())
+	}
+
+	_, _, err = New(`
+    rule -> A{4,2}
+  `)
+	assert.Equal(t, "Bad Count: {4,2}", err.Error())
+}
+
+func TestEBNFReduce(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    int /\d+/
+    ,   /,/
+    (   /\(/
+    )   /\)/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, rdcr, err := New(`
+    List -> "(" int % "," ")"
+  `)
+	assert.NoError(t, err)
+
+	pn := rdcr.Reduce(packrat.New(grmr).Parse(lxr.Lex("(1, 2, 3)")))
+	expected, err := tree.New(`
+    List {
+      (: "("
+      int: "1"
+      ,: ","
+      int: "2"
+      ,: ","
+      int: "3"
+      ): ")"
+    }
+  `)
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	grmr, rdcr, err := New(`
+    Call         -> word Wrap<List<Arg, comma>?>
+    Arg          -> int|word
+    List<X, Sep> -> X (Sep X)*
+    Wrap<X>      -> "(" X ")"
+    Args         -> List<int word, semi>
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected, err := grammar.New(`
+    Call                            -> word Wrap<List<Arg,comma>?>
+    Arg                             -> int
+                                    -> word
+    Args                            -> List<(int_word),semi>
+    Wrap<List<Arg,comma>?>          -> ( List<Arg,comma> )
+                                    -> ( )
+    List<(int_word),semi>           -> int word (semi_(int_word))*
+    List<Arg,comma>                 -> Arg (comma_Arg)*
+    (semi_(int_word))*              -> semi int word (semi_(int_word))*
+                                    ->
+    (comma_Arg)*                    -> comma Arg (comma_Arg)*
+                                    ->
+  `)
+	assert.NoError(t, err)
+	if expected.String() != grmr.String() {
+		t.Error("\n" + grmr.String() + "====\n" + expected.String())
+	}
+
+	lxr, err := simplelexer.New(`
+    int   /\d+/
+    word  /\w+/
+    comma /,/
+    (     /\(/
+    )     /\)/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	pn := rdcr.Reduce(packrat.New(grmr).Parse(lxr.Lex("f(1, x)")))
+	expectedTree, err := tree.New(`
+    Call {
+      word: "f"
+      (: "("
+      Arg {
+        int: "1"
+      }
+      comma: ","
+      Arg {
+        word: "x"
+      }
+      ): ")"
+    }
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTree.String(), pn.(*tree.PN).String())
+
+	tests := map[string]string{
+		"A -> B<x>":                       "Unknown Template: B",
+		"A -> B<x>\nB<X, Y> -> X Y":       "Bad Template: B takes 2 arguments, got 1",
+		"A -> B<x>\nB<X> -> X\nB<Y> -> Y": "Duplicate Template: B",
+		"A -> B<x>\nB<X> -> X B<(X X)>":   "Bad Template: B expands forever",
+	}
+	for src, expected := range tests {
+		_, _, err := New(src)
+		if assert.Error(t, err, src) {
+			assert.Equal(t, expected, err.Error(), src)
+		}
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	literals, err := Literals(`
+    If       -> "if" Expr Block ("else" Block)?
+    Block    -> "{" "rule-7"* "}"
+    "rule-7" -> Expr ";"
+    Expr     -> int % ","
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"if", "else", "{", "}", ";", ","}, literals)
+
+	_, err = Literals("rule1 -> (")
+	assert.Error(t, err)
+}
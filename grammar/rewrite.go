@@ -0,0 +1,284 @@
+package grammar
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+)
+
+// The rewrites return a new grammar and leave the grammar they are called on
+// unchanged. Each also returns a reducer that restores trees parsed with the
+// new grammar to the shape they would have with the old one, the same as
+// RemoveLeftRecursion and ToCNF.
+
+// Rename returns a copy of the grammar with the non-terminal from renamed to
+// to. The reducer renames the nodes back. Precedence, predicates and weights
+// are kept.
+func (g *Grammar) Rename(from, to string) (*Grammar, tree.Reducer, error) {
+	if g.Productions(stringsymbol.Symbol(from)) == nil {
+		return nil, nil, fmt.Errorf("Unknown Non-Terminal: %s", from)
+	}
+	if g.uses(to) {
+		return nil, nil, fmt.Errorf("Symbol In Use: %s", to)
+	}
+	out := Empty()
+	if err := out.merge(g, map[string]string{from: to}, nil); err != nil {
+		return nil, nil, err
+	}
+	return out, tree.Reducer{to: tree.Rename(from)}, nil
+}
+
+// Inline returns a copy of the grammar without the non-terminal name. Each
+// production that uses it is replaced by a copy for each of its productions,
+// with the symbols of that production in its place, so
+//   E  -> T Op E
+//      -> T
+//   Op -> +
+//      -> -
+// becomes
+//   E -> T + E
+//     -> T - E
+//     -> T
+// The weight of a new production is the product of the weights it was made
+// from. A production that uses name loses its predicate, because its children
+// change. The start symbol and a non-terminal that uses itself cannot be
+// inlined. The reducer puts the nodes for name back.
+func (g *Grammar) Inline(name string) (*Grammar, tree.Reducer, error) {
+	sym := stringsymbol.Symbol(name)
+	inlined := g.Productions(sym)
+	if inlined == nil {
+		return nil, nil, fmt.Errorf("Unknown Non-Terminal: %s", name)
+	}
+	if g.Start().String() == name {
+		return nil, nil, fmt.Errorf("Cannot Inline Start: %s", name)
+	}
+	bodies := make([][]string, 0, inlined.Productions())
+	for i := inlined.Iter(); i.Next(); {
+		body := productionStrs(i.Production)
+		for _, s := range body {
+			if s == name {
+				return nil, nil, fmt.Errorf("Recursive Non-Terminal: %s", name)
+			}
+		}
+		bodies = append(bodies, body)
+	}
+
+	// expansion is a production being built, with the shape of the production
+	// it was made from
+	type expansion struct {
+		symbols []string
+		s       *shape
+		weight  float64
+	}
+	out := Empty()
+	sr := newShapeReducer()
+	r := tree.Reducer{}
+	for _, nt := range g.NonTerminals() {
+		kind := nt.String()
+		if kind == name {
+			continue
+		}
+		for i := g.Productions(nt).Iter(); i.Next(); {
+			symbols := productionStrs(i.Production)
+			if !contains(symbols, name) {
+				if !out.has(kind, symbols) {
+					out.addFrom(g, nt, i.Idx, kind)
+				}
+				continue
+			}
+			r[kind] = sr.restore
+			exps := []expansion{{
+				s:      &shape{slot: -1, kind: kind},
+				weight: g.Weight(nt, i.Idx),
+			}}
+			for _, s := range symbols {
+				if s != name {
+					for j := range exps {
+						exps[j].s.c = append(exps[j].s.c, &shape{slot: len(exps[j].symbols)})
+						exps[j].symbols = append(exps[j].symbols, s)
+					}
+					continue
+				}
+				next := make([]expansion, 0, len(exps)*len(bodies))
+				for _, e := range exps {
+					for b, body := range bodies {
+						c := &shape{slot: -1, kind: name}
+						for k := range body {
+							c.c = append(c.c, &shape{slot: len(e.symbols) + k})
+						}
+						next = append(next, expansion{
+							symbols: append(append([]string(nil), e.symbols...), body...),
+							s: &shape{
+								slot: -1,
+								kind: kind,
+								c:    append(append([]*shape(nil), e.s.c...), c),
+							},
+							weight: e.weight * g.Weight(sym, b),
+						})
+					}
+				}
+				exps = next
+			}
+			for _, e := range exps {
+				if out.has(kind, e.symbols) {
+					continue
+				}
+				prod := production(e.symbols...)
+				out.Add(nt, prod)
+				if e.weight != 1 {
+					out.SetWeight(nt, prod, e.weight)
+				}
+				sr.shapes[shapeKey(kind, e.symbols)] = e.s
+			}
+		}
+	}
+	out.copyPrecedence(g)
+	return out, r, nil
+}
+
+// Extract returns a copy of the grammar where the productions of nt at the
+// indexes in alts are moved to a new non-terminal name, which takes the place of
+// the first of them, so
+//   Expr -> Expr + Expr
+//        -> ( Expr )
+//        -> int
+// with alts 1 and 2 and the name Term becomes
+//   Expr -> Expr + Expr
+//        -> Term
+//   Term -> ( Expr )
+//        -> int
+// The productions keep their order, predicates and weights and the weight of
+// the production for name is the sum of their weights. The reducer replaces
+// each node for name with its children and gives each node for nt the index of
+// its production in the old grammar.
+func (g *Grammar) Extract(nt string, alts []int, name string) (*Grammar, tree.Reducer, error) {
+	prods := g.Productions(stringsymbol.Symbol(nt))
+	if prods == nil {
+		return nil, nil, fmt.Errorf("Unknown Non-Terminal: %s", nt)
+	}
+	if g.uses(name) {
+		return nil, nil, fmt.Errorf("Symbol In Use: %s", name)
+	}
+	if len(alts) == 0 {
+		return nil, nil, fmt.Errorf("Nothing To Extract: %s", nt)
+	}
+	extract := make(map[int]bool, len(alts))
+	for _, a := range alts {
+		if a < 0 || a >= prods.Productions() || extract[a] {
+			return nil, nil, fmt.Errorf("Bad Production Index: %s %d", nt, a)
+		}
+		extract[a] = true
+	}
+
+	out := Empty()
+	// old holds the index in the old grammar of each production of nt in the
+	// new grammar, with -1 for the production for name, and extracted holds it
+	// for each production of name
+	var old, extracted []int
+	for _, s := range g.NonTerminals() {
+		if s.String() != nt {
+			for i := g.Productions(s).Iter(); i.Next(); {
+				out.addFrom(g, s, i.Idx, s.String())
+			}
+			continue
+		}
+		weight := 0.0
+		for i := prods.Iter(); i.Next(); {
+			if !extract[i.Idx] {
+				old = append(old, i.Idx)
+				out.addFrom(g, s, i.Idx, nt)
+				continue
+			}
+			weight += g.Weight(s, i.Idx)
+			extracted = append(extracted, i.Idx)
+			if len(extracted) == 1 {
+				old = append(old, -1)
+				out.Add(s, production(name))
+			}
+		}
+		if weight != 1 {
+			out.SetWeight(s, production(name), weight)
+		}
+		for _, idx := range extracted {
+			out.addFrom(g, s, idx, name)
+		}
+	}
+	out.copyPrecedence(g)
+
+	oldIdx := func(idx int, in []int) int {
+		if idx < 0 || idx >= len(in) {
+			return -1
+		}
+		return in[idx]
+	}
+	return out, tree.Reducer{
+		nt: func(node *tree.PN) {
+			if len(node.C) == 1 && node.C[0].Kind().String() == name {
+				c := node.C[0]
+				node.C = c.C
+				node.SetProduction(oldIdx(c.Production(), extracted))
+				return
+			}
+			node.SetProduction(oldIdx(node.Production(), old))
+		},
+	}, nil
+}
+
+// uses is true if the symbol is a non-terminal or is used in a production.
+func (g *Grammar) uses(name string) bool {
+	for _, nt := range g.NonTerminals() {
+		if nt.String() == name {
+			return true
+		}
+		for i := g.Productions(nt).Iter(); i.Next(); {
+			if contains(productionStrs(i.Production), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// has is true if nt already has a production with the symbols.
+func (g *Grammar) has(nt string, symbols []string) bool {
+	prods := g.Productions(stringsymbol.Symbol(nt))
+	return prods != nil && g.hasProduction(prods, symbols)
+}
+
+// addFrom adds the production of nt at idx in src to the grammar as a
+// production of to, with its predicate and weight.
+func (g *Grammar) addFrom(src *Grammar, nt parlex.Symbol, idx int, to string) {
+	prod := production(productionStrs(src.Productions(nt).Production(idx))...)
+	from := stringsymbol.Symbol(to)
+	g.Add(from, prod)
+	if p := src.Predicate(nt, idx); p != nil {
+		g.AddPredicate(from, prod, p)
+	}
+	if w := src.Weight(nt, idx); w != 1 {
+		g.SetWeight(from, prod, w)
+	}
+}
+
+func (g *Grammar) copyPrecedence(src *Grammar) {
+	for i, level := range src.levels {
+		g.AddPrecedence(src.assocs[i], level...)
+	}
+}
+
+func productionStrs(prod parlex.Production) []string {
+	strs := make([]string, 0, prod.Symbols())
+	for i := prod.Iter(); i.Next(); {
+		strs = append(strs, i.Symbol.String())
+	}
+	return strs
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/earley"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var rewriteLexer = parlex.MustLexer(simplelexer.New(`
+    int /\d+/
+    +   /\+/
+    -   /-/
+    (   /\(/
+    )   /\)/
+    space /\s+/ -
+  `))
+
+// assertRestores checks that trees parsed with the new grammar reduce to the
+// trees parsed with the old grammar.
+func assertRestores(t *testing.T, old, new parlex.Grammar, r tree.Reducer, inputs ...string) {
+	for _, input := range inputs {
+		expected, err := earley.New(old).ParseErr(rewriteLexer.Lex(input))
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		pn, err := earley.New(new).ParseErr(rewriteLexer.Lex(input))
+		if assert.NoError(t, err, input) {
+			assert.Equal(t, expected.(*tree.PN).String(), r.RawReduce(pn).String(), input)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	g, err := New(`
+    %left +
+    E -> E + T @2
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	out, r, err := g.Rename("T", "Term")
+	assert.NoError(t, err)
+	expected, err := New(`
+    %left +
+    E    -> E + Term @2
+         -> Term
+    Term -> ( E )
+         -> int
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), out.String())
+	assertRestores(t, g, out, r, "1 + (2 + 3)")
+
+	_, _, err = g.Rename("X", "Y")
+	assert.Equal(t, "Unknown Non-Terminal: X", err.Error())
+	_, _, err = g.Rename("T", "int")
+	assert.Equal(t, "Symbol In Use: int", err.Error())
+}
+
+func TestInline(t *testing.T) {
+	g, err := New(`
+    E  -> T Op E
+       -> T
+    T  -> ( E )
+       -> int
+    Op -> + @3
+       -> -
+  `)
+	assert.NoError(t, err)
+
+	out, r, err := g.Inline("Op")
+	assert.NoError(t, err)
+	expected, err := New(`
+    E -> T + E @3
+      -> T - E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), out.String())
+	assertRestores(t, g, out, r, "1 + (2 - 3) - 4", "5")
+
+	// more than one use, and a nullable non-terminal
+	g, err = New(`
+    L -> ( S int S )
+    S -> +
+      ->
+  `)
+	assert.NoError(t, err)
+	out, r, err = g.Inline("S")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, out.Productions(stringsymbol.Symbol("L")).Productions())
+	assert.Len(t, out.NonTerminals(), 1)
+	assertRestores(t, g, out, r, "(1)", "(+1)", "(1+)", "(+1+)")
+
+	_, _, err = g.Inline("L")
+	assert.Equal(t, "Cannot Inline Start: L", err.Error())
+	_, _, err = g.Inline("int")
+	assert.Equal(t, "Unknown Non-Terminal: int", err.Error())
+	g, err = New(`
+    L -> ( S )
+    S -> S +
+      -> +
+  `)
+	assert.NoError(t, err)
+	_, _, err = g.Inline("S")
+	assert.Equal(t, "Recursive Non-Terminal: S", err.Error())
+}
+
+func TestExtract(t *testing.T) {
+	g, err := New(`
+    Expr -> Expr + Expr
+         -> ( Expr ) @2
+         -> Expr - Expr
+         -> int @3
+  `)
+	assert.NoError(t, err)
+
+	out, r, err := g.Extract("Expr", []int{3, 1}, "Term")
+	assert.NoError(t, err)
+	expected, err := New(`
+    Expr -> Expr + Expr
+         -> Term @5
+         -> Expr - Expr
+    Term -> ( Expr ) @2
+         -> int @3
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), out.String())
+	assertRestores(t, g, out, r, "1 + (2 - 3)", "4")
+
+	pn, err := earley.New(out).ParseErr(rewriteLexer.Lex("(1) - 2"))
+	assert.NoError(t, err)
+	restored := r.RawReduce(pn)
+	assert.Equal(t, 2, restored.Production())
+	assert.Equal(t, 1, restored.C[0].Production())
+	assert.Equal(t, 3, restored.C[0].C[1].Production())
+	assert.Equal(t, 3, restored.C[2].Production())
+
+	_, _, err = g.Extract("Term", []int{0}, "X")
+	assert.Equal(t, "Unknown Non-Terminal: Term", err.Error())
+	_, _, err = g.Extract("Expr", []int{0}, "int")
+	assert.Equal(t, "Symbol In Use: int", err.Error())
+	_, _, err = g.Extract("Expr", []int{0, 4}, "Term")
+	assert.Equal(t, "Bad Production Index: Expr 4", err.Error())
+	_, _, err = g.Extract("Expr", []int{1, 1}, "Term")
+	assert.Equal(t, "Bad Production Index: Expr 1", err.Error())
+	_, _, err = g.Extract("Expr", nil, "Term")
+	assert.Equal(t, "Nothing To Extract: Expr", err.Error())
+}
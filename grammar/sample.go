@@ -0,0 +1,59 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/gen"
+	"math/rand"
+)
+
+// SampleOptions control the sentences produced by Sample.
+type SampleOptions struct {
+	// Seed for the random source. The same grammar, options and seed always
+	// produce the same sentences.
+	Seed int64
+	// N is the number of sentences, if it is less than 1 there is one.
+	N int
+	// MaxDepth is how deep a derivation goes before only the productions that
+	// finish it soonest are chosen. If it is 0, gen.DefaultMaxDepth is used.
+	MaxDepth int
+	// Start is the symbol the sentences are derived from. If it is nil, the
+	// start symbol of the grammar is used.
+	Start parlex.Symbol
+	// Values generates the value of a terminal by kind. A terminal without
+	// one has its kind as its value.
+	Values map[string]func(r *rand.Rand) string
+}
+
+// Sample returns random sentences derived from a grammar as lexemes using a
+// gen.Generator. If the grammar is a parlex.WeightedGrammar, each production
+// is chosen in proportion to its weight among the productions of its
+// non-terminal, so the sentences can be made to look like real input.
+func Sample(grmr parlex.Grammar, opts SampleOptions) ([][]parlex.Lexeme, error) {
+	start := opts.Start
+	if start == nil {
+		nts := grmr.NonTerminals()
+		if len(nts) == 0 {
+			return nil, parlex.ErrBadGrammar
+		}
+		start = nts[0]
+	}
+	g := gen.New(grmr, opts.Seed)
+	if opts.MaxDepth != 0 {
+		g.MaxDepth = opts.MaxDepth
+	}
+	for kind, v := range opts.Values {
+		g.Values[kind] = v
+	}
+	n := opts.N
+	if n < 1 {
+		n = 1
+	}
+	out := make([][]parlex.Lexeme, n)
+	for i := range out {
+		var err error
+		if out[i], err = g.SentenceOf(start); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
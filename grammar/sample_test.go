@@ -0,0 +1,117 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func text(lxs []parlex.Lexeme) string {
+	strs := make([]string, len(lxs))
+	for i, lx := range lxs {
+		strs[i] = lx.Value()
+	}
+	return strings.Join(strs, " ")
+}
+
+func TestSample(t *testing.T) {
+	g, err := New(`
+    E -> E + E @2
+      -> ( E )
+      -> int @5
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	opts := SampleOptions{
+		Seed: 3,
+		N:    200,
+		Values: map[string]func(*rand.Rand) string{
+			"int": func(r *rand.Rand) string { return strconv.Itoa(r.Intn(100)) },
+		},
+	}
+	s1, err := Sample(g, opts)
+	assert.NoError(t, err)
+	assert.Len(t, s1, 200)
+
+	// the same seed gives the same sentences
+	s2, err := Sample(g, opts)
+	assert.NoError(t, err)
+	for i := range s1 {
+		assert.Equal(t, text(s1[i]), text(s2[i]))
+	}
+
+	ints := 0
+	for _, lxs := range s1 {
+		for _, lx := range lxs {
+			if lx.Kind().String() == "int" {
+				_, err := strconv.Atoi(lx.Value())
+				assert.NoError(t, err)
+			}
+		}
+		if len(lxs) == 1 {
+			ints++
+		}
+	}
+	// E -> int has a weight of 5 out of 8
+	assert.InDelta(t, 200*5/8, ints, 25)
+}
+
+func TestSampleWeights(t *testing.T) {
+	g, err := New(`
+    A -> x @9
+      -> y
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := Sample(g, SampleOptions{N: 1000})
+	assert.NoError(t, err)
+	xs := 0
+	for _, lxs := range s {
+		if text(lxs) == "x" {
+			xs++
+		}
+	}
+	assert.InDelta(t, 900, xs, 50)
+
+	// a weight of 0 is never chosen
+	assert.NoError(t, g.SetWeight(A, stringsymbol.Production{x}, 0))
+	s, err = Sample(g, SampleOptions{N: 100})
+	assert.NoError(t, err)
+	for _, lxs := range s {
+		assert.Equal(t, "y", text(lxs))
+	}
+}
+
+func TestSampleDepth(t *testing.T) {
+	g, err := New(`
+    S -> L
+    L -> L , x @100
+      -> x
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := Sample(g, SampleOptions{Seed: 1, MaxDepth: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, "x , x , x", text(s[0]))
+
+	s, err = Sample(g, SampleOptions{Start: stringsymbol.Symbol("x")})
+	assert.NoError(t, err)
+	assert.Equal(t, "x", text(s[0]))
+
+	g, err = New(`
+    A -> A x
+  `)
+	assert.NoError(t, err)
+	_, err = Sample(g, SampleOptions{})
+	assert.EqualError(t, err, "Infinite Non-Terminal: A")
+
+	_, err = Sample(Empty(), SampleOptions{})
+	assert.Equal(t, parlex.ErrBadGrammar, err)
+}
@@ -0,0 +1,136 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+	"strings"
+)
+
+// shape rebuilds part of the original tree from the children of a node parsed
+// with a grammar that was rewritten, like by ToCNF or Inline. A shape is either
+// a slot, which is one of the children, or a node of the original grammar. A
+// node with no slots under it is an empty derivation that was removed from the
+// grammar.
+type shape struct {
+	slot int
+	kind string
+	c    []*shape
+}
+
+// identityShape is a node of kind with ln children in the slots in order.
+func identityShape(kind string, ln int) *shape {
+	s := &shape{slot: -1, kind: kind}
+	for i := 0; i < ln; i++ {
+		s.c = append(s.c, &shape{slot: i})
+	}
+	return s
+}
+
+// remap replaces each slot i with m[i].
+func (s *shape) remap(m []*shape) *shape {
+	if s.slot >= 0 {
+		return m[s.slot]
+	}
+	cp := &shape{
+		slot: -1,
+		kind: s.kind,
+		c:    make([]*shape, len(s.c)),
+	}
+	for i, c := range s.c {
+		cp.c[i] = c.remap(m)
+	}
+	return cp
+}
+
+func (s *shape) isIdentity(kind string) bool {
+	if s.kind != kind {
+		return false
+	}
+	for i, c := range s.c {
+		if c.slot != i {
+			return false
+		}
+	}
+	return true
+}
+
+func production(symbols ...string) stringsymbol.Production {
+	prod := make(stringsymbol.Production, len(symbols))
+	for i, s := range symbols {
+		prod[i] = stringsymbol.Symbol(s)
+	}
+	return prod
+}
+
+// shapeReducer holds the shape for each production of the rewritten grammar,
+// by the production before any helpers were added. The helpers are only
+// reduced as part of the node they belong to, so they do not need a key in the
+// Reducer that could be taken for a pattern.
+type shapeReducer struct {
+	shapes  map[string]*shape
+	helpers map[string]bool
+}
+
+func newShapeReducer() *shapeReducer {
+	return &shapeReducer{
+		shapes:  make(map[string]*shape),
+		helpers: make(map[string]bool),
+	}
+}
+
+func shapeKey(nt string, symbols []string) string {
+	return nt + " -> " + strings.Join(symbols, " ")
+}
+
+// restore rebuilds a node. The production of the rebuilt node is not known.
+func (sr *shapeReducer) restore(node *tree.PN) {
+	// splice in the chain of a long production, then the terminal of each
+	// terminal helper; the children then match the production
+	for ln := len(node.C); ln > 0 && sr.helpers[node.C[ln-1].Kind().String()] && len(node.C[ln-1].C) == 2; ln = len(node.C) {
+		node.C = append(node.C[:ln-1], node.C[ln-1].C...)
+	}
+	kinds := make([]string, len(node.C))
+	for i, c := range node.C {
+		if sr.helpers[c.Kind().String()] {
+			node.C[i] = c.C[0]
+			c = node.C[i]
+		}
+		kinds[i] = c.Kind().String()
+	}
+	s, ok := sr.shapes[shapeKey(node.Kind().String(), kinds)]
+	if !ok {
+		return
+	}
+	restored := sr.build(s, node.C)
+	node.Lexeme = restored.Lexeme
+	node.C = restored.C
+	for _, c := range node.C {
+		c.P = node
+	}
+	node.SetProduction(-1)
+	node.UpdateSpan()
+}
+
+// build a node from a shape using the slots.
+func (sr *shapeReducer) build(s *shape, slots []*tree.PN) *tree.PN {
+	if s.slot >= 0 {
+		return slots[s.slot]
+	}
+	lx := lexeme.New(stringsymbol.Symbol(s.kind))
+	pn := &tree.PN{
+		Lexeme: lx,
+		C:      make([]*tree.PN, len(s.c)),
+	}
+	for i, c := range s.c {
+		pn.C[i] = sr.build(c, slots)
+		pn.C[i].P = pn
+	}
+	pn.UpdateSpan()
+	if len(pn.C) > 0 {
+		if l, c := pn.C[0].Pos(); l >= 0 {
+			lx.At(l, c).AtOffset(pn.C[0].Offset())
+		}
+	}
+	return pn
+}
@@ -0,0 +1,100 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"strings"
+)
+
+// Validation lists the problems found in a grammar by Validate. Unreachable
+// holds the non-terminals that cannot be reached from the start symbol.
+// NonProductive holds the non-terminals that can never derive a string of
+// terminals, as found by analysis.Unproductive. Undefined holds the terminals
+// that are used in the grammar but were not given as terminals, which is often
+// a typo in a non-terminal.
+type Validation struct {
+	Unreachable   []parlex.Symbol
+	NonProductive []parlex.Symbol
+	Undefined     []parlex.Symbol
+}
+
+// Error fulfills error and lists all the problems.
+func (v *Validation) Error() string {
+	var segs []string
+	add := func(name string, symbols []parlex.Symbol) {
+		if len(symbols) == 0 {
+			return
+		}
+		strs := make([]string, len(symbols))
+		for i, s := range symbols {
+			strs[i] = s.String()
+		}
+		segs = append(segs, name+": "+strings.Join(strs, ", "))
+	}
+	add("unreachable", v.Unreachable)
+	add("non-productive", v.NonProductive)
+	add("undefined terminals", v.Undefined)
+	return "Invalid Grammar) " + strings.Join(segs, "; ")
+}
+
+// Validate checks a grammar for non-terminals that are unreachable or
+// non-productive. If terminals are given, any other symbol in the grammar that
+// is not a non-terminal is reported as undefined. The terminals will usually be
+// the kinds the lexer produces. If there are any problems, the returned error
+// will be a *Validation.
+func Validate(grammar parlex.Grammar, terminals ...parlex.Symbol) error {
+	nts := grammar.NonTerminals()
+	if len(nts) == 0 {
+		return nil
+	}
+	isNT := make(map[string]bool, len(nts))
+	for _, nt := range nts {
+		isNT[nt.String()] = true
+	}
+
+	v := &Validation{}
+
+	reached := map[string]bool{nts[0].String(): true}
+	queue := []parlex.Symbol{nts[0]}
+	for len(queue) > 0 {
+		nt := queue[0]
+		queue = queue[1:]
+		for i := grammar.Productions(nt).Iter(); i.Next(); {
+			for j := i.Iter(); j.Next(); {
+				if str := j.Symbol.String(); isNT[str] && !reached[str] {
+					reached[str] = true
+					queue = append(queue, j.Symbol)
+				}
+			}
+		}
+	}
+
+	for _, nt := range nts {
+		if !reached[nt.String()] {
+			v.Unreachable = append(v.Unreachable, nt)
+		}
+	}
+	v.NonProductive = analysis.New(grammar).Unproductive()
+
+	if len(terminals) > 0 {
+		defined := make(map[string]bool, len(terminals))
+		for _, t := range terminals {
+			defined[t.String()] = true
+		}
+		for _, nt := range nts {
+			for i := grammar.Productions(nt).Iter(); i.Next(); {
+				for j := i.Iter(); j.Next(); {
+					if str := j.Symbol.String(); !isNT[str] && !defined[str] {
+						defined[str] = true
+						v.Undefined = append(v.Undefined, j.Symbol)
+					}
+				}
+			}
+		}
+	}
+
+	if v.Unreachable == nil && v.NonProductive == nil && v.Undefined == nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,55 @@
+package grammar
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    int /\d+/
+    op  /[+\-]/
+    (   /\(/
+    )   /\)/
+  `)
+	assert.NoError(t, err)
+
+	g, err := New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(g, lxr.Symbols()...))
+
+	g, err = New(`
+    E -> T op E
+      -> T
+    T -> ( Exp )
+      -> int
+      -> L
+    L -> L int
+    U -> int
+  `)
+	assert.NoError(t, err)
+	err = Validate(g, lxr.Symbols()...)
+	if v, ok := err.(*Validation); assert.True(t, ok) {
+		str := func(symbols []parlex.Symbol) []string {
+			out := make([]string, len(symbols))
+			for i, s := range symbols {
+				out[i] = s.String()
+			}
+			return out
+		}
+		assert.Equal(t, []string{"U"}, str(v.Unreachable))
+		assert.Equal(t, []string{"L"}, str(v.NonProductive))
+		assert.Equal(t, []string{"Exp"}, str(v.Undefined))
+		assert.Equal(t, "Invalid Grammar) unreachable: U; non-productive: L; undefined terminals: Exp", v.Error())
+	}
+
+	// without terminals, Exp is treated as a terminal
+	assert.Equal(t, "Invalid Grammar) unreachable: U; non-productive: L", Validate(g).Error())
+}
@@ -0,0 +1,112 @@
+package yacc
+
+import (
+	"github.com/adamcolton/parlex/internal/textscan"
+	"unicode"
+)
+
+const (
+	tEOF    = textscan.EOF
+	tIdent  = textscan.Kind(iota)
+	tChar   // 'x', text is the contents
+	tString // "x", text is the contents
+	tNumber
+	tAction    // {...}
+	tTag       // <...>
+	tDirective // %token, %left...
+	tMark      // %%
+	tPunct     // : | ;
+)
+
+type scanner struct {
+	*textscan.Scanner
+	marks int
+}
+
+func scan(src string) ([]textscan.Token, error) {
+	s := &scanner{Scanner: textscan.New(src)}
+	return textscan.Tokens(s.next)
+}
+
+// skip also skips the prologue, which is target code.
+func (s *scanner) skip() error {
+	for {
+		if err := s.Skip(); err != nil {
+			return err
+		}
+		if !s.HasPrefix("%{") {
+			return nil
+		}
+		s.Advance(2)
+		if err := s.SkipTo("%}"); err != nil {
+			return err
+		}
+	}
+}
+
+func isIdent(r rune, first bool) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
+
+func (s *scanner) next() (textscan.Token, error) {
+	if s.marks == 2 {
+		// the epilogue is target code
+		return s.Token(tEOF), nil
+	}
+	if err := s.skip(); err != nil {
+		return textscan.Token{}, err
+	}
+	t := s.Token(tEOF)
+	r := s.Peek(0)
+	var err error
+	switch {
+	case s.Done():
+	case isIdent(r, true):
+		t.Kind = tIdent
+		t.Text = s.word()
+	case unicode.IsDigit(r):
+		t.Kind = tNumber
+		n := 1
+		for unicode.IsDigit(s.Peek(n)) {
+			n++
+		}
+		t.Text = s.Advance(n)
+	case r == '%' && s.Peek(1) == '%':
+		t.Kind = tMark
+		t.Text = s.Advance(2)
+		s.marks++
+	case r == '%' && isIdent(s.Peek(1), true):
+		t.Kind = tDirective
+		s.Advance(1)
+		t.Text = "%" + s.word()
+	case r == '\'':
+		t.Kind = tChar
+		s.Advance(1)
+		t.Text, err = s.Until('\'')
+	case r == '"':
+		t.Kind = tString
+		s.Advance(1)
+		t.Text, err = s.Until('"')
+	case r == '<':
+		t.Kind = tTag
+		s.Advance(1)
+		t.Text, err = s.Until('>')
+	case r == '{':
+		t.Kind = tAction
+		t.Text, err = s.Action()
+	case r == ':' || r == '|' || r == ';':
+		t.Kind = tPunct
+		t.Text = s.Advance(1)
+	default:
+		err = s.Errorf("unexpected %q", r)
+	}
+	return t, err
+}
+
+func (s *scanner) word() string {
+	n := 1
+	for isIdent(s.Peek(n), false) || s.Peek(n) == '-' && isIdent(s.Peek(n+1), false) {
+		n++
+	}
+	return s.Advance(n)
+}
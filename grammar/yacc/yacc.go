@@ -0,0 +1,373 @@
+// Package yacc imports and exports grammars in the format used by yacc and
+// bison.
+//
+// Import reads the rules and the precedence declarations. Actions, the
+// prologue, the epilogue and declarations like %type and %union are ignored
+// because they only change the generated code; %token is only read for
+// aliases. A character literal like '+' becomes the symbol +, an escape like
+// '\n' keeps the backslash, and a string literal like "==" becomes the symbol
+// ==, or the token it is an alias of. The %prec modifier is
+// ignored because a parlex.PrecedenceGrammar takes the precedence of a
+// production from its last terminal.
+//
+// Export writes a .y skeleton with the rules and precedence of a grammar and
+// no actions. Terminals that are not identifiers are written as character or
+// string literals.
+package yacc
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/internal/textscan"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var assocs = map[string]parlex.Assoc{
+	"%left":       parlex.Left,
+	"%right":      parlex.Right,
+	"%nonassoc":   parlex.NonAssoc,
+	"%precedence": parlex.NonAssoc,
+}
+
+type yaccRule struct {
+	nt    string
+	prods []stringsymbol.Production
+}
+
+type importOp struct {
+	tkns  []textscan.Token
+	cur   int
+	start string
+	rules []*yaccRule
+	byNT  map[string]*yaccRule
+	// aliases maps a string literal declared with %token to the token name
+	aliases map[string]string
+	g       *grammar.Grammar
+}
+
+// Import converts the source of a .y file to a grammar.
+func Import(src string) (*grammar.Grammar, error) {
+	tkns, err := scan(src)
+	if err != nil {
+		return nil, err
+	}
+	op := &importOp{
+		tkns:    tkns,
+		byNT:    make(map[string]*yaccRule),
+		aliases: make(map[string]string),
+		g:       grammar.Empty(),
+	}
+	if err := op.declarations(); err != nil {
+		return nil, err
+	}
+	if err := op.ruleSection(); err != nil {
+		return nil, err
+	}
+	if len(op.rules) == 0 {
+		return nil, fmt.Errorf("Bad Grammar: no rules")
+	}
+
+	// the start symbol must be added first
+	start := op.rules[0]
+	if op.start != "" {
+		if start = op.byNT[op.start]; start == nil {
+			return nil, fmt.Errorf("Bad Grammar: start symbol %s has no rules", op.start)
+		}
+	}
+	op.add(start)
+	for _, r := range op.rules {
+		if r != start {
+			op.add(r)
+		}
+	}
+	return op.g, nil
+}
+
+func (op *importOp) add(r *yaccRule) {
+	for _, p := range r.prods {
+		op.g.Add(stringsymbol.Symbol(r.nt), p)
+	}
+}
+
+func (op *importOp) peek() textscan.Token { return op.tkns[op.cur] }
+
+func (op *importOp) next() textscan.Token {
+	t := op.tkns[op.cur]
+	if t.Kind != tEOF {
+		op.cur++
+	}
+	return t
+}
+
+func (op *importOp) expected(what string) error {
+	return op.peek().Expected(what)
+}
+
+// symbol returns the name of a symbol token, or false if the token is not a
+// symbol.
+func (op *importOp) symbol(t textscan.Token) (string, bool) {
+	switch t.Kind {
+	case tString:
+		if name, ok := op.aliases[t.Text]; ok {
+			return name, true
+		}
+		return t.Text, true
+	case tIdent, tChar:
+		return t.Text, true
+	}
+	return "", false
+}
+
+// endOfDecl returns true if the next token starts a new declaration or the
+// rules.
+func (op *importOp) endOfDecl() bool {
+	k := op.peek().Kind
+	return k == tDirective || k == tMark || k == tEOF
+}
+
+func (op *importOp) declarations() error {
+	for {
+		t := op.next()
+		switch t.Kind {
+		case tMark:
+			return nil
+		case tEOF:
+			return op.expected(`"%%"`)
+		case tDirective:
+			if assoc, ok := assocs[t.Text]; ok {
+				var symbols []parlex.Symbol
+				for !op.endOfDecl() {
+					if s, ok := op.symbol(op.next()); ok {
+						symbols = append(symbols, stringsymbol.Symbol(s))
+					}
+				}
+				op.g.AddPrecedence(assoc, symbols...)
+				continue
+			}
+			switch t.Text {
+			case "%start":
+				s, ok := op.symbol(op.next())
+				if !ok {
+					op.cur--
+					return op.expected("a symbol")
+				}
+				op.start = s
+			case "%token":
+				op.tokens()
+			}
+			// the rest of the declaration is skipped
+			for !op.endOfDecl() {
+				op.next()
+			}
+		default:
+			op.cur--
+			return op.expected("a declaration")
+		}
+	}
+}
+
+// tokens records the aliases in a %token declaration, like NUM "number" or
+// NUM 258 "number".
+func (op *importOp) tokens() {
+	name := ""
+	for !op.endOfDecl() {
+		t := op.next()
+		switch t.Kind {
+		case tIdent:
+			name = t.Text
+		case tString:
+			if name != "" {
+				op.aliases[t.Text] = name
+			}
+			name = ""
+		case tNumber:
+		default:
+			name = ""
+		}
+	}
+}
+
+func (op *importOp) ruleSection() error {
+	for {
+		t := op.peek()
+		if t.Kind == tEOF || t.Kind == tMark {
+			return nil
+		}
+		if t.Kind != tIdent {
+			return op.expected("a rule")
+		}
+		op.next()
+		if p := op.next(); p.Kind != tPunct || p.Text != ":" {
+			op.cur--
+			return op.expected(`":"`)
+		}
+		r := op.byNT[t.Text]
+		if r == nil {
+			r = &yaccRule{nt: t.Text}
+			op.byNT[t.Text] = r
+			op.rules = append(op.rules, r)
+		}
+		if err := op.alternatives(r); err != nil {
+			return err
+		}
+	}
+}
+
+func (op *importOp) alternatives(r *yaccRule) error {
+	prod := stringsymbol.Production{}
+	for {
+		t := op.peek()
+		switch {
+		case t.Kind == tPunct && t.Text == "|":
+			r.prods = append(r.prods, prod)
+			prod = stringsymbol.Production{}
+		case t.Kind == tPunct && t.Text == ";":
+			op.next()
+			r.prods = append(r.prods, prod)
+			return nil
+		case t.Kind == tEOF || t.Kind == tMark:
+			r.prods = append(r.prods, prod)
+			return nil
+		case t.Kind == tIdent && op.tkns[op.cur+1].Kind == tPunct && op.tkns[op.cur+1].Text == ":":
+			// yacc does not require the semicolon
+			r.prods = append(r.prods, prod)
+			return nil
+		case t.Kind == tAction:
+		case t.Kind == tDirective:
+			switch t.Text {
+			case "%empty":
+			case "%prec", "%dprec", "%merge":
+				op.next()
+			default:
+				return op.expected("a symbol")
+			}
+		default:
+			s, ok := op.symbol(t)
+			if !ok {
+				return op.expected("a symbol")
+			}
+			prod = append(prod, stringsymbol.Symbol(s))
+		}
+		op.next()
+	}
+}
+
+// Export writes the grammar as a .y file. The first non-terminal is the start
+// symbol. If the grammar is a parlex.PrecedenceGrammar, the precedence of the
+// terminals is declared.
+func Export(g parlex.Grammar) string {
+	nts := g.NonTerminals()
+	isNT := make(map[string]bool, len(nts))
+	for _, nt := range nts {
+		isNT[nt.String()] = true
+	}
+
+	// find the terminals in the order they are used
+	var terms []parlex.Symbol
+	seen := make(map[string]bool)
+	for _, nt := range nts {
+		for i := g.Productions(nt).Iter(); i.Next(); {
+			for j := i.Iter(); j.Next(); {
+				if s := j.Symbol.String(); !isNT[s] && !seen[s] {
+					seen[s] = true
+					terms = append(terms, j.Symbol)
+				}
+			}
+		}
+	}
+
+	var buf strings.Builder
+	buf.WriteString("%{\n%}\n\n")
+	var tokens []string
+	for _, t := range terms {
+		if s := t.String(); isCIdent(s) {
+			tokens = append(tokens, s)
+		}
+	}
+	if len(tokens) > 0 {
+		fmt.Fprintf(&buf, "%%token %s\n", strings.Join(tokens, " "))
+	}
+
+	if pg, ok := g.(parlex.PrecedenceGrammar); ok {
+		levels := make(map[int][]string)
+		lvlAssoc := make(map[int]parlex.Assoc)
+		var lvls []int
+		for _, t := range terms {
+			level, assoc := pg.Precedence(t)
+			if level == 0 {
+				continue
+			}
+			if _, ok := levels[level]; !ok {
+				lvls = append(lvls, level)
+			}
+			levels[level] = append(levels[level], symbolString(t.String()))
+			lvlAssoc[level] = assoc
+		}
+		sort.Ints(lvls)
+		for _, l := range lvls {
+			fmt.Fprintf(&buf, "%s %s\n", assocDirective(lvlAssoc[l]), strings.Join(levels[l], " "))
+		}
+	}
+
+	if len(nts) > 0 {
+		fmt.Fprintf(&buf, "\n%%start %s\n", nts[0])
+	}
+	buf.WriteString("\n%%\n")
+	for _, nt := range nts {
+		fmt.Fprintf(&buf, "\n%s\n", nt)
+		sep := ":"
+		for i := g.Productions(nt).Iter(); i.Next(); {
+			var syms []string
+			for j := i.Iter(); j.Next(); {
+				syms = append(syms, symbolString(j.Symbol.String()))
+			}
+			if len(syms) == 0 {
+				syms = append(syms, "/* empty */")
+			}
+			fmt.Fprintf(&buf, "\t%s %s\n", sep, strings.Join(syms, " "))
+			sep = "|"
+		}
+		buf.WriteString("\t;\n")
+	}
+	buf.WriteString("\n%%\n")
+	return buf.String()
+}
+
+func assocDirective(a parlex.Assoc) string {
+	switch a {
+	case parlex.Left:
+		return "%left"
+	case parlex.Right:
+		return "%right"
+	}
+	return "%nonassoc"
+}
+
+func isCIdent(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// symbolString writes a symbol as an identifier, a character literal or a
+// string literal.
+func symbolString(s string) string {
+	switch {
+	case isCIdent(s):
+		return s
+	case len([]rune(s)) == 1 && s != "'" && s != `\`:
+		return "'" + s + "'"
+	case len(s) == 2 && s[0] == '\\':
+		// an escape that was imported from a character literal
+		return "'" + s + "'"
+	}
+	return strconv.Quote(s)
+}
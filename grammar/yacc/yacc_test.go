@@ -0,0 +1,135 @@
+package yacc
+
+import (
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const calc = `
+%{
+#include <stdio.h>
+int yylex(void);
+%}
+
+%union { int val; }
+%token <val> NUM
+%token POW "**"
+%left '+' '-'
+%left '*' '/'
+%right POW
+%nonassoc UMINUS
+%start lines
+
+%%
+
+lines : /* empty */
+      | lines expr '\n' { printf("%d\n", $2); }
+      ;
+
+expr : expr '+' expr { $$ = $1 + $3; }
+     | expr '-' expr { $$ = $1 - $3; }
+     | expr '*' expr
+     | expr '/' expr
+     | expr "**" expr
+     | '-' expr %prec UMINUS { $$ = -$2; }
+     | '(' expr ')' { $$ = $2; /* } */ }
+     | NUM
+num_list: %empty
+        | num_list NUM
+%%
+
+int main(void) { return yyparse(); }
+`
+
+func TestImport(t *testing.T) {
+	g, err := Import(calc)
+	assert.NoError(t, err)
+	expected, err := grammar.New(`
+%left + -
+%left * /
+%right POW
+%nonassoc UMINUS
+lines    ->
+         -> lines expr \n
+expr     -> expr + expr
+         -> expr - expr
+         -> expr * expr
+         -> expr / expr
+         -> expr POW expr
+         -> - expr
+         -> ( expr )
+         -> NUM
+num_list ->
+         -> num_list NUM`)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), g.String())
+}
+
+func TestImportErrors(t *testing.T) {
+	tt := map[string]string{
+		"%token A\nE : A":       `Bad Grammar: expected "%%", got end of file at 2:6`,
+		"%%\nE : A ) B ;":       `Bad Grammar: unexpected ')' at 2:7`,
+		"%%\nE : A { B ;":       `Bad Grammar: unterminated action at 2:12`,
+		"%%\n'a' : B ;":         `Bad Grammar: expected a rule, got "a" at 2:1`,
+		"%%\nE A : B ;":         `Bad Grammar: expected ":", got "A" at 2:3`,
+		"%start F\n%%\nE : A ;": `Bad Grammar: start symbol F has no rules`,
+		"%%\n%%":                `Bad Grammar: no rules`,
+		"%%\nE : A %union B ;":  `Bad Grammar: expected a symbol, got "%union" at 2:7`,
+		"%%\nE : A /* B ;":      `Bad Grammar: missing "*/" at 2:13`,
+		"%%\nE : 'A ;":          `Bad Grammar: missing '\'' at 2:9`,
+	}
+	for src, msg := range tt {
+		_, err := Import(src)
+		if assert.Error(t, err, src) {
+			assert.Equal(t, msg, err.Error(), src)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	g, err := grammar.New(`
+    %left + -
+    %left * /
+    E -> E + E
+      -> E - E
+      -> E * E
+      -> E / E
+      -> ( E )
+      -> int
+      -> E == E
+      ->
+  `)
+	assert.NoError(t, err)
+
+	expected := `%{
+%}
+
+%token int
+%left '+' '-'
+%left '*' '/'
+
+%start E
+
+%%
+
+E
+	: E '+' E
+	| E '-' E
+	| E '*' E
+	| E '/' E
+	| '(' E ')'
+	| int
+	| E "==" E
+	| /* empty */
+	;
+
+%%
+`
+	out := Export(g)
+	assert.Equal(t, expected, out)
+
+	g2, err := Import(out)
+	assert.NoError(t, err)
+	assert.Equal(t, g.String(), g2.String())
+}
@@ -49,6 +49,59 @@ func LexemeList(ls []Lexeme) string {
 	return strings.Join(strs, "\n")
 }
 
+// Offset returns the byte offset of a Lexeme if it fulfills LexemePos. If it
+// does not, -1 is returned.
+func Offset(l Lexeme) int {
+	if lp, ok := l.(LexemePos); ok {
+		return lp.Offset()
+	}
+	return -1
+}
+
+// ProductionOf returns the index of the production that produced a node if it
+// fulfills ProductionNode. If it does not, -1 is returned.
+func ProductionOf(node ParseNode) int {
+	if pn, ok := node.(ProductionNode); ok {
+		return pn.Production()
+	}
+	return -1
+}
+
+// Leading returns the leading trivia of a Lexeme if it fulfills LexemeTrivia.
+// If it does not, nil is returned.
+func Leading(l Lexeme) []Lexeme {
+	if lt, ok := l.(LexemeTrivia); ok {
+		return lt.Leading()
+	}
+	return nil
+}
+
+// Trailing returns the trailing trivia of a Lexeme if it fulfills
+// LexemeTrivia. If it does not, nil is returned.
+func Trailing(l Lexeme) []Lexeme {
+	if lt, ok := l.(LexemeTrivia); ok {
+		return lt.Trailing()
+	}
+	return nil
+}
+
+// ProductionPrecedence returns the precedence of a production, which is the
+// precedence of the last terminal in the production that has one. If the
+// grammar is not a PrecedenceGrammar or no terminal has a precedence, the
+// level is 0.
+func ProductionPrecedence(grammar Grammar, production Production) (int, Assoc) {
+	pg, ok := grammar.(PrecedenceGrammar)
+	if !ok {
+		return 0, NonAssoc
+	}
+	for i := production.Symbols() - 1; i >= 0; i-- {
+		if level, assoc := pg.Precedence(production.Symbol(i)); level > 0 {
+			return level, assoc
+		}
+	}
+	return 0, NonAssoc
+}
+
 // MustParser consumes the error from a parser constructor and panics if it is
 // not nil.
 func MustParser(p Parser, err error) Parser {
@@ -168,3 +221,84 @@ func (p *ProductionsIterator) Next() bool {
 	p.Production = p.Productions.Production(p.Idx)
 	return true
 }
+
+// GrammarIterator is used to iterate over every production in a grammar, in
+// the order of the non-terminals.
+type GrammarIterator struct {
+	*ProductionsIterator
+	NonTerminal Symbol
+	Grammar     Grammar
+	nts         []Symbol
+	ntIdx       int
+}
+
+// IterGrammar returns a GrammarIterator for a grammar.
+func IterGrammar(grammar Grammar) *GrammarIterator {
+	return &GrammarIterator{
+		Grammar: grammar,
+		nts:     grammar.NonTerminals(),
+		ntIdx:   -1,
+	}
+}
+
+// Next moves Production to the next production in the grammar and sets
+// NonTerminal to the non-terminal it belongs to. It returns false if there are
+// no more productions.
+func (g *GrammarIterator) Next() bool {
+	for g.ProductionsIterator == nil || !g.ProductionsIterator.Next() {
+		g.ntIdx++
+		if g.ntIdx >= len(g.nts) {
+			return false
+		}
+		g.NonTerminal = g.nts[g.ntIdx]
+		g.ProductionsIterator = &ProductionsIterator{
+			Productions: g.Grammar.Productions(g.NonTerminal),
+		}
+	}
+	return true
+}
+
+// StartSymbol returns the start symbol of a grammar, which is the first
+// non-terminal. If there are no non-terminals, nil is returned.
+func StartSymbol(grammar Grammar) Symbol {
+	if ig, ok := grammar.(IntrospectGrammar); ok {
+		return ig.Start()
+	}
+	nts := grammar.NonTerminals()
+	if len(nts) == 0 {
+		return nil
+	}
+	return nts[0]
+}
+
+// IsTerminal returns true if the symbol is not a non-terminal in the grammar.
+func IsTerminal(grammar Grammar, symbol Symbol) bool {
+	if ig, ok := grammar.(IntrospectGrammar); ok {
+		return ig.IsTerminal(symbol)
+	}
+	prods := grammar.Productions(symbol)
+	return prods == nil || prods.Productions() == 0
+}
+
+// Terminals returns the terminals used in the productions of a grammar in the
+// order they first appear.
+func Terminals(grammar Grammar) []Symbol {
+	if ig, ok := grammar.(IntrospectGrammar); ok {
+		return ig.Terminals()
+	}
+	nts := make(map[string]bool)
+	for _, nt := range grammar.NonTerminals() {
+		nts[nt.String()] = true
+	}
+	var terminals []Symbol
+	seen := make(map[string]bool)
+	for i := IterGrammar(grammar); i.Next(); {
+		for j := i.Iter(); j.Next(); {
+			if str := j.String(); !nts[str] && !seen[str] {
+				seen[str] = true
+				terminals = append(terminals, j.Symbol)
+			}
+		}
+	}
+	return terminals
+}
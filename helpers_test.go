@@ -1,6 +1,8 @@
 package parlex
 
 import (
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -157,3 +159,58 @@ func TestLexErrors(t *testing.T) {
 		assert.Equal(t, "2", errs[0].Value())
 	}
 }
+
+func TestParseError(t *testing.T) {
+	lxs := []Lexeme{
+		&lx{k: "int", v: "1"},
+		&lx{k: "int", v: "2"},
+	}
+
+	err := NewParseError(1, lxs, []Symbol{symbol("op"), symbol("("), symbol("op")})
+	assert.Equal(t, 1, err.Pos)
+	assert.Equal(t, []Symbol{symbol("("), symbol("op")}, err.Expected)
+	assert.Equal(t, "Could Not Parse) found int: 2, expected (, op", err.Error())
+	assert.True(t, errors.Is(err, ErrCouldNotParse))
+
+	err = NewParseError(2, lxs, nil)
+	assert.Nil(t, err.Lexeme)
+	assert.Equal(t, "Could Not Parse) found end of input, expected end of input", err.Error())
+}
+
+func TestIsIncomplete(t *testing.T) {
+	lxs := []Lexeme{
+		&lx{k: "int", v: "1"},
+		&lx{k: "op", v: "+"},
+	}
+	assert.True(t, IsIncomplete(NewParseError(2, lxs, []Symbol{symbol("int")})))
+	assert.True(t, IsIncomplete(fmt.Errorf("line 1: %w", NewParseError(2, lxs, []Symbol{symbol("int")}))))
+	assert.False(t, IsIncomplete(NewParseError(1, lxs, []Symbol{symbol("int")})))
+	assert.False(t, IsIncomplete(NewParseError(2, lxs, nil)))
+	assert.False(t, IsIncomplete(ErrCouldNotParse))
+	assert.False(t, IsIncomplete(nil))
+}
+
+func TestIntrospect(t *testing.T) {
+	g := &testGrammar{}
+	g.reset()
+	assert.Nil(t, StartSymbol(g))
+	assert.False(t, IterGrammar(g).Next())
+
+	g.new("E")
+	g.add("E", "op", "T")
+	g.add("T")
+	g.new("T")
+	g.add("lp", "E", "rp")
+	g.add("int")
+
+	assert.Equal(t, symbol("E"), StartSymbol(g))
+	assert.True(t, IsTerminal(g, symbol("op")))
+	assert.False(t, IsTerminal(g, symbol("T")))
+	assert.Equal(t, []Symbol{symbol("op"), symbol("lp"), symbol("rp"), symbol("int")}, Terminals(g))
+
+	var prods []string
+	for i := IterGrammar(g); i.Next(); {
+		prods = append(prods, i.NonTerminal.String()+fmt.Sprint(i.Idx, i.Symbols()))
+	}
+	assert.Equal(t, []string{"E0 3", "E1 1", "T0 3", "T1 1"}, prods)
+}
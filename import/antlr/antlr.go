@@ -0,0 +1,265 @@
+// Package antlr imports ANTLR 4 grammars.
+//
+// Parser rules become a regexgram grammar and lexer rules become simplelexer
+// rules. Literals used in parser rules become lexer rules that are defined
+// before the others, unless a lexer rule matches exactly that literal, which
+// is the same as ANTLR.
+//
+// Actions, labels, options and element options are ignored because they only
+// change the code ANTLR generates. The skip and channel commands discard a
+// lexeme and pushMode and popMode are supported with lexer modes. Semantic
+// predicates, rule arguments and return values, tokens blocks, imports and
+// the more, type and mode commands cannot be represented and return an
+// *Unsupported error.
+//
+// ANTLR resolves ambiguity in left recursive rules by the order of the
+// alternatives and the assoc element option, parlex parsers may resolve it
+// differently.
+package antlr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/regexgram"
+	"github.com/adamcolton/parlex/internal/textscan"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"strings"
+)
+
+// Grammar is an imported grammar.
+type Grammar struct {
+	Name string
+	// Grammar is nil for a lexer grammar
+	Grammar *grammar.Grammar
+	// Reducer removes the symbols regexgram adds for groups and repetition
+	Reducer tree.Reducer
+	// Lexer is nil for a parser grammar
+	Lexer *simplelexer.Lexer
+	// GrammarRules and LexerRules are the definitions the Grammar and Lexer
+	// were built from.
+	GrammarRules, LexerRules string
+}
+
+// Import converts the source of a .g4 file.
+func Import(src string) (*Grammar, error) {
+	g4, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	op := &importOp{
+		g4:       g4,
+		rules:    make(map[string]*rule),
+		literals: make(map[string]string),
+	}
+	for _, r := range g4.rules {
+		if _, ok := op.rules[r.name]; ok {
+			return nil, fmt.Errorf("Duplicate Rule: %s at %d:%d", r.name, r.line, r.col)
+		}
+		op.rules[r.name] = r
+	}
+
+	g := &Grammar{
+		Name: g4.name,
+	}
+	if g4.kind != "lexer" {
+		if g.GrammarRules, err = op.grammarRules(); err != nil {
+			return nil, err
+		}
+		if g.Grammar, g.Reducer, err = regexgram.New(g.GrammarRules); err != nil {
+			return nil, err
+		}
+	}
+	if g4.kind != "parser" {
+		if g.LexerRules, err = op.lexerRules(); err != nil {
+			return nil, err
+		}
+		if g.Lexer, err = simplelexer.New(g.LexerRules); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+type importOp struct {
+	g4    *g4
+	rules map[string]*rule
+	// literals maps the text of a literal in a parser rule to the token that
+	// matches it and implicit holds the literals that need a token
+	literals map[string]string
+	implicit []string
+}
+
+func (op *importOp) grammarRules() (string, error) {
+	// lexer rules that are exactly a literal are used for that literal
+	for _, r := range op.g4.rules {
+		if !r.isLexer() || r.fragment || r.mode != "" || len(r.alts) != 1 || len(r.alts[0].cmds) > 0 {
+			continue
+		}
+		if es := r.alts[0].elems; len(es) == 1 && es[0].kind == eLiteral && es[0].suffix == "" {
+			if _, ok := op.literals[es[0].text]; !ok {
+				op.literals[es[0].text] = r.name
+			}
+		}
+	}
+
+	var buf strings.Builder
+	for _, r := range op.g4.rules {
+		if r.isLexer() {
+			continue
+		}
+		for i, a := range r.alts {
+			syms, err := op.symbols(a)
+			if err != nil {
+				return "", err
+			}
+			if i == 0 {
+				buf.WriteString(r.name)
+			} else {
+				buf.WriteString(strings.Repeat(" ", len(r.name)))
+			}
+			buf.WriteString(" -> ")
+			buf.WriteString(strings.Join(syms, " "))
+			buf.WriteString("\n")
+		}
+	}
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("Bad Grammar: no parser rules in %s", op.g4.name)
+	}
+	return buf.String(), nil
+}
+
+func (op *importOp) symbols(a *alt) ([]string, error) {
+	var syms []string
+	for _, e := range a.elems {
+		s, err := op.symbol(e)
+		if err != nil {
+			return nil, err
+		}
+		if s != "" {
+			syms = append(syms, s)
+		}
+	}
+	return syms, nil
+}
+
+func (op *importOp) symbol(e *elem) (string, error) {
+	var s string
+	suffix := e.suffix
+	switch e.kind {
+	case eRef:
+		if e.text == "EOF" {
+			// the parsers always consume all the lexemes
+			return "", nil
+		}
+		r, ok := op.rules[e.text]
+		if ok && r.fragment {
+			return "", fmt.Errorf("Undefined Token: %s is a fragment at %d:%d", e.text, e.line, e.col)
+		}
+		if !ok && !(op.g4.kind == "parser" && isToken(e.text)) {
+			return "", fmt.Errorf("Undefined Rule: %s at %d:%d", e.text, e.line, e.col)
+		}
+		s = e.text
+	case eLiteral:
+		if op.g4.kind == "parser" {
+			return "", &Unsupported{Construct: "literal in a parser grammar", Line: e.line, Col: e.col}
+		}
+		name, ok := op.literals[e.text]
+		if !ok {
+			name = fmt.Sprintf("T__%d", len(op.implicit))
+			op.literals[e.text] = name
+			op.implicit = append(op.implicit, e.text)
+		}
+		s = name
+	case eBlock:
+		var alts []string
+		empty := false
+		for _, a := range e.block {
+			syms, err := op.symbols(a)
+			if err != nil {
+				return "", err
+			}
+			switch len(syms) {
+			case 0:
+				empty = true
+			case 1:
+				alts = append(alts, syms[0])
+			default:
+				alts = append(alts, "("+strings.Join(syms, " ")+")")
+			}
+		}
+		if len(alts) == 0 {
+			return "", nil
+		}
+		if empty {
+			switch suffix {
+			case "":
+				suffix = "?"
+			case "+":
+				suffix = "*"
+			}
+		}
+		s = "(" + strings.Join(alts, " | ") + ")"
+	case eWildcard:
+		return "", &Unsupported{Construct: "wildcard in a parser rule", Line: e.line, Col: e.col}
+	default:
+		return "", &Unsupported{Construct: "character set in a parser rule", Line: e.line, Col: e.col}
+	}
+	return s + suffix, nil
+}
+
+func (op *importOp) lexerRules() (string, error) {
+	lg := &lexGen{
+		rules: op.rules,
+		stack: make(map[string]bool),
+	}
+	var buf strings.Builder
+	for i, l := range op.implicit {
+		s, err := unquote(l)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "T__%d /%s/\n", i, textscan.QuoteString(s))
+	}
+	for _, r := range op.g4.rules {
+		if !r.isLexer() || r.fragment {
+			continue
+		}
+		re, err := lg.rule(r)
+		if err != nil {
+			return "", err
+		}
+		if r.mode != "" && r.mode != "DEFAULT_MODE" {
+			fmt.Fprintf(&buf, "<%s> ", r.mode)
+		}
+		fmt.Fprintf(&buf, "%s /%s/", r.name, re)
+		cmds, err := ruleCommands(r)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(cmds)
+		buf.WriteString("\n")
+	}
+	return buf.String(), nil
+}
+
+// ruleCommands converts the commands of a rule to a simplelexer discard and
+// transition.
+func ruleCommands(r *rule) (string, error) {
+	var discard, transition string
+	for _, a := range r.alts {
+		for _, c := range a.cmds {
+			switch c.name {
+			case "skip", "channel":
+				discard = " -"
+			case "pushMode":
+				transition = " push(" + c.arg + ")"
+			case "popMode":
+				transition = " pop"
+			default:
+				return "", &Unsupported{Construct: c.name + " command", Line: c.line, Col: c.col}
+			}
+		}
+	}
+	return discard + transition, nil
+}
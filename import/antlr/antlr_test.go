@@ -0,0 +1,140 @@
+package antlr
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const calc = `
+/** A calculator */
+grammar Calc;
+
+options { language = Go; }
+
+@header {
+  import "fmt"
+}
+
+prog : stat+ EOF ;
+
+stat : expr NEWLINE            # printExpr
+     | ID '=' expr NEWLINE     # assign
+     | NEWLINE                 # blank
+     ;
+
+expr : expr op=('*'|'/') expr
+     | expr op=(ADD|'-') expr
+     | INT
+     | ID
+     | '(' expr ')'
+     | FUNC '(' (expr (',' expr)*)? ')' {fmt.Println("call")}
+     ;
+
+ADD     : '+' ;
+FUNC    : 'max' | 'min' ;
+ID      : LETTER (LETTER | DIGIT)* ;
+INT     : DIGIT+ ;
+NEWLINE : '\r'? '\n' ;
+WS      : [ \t]+ -> skip ;
+COMMENT : '/*' .*? '*/' -> channel(HIDDEN) ;
+
+fragment LETTER : [a-zA-Z_] ;
+fragment DIGIT  : '0'..'9' ;
+`
+
+func TestImport(t *testing.T) {
+	g, err := Import(calc)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Calc", g.Name)
+	assert.Equal(t, `T__0 /=/
+T__1 /\*/
+T__2 /\//
+T__3 /-/
+T__4 /\(/
+T__5 /\)/
+T__6 /,/
+ADD /\+/
+FUNC /max|min/
+ID /(?:[a-zA-Z_])(?:(?:[a-zA-Z_])|(?:[0-9]))*/
+INT /(?:[0-9])+/
+NEWLINE /\r?\n/
+WS /[ \t]+/ -
+COMMENT /\/\*(?s:.)*?\*\// -
+`, g.LexerRules)
+
+	lxms := g.Lexer.Lex("x = max(1, 2)*3 /* comment */\nx+4\n")
+	assert.Equal(t, []string{"ID", "T__0", "FUNC", "T__4", "INT", "T__6", "INT", "T__5", "T__1", "INT", "NEWLINE", "ID", "ADD", "INT", "NEWLINE"}, kinds(lxms))
+
+	prsr := packrat.New(g.Grammar)
+	pn, err := prsr.ParseErr(lxms)
+	assert.NoError(t, err)
+	assert.NotNil(t, g.Reducer.Reduce(pn))
+
+	_, err = prsr.ParseErr(g.Lexer.Lex("x = \n"))
+	assert.Error(t, err)
+}
+
+func TestImportModes(t *testing.T) {
+	g, err := Import(`
+lexer grammar Str;
+QUOTE : '"' -> pushMode(STR) ;
+WORD  : ~[ "]+ ;
+SPACE : ' ' -> skip ;
+
+mode STR;
+END   : '"' -> popMode ;
+TEXT  : ~'"'+ ;
+ESC   : '\\' . ;
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Nil(t, g.Grammar)
+	lxms := g.Lexer.Lex(`a "b c" d`)
+	assert.Equal(t, []string{"WORD", "QUOTE", "TEXT", "END", "WORD"}, kinds(lxms))
+}
+
+func kinds(lxms []parlex.Lexeme) []string {
+	ks := make([]string, len(lxms))
+	for i, l := range lxms {
+		ks[i] = l.Kind().String()
+	}
+	return ks
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := map[string]string{
+		"predicate":  "grammar A; a : {p}? B ; B : 'b' ;",
+		"args":       "grammar A; a[int x] : B ; B : 'b' ;",
+		"tokens":     "grammar A; tokens { X } a : B ; B : 'b' ;",
+		"more":       "grammar A; a : B ; B : 'b' -> more ;",
+		"undefined":  "grammar A; a : C ; B : 'b' ;",
+		"recursive":  "grammar A; a : B ; B : 'b' B? ;",
+		"not":        "grammar A; a : B ; B : ~('ab') ;",
+		"syntax":     "grammar A; a : B ",
+		"no grammar": "a : B ;",
+		"escape":     `grammar A; a : B ; B : '\q' ;`,
+	}
+	expected := map[string]string{
+		"predicate":  "Unsupported Construct: semantic predicate at 1:16",
+		"args":       "Unsupported Construct: rule arguments at 1:13",
+		"tokens":     "Unsupported Construct: tokens block at 1:12",
+		"more":       "Unsupported Construct: more command at 1:31",
+		"undefined":  "Undefined Rule: C at 1:16",
+		"recursive":  "Unsupported Construct: recursive lexer rule B at 1:20",
+		"not":        "Unsupported Construct: ~ of more than one character at 1:26",
+		"syntax":     `Bad Grammar: expected ";", got end of file at 1:18`,
+		"no grammar": `Bad Grammar: expected "grammar", got "a" at 1:1`,
+		"escape":     `Bad Escape: \q`,
+	}
+	for n, src := range tests {
+		t.Run(n, func(t *testing.T) {
+			_, err := Import(src)
+			assert.EqualError(t, err, expected[n])
+		})
+	}
+}
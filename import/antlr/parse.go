@@ -0,0 +1,410 @@
+package antlr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/internal/textscan"
+	"unicode"
+)
+
+type elemKind byte
+
+const (
+	eRef elemKind = iota
+	eLiteral
+	eSet
+	eRange
+	eWildcard
+	eNot
+	eBlock
+)
+
+type elem struct {
+	kind elemKind
+	// text is the name of a reference, the raw literal or the raw contents
+	// of a set; for a range it is the raw start
+	text string
+	// to is the raw end of a range
+	to    string
+	not   *elem
+	block []*alt
+	// suffix is ?, * or + and lazy is set if it was followed by ?
+	suffix    string
+	lazy      bool
+	line, col int
+}
+
+type command struct {
+	name, arg string
+	line, col int
+}
+
+type alt struct {
+	elems []*elem
+	cmds  []command
+}
+
+type rule struct {
+	name      string
+	fragment  bool
+	mode      string
+	alts      []*alt
+	line, col int
+}
+
+func (r *rule) isLexer() bool { return isToken(r.name) }
+
+// isToken returns true for names that start with an upper case letter, which
+// ANTLR uses for tokens and lexer rules.
+func isToken(name string) bool {
+	return unicode.IsUpper([]rune(name)[0])
+}
+
+type g4 struct {
+	name  string
+	kind  string // "lexer", "parser" or "" for a combined grammar
+	rules []*rule
+}
+
+type parser struct {
+	tkns []textscan.Token
+	cur  int
+	mode string
+	g    *g4
+}
+
+// Unsupported is returned when a grammar uses a construct that cannot be
+// represented.
+type Unsupported struct {
+	Construct string
+	Line, Col int
+}
+
+// Error fulfills error.
+func (u *Unsupported) Error() string {
+	return fmt.Sprintf("Unsupported Construct: %s at %d:%d", u.Construct, u.Line, u.Col)
+}
+
+func parse(src string) (*g4, error) {
+	tkns, err := scan(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		tkns: tkns,
+		g:    &g4{},
+	}
+	return p.g, p.grammarSpec()
+}
+
+func (p *parser) peek() textscan.Token { return p.tkns[p.cur] }
+
+func (p *parser) next() textscan.Token {
+	t := p.tkns[p.cur]
+	if t.Kind != tEOF {
+		p.cur++
+	}
+	return t
+}
+
+func (p *parser) is(kind textscan.Kind, text string) bool {
+	t := p.peek()
+	return t.Kind == kind && t.Text == text
+}
+
+func (p *parser) isPunct(text string) bool { return p.is(tPunct, text) }
+
+func (p *parser) expected(what string) error {
+	return p.peek().Expected(what)
+}
+
+func (p *parser) unsupported(construct string, t textscan.Token) error {
+	return &Unsupported{
+		Construct: construct,
+		Line:      t.Line,
+		Col:       t.Col,
+	}
+}
+
+func (p *parser) expect(text string) error {
+	if !p.isPunct(text) {
+		return p.expected(fmt.Sprintf("%q", text))
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) ident() (textscan.Token, error) {
+	if p.peek().Kind != tIdent {
+		return textscan.Token{}, p.expected("a name")
+	}
+	return p.next(), nil
+}
+
+func (p *parser) grammarSpec() error {
+	if p.is(tIdent, "lexer") || p.is(tIdent, "parser") {
+		p.g.kind = p.next().Text
+	}
+	if !p.is(tIdent, "grammar") {
+		return p.expected(`"grammar"`)
+	}
+	p.next()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	p.g.name = name.Text
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	for p.peek().Kind != tEOF {
+		t := p.peek()
+		switch {
+		case p.is(tIdent, "options") || p.is(tIdent, "channels"):
+			// options only change the generated code and channels are
+			// treated as skip
+			p.next()
+			if p.next().Kind != tAction {
+				p.cur--
+				return p.expected("a block")
+			}
+		case p.is(tIdent, "tokens"):
+			return p.unsupported("tokens block", t)
+		case p.is(tIdent, "import"):
+			return p.unsupported("import", t)
+		case p.isPunct("@"):
+			// named actions like @header hold target code
+			p.next()
+			for p.peek().Kind == tIdent || p.isPunct(":") {
+				p.next()
+			}
+			if p.next().Kind != tAction {
+				p.cur--
+				return p.expected("an action")
+			}
+		case p.is(tIdent, "mode"):
+			p.next()
+			name, err := p.ident()
+			if err != nil {
+				return err
+			}
+			p.mode = name.Text
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		default:
+			if err := p.rule(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *parser) rule() error {
+	r := &rule{
+		mode: p.mode,
+	}
+	if p.is(tIdent, "fragment") {
+		p.next()
+		r.fragment = true
+	}
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	r.name, r.line, r.col = name.Text, name.Line, name.Col
+
+	for !p.isPunct(":") {
+		t := p.peek()
+		switch {
+		case t.Kind == tBrack:
+			return p.unsupported("rule arguments", t)
+		case p.is(tIdent, "returns"), p.is(tIdent, "locals"), p.is(tIdent, "throws"):
+			return p.unsupported(t.Text, t)
+		case p.is(tIdent, "options"):
+			p.next()
+			if p.next().Kind != tAction {
+				p.cur--
+				return p.expected("a block")
+			}
+		case p.isPunct("@"):
+			p.next()
+			if _, err := p.ident(); err != nil {
+				return err
+			}
+			if p.next().Kind != tAction {
+				p.cur--
+				return p.expected("an action")
+			}
+		default:
+			return p.expected(`":"`)
+		}
+	}
+	p.next()
+
+	r.alts, err = p.alts()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+	if t := p.peek(); p.is(tIdent, "catch") || p.is(tIdent, "finally") {
+		return p.unsupported("exception handler", t)
+	}
+	p.g.rules = append(p.g.rules, r)
+	return nil
+}
+
+func (p *parser) alts() ([]*alt, error) {
+	var alts []*alt
+	for {
+		a, err := p.alt()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, a)
+		if !p.isPunct("|") {
+			return alts, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) alt() (*alt, error) {
+	a := &alt{}
+	for {
+		t := p.peek()
+		switch {
+		case t.Kind == tEOF || p.isPunct("|") || p.isPunct(";") || p.isPunct(")"):
+			return a, nil
+		case p.isPunct("#"):
+			// alternative labels only name the generated context
+			p.next()
+			if _, err := p.ident(); err != nil {
+				return nil, err
+			}
+		case p.isPunct("->"):
+			p.next()
+			cmds, err := p.commands()
+			if err != nil {
+				return nil, err
+			}
+			a.cmds = cmds
+		case t.Kind == tAction || t.Kind == tOpts:
+			// actions are ignored and element options only affect how
+			// ambiguity is resolved in the generated parser
+			p.next()
+		case t.Kind == tPred:
+			return nil, p.unsupported("semantic predicate", t)
+		default:
+			e, err := p.element()
+			if err != nil {
+				return nil, err
+			}
+			a.elems = append(a.elems, e)
+		}
+	}
+}
+
+func (p *parser) commands() ([]command, error) {
+	var cmds []command
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		c := command{
+			name: name.Text,
+			line: name.Line,
+			col:  name.Col,
+		}
+		if p.isPunct("(") {
+			p.next()
+			arg, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			c.arg = arg.Text
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+		cmds = append(cmds, c)
+		if !p.isPunct(",") {
+			return cmds, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) element() (*elem, error) {
+	// labels only name the field in the generated context
+	if p.peek().Kind == tIdent && p.cur+1 < len(p.tkns) {
+		if n := p.tkns[p.cur+1]; n.Kind == tPunct && (n.Text == "=" || n.Text == "+=") {
+			p.cur += 2
+		}
+	}
+	e, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("?") || p.isPunct("*") || p.isPunct("+") {
+		e.suffix = p.next().Text
+		if p.isPunct("?") {
+			p.next()
+			e.lazy = true
+		}
+	}
+	return e, nil
+}
+
+func (p *parser) atom() (*elem, error) {
+	t := p.peek()
+	e := &elem{
+		line: t.Line,
+		col:  t.Col,
+	}
+	switch {
+	case t.Kind == tIdent:
+		e.kind, e.text = eRef, p.next().Text
+	case t.Kind == tString:
+		e.kind, e.text = eLiteral, p.next().Text
+		if p.isPunct("..") {
+			p.next()
+			if p.peek().Kind != tString {
+				return nil, p.expected("a string")
+			}
+			e.kind, e.to = eRange, p.next().Text
+		}
+	case t.Kind == tBrack:
+		e.kind, e.text = eSet, p.next().Text
+	case p.isPunct("."):
+		p.next()
+		e.kind = eWildcard
+	case p.isPunct("~"):
+		p.next()
+		not, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		e.kind, e.not = eNot, not
+	case p.isPunct("("):
+		p.next()
+		if p.is(tIdent, "options") {
+			return nil, p.unsupported("block options", p.peek())
+		}
+		alts, err := p.alts()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		e.kind, e.block = eBlock, alts
+	default:
+		return nil, p.expected("an element")
+	}
+	return e, nil
+}
@@ -0,0 +1,254 @@
+package antlr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/internal/textscan"
+	"strconv"
+	"strings"
+)
+
+// setItem is a rune, a range of runes or a unicode property in a set.
+type setItem struct {
+	lo, hi rune
+	prop   string
+}
+
+// unescape reads one rune from raw, handling the escapes ANTLR allows. If the
+// escape is a unicode property, it is returned as prop.
+func unescape(raw []rune) (r rune, prop string, n int, err error) {
+	if raw[0] != '\\' {
+		return raw[0], "", 1, nil
+	}
+	if len(raw) < 2 {
+		return 0, "", 0, fmt.Errorf("Bad Escape: %q", string(raw))
+	}
+	switch raw[1] {
+	case 'n':
+		return '\n', "", 2, nil
+	case 'r':
+		return '\r', "", 2, nil
+	case 't':
+		return '\t', "", 2, nil
+	case 'b':
+		return '\b', "", 2, nil
+	case 'f':
+		return '\f', "", 2, nil
+	case '\\', '\'', ']', '-', '"':
+		return raw[1], "", 2, nil
+	case 'u':
+		hex, n := string(raw[2:min(6, len(raw))]), 6
+		if len(raw) > 2 && raw[2] == '{' {
+			end := indexRune(raw, '}')
+			if end < 0 {
+				return 0, "", 0, fmt.Errorf("Bad Escape: %q", string(raw))
+			}
+			hex, n = string(raw[3:end]), end+1
+		}
+		i, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return 0, "", 0, fmt.Errorf("Bad Escape: \\u%s", hex)
+		}
+		return rune(i), "", n, nil
+	case 'p', 'P':
+		end := indexRune(raw, '}')
+		if len(raw) < 3 || raw[2] != '{' || end < 0 {
+			return 0, "", 0, fmt.Errorf("Bad Escape: %q", string(raw))
+		}
+		return 0, string(raw[:end+1]), end + 1, nil
+	}
+	return 0, "", 0, fmt.Errorf("Bad Escape: \\%c", raw[1])
+}
+
+func indexRune(rs []rune, r rune) int {
+	for i, c := range rs {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote converts the raw text of a literal to a string.
+func unquote(raw string) (string, error) {
+	var buf strings.Builder
+	for rs := []rune(raw); len(rs) > 0; {
+		r, prop, n, err := unescape(rs)
+		if err != nil {
+			return "", err
+		}
+		if prop != "" {
+			return "", fmt.Errorf("Bad Escape: %s", prop)
+		}
+		buf.WriteRune(r)
+		rs = rs[n:]
+	}
+	return buf.String(), nil
+}
+
+func parseSet(raw string) ([]setItem, error) {
+	var items []setItem
+	for rs := []rune(raw); len(rs) > 0; {
+		lo, prop, n, err := unescape(rs)
+		if err != nil {
+			return nil, err
+		}
+		rs = rs[n:]
+		if prop != "" {
+			items = append(items, setItem{prop: prop})
+			continue
+		}
+		hi := lo
+		if len(rs) > 1 && rs[0] == '-' {
+			if hi, _, n, err = unescape(rs[1:]); err != nil {
+				return nil, err
+			}
+			rs = rs[n+1:]
+		}
+		items = append(items, setItem{lo: lo, hi: hi})
+	}
+	return items, nil
+}
+
+func classOf(items []setItem, negate bool) string {
+	var buf strings.Builder
+	buf.WriteRune('[')
+	if negate {
+		buf.WriteRune('^')
+	}
+	for _, i := range items {
+		if i.prop != "" {
+			buf.WriteString(i.prop)
+			continue
+		}
+		buf.WriteString(textscan.QuoteRune(i.lo, true))
+		if i.hi != i.lo {
+			buf.WriteRune('-')
+			buf.WriteString(textscan.QuoteRune(i.hi, true))
+		}
+	}
+	buf.WriteRune(']')
+	return buf.String()
+}
+
+// lexGen converts lexer rules to regular expressions.
+type lexGen struct {
+	rules map[string]*rule
+	// stack holds the rules being converted to catch recursion
+	stack map[string]bool
+}
+
+func (lg *lexGen) rule(r *rule) (string, error) {
+	if lg.stack[r.name] {
+		return "", &Unsupported{Construct: "recursive lexer rule " + r.name, Line: r.line, Col: r.col}
+	}
+	lg.stack[r.name] = true
+	defer delete(lg.stack, r.name)
+	return lg.alts(r.alts)
+}
+
+func (lg *lexGen) alts(alts []*alt) (string, error) {
+	strs := make([]string, len(alts))
+	for i, a := range alts {
+		var buf strings.Builder
+		for _, e := range a.elems {
+			re, err := lg.elem(e)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(re)
+		}
+		strs[i] = buf.String()
+	}
+	return strings.Join(strs, "|"), nil
+}
+
+func (lg *lexGen) elem(e *elem) (string, error) {
+	var re string
+	// single tells if the suffix can be added without a group
+	single := true
+	switch e.kind {
+	case eRef:
+		r, ok := lg.rules[e.text]
+		if !ok || !r.isLexer() {
+			return "", fmt.Errorf("Undefined Token: %s at %d:%d", e.text, e.line, e.col)
+		}
+		sub, err := lg.rule(r)
+		if err != nil {
+			return "", err
+		}
+		re = "(?:" + sub + ")"
+	case eLiteral:
+		s, err := unquote(e.text)
+		if err != nil {
+			return "", err
+		}
+		re, single = textscan.QuoteString(s), len([]rune(s)) == 1
+	case eSet, eRange:
+		items, err := lg.items(e)
+		if err != nil {
+			return "", err
+		}
+		re = classOf(items, false)
+	case eWildcard:
+		re = "(?s:.)"
+	case eNot:
+		items, err := lg.items(e.not)
+		if err != nil {
+			return "", err
+		}
+		re = classOf(items, true)
+	case eBlock:
+		sub, err := lg.alts(e.block)
+		if err != nil {
+			return "", err
+		}
+		re = "(?:" + sub + ")"
+	}
+	if e.suffix == "" {
+		return re, nil
+	}
+	if !single {
+		re = "(?:" + re + ")"
+	}
+	re += e.suffix
+	if e.lazy {
+		re += "?"
+	}
+	return re, nil
+}
+
+// items returns the runes matched by an element that matches a single rune.
+func (lg *lexGen) items(e *elem) ([]setItem, error) {
+	switch e.kind {
+	case eSet:
+		return parseSet(e.text)
+	case eRange, eLiteral:
+		lo, err := unquote(e.text)
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if e.kind == eRange {
+			if hi, err = unquote(e.to); err != nil {
+				return nil, err
+			}
+		}
+		if l, h := []rune(lo), []rune(hi); len(l) == 1 && len(h) == 1 {
+			return []setItem{{lo: l[0], hi: h[0]}}, nil
+		}
+	case eBlock:
+		var items []setItem
+		for _, a := range e.block {
+			if len(a.elems) != 1 || a.elems[0].suffix != "" {
+				return nil, &Unsupported{Construct: "~ of more than one character", Line: e.line, Col: e.col}
+			}
+			is, err := lg.items(a.elems[0])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, is...)
+		}
+		return items, nil
+	}
+	return nil, &Unsupported{Construct: "~ of more than one character", Line: e.line, Col: e.col}
+}
@@ -0,0 +1,79 @@
+package antlr
+
+import (
+	"github.com/adamcolton/parlex/internal/textscan"
+	"unicode"
+)
+
+const (
+	tEOF   = textscan.EOF
+	tIdent = textscan.Kind(iota)
+	tString
+	tBrack  // [...] a character set or rule arguments
+	tAction // {...}
+	tPred   // {...}?
+	tOpts   // <...>
+	tPunct
+)
+
+// scanner splits a .g4 file into tokens. The contents of strings, brackets,
+// actions and element options are kept raw.
+type scanner struct {
+	*textscan.Scanner
+}
+
+// puncts is ordered so that the two character tokens are checked first
+var puncts = []string{"->", "..", "+=", ":", ";", "|", "(", ")", "?", "*", "+", "~", ".", ",", "=", "#", "@"}
+
+func scan(src string) ([]textscan.Token, error) {
+	s := &scanner{textscan.New(src)}
+	return textscan.Tokens(s.next)
+}
+
+func (s *scanner) next() (textscan.Token, error) {
+	if err := s.Skip(); err != nil {
+		return textscan.Token{}, err
+	}
+	t := s.Token(tEOF)
+	r := s.Peek(0)
+	var err error
+	switch {
+	case s.Done():
+	case r == '_' || unicode.IsLetter(r):
+		t.Kind = tIdent
+		n := 1
+		for r := s.Peek(n); r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r); r = s.Peek(n) {
+			n++
+		}
+		t.Text = s.Advance(n)
+	case r == '\'':
+		t.Kind = tString
+		s.Advance(1)
+		t.Text, err = s.Until('\'')
+	case r == '[':
+		t.Kind = tBrack
+		s.Advance(1)
+		t.Text, err = s.Until(']')
+	case r == '<':
+		t.Kind = tOpts
+		s.Advance(1)
+		t.Text, err = s.Until('>')
+	case r == '{':
+		t.Kind = tAction
+		t.Text, err = s.Action()
+		if err == nil && s.Peek(0) == '?' {
+			s.Advance(1)
+			t.Kind = tPred
+		}
+	default:
+		for _, p := range puncts {
+			if s.HasPrefix(p) {
+				t.Kind = tPunct
+				t.Text = s.Advance(len(p))
+				return t, nil
+			}
+		}
+		err = s.Errorf("unexpected %q", r)
+	}
+	return t, err
+}
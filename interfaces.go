@@ -1,5 +1,9 @@
 package parlex
 
+import (
+	"context"
+)
+
 // Symbol is base of a grammar. A symbol should always return the same string.
 // Two symbols that return the same thing are considered to be the same.
 type Symbol interface {
@@ -29,6 +33,24 @@ type Lexeme interface {
 	Pos() (line int, col int)
 }
 
+// LexemePos is an optional extension of Lexeme for lexemes that also know the
+// byte offset where they started in the original string. Offset should return
+// -1 if it is not known.
+type LexemePos interface {
+	Lexeme
+	Offset() int
+}
+
+// LexemeTrivia is an optional extension of Lexeme for lexemes that keep the
+// hidden lexemes around them, like white space and comments, that were not
+// passed to the parser. Leading trivia comes before the lexeme and trailing
+// trivia follows it to the end of the line.
+type LexemeTrivia interface {
+	Lexeme
+	Leading() []Lexeme
+	Trailing() []Lexeme
+}
+
 // Lexer is fulfilled by a type that can convert a string into a slice of
 // Lexemes.
 type Lexer interface {
@@ -43,12 +65,53 @@ type ParseNode interface {
 	Child(int) ParseNode
 }
 
+// ProductionNode is an optional extension of ParseNode for nodes that know
+// which production of their non-terminal produced them. Production should
+// return the index of the production in the grammar or -1 if it is not known.
+type ProductionNode interface {
+	ParseNode
+	Production() int
+}
+
 // Parser takes a slice of Lexemes and returns a ParseNode. If the parse fails,
 // ParseNode will be nil.
 type Parser interface {
 	Parse([]Lexeme) ParseNode
 }
 
+// ErrParser is optionally fulfilled by a Parser that can describe why a parse
+// failed. ParseErr should return either a ParseNode or an error, generally a
+// *ParseError.
+type ErrParser interface {
+	Parser
+	ParseErr([]Lexeme) (ParseNode, error)
+}
+
+// ContextParser is optionally fulfilled by a Parser that can be canceled. If
+// the context is done before the parse finishes, ParseContext should stop and
+// return the context's error.
+type ContextParser interface {
+	Parser
+	ParseContext(context.Context, []Lexeme) (ParseNode, error)
+}
+
+// FromParser is optionally fulfilled by a Parser that can start from any
+// non-terminal in its grammar, not just the start symbol. This is useful for
+// testing a single rule or for parsing a fragment of a language.
+type FromParser interface {
+	Parser
+	ParseFrom(start string, lexemes []Lexeme) (ParseNode, error)
+}
+
+// PrefixParser is optionally fulfilled by a Parser that can parse the longest
+// prefix of the lexemes that can be derived from the start symbol.
+// ParsePrefix should return the tree for the prefix and the lexemes after it.
+// This lets a REPL or protocol framer find where one complete statement ends.
+type PrefixParser interface {
+	Parser
+	ParsePrefix(lexemes []Lexeme) (ParseNode, []Lexeme, error)
+}
+
 // ParserConstructor is a function that takes a Grammar and returns a Parser
 type ParserConstructor func(Grammar) (Parser, error)
 
@@ -58,9 +121,71 @@ type Grammar interface {
 	NonTerminals() []Symbol // The first NonTerminal should be the start symbol
 }
 
+// IntrospectGrammar is optionally fulfilled by a Grammar that can describe its
+// own symbols. Start should return the start symbol, or nil if there are no
+// non-terminals. IsTerminal should return true for any symbol that is not a
+// non-terminal. Terminals should return the terminals used in the
+// productions in the order they first appear. The helpers StartSymbol,
+// IsTerminal and Terminals work with any Grammar and use these methods when
+// they are available.
+type IntrospectGrammar interface {
+	Grammar
+	Start() Symbol
+	IsTerminal(Symbol) bool
+	Terminals() []Symbol
+}
+
+// Assoc is the associativity of an operator.
+type Assoc byte
+
+// Associativity of operators in a PrecedenceGrammar
+const (
+	NonAssoc Assoc = iota
+	Left
+	Right
+)
+
+// PrecedenceGrammar is optionally fulfilled by a Grammar that declares the
+// precedence and associativity of terminals. Precedence should return 0 for a
+// terminal without a precedence, otherwise higher levels bind more tightly.
+type PrecedenceGrammar interface {
+	Grammar
+	Precedence(Symbol) (int, Assoc)
+}
+
+// Predicate is a semantic check on the children of a production. The
+// production only applies if the predicate returns true.
+type Predicate func(children []ParseNode) bool
+
+// PredicateGrammar is optionally fulfilled by a Grammar that attaches
+// predicates to productions. Predicate takes a non-terminal and the index of
+// one of its productions and should return nil if that production does not
+// have a predicate.
+type PredicateGrammar interface {
+	Grammar
+	Predicate(nonterminal Symbol, production int) Predicate
+}
+
+// WeightedGrammar is optionally fulfilled by a Grammar that gives productions
+// weights for generating sentences. Weight takes a non-terminal and the index
+// of one of its productions and returns how likely that production is to be
+// chosen compared to the other productions of the non-terminal. A production
+// without a weight should have a weight of 1.
+type WeightedGrammar interface {
+	Grammar
+	Weight(nonterminal Symbol, production int) float64
+}
+
 // Reducer is used to reduce a ParseTree to something more useful, generally
 // clearing away symbols that are now represeneted by the tree structure.
 type Reducer interface {
 	Reduce(ParseNode) ParseNode
 	Can(ParseNode) bool
 }
+
+// SourceReducer is optionally fulfilled by a Reducer that can use the source
+// string the tree was parsed from, such as to read the text covered by a node.
+type SourceReducer interface {
+	Reducer
+	ReduceSource(node ParseNode, src string) ParseNode
+}
@@ -0,0 +1,205 @@
+// Package bincodec is the compact binary form used to save the tables of the
+// simplelexer and lalr packages. Data starts with a magic string whose last
+// byte is the version of the format, followed by values written as varints.
+// The same values always produce the same bytes, so the output can be checked
+// in and compared.
+package bincodec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Writer appends values to Buf.
+type Writer struct {
+	Buf []byte
+}
+
+// NewWriter returns a Writer that starts with the magic string.
+func NewWriter(magic string) *Writer {
+	return &Writer{Buf: []byte(magic)}
+}
+
+// Int writes an int as a varint.
+func (w *Writer) Int(i int) {
+	var b [binary.MaxVarintLen64]byte
+	w.Buf = append(w.Buf, b[:binary.PutVarint(b[:], int64(i))]...)
+}
+
+// Ints writes the length and then each int.
+func (w *Writer) Ints(is []int) {
+	w.Int(len(is))
+	for _, i := range is {
+		w.Int(i)
+	}
+}
+
+// Str writes the length and then the bytes of a string.
+func (w *Writer) Str(s string) {
+	w.Int(len(s))
+	w.Buf = append(w.Buf, s...)
+}
+
+// Strs writes the length and then each string.
+func (w *Writer) Strs(strs []string) {
+	w.Int(len(strs))
+	for _, s := range strs {
+		w.Str(s)
+	}
+}
+
+// Bools writes up to 8 bools as the bits of a byte.
+func (w *Writer) Bools(bs ...bool) {
+	var b byte
+	for i, v := range bs {
+		if v {
+			b |= 1 << uint(i)
+		}
+	}
+	w.Buf = append(w.Buf, b)
+}
+
+// IntMap writes a map with the keys in order.
+func (w *Writer) IntMap(m map[int]int) {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	w.Int(len(keys))
+	for _, k := range keys {
+		w.Int(k)
+		w.Int(m[k])
+	}
+}
+
+// Reader reads what Writer wrote. After the first error every read returns
+// the zero value and the error is kept in Err. A caller can set Err when a
+// value it read is not valid and the reads after it stop the same way.
+type Reader struct {
+	data []byte
+	Err  error
+}
+
+// NewReader returns a Reader for the data after the magic string. If the data
+// does not start with it, the error names the kind of tables expected.
+func NewReader(data []byte, magic, kind string) (*Reader, error) {
+	if len(data) < len(magic) || string(data[:len(magic)]) != magic {
+		return nil, fmt.Errorf("Bad Tables: not %s tables or an unsupported version", kind)
+	}
+	return &Reader{data: data[len(magic):]}, nil
+}
+
+// Int reads an int.
+func (r *Reader) Int() int {
+	if r.Err != nil {
+		return 0
+	}
+	i, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.Err = fmt.Errorf("Bad Tables: unexpected end of data")
+		return 0
+	}
+	r.data = r.data[n:]
+	return int(i)
+}
+
+// Count reads a length, which cannot be more than the bytes left since every
+// item takes at least one byte.
+func (r *Reader) Count() int {
+	n := r.Int()
+	if r.Err == nil && (n < 0 || n > len(r.data)) {
+		r.Err = fmt.Errorf("Bad Tables: bad length %d", n)
+	}
+	if r.Err != nil {
+		return 0
+	}
+	return n
+}
+
+// Idx reads an index that must be less than max.
+func (r *Reader) Idx(max int) int {
+	i := r.Int()
+	if r.Err == nil && (i < 0 || i >= max) {
+		r.Err = fmt.Errorf("Bad Tables: index %d out of range", i)
+	}
+	if r.Err != nil {
+		return 0
+	}
+	return i
+}
+
+// Idxs reads what Ints wrote, checking each is an index less than max.
+func (r *Reader) Idxs(max int) []int {
+	n := r.Count()
+	if n == 0 {
+		return nil
+	}
+	is := make([]int, n)
+	for i := range is {
+		is[i] = r.Idx(max)
+	}
+	return is
+}
+
+// Str reads a string.
+func (r *Reader) Str() string {
+	n := r.Count()
+	if r.Err != nil {
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+// Strs reads what Strs wrote.
+func (r *Reader) Strs() []string {
+	n := r.Count()
+	if n == 0 {
+		return nil
+	}
+	strs := make([]string, n)
+	for i := range strs {
+		strs[i] = r.Str()
+	}
+	return strs
+}
+
+// Bools reads what Bools wrote into the bools.
+func (r *Reader) Bools(bs ...*bool) {
+	if r.Err == nil && len(r.data) == 0 {
+		r.Err = fmt.Errorf("Bad Tables: unexpected end of data")
+	}
+	if r.Err != nil {
+		return
+	}
+	for i, b := range bs {
+		*b = r.data[0]&(1<<uint(i)) != 0
+	}
+	r.data = r.data[1:]
+}
+
+// IntMap reads a map with keys less than max, ok checks each value.
+func (r *Reader) IntMap(max int, ok func(int) bool) map[int]int {
+	n := r.Count()
+	m := make(map[int]int, n)
+	for i := 0; i < n; i++ {
+		k := r.Idx(max)
+		v := r.Int()
+		if r.Err == nil && !ok(v) {
+			r.Err = fmt.Errorf("Bad Tables: bad entry %d for symbol %d", v, k)
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// Close returns the first error or an error if there is data left over.
+func (r *Reader) Close() error {
+	if r.Err == nil && len(r.data) > 0 {
+		r.Err = fmt.Errorf("Bad Tables: %d bytes after the tables", len(r.data))
+	}
+	return r.Err
+}
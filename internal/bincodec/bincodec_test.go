@@ -0,0 +1,47 @@
+package bincodec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	w := NewWriter("test\x01")
+	w.Int(-3)
+	w.Ints([]int{1, 2})
+	w.Strs([]string{"a", "bc"})
+	w.Bools(true, false, true)
+	w.IntMap(map[int]int{2: -1, 0: 5})
+
+	r, err := NewReader(w.Buf, "test\x01", "test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, -3, r.Int())
+	assert.Equal(t, []int{1, 2}, r.Idxs(3))
+	assert.Equal(t, []string{"a", "bc"}, r.Strs())
+	var a, b, c bool
+	r.Bools(&a, &b, &c)
+	assert.Equal(t, []bool{true, false, true}, []bool{a, b, c})
+	assert.Equal(t, map[int]int{2: -1, 0: 5}, r.IntMap(3, func(int) bool { return true }))
+	assert.NoError(t, r.Close())
+
+	_, err = NewReader(w.Buf, "tset\x01", "other")
+	assert.EqualError(t, err, "Bad Tables: not other tables or an unsupported version")
+
+	// an index out of range stops every read after it
+	r, _ = NewReader(w.Buf, "test\x01", "test")
+	r.Int()
+	r.Idxs(2)
+	assert.Error(t, r.Err)
+	assert.Equal(t, "", r.Str())
+	assert.EqualError(t, r.Close(), "Bad Tables: index 2 out of range")
+
+	r, _ = NewReader(append(w.Buf, 0), "test\x01", "test")
+	r.Int()
+	r.Idxs(3)
+	r.Strs()
+	r.Bools(&a)
+	r.IntMap(3, func(int) bool { return true })
+	assert.EqualError(t, r.Close(), "Bad Tables: 1 bytes after the tables")
+}
@@ -0,0 +1,42 @@
+package textscan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// QuoteRune writes a rune for a regular expression, inside a character class
+// if inClass is true. The slash is escaped because it delimits the regular
+// expression in a simplelexer rule and a rune that is not printable ASCII is
+// written as an escape so the rule stays readable.
+func QuoteRune(r rune, inClass bool) string {
+	switch {
+	case r == '/':
+		return `\/`
+	case r == '\n':
+		return `\n`
+	case r == '\r':
+		return `\r`
+	case r == '\t':
+		return `\t`
+	case !unicode.IsPrint(r) || r > unicode.MaxASCII:
+		return fmt.Sprintf(`\x{%x}`, r)
+	case inClass && strings.ContainsRune(`\]^[-`, r):
+		return `\` + string(r)
+	case !inClass:
+		return regexp.QuoteMeta(string(r))
+	}
+	return string(r)
+}
+
+// QuoteString writes a string for a regular expression that matches it
+// exactly.
+func QuoteString(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		buf.WriteString(QuoteRune(r, false))
+	}
+	return buf.String()
+}
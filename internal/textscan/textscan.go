@@ -0,0 +1,205 @@
+// Package textscan holds what the importers of grammar files share; a scanner
+// that tracks the line and column of each token and the quoting used to write
+// simplelexer rules. Each importer declares its own kinds of tokens.
+package textscan
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Kind is the kind of a Token.
+type Kind byte
+
+// EOF is the Kind of the Token at the end of the source for every importer.
+const EOF Kind = 0
+
+// Token is a piece of the source and where it starts.
+type Token struct {
+	Kind      Kind
+	Text      string
+	Line, Col int
+}
+
+// Expected returns the error for a parser that wanted what and found the
+// Token.
+func (t Token) Expected(what string) error {
+	got := fmt.Sprintf("%q", t.Text)
+	if t.Kind == EOF {
+		got = "end of file"
+	}
+	return fmt.Errorf("Bad Grammar: expected %s, got %s at %d:%d", what, got, t.Line, t.Col)
+}
+
+// Tokens calls next until it returns a Token of Kind EOF, which is included,
+// or an error.
+func Tokens(next func() (Token, error)) ([]Token, error) {
+	var tkns []Token
+	for {
+		t, err := next()
+		if err != nil {
+			return nil, err
+		}
+		tkns = append(tkns, t)
+		if t.Kind == EOF {
+			return tkns, nil
+		}
+	}
+}
+
+// Scanner reads a source one rune at a time. Line and Col are the position of
+// the next rune.
+type Scanner struct {
+	src       []rune
+	cur       int
+	Line, Col int
+}
+
+// New returns a Scanner at the start of the source.
+func New(src string) *Scanner {
+	return &Scanner{
+		src:  []rune(src),
+		Line: 1,
+		Col:  1,
+	}
+}
+
+// Done is true at the end of the source.
+func (s *Scanner) Done() bool {
+	return s.cur >= len(s.src)
+}
+
+// Peek returns the rune i after the next one, or 0 past the end.
+func (s *Scanner) Peek(i int) rune {
+	if s.cur+i >= len(s.src) {
+		return 0
+	}
+	return s.src[s.cur+i]
+}
+
+// HasPrefix is true if the source continues with str.
+func (s *Scanner) HasPrefix(str string) bool {
+	end := s.cur + len([]rune(str))
+	if end > len(s.src) {
+		return false
+	}
+	return string(s.src[s.cur:end]) == str
+}
+
+// Token returns a Token of the kind that starts at the next rune.
+func (s *Scanner) Token(kind Kind) Token {
+	return Token{
+		Kind: kind,
+		Line: s.Line,
+		Col:  s.Col,
+	}
+}
+
+// Advance moves past n runes and returns them.
+func (s *Scanner) Advance(n int) string {
+	start := s.cur
+	for ; n > 0 && s.cur < len(s.src); n-- {
+		if s.src[s.cur] == '\n' {
+			s.Line++
+			s.Col = 1
+		} else {
+			s.Col++
+		}
+		s.cur++
+	}
+	return string(s.src[start:s.cur])
+}
+
+// Errorf returns a Bad Grammar error at the position of the next rune.
+func (s *Scanner) Errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Bad Grammar: "+format+" at %d:%d", append(args, s.Line, s.Col)...)
+}
+
+// SkipTo advances past the end string.
+func (s *Scanner) SkipTo(end string) error {
+	for !s.HasPrefix(end) {
+		if s.Done() {
+			return s.Errorf("missing %q", end)
+		}
+		s.Advance(1)
+	}
+	s.Advance(len([]rune(end)))
+	return nil
+}
+
+// Skip advances past white space and // and /* */ comments.
+func (s *Scanner) Skip() error {
+	for !s.Done() {
+		switch r := s.Peek(0); {
+		case unicode.IsSpace(r):
+			s.Advance(1)
+		case r == '/' && s.Peek(1) == '/':
+			for !s.Done() && s.Peek(0) != '\n' {
+				s.Advance(1)
+			}
+		case r == '/' && s.Peek(1) == '*':
+			s.Advance(2)
+			if err := s.SkipTo("*/"); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Until reads up to the closing rune, which is consumed but not returned. A
+// backslash escapes the next rune, the escape is kept in the text. The text
+// cannot contain a line break.
+func (s *Scanner) Until(end rune) (string, error) {
+	start := s.cur
+	for s.Peek(0) != end {
+		if s.Done() || s.Peek(0) == '\n' {
+			return "", s.Errorf("missing %q", end)
+		}
+		if s.Peek(0) == '\\' {
+			s.Advance(1)
+		}
+		s.Advance(1)
+	}
+	text := string(s.src[start:s.cur])
+	s.Advance(1)
+	return text, nil
+}
+
+// Action reads a block of target code in braces, which may contain nested
+// braces, strings and comments.
+func (s *Scanner) Action() (string, error) {
+	start := s.cur
+	depth := 0
+	for {
+		if s.Done() {
+			return "", s.Errorf("unterminated action")
+		}
+		switch r := s.Peek(0); {
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case r == '"' || r == '\'':
+			s.Advance(1)
+			for !s.Done() && s.Peek(0) != r && s.Peek(0) != '\n' {
+				if s.Peek(0) == '\\' {
+					s.Advance(1)
+				}
+				s.Advance(1)
+			}
+		case r == '/' && s.Peek(1) == '*':
+			s.Advance(2)
+			if err := s.SkipTo("*/"); err != nil {
+				return "", err
+			}
+			continue
+		}
+		s.Advance(1)
+		if depth == 0 {
+			return string(s.src[start:s.cur]), nil
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package textscan
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	s := New("// comment\n  /* a\n b */ 'x\\'y' {a {b} \"}\" /* } */} rest")
+	assert.NoError(t, s.Skip())
+	assert.Equal(t, Token{Kind: 1, Line: 3, Col: 7}, s.Token(1))
+	s.Advance(1)
+	text, err := s.Until('\'')
+	assert.NoError(t, err)
+	assert.Equal(t, `x\'y`, text)
+	assert.NoError(t, s.Skip())
+	text, err = s.Action()
+	assert.NoError(t, err)
+	assert.Equal(t, `{a {b} "}" /* } */}`, text)
+	assert.NoError(t, s.Skip())
+	assert.True(t, s.HasPrefix("rest"))
+	assert.False(t, s.HasPrefix("rest of"))
+	s.Advance(4)
+	assert.True(t, s.Done())
+	assert.Equal(t, rune(0), s.Peek(0))
+
+	_, err = New("abc\n'").Until('\'')
+	assert.EqualError(t, err, `Bad Grammar: missing '\'' at 1:4`)
+	assert.EqualError(t, New("/* open").Skip(), `Bad Grammar: missing "*/" at 1:8`)
+	_, err = New("{ {").Action()
+	assert.EqualError(t, err, "Bad Grammar: unterminated action at 1:4")
+}
+
+func TestTokens(t *testing.T) {
+	s := New("a b")
+	tkns, err := Tokens(func() (Token, error) {
+		s.Skip()
+		t := s.Token(EOF)
+		if !s.Done() {
+			t.Kind, t.Text = 1, s.Advance(1)
+		}
+		return t, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{
+		{Kind: 1, Text: "a", Line: 1, Col: 1},
+		{Kind: 1, Text: "b", Line: 1, Col: 3},
+		{Kind: EOF, Line: 1, Col: 4},
+	}, tkns)
+	assert.EqualError(t, tkns[1].Expected("c"), `Bad Grammar: expected c, got "b" at 1:3`)
+	assert.EqualError(t, tkns[2].Expected("c"), `Bad Grammar: expected c, got end of file at 1:4`)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `a\.b\/\r\n\t\x{e9}`, QuoteString("a.b/\r\n\té"))
+	assert.Equal(t, `\]`, QuoteRune(']', true))
+	assert.Equal(t, `.`, QuoteRune('.', true))
+}
@@ -10,19 +10,23 @@ type symbol string
 
 func (s symbol) String() string { return string(s) }
 
-// Lexeme is a concrete implementation of parlex.Lexeme
+// Lexeme is a concrete implementation of parlex.Lexeme, parlex.LexemePos and
+// parlex.LexemeTrivia. O is the byte offset of the lexeme in the original
+// string. Lead and Trail hold the leading and trailing trivia.
 type Lexeme struct {
-	K    parlex.Symbol
-	V    string
-	L, C int
+	K           parlex.Symbol
+	V           string
+	L, C, O     int
+	Lead, Trail []parlex.Lexeme
 }
 
-// New returns a new Lexeme. Line is initially set to -1 to indicate the the
-// position has not been set.
+// New returns a new Lexeme. Line and offset are initially set to -1 to
+// indicate the the position has not been set.
 func New(kind parlex.Symbol) *Lexeme {
 	return &Lexeme{
 		K: kind,
 		L: -1,
+		O: -1,
 	}
 }
 
@@ -32,6 +36,7 @@ func String(str string) *Lexeme {
 	return &Lexeme{
 		K: symbol(str),
 		L: -1,
+		O: -1,
 	}
 }
 
@@ -53,9 +58,19 @@ func (l *Lexeme) At(line, col int) *Lexeme {
 	return l
 }
 
+// AtOffset sets the byte offset and returns the Lexeme, it's intended to be
+// used along with At
+//   atPos := lexeme.New("E").At(1,2).AtOffset(1)
+func (l *Lexeme) AtOffset(offset int) *Lexeme {
+	l.O = offset
+	return l
+}
+
 // Copy a parlex.Lexeme to *Lexeme
 func Copy(l parlex.Lexeme) *Lexeme {
-	return New(l.Kind()).Set(l.Value()).At(l.Pos())
+	cp := New(l.Kind()).Set(l.Value()).At(l.Pos()).AtOffset(parlex.Offset(l))
+	cp.Lead, cp.Trail = parlex.Leading(l), parlex.Trailing(l)
+	return cp
 }
 
 // Kind returns the token indicating what kind of lexeme this is
@@ -68,6 +83,18 @@ func (l *Lexeme) Value() string { return l.V }
 // the original string.
 func (l *Lexeme) Pos() (int, int) { return l.L, l.C }
 
+// Offset returns the byte offset of where the lexeme started in the original
+// string. It fulfills parlex.LexemePos.
+func (l *Lexeme) Offset() int { return l.O }
+
+// Leading returns the hidden lexemes before the lexeme. It fulfills
+// parlex.LexemeTrivia.
+func (l *Lexeme) Leading() []parlex.Lexeme { return l.Lead }
+
+// Trailing returns the hidden lexemes after the lexeme to the end of the line.
+// It fulfills parlex.LexemeTrivia.
+func (l *Lexeme) Trailing() []parlex.Lexeme { return l.Trail }
+
 // String returns a formatted representation of the lexeme.
 func (l *Lexeme) String() string {
 	pos := ""
@@ -0,0 +1,280 @@
+// Package filter rewrites the stream of lexemes between a lexer and a parser.
+// A Filter can drop kinds, merge runs of lexemes or insert synthetic lexemes,
+// like the semicolons Go inserts at the end of a line, so rules that only
+// depend on the neighbouring tokens do not complicate the grammar.
+//
+// Filters are composed with Chain and used either by wrapping a lexer with New
+// or as parlex.Middleware with Middleware.
+//
+//   lxr := filter.New(simple,
+//     filter.Drop("comment"),
+//     filter.Semicolons("semi", "ident", "int", "rp"),
+//   )
+package filter
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"strings"
+	"unicode/utf8"
+)
+
+// Filter takes the lexemes from a lexer and returns the lexemes for the
+// parser. A Filter may change the slice it is given.
+type Filter func(lxs []parlex.Lexeme) []parlex.Lexeme
+
+// Chain returns a Filter that runs the filters in order.
+func Chain(filters ...Filter) Filter {
+	return func(lxs []parlex.Lexeme) []parlex.Lexeme {
+		for _, f := range filters {
+			lxs = f(lxs)
+		}
+		return lxs
+	}
+}
+
+// Lexer wraps a lexer and runs its lexemes through a Filter. It fulfills
+// parlex.Lexer.
+type Lexer struct {
+	parlex.Lexer
+	Filter Filter
+}
+
+// New returns a Lexer that runs the filters in order on the lexemes from the
+// lexer.
+func New(lexer parlex.Lexer, filters ...Filter) *Lexer {
+	return &Lexer{
+		Lexer:  lexer,
+		Filter: Chain(filters...),
+	}
+}
+
+// Lex fulfills parlex.Lexer. If the wrapped lexer returns nil, so does Lex.
+func (l *Lexer) Lex(input string) []parlex.Lexeme {
+	lxs := l.Lexer.Lex(input)
+	if lxs == nil {
+		return nil
+	}
+	return l.Filter(lxs)
+}
+
+// Middleware returns parlex.Middleware that runs the filters in order on the
+// lexemes of the lex stage.
+func Middleware(filters ...Filter) parlex.Middleware {
+	f := Chain(filters...)
+	return parlex.Middleware{
+		Lex: func(next parlex.LexFunc) parlex.LexFunc {
+			return func(input string) []parlex.Lexeme {
+				lxs := next(input)
+				if lxs == nil {
+					return nil
+				}
+				return f(lxs)
+			}
+		},
+	}
+}
+
+func kindSet(kinds []string) map[string]bool {
+	set := make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		set[k] = true
+	}
+	return set
+}
+
+// Drop removes the lexemes of the given kinds.
+func Drop(kinds ...string) Filter {
+	drop := kindSet(kinds)
+	return func(lxs []parlex.Lexeme) []parlex.Lexeme {
+		out := lxs[:0]
+		for _, lx := range lxs {
+			if !drop[lx.Kind().String()] {
+				out = append(out, lx)
+			}
+		}
+		return out
+	}
+}
+
+// Merge replaces each run of adjacent lexemes of one of the given kinds with a
+// single lexeme of that kind. Its value is the values of the run joined
+// together and its position is the position of the first. Lexemes that are
+// errors are never merged. Merge is useful for a lexer that produces a string
+// in pieces, or to collapse blank lines into one newline.
+func Merge(kinds ...string) Filter {
+	merge := kindSet(kinds)
+	return func(lxs []parlex.Lexeme) []parlex.Lexeme {
+		out := lxs[:0]
+		for i := 0; i < len(lxs); {
+			kind := lxs[i].Kind().String()
+			end := i + 1
+			if merge[kind] && !isErr(lxs[i]) {
+				for end < len(lxs) && lxs[end].Kind().String() == kind && !isErr(lxs[end]) {
+					end++
+				}
+			}
+			if end-i == 1 {
+				out = append(out, lxs[i])
+			} else {
+				out = append(out, join(lxs[i:end]))
+			}
+			i = end
+		}
+		return out
+	}
+}
+
+func isErr(lx parlex.Lexeme) bool {
+	_, ok := lx.(parlex.LexError)
+	return ok
+}
+
+// join makes one lexeme from a run, keeping the leading trivia of the first and
+// the trailing trivia of the last.
+func join(run []parlex.Lexeme) parlex.Lexeme {
+	vals := make([]string, len(run))
+	for i, lx := range run {
+		vals[i] = lx.Value()
+	}
+	lx := lexeme.Copy(run[0])
+	lx.V = strings.Join(vals, "")
+	lx.Trail = parlex.Trailing(run[len(run)-1])
+	return lx
+}
+
+// Insert returns a Filter that calls rule between each pair of lexemes and
+// inserts the lexeme it returns, if it is not nil. The rule is also called
+// before the first lexeme with a nil prev and after the last with a nil next.
+func Insert(rule func(prev, next parlex.Lexeme) parlex.Lexeme) Filter {
+	return func(lxs []parlex.Lexeme) []parlex.Lexeme {
+		out := make([]parlex.Lexeme, 0, len(lxs))
+		var prev parlex.Lexeme
+		for _, lx := range lxs {
+			if ins := rule(prev, lx); ins != nil {
+				out = append(out, ins)
+			}
+			out = append(out, lx)
+			prev = lx
+		}
+		if ins := rule(prev, nil); ins != nil {
+			out = append(out, ins)
+		}
+		return out
+	}
+}
+
+// Semicolons inserts a lexeme of the given kind at the end of a line, the same
+// way Go inserts semicolons. A lexeme is inserted after a lexeme of one of the
+// after kinds when the next lexeme starts on a later line, or there is no next
+// lexeme. It is not inserted if the next lexeme is already of the kind. The
+// lexer can discard newlines, the lines come from the position of the lexemes.
+//
+// The inserted lexeme has an empty value and is positioned right after the
+// lexeme it follows.
+func Semicolons(kind string, after ...string) Filter {
+	ends := kindSet(after)
+	return Insert(func(prev, next parlex.Lexeme) parlex.Lexeme {
+		if prev == nil || !ends[prev.Kind().String()] {
+			return nil
+		}
+		line, col, offset := End(prev)
+		if next != nil {
+			if next.Kind().String() == kind {
+				return nil
+			}
+			if nextLine, _ := next.Pos(); nextLine <= line {
+				return nil
+			}
+		}
+		return lexeme.String(kind).At(line, col).AtOffset(offset)
+	})
+}
+
+// End returns the line, column and offset just after a lexeme, found from its
+// position and value. Columns count runes. The offset is -1 if the lexeme does
+// not have one.
+func End(lx parlex.Lexeme) (line, col, offset int) {
+	line, col = lx.Pos()
+	v := lx.Value()
+	if i := strings.LastIndexByte(v, '\n'); i >= 0 {
+		line += strings.Count(v, "\n")
+		col = utf8.RuneCountInString(v[i+1:]) + 1
+	} else {
+		col += utf8.RuneCountInString(v)
+	}
+	offset = parlex.Offset(lx)
+	if offset >= 0 {
+		offset += len(v)
+	}
+	return
+}
+
+// Newlines makes the end of a line a statement terminator, as in languages like
+// Go and JavaScript. Between two lexemes on different lines, a lexeme of Kind
+// is inserted unless the line continues. A line continues if its last lexeme
+// is one of the Continue kinds, like an operator or a comma, if the next line
+// starts with one of the Leading kinds, like "." in a chain of calls, or if the
+// lexemes are inside one of the Nest pairs of open and close kinds. A
+// terminator is also inserted at the end of the input, and never next to a
+// lexeme that is already of Kind.
+//
+// The lines come from the positions of the lexemes, so the lexer can discard
+// newlines.
+//
+//   nl := filter.Newlines{
+//     Kind:     "semi",
+//     Continue: []string{"op", "comma", "lb"},
+//     Leading:  []string{"dot"},
+//     Nest:     [][2]string{{"lp", "rp"}},
+//   }
+//   lxr := filter.New(simple, nl.Filter())
+type Newlines struct {
+	Kind     string
+	Continue []string
+	Leading  []string
+	Nest     [][2]string
+}
+
+// Filter returns the Filter for the newline rules.
+func (n Newlines) Filter() Filter {
+	cont := kindSet(n.Continue)
+	leading := kindSet(n.Leading)
+	opening := make(map[string]bool, len(n.Nest))
+	closing := make(map[string]bool, len(n.Nest))
+	for _, pair := range n.Nest {
+		opening[pair[0]] = true
+		closing[pair[1]] = true
+	}
+	return func(lxs []parlex.Lexeme) []parlex.Lexeme {
+		// depth is kept across the lexemes, so each run of the filter needs its
+		// own
+		depth := 0
+		return Insert(func(prev, next parlex.Lexeme) parlex.Lexeme {
+			if next != nil {
+				defer func() {
+					if k := next.Kind().String(); opening[k] {
+						depth++
+					} else if closing[k] && depth > 0 {
+						depth--
+					}
+				}()
+			}
+			if prev == nil || depth > 0 {
+				return nil
+			}
+			kind := prev.Kind().String()
+			if kind == n.Kind || cont[kind] {
+				return nil
+			}
+			line, col, offset := End(prev)
+			if next != nil {
+				kind = next.Kind().String()
+				if nextLine, _ := next.Pos(); nextLine <= line || kind == n.Kind || leading[kind] {
+					return nil
+				}
+			}
+			return lexeme.String(n.Kind).At(line, col).AtOffset(offset)
+		})(lxs)
+	}
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func kinds(lxs []parlex.Lexeme) string {
+	strs := make([]string, len(lxs))
+	for i, lx := range lxs {
+		strs[i] = lx.Kind().String()
+		if v := lx.Value(); v != "" && v != strs[i] {
+			strs[i] += ":" + v
+		}
+	}
+	return strings.Join(strs, " ")
+}
+
+func TestDropMerge(t *testing.T) {
+	sl, err := simplelexer.New(`
+    word    /\w+/
+    comment /#[^\n]*/
+    nl      /\n/
+    space   /[ \t]+/ -
+  `)
+	assert.NoError(t, err)
+
+	src := "a # note\n\n\nb c\n$$"
+	lxr := New(sl, Drop("comment"), Merge("nl"))
+	lxs := lxr.Lex(src)
+	assert.Equal(t, "word:a nl:\n\n\n word:b word:c nl:\n Error:$$", kinds(lxs))
+	l, c := lxs[1].Pos()
+	assert.Equal(t, 1, l)
+	assert.Equal(t, 9, c)
+	assert.Equal(t, 8, parlex.Offset(lxs[1]))
+
+	// errors are not merged
+	lxs = Merge("Error")([]parlex.Lexeme{
+		sl.Lex("$")[0],
+		sl.Lex("$")[0],
+	})
+	assert.Len(t, lxs, 2)
+
+	assert.Nil(t, New(sl, Drop("word")).Lex(""))
+}
+
+func TestSemicolons(t *testing.T) {
+	sl, err := simplelexer.New(`
+    ident /[a-z]+/
+    int   /\d+/
+    str   /"[^"]*"/
+    lp    /\(/
+    rp    /\)/
+    semi  /;/
+    op    /[=+]/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	semis := Semicolons("semi", "ident", "int", "str", "rp")
+
+	src := "x = 1 +\n  2\nf(\"a\nb\")\ny = 3;\nz"
+	lxs := New(sl, semis).Lex(src)
+	assert.Equal(t, "ident:x op:= int:1 op:+ int:2 semi ident:f lp:( str:\"a\nb\" rp:) semi ident:y op:= int:3 semi:; ident:z semi", kinds(lxs))
+
+	// inserted after the multi-line string ends
+	l, c := lxs[10].Pos()
+	assert.Equal(t, 4, l)
+	assert.Equal(t, 4, c)
+	assert.Equal(t, strings.Index(src, ")")+1, parlex.Offset(lxs[10]))
+
+	m := Middleware(Drop("op"), semis)
+	lex := m.Lex(sl.Lex)
+	assert.Equal(t, "ident:a ident:b semi", kinds(lex("a = b")))
+}
+
+func TestInsert(t *testing.T) {
+	f := Insert(func(prev, next parlex.Lexeme) parlex.Lexeme {
+		if prev == nil {
+			return lexeme.String("START")
+		}
+		if next == nil {
+			return lexeme.String("END")
+		}
+		return nil
+	})
+	lxs := f([]parlex.Lexeme{lexeme.String("a"), lexeme.String("b")})
+	assert.Equal(t, "START a b END", kinds(lxs))
+}
+
+func TestNewlines(t *testing.T) {
+	sl, err := simplelexer.New(`
+    ident /[a-z]+/
+    int   /\d+/
+    lp    /\(/
+    rp    /\)/
+    lb    /\{/
+    rb    /\}/
+    comma /,/
+    dot   /\./
+    semi  /;/
+    op    /[=+]/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	nl := Newlines{
+		Kind:     "semi",
+		Continue: []string{"op", "comma", "lb"},
+		Leading:  []string{"dot"},
+		Nest:     [][2]string{{"lp", "rp"}},
+	}
+	lxr := New(sl, nl.Filter())
+
+	src := "x = 1 +\n  2\nf(a,\n  b\n)\n.g()\nif {\n  y;\n}\n"
+	assert.Equal(t, "ident:x op:= int:1 op:+ int:2 semi ident:f lp:( ident:a comma:, ident:b rp:) dot:. ident:g lp:( rp:) semi ident:if lb:{ ident:y semi:; rb:} semi", kinds(lxr.Lex(src)))
+
+	// the depth starts over for each input
+	assert.Equal(t, "lp:(", kinds(lxr.Lex("(")))
+	assert.Equal(t, "ident:a semi ident:b semi", kinds(lxr.Lex("a\nb")))
+}
@@ -0,0 +1,145 @@
+package simplelexer
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/internal/bincodec"
+	"sort"
+)
+
+// binaryMagic starts the binary form of the lexer Tables, the last byte is the
+// version.
+const binaryMagic = "plxL\x01"
+
+// MarshalBinary fulfills encoding.BinaryMarshaler. The binary form is compact,
+// is the same each time for the same tables and can be embedded in a program
+// and loaded by FromBinary.
+func (t *Tables) MarshalBinary() ([]byte, error) {
+	w := bincodec.NewWriter(binaryMagic)
+	w.Strs(t.Symbols)
+	w.Int(len(t.Rules))
+	for _, r := range t.Rules {
+		w.Int(r.Kind)
+		w.Str(r.Pattern)
+		w.Bools(r.Discard, r.Pop, r.Fold)
+		w.Int(r.Priority)
+		w.Strs(r.Modes)
+		w.Str(r.Push)
+	}
+	w.Int(len(t.States))
+	for _, s := range t.States {
+		w.Ints(s.Matched)
+		w.Bools(s.Live)
+		w.Int(len(s.Next))
+		for _, tr := range s.Next {
+			w.Int(int(tr.Lo))
+			w.Int(int(tr.Hi))
+			w.Int(tr.To)
+		}
+	}
+	keys := make([]string, 0, len(t.Starts))
+	for k := range t.Starts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.Int(len(keys))
+	for _, k := range keys {
+		w.Str(k)
+		w.Int(t.Starts[k])
+	}
+	w.Int(len(t.Keywords))
+	for _, k := range t.Keywords {
+		w.Strs(k.Words)
+		w.Ints(k.Kinds)
+		w.Strs(k.Modes)
+	}
+	w.Int(int(t.Policy))
+	w.Str(t.Error)
+	w.Bools(t.IgnoreCase, t.Trivia)
+	w.Int(t.TabWidth)
+	w.Strs(t.InsertStart[:])
+	w.Strs(t.InsertEnd[:])
+	return w.Buf, nil
+}
+
+// UnmarshalBinary fulfills encoding.BinaryUnmarshaler. It reads the form
+// written by MarshalBinary and checks that the tables refer to kinds and states
+// that exist.
+func (t *Tables) UnmarshalBinary(data []byte) error {
+	r, err := bincodec.NewReader(data, binaryMagic, "simplelexer")
+	if err != nil {
+		return err
+	}
+	var out Tables
+	out.Symbols = r.Strs()
+	kinds := len(out.Symbols)
+	out.Rules = make([]RuleTable, r.Count())
+	for i := range out.Rules {
+		rt := &out.Rules[i]
+		rt.Kind = r.Idx(kinds)
+		rt.Pattern = r.Str()
+		r.Bools(&rt.Discard, &rt.Pop, &rt.Fold)
+		rt.Priority = r.Int()
+		rt.Modes = r.Strs()
+		rt.Push = r.Str()
+	}
+	out.States = make([]StateTable, r.Count())
+	states := len(out.States)
+	for i := range out.States {
+		st := &out.States[i]
+		st.Matched = r.Idxs(kinds)
+		r.Bools(&st.Live)
+		st.Next = make([]Transition, r.Count())
+		for j := range st.Next {
+			st.Next[j] = Transition{
+				Lo: rune(r.Int()),
+				Hi: rune(r.Int()),
+				To: r.Idx(states),
+			}
+		}
+	}
+	out.Starts = make(map[string]int)
+	for i, n := 0, r.Count(); i < n; i++ {
+		k := r.Str()
+		out.Starts[k] = r.Idx(states)
+	}
+	out.Keywords = make([]KeywordTable, r.Count())
+	for i := range out.Keywords {
+		kt := &out.Keywords[i]
+		kt.Words = r.Strs()
+		kt.Kinds = r.Idxs(kinds)
+		kt.Modes = r.Strs()
+		if r.Err == nil && len(kt.Words) != len(kt.Kinds) {
+			r.Err = fmt.Errorf("Bad Tables: %d keywords with %d kinds", len(kt.Words), len(kt.Kinds))
+		}
+	}
+	out.Policy = Policy(r.Int())
+	out.Error = r.Str()
+	r.Bools(&out.IgnoreCase, &out.Trivia)
+	out.TabWidth = r.Int()
+	copy(out.InsertStart[:], r.Strs())
+	copy(out.InsertEnd[:], r.Strs())
+	if err := r.Close(); err != nil {
+		return err
+	}
+	*t = out
+	return nil
+}
+
+// MarshalBinary fulfills encoding.BinaryMarshaler by writing the Tables of the
+// lexer. A lexer with a rule the DFA does not support returns an error.
+func (l *Lexer) MarshalBinary() ([]byte, error) {
+	t, err := l.Tables()
+	if err != nil {
+		return nil, err
+	}
+	return t.MarshalBinary()
+}
+
+// FromBinary returns a lexer from tables written by MarshalBinary.
+func FromBinary(data []byte) (*Lexer, error) {
+	t := &Tables{}
+	if err := t.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return FromTables(t), nil
+}
@@ -0,0 +1,80 @@
+package simplelexer
+
+import (
+	"encoding"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	lxr, err := New(`
+          strStart /"/ push(STR)
+          word     /[\p{L}_]+/
+          num      /\d+/ priority(2)
+          space    /\s+/ -
+    <STR> strEnd   /"/ pop
+    <STR> text     /[^"]+/
+    keywords if else -> kw_if kw_else
+    offside 2
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	lxr.KeepTrivia().InsertEnd("eof", "")
+	data, err := lxr.MarshalBinary()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	again, err := lxr.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	loaded, err := FromBinary(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, lxr.String(), loaded.String())
+	for _, in := range []string{"if \"naïve\" else 12\n  x\ny", "ü \"open"} {
+		expected, expectedModes := lxr.LexModes(in)
+		lxs, modes := loaded.LexModes(in)
+		assert.Equal(t, describe(expected), describe(lxs), in)
+		assert.Equal(t, expectedModes, modes, in)
+	}
+
+	// the same tables are always written the same way
+	tbls := &Tables{}
+	assert.NoError(t, tbls.UnmarshalBinary(data))
+	again, err = tbls.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	var _ encoding.BinaryMarshaler = lxr
+	var _ encoding.BinaryUnmarshaler = &Tables{}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	lxr, err := New(`
+    word  /\w+/
+    space /\s+/ -
+    keywords if
+  `)
+	assert.NoError(t, err)
+	data, err := lxr.MarshalBinary()
+	assert.NoError(t, err)
+
+	for i := range data {
+		_, err := FromBinary(data[:i])
+		assert.Error(t, err, i)
+	}
+	_, err = FromBinary(append(data, 0))
+	assert.Equal(t, "Bad Tables: 1 bytes after the tables", err.Error())
+
+	_, err = FromBinary([]byte("plxL\x02"))
+	assert.Equal(t, "Bad Tables: not simplelexer tables or an unsupported version", err.Error())
+
+	bad, err := New(`word /\w+\b/`)
+	assert.NoError(t, err)
+	_, err = bad.MarshalBinary()
+	assert.Error(t, err)
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -12,30 +15,209 @@ import (
 var DefaultErrorString = "Error"
 
 // New returns a new Lexer. It can be provided definitions, though most often it
-// is only given a single definition. Each line will be one rule.
+// is only given a single definition. Each line will be one rule. Files that are
+// included are relative to the working directory. An error in a line is a
+// *parlex.DefinitionError with the line and the reason.
 func New(definitions ...string) (*Lexer, error) {
-	l := &Lexer{
-		compare: lengthThenPriority,
-		Error:   DefaultErrorString,
-		set:     setsymbol.New(),
+	c := newConstructOp()
+	for _, definition := range definitions {
+		if err := c.definition(definition, ""); err != nil {
+			return nil, err
+		}
 	}
+	return c.done()
+}
+
+// Compile is the same as New but does not stop at the first bad line. If there
+// are any errors, the returned error is a parlex.DefinitionErrors with a
+// *parlex.DefinitionError for each bad line.
+func Compile(definitions ...string) (*Lexer, error) {
+	c := newConstructOp()
+	c.collect = true
 	for _, definition := range definitions {
-		for _, line := range strings.Split(definition, "\n") {
-			r, err := l.ruleFromLine(line)
-			if err != nil {
-				return nil, err
+		c.definition(definition, "")
+	}
+	if err := c.checkModes(); err != nil {
+		c.errs = append(c.errs, err)
+	}
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+	return c.Lexer, nil
+}
+
+// Load reads the definition of a Lexer from a file. Files that it includes are
+// relative to the directory of the file.
+func Load(filename string) (*Lexer, error) {
+	c := newConstructOp()
+	if err := c.include(filename, ""); err != nil {
+		return nil, err
+	}
+	return c.done()
+}
+
+// constructOp holds the state of reading definitions. Fragments are regular
+// expressions that can be used in the rules that come after them and
+// including tracks the files being included to catch a cycle. If collect is
+// true, errors are added to errs instead of stopping.
+type constructOp struct {
+	*Lexer
+	fragments map[string]string
+	including map[string]bool
+	collect   bool
+	errs      parlex.DefinitionErrors
+}
+
+func newConstructOp() *constructOp {
+	return &constructOp{
+		Lexer: &Lexer{
+			compare: lengthThenPriority,
+			Error:   DefaultErrorString,
+			set:     setsymbol.New(),
+		},
+		fragments: make(map[string]string),
+		including: make(map[string]bool),
+	}
+}
+
+func (c *constructOp) done() (*Lexer, error) {
+	if err := c.checkModes(); err != nil {
+		return nil, err
+	}
+	return c.Lexer, nil
+}
+
+var includeStr = regexp.MustCompile(`^\s*include\s+"([^"]+)"\s*$`)
+var fragmentStr = regexp.MustCompile(`^\s*fragment\s+([A-Za-z_]\w*)\s*\/((?:[^\/\\]|(?:\\\/?))+)\/\s*$`)
+var keywordsStr = regexp.MustCompile(`^\s*keywords((?:\s+\w+)+)(?:\s*->((?:\s+[^\s\/]+)+))?\s*$`)
+var offSideStr = regexp.MustCompile(`^\s*offside\s+(\d+)\s*$`)
+var policyStr = regexp.MustCompile(`^\s*policy\s+(\w+)\s*$`)
+
+// definition reads the lines of a definition. The file is empty if the
+// definition was not read from a file, otherwise the files it includes are
+// relative to it. An error is a *parlex.DefinitionError; if the errors are
+// being collected for Compile, it is added to errs and reading continues.
+func (c *constructOp) definition(definition, file string) error {
+	dir := ""
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+	for i, line := range strings.Split(definition, "\n") {
+		if err := c.line(line, dir); err != nil {
+			err = parlex.DefinitionErrorAt(file, i+1, line, err)
+			if !c.collect {
+				return err
+			}
+			c.errs = append(c.errs, err)
+		}
+	}
+	return nil
+}
+
+func (c *constructOp) line(line, dir string) error {
+	if m := includeStr.FindStringSubmatch(line); m != nil {
+		return c.include(m[1], dir)
+	}
+	if m := fragmentStr.FindStringSubmatch(line); m != nil {
+		if _, ok := c.fragments[m[1]]; ok {
+			return fmt.Errorf("Duplicate Fragment: %s", m[1])
+		}
+		re, err := c.expand(m[2])
+		if err != nil {
+			return err
+		}
+		if _, err := regexp.Compile(re); err != nil {
+			return err
+		}
+		c.fragments[m[1]] = re
+		return nil
+	}
+	if m := offSideStr.FindStringSubmatch(line); m != nil {
+		tabWidth, _ := strconv.Atoi(m[1])
+		c.OffSide(tabWidth)
+		return nil
+	}
+	if m := policyStr.FindStringSubmatch(line); m != nil {
+		p, err := ParsePolicy(m[1])
+		if err != nil {
+			return err
+		}
+		c.SetPolicy(p)
+		return nil
+	}
+	ok, err := c.keywordsFromLine(line)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	r, err := c.ruleFromLine(line, c.expand)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return nil
+	}
+	return c.addRule(r)
+}
+
+func (c *constructOp) include(filename, dir string) error {
+	if dir != "" && !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
+	filename = filepath.Clean(filename)
+	if c.including[filename] {
+		return fmt.Errorf("Include Cycle: %s", filename)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	c.including[filename] = true
+	err = c.definition(string(b), filename)
+	delete(c.including, filename)
+	return err
+}
+
+var fragmentRef = regexp.MustCompile(`^\{([A-Za-z_]\w*)\}`)
+
+// expand replaces each {name} in a regular expression with the fragment of
+// that name. Escapes are copied as they are, including ones with braces like
+// \p{L}, and repetitions like {2,3} do not start with a letter so they are not
+// mistaken for a fragment.
+func (c *constructOp) expand(re string) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(re); {
+		switch {
+		case re[i] == '\\' && i+1 < len(re):
+			end := i + 2
+			if strings.IndexByte("pPx", re[i+1]) >= 0 && end < len(re) && re[end] == '{' {
+				if close := strings.IndexByte(re[end:], '}'); close >= 0 {
+					end += close + 1
+				}
 			}
-			if r == nil {
+			buf.WriteString(re[i:end])
+			i = end
+		case re[i] == '{':
+			m := fragmentRef.FindStringSubmatch(re[i:])
+			if m == nil {
+				buf.WriteByte('{')
+				i++
 				continue
 			}
-			err = l.addRule(r)
-			if err != nil {
-				return nil, err
+			fragment, ok := c.fragments[m[1]]
+			if !ok {
+				return "", fmt.Errorf("Undefined Fragment: %s", m[1])
 			}
+			buf.WriteString("(?:" + fragment + ")")
+			i += len(m[0])
+		default:
+			buf.WriteByte(re[i])
+			i++
 		}
 	}
-
-	return l, nil
+	return buf.String(), nil
 }
 
 // InsertStart will insert a lexeme at the start of any results. This can be
@@ -54,29 +236,200 @@ func (l *Lexer) InsertEnd(kind, val string) *Lexer {
 	return l
 }
 
-var lexStr = regexp.MustCompile(`([^\/\s]+)\s*(?:\/((?:[^\/\\]|(?:\\\/?))+)\/)?\s*(-?)`)
+var lexStr = regexp.MustCompile(`([^\/\s]+)\s*(?:\/((?:[^\/\\]|(?:\\\/?))+)\/(i?))?\s*(-?)`)
+var modeStr = regexp.MustCompile(`^\s*<(\w+(?:,\w+)*)>`)
+var optionStr = regexp.MustCompile(`^\s*(?:push\((\w+)\)|(pop)|priority\((-?\d+)\))`)
 
-func (l *Lexer) ruleFromLine(line string) (*rule, error) {
-	m := lexStr.FindStringSubmatch(line)
-	if len(m) != 4 {
+// ruleFromLine parses a rule. If the rule has a regular expression, expand is
+// called on it before it is compiled. A regular expression that ends with "/i"
+// ignores case. The rule can be followed by a push or pop and a priority.
+func (l *Lexer) ruleFromLine(line string, expand func(string) (string, error)) (*rule, error) {
+	var modes []string
+	if m := modeStr.FindStringSubmatch(line); m != nil {
+		modes = strings.Split(m[1], ",")
+		line = line[len(m[0]):]
+	}
+	idx := lexStr.FindStringSubmatchIndex(line)
+	if idx == nil {
 		return nil, nil
 	}
-	i := 2
-	if m[i] == "" {
+	m := lexStr.FindStringSubmatch(line)
+	var push string
+	var pop bool
+	var number int
+	for rest := line[idx[1]:]; ; {
+		t := optionStr.FindStringSubmatch(rest)
+		if t == nil {
+			break
+		}
+		switch {
+		case t[1] != "":
+			push = t[1]
+		case t[2] != "":
+			pop = true
+		default:
+			number, _ = strconv.Atoi(t[3])
+		}
+		rest = rest[len(t[0]):]
+	}
+	src := m[2]
+	if src == "" {
 		// if there is no regex, the word becomes the regex
-		i = 1
+		src = m[1]
+	} else if expand != nil {
+		var err error
+		if src, err = expand(src); err != nil {
+			return nil, err
+		}
 	}
-	re, err := regexp.Compile(m[i])
+	fold := m[3] == "i"
+	if fold {
+		src = "(?i)" + src
+	}
+	re, err := regexp.Compile(src)
 	if err != nil {
 		return nil, err
 	}
 	return &rule{
 		kind:    l.set.Str(m[1]).Idx(),
 		re:      re,
-		discard: m[3] == "-",
+		discard: m[4] == "-",
+		modes:   modes,
+		push:    push,
+		pop:     pop,
+		fold:    fold,
+		number:  number,
 	}, nil
 }
 
+// keywordsFromLine adds a keyword table if the line is a keywords directive.
+// Without a list of kinds, each keyword is its own kind.
+func (l *Lexer) keywordsFromLine(line string) (bool, error) {
+	var modes []string
+	if m := modeStr.FindStringSubmatch(line); m != nil {
+		modes = strings.Split(m[1], ",")
+		line = line[len(m[0]):]
+	}
+	m := keywordsStr.FindStringSubmatch(line)
+	if m == nil {
+		return false, nil
+	}
+	words := strings.Fields(m[1])
+	kinds := words
+	if m[2] != "" {
+		kinds = strings.Fields(m[2])
+	}
+	return true, l.AddKeywords(words, kinds, modes...)
+}
+
+// AddKeywords adds a table of reserved words. When a rule that is not
+// discarded matches exactly words[i], the lexeme is given the kind kinds[i]
+// instead of the kind of the rule. The keywords only apply in the modes given,
+// with no modes they apply in InitialMode.
+func (l *Lexer) AddKeywords(words, kinds []string, modes ...string) error {
+	if len(kinds) != len(words) {
+		return fmt.Errorf("Bad Keywords: %d words and %d kinds", len(words), len(kinds))
+	}
+	k := &keywords{
+		words: words,
+		kinds: make([]int, len(kinds)),
+		idx:   make(map[string]int, len(words)),
+	}
+	if len(modes) > 0 {
+		k.modes = modes
+	}
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		if _, ok := k.idx[lower]; ok {
+			return fmt.Errorf("Duplicate Keyword: %s", word)
+		}
+		k.idx[lower] = i
+		k.kinds[i] = l.set.Str(kinds[i]).Idx()
+	}
+	l.keywords = append(l.keywords, k)
+	return nil
+}
+
+// AddLiterals makes sure the lexer produces each literal with the literal as
+// its kind, like the quoted symbols in a regexgram grammar. A literal that is
+// already a kind is left alone. A literal that a rule already matches, like
+// "if" matched by an identifier rule, becomes a keyword so "ifx" is still an
+// identifier. Any other literal gets a rule that matches it exactly and wins a
+// tie with the rules before it. Literals only apply in InitialMode.
+func (l *Lexer) AddLiterals(literals ...string) error {
+	kinds := make(map[string]bool)
+	for _, s := range l.Symbols() {
+		kinds[s.String()] = true
+	}
+	var words []string
+	var rules []*rule
+	for _, lit := range literals {
+		if lit == "" || kinds[lit] {
+			continue
+		}
+		kinds[lit] = true
+		if l.matchedByRule(lit) {
+			words = append(words, lit)
+			continue
+		}
+		rules = append(rules, &rule{
+			kind: l.set.Str(lit).Idx(),
+			re:   regexp.MustCompile(regexp.QuoteMeta(lit)),
+		})
+	}
+	if len(words) > 0 {
+		if err := l.AddKeywords(words, words); err != nil {
+			return err
+		}
+	}
+	number := 0
+	for _, kind := range l.order {
+		if n := l.rules[kind].number; n >= number {
+			number = n + 1
+		}
+	}
+	for _, r := range rules {
+		r.number = number
+		if err := l.addRule(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchedByRule checks if a rule that keeps what it matches in InitialMode
+// matches all of str.
+func (l *Lexer) matchedByRule(str string) bool {
+	for _, kind := range l.order {
+		r := l.rules[kind]
+		if r.discard || !r.in(InitialMode) {
+			continue
+		}
+		if re, err := regexp.Compile(`^(?:` + r.pattern() + `)$`); err == nil && re.MatchString(str) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkModes confirms that every mode that is pushed has rules.
+func (l *Lexer) checkModes() error {
+	modes := map[string]bool{InitialMode: true}
+	for _, r := range l.rules {
+		if r != nil {
+			for _, m := range r.modes {
+				modes[m] = true
+			}
+		}
+	}
+	for _, kind := range l.order {
+		if r := l.rules[kind]; r.push != "" && !modes[r.push] {
+			return fmt.Errorf("Undefined Mode: %s", r.push)
+		}
+	}
+	return nil
+}
+
 // Add a lexer rule
 func (l *Lexer) Add(kind parlex.Symbol, re *regexp.Regexp, discard bool) error {
 	return l.addRule(&rule{
@@ -95,24 +448,112 @@ func (l *Lexer) addRule(r *rule) error {
 		l.rules = append(l.rules, make([]*rule, 1+r.kind-len(l.rules))...)
 	}
 
-	r.priority = l.priorityCounter
-	l.priorityCounter++
+	if l.ignoreCase {
+		r.foldCase()
+	}
 	l.rules[r.kind] = r
 	l.order = append(l.order, r.kind)
+	l.rank()
+	if l.dfa != nil {
+		return l.UseDFA()
+	}
 	return nil
 }
 
+// Symbols returns the kinds of all the rules in the order they were defined,
+// followed by the kinds of keywords, of the off-side rule and of the lexemes
+// added by InsertStart and InsertEnd that are not rules. They can be passed to grammar.Validate to check the terminals in a
+// grammar.
+func (l *Lexer) Symbols() []parlex.Symbol {
+	symbols := make([]parlex.Symbol, len(l.order))
+	seen := make(map[int]bool, len(l.order))
+	for i, kind := range l.order {
+		symbols[i] = l.set.ByIdx(kind)
+		seen[kind] = true
+	}
+	for _, k := range l.keywords {
+		for _, kind := range k.kinds {
+			if !seen[kind] {
+				seen[kind] = true
+				symbols = append(symbols, l.set.ByIdx(kind))
+			}
+		}
+	}
+	if l.tabWidth > 0 {
+		for _, kind := range []string{Newline, Indent, Dedent} {
+			if sym := l.set.Str(kind); !seen[sym.Idx()] {
+				seen[sym.Idx()] = true
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+	for _, kind := range []string{l.insert.startKind, l.insert.endKind} {
+		if kind == "" {
+			continue
+		}
+		if sym := l.set.Str(kind); !seen[sym.Idx()] {
+			seen[sym.Idx()] = true
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
+
+// Pattern returns the regexp of the rule for a kind. For a kind that only comes
+// from keywords, the regexp matches those keywords. If the lexer does not
+// produce the kind, nil is returned.
+func (l *Lexer) Pattern(kind parlex.Symbol) *regexp.Regexp {
+	idx := l.set.Symbol(kind).Idx()
+	if idx < len(l.rules) && l.rules[idx] != nil {
+		return l.rules[idx].regexp()
+	}
+	var words []string
+	for _, k := range l.keywords {
+		for i, kk := range k.kinds {
+			if kk == idx {
+				words = append(words, regexp.QuoteMeta(k.words[i]))
+			}
+		}
+	}
+	if words == nil {
+		return nil
+	}
+	return regexp.MustCompile(strings.Join(words, "|"))
+}
+
+// Discards returns the kinds of the rules that discard what they match, in the
+// order they were defined.
+func (l *Lexer) Discards() []parlex.Symbol {
+	var symbols []parlex.Symbol
+	for _, kind := range l.order {
+		if l.rules[kind].discard {
+			symbols = append(symbols, l.set.ByIdx(kind))
+		}
+	}
+	return symbols
+}
+
 // String exports the lexer as a string. The output of String can be used to
 // make a copy of the lexer.
 func (l *Lexer) String() string {
 	var longest int
-	for _, rule := range l.rules {
-		if ln := parlex.SymLen(l.set.ByIdx(rule.kind)); ln > longest {
+	for _, kind := range l.order {
+		if ln := parlex.SymLen(l.set.ByIdx(kind)); ln > longest {
 			longest = ln
 		}
 	}
 
+	var longestModes int
+	for _, kind := range l.order {
+		if ln := len(l.rules[kind].modesString()); ln > longestModes {
+			longestModes = ln
+		}
+	}
+
 	format := fmt.Sprintf("%%-%ds %%s %%s", longest)
+	if longestModes > 0 {
+		format = fmt.Sprintf("%%-%ds %%-%ds %%s %%s", longestModes, longest)
+	}
 	lines := make([]string, len(l.order))
 	for i, kind := range l.order {
 		rule := l.rules[kind]
@@ -120,14 +561,54 @@ func (l *Lexer) String() string {
 		if rule.discard {
 			d = "-"
 		}
+		if rule.push != "" {
+			d += " push(" + rule.push + ")"
+		} else if rule.pop {
+			d += " pop"
+		}
+		if rule.number != 0 {
+			d += " priority(" + strconv.Itoa(rule.number) + ")"
+		}
 		str := l.set.ByIdx(kind).String()
-		re := rule.re.String()
-		if re == str {
+		re := rule.source()
+		if rule.fold {
+			re = "/" + re + "/i"
+		} else if re == str {
 			re = ""
 		} else {
 			re = "/" + re + "/"
 		}
-		lines[i] = fmt.Sprintf(format, str, re, d)
+		if longestModes > 0 {
+			lines[i] = fmt.Sprintf(format, rule.modesString(), str, re, d)
+		} else {
+			lines[i] = fmt.Sprintf(format, str, re, d)
+		}
+	}
+	for _, k := range l.keywords {
+		lines = append(lines, l.keywordsString(k))
+	}
+	if l.tabWidth > 0 {
+		lines = append(lines, "offside "+strconv.Itoa(l.tabWidth))
+	}
+	if l.policy != LongestMatch {
+		lines = append(lines, "policy "+l.policy.String())
 	}
 	return strings.Join(lines, "\n")
 }
+
+func (l *Lexer) keywordsString(k *keywords) string {
+	line := "keywords " + strings.Join(k.words, " ")
+	kinds := make([]string, len(k.kinds))
+	same := true
+	for i, kind := range k.kinds {
+		kinds[i] = l.set.ByIdx(kind).String()
+		same = same && kinds[i] == k.words[i]
+	}
+	if !same {
+		line += " -> " + strings.Join(kinds, " ")
+	}
+	if k.modes != nil {
+		line = modesString(k.modes) + " " + line
+	}
+	return line
+}
@@ -0,0 +1,289 @@
+package simplelexer
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// dfa combines the regular expressions of all the rules into a single lazily
+// constructed DFA. A state holds the NFA threads of each rule that is still
+// running. The threads of each rule are kept in priority order and the lower
+// priority threads are cut when a rule matches, so each rule matches the same
+// as it would with Go's leftmost-first regexp. States are only built as they
+// are reached and then cached.
+//
+// A compiled DFA is built from Tables. It has every state already and no
+// programs, so it is never changed and does not need the lock.
+type dfa struct {
+	sync.Mutex
+	progs    []*syntax.Prog
+	states   map[string]*dfaState
+	starts   map[string]*dfaState
+	compiled bool
+	none     *dfaState
+}
+
+type dfaThreads struct {
+	kind int
+	pcs  []uint32
+}
+
+type dfaState struct {
+	threads []dfaThreads
+	matched []int
+	ascii   [utf8.RuneSelf]*dfaState
+	other   map[rune]*dfaState
+	// a compiled state does not keep its threads, live is true if it had any.
+	// ranges hold its transitions for runes that are not ASCII, sorted by lo.
+	live   bool
+	ranges []dfaRange
+}
+
+type dfaRange struct {
+	lo, hi rune
+	to     *dfaState
+}
+
+func (s *dfaState) dead() bool {
+	return len(s.threads) == 0 && !s.live
+}
+
+// emptyOK are the empty width assertions the DFA can check without looking at
+// the next rune.
+const emptyOK = syntax.EmptyBeginText | syntax.EmptyBeginLine
+
+func newDFA(rules []*rule) (*dfa, error) {
+	d := &dfa{
+		progs:  make([]*syntax.Prog, len(rules)),
+		states: make(map[string]*dfaState),
+		starts: make(map[string]*dfaState),
+	}
+	for kind, r := range rules {
+		if r == nil {
+			continue
+		}
+		re, err := syntax.Parse(r.pattern(), syntax.Perl)
+		if err != nil {
+			return nil, err
+		}
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			return nil, err
+		}
+		for _, inst := range prog.Inst {
+			if inst.Op == syntax.InstEmptyWidth && syntax.EmptyOp(inst.Arg)&^emptyOK != 0 {
+				return nil, fmt.Errorf("Unsupported by DFA: /%s/", r.pattern())
+			}
+		}
+		d.progs[kind] = prog
+	}
+	return d, nil
+}
+
+// follow adds the thread at pc and the threads reachable from it without
+// consuming a rune to pcs. It returns true if a match was reached, in which
+// case no lower priority threads should be added.
+func follow(prog *syntax.Prog, pc uint32, empty syntax.EmptyOp, seen []bool, pcs []uint32) ([]uint32, bool) {
+	if seen[pc] {
+		return pcs, false
+	}
+	seen[pc] = true
+	inst := &prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstFail:
+		return pcs, false
+	case syntax.InstAlt, syntax.InstAltMatch:
+		pcs, matched := follow(prog, inst.Out, empty, seen, pcs)
+		if matched {
+			return pcs, true
+		}
+		return follow(prog, inst.Arg, empty, seen, pcs)
+	case syntax.InstNop, syntax.InstCapture:
+		return follow(prog, inst.Out, empty, seen, pcs)
+	case syntax.InstEmptyWidth:
+		if syntax.EmptyOp(inst.Arg)&^empty != 0 {
+			return pcs, false
+		}
+		return follow(prog, inst.Out, empty, seen, pcs)
+	case syntax.InstMatch:
+		return pcs, true
+	}
+	return append(pcs, pc), false
+}
+
+func matchRune(inst *syntax.Inst, c rune) bool {
+	switch inst.Op {
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return c != '\n'
+	}
+	return inst.MatchRune(c)
+}
+
+// start returns the start state for a mode, which only has threads for the
+// rules in that mode. The empty width assertions that hold at the start depend
+// on the preceding input.
+func (d *dfa) start(rules []*rule, mode string, empty syntax.EmptyOp) *dfaState {
+	key := startKey(mode, empty)
+	if s, ok := d.starts[key]; ok {
+		return s
+	}
+	if d.compiled {
+		return d.none
+	}
+	var threads []dfaThreads
+	var matched []int
+	for kind, prog := range d.progs {
+		if prog == nil || !rules[kind].in(mode) {
+			continue
+		}
+		pcs, m := follow(prog, uint32(prog.Start), empty, make([]bool, len(prog.Inst)), nil)
+		if m {
+			matched = append(matched, kind)
+		}
+		if len(pcs) > 0 {
+			threads = append(threads, dfaThreads{kind, pcs})
+		}
+	}
+	s := d.state(threads, matched)
+	d.starts[key] = s
+	return s
+}
+
+// next returns the state reached from s by consuming c.
+func (d *dfa) next(s *dfaState, c rune) *dfaState {
+	if c < utf8.RuneSelf {
+		if n := s.ascii[c]; n != nil {
+			return n
+		}
+	} else if n, ok := s.other[c]; ok {
+		return n
+	}
+	if d.compiled {
+		i := sort.Search(len(s.ranges), func(i int) bool { return s.ranges[i].hi >= c })
+		if i < len(s.ranges) && s.ranges[i].lo <= c {
+			return s.ranges[i].to
+		}
+		return d.none
+	}
+
+	var empty syntax.EmptyOp
+	if c == '\n' {
+		empty = syntax.EmptyBeginLine
+	}
+	var threads []dfaThreads
+	var matched []int
+	for _, t := range s.threads {
+		prog := d.progs[t.kind]
+		seen := make([]bool, len(prog.Inst))
+		var pcs []uint32
+		for _, pc := range t.pcs {
+			inst := &prog.Inst[pc]
+			if !matchRune(inst, c) {
+				continue
+			}
+			var m bool
+			pcs, m = follow(prog, inst.Out, empty, seen, pcs)
+			if m {
+				matched = append(matched, t.kind)
+				break
+			}
+		}
+		if len(pcs) > 0 {
+			threads = append(threads, dfaThreads{t.kind, pcs})
+		}
+	}
+
+	n := d.state(threads, matched)
+	if c < utf8.RuneSelf {
+		s.ascii[c] = n
+	} else {
+		if s.other == nil {
+			s.other = make(map[rune]*dfaState)
+		}
+		s.other[c] = n
+	}
+	return n
+}
+
+// state returns the cached state for the threads and matches, creating it if
+// it does not exist.
+func (d *dfa) state(threads []dfaThreads, matched []int) *dfaState {
+	var key []string
+	for _, t := range threads {
+		pcs := make([]string, len(t.pcs))
+		for i, pc := range t.pcs {
+			pcs[i] = strconv.Itoa(int(pc))
+		}
+		key = append(key, strconv.Itoa(t.kind)+":"+strings.Join(pcs, ","))
+	}
+	for _, m := range matched {
+		key = append(key, "m"+strconv.Itoa(m))
+	}
+	k := strings.Join(key, ";")
+	if s, ok := d.states[k]; ok {
+		return s
+	}
+	s := &dfaState{
+		threads: threads,
+		matched: matched,
+	}
+	d.states[k] = s
+	return s
+}
+
+// UseDFA compiles all the rules into a single DFA that is used by Lex instead
+// of trying each regexp in turn. This is much faster for large rule sets. The
+// DFA supports everything except the empty width assertions $, \A, \z and \b.
+// Lexing with a DFA holds a lock, so one Lexer will only lex one input at a
+// time.
+func (l *Lexer) UseDFA() error {
+	d, err := newDFA(l.rules)
+	if err != nil {
+		return err
+	}
+	l.dfa = d
+	return nil
+}
+
+// UseRegexp switches Lex back to trying the regexp of each rule in turn. This
+// is the default.
+func (l *Lexer) UseRegexp() {
+	for _, r := range l.rules {
+		if r != nil {
+			r.regexp()
+		}
+	}
+	l.dfa = nil
+}
+
+// findNextMatchDFA is the DFA equivalent of findNextMatch.
+func (op *lexOp) findNextMatchDFA() (int, int) {
+	kind, end, p := -1, op.cur, -1
+	var empty syntax.EmptyOp
+	if op.cur == 0 {
+		empty = emptyOK
+	} else if op.b[op.cur-1] == '\n' {
+		empty = syntax.EmptyBeginLine
+	}
+	s := op.dfa.start(op.rules, op.modes.current(), empty)
+	for pos := op.cur; ; {
+		for _, k := range s.matched {
+			if rp := op.rules[k].priority; op.compare(pos, rp, end, p) {
+				kind, end, p = k, pos, rp
+			}
+		}
+		if s.dead() || pos >= len(op.b) {
+			return kind, end
+		}
+		c, size := utf8.DecodeRune(op.b[pos:])
+		pos += size
+		s = op.dfa.next(s, c)
+	}
+}
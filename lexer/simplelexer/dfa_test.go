@@ -0,0 +1,126 @@
+package simplelexer
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDFAMatchesRegexp(t *testing.T) {
+	tests := []struct {
+		rules string
+		input string
+	}{
+		{
+			rules: `
+        if
+        word  /\w+/
+        space /\s+/ -
+      `,
+			input: "if iffy then $ else if",
+		},
+		{
+			// leftmost-first alternation and non-greedy repetition
+			rules: `
+        alt  /a|ab/
+        lazy /c+?/
+        b
+      `,
+			input: "ababccc",
+		},
+		{
+			rules: `
+        kw    /(?i)select|from/
+        word  /[\p{L}_]+/
+        num   /\d+(\.\d+)?/
+        str   /"([^"\\]|\\.)*"/
+        line  /(?m)^#.*/
+        nl    /\n/
+        space /[ \t]+/ -
+        any   /./
+      `,
+			input: "SELECT naïve, \"a\\\"b\" FROM t 3.14\n# comment\nx#y é",
+		},
+	}
+
+	for _, tc := range tests {
+		for _, byPriority := range []bool{false, true} {
+			lxr, err := New(tc.rules)
+			assert.NoError(t, err)
+			if byPriority {
+				lxr.ByPriority()
+			}
+			expected := lxr.Lex(tc.input)
+			assert.NoError(t, lxr.UseDFA())
+			assert.Equal(t, expected, lxr.Lex(tc.input), tc.input)
+		}
+	}
+}
+
+func TestDFAModes(t *testing.T) {
+	lxr, err := New(`
+          strStart /"/ push(STR)
+          word     /\w+/
+          space    /\s+/ -
+    <STR> strEnd   /"/ pop
+    <STR> text     /[^"]+/
+  `)
+	assert.NoError(t, err)
+	s := `say "hi there" now "again`
+	expected, expectedModes := lxr.LexModes(s)
+	assert.NoError(t, lxr.UseDFA())
+	lxs, modes := lxr.LexModes(s)
+	assert.Equal(t, expected, lxs)
+	assert.Equal(t, expectedModes, modes)
+}
+
+func TestDFAUnsupported(t *testing.T) {
+	lxr, err := New(`
+    word /\w+\b/
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, `Unsupported by DFA: /\w+\b/`, lxr.UseDFA().Error())
+}
+
+func benchLexer(b *testing.B) (*Lexer, string) {
+	var rules []string
+	for i := 0; i < 50; i++ {
+		rules = append(rules, fmt.Sprintf("kw%d /keyword%d/", i, i))
+	}
+	rules = append(rules,
+		`ident /[a-zA-Z_]\w*/`,
+		`num   /\d+(\.\d+)?/`,
+		`op    /[+\-*\/=<>!]=?/`,
+		`punc  /[(){};,]/`,
+		`space /\s+/ -`,
+	)
+	lxr, err := New(strings.Join(rules, "\n"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var src []string
+	for i := 0; i < 500; i++ {
+		src = append(src, fmt.Sprintf("keyword%d (x%d, 3.14) { y = x%d + %d; }", i%60, i, i, i))
+	}
+	return lxr, strings.Join(src, "\n")
+}
+
+func BenchmarkLexRegexp(b *testing.B) {
+	lxr, src := benchLexer(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lxr.Lex(src)
+	}
+}
+
+func BenchmarkLexDFA(b *testing.B) {
+	lxr, src := benchLexer(b)
+	if err := lxr.UseDFA(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lxr.Lex(src)
+	}
+}
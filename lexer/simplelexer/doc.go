@@ -7,6 +7,71 @@
 // indicate that the value should be dropped, which is often helpful to
 // eliminate whitespace.
 //
+// A regexp that ends with "/i" instead of "/" ignores case, which saves writing
+// patterns like [Ss][Ee][Ll][Ee][Cc][Tt]. IgnoreCase does the same for every
+// rule and the keywords. Either way, the value of the lexeme keeps the case of
+// the input.
+//
+// The input is read as UTF-8, so a rule can use Unicode classes like \p{L} or
+// \p{Nd} and never matches part of a rune. Lines and columns start at 1 and
+// columns count runes, while offsets are in bytes. Text that no rule matches
+// becomes an error lexeme made of whole runes.
+//
+//   ident /\p{L}[\p{L}\p{Nd}_]*/
+//
+// Rules can be limited to modes for context sensitive lexing, like strings with
+// interpolation. A rule that starts with a list of modes like "<STR,INTERP>"
+// is only used in those modes, otherwise it is only used in InitialMode. A
+// rule can end with "push(MODE)" to switch to a mode or "pop" to return to the
+// previous mode.
+//
+//   strStart /"/ push(STR)
+//   <STR> strEnd /"/ pop
+//   <STR> text /[^"]+/
+//
+// A fragment is a named regular expression that is not a rule. Rules and
+// fragments that come after it can use it as {name}. A line like
+// include "common.lex" reads the rules from another file; Load resolves
+// includes relative to the file being read and New relative to the working
+// directory.
+//
+//   fragment digit /[0-9]/
+//   int   /{digit}+/
+//   float /{digit}+\.{digit}+/
+//
+// A keywords line makes reserved words out of what other rules match, so an
+// identifier rule does not need a regexp that excludes them. When a rule
+// matches exactly one of the words, the lexeme gets the kind after "->" in the
+// same position. Without "->" each word is its own kind. Like rules, keywords
+// only apply in InitialMode unless they start with a list of modes.
+//
+//   keywords if else while -> kw_if kw_else kw_while
+//   ident /[A-Za-z_]\w*/
+//
+// A line like offside 4 turns on the off-side rule with a tab width of 4. The
+// lexer adds NEWLINE, INDENT and DEDENT lexemes from the indentation of each
+// line, like Python, so the rules for white space and newlines can simply
+// discard them.
+//
+// KeepTrivia keeps the lexemes that rules discard, like white space and
+// comments, as leading and trailing trivia on the lexemes around them, for
+// tools like formatters that need them.
+//
+// Lex requires the whole input as a string. For large inputs, LexReader lexes
+// an io.Reader incrementally using a bounded buffer and returns a Stream that
+// produces one lexeme at a time.
+//
+// When more than one rule matches, the Policy decides which one is used. By
+// default the longest match wins and a tie goes to the rule with the higher
+// priority number, then the one declared first. A line like "policy first" or
+// "policy priority" changes it and a rule can end with "priority(N)" to set
+// its number. Overlaps reports the pairs of rules that can match the same text,
+// so the choice is never a surprise.
+//
+// By default, Lex tries the regexp of every rule at each position. UseDFA
+// compiles all the rules into a single DFA, which is much faster for large rule
+// sets; BenchmarkLexDFA compares the two.
+//
 // An example of the simple lexer can be seen in
 // parlex/examples/parlexmath
 package simplelexer
@@ -0,0 +1,118 @@
+package simplelexer
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"strings"
+)
+
+// Kinds of the lexemes that are added by the off-side rule.
+const (
+	Indent  = "INDENT"
+	Dedent  = "DEDENT"
+	Newline = "NEWLINE"
+)
+
+// OffSide turns on the off-side rule for indentation sensitive languages like
+// Python. Lexemes are added before the first lexeme on each line: NEWLINE to
+// end the line before it, then INDENT if the line is indented more than the
+// line before it or one DEDENT for each level it closes. At the end, a NEWLINE
+// and a DEDENT for each open level are added. A tab moves to the next multiple
+// of tabWidth. A tabWidth less than 1 turns the off-side rule off.
+//
+// Lines are only counted if they start in InitialMode, so a mode can be used
+// to continue a line, for instance inside brackets.
+func (l *Lexer) OffSide(tabWidth int) *Lexer {
+	if tabWidth < 1 {
+		tabWidth = 0
+	}
+	l.tabWidth = tabWidth
+	return l
+}
+
+// indenter tracks the indentation of the lines for one pass of the lexer.
+type indenter struct {
+	*Lexer
+	levels []int
+	// width of the white space at the start of the current line and leading
+	// is true until something else is seen on the line
+	width   int
+	leading bool
+	// line the last lexeme ended on
+	line int
+}
+
+func (l *Lexer) newIndenter() *indenter {
+	if l.tabWidth == 0 {
+		return nil
+	}
+	return &indenter{
+		Lexer:   l,
+		levels:  []int{0},
+		leading: true,
+	}
+}
+
+// advance is called with each byte of the input as it is consumed.
+func (in *indenter) advance(c byte) {
+	switch {
+	case c == '\n':
+		in.width, in.leading = 0, true
+	case !in.leading:
+	case c == ' ':
+		in.width++
+	case c == '\t':
+		in.width += in.tabWidth - in.width%in.tabWidth
+	default:
+		in.leading = false
+	}
+}
+
+// before returns the lexemes to add before a lexeme that was matched in a mode.
+// It is called before the lexeme is consumed. A line that is indented less
+// than the line before it, but does not line up with an open level, gets an
+// error lexeme and starts a new level.
+func (in *indenter) before(lx *lexeme.Lexeme, mode string) []parlex.Lexeme {
+	start := in.line
+	in.line = lx.L + strings.Count(lx.V, "\n")
+	if mode != InitialMode || lx.L <= start {
+		return nil
+	}
+	var lxs []parlex.Lexeme
+	if start > 0 {
+		lxs = append(lxs, in.lexeme(Newline, lx.L, lx.C, lx.O))
+	}
+	top := in.levels[len(in.levels)-1]
+	if in.width > top {
+		in.levels = append(in.levels, in.width)
+		return append(lxs, in.lexeme(Indent, lx.L, lx.C, lx.O))
+	}
+	for in.width < top {
+		in.levels = in.levels[:len(in.levels)-1]
+		lxs = append(lxs, in.lexeme(Dedent, lx.L, lx.C, lx.O))
+		top = in.levels[len(in.levels)-1]
+	}
+	if in.width > top {
+		in.levels = append(in.levels, in.width)
+		err := lexeme.New(in.set.Str(in.Error)).At(lx.L, lx.C).AtOffset(lx.O)
+		lxs = append(lxs, &errLexeme{err})
+	}
+	return lxs
+}
+
+// end returns the lexemes to add at the end of the input.
+func (in *indenter) end(line, col, offset int) []parlex.Lexeme {
+	if in.line == 0 {
+		return nil
+	}
+	lxs := []parlex.Lexeme{in.lexeme(Newline, line, col, offset)}
+	for range in.levels[1:] {
+		lxs = append(lxs, in.lexeme(Dedent, line, col, offset))
+	}
+	in.levels = in.levels[:1]
+	return lxs
+}
+
+func (in *indenter) lexeme(kind string, line, col, offset int) parlex.Lexeme {
+	return lexeme.New(in.set.Str(kind)).At(line, col).AtOffset(offset)
+}
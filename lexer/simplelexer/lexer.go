@@ -7,19 +7,29 @@ import (
 	"github.com/adamcolton/parlex/symbol/setsymbol"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 // Lexer implements parlex.Lexer. It can take a string and produce a slice of
 // lexemes. Changing Error will change what Kind it assigns to error Lexemes
 // if it fails to lex a given input.
+//
+// Once it is configured, a Lexer is safe to use from many goroutines at once.
+// Each call to Lex keeps its own state. The regexps are safe for concurrent use
+// and the DFA is guarded by a lock.
 type Lexer struct {
-	order           []int
-	rules           []*rule
-	compare         func(e1, p1, e2, p2 int) bool
-	priorityCounter int
-	Error           string
-	set             *setsymbol.Set
-	insert          struct {
+	order      []int
+	rules      []*rule
+	compare    func(e1, p1, e2, p2 int) bool
+	policy     Policy
+	Error      string
+	set        *setsymbol.Set
+	dfa        *dfa
+	keywords   []*keywords
+	ignoreCase bool
+	tabWidth   int
+	trivia     bool
+	insert     struct {
 		startKind string
 		startVal  string
 		endKind   string
@@ -28,25 +38,159 @@ type Lexer struct {
 }
 
 // ByLength sets the lexer to choose the longest match and use priority to
-// decide a tie. This is the default.
-func (l *Lexer) ByLength() { l.compare = lengthThenPriority }
+// decide a tie. This is the default. It is the same as SetPolicy(LongestMatch).
+func (l *Lexer) ByLength() { l.SetPolicy(LongestMatch) }
 
 // ByPriority sets the lexer to choose the highest priority match and use the
-// length to decide a tie.
-func (l *Lexer) ByPriority() { l.compare = priorityThenLength }
+// length to decide a tie. It is the same as SetPolicy(PriorityNumbers).
+func (l *Lexer) ByPriority() { l.SetPolicy(PriorityNumbers) }
 
+// priorityThenLength is called with a priority of -1 before there is a match,
+// then any match that is not empty wins.
 func priorityThenLength(e1, p1, e2, p2 int) bool {
+	if p2 < 0 {
+		return e1 > e2
+	}
 	return p1 < p2 || (p1 == p2 && e1 > e2)
 }
 func lengthThenPriority(e1, p1, e2, p2 int) bool {
 	return e1 > e2 || (e1 == e2 && p1 < p2)
 }
 
+// InitialMode is the mode the lexer starts in. Rules that do not list any
+// modes belong to InitialMode.
+const InitialMode = "INITIAL"
+
 type rule struct {
 	kind     int
 	re       *regexp.Regexp
+	expr     string
 	discard  bool
 	priority int
+	number   int
+	modes    []string
+	push     string
+	pop      bool
+	fold     bool
+}
+
+func (r *rule) in(mode string) bool {
+	return inModes(r.modes, mode)
+}
+
+func (r *rule) modesString() string {
+	return modesString(r.modes)
+}
+
+func inModes(modes []string, mode string) bool {
+	if modes == nil {
+		return mode == InitialMode
+	}
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func modesString(modes []string) string {
+	if modes == nil {
+		return ""
+	}
+	return "<" + strings.Join(modes, ",") + ">"
+}
+
+// keywords is a table of reserved words. When a rule that is not discarded
+// matches exactly one of the words in one of the modes, the lexeme is given
+// the kind of the keyword instead of the kind of the rule.
+type keywords struct {
+	words []string
+	kinds []int
+	idx   map[string]int
+	modes []string
+}
+
+// keyword returns the kind for a lexeme of a kind with a value that was
+// matched in a mode. The words are indexed in lower case so they can also be
+// found when the lexer ignores case.
+func (l *Lexer) keyword(kind int, val, mode string) int {
+	if len(l.keywords) == 0 {
+		return kind
+	}
+	lower := strings.ToLower(val)
+	for _, k := range l.keywords {
+		if i, ok := k.idx[lower]; ok && (l.ignoreCase || k.words[i] == val) && inModes(k.modes, mode) {
+			return k.kinds[i]
+		}
+	}
+	return kind
+}
+
+// IgnoreCase makes every rule, including the ones added after it is called,
+// and the keywords match without regard to case. The value of a lexeme keeps
+// the case of the input. A single rule can ignore case by ending its regexp
+// with "/i".
+func (l *Lexer) IgnoreCase() error {
+	l.ignoreCase = true
+	for _, kind := range l.order {
+		l.rules[kind].foldCase()
+	}
+	if l.dfa != nil {
+		return l.UseDFA()
+	}
+	return nil
+}
+
+// foldCase recompiles the regexp of the rule to ignore case.
+func (r *rule) foldCase() {
+	if !r.fold {
+		r.fold = true
+		r.re = regexp.MustCompile("(?i)" + r.pattern())
+	}
+}
+
+// pattern returns the regexp of the rule as it is compiled.
+func (r *rule) pattern() string {
+	if r.re == nil {
+		return r.expr
+	}
+	return r.re.String()
+}
+
+// regexp returns the compiled regexp of the rule. A rule from Tables only has
+// the pattern, so it is compiled the first time it is needed.
+func (r *rule) regexp() *regexp.Regexp {
+	if r.re == nil {
+		r.re = regexp.MustCompile(r.expr)
+	}
+	return r.re
+}
+
+// source returns the regexp of the rule as it was defined.
+func (r *rule) source() string {
+	if r.fold {
+		return strings.TrimPrefix(r.pattern(), "(?i)")
+	}
+	return r.pattern()
+}
+
+// modeStack holds the modes that have been pushed. The last mode is the
+// current mode.
+type modeStack []string
+
+func (m modeStack) current() string {
+	return m[len(m)-1]
+}
+
+// transition pushes or pops a mode for a rule that matched. The initial mode
+// is never popped.
+func (m *modeStack) transition(r *rule) {
+	if r.push != "" {
+		*m = append(*m, r.push)
+	} else if r.pop && len(*m) > 1 {
+		*m = (*m)[:len(*m)-1]
+	}
 }
 
 type errLexeme struct {
@@ -64,25 +208,52 @@ type lexOp struct {
 	next     [][]int
 	errFlag  bool
 	errStart int
+	errLine  int
+	errCol   int
 	cur      int
-	lines    int
+	line     int
+	column   int
+	modes    modeStack
+	indent   *indenter
+	hide     *hider
 }
 
 // Lex takes a string and produces a slice of lexemes that can be consumed by a
 // parser.
 func (l *Lexer) Lex(str string) []parlex.Lexeme {
+	lxs, _ := l.LexModes(str)
+	return lxs
+}
+
+// LexModes lexes a string the same as Lex and also returns the mode stack at
+// the end of the input, starting with InitialMode. If there is more than one
+// mode, a mode was not closed, for instance an unterminated string.
+func (l *Lexer) LexModes(str string) ([]parlex.Lexeme, []string) {
+	modes := []string{InitialMode}
 	if str == "" {
-		return nil
+		return nil, modes
 	}
 	op := &lexOp{
-		Lexer: l,
-		b:     []byte(str),
+		Lexer:  l,
+		b:      []byte(str),
+		line:   1,
+		column: 1,
+		modes:  modes,
+		indent: l.newIndenter(),
+		hide:   l.newHider(),
 	}
 
 	if op.insert.startKind != "" {
 		op.lxs = append(op.lxs, lexeme.String(op.insert.startKind).Set(op.insert.startVal))
 	}
-	op.populateNext()
+	if op.dfa != nil {
+		if !op.dfa.compiled {
+			op.dfa.Lock()
+			defer op.dfa.Unlock()
+		}
+	} else {
+		op.populateNext()
+	}
 
 	for {
 		lx, lxEnd := op.findNextMatch()
@@ -90,27 +261,51 @@ func (l *Lexer) Lex(str string) []parlex.Lexeme {
 			if !op.errFlag {
 				op.errFlag = true
 				op.errStart = op.cur
+				op.errLine, op.errCol = op.line, op.col()
 			}
-			op.cur++
+			op.advance(runeEnd(op.b, op.cur))
 		} else {
 			op.checkError()
-			if !op.rules[lx.K.(*setsymbol.Symbol).Idx()].discard {
+			kind := lx.K.(*setsymbol.Symbol).Idx()
+			r := op.rules[kind]
+			if !r.discard {
+				mode := op.modes.current()
+				if kw := op.keyword(kind, lx.V, mode); kw != kind {
+					lx.K = op.set.ByIdx(kw)
+				}
+				if op.indent != nil {
+					op.lxs = append(op.lxs, op.indent.before(lx, mode)...)
+				}
 				op.lxs = append(op.lxs, lx)
+				if op.hide != nil {
+					op.hide.attach(lx)
+				}
+			} else if op.hide != nil {
+				op.hide.hide(lx)
 			}
-			op.cur = lxEnd
+			op.modes.transition(r)
+			op.advance(lxEnd)
 		}
 		if op.cur >= len(op.b) {
 			break
 		}
-		op.updateNext()
+		if op.dfa == nil {
+			op.updateNext()
+		}
 	}
 	op.checkError()
+	if op.hide != nil {
+		op.hide.end()
+	}
+	if op.indent != nil {
+		op.lxs = append(op.lxs, op.indent.end(op.line, op.col(), op.cur)...)
+	}
 
 	if op.insert.endKind != "" {
 		op.lxs = append(op.lxs, lexeme.String(op.insert.endKind).Set(op.insert.endVal))
 	}
 
-	return op.lxs
+	return op.lxs, op.modes
 }
 
 func (op *lexOp) checkError() {
@@ -120,21 +315,53 @@ func (op *lexOp) checkError() {
 	op.errFlag = false
 	val := string(op.b[op.errStart:op.cur])
 	errKind := op.set.Str(op.Error)
-	lxm := lexeme.New(errKind).Set(val)
+	lxm := lexeme.New(errKind).Set(val).At(op.errLine, op.errCol).AtOffset(op.errStart)
 	op.lxs = append(op.lxs, &errLexeme{lxm})
 }
 
+// advance moves cur forward to the given offset, keeping track of the current
+// line and column.
+func (op *lexOp) advance(to int) {
+	for ; op.cur < to; op.cur++ {
+		if op.b[op.cur] == '\n' {
+			op.line++
+			op.column = 1
+		} else if utf8.RuneStart(op.b[op.cur]) {
+			op.column++
+		}
+		if op.indent != nil {
+			op.indent.advance(op.b[op.cur])
+		}
+	}
+}
+
+// col returns the column of cur. Columns start at 1 and count runes, not bytes.
+func (op *lexOp) col() int {
+	return op.column
+}
+
+// runeEnd returns the offset after the rune that starts at i, so a byte that
+// cannot be lexed does not split a rune. Bytes that are not valid UTF-8 are
+// taken one at a time.
+func runeEnd(b []byte, i int) int {
+	for i++; i < len(b) && !utf8.RuneStart(b[i]); i++ {
+	}
+	return i
+}
+
 func (op *lexOp) populateNext() {
 	op.next = make([][]int, len(op.rules))
 	for kind, r := range op.rules {
-		op.next[kind] = r.re.FindIndex(op.b)
+		if r != nil {
+			op.next[kind] = r.regexp().FindIndex(op.b)
+		}
 	}
 }
 
 func (op *lexOp) updateNext() {
 	for kind, loc := range op.next {
 		if loc != nil && loc[0] <= op.cur {
-			loc := op.rules[kind].re.FindIndex(op.b[op.cur:])
+			loc := op.rules[kind].regexp().FindIndex(op.b[op.cur:])
 			if loc != nil {
 				loc[0] += op.cur
 				loc[1] += op.cur
@@ -149,9 +376,21 @@ func (op *lexOp) findNextMatch() (*lexeme.Lexeme, int) {
 	lxEnd := op.cur
 	lxP := -1
 
-	// look in next for matches and take the longest one
+	if op.dfa != nil {
+		var kind int
+		kind, lxEnd = op.findNextMatchDFA()
+		if lxEnd > op.cur {
+			lx.K = op.set.ByIdx(kind)
+			lx.V = string(op.b[op.cur:lxEnd])
+		}
+		lx.L, lx.C, lx.O = op.line, op.col(), op.cur
+		return lx, lxEnd
+	}
+
+	// look in next for matches in the current mode and take the longest one
+	mode := op.modes.current()
 	for kind, loc := range op.next {
-		if loc != nil && loc[0] == op.cur {
+		if loc != nil && loc[0] == op.cur && op.rules[kind].in(mode) {
 			p := op.rules[kind].priority
 			if op.compare(loc[1], p, lxEnd, lxP) {
 				lx.K = op.set.ByIdx(kind)
@@ -162,10 +401,7 @@ func (op *lexOp) findNextMatch() (*lexeme.Lexeme, int) {
 		}
 	}
 
-	lx.L = op.lines
-	lx.C = strings.LastIndex(string(op.b[:op.cur]), "\n")
-	lx.C = op.cur - lx.C
-	op.lines += strings.Count(lx.V, "\n")
+	lx.L, lx.C, lx.O = op.line, op.col(), op.cur
 
 	return lx, lxEnd
 }
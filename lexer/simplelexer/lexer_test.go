@@ -3,27 +3,37 @@ package simplelexer
 import (
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 )
 
 func TestLexStr(t *testing.T) {
 	tests := []struct {
 		str, kind, regex string
-		hasMinus         bool
+		hasMinus, fold   bool
 	}{
-		{"test", "test", "", false},
-		{"foo /bar/ -", "foo", "bar", true},
-		{"foo/bar/ -", "foo", "bar", true},             // space not actually required
-		{"   foo  \t  /bar/ -   ", "foo", "bar", true}, // leading and trailing spaces are ignored
+		{"test", "test", "", false, false},
+		{"foo /bar/ -", "foo", "bar", true, false},
+		{"foo/bar/ -", "foo", "bar", true, false},             // space not actually required
+		{"   foo  \t  /bar/ -   ", "foo", "bar", true, false}, // leading and trailing spaces are ignored
+		{"foo /bar/i -", "foo", "bar", true, true},
 	}
 
 	for _, test := range tests {
 		m := lexStr.FindStringSubmatch(test.str)
-		assert.Equal(t, 4, len(m))
+		assert.Equal(t, 5, len(m))
 		assert.Equal(t, test.kind, m[1])
 		assert.Equal(t, test.regex, m[2])
-		assert.True(t, test.hasMinus == (m[3] == "-"))
+		assert.True(t, test.fold == (m[3] == "i"))
+		assert.True(t, test.hasMinus == (m[4] == "-"))
 	}
 
 	m := lexStr.FindStringSubmatch("  /  ")
@@ -89,6 +99,10 @@ func TestLexDiscard(t *testing.T) {
 		assert.Equal(t, "test", lxs[3].Kind().String())
 		assert.Equal(t, "test", lxs[3].Value())
 	}
+
+	if discards := lxr.Discards(); assert.Len(t, discards, 1) {
+		assert.Equal(t, "space", discards[0].String())
+	}
 }
 
 func TestError(t *testing.T) {
@@ -96,3 +110,653 @@ func TestError(t *testing.T) {
 	err = &errLexeme{lexeme.String("Error").At(10, 10)}
 	assert.Error(t, err)
 }
+
+func TestLexPos(t *testing.T) {
+	lxr, err := New(`
+    word  /\w+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	lxs := lxr.Lex("this is\n  a $ test")
+
+	expected := []*lexeme.Lexeme{
+		lexeme.String("word").Set("this").At(1, 1).AtOffset(0),
+		lexeme.String("word").Set("is").At(1, 6).AtOffset(5),
+		lexeme.String("word").Set("a").At(2, 3).AtOffset(10),
+		lexeme.String("Error").Set("$").At(2, 5).AtOffset(12),
+		lexeme.String("word").Set("test").At(2, 7).AtOffset(14),
+	}
+	if assert.Len(t, lxs, len(expected)) {
+		for i, e := range expected {
+			lx := lxs[i]
+			assert.Equal(t, e.K.String(), lx.Kind().String())
+			assert.Equal(t, e.V, lx.Value())
+			l, c := lx.Pos()
+			assert.Equal(t, e.L, l, e.V)
+			assert.Equal(t, e.C, c, e.V)
+			assert.Equal(t, e.O, parlex.Offset(lx), e.V)
+		}
+	}
+}
+
+func TestLexReader(t *testing.T) {
+	lxr, err := New(`
+    test
+    word    /\w+/
+    comment /\/\*[^*]*\*\//
+    space   /\s+/ -
+  `)
+	assert.NoError(t, err)
+	lxr.
+		InsertStart("START_K", "START_V").
+		InsertEnd("END_K", "END_V")
+	s := "this is\n  a $ test /* a long comment */ of\nthe $$ stream lexer"
+	expected := lxr.Lex(s)
+
+	for _, size := range []int{2, 5, 16, DefaultBufferSize} {
+		stream := lxr.LexReaderSize(iotest.OneByteReader(strings.NewReader(s)), size)
+		var lxs []parlex.Lexeme
+		for lx := stream.Next(); lx != nil; lx = stream.Next() {
+			lxs = append(lxs, lx)
+		}
+		assert.NoError(t, stream.Err())
+		assert.Equal(t, expected, lxs, size)
+	}
+}
+
+func TestLexReaderErr(t *testing.T) {
+	lxr, err := New(`
+    word  /\w+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	stream := lxr.LexReader(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("abc"))))
+	lx := stream.Next()
+	if assert.NotNil(t, lx) {
+		assert.Equal(t, "a", lx.Value())
+	}
+	assert.Nil(t, stream.Next())
+	assert.Equal(t, iotest.ErrTimeout, stream.Err())
+}
+
+func TestLexReaderRunes(t *testing.T) {
+	lxr, err := New(`
+    ident /\p{L}+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+
+	// short reads and small buffers end in the middle of a rune
+	long := strings.Repeat("日", 40000)
+	for _, s := range []string{"x日本a", "größe λ " + long + " π"} {
+		expected := lxr.Lex(s)
+		for _, size := range []int{4, 7, DefaultBufferSize} {
+			stream := lxr.LexReaderSize(iotest.OneByteReader(strings.NewReader(s)), size)
+			var lxs []parlex.Lexeme
+			for lx := stream.Next(); lx != nil; lx = stream.Next() {
+				lxs = append(lxs, lx)
+			}
+			assert.NoError(t, stream.Err())
+			assert.Equal(t, expected, lxs, size)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	lxr, err := New(`
+    ident /\p{L}[\p{L}\p{Nd}]*/
+    num   /\p{Nd}+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	s := "größe = ٤٢\n  λx ∑ π2"
+	expected := []*lexeme.Lexeme{
+		lexeme.String("ident").Set("größe").At(1, 1).AtOffset(0),
+		lexeme.String("Error").Set("=").At(1, 7).AtOffset(8),
+		lexeme.String("num").Set("٤٢").At(1, 9).AtOffset(10),
+		lexeme.String("ident").Set("λx").At(2, 3).AtOffset(17),
+		lexeme.String("Error").Set("∑").At(2, 6).AtOffset(21),
+		lexeme.String("ident").Set("π2").At(2, 8).AtOffset(25),
+	}
+	check := func(lxs []parlex.Lexeme, name string) {
+		if !assert.Len(t, lxs, len(expected), name) {
+			return
+		}
+		for i, e := range expected {
+			lx := lxs[i]
+			assert.Equal(t, e.K.String(), lx.Kind().String(), name)
+			assert.Equal(t, e.V, lx.Value(), name)
+			l, c := lx.Pos()
+			assert.Equal(t, e.L, l, name+" "+e.V)
+			assert.Equal(t, e.C, c, name+" "+e.V)
+			assert.Equal(t, e.O, parlex.Offset(lx), name+" "+e.V)
+		}
+	}
+
+	check(lxr.Lex(s), "regexp")
+	for _, size := range []int{2, 5, DefaultBufferSize} {
+		var lxs []parlex.Lexeme
+		stream := lxr.LexReaderSize(iotest.OneByteReader(strings.NewReader(s)), size)
+		for lx := stream.Next(); lx != nil; lx = stream.Next() {
+			lxs = append(lxs, lx)
+		}
+		check(lxs, "stream")
+	}
+	assert.NoError(t, lxr.UseDFA())
+	check(lxr.Lex(s), "dfa")
+
+	// a byte that is not valid UTF-8 is an error of its own
+	lxs := lxr.Lex("a\xffb")
+	if assert.Len(t, lxs, 3) {
+		assert.Equal(t, "\xff", lxs[1].Value())
+		_, c := lxs[2].Pos()
+		assert.Equal(t, 3, c)
+	}
+}
+
+func TestModes(t *testing.T) {
+	lxr, err := New(`
+          strStart  /"/ push(STR)
+          word      /\w+/
+          space     /\s+/ -
+    <STR> strEnd    /"/ pop
+    <STR> interpStart /\$\{/ push(INTERP)
+    <STR> text      /[^"$]+/
+    <INTERP> interpEnd /\}/ pop
+    <INTERP,INITIAL> op /\+/
+    <INTERP> ident  /\w+/
+    <INTERP> ispace /\s+/ -
+  `)
+	assert.NoError(t, err)
+
+	lxs, modes := lxr.LexModes(`say "hi ${a + b} there" now`)
+	expected := []string{
+		"word:say", "strStart:\"", "text:hi ", "interpStart:${", "ident:a",
+		"op:+", "ident:b", "interpEnd:}", "text: there", "strEnd:\"", "word:now",
+	}
+	got := make([]string, len(lxs))
+	for i, lx := range lxs {
+		got[i] = lx.Kind().String() + ":" + lx.Value()
+	}
+	assert.Equal(t, expected, got)
+	assert.Equal(t, []string{InitialMode}, modes)
+
+	_, modes = lxr.LexModes(`say "hi ${a`)
+	assert.Equal(t, []string{InitialMode, "STR", "INTERP"}, modes)
+
+	stream := lxr.LexReader(strings.NewReader(`say "hi ${a + b} there" now`))
+	got = got[:0]
+	for lx := stream.Next(); lx != nil; lx = stream.Next() {
+		got = append(got, lx.Kind().String()+":"+lx.Value())
+		if lx.Kind().String() == "ident" {
+			assert.Equal(t, []string{InitialMode, "STR", "INTERP"}, stream.Modes())
+		}
+	}
+	assert.Equal(t, expected, got)
+
+	lxr2, err := New(lxr.String())
+	assert.NoError(t, err)
+	assert.Equal(t, lxr.String(), lxr2.String())
+
+	_, err = New(`start /"/ push(STR)`)
+	assert.Equal(t, "Undefined Mode: STR", err.Error())
+}
+
+func TestFragments(t *testing.T) {
+	lxr, err := New(`
+    fragment digit /[0-9]/
+    fragment int   /{digit}+/
+    float /{int}\.{int}/
+    int   /{int}/
+    pair  /\{{digit}{2}\}/
+    word  /\p{L}+/
+    space /\s+/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"float", "int", "pair", "word", "space"}, symbolStrings(lxr.Symbols()))
+	lxs := lxr.Lex("12 3.45 {67}")
+	if assert.Len(t, lxs, 3) {
+		assert.Equal(t, "int", lxs[0].Kind().String())
+		assert.Equal(t, "float", lxs[1].Kind().String())
+		assert.Equal(t, "pair", lxs[2].Kind().String())
+	}
+
+	// a rule that is just the word fragment is still a rule
+	lxr, err = New("fragment")
+	assert.NoError(t, err)
+	assert.Equal(t, "fragment", lxr.Lex("fragment")[0].Kind().String())
+
+	for def, msg := range map[string]string{
+		"int /{digit}+/":                               "Undefined Fragment: digit at line 1 (int /{digit}+/)",
+		"fragment d /\\d/\nfragment d /[0-9]/":         "Duplicate Fragment: d at line 2 (fragment d /[0-9]/)",
+		"fragment int /{digit}/\nfragment digit /\\d/": "Undefined Fragment: digit at line 1 (fragment int /{digit}/)",
+	} {
+		_, err := New(def)
+		if assert.Error(t, err, def) {
+			assert.Equal(t, msg, err.Error(), def)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "simplelexer")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, src string) string {
+		name = filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.NoError(t, ioutil.WriteFile(name, []byte(src), 0644))
+		return name
+	}
+	write("common/numbers.lex", `
+    fragment digit /[0-9]/
+    int /{digit}+/
+  `)
+	write("common/space.lex", `space /\s+/ -`)
+	main := write("calc.lex", `
+    include "common/numbers.lex"
+    include "common/space.lex"
+    op /[+\-]/
+    hex /0x{digit}+/
+  `)
+
+	lxr, err := Load(main)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"int", "space", "op", "hex"}, symbolStrings(lxr.Symbols()))
+	assert.Equal(t, 3, len(lxr.Lex("1 + 0x2")))
+
+	lxr, err = New(`include "` + filepath.Join(dir, "common/space.lex") + `"`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"space"}, symbolStrings(lxr.Symbols()))
+	lxr.InsertStart("space", " ").InsertEnd("eof", "")
+	assert.Equal(t, []string{"space", "eof"}, symbolStrings(lxr.Symbols()))
+
+	write("a.lex", `include "b.lex"`)
+	write("b.lex", `include "a.lex"`)
+	_, err = Load(filepath.Join(dir, "a.lex"))
+	assert.Equal(t, "Include Cycle: "+filepath.Join(dir, "a.lex")+" at "+filepath.Join(dir, "b.lex")+` line 1 (include "a.lex")`, err.Error())
+
+	_, err = Load(filepath.Join(dir, "missing.lex"))
+	assert.Error(t, err)
+}
+
+func symbolStrings(symbols []parlex.Symbol) []string {
+	strs := make([]string, len(symbols))
+	for i, s := range symbols {
+		strs[i] = s.String()
+	}
+	return strs
+}
+
+func TestKeywords(t *testing.T) {
+	lxr, err := New(`
+    keywords if else while -> kw_if kw_else kw_while
+    ident  /[a-z]+/
+    str    /"/ push(STR)
+    space  /\s+/ -
+    <STR> strEnd /"/ pop
+    <STR> text   /[^"]+/
+    <STR> keywords else
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"ident", "str", "space", "strEnd", "text", "kw_if", "kw_else", "kw_while", "else"}, symbolStrings(lxr.Symbols()))
+
+	expected := []string{"kw_if:if", "ident:iffy", "kw_while:while", "str:\"", "text:if", "strEnd:\"", "kw_else:else", "str:\"", "else:else", "strEnd:\""}
+	input := `if iffy while "if" else "else"`
+	got := func(lxs []parlex.Lexeme) []string {
+		strs := make([]string, len(lxs))
+		for i, lx := range lxs {
+			strs[i] = lx.Kind().String() + ":" + lx.Value()
+		}
+		return strs
+	}
+	assert.Equal(t, expected, got(lxr.Lex(input)))
+
+	var lxs []parlex.Lexeme
+	stream := lxr.LexReader(strings.NewReader(input))
+	for lx := stream.Next(); lx != nil; lx = stream.Next() {
+		lxs = append(lxs, lx)
+	}
+	assert.Equal(t, expected, got(lxs))
+
+	assert.NoError(t, lxr.UseDFA())
+	assert.Equal(t, expected, got(lxr.Lex(input)))
+
+	cp, err := New(lxr.String())
+	if assert.NoError(t, err) {
+		assert.Equal(t, lxr.String(), cp.String())
+		assert.Equal(t, expected, got(cp.Lex(input)))
+	}
+
+	for def, msg := range map[string]string{
+		"keywords if else -> kw_if": "Bad Keywords: 2 words and 1 kinds at line 1 (keywords if else -> kw_if)",
+		"keywords if if":            "Duplicate Keyword: if at line 1 (keywords if if)",
+	} {
+		_, err := New(def)
+		if assert.Error(t, err, def) {
+			assert.Equal(t, msg, err.Error(), def)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	lxr, err := New(`
+    keywords if else -> kw kw
+    ident  /[a-z]+/
+    space  /\s+/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "[a-z]+", lxr.Pattern(stringsymbol.Symbol("ident")).String())
+	assert.Equal(t, "if|else", lxr.Pattern(stringsymbol.Symbol("kw")).String())
+	assert.Nil(t, lxr.Pattern(stringsymbol.Symbol("missing")))
+}
+
+func TestIgnoreCase(t *testing.T) {
+	lxr, err := New(`
+    select /select/i
+    from   /from/i
+    ident  /[a-z]+/
+    space  /\s+/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	got := func(lxs []parlex.Lexeme) []string {
+		strs := make([]string, len(lxs))
+		for i, lx := range lxs {
+			strs[i] = lx.Kind().String() + ":" + lx.Value()
+		}
+		return strs
+	}
+	assert.Equal(t, []string{"select:SELECT", "ident:a", "from:From", "ident:t", "Error:B"}, got(lxr.Lex("SELECT a From t B")))
+
+	cp, err := New(lxr.String())
+	if assert.NoError(t, err) {
+		assert.Equal(t, lxr.String(), cp.String())
+		assert.Contains(t, cp.String(), "/select/i")
+	}
+
+	lxr, err = New(`
+    keywords select from -> kw_select kw_from
+    ident /[a-z]+/
+    space /\s+/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"kw_select:select", "Error:SELECT"}, got(lxr.Lex("select SELECT")))
+	assert.NoError(t, lxr.IgnoreCase())
+	assert.NoError(t, lxr.Add(stringsymbol.Symbol("num"), regexp.MustCompile(`#x[0-9]+`), false))
+	expected := []string{"kw_select:select", "kw_select:SELECT", "kw_from:From", "ident:Tbl", "num:#X12"}
+	input := "select SELECT From Tbl #X12"
+	assert.Equal(t, expected, got(lxr.Lex(input)))
+	assert.NoError(t, lxr.UseDFA())
+	assert.Equal(t, expected, got(lxr.Lex(input)))
+
+	var lxs []parlex.Lexeme
+	stream := lxr.LexReader(strings.NewReader(input))
+	for lx := stream.Next(); lx != nil; lx = stream.Next() {
+		lxs = append(lxs, lx)
+	}
+	assert.Equal(t, expected, got(lxs))
+}
+
+func TestOffSide(t *testing.T) {
+	lxr, err := New(`
+    offside 4
+    word   /\w+/
+    colon  /:/
+    open   /\(/ push(PAREN)
+    <PAREN> close /\)/ pop
+    <PAREN> arg   /\w+/
+    <PAREN> pspace /\s+/ -
+    space  /[ \t]+/ -
+    nl     /\n/ -
+    comment /#[^\n]*/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	input := "if a:\n    b\n\t# comment\n\tif c:\n\n\t  d(e\nf)\n  g\nh"
+	expected := []string{
+		"word:if", "word:a", "colon::",
+		"NEWLINE:", "INDENT:", "word:b",
+		"NEWLINE:", "word:if", "word:c", "colon::",
+		"NEWLINE:", "INDENT:", "word:d", "open:(", "arg:e", "arg:f", "close:)",
+		"NEWLINE:", "DEDENT:", "DEDENT:", "Error:", "word:g",
+		"NEWLINE:", "DEDENT:", "word:h",
+		"NEWLINE:",
+	}
+	got := func(lxs []parlex.Lexeme) []string {
+		strs := make([]string, len(lxs))
+		for i, lx := range lxs {
+			strs[i] = lx.Kind().String() + ":" + lx.Value()
+		}
+		return strs
+	}
+	lxs := lxr.Lex(input)
+	assert.Equal(t, expected, got(lxs))
+	assert.Equal(t, 2, lxs[3].(*lexeme.Lexeme).L)
+
+	lxs = nil
+	stream := lxr.LexReaderSize(strings.NewReader(input), 4)
+	for lx := stream.Next(); lx != nil; lx = stream.Next() {
+		lxs = append(lxs, lx)
+	}
+	assert.Equal(t, expected, got(lxs))
+
+	assert.Contains(t, symbolStrings(lxr.Symbols()), Indent)
+	cp, err := New(lxr.String())
+	if assert.NoError(t, err) {
+		assert.Equal(t, lxr.String(), cp.String())
+		assert.Equal(t, expected, got(cp.Lex(input)))
+	}
+
+	assert.Equal(t, []string{"word:x"}, got(lxr.OffSide(0).Lex("  x")))
+}
+
+func TestKeepTrivia(t *testing.T) {
+	lxr, err := New(`
+    word    /\w+/
+    space   /[ \t]+/ -
+    nl      /\n/ -
+    comment /#[^\n]*/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	lxr.KeepTrivia()
+	input := "# top\nfoo # about foo\n\nbar baz # end"
+	values := func(lxs []parlex.Lexeme) []string {
+		strs := make([]string, len(lxs))
+		for i, lx := range lxs {
+			strs[i] = lx.Value()
+		}
+		return strs
+	}
+	check := func(lxs []parlex.Lexeme) {
+		if !assert.Len(t, lxs, 3) {
+			return
+		}
+		assert.Equal(t, []string{"# top", "\n"}, values(parlex.Leading(lxs[0])))
+		assert.Equal(t, []string{" ", "# about foo", "\n"}, values(parlex.Trailing(lxs[0])))
+		assert.Equal(t, []string{"\n"}, values(parlex.Leading(lxs[1])))
+		assert.Equal(t, []string{" "}, values(parlex.Trailing(lxs[1])))
+		assert.Nil(t, parlex.Leading(lxs[2]))
+		assert.Equal(t, []string{" ", "# end"}, values(parlex.Trailing(lxs[2])))
+	}
+	check(lxr.Lex(input))
+
+	var lxs []parlex.Lexeme
+	stream := lxr.LexReader(strings.NewReader(input))
+	for lx := stream.Next(); lx != nil; lx = stream.Next() {
+		lxs = append(lxs, lx)
+	}
+	check(lxs)
+}
+
+func TestPolicy(t *testing.T) {
+	lxr, err := New(`
+    word  /[a-z]+/
+    kw    /iffy|if/
+    num   /[0-9]+/
+    space /\s+/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	kinds := func(lxs []parlex.Lexeme) []string {
+		strs := make([]string, len(lxs))
+		for i, lx := range lxs {
+			strs[i] = lx.Kind().String() + ":" + lx.Value()
+		}
+		return strs
+	}
+	input := "if iffy"
+	assert.Equal(t, []string{"word:if", "word:iffy"}, kinds(lxr.Lex(input)))
+
+	assert.NoError(t, lxr.SetPriority(stringsymbol.Symbol("kw"), 1))
+	assert.Equal(t, []string{"kw:if", "kw:iffy"}, kinds(lxr.Lex(input)))
+	assert.Equal(t, "Unknown Kind: nope", lxr.SetPriority(stringsymbol.Symbol("nope"), 1).Error())
+
+	lxr.SetPolicy(FirstDeclared)
+	assert.Equal(t, []string{"word:if", "word:iffy"}, kinds(lxr.Lex(input)))
+
+	lxr.SetPolicy(PriorityNumbers)
+	assert.Equal(t, []string{"kw:if", "kw:iffy"}, kinds(lxr.Lex("if iffy")))
+	assert.Equal(t, []string{"kw:iffy", "word:x"}, kinds(lxr.Lex("iffyx")))
+
+	cp, err := New(lxr.String())
+	if assert.NoError(t, err) {
+		assert.Equal(t, lxr.String(), cp.String())
+		assert.Contains(t, cp.String(), "policy priority")
+		assert.Contains(t, cp.String(), "priority(1)")
+	}
+
+	_, err = New("policy best")
+	assert.Equal(t, "Unknown Policy: best at line 1 (policy best)", err.Error())
+}
+
+func TestOverlaps(t *testing.T) {
+	lxr, err := New(`
+    ident  /[a-z_]+/
+    select /(?i)select/
+    num    /[0-9]+/
+    float  /[0-9]*\.[0-9]+/
+    word   /\w+\b/
+    str    /"/ push(STR)
+    <STR> text /[a-z]+/
+    <STR> end  /"/ pop
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var got []string
+	for _, o := range lxr.Overlaps() {
+		got = append(got, o.String())
+	}
+	assert.Equal(t, []string{`ident and select both match "select", ident wins`}, got)
+
+	lxr.SetPolicy(PriorityNumbers)
+	assert.NoError(t, lxr.SetPriority(stringsymbol.Symbol("select"), 1))
+	o := lxr.Overlaps()
+	if assert.Len(t, o, 1) {
+		assert.Equal(t, "select", o[0].Winner.String())
+	}
+}
+
+func TestLexConcurrent(t *testing.T) {
+	lxr, err := New(`
+    word  /\w+/
+    int   /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	srcs := []string{"this is a test", "1 2 3", "a1 b2 c3 !", "\n\nx"}
+
+	for _, useDFA := range []bool{false, true} {
+		if useDFA {
+			assert.NoError(t, lxr.UseDFA())
+		}
+		expected := make([]string, len(srcs))
+		for i, src := range srcs {
+			expected[i] = parlex.LexemeString(lxr.Lex(src)...)
+		}
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for n := 0; n < 20; n++ {
+					i := (g + n) % len(srcs)
+					assert.Equal(t, expected[i], parlex.LexemeString(lxr.Lex(srcs[i])...))
+				}
+			}(g)
+		}
+		wg.Wait()
+	}
+}
+
+func TestAddLiterals(t *testing.T) {
+	lxr, err := New(`
+    ident /[a-z]+/
+    int   /\d+/
+    op    /[=<>!]+/
+    (     /\(/
+    space /\s+/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, lxr.AddLiterals("if", "==", "(", ")", "if"))
+	assert.Equal(t, []string{"ident", "int", "op", "(", "space", ")", "if", "=="}, symbolStrings(lxr.Symbols()))
+
+	kinds := func(in string) string {
+		var strs []string
+		for _, lx := range lxr.Lex(in) {
+			strs = append(strs, lx.Kind().String())
+		}
+		return strings.Join(strs, " ")
+	}
+	assert.Equal(t, "if ( ident == ident ) )", kinds("if (x == ifx) )"))
+	// a literal does not split a longer match
+	assert.Equal(t, "op", kinds("==="))
+}
+
+func TestCompile(t *testing.T) {
+	def := `
+    int   /\d+/
+    bad   /(/
+    int   /[0-9]+/
+    space /\s+/ -
+  `
+	_, err := Compile(def)
+	errs, ok := err.(parlex.DefinitionErrors)
+	if assert.True(t, ok) && assert.Len(t, errs, 2) {
+		de := errs[0].(*parlex.DefinitionError)
+		assert.Equal(t, 3, de.Line)
+		assert.Equal(t, "bad   /(/", strings.TrimSpace(de.Text))
+		assert.Equal(t, "error parsing regexp: missing closing ): `(` at line 3 (bad   /(/)", de.Error())
+		assert.Equal(t, "Duplicate Kind: int at line 4 (int   /[0-9]+/)", errs[1].Error())
+	}
+
+	// New stops at the first one
+	_, err = New(def)
+	assert.Equal(t, errs[0], err)
+
+	lxr, err := Compile(`int /\d+/`)
+	assert.NoError(t, err)
+	assert.Equal(t, "int", lxr.Lex("1")[0].Kind().String())
+
+	assert.PanicsWithError(t, errs[0].Error(), func() { parlex.MustLexer(New(def)) })
+}
@@ -0,0 +1,234 @@
+package simplelexer
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy decides which rule wins when more than one rule matches at the same
+// position.
+type Policy int
+
+const (
+	// LongestMatch chooses the longest match. If two rules match the same
+	// length, the one with the higher priority number wins and then the one
+	// declared first. This is the default.
+	LongestMatch Policy = iota
+	// FirstDeclared chooses the rule that was declared first, ignoring
+	// priority numbers. The length decides between matches of the same rule.
+	FirstDeclared
+	// PriorityNumbers chooses the rule with the highest priority number, then
+	// the one declared first, then the longest match.
+	PriorityNumbers
+)
+
+var policyNames = []string{"longest", "first", "priority"}
+
+// String returns the name of the policy as it is written in a definition.
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return "Policy(" + strconv.Itoa(int(p)) + ")"
+	}
+	return policyNames[p]
+}
+
+// ParsePolicy returns the policy for a name: longest, first or priority.
+func ParsePolicy(name string) (Policy, error) {
+	for i, n := range policyNames {
+		if n == name {
+			return Policy(i), nil
+		}
+	}
+	return LongestMatch, fmt.Errorf("Unknown Policy: %s", name)
+}
+
+// SetPolicy sets how the lexer chooses between rules that match at the same
+// position.
+func (l *Lexer) SetPolicy(p Policy) {
+	l.policy = p
+	if p == LongestMatch {
+		l.compare = lengthThenPriority
+	} else {
+		l.compare = priorityThenLength
+	}
+	l.rank()
+}
+
+// SetPriority sets the priority number of the rule for a kind. Rules have a
+// priority of 0 unless it is set, higher numbers win. In a definition, a rule
+// can end with "priority(N)".
+func (l *Lexer) SetPriority(kind parlex.Symbol, priority int) error {
+	idx := l.set.Symbol(kind).Idx()
+	if idx >= len(l.rules) || l.rules[idx] == nil {
+		return fmt.Errorf("Unknown Kind: %s", kind.String())
+	}
+	l.rules[idx].number = priority
+	l.rank()
+	return nil
+}
+
+// rank sets the priority of each rule used to compare matches from the
+// declaration order and, unless the policy is FirstDeclared, the priority
+// numbers. The lowest value wins.
+func (l *Lexer) rank() {
+	kinds := append([]int(nil), l.order...)
+	if l.policy != FirstDeclared {
+		sort.SliceStable(kinds, func(i, j int) bool {
+			return l.rules[kinds[i]].number > l.rules[kinds[j]].number
+		})
+	}
+	for i, kind := range kinds {
+		l.rules[kind].priority = i
+	}
+}
+
+// Overlap is a pair of rules that can both match the same text, so which one
+// is used depends on the Policy. Example is the shortest text they both match
+// and Winner is the rule that is used for it.
+type Overlap struct {
+	A, B    parlex.Symbol
+	Example string
+	Winner  parlex.Symbol
+}
+
+func (o Overlap) String() string {
+	return fmt.Sprintf("%s and %s both match %q, %s wins", o.A, o.B, o.Example, o.Winner)
+}
+
+// maxOverlapStates limits the search for text that a pair of rules both match.
+const maxOverlapStates = 1000
+
+// Overlaps reports the pairs of rules in the same mode that can match the same
+// text, in the order the rules were declared. Rules that discard what they
+// match are included. Rules that use empty width assertions the DFA does not
+// support, like \b, are skipped.
+func (l *Lexer) Overlaps() []Overlap {
+	progs := make(map[int]*syntax.Prog, len(l.order))
+	for _, kind := range l.order {
+		d, err := newDFA([]*rule{l.rules[kind]})
+		if err == nil {
+			progs[kind] = d.progs[0]
+		}
+	}
+
+	var overlaps []Overlap
+	for i, a := range l.order {
+		for _, b := range l.order[i+1:] {
+			ra, rb := l.rules[a], l.rules[b]
+			if progs[a] == nil || progs[b] == nil || !shareMode(ra, rb) {
+				continue
+			}
+			example, ok := overlap(progs[a], progs[b])
+			if !ok {
+				continue
+			}
+			winner := a
+			if rb.priority < ra.priority {
+				winner = b
+			}
+			overlaps = append(overlaps, Overlap{
+				A:       l.set.ByIdx(a),
+				B:       l.set.ByIdx(b),
+				Example: example,
+				Winner:  l.set.ByIdx(winner),
+			})
+		}
+	}
+	return overlaps
+}
+
+func shareMode(a, b *rule) bool {
+	if a.modes == nil {
+		return b.in(InitialMode)
+	}
+	for _, m := range a.modes {
+		if b.in(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlap does a breadth first search of the DFA for a pair of programs for
+// the shortest text that both of them match.
+func overlap(a, b *syntax.Prog) (string, bool) {
+	d := &dfa{
+		progs:  []*syntax.Prog{a, b},
+		states: make(map[string]*dfaState),
+		starts: make(map[string]*dfaState),
+	}
+	start := d.start([]*rule{{}, {}}, InitialMode, emptyOK)
+	type step struct {
+		s    *dfaState
+		text string
+	}
+	queue := []step{{start, ""}}
+	seen := map[*dfaState]bool{start: true}
+	for len(queue) > 0 && len(seen) < maxOverlapStates {
+		cur := queue[0]
+		queue = queue[1:]
+		if len(cur.s.matched) == 2 && cur.text != "" {
+			return cur.text, true
+		}
+		if len(cur.s.threads) < 2 {
+			continue
+		}
+		for _, c := range candidates(d, cur.s) {
+			n := d.next(cur.s, c)
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, step{n, cur.text + string(c)})
+			}
+		}
+	}
+	return "", false
+}
+
+// candidates returns runes that can move the threads of both programs. For
+// each pair of rune ranges, the larger start is in both if they intersect.
+func candidates(d *dfa, s *dfaState) []rune {
+	ranges := make([][][2]rune, 2)
+	for _, t := range s.threads {
+		prog := d.progs[t.kind]
+		for _, pc := range t.pcs {
+			inst := &prog.Inst[pc]
+			switch inst.Op {
+			case syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+				ranges[t.kind] = append(ranges[t.kind], [2]rune{0, utf8.MaxRune})
+			case syntax.InstRune1, syntax.InstRune:
+				if len(inst.Rune) == 1 {
+					r := inst.Rune[0]
+					ranges[t.kind] = append(ranges[t.kind], [2]rune{r, r})
+					if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+						for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+							ranges[t.kind] = append(ranges[t.kind], [2]rune{f, f})
+						}
+					}
+				}
+				for i := 0; i+1 < len(inst.Rune); i += 2 {
+					ranges[t.kind] = append(ranges[t.kind], [2]rune{inst.Rune[i], inst.Rune[i+1]})
+				}
+			}
+		}
+	}
+	var cs []rune
+	found := make(map[rune]bool)
+	for _, ra := range ranges[0] {
+		for _, rb := range ranges[1] {
+			c := ra[0]
+			if rb[0] > c {
+				c = rb[0]
+			}
+			if c <= ra[1] && c <= rb[1] && !found[c] {
+				found[c] = true
+				cs = append(cs, c)
+			}
+		}
+	}
+	return cs
+}
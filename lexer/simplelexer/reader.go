@@ -0,0 +1,292 @@
+package simplelexer
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"io"
+	"regexp"
+	"unicode/utf8"
+)
+
+// DefaultBufferSize is the size of the buffer used by LexReader. The buffer
+// only grows if a single lexeme is longer than half the buffer.
+var DefaultBufferSize = 1 << 16
+
+// Stream lexes the input from an io.Reader incrementally, so the whole input
+// never needs to be held in memory. Each call to Next returns the next lexeme.
+type Stream struct {
+	*Lexer
+	r        io.Reader
+	re       []*regexp.Regexp
+	size     int
+	buf      []byte
+	off      int
+	cur      int
+	eof      bool
+	err      error
+	line     int
+	column   int
+	errFlag  bool
+	errStart int
+	errLine  int
+	errCol   int
+	started  bool
+	done     bool
+	modes    modeStack
+	indent   *indenter
+	hide     *hider
+	pending  []parlex.Lexeme
+}
+
+// LexReader returns a Stream that will lex the input from the reader.
+func (l *Lexer) LexReader(r io.Reader) *Stream {
+	return l.LexReaderSize(r, DefaultBufferSize)
+}
+
+// LexReaderSize returns a Stream that will lex the input from the reader using
+// a buffer of the given size.
+func (l *Lexer) LexReaderSize(r io.Reader, size int) *Stream {
+	if size < 2 {
+		size = 2
+	}
+	s := &Stream{
+		Lexer:  l,
+		r:      r,
+		re:     make([]*regexp.Regexp, len(l.rules)),
+		size:   size,
+		line:   1,
+		column: 1,
+		modes:  modeStack{InitialMode},
+		indent: l.newIndenter(),
+		hide:   l.newHider(),
+	}
+	for kind, rule := range l.rules {
+		if rule != nil {
+			// anchoring the regexp finds the same match that Lex would find
+			// starting at the current position
+			s.re[kind] = regexp.MustCompile(`^(?:` + rule.pattern() + `)`)
+		}
+	}
+	return s
+}
+
+// Next returns the next lexeme. When the input is exhausted it returns nil. If
+// reading from the io.Reader fails, Next returns nil and Err will return the
+// error.
+func (s *Stream) Next() parlex.Lexeme {
+	if len(s.pending) > 0 {
+		lx := s.pending[0]
+		s.pending = s.pending[1:]
+		return lx
+	}
+	if !s.started {
+		s.started = true
+		if s.insert.startKind != "" {
+			return lexeme.String(s.insert.startKind).Set(s.insert.startVal)
+		}
+	}
+	for !s.done {
+		s.fill(false)
+		if s.cur == len(s.buf) {
+			if lx := s.checkError(); lx != nil {
+				return lx
+			}
+			s.done = true
+			if s.hide != nil {
+				s.hide.end()
+			}
+			if s.err != nil {
+				break
+			}
+			if s.indent != nil {
+				s.pending = s.indent.end(s.line, s.col(), s.off+s.cur)
+			}
+			if s.insert.endKind != "" {
+				s.pending = append(s.pending, lexeme.String(s.insert.endKind).Set(s.insert.endVal))
+			}
+			return s.Next()
+		}
+
+		kind, end := s.match()
+		if end == s.cur {
+			if !s.errFlag {
+				s.errFlag = true
+				s.errStart = s.off + s.cur
+				s.errLine, s.errCol = s.line, s.col()
+			}
+			s.advance(runeEnd(s.buf, s.cur))
+			continue
+		}
+		if lx := s.checkError(); lx != nil {
+			return lx
+		}
+		lx := &lexeme.Lexeme{
+			K: s.set.ByIdx(kind),
+			V: string(s.buf[s.cur:end]),
+			L: s.line,
+			C: s.col(),
+			O: s.off + s.cur,
+		}
+		r := s.rules[kind]
+		if !r.discard {
+			mode := s.modes.current()
+			if kw := s.keyword(kind, lx.V, mode); kw != kind {
+				lx.K = s.set.ByIdx(kw)
+			}
+			if s.indent != nil {
+				s.pending = append(s.indent.before(lx, mode), lx)
+			}
+			if s.hide != nil {
+				s.hide.attach(lx)
+			}
+		} else if s.hide != nil {
+			s.hide.hide(lx)
+		}
+		s.advance(end)
+		s.modes.transition(r)
+		if r.discard {
+			continue
+		}
+		if s.indent == nil {
+			return lx
+		}
+		return s.Next()
+	}
+	return nil
+}
+
+// Modes returns a copy of the mode stack. The last mode is the current mode.
+func (s *Stream) Modes() []string {
+	return append([]string(nil), s.modes...)
+}
+
+// Err returns the first error returned by the io.Reader other than io.EOF.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// match finds the best rule in the current mode that matches at cur. Each regexp reads from the
+// stream, so a match can be longer than the buffer.
+func (s *Stream) match() (int, int) {
+	// reading may move the buffer, so end is tracked as an offset in the input
+	start := s.off + s.cur
+	kind, end, p := -1, start, -1
+	mode := s.modes.current()
+	for k, re := range s.re {
+		if re == nil || !s.rules[k].in(mode) {
+			continue
+		}
+		loc := re.FindReaderIndex(&runeReader{Stream: s, pos: start})
+		if loc == nil {
+			continue
+		}
+		e, rp := start+loc[1], s.rules[k].priority
+		if s.compare(e, rp, end, p) {
+			kind, end, p = k, e, rp
+		}
+	}
+	return kind, end - s.off
+}
+
+// runeReader reads runes from the stream starting at an offset in the input
+// without consuming them.
+type runeReader struct {
+	*Stream
+	pos int
+}
+
+func (r *runeReader) ReadRune() (rune, int, error) {
+	// a short read can end in the middle of a rune, which would not decode
+	i := r.pos - r.off
+	for !r.eof && !utf8.FullRune(r.buf[i:]) {
+		r.fill(true)
+		i = r.pos - r.off
+	}
+	if i >= len(r.buf) {
+		return 0, 0, io.EOF
+	}
+	c, size := utf8.DecodeRune(r.buf[i:])
+	r.pos += size
+	return c, size, nil
+}
+
+// fill reads more input. Unless force is true, it only reads if less than half
+// the buffer is left to lex; if force is true, it stops after the first read
+// that returns anything. The consumed part of the buffer is dropped, except
+// for an error in progress.
+func (s *Stream) fill(force bool) {
+	if s.eof || (!force && len(s.buf)-s.cur >= s.size/2) {
+		return
+	}
+	keep := s.cur
+	if s.errFlag {
+		keep = s.errStart - s.off
+	}
+	ln := len(s.buf) - keep
+	want := s.size
+	if ln+s.size/2 > want {
+		want = ln + s.size/2
+	}
+	buf := s.buf
+	if cap(buf) < want {
+		// a long lexeme read in small pieces would otherwise be copied each
+		// time the buffer grows
+		c := want
+		if c < 2*cap(buf) {
+			c = 2 * cap(buf)
+		}
+		buf = make([]byte, ln, c)
+	}
+	if keep > 0 || cap(buf) != cap(s.buf) {
+		copy(buf[:ln], s.buf[keep:])
+	}
+	s.buf = buf[:ln]
+	s.off += keep
+	s.cur -= keep
+
+	for len(s.buf) < want && !s.eof {
+		n, err := s.r.Read(s.buf[len(s.buf):want])
+		s.buf = s.buf[:len(s.buf)+n]
+		if err != nil {
+			s.eof = true
+			if err != io.EOF {
+				s.err = err
+			}
+		}
+		if n > 0 && force {
+			break
+		}
+	}
+}
+
+func (s *Stream) checkError() parlex.Lexeme {
+	if !s.errFlag {
+		return nil
+	}
+	s.errFlag = false
+	val := string(s.buf[s.errStart-s.off : s.cur])
+	errKind := s.set.Str(s.Error)
+	lxm := lexeme.New(errKind).Set(val).At(s.errLine, s.errCol).AtOffset(s.errStart)
+	return &errLexeme{lxm}
+}
+
+// advance moves cur forward to the given index in the buffer, keeping track of
+// the current line and column.
+func (s *Stream) advance(to int) {
+	for ; s.cur < to; s.cur++ {
+		if s.buf[s.cur] == '\n' {
+			s.line++
+			s.column = 1
+		} else if utf8.RuneStart(s.buf[s.cur]) {
+			s.column++
+		}
+		if s.indent != nil {
+			s.indent.advance(s.buf[s.cur])
+		}
+	}
+}
+
+// col returns the column of cur. Columns start at 1 and count runes.
+func (s *Stream) col() int {
+	return s.column
+}
@@ -0,0 +1,404 @@
+package simplelexer
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTableStates limits the number of DFA states Tables will build.
+var MaxTableStates = 1 << 16
+
+// Tables are a Lexer with its rules compiled into a complete DFA. A Lexer made
+// by FromTables lexes the same as the Lexer the tables came from without
+// compiling any regexps, so a program does not need to ship the definition of
+// its lexer or pay to build it when it starts. cmd/parlexc writes Tables as Go
+// code.
+//
+// Kinds are referred to by their index in Symbols.
+type Tables struct {
+	Symbols []string
+	// Rules are in the order they were declared
+	Rules []RuleTable
+	// States of the DFA. Starts holds the first state for a mode and the empty
+	// width assertions that hold where a lexeme starts.
+	States   []StateTable
+	Starts   map[string]int
+	Keywords []KeywordTable
+	Policy   Policy
+	Error    string
+	// IgnoreCase, TabWidth and Trivia are set by IgnoreCase, OffSide and
+	// KeepTrivia
+	IgnoreCase bool
+	TabWidth   int
+	Trivia     bool
+	// InsertStart and InsertEnd are the kind and value of the lexemes set by
+	// InsertStart and InsertEnd
+	InsertStart, InsertEnd [2]string
+}
+
+// RuleTable is a rule of a Lexer. Pattern is the regexp of the rule, it is only
+// compiled if it is needed, for instance after UseRegexp.
+type RuleTable struct {
+	Kind     int
+	Pattern  string
+	Discard  bool
+	Priority int
+	Modes    []string
+	Push     string
+	Pop      bool
+	Fold     bool
+}
+
+// StateTable is a state of the DFA. Matched holds the kinds of the rules that
+// match when the state is reached. Live is true if more input can lead to
+// another match. Next holds the transitions sorted by rune, a rune without one
+// ends the lexeme.
+type StateTable struct {
+	Matched []int
+	Live    bool
+	Next    []Transition
+}
+
+// Transition moves to the state To on a rune from Lo to Hi.
+type Transition struct {
+	Lo, Hi rune
+	To     int
+}
+
+// KeywordTable is a table of reserved words added by AddKeywords.
+type KeywordTable struct {
+	Words []string
+	Kinds []int
+	Modes []string
+}
+
+// Tables compiles the lexer into a complete DFA. It returns an error if a rule
+// uses something the DFA does not support, see UseDFA, or the DFA would have
+// more than MaxTableStates states.
+func (l *Lexer) Tables() (*Tables, error) {
+	d, err := newDFA(l.rules)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tables{
+		Policy:      l.policy,
+		Error:       l.Error,
+		IgnoreCase:  l.ignoreCase,
+		TabWidth:    l.tabWidth,
+		Trivia:      l.trivia,
+		InsertStart: [2]string{l.insert.startKind, l.insert.startVal},
+		InsertEnd:   [2]string{l.insert.endKind, l.insert.endVal},
+		Starts:      make(map[string]int),
+	}
+	for i := 0; i < l.set.Size(); i++ {
+		t.Symbols = append(t.Symbols, l.set.ByIdx(i).String())
+	}
+	modes := map[string]bool{InitialMode: true}
+	for _, kind := range l.order {
+		r := l.rules[kind]
+		t.Rules = append(t.Rules, RuleTable{
+			Kind:     kind,
+			Pattern:  r.pattern(),
+			Discard:  r.discard,
+			Priority: r.number,
+			Modes:    r.modes,
+			Push:     r.push,
+			Pop:      r.pop,
+			Fold:     r.fold,
+		})
+		for _, m := range r.modes {
+			modes[m] = true
+		}
+	}
+	for _, k := range l.keywords {
+		t.Keywords = append(t.Keywords, KeywordTable{
+			Words: k.words,
+			Kinds: k.kinds,
+			Modes: k.modes,
+		})
+	}
+
+	idx := make(map[*dfaState]int)
+	var queue []*dfaState
+	add := func(s *dfaState) int {
+		i, ok := idx[s]
+		if !ok {
+			i = len(queue)
+			idx[s] = i
+			queue = append(queue, s)
+		}
+		return i
+	}
+	var names []string
+	for m := range modes {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	for _, m := range names {
+		for _, empty := range []syntax.EmptyOp{0, syntax.EmptyBeginLine, emptyOK} {
+			t.Starts[startKey(m, empty)] = add(d.start(l.rules, m, empty))
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		if len(queue) > MaxTableStates {
+			return nil, fmt.Errorf("Too Many States: more than %d", MaxTableStates)
+		}
+		s := queue[i]
+		st := StateTable{
+			Matched: s.matched,
+			Live:    len(s.threads) > 0,
+		}
+		if st.Live {
+			for _, rng := range runeRanges(d, s) {
+				n := d.next(s, rng[0])
+				if len(n.threads) == 0 && len(n.matched) == 0 {
+					continue
+				}
+				to := add(n)
+				if ln := len(st.Next); ln > 0 && st.Next[ln-1].To == to && st.Next[ln-1].Hi+1 == rng[0] {
+					st.Next[ln-1].Hi = rng[1]
+					continue
+				}
+				st.Next = append(st.Next, Transition{Lo: rng[0], Hi: rng[1], To: to})
+			}
+		}
+		t.States = append(t.States, st)
+	}
+	return t, nil
+}
+
+// runeRanges splits every rune into ranges that move the threads of the state
+// the same way. '\n' is always a range of its own because it changes the empty
+// width assertions.
+func runeRanges(d *dfa, s *dfaState) [][2]rune {
+	bounds := map[rune]bool{0: true, '\n': true, '\n' + 1: true}
+	addRange := func(lo, hi rune) {
+		bounds[lo] = true
+		bounds[hi+1] = true
+	}
+	for _, t := range s.threads {
+		prog := d.progs[t.kind]
+		for _, pc := range t.pcs {
+			inst := &prog.Inst[pc]
+			if inst.Op != syntax.InstRune1 && inst.Op != syntax.InstRune {
+				continue
+			}
+			if len(inst.Rune) == 1 {
+				r := inst.Rune[0]
+				addRange(r, r)
+				if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+					for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+						addRange(f, f)
+					}
+				}
+			}
+			for i := 0; i+1 < len(inst.Rune); i += 2 {
+				addRange(inst.Rune[i], inst.Rune[i+1])
+			}
+		}
+	}
+	starts := make([]rune, 0, len(bounds))
+	for b := range bounds {
+		if b <= unicode.MaxRune {
+			starts = append(starts, b)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	ranges := make([][2]rune, len(starts))
+	for i, lo := range starts {
+		hi := rune(unicode.MaxRune)
+		if i+1 < len(starts) {
+			hi = starts[i+1] - 1
+		}
+		ranges[i] = [2]rune{lo, hi}
+	}
+	return ranges
+}
+
+// FromTables returns a Lexer that uses the compiled DFA in the tables. It can
+// be configured further, but changing the rules compiles their regexps.
+func FromTables(t *Tables) *Lexer {
+	l := &Lexer{
+		Error:      t.Error,
+		set:        setsymbol.New(),
+		ignoreCase: t.IgnoreCase,
+		tabWidth:   t.TabWidth,
+		trivia:     t.Trivia,
+	}
+	l.insert.startKind, l.insert.startVal = t.InsertStart[0], t.InsertStart[1]
+	l.insert.endKind, l.insert.endVal = t.InsertEnd[0], t.InsertEnd[1]
+	for _, s := range t.Symbols {
+		l.set.Str(s)
+	}
+	// the kinds the lexer can add while lexing are added now, so lexing never
+	// changes the set
+	l.set.Str(l.Error)
+	if l.tabWidth > 0 {
+		for _, kind := range []string{Newline, Indent, Dedent} {
+			l.set.Str(kind)
+		}
+	}
+
+	l.rules = make([]*rule, len(t.Symbols))
+	for _, rt := range t.Rules {
+		l.rules[rt.Kind] = &rule{
+			kind:    rt.Kind,
+			expr:    rt.Pattern,
+			discard: rt.Discard,
+			number:  rt.Priority,
+			modes:   rt.Modes,
+			push:    rt.Push,
+			pop:     rt.Pop,
+			fold:    rt.Fold,
+		}
+		l.order = append(l.order, rt.Kind)
+	}
+	for _, kt := range t.Keywords {
+		k := &keywords{
+			words: kt.Words,
+			kinds: kt.Kinds,
+			idx:   make(map[string]int, len(kt.Words)),
+			modes: kt.Modes,
+		}
+		for i, word := range kt.Words {
+			k.idx[strings.ToLower(word)] = i
+		}
+		l.keywords = append(l.keywords, k)
+	}
+	l.SetPolicy(t.Policy)
+
+	d := &dfa{
+		starts:   make(map[string]*dfaState, len(t.Starts)),
+		compiled: true,
+		none:     &dfaState{},
+	}
+	states := make([]*dfaState, len(t.States))
+	for i := range states {
+		states[i] = &dfaState{}
+	}
+	for i, st := range t.States {
+		s := states[i]
+		s.matched, s.live = st.Matched, st.Live
+		for _, tr := range st.Next {
+			for c := tr.Lo; c <= tr.Hi && c < utf8.RuneSelf; c++ {
+				s.ascii[c] = states[tr.To]
+			}
+			if tr.Hi >= utf8.RuneSelf {
+				lo := tr.Lo
+				if lo < utf8.RuneSelf {
+					lo = utf8.RuneSelf
+				}
+				s.ranges = append(s.ranges, dfaRange{lo: lo, hi: tr.Hi, to: states[tr.To]})
+			}
+		}
+	}
+	for key, i := range t.Starts {
+		d.starts[key] = states[i]
+	}
+	l.dfa = d
+	return l
+}
+
+// GoString returns the tables as a Go expression so they can be written into a
+// program. It fulfills fmt.GoStringer.
+func (t *Tables) GoString() string {
+	var buf strings.Builder
+	buf.WriteString("&simplelexer.Tables{\n")
+	fmt.Fprintf(&buf, "Symbols: %#v,\n", t.Symbols)
+	buf.WriteString("Rules: []simplelexer.RuleTable{\n")
+	for _, r := range t.Rules {
+		fmt.Fprintf(&buf, "{Kind: %d, Pattern: %q", r.Kind, r.Pattern)
+		if r.Discard {
+			buf.WriteString(", Discard: true")
+		}
+		if r.Priority != 0 {
+			fmt.Fprintf(&buf, ", Priority: %d", r.Priority)
+		}
+		if r.Modes != nil {
+			fmt.Fprintf(&buf, ", Modes: %#v", r.Modes)
+		}
+		if r.Push != "" {
+			fmt.Fprintf(&buf, ", Push: %q", r.Push)
+		}
+		if r.Pop {
+			buf.WriteString(", Pop: true")
+		}
+		if r.Fold {
+			buf.WriteString(", Fold: true")
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("},\nStates: []simplelexer.StateTable{\n")
+	for i, s := range t.States {
+		fmt.Fprintf(&buf, "/* %d */ {Matched: %#v, Live: %t", i, s.Matched, s.Live)
+		if len(s.Next) > 0 {
+			buf.WriteString(", Next: []simplelexer.Transition{")
+			for j, tr := range s.Next {
+				if j > 0 {
+					buf.WriteString(", ")
+				}
+				fmt.Fprintf(&buf, "{Lo: %s, Hi: %s, To: %d}", runeLiteral(tr.Lo), runeLiteral(tr.Hi), tr.To)
+			}
+			buf.WriteString("}")
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("},\n")
+	fmt.Fprintf(&buf, "Starts: %#v,\n", t.Starts)
+	if len(t.Keywords) > 0 {
+		buf.WriteString("Keywords: []simplelexer.KeywordTable{\n")
+		for _, k := range t.Keywords {
+			fmt.Fprintf(&buf, "{Words: %#v, Kinds: %#v", k.Words, k.Kinds)
+			if k.Modes != nil {
+				fmt.Fprintf(&buf, ", Modes: %#v", k.Modes)
+			}
+			buf.WriteString("},\n")
+		}
+		buf.WriteString("},\n")
+	}
+	fmt.Fprintf(&buf, "Policy: simplelexer.%s,\n", policyConsts[t.Policy])
+	fmt.Fprintf(&buf, "Error: %q,\n", t.Error)
+	if t.IgnoreCase {
+		buf.WriteString("IgnoreCase: true,\n")
+	}
+	if t.TabWidth > 0 {
+		fmt.Fprintf(&buf, "TabWidth: %d,\n", t.TabWidth)
+	}
+	if t.Trivia {
+		buf.WriteString("Trivia: true,\n")
+	}
+	if t.InsertStart[0] != "" {
+		fmt.Fprintf(&buf, "InsertStart: %#v,\n", t.InsertStart)
+	}
+	if t.InsertEnd[0] != "" {
+		fmt.Fprintf(&buf, "InsertEnd: %#v,\n", t.InsertEnd)
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+var policyConsts = map[Policy]string{
+	LongestMatch:    "LongestMatch",
+	FirstDeclared:   "FirstDeclared",
+	PriorityNumbers: "PriorityNumbers",
+}
+
+// runeLiteral writes printable runes as character literals so the transitions
+// are easy to read.
+func runeLiteral(r rune) string {
+	if r < utf8.RuneSelf && unicode.IsPrint(r) {
+		return strconv.QuoteRune(r)
+	}
+	return strconv.Itoa(int(r))
+}
+
+// startKey is the key of a start state in dfa.starts.
+func startKey(mode string, empty syntax.EmptyOp) string {
+	return mode + ":" + strconv.Itoa(int(empty))
+}
@@ -0,0 +1,144 @@
+package simplelexer
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/stretchr/testify/assert"
+	"go/parser"
+	"strings"
+	"testing"
+)
+
+// describe writes each lexeme with its position and trivia, so lexemes from
+// lexers with different symbol sets can be compared.
+func describe(lxs []parlex.Lexeme) []string {
+	strs := make([]string, len(lxs))
+	for i, lx := range lxs {
+		l, c := lx.Pos()
+		strs[i] = fmt.Sprintf("%s %q %d:%d@%d", lx.Kind(), lx.Value(), l, c, parlex.Offset(lx))
+		if _, ok := lx.(parlex.LexError); ok {
+			strs[i] += " err"
+		}
+		for _, t := range parlex.Leading(lx) {
+			strs[i] += fmt.Sprintf(" <%q", t.Value())
+		}
+		for _, t := range parlex.Trailing(lx) {
+			strs[i] += fmt.Sprintf(" >%q", t.Value())
+		}
+	}
+	return strs
+}
+
+func TestTables(t *testing.T) {
+	tests := []struct {
+		rules  string
+		setup  func(*Lexer)
+		inputs []string
+	}{
+		{
+			rules: `
+        kw    /(?i)select|from/
+        word  /[\p{L}_]+/
+        num   /\d+(\.\d+)?/
+        str   /"([^"\\]|\\.)*"/
+        line  /(?m)^#.*/
+        nl    /\n/
+        space /[ \t]+/ -
+        any   /./
+      `,
+			inputs: []string{
+				"SELECT naïve, \"a\\\"b\" FROM t 3.14\n# comment\nx#y é",
+				"日本語 \xff 12",
+			},
+		},
+		{
+			rules: `
+              strStart /"/ push(STR)
+              word     /\w+/
+              space    /\s+/ -
+        <STR> strEnd   /"/ pop
+        <STR> text     /[^"]+/
+        keywords if else -> kw_if kw_else
+      `,
+			inputs: []string{`if "hi there" else "again`, "x ! y"},
+		},
+		{
+			rules: `
+        policy priority
+        ident /[a-z]+/
+        sel   /select/i priority(1)
+        op    /[+\-]/
+        space /\s+/ -
+      `,
+			setup: func(l *Lexer) {
+				l.KeepTrivia()
+				l.InsertStart("start", "").InsertEnd("end", "")
+			},
+			inputs: []string{"select a + SeLeCt -b   \n c"},
+		},
+		{
+			rules: `
+        offside 4
+        word  /\w+/
+        colon /:/
+        nl    /\n/ -
+        space /[ \t]+/ -
+      `,
+			setup: func(l *Lexer) {
+				assert.NoError(t, l.IgnoreCase())
+			},
+			inputs: []string{"if x:\n    y\n\tz\nw"},
+		},
+	}
+
+	for _, tc := range tests {
+		lxr, err := New(tc.rules)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		if tc.setup != nil {
+			tc.setup(lxr)
+		}
+		tbls, err := lxr.Tables()
+		if !assert.NoError(t, err) {
+			continue
+		}
+		compiled := FromTables(tbls)
+		assert.Equal(t, lxr.String(), compiled.String())
+		for _, in := range tc.inputs {
+			expected, expectedModes := lxr.LexModes(in)
+			lxs, modes := compiled.LexModes(in)
+			assert.Equal(t, describe(expected), describe(lxs), in)
+			assert.Equal(t, expectedModes, modes, in)
+		}
+
+		_, err = parser.ParseExpr(tbls.GoString())
+		assert.NoError(t, err)
+	}
+}
+
+func TestTablesRegexp(t *testing.T) {
+	lxr, err := New(`
+    word  /\w+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	tbls, err := lxr.Tables()
+	assert.NoError(t, err)
+	compiled := FromTables(tbls)
+	assert.Equal(t, `\w+`, compiled.Pattern(compiled.Symbols()[0]).String())
+
+	// the regexps are compiled when they are needed
+	compiled.UseRegexp()
+	assert.Equal(t, describe(lxr.Lex("a b")), describe(compiled.Lex("a b")))
+
+	bad, err := New(`word /\w+\b/`)
+	assert.NoError(t, err)
+	_, err = bad.Tables()
+	assert.Equal(t, `Unsupported by DFA: /\w+\b/`, err.Error())
+
+	str := tbls.GoString()
+	assert.True(t, strings.HasPrefix(str, "&simplelexer.Tables{\n"))
+	assert.True(t, strings.Contains(str, "Policy: simplelexer.LongestMatch,"))
+	assert.True(t, strings.Contains(str, "Next: []simplelexer.Transition{{Lo: "))
+}
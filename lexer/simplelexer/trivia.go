@@ -0,0 +1,68 @@
+package simplelexer
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"strings"
+)
+
+// KeepTrivia keeps the lexemes of rules that discard their value on a hidden
+// channel instead of dropping them. They are still not passed to the parser,
+// but are attached to the lexemes around them as trivia. A hidden lexeme is
+// trailing trivia of the lexeme before it up to and including the first one
+// with a newline, the rest are leading trivia of the lexeme after them. Hidden
+// lexemes after the last lexeme are all trailing trivia.
+//
+// A Stream fills in the trailing trivia of a lexeme when it reads the next
+// lexeme.
+func (l *Lexer) KeepTrivia() *Lexer {
+	l.trivia = true
+	return l
+}
+
+// hider attaches hidden lexemes to the lexemes around them for one pass of the
+// lexer.
+type hider struct {
+	last   *lexeme.Lexeme
+	hidden []parlex.Lexeme
+}
+
+func (l *Lexer) newHider() *hider {
+	if !l.trivia {
+		return nil
+	}
+	return &hider{}
+}
+
+func (h *hider) hide(lx *lexeme.Lexeme) {
+	h.hidden = append(h.hidden, lx)
+}
+
+// attach the hidden lexemes to the last lexeme and lx.
+func (h *hider) attach(lx *lexeme.Lexeme) {
+	i := 0
+	if h.last != nil {
+		for i < len(h.hidden) {
+			i++
+			if strings.Contains(h.hidden[i-1].Value(), "\n") {
+				break
+			}
+		}
+		if i > 0 {
+			h.last.Trail = append(h.last.Trail, h.hidden[:i]...)
+		}
+	}
+	if i < len(h.hidden) {
+		lx.Lead = h.hidden[i:]
+	}
+	h.hidden = nil
+	h.last = lx
+}
+
+// end attaches the hidden lexemes at the end of the input.
+func (h *hider) end() {
+	if h.last != nil && len(h.hidden) > 0 {
+		h.last.Trail = append(h.last.Trail, h.hidden...)
+	}
+	h.hidden = nil
+}
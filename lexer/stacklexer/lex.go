@@ -144,7 +144,7 @@ func (op *lexOp) findNextMatch() (*lexeme.Lexeme, int, *rule) {
 }
 
 func (op *lexOp) handleLineCol(lx *lexeme.Lexeme, str string) {
-	lx.L = op.lines
+	lx.L, lx.O = op.lines, op.cur
 	lx.C = strings.LastIndex(string(op.b[:op.cur]), "\n")
 	lx.C = op.cur - lx.C
 	op.lines += strings.Count(str, "\n")
@@ -159,6 +159,7 @@ func (op *lexOp) checkError() {
 	lx := lexeme.New(op.err.kind).Set(val)
 	op.handleLineCol(lx, lx.V)
 	lx.C -= len(val)
+	lx.O = op.err.start
 	op.lxs = append(op.lxs, &errLexeme{lx})
 }
 
@@ -180,12 +180,14 @@ func TestLexerError(t *testing.T) {
 			V: "error1",
 			L: 1,
 			C: 9,
+			O: 8,
 		}},
 		&errLexeme{&lexeme.Lexeme{
 			K: lxr.set.Str(lxr.Error),
 			V: "error2",
 			L: 1,
 			C: 20,
+			O: 19,
 		}},
 	}
 	assert.Equal(t, expected, errs)
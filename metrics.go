@@ -0,0 +1,131 @@
+package parlex
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Instrument receives measurements of runs. It is a Tracer so it can also be
+// set as the Tracer of a parser to receive the steps of each parse.
+type Instrument interface {
+	Tracer
+	// Lexed is called with the number of lexemes from the lex stage
+	Lexed(n int)
+	// Stage is called with the wall time of each stage
+	Stage(stage Stage, elapsed time.Duration)
+}
+
+// Instrumented returns Middleware that reports the number of lexemes and the
+// time of each stage of a run to the Instrument.
+func Instrumented(inst Instrument) Middleware {
+	return Middleware{
+		Lex: func(next LexFunc) LexFunc {
+			return func(input string) []Lexeme {
+				start := time.Now()
+				lxs := next(input)
+				inst.Stage(StageLex, time.Since(start))
+				inst.Lexed(len(lxs))
+				return lxs
+			}
+		},
+		Parse: func(next ParseFunc) ParseFunc {
+			return func(lexemes []Lexeme) (ParseNode, error) {
+				start := time.Now()
+				defer func() { inst.Stage(StageParse, time.Since(start)) }()
+				return next(lexemes)
+			}
+		},
+		Reduce: func(next ReduceFunc) ReduceFunc {
+			return func(node ParseNode, input string) ParseNode {
+				start := time.Now()
+				defer func() { inst.Stage(StageReduce, time.Since(start)) }()
+				return next(node, input)
+			}
+		},
+	}
+}
+
+// Metrics is an Instrument that adds up the measurements of every run. It is
+// not safe to use from more than one run at a time.
+//   m := &parlex.Metrics{}
+//   prsr.Tracer = m
+//   r := parlex.New(lxr, prsr, rdcr, parlex.Instrumented(m))
+type Metrics struct {
+	// Lexemes is the number of lexemes lexed
+	Lexemes int
+	// Attempts is the number of productions attempted
+	Attempts int
+	// Matches, Fails and Backtracks are the number of each TraceEvent
+	Matches, Fails, Backtracks int
+	// MemoHits and MemoMisses are the uses of the memo table
+	MemoHits, MemoMisses int
+	// MaxDepth is the deepest the parser was nested, if it tracks the depth
+	MaxDepth int
+	// Lex, Parse and Reduce are the wall time of each stage
+	Lex, Parse, Reduce time.Duration
+}
+
+// Trace fulfills Tracer by counting the event.
+func (m *Metrics) Trace(e TraceEvent) {
+	switch e.Kind {
+	case TraceAttempt:
+		m.Attempts++
+	case TraceMatch:
+		m.Matches++
+	case TraceFail:
+		m.Fails++
+	case TraceMemoHit:
+		m.MemoHits++
+	case TraceMemoMiss:
+		m.MemoMisses++
+	case TraceBacktrack:
+		m.Backtracks++
+	}
+	if e.Depth > m.MaxDepth {
+		m.MaxDepth = e.Depth
+	}
+}
+
+// Lexed fulfills Instrument.
+func (m *Metrics) Lexed(n int) {
+	m.Lexemes += n
+}
+
+// Stage fulfills Instrument.
+func (m *Metrics) Stage(stage Stage, elapsed time.Duration) {
+	switch stage {
+	case StageLex:
+		m.Lex += elapsed
+	case StageParse:
+		m.Parse += elapsed
+	case StageReduce:
+		m.Reduce += elapsed
+	}
+}
+
+// MemoHitRate is the fraction of memo table lookups that were hits. It is 0
+// if there were none.
+func (m *Metrics) MemoHitRate() float64 {
+	lookups := m.MemoHits + m.MemoMisses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(m.MemoHits) / float64(lookups)
+}
+
+// String reports the metrics, one per line.
+func (m *Metrics) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "lexemes:    %d\n", m.Lexemes)
+	fmt.Fprintf(&buf, "attempts:   %d\n", m.Attempts)
+	fmt.Fprintf(&buf, "matches:    %d\n", m.Matches)
+	fmt.Fprintf(&buf, "fails:      %d\n", m.Fails)
+	fmt.Fprintf(&buf, "backtracks: %d\n", m.Backtracks)
+	fmt.Fprintf(&buf, "memo:       %d hits, %d misses (%.1f%%)\n", m.MemoHits, m.MemoMisses, 100*m.MemoHitRate())
+	fmt.Fprintf(&buf, "max depth:  %d\n", m.MaxDepth)
+	fmt.Fprintf(&buf, "lex:        %s\n", m.Lex)
+	fmt.Fprintf(&buf, "parse:      %s\n", m.Parse)
+	fmt.Fprintf(&buf, "reduce:     %s\n", m.Reduce)
+	return buf.String()
+}
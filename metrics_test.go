@@ -0,0 +1,51 @@
+package parlex
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowLexer takes a millisecond to lex.
+type slowLexer struct{ wordLexer }
+
+func (l slowLexer) Lex(str string) []Lexeme {
+	time.Sleep(time.Millisecond)
+	return l.wordLexer.Lex(str)
+}
+
+func TestMetrics(t *testing.T) {
+	m := &Metrics{}
+	r := New(slowLexer{}, evenParser{}, nil, Instrumented(m))
+	_, err := r.Run("a b c d")
+	assert.NoError(t, err)
+	_, err = r.Run("a b")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, m.Lexemes)
+	assert.True(t, m.Lex >= 2*time.Millisecond)
+
+	events := []TraceEvent{
+		{Kind: TraceMemoMiss, Depth: 1},
+		{Kind: TraceAttempt, Depth: 1},
+		{Kind: TraceMemoMiss, Depth: 2},
+		{Kind: TraceAttempt, Depth: 2},
+		{Kind: TraceMatch, Depth: 2},
+		{Kind: TraceMemoHit, Depth: 1},
+		{Kind: TraceFail, Depth: 1},
+		{Kind: TraceBacktrack, Depth: 1},
+		{Kind: TraceMemoHit, Depth: 1},
+	}
+	for _, e := range events {
+		m.Trace(e)
+	}
+	assert.Equal(t, 2, m.Attempts)
+	assert.Equal(t, 1, m.Matches)
+	assert.Equal(t, 1, m.Fails)
+	assert.Equal(t, 1, m.Backtracks)
+	assert.Equal(t, 2, m.MaxDepth)
+	assert.InDelta(t, 0.5, m.MemoHitRate(), 1e-9)
+	assert.True(t, strings.Contains(m.String(), "memo:       2 hits, 2 misses (50.0%)\n"))
+
+	assert.Equal(t, 0.0, (&Metrics{}).MemoHitRate())
+}
@@ -0,0 +1,70 @@
+package parlex
+
+// LexFunc is the lex stage of a Runner.
+type LexFunc func(input string) []Lexeme
+
+// ParseFunc is the parse stage of a Runner. If it fails, it should return an
+// error, preferably a *ParseError.
+type ParseFunc func(lexemes []Lexeme) (ParseNode, error)
+
+// ReduceFunc is the reduce stage of a Runner. The input is passed along for a
+// SourceReducer. If there is no Reducer, the stage returns the node unchanged.
+type ReduceFunc func(node ParseNode, input string) ParseNode
+
+// Middleware wraps the stages of a Runner, the same way HTTP middleware wraps a
+// handler. Each field takes the next function in the stage and returns a
+// function that usually calls it, so a Middleware can time a stage, filter the
+// lexemes before parsing or change the tree after reducing. Any field can be
+// nil.
+//   timing := parlex.Middleware{
+//     Parse: func(next parlex.ParseFunc) parlex.ParseFunc {
+//       return func(lxs []parlex.Lexeme) (parlex.ParseNode, error) {
+//         start := time.Now()
+//         defer func() { log.Println("parse", time.Since(start)) }()
+//         return next(lxs)
+//       }
+//     },
+//   }
+type Middleware struct {
+	Lex    func(next LexFunc) LexFunc
+	Parse  func(next ParseFunc) ParseFunc
+	Reduce func(next ReduceFunc) ReduceFunc
+}
+
+// stages are the functions a Runner uses, with the middleware applied.
+type stages struct {
+	lex    LexFunc
+	parse  ParseFunc
+	reduce ReduceFunc
+}
+
+func newStages(lexer Lexer, parser Parser, reducer Reducer, middleware []Middleware) stages {
+	s := stages{
+		lex: lexer.Lex,
+		parse: func(lexemes []Lexeme) (ParseNode, error) {
+			return parseErr(parser, lexemes)
+		},
+		reduce: func(node ParseNode, input string) ParseNode {
+			if sr, ok := reducer.(SourceReducer); ok {
+				return sr.ReduceSource(node, input)
+			}
+			if reducer != nil {
+				return reducer.Reduce(node)
+			}
+			return node
+		},
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		m := middleware[i]
+		if m.Lex != nil {
+			s.lex = m.Lex(s.lex)
+		}
+		if m.Parse != nil {
+			s.parse = m.Parse(s.parse)
+		}
+		if m.Reduce != nil {
+			s.reduce = m.Reduce(s.reduce)
+		}
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package parlex
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	logger := func(name string) Middleware {
+		return Middleware{
+			Lex: func(next LexFunc) LexFunc {
+				return func(input string) []Lexeme {
+					calls = append(calls, name+" lex")
+					return next(input)
+				}
+			},
+			Parse: func(next ParseFunc) ParseFunc {
+				return func(lexemes []Lexeme) (ParseNode, error) {
+					calls = append(calls, name+" parse")
+					return next(lexemes)
+				}
+			},
+			Reduce: func(next ReduceFunc) ReduceFunc {
+				return func(node ParseNode, input string) ParseNode {
+					calls = append(calls, name+" reduce")
+					return next(node, input)
+				}
+			},
+		}
+	}
+	// drops every lexeme with the value "x"
+	filter := Middleware{
+		Lex: func(next LexFunc) LexFunc {
+			return func(input string) []Lexeme {
+				var out []Lexeme
+				for _, lx := range next(input) {
+					if lx.Value() != "x" {
+						out = append(out, lx)
+					}
+				}
+				return out
+			}
+		},
+	}
+	// replaces the tree with one holding the input
+	post := Middleware{
+		Reduce: func(next ReduceFunc) ReduceFunc {
+			return func(node ParseNode, input string) ParseNode {
+				if next(node, input) == nil {
+					return nil
+				}
+				return &testNode{lx{k: "input", v: input}}
+			}
+		},
+	}
+
+	r := New(wordLexer{}, evenParser{}, nil, logger("a"), filter, logger("b"), post)
+	node, err := r.Run("p x q")
+	assert.NoError(t, err)
+	assert.Equal(t, "p x q", node.Value())
+	assert.Equal(t, []string{"a lex", "b lex", "a parse", "b parse", "a reduce", "b reduce"}, calls)
+
+	calls = nil
+	_, ds := r.RunDiagnostics("p x")
+	if assert.Len(t, ds, 1) {
+		assert.Equal(t, StageParse, ds[0].Stage)
+	}
+	assert.Equal(t, []string{"a lex", "b lex", "a parse", "b parse"}, calls)
+
+	// without the filter, the x is parsed and there is an odd number
+	_, err = New(wordLexer{}, evenParser{}, nil, post).Run("p x q")
+	assert.Equal(t, ErrCouldNotParse, err)
+}
@@ -1,8 +1,13 @@
 package parlex
 
-// Run performs the lexing, parsing and reducing for an input
+// Run performs the lexing, parsing and reducing for an input. If the reducer
+// is a SourceReducer, it is given the input.
 func Run(input string, lexer Lexer, parser Parser, reducer Reducer) (ParseNode, error) {
-	lexemes := lexer.Lex(input)
+	return newStages(lexer, parser, reducer, nil).run(input)
+}
+
+func (s stages) run(input string) (ParseNode, error) {
+	lexemes := s.lex(input)
 	if lexemes == nil {
 		return nil, ErrCouldNotLex
 	}
@@ -11,16 +16,17 @@ func Run(input string, lexer Lexer, parser Parser, reducer Reducer) (ParseNode,
 		return nil, errs[0]
 	}
 
-	parseTree := parser.Parse(lexemes)
+	parseTree, err := s.parse(lexemes)
+	if err != nil {
+		return nil, err
+	}
 	if parseTree == nil {
 		return nil, ErrCouldNotParse
 	}
 
-	if reducer != nil {
-		parseTree = reducer.Reduce(parseTree)
-		if parseTree == nil {
-			return nil, ErrCouldNotReduce
-		}
+	parseTree = s.reduce(parseTree, input)
+	if parseTree == nil {
+		return nil, ErrCouldNotReduce
 	}
 
 	return parseTree, nil
@@ -29,21 +35,18 @@ func Run(input string, lexer Lexer, parser Parser, reducer Reducer) (ParseNode,
 // Runner holds a Lexer, Parser and Reducer and uses them to operate on an input
 // string
 type Runner struct {
-	lexer   Lexer
-	parser  Parser
-	reducer Reducer
+	stages stages
 }
 
-// New returns a new runner. The reducer can be nil.
-func New(lexer Lexer, parser Parser, reducer Reducer) *Runner {
+// New returns a new runner. The reducer can be nil. Any middleware wraps the
+// stages of every run, the first middleware is the outermost.
+func New(lexer Lexer, parser Parser, reducer Reducer, middleware ...Middleware) *Runner {
 	return &Runner{
-		lexer:   lexer,
-		parser:  parser,
-		reducer: reducer,
+		stages: newStages(lexer, parser, reducer, middleware),
 	}
 }
 
 // Run using the Parser, Lexer and Reducer in the Runner.
 func (r *Runner) Run(input string) (ParseNode, error) {
-	return Run(input, r.lexer, r.parser, r.reducer)
+	return r.stages.run(input)
 }
@@ -0,0 +1,32 @@
+package parlex
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type wordLexer struct{}
+
+func (wordLexer) Lex(str string) []Lexeme {
+	var lxs []Lexeme
+	for _, w := range strings.Fields(str) {
+		lxs = append(lxs, &lx{k: "word", v: w})
+	}
+	return lxs
+}
+
+// srcReducer replaces the tree with a node holding the source.
+type srcReducer struct{}
+
+func (srcReducer) Reduce(node ParseNode) ParseNode { return node }
+func (srcReducer) Can(ParseNode) bool              { return true }
+func (srcReducer) ReduceSource(node ParseNode, src string) ParseNode {
+	return &testNode{lx{k: "src", v: src}}
+}
+
+func TestRunSource(t *testing.T) {
+	node, err := New(wordLexer{}, evenParser{}, srcReducer{}).Run("a b")
+	assert.NoError(t, err)
+	assert.Equal(t, "a b", node.Value())
+}
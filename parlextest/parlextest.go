@@ -0,0 +1,123 @@
+// Package parlextest provides helpers for golden tests of parse trees. Trees
+// are written in the s-expression notation of tree.ToSExpr.
+//   (E (int "1") + (T (int "2") * (int "3")))
+package parlextest
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/tree"
+	"reflect"
+	"strings"
+)
+
+// TestingT is the part of *testing.T used to report a failure.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+type helper interface {
+	Helper()
+}
+
+// AssertTree checks that a tree matches the expected tree in s-expression
+// notation. If it does not, the failure shows a diff of the trees, one node per
+// line. It returns true if the trees match.
+func AssertTree(t TestingT, root parlex.ParseNode, expected string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	exp, err := tree.FromSExpr(expected, nil)
+	if err != nil {
+		t.Errorf("%s", err)
+		return false
+	}
+	if Equal(exp, root) {
+		return true
+	}
+	t.Errorf("Trees do not match:\n%s", Diff(exp, root))
+	return false
+}
+
+// Equal returns true if the trees have the same kinds and values in the same
+// shape.
+func Equal(a, b parlex.ParseNode) bool {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+	if a.Kind().String() != b.Kind().String() || a.Value() != b.Value() || a.Children() != b.Children() {
+		return false
+	}
+	for i := 0; i < a.Children(); i++ {
+		if !Equal(a.Child(i), b.Child(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns a line diff of the trees with one node per line, indented by
+// depth. Lines only in expected start with "- ", lines only in actual start
+// with "+ " and lines in both start with two spaces. If the trees are equal,
+// an empty string is returned.
+func Diff(expected, actual parlex.ParseNode) string {
+	if Equal(expected, actual) {
+		return ""
+	}
+	a, b := lines(expected, "", nil), lines(actual, "", nil)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+func lines(node parlex.ParseNode, pad string, out []string) []string {
+	if isNil(node) {
+		return append(out, pad+"NIL")
+	}
+	out = append(out, pad+tree.ToSExpr(&tree.PN{Lexeme: node}))
+	for i := 0; i < node.Children(); i++ {
+		out = lines(node.Child(i), pad+"  ", out)
+	}
+	return out
+}
+
+// isNil is true for a nil node, including a nil pointer like a *tree.PN.
+func isNil(node parlex.ParseNode) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
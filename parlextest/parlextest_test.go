@@ -0,0 +1,62 @@
+package parlextest
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type recorder struct {
+	errs []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestAssertTree(t *testing.T) {
+	lxr := parlex.MustLexer(simplelexer.New(`
+    + /\+/
+    int /\d+/
+    space /\s+/ -
+  `))
+	grmr := parlex.MustGrammar(grammar.New(`
+    E -> E + int
+      -> int
+  `))
+	pn := packrat.New(grmr).Parse(lxr.Lex("1 + 2"))
+
+	assert.True(t, AssertTree(t, pn, `
+    (E
+      (E (int "1"))
+      (+ "+")
+      (int "2"))
+  `))
+
+	r := &recorder{}
+	assert.False(t, AssertTree(r, pn, `(E (E (int "1")) (+ "+") (int "3"))`))
+	if assert.Len(t, r.errs, 1) {
+		assert.Equal(t, `Trees do not match:
+  E
+    E
+      (int "1")
+    (+ "+")
+-   (int "3")
++   (int "2")
+`, r.errs[0])
+	}
+
+	r = &recorder{}
+	assert.False(t, AssertTree(r, nil, `E`))
+	assert.Equal(t, []string{"Trees do not match:\n- E\n+ NIL\n"}, r.errs)
+
+	r = &recorder{}
+	assert.False(t, AssertTree(r, pn, `(E`))
+	assert.Equal(t, []string{"Bad S-Expression: expected ) at 2"}, r.errs)
+
+	assert.Equal(t, "", Diff(pn, pn))
+}
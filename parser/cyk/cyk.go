@@ -0,0 +1,234 @@
+// Package cyk implements a CYK parser. It fills a table with every symbol that
+// can be built from every span of the lexemes, so it takes O(n³) time no matter
+// how ambiguous the grammar is, where packrat can take exponential time.
+//
+// Any grammar can be used; it is converted with grammar.ToCNF and the trees are
+// restored to the shape of the original grammar before they are returned.
+package cyk
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/parser/earley"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/adamcolton/parlex/tree"
+)
+
+// CYK is a CYK parser
+type CYK struct {
+	parlex.Grammar
+	cnf     *grammar.Grammar
+	reducer tree.Reducer
+	set     *setsymbol.Set
+	start   int
+	empty   bool
+	// unary holds the non-terminals that are a terminal, by the terminal
+	unary map[int][]int
+	// binary holds the productions that are two non-terminals
+	binary []binary
+}
+
+type binary struct {
+	nt, left, right int
+}
+
+// New returns a CYK parser for the grammar.
+func New(grmr parlex.Grammar) *CYK {
+	cnf, reducer := grammar.ToCNF(grmr)
+	c := &CYK{
+		Grammar: grmr,
+		cnf:     cnf,
+		reducer: reducer,
+		set:     setsymbol.New(),
+		start:   -1,
+		unary:   make(map[int][]int),
+	}
+	c.set.LoadGrammar(cnf)
+	for _, nt := range cnf.NonTerminals() {
+		ntIdx := c.set.Symbol(nt).Idx()
+		if c.start == -1 {
+			c.start = ntIdx
+		}
+		for i := cnf.Productions(nt).Iter(); i.Next(); {
+			switch i.Symbols() {
+			case 0:
+				c.empty = true
+			case 1:
+				t := c.set.Symbol(i.Symbol(0)).Idx()
+				c.unary[t] = append(c.unary[t], ntIdx)
+			case 2:
+				c.binary = append(c.binary, binary{
+					nt:    ntIdx,
+					left:  c.set.Symbol(i.Symbol(0)).Idx(),
+					right: c.set.Symbol(i.Symbol(1)).Idx(),
+				})
+			}
+		}
+	}
+	return c
+}
+
+// Constructor fulfills parlex.ParserConstructor
+func Constructor(grmr parlex.Grammar) (parlex.Parser, error) {
+	return New(grmr), nil
+}
+
+// CNF returns the grammar in Chomsky normal form that the parser uses.
+func (c *CYK) CNF() *grammar.Grammar {
+	return c.cnf
+}
+
+// Parse fulfills parlex.Parser. If the lexemes cannot be parsed, nil is
+// returned.
+func (c *CYK) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := c.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. The table does not show how far a valid
+// prefix of the lexemes reaches, so when the parse fails the lexemes are parsed
+// again with the Earley parser to get the *parlex.ParseError.
+func (c *CYK) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return c.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops and returns the context's error if the context is done before the
+// parse finishes.
+func (c *CYK) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	if c.start == -1 {
+		return nil, parlex.ErrBadGrammar
+	}
+	op := &cykOp{
+		CYK:     c,
+		lexemes: lexemes,
+	}
+	op.intr.Ctx = ctx
+	op.fill()
+	if op.intr.Err != nil {
+		return nil, op.intr.Err
+	}
+	pn := op.tree()
+	if pn == nil {
+		_, err := earley.New(c.Grammar).ParseContext(ctx, lexemes)
+		if err == nil {
+			err = parlex.NewParseError(len(lexemes), lexemes, nil)
+		}
+		return nil, err
+	}
+	return c.reducer.RawReduce(pn), nil
+}
+
+// ParseFrom fulfills parlex.FromParser. It is the same as ParseErr but the
+// lexemes are parsed as the non-terminal named start.
+func (c *CYK) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	grmr, err := parlex.StartFrom(c.Grammar, start)
+	if err != nil {
+		return nil, err
+	}
+	return New(grmr).ParseErr(lexemes)
+}
+
+// back records how a symbol was built for a span. For a span of one lexeme,
+// rule is -1.
+type back struct {
+	rule, split int
+}
+
+// cyk parse operation
+type cykOp struct {
+	*CYK
+	lexemes []parlex.Lexeme
+	// table holds the symbols built for each span by start and length-1
+	table [][]map[int]back
+	intr  parlex.Interrupt
+}
+
+func (op *cykOp) fill() {
+	n := len(op.lexemes)
+	op.table = make([][]map[int]back, n)
+	for i, lx := range op.lexemes {
+		op.table[i] = make([]map[int]back, n-i)
+		cell := make(map[int]back)
+		op.table[i][0] = cell
+		s := op.set.HasSymbol(lx.Kind())
+		if s == nil {
+			continue
+		}
+		for _, nt := range op.unary[s.Idx()] {
+			cell[nt] = back{rule: -1}
+		}
+	}
+	for ln := 2; ln <= n; ln++ {
+		for i := 0; i+ln <= n; i++ {
+			cell := make(map[int]back)
+			op.table[i][ln-1] = cell
+			// the first production wins and the left child is made as long as
+			// possible
+			for r, b := range op.binary {
+				if op.intr.Check() {
+					return
+				}
+				if _, ok := cell[b.nt]; ok {
+					continue
+				}
+				for split := ln - 1; split > 0; split-- {
+					if _, ok := op.table[i][split-1][b.left]; !ok {
+						continue
+					}
+					if _, ok := op.table[i+split][ln-split-1][b.right]; ok {
+						cell[b.nt] = back{rule: r, split: split}
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// tree builds the parse tree of the CNF grammar.
+func (op *cykOp) tree() *tree.PN {
+	n := len(op.lexemes)
+	if n == 0 {
+		if !op.empty {
+			return nil
+		}
+		return &tree.PN{
+			Lexeme: lexeme.New(op.set.ByIdx(op.start)),
+		}
+	}
+	if _, ok := op.table[0][n-1][op.start]; !ok {
+		return nil
+	}
+	return op.toPN(op.start, 0, n)
+}
+
+func (op *cykOp) toPN(nt, start, ln int) *tree.PN {
+	b := op.table[start][ln-1][nt]
+	lx := lexeme.New(op.set.ByIdx(nt))
+	pn := &tree.PN{
+		Lexeme: lx,
+	}
+	if b.rule == -1 {
+		leaf := &tree.PN{
+			Lexeme: lexeme.Copy(op.lexemes[start]),
+			P:      pn,
+		}
+		leaf.UpdateSpan()
+		pn.C = []*tree.PN{leaf}
+	} else {
+		r := op.binary[b.rule]
+		pn.C = []*tree.PN{
+			op.toPN(r.left, start, b.split),
+			op.toPN(r.right, start+b.split, ln-b.split),
+		}
+		for _, c := range pn.C {
+			c.P = pn
+		}
+	}
+	lx.At(pn.C[0].Pos()).AtOffset(pn.C[0].Offset())
+	pn.UpdateSpan()
+	return pn
+}
@@ -0,0 +1,141 @@
+package cyk
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/earley"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    , /,/
+    space /\s+/ -
+  `))
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		grammar string
+		inputs  []string
+	}{
+		{
+			grammar: `
+        E -> T op E
+          -> T
+        T -> ( E )
+          -> int
+      `,
+			inputs: []string{"1+(2+3)", "1", "((1)) * 2 - 3"},
+		},
+		{
+			// ambiguous
+			grammar: `
+        E -> E op E
+          -> ( E )
+          -> int
+      `,
+			inputs: []string{"1+2*3-4", "(1+2)*(3)"},
+		},
+		{
+			// empty productions and a nullable start
+			grammar: `
+        L -> ( Items )
+          ->
+        Items -> Item , Items
+              -> Item
+        Item  -> int
+              -> L
+              ->
+      `,
+			inputs: []string{"", "()", "(1,,2)", "(1,(),(2,3),)"},
+		},
+	}
+
+	for _, tc := range tests {
+		grmr, err := grammar.New(tc.grammar)
+		assert.NoError(t, err)
+		c := New(grmr)
+		for _, input := range tc.inputs {
+			lxs := lxr.Lex(input)
+			expected, err := earley.New(grmr).ParseErr(lxs)
+			if !assert.NoError(t, err, input) {
+				continue
+			}
+			pn, err := c.ParseErr(lxs)
+			if assert.NoError(t, err, input) {
+				assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String(), input)
+			}
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	c := New(grmr)
+
+	pn, err := c.ParseErr(lxr.Lex("1 + (2 3)"))
+	assert.Nil(t, pn)
+	if pe, ok := err.(*parlex.ParseError); assert.True(t, ok) {
+		assert.Equal(t, 4, pe.Pos)
+		assert.Equal(t, "3", pe.Lexeme.Value())
+	}
+
+	_, err = c.ParseErr(nil)
+	assert.Error(t, err)
+	assert.Nil(t, c.Parse(lxr.Lex("1 +")))
+}
+
+func TestParseFrom(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	pn, err := New(grmr).ParseFrom("T", lxr.Lex("(1+2)"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, "T", pn.Kind().String())
+	}
+}
+
+func TestContext(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = New(grmr).ParseContext(ctx, lxr.Lex("1+2+3+4+5+6+7+8+9"))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestCNF(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	expected, err := grammar.New(`
+    E   -> E E'
+        -> int
+    E'  -> op' E
+    op' -> op
+  `)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), New(grmr).CNF().String())
+}
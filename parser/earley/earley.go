@@ -0,0 +1,394 @@
+// Package earley implements an Earley parser. It can handle any context free
+// grammar, including ambiguous grammars, left recursion and empty productions.
+// When a grammar is ambiguous, the production declared first is preferred and
+// the left most children are made as long as possible. Each node records the
+// index of the production that built it, which is returned by Production.
+package earley
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/adamcolton/parlex/tree"
+)
+
+// Earley is an Earley parser
+type Earley struct {
+	parlex.Grammar
+}
+
+// New returns an Earley parser
+func New(grmr parlex.Grammar) *Earley {
+	return &Earley{
+		Grammar: grmr,
+	}
+}
+
+// Constructor fulfills parlex.ParserConstructor
+func Constructor(grmr parlex.Grammar) (parlex.Parser, error) {
+	return New(grmr), nil
+}
+
+// item is an Earley item; the production prod of non-terminal nt with the dot
+// before the symbol at dot, started at origin.
+type item struct {
+	nt, prod, dot, origin int
+}
+
+// spanKey identifies a symbol that was recognized between start and end.
+type spanKey struct {
+	idx, start, end int
+}
+
+type matchKey struct {
+	spanKey
+	prod, child int
+}
+
+// choice records which production built a node and where each child ends.
+type choice struct {
+	prod int
+	ends []int
+}
+
+// earley parse operation
+type eOp struct {
+	set      *setsymbol.Set
+	prods    [][][]int // [nonterminal][production][symbol]
+	nullable []bool
+	lxms     []*lexeme.Lexeme
+	// scan is set for a scannerless parse, the positions in the chart are then
+	// byte offsets in its source and begin is after any leading discards
+	scan       *scanOp
+	begin      int
+	chart      [][]item
+	seen       []map[item]bool
+	completed  map[spanKey]bool
+	choices    map[spanKey]*choice
+	matches    map[matchKey][]int
+	inProgress map[spanKey]bool
+	blocked    int
+	intr       parlex.Interrupt
+}
+
+// Parse fulfills parlex.Parser. If the lexemes cannot be parsed, nil is
+// returned.
+func (e *Earley) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := e.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. If the parse fails, a *parlex.ParseError
+// is returned for the furthest position in the chart that the parser reached
+// with the lexemes that were predicted there.
+func (e *Earley) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return e.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops and returns the context's error if the context is done before the
+// parse finishes.
+func (e *Earley) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	nts := e.NonTerminals()
+	if len(nts) == 0 {
+		return nil, parlex.ErrBadGrammar
+	}
+	op := newOp(e.Grammar, lexemes)
+	op.intr.Ctx = ctx
+	start := op.set.Symbol(nts[0]).Idx()
+	op.recognize(start)
+	if op.intr.Err != nil {
+		return nil, op.intr.Err
+	}
+	node := op.tree(start)
+	if node == nil {
+		return nil, op.parseError(lexemes)
+	}
+	return node, nil
+}
+
+// ParseFrom fulfills parlex.FromParser. It is the same as ParseErr but the
+// lexemes are parsed as the non-terminal named start.
+func (e *Earley) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	grmr, err := parlex.StartFrom(e.Grammar, start)
+	if err != nil {
+		return nil, err
+	}
+	return New(grmr).ParseErr(lexemes)
+}
+
+func (op *eOp) parseError(lexemes []parlex.Lexeme) *parlex.ParseError {
+	pos := len(op.chart) - 1
+	for len(op.chart[pos]) == 0 {
+		pos--
+	}
+	var expected []parlex.Symbol
+	for _, it := range op.chart[pos] {
+		prod := op.prods[it.nt][it.prod]
+		if it.dot < len(prod) && !op.isNonTerminal(prod[it.dot]) {
+			expected = append(expected, op.set.ByIdx(prod[it.dot]))
+		}
+	}
+	if op.scan != nil {
+		return op.scan.parseError(pos, expected)
+	}
+	return parlex.NewParseError(pos, lexemes, expected)
+}
+
+func newOp(grmr parlex.Grammar, lexemes []parlex.Lexeme) *eOp {
+	set := setsymbol.New()
+	set.LoadGrammar(grmr)
+	op := &eOp{
+		set:        set,
+		lxms:       set.LoadLexemes(lexemes),
+		completed:  make(map[spanKey]bool),
+		choices:    make(map[spanKey]*choice),
+		matches:    make(map[matchKey][]int),
+		inProgress: make(map[spanKey]bool),
+	}
+	op.prods = make([][][]int, set.Size())
+	for _, nt := range grmr.NonTerminals() {
+		ntIdx := set.Symbol(nt).Idx()
+		prods := grmr.Productions(nt)
+		op.prods[ntIdx] = make([][]int, prods.Productions())
+		for i := prods.Iter(); i.Next(); {
+			prod := make([]int, i.Symbols())
+			for j := i.Iter(); j.Next(); {
+				prod[j.Idx] = set.Symbol(j.Symbol).Idx()
+			}
+			op.prods[ntIdx][i.Idx] = prod
+		}
+	}
+	op.findNullable()
+	return op
+}
+
+func (op *eOp) findNullable() {
+	op.nullable = make([]bool, len(op.prods))
+	for changed := true; changed; {
+		changed = false
+		for nt, prods := range op.prods {
+			if op.nullable[nt] {
+				continue
+			}
+			for _, prod := range prods {
+				if op.allNullable(prod) {
+					op.nullable[nt] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+}
+
+func (op *eOp) allNullable(prod []int) bool {
+	for _, s := range prod {
+		if !op.isNonTerminal(s) || !op.nullable[s] {
+			return false
+		}
+	}
+	return true
+}
+
+func (op *eOp) isNonTerminal(idx int) bool {
+	return idx < len(op.prods) && op.prods[idx] != nil
+}
+
+func (op *eOp) isLexeme(idx, pos int) bool {
+	return pos < len(op.lxms) && op.lxms[pos].K.(*setsymbol.Symbol).Idx() == idx
+}
+
+// size is the last position in the chart.
+func (op *eOp) size() int {
+	if op.scan != nil {
+		return len(op.scan.src)
+	}
+	return len(op.lxms)
+}
+
+// terminal returns where a terminal that starts at pos ends, if it matches.
+func (op *eOp) terminal(idx, pos int) (int, bool) {
+	if op.scan != nil {
+		return op.scan.match(idx, pos)
+	}
+	return pos + 1, op.isLexeme(idx, pos)
+}
+
+// leaf returns the lexeme of a terminal that matched at pos.
+func (op *eOp) leaf(idx, pos int) *lexeme.Lexeme {
+	if op.scan != nil {
+		return op.scan.lexeme(op.set.ByIdx(idx), idx, pos)
+	}
+	return op.lxms[pos]
+}
+
+func (op *eOp) add(pos int, it item) {
+	if op.seen[pos] == nil {
+		op.seen[pos] = make(map[item]bool)
+	} else if op.seen[pos][it] {
+		return
+	}
+	op.seen[pos][it] = true
+	op.chart[pos] = append(op.chart[pos], it)
+}
+
+// recognize fills the chart and records every completed non-terminal span.
+func (op *eOp) recognize(start int) {
+	ln := op.size()
+	op.chart = make([][]item, ln+1)
+	op.seen = make([]map[item]bool, ln+1)
+	for p := range op.prods[start] {
+		op.add(op.begin, item{nt: start, prod: p, origin: op.begin})
+	}
+
+	for pos := op.begin; pos <= ln; pos++ {
+		for i := 0; i < len(op.chart[pos]); i++ {
+			if op.intr.Check() {
+				return
+			}
+			it := op.chart[pos][i]
+			prod := op.prods[it.nt][it.prod]
+			if it.dot == len(prod) {
+				op.complete(it, pos)
+				continue
+			}
+			next := prod[it.dot]
+			if op.isNonTerminal(next) {
+				op.predict(it, next, pos)
+			} else if end, ok := op.terminal(next, pos); ok {
+				it.dot++
+				op.add(end, it)
+			}
+		}
+	}
+}
+
+func (op *eOp) predict(it item, next, pos int) {
+	for p := range op.prods[next] {
+		op.add(pos, item{nt: next, prod: p, origin: pos})
+	}
+	// Aycock and Horspool: a nullable symbol can be skipped immediately
+	if op.nullable[next] {
+		it.dot++
+		op.add(pos, it)
+	}
+}
+
+func (op *eOp) complete(it item, pos int) {
+	op.completed[spanKey{it.nt, it.origin, pos}] = true
+	for i := 0; i < len(op.chart[it.origin]); i++ {
+		waiting := op.chart[it.origin][i]
+		prod := op.prods[waiting.nt][waiting.prod]
+		if waiting.dot < len(prod) && prod[waiting.dot] == it.nt {
+			waiting.dot++
+			op.add(pos, waiting)
+		}
+	}
+}
+
+// tree builds the parse tree for the start symbol covering all the lexemes.
+func (op *eOp) tree(start int) *tree.PN {
+	root := spanKey{start, op.begin, op.size()}
+	if !op.derive(root) {
+		return nil
+	}
+	return op.toPN(root)
+}
+
+// derive checks that a span can be built and records the choice of production
+// and children used to build it.
+func (op *eOp) derive(key spanKey) bool {
+	if !op.isNonTerminal(key.idx) {
+		end, ok := op.terminal(key.idx, key.start)
+		return ok && end == key.end
+	}
+	if _, ok := op.choices[key]; ok {
+		return true
+	}
+	if !op.completed[key] {
+		return false
+	}
+	if op.inProgress[key] {
+		op.blocked++
+		return false
+	}
+	op.inProgress[key] = true
+	defer delete(op.inProgress, key)
+	for p := range op.prods[key.idx] {
+		if ends := op.match(matchKey{key, p, 0}); ends != nil {
+			op.choices[key] = &choice{
+				prod: p,
+				ends: ends[:len(ends)-1],
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// match tries to match the symbols of a production starting from child to the
+// end of the span. The returned slice holds the end of each child followed by
+// a sentinel; nil means no match.
+func (op *eOp) match(key matchKey) []int {
+	if ends, ok := op.matches[key]; ok {
+		return ends
+	}
+	prod := op.prods[key.idx][key.prod]
+	blocked := op.blocked
+	var ends []int
+	if key.child == len(prod) {
+		if key.start == key.end {
+			ends = []int{key.end}
+		}
+	} else {
+		for e := key.end; e >= key.start && ends == nil; e-- {
+			if !op.derive(spanKey{prod[key.child], key.start, e}) {
+				continue
+			}
+			next := key
+			next.start = e
+			next.child++
+			if rest := op.match(next); rest != nil {
+				ends = append([]int{e}, rest...)
+			}
+		}
+	}
+	// a failure caused by a cycle is not cached, it may succeed from a
+	// different path
+	if ends != nil || blocked == op.blocked {
+		op.matches[key] = ends
+	}
+	return ends
+}
+
+func (op *eOp) toPN(key spanKey) *tree.PN {
+	if !op.isNonTerminal(key.idx) {
+		pn := &tree.PN{
+			Lexeme: op.leaf(key.idx, key.start),
+		}
+		pn.UpdateSpan()
+		return pn
+	}
+	ch := op.choices[key]
+	prod := op.prods[key.idx][ch.prod]
+	lx := lexeme.New(op.set.ByIdx(key.idx))
+	pn := &tree.PN{
+		Lexeme: lx,
+		C:      make([]*tree.PN, len(prod)),
+	}
+	pn.SetProduction(ch.prod)
+	start := key.start
+	for i, s := range prod {
+		c := op.toPN(spanKey{s, start, ch.ends[i]})
+		c.P = pn
+		pn.C[i] = c
+		start = ch.ends[i]
+	}
+	if len(pn.C) > 0 {
+		lx.At(pn.C[0].Pos()).AtOffset(pn.C[0].Offset())
+	}
+	pn.UpdateSpan()
+	return pn
+}
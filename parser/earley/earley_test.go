@@ -0,0 +1,208 @@
+package earley
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `))
+
+func TestParse(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	pn := New(grmr).Parse(lxr.Lex("1+(2+3)"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      E {
+        T {
+          int: "1"
+        }
+        op: "+"
+        E {
+          T {
+            (: "("
+            E {
+              T {
+                int: "2"
+              }
+              op: "+"
+              E {
+                T {
+                  int: "3"
+                }
+              }
+            }
+            ): ")"
+          }
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+}
+
+func TestAmbiguousLeftRecursion(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	pn := New(grmr).Parse(lxr.Lex("1+2+3"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      E {
+        E {
+          E {
+            int: "1"
+          }
+          op: "+"
+          E {
+            int: "2"
+          }
+        }
+        op: "+"
+        E {
+          int: "3"
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+		assert.Equal(t, tree.Span{Start: 0, End: 5}, pn.(*tree.PN).S)
+	}
+}
+
+func TestNullable(t *testing.T) {
+	grmr, err := grammar.New(`
+    L -> L Item
+      ->
+    Item -> Sign int
+    Sign -> op
+         ->
+  `)
+	assert.NoError(t, err)
+
+	pn := New(grmr).Parse(lxr.Lex("1 -2 3"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      L {
+        L {
+          L {
+            L
+            Item {
+              Sign
+              int: "1"
+            }
+          }
+          Item {
+            Sign {
+              op: "-"
+            }
+            int: "2"
+          }
+        }
+        Item {
+          Sign
+          int: "3"
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+}
+
+func TestCyclic(t *testing.T) {
+	grmr, err := grammar.New(`
+    A -> B
+      -> int
+    B -> A
+  `)
+	assert.NoError(t, err)
+
+	pn := New(grmr).Parse(lxr.Lex("1"))
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, "A {\n\tint: \"1\"\n}\n", pn.(*tree.PN).String())
+	}
+}
+
+func TestFail(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	assert.True(t, New(grmr).Parse(lxr.Lex("1+")) == nil)
+}
+
+func TestParseErr(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	_, err = New(grmr).ParseErr(lxr.Lex("1 + (2 3)"))
+	if pe, ok := err.(*parlex.ParseError); assert.True(t, ok) {
+		assert.Equal(t, 4, pe.Pos)
+		assert.Equal(t, "3", pe.Lexeme.Value())
+		assert.Equal(t, "[) op]", fmt.Sprint(pe.Expected))
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	p := New(grmr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pn, err := p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	_, err = p.ParseContext(ctx, lxr.Lex("1+"))
+	_, ok := err.(*parlex.ParseError)
+	assert.True(t, ok)
+	cancel()
+	pn, err = p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.Nil(t, pn)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestProduction(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	pn := New(grmr).Parse(lxr.Lex("(1)+2")).(*tree.PN)
+	assert.Equal(t, 0, pn.Production())
+	assert.Equal(t, 0, pn.C[0].Production())
+	assert.Equal(t, 1, pn.C[0].C[1].Production())
+	assert.Equal(t, 1, pn.C[2].Production())
+	assert.Equal(t, 1, pn.C[2].C[0].Production())
+	assert.Equal(t, -1, pn.C[1].Production())
+}
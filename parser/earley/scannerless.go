@@ -0,0 +1,224 @@
+package earley
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scannerless parses a string without a separate lexer. Each terminal is
+// matched against the source only where the grammar expects it, so the same
+// characters can be different terminals in different places. With
+//   TypeArgs -> < Types >
+//   Shift    -> Expr >> Expr
+// the >> that closes List<List<int>> is read as two > because only > is
+// expected there.
+//
+// The pattern of a terminal comes from the rule of the same kind in the lexer,
+// including its keywords. A terminal without a rule matches its own name, like
+// ( or while. After each terminal, and at the start, the rules that discard
+// what they match are skipped.
+//
+// A terminal that is a literal word, like a keyword, does not match the start
+// of a longer word, and a terminal that is not a literal does not match any of
+// the literal words in the grammar, so they are reserved.
+type Scannerless struct {
+	*Earley
+	terms   map[string]*terminal
+	skip    []*regexp.Regexp
+	reserve map[string]bool
+}
+
+type terminal struct {
+	re *regexp.Regexp
+	// word is true for a literal that ends with a letter, digit or _
+	word bool
+}
+
+// Scannerless returns a parser that reads strings with the rules of the lexer
+// as the patterns of the terminals. The lexer can be nil if every terminal
+// matches its name.
+func (e *Earley) Scannerless(lxr *simplelexer.Lexer) *Scannerless {
+	s := &Scannerless{
+		Earley:  e,
+		terms:   make(map[string]*terminal),
+		reserve: make(map[string]bool),
+	}
+	nts := make(map[string]bool)
+	for _, nt := range e.NonTerminals() {
+		nts[nt.String()] = true
+	}
+	for _, nt := range e.NonTerminals() {
+		for i := e.Productions(nt).Iter(); i.Next(); {
+			for j := i.Iter(); j.Next(); {
+				if name := j.Symbol.String(); !nts[name] && s.terms[name] == nil {
+					s.terms[name] = s.terminal(lxr, j.Symbol)
+				}
+			}
+		}
+	}
+	if lxr != nil {
+		for _, d := range lxr.Discards() {
+			s.skip = append(s.skip, anchor(lxr.Pattern(d)))
+		}
+	}
+	return s
+}
+
+func (s *Scannerless) terminal(lxr *simplelexer.Lexer, sym parlex.Symbol) *terminal {
+	var re *regexp.Regexp
+	if lxr != nil {
+		re = lxr.Pattern(sym)
+	}
+	if re == nil {
+		re = regexp.MustCompile(regexp.QuoteMeta(sym.String()))
+	}
+	t := &terminal{
+		re: anchor(re),
+	}
+	if lit, complete := re.LiteralPrefix(); complete && lit != "" {
+		r, _ := utf8.DecodeLastRuneInString(lit)
+		t.word = isWord(r)
+		if t.word {
+			s.reserve[lit] = true
+		}
+	}
+	return t
+}
+
+// anchor makes a regexp only match at the start of the string.
+func anchor(re *regexp.Regexp) *regexp.Regexp {
+	return regexp.MustCompile(`^(?:` + re.String() + `)`)
+}
+
+func isWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// ParseString parses the source. If the parse fails, a *parlex.ParseError is
+// returned with the byte offset the parser reached as its Pos and the
+// character there as its Lexeme.
+func (s *Scannerless) ParseString(src string) (parlex.ParseNode, error) {
+	return s.ParseStringContext(context.Background(), src)
+}
+
+// ParseStringContext is the same as ParseString but stops and returns the
+// context's error if the context is done before the parse finishes.
+func (s *Scannerless) ParseStringContext(ctx context.Context, src string) (parlex.ParseNode, error) {
+	nts := s.NonTerminals()
+	if len(nts) == 0 {
+		return nil, parlex.ErrBadGrammar
+	}
+	op := newOp(s.Grammar, nil)
+	op.intr.Ctx = ctx
+	op.scan = &scanOp{
+		Scannerless: s,
+		src:         src,
+		source:      tree.NewSource(src),
+		terms:       make([]*terminal, op.set.Size()),
+		matches:     make(map[scanKey]int),
+	}
+	for i := range op.scan.terms {
+		op.scan.terms[i] = s.terms[op.set.ByIdx(i).String()]
+	}
+	op.begin = op.scan.skipFrom(0)
+	start := op.set.Symbol(nts[0]).Idx()
+	op.recognize(start)
+	if op.intr.Err != nil {
+		return nil, op.intr.Err
+	}
+	node := op.tree(start)
+	if node == nil {
+		return nil, op.parseError(nil)
+	}
+	return node, nil
+}
+
+type scanKey struct {
+	idx, pos int
+}
+
+// scanner operation for one source
+type scanOp struct {
+	*Scannerless
+	src    string
+	source *tree.Source
+	// terms is indexed by symbol and matches holds where each terminal that
+	// was tried ends, or -1
+	terms   []*terminal
+	matches map[scanKey]int
+}
+
+// skipFrom returns the offset after any discarded text at pos.
+func (op *scanOp) skipFrom(pos int) int {
+	for skipped := true; skipped; {
+		skipped = false
+		for _, re := range op.skip {
+			if loc := re.FindStringIndex(op.src[pos:]); loc != nil && loc[1] > 0 {
+				pos += loc[1]
+				skipped = true
+			}
+		}
+	}
+	return pos
+}
+
+// match returns the end of the terminal that starts at pos, including any
+// discarded text after it.
+func (op *scanOp) match(idx, pos int) (int, bool) {
+	key := scanKey{idx, pos}
+	if end, ok := op.matches[key]; ok {
+		return end, end >= 0
+	}
+	end := -1
+	if n := op.length(idx, pos); n > 0 {
+		end = op.skipFrom(pos + n)
+	}
+	op.matches[key] = end
+	return end, end >= 0
+}
+
+// length returns the length of the terminal at pos, 0 if it does not match.
+func (op *scanOp) length(idx, pos int) int {
+	t := op.terms[idx]
+	if t == nil {
+		return 0
+	}
+	loc := t.re.FindStringIndex(op.src[pos:])
+	if loc == nil {
+		return 0
+	}
+	n := loc[1]
+	if t.word {
+		if r, _ := utf8.DecodeRuneInString(op.src[pos+n:]); pos+n < len(op.src) && isWord(r) {
+			return 0
+		}
+	} else if op.reserve[op.src[pos:pos+n]] {
+		return 0
+	}
+	return n
+}
+
+func (op *scanOp) lexeme(kind parlex.Symbol, idx, pos int) *lexeme.Lexeme {
+	return lexeme.New(kind).
+		Set(op.src[pos : pos+op.length(idx, pos)]).
+		At(op.source.Pos(pos)).
+		AtOffset(pos)
+}
+
+// parseError reports the character at the furthest offset the parser reached.
+func (op *scanOp) parseError(pos int, expected []parlex.Symbol) *parlex.ParseError {
+	var found []parlex.Lexeme
+	if pos < len(op.src) {
+		_, n := utf8.DecodeRuneInString(op.src[pos:])
+		found = []parlex.Lexeme{lexeme.String("char").Set(op.src[pos : pos+n]).At(op.source.Pos(pos)).AtOffset(pos)}
+	}
+	err := parlex.NewParseError(0, found, expected)
+	err.Pos = pos
+	return err
+}
@@ -0,0 +1,126 @@
+package earley
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScannerless(t *testing.T) {
+	grmr := parlex.MustGrammar(grammar.New(`
+    Stmt  -> Type name ;
+          -> name = Expr ;
+    Type  -> name < Types >
+          -> name
+    Types -> Type , Types
+          -> Type
+    Expr  -> Expr >> Expr
+          -> name
+          -> int
+  `))
+	lxr, err := simplelexer.New(`
+    name    /[A-Za-z_]\w*/
+    int     /\d+/
+    space   /\s+/ -
+    comment /\/\/[^\n]*/ -
+  `)
+	assert.NoError(t, err)
+	s := New(grmr).Scannerless(lxr)
+
+	// >> closes two type arguments
+	pn, err := s.ParseString("List<List<int>> x;")
+	if assert.NoError(t, err) {
+		expected, _ := tree.New(`
+      Stmt {
+        Type {
+          name: "List"
+          <: "<"
+          Types {
+            Type {
+              name: "List"
+              <: "<"
+              Types {
+                Type {
+                  name: "int"
+                }
+              }
+              >: ">"
+            }
+          }
+          >: ">"
+        }
+        name: "x"
+        ;: ";"
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+
+	// and is a shift in an expression
+	src := "  // shift\n  y = a >> 2 ; "
+	pn, err = s.ParseString(src)
+	if assert.NoError(t, err) {
+		shift := pn.Child(2)
+		assert.Equal(t, 3, shift.Children())
+		assert.Equal(t, ">>", shift.Child(1).Value())
+		l, c := shift.Child(1).Pos()
+		assert.Equal(t, 2, l)
+		assert.Equal(t, 9, c)
+		assert.Equal(t, "a >> 2", src[shift.(*tree.PN).S.Start:shift.(*tree.PN).S.End])
+	}
+
+	_, err = s.ParseString("y = a >> ;")
+	assert.EqualError(t, err, "Could Not Parse 1:10) found char: ;, expected int, name")
+	_, err = s.ParseString("List<int x;")
+	assert.EqualError(t, err, "Could Not Parse 1:10) found char: x, expected ,, <, >")
+	_, err = s.ParseString("y = a")
+	assert.EqualError(t, err, "Could Not Parse) found end of input, expected ;, >>")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.ParseStringContext(ctx, "x;")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestScannerlessWords(t *testing.T) {
+	grmr := parlex.MustGrammar(grammar.New(`
+    S -> while name ;
+      -> name ;
+  `))
+	lxr, err := simplelexer.New(`
+    name  /\w+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	s := New(grmr).Scannerless(lxr)
+
+	// a literal word does not match the start of a longer word
+	pn, err := s.ParseString("whilex;")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, pn.Children())
+		assert.Equal(t, "whilex", pn.Child(0).Value())
+	}
+
+	pn, err = s.ParseString("while x;")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "while", pn.Child(0).Kind().String())
+	}
+
+	// and is reserved
+	_, err = s.ParseString("while;")
+	assert.EqualError(t, err, "Could Not Parse 1:6) found char: ;, expected name")
+
+	// without a lexer every terminal matches its name
+	s = New(parlex.MustGrammar(grammar.New(`
+    S -> ( S )
+      ->
+  `))).Scannerless(nil)
+	_, err = s.ParseString("(())")
+	assert.NoError(t, err)
+	_, err = s.ParseString("(()")
+	assert.EqualError(t, err, "Could Not Parse) found end of input, expected )")
+}
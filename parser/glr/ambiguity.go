@@ -0,0 +1,73 @@
+package glr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"strconv"
+	"strings"
+)
+
+// Ambiguity describes a span of the input that can be derived more than one
+// way. Each entry in Derivations is one of the competing productions written
+// with the span of lexemes each symbol covers, like "E -> E[0:3] op[3:4] E[4:5]".
+// Line and Col are the position of the first lexeme in the span.
+type Ambiguity struct {
+	Symbol      parlex.Symbol
+	Start, End  int
+	Line, Col   int
+	Derivations []string
+}
+
+// String describes the ambiguity in the form
+//   1:1) E[0:5] is ambiguous: E -> E[0:3] op[3:4] E[4:5] | E -> E[0:1] op[1:2] E[2:5]
+func (a Ambiguity) String() string {
+	return fmt.Sprintf("%d:%d) %s[%d:%d] is ambiguous: %s", a.Line, a.Col, a.Symbol, a.Start, a.End, strings.Join(a.Derivations, " | "))
+}
+
+// Ambiguities parses the lexemes and reports every position where more than
+// one derivation exists. Running it over a corpus of sample inputs is a way to
+// find accidental ambiguities in a grammar. If the lexemes cannot be parsed,
+// ErrCouldNotParse is returned.
+func (g *GLR) Ambiguities(lexemes []parlex.Lexeme) ([]Ambiguity, error) {
+	f := g.ParseForest(lexemes)
+	if f.Root == nil {
+		return nil, parlex.ErrCouldNotParse
+	}
+	return f.Report(lexemes), nil
+}
+
+// Report describes each of the ambiguous nodes in the forest. The lexemes
+// should be the ones that were parsed and are used to find the line and column
+// of each ambiguity.
+func (f *Forest) Report(lexemes []parlex.Lexeme) []Ambiguity {
+	nodes := f.Ambiguities()
+	if len(nodes) == 0 {
+		return nil
+	}
+	out := make([]Ambiguity, len(nodes))
+	for i, n := range nodes {
+		a := Ambiguity{
+			Symbol:      n.Symbol,
+			Start:       n.Start,
+			End:         n.End,
+			Derivations: make([]string, len(n.Alternatives)),
+		}
+		if n.Start < len(lexemes) {
+			a.Line, a.Col = lexemes[n.Start].Pos()
+		}
+		for j, alt := range n.Alternatives {
+			a.Derivations[j] = derivation(n.Symbol, alt)
+		}
+		out[i] = a
+	}
+	return out
+}
+
+func derivation(s parlex.Symbol, alt *Alternative) string {
+	strs := make([]string, 0, len(alt.Children)+2)
+	strs = append(strs, s.String(), "->")
+	for _, c := range alt.Children {
+		strs = append(strs, c.Symbol.String()+"["+strconv.Itoa(c.Start)+":"+strconv.Itoa(c.End)+"]")
+	}
+	return strings.Join(strs, " ")
+}
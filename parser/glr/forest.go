@@ -0,0 +1,222 @@
+package glr
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/tree"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Node is a node in a shared packed parse forest. A node represents a symbol
+// that was recognized from the lexeme at Start up to, but not including, the
+// lexeme at End. Terminal nodes hold the Lexeme that was matched. Non-terminal
+// nodes hold every way the symbol could be derived over that span as
+// Alternatives. Nodes are shared between all the alternatives that use them.
+type Node struct {
+	Symbol       parlex.Symbol
+	Lexeme       parlex.Lexeme
+	Start, End   int
+	Alternatives []*Alternative
+	altKeys      map[string]bool
+}
+
+// Alternative is one derivation of a Node. Production is the index of the
+// production within the non-terminal's productions.
+type Alternative struct {
+	Production int
+	Children   []*Node
+}
+
+// Terminal returns true if the node represents a lexeme.
+func (n *Node) Terminal() bool {
+	return n.Lexeme != nil
+}
+
+// Ambiguous returns true if there is more than one way to derive the node.
+func (n *Node) Ambiguous() bool {
+	return len(n.Alternatives) > 1
+}
+
+func (n *Node) addAlternative(production int, children []*Node) {
+	strs := make([]string, len(children)+1)
+	strs[0] = strconv.Itoa(production)
+	for i, c := range children {
+		strs[i+1] = strconv.Itoa(c.Start) + ":" + c.Symbol.String() + ":" + strconv.Itoa(c.End)
+	}
+	key := strings.Join(strs, " ")
+	if n.altKeys[key] {
+		return
+	}
+	n.altKeys[key] = true
+	n.Alternatives = append(n.Alternatives, &Alternative{
+		Production: production,
+		Children:   children,
+	})
+	sort.SliceStable(n.Alternatives, func(i, j int) bool {
+		return n.Alternatives[i].Production < n.Alternatives[j].Production
+	})
+}
+
+// Forest is a shared packed parse forest. It holds every parse tree for the
+// input.
+type Forest struct {
+	Root *Node
+}
+
+// Count returns the number of distinct parse trees in the forest. If the
+// grammar is cyclic, there are infinitely many and -1 is returned.
+func (f *Forest) Count() int {
+	if f == nil || f.Root == nil {
+		return 0
+	}
+	return count(f.Root, make(map[*Node]int), make(map[*Node]bool))
+}
+
+func count(n *Node, memo map[*Node]int, stack map[*Node]bool) int {
+	if n.Terminal() {
+		return 1
+	}
+	if c, ok := memo[n]; ok {
+		return c
+	}
+	if stack[n] {
+		return -1
+	}
+	stack[n] = true
+	total := 0
+	for _, alt := range n.Alternatives {
+		product := 1
+		for _, c := range alt.Children {
+			cc := count(c, memo, stack)
+			if cc == -1 {
+				return -1
+			}
+			product *= cc
+		}
+		total += product
+	}
+	stack[n] = false
+	memo[n] = total
+	return total
+}
+
+// Ambiguities returns every node reachable from the root that has more than
+// one alternative, ordered by position.
+func (f *Forest) Ambiguities() []*Node {
+	if f == nil || f.Root == nil {
+		return nil
+	}
+	var out []*Node
+	seen := map[*Node]bool{f.Root: true}
+	stack := []*Node{f.Root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.Ambiguous() {
+			out = append(out, n)
+		}
+		for _, alt := range n.Alternatives {
+			for _, c := range alt.Children {
+				if !seen[c] {
+					seen[c] = true
+					stack = append(stack, c)
+				}
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Start != out[j].Start {
+			return out[i].Start < out[j].Start
+		}
+		if out[i].End != out[j].End {
+			return out[i].End > out[j].End
+		}
+		return out[i].Symbol.String() < out[j].Symbol.String()
+	})
+	return out
+}
+
+// Tree returns the preferred parse tree; at each node the first alternative,
+// which uses the production declared first, is chosen.
+func (f *Forest) Tree() *tree.PN {
+	var pn *tree.PN
+	f.Each(func(t *tree.PN) bool {
+		pn = t
+		return false
+	})
+	return pn
+}
+
+// Each calls fn with each parse tree in the forest until fn returns false.
+// Derivations where a node is it's own descendant are skipped so that cyclic
+// grammars still produce a finite set of trees. The trees passed to fn do not
+// share any nodes and can be safely modified.
+func (f *Forest) Each(fn func(*tree.PN) bool) {
+	if f == nil || f.Root == nil {
+		return
+	}
+	each(f.Root, make(map[*Node]bool), func(pn *tree.PN) bool {
+		return fn(copyPN(pn, nil))
+	})
+}
+
+func each(n *Node, path map[*Node]bool, fn func(*tree.PN) bool) bool {
+	if n.Terminal() {
+		pn := &tree.PN{Lexeme: n.Lexeme}
+		pn.UpdateSpan()
+		return fn(pn)
+	}
+	path[n] = true
+	defer delete(path, n)
+	for _, alt := range n.Alternatives {
+		cyclic := false
+		for _, c := range alt.Children {
+			cyclic = cyclic || path[c]
+		}
+		if cyclic {
+			continue
+		}
+		cont := eachChildren(alt.Children, path, nil, func(children []*tree.PN) bool {
+			lx := lexeme.New(n.Symbol)
+			if len(children) > 0 {
+				lx.At(children[0].Pos()).AtOffset(children[0].Offset())
+			}
+			pn := &tree.PN{
+				Lexeme: lx,
+				C:      children,
+			}
+			pn.UpdateSpan()
+			return fn(pn)
+		})
+		if !cont {
+			return false
+		}
+	}
+	return true
+}
+
+func eachChildren(ns []*Node, path map[*Node]bool, acc []*tree.PN, fn func([]*tree.PN) bool) bool {
+	if len(acc) == len(ns) {
+		return fn(append([]*tree.PN(nil), acc...))
+	}
+	return each(ns[len(acc)], path, func(pn *tree.PN) bool {
+		return eachChildren(ns, path, append(acc, pn), fn)
+	})
+}
+
+// copyPN makes a copy of the tree so that subtrees shared during enumeration
+// are not shared between the trees that are returned.
+func copyPN(pn, parent *tree.PN) *tree.PN {
+	cp := &tree.PN{
+		Lexeme: pn.Lexeme,
+		P:      parent,
+		C:      make([]*tree.PN, len(pn.C)),
+		S:      pn.S,
+	}
+	for i, c := range pn.C {
+		cp.C[i] = copyPN(c, cp)
+	}
+	return cp
+}
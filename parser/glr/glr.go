@@ -0,0 +1,255 @@
+// Package glr implements a generalized LR parser. Instead of choosing a single
+// parse tree, it produces a shared packed parse forest that holds every parse
+// of the input. This makes it useful for finding and working with ambiguous
+// grammars.
+package glr
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/parser/lr"
+	"sync"
+)
+
+// GLR is a generalized LR parser. It fulfills parlex.Parser, returning the
+// preferred tree from the forest, and ParseForest returns the whole forest.
+type GLR struct {
+	parlex.Grammar
+	a    *lr.Automaton
+	mux  sync.Mutex
+	from map[string]*GLR
+}
+
+// New returns a GLR parser. The LR(0) automaton for the grammar is built once
+// and shared by every parse.
+func New(grmr parlex.Grammar) *GLR {
+	g := &GLR{
+		Grammar: grmr,
+	}
+	if len(grmr.NonTerminals()) > 0 {
+		g.a = lr.New(grmr)
+	}
+	return g
+}
+
+// Constructor fulfills parlex.ParserConstructor
+func Constructor(grmr parlex.Grammar) (parlex.Parser, error) {
+	return New(grmr), nil
+}
+
+// Parse fulfills parlex.Parser. It returns the preferred tree in the parse
+// forest or nil if the lexemes cannot be parsed.
+func (g *GLR) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn := g.ParseForest(lexemes).Tree()
+	if pn == nil {
+		return nil
+	}
+	return pn
+}
+
+type gssNode struct {
+	state, level int
+	edges        []gssEdge
+}
+
+type gssEdge struct {
+	to   *gssNode
+	node *Node
+}
+
+type forestKey struct {
+	idx, start, end int
+}
+
+// glr parse operation
+type glrOp struct {
+	*lr.Automaton
+	lxms    []parlex.Lexeme
+	forest  map[forestKey]*Node
+	level   []*gssNode
+	byState map[int]*gssNode
+	intr    parlex.Interrupt
+}
+
+// ParseContext fulfills parlex.ContextParser. It returns the preferred tree in
+// the parse forest. If the context is done before the parse finishes, the
+// context's error is returned and if the lexemes cannot be parsed,
+// ErrCouldNotParse is returned.
+func (g *GLR) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	f, err := g.ParseForestContext(ctx, lexemes)
+	if err != nil {
+		return nil, err
+	}
+	if f.Root == nil {
+		return nil, parlex.ErrCouldNotParse
+	}
+	return f.Tree(), nil
+}
+
+// ParseFrom fulfills parlex.FromParser. It is the same as ParseContext but the
+// lexemes are parsed as the non-terminal named start. The automaton for each
+// start symbol is built the first time it is used.
+func (g *GLR) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	g.mux.Lock()
+	sub, ok := g.from[start]
+	if !ok {
+		grmr, err := parlex.StartFrom(g.Grammar, start)
+		if err != nil {
+			g.mux.Unlock()
+			return nil, err
+		}
+		sub = New(grmr)
+		if g.from == nil {
+			g.from = make(map[string]*GLR)
+		}
+		g.from[start] = sub
+	}
+	g.mux.Unlock()
+	return sub.ParseContext(context.Background(), lexemes)
+}
+
+// ParseForest parses the lexemes and returns a forest holding every parse
+// tree. If the lexemes cannot be parsed, the forest will have a nil Root.
+func (g *GLR) ParseForest(lexemes []parlex.Lexeme) *Forest {
+	f, _ := g.ParseForestContext(context.Background(), lexemes)
+	return f
+}
+
+// ParseForestContext is the same as ParseForest but stops and returns the
+// context's error if the context is done before the parse finishes.
+func (g *GLR) ParseForestContext(ctx context.Context, lexemes []parlex.Lexeme) (*Forest, error) {
+	if g.a == nil {
+		return &Forest{}, nil
+	}
+	op := &glrOp{
+		Automaton: g.a,
+		lxms:      lexemes,
+		forest:    make(map[forestKey]*Node),
+		intr:      parlex.Interrupt{Ctx: ctx},
+	}
+	op.newLevel()
+	op.getNode(0, 0)
+	for pos := 0; ; pos++ {
+		op.reduceAll(pos)
+		if op.intr.Err != nil {
+			return &Forest{}, op.intr.Err
+		}
+		if pos == len(lexemes) || !op.shift(pos) {
+			break
+		}
+	}
+	return &Forest{
+		Root: op.forest[forestKey{op.Start, 0, len(lexemes)}],
+	}, nil
+}
+
+func (op *glrOp) newLevel() {
+	op.level = nil
+	op.byState = make(map[int]*gssNode)
+}
+
+func (op *glrOp) getNode(state, pos int) (*gssNode, bool) {
+	if n, ok := op.byState[state]; ok {
+		return n, false
+	}
+	n := &gssNode{
+		state: state,
+		level: pos,
+	}
+	op.byState[state] = n
+	op.level = append(op.level, n)
+	return n, true
+}
+
+func (op *glrOp) forestNode(idx, start, end int) *Node {
+	key := forestKey{idx, start, end}
+	n, ok := op.forest[key]
+	if !ok {
+		n = &Node{
+			Symbol:  op.Set.ByIdx(idx),
+			Start:   start,
+			End:     end,
+			altKeys: make(map[string]bool),
+		}
+		op.forest[key] = n
+	}
+	return n
+}
+
+// reduceAll performs every possible reduction at the current level until no
+// new stack nodes or edges are created.
+func (op *glrOp) reduceAll(pos int) {
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < len(op.level); i++ {
+			v := op.level[i]
+			for _, pIdx := range op.States[v.state].Reductions {
+				p := op.Prods[pIdx]
+				op.paths(v, len(p.Symbols), nil, func(u *gssNode, children []*Node) {
+					if op.reduce(u, p, children, pos) {
+						changed = true
+					}
+				})
+			}
+		}
+	}
+}
+
+// paths calls fn with every stack node n edges below v along with the forest
+// nodes on those edges.
+func (op *glrOp) paths(v *gssNode, n int, acc []*Node, fn func(*gssNode, []*Node)) {
+	if op.intr.Check() {
+		return
+	}
+	if n == 0 {
+		children := make([]*Node, len(acc))
+		for i, c := range acc {
+			children[len(acc)-1-i] = c
+		}
+		fn(v, children)
+		return
+	}
+	for _, e := range v.edges {
+		op.paths(e.to, n-1, append(acc, e.node), fn)
+	}
+}
+
+func (op *glrOp) reduce(u *gssNode, p lr.Prod, children []*Node, pos int) bool {
+	to, ok := op.States[u.state].Trans[p.NT]
+	if !ok {
+		return false
+	}
+	fn := op.forestNode(p.NT, u.level, pos)
+	fn.addAlternative(p.Idx, children)
+
+	w, changed := op.getNode(to, pos)
+	for _, e := range w.edges {
+		if e.to == u && e.node == fn {
+			return changed
+		}
+	}
+	w.edges = append(w.edges, gssEdge{to: u, node: fn})
+	return true
+}
+
+func (op *glrOp) shift(pos int) bool {
+	kind := op.Set.Idx(op.lxms[pos].Kind())
+	if kind < 0 {
+		return false
+	}
+	leaf := &Node{
+		Symbol: op.lxms[pos].Kind(),
+		Lexeme: op.lxms[pos],
+		Start:  pos,
+		End:    pos + 1,
+	}
+	prev := op.level
+	op.newLevel()
+	for _, v := range prev {
+		if to, ok := op.States[v.state].Trans[kind]; ok {
+			w, _ := op.getNode(to, pos+1)
+			w.edges = append(w.edges, gssEdge{to: v, node: leaf})
+		}
+	}
+	return len(op.level) > 0
+}
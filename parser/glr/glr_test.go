@@ -0,0 +1,246 @@
+package glr
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `))
+
+func TestParse(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	p := New(grmr)
+	f := p.ParseForest(lxr.Lex("1+(2+3)"))
+	assert.Equal(t, 1, f.Count())
+	assert.Len(t, f.Ambiguities(), 0)
+
+	pn := p.Parse(lxr.Lex("1+(2+3)"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      E {
+        T {
+          int: "1"
+        }
+        op: "+"
+        E {
+          T {
+            (: "("
+            E {
+              T {
+                int: "2"
+              }
+              op: "+"
+              E {
+                T {
+                  int: "3"
+                }
+              }
+            }
+            ): ")"
+          }
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+
+	assert.True(t, p.Parse(lxr.Lex("1+")) == nil)
+}
+
+func TestAmbiguous(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	f := New(grmr).ParseForest(lxr.Lex("1+2+3+4"))
+	// Catalan number: 5 ways to group 4 operands
+	assert.Equal(t, 5, f.Count())
+
+	var trees []string
+	f.Each(func(pn *tree.PN) bool {
+		trees = append(trees, pn.String())
+		return true
+	})
+	assert.Len(t, trees, 5)
+	seen := make(map[string]bool)
+	for _, tr := range trees {
+		assert.False(t, seen[tr])
+		seen[tr] = true
+	}
+
+	amb := f.Ambiguities()
+	if assert.Len(t, amb, 3) {
+		assert.Equal(t, 0, amb[0].Start)
+		assert.Equal(t, 7, amb[0].End)
+		assert.Len(t, amb[0].Alternatives, 3)
+	}
+}
+
+func TestNullableAndLeftRecursion(t *testing.T) {
+	grmr, err := grammar.New(`
+    L -> L Item
+      ->
+    Item -> Sign int
+    Sign -> op
+         ->
+  `)
+	assert.NoError(t, err)
+
+	f := New(grmr).ParseForest(lxr.Lex("1 -2 3"))
+	assert.Equal(t, 1, f.Count())
+	expected, _ := tree.New(`
+    L {
+      L {
+        L {
+          L
+          Item {
+            Sign
+            int: "1"
+          }
+        }
+        Item {
+          Sign {
+            op: "-"
+          }
+          int: "2"
+        }
+      }
+      Item {
+        Sign
+        int: "3"
+      }
+    }
+  `)
+	assert.Equal(t, expected.String(), f.Tree().String())
+}
+
+func TestCyclic(t *testing.T) {
+	grmr, err := grammar.New(`
+    A -> B
+      -> int
+    B -> A
+  `)
+	assert.NoError(t, err)
+
+	f := New(grmr).ParseForest(lxr.Lex("1"))
+	assert.Equal(t, -1, f.Count())
+	n := 0
+	f.Each(func(pn *tree.PN) bool {
+		n++
+		return true
+	})
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "A {\n\tint: \"1\"\n}\n", f.Tree().String())
+}
+
+func TestAmbiguityReport(t *testing.T) {
+	grmr, err := grammar.New(`
+    S -> E
+    E -> E op E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	g := New(grmr)
+
+	corpus := map[string]int{
+		"1":         0,
+		"(1+2)":     0,
+		"1+2+3":     1,
+		"(1+2+3)+4": 1,
+		"1+2+3+4":   3,
+	}
+	for in, expected := range corpus {
+		amb, err := g.Ambiguities(lxr.Lex(in))
+		assert.NoError(t, err, in)
+		assert.Len(t, amb, expected, in)
+	}
+
+	amb, _ := g.Ambiguities(lxr.Lex("1+2+3"))
+	if assert.Len(t, amb, 1) {
+		assert.Equal(t, "E", amb[0].Symbol.String())
+		assert.Equal(t, []string{
+			"E -> E[0:1] op[1:2] E[2:5]",
+			"E -> E[0:3] op[3:4] E[4:5]",
+		}, sorted(amb[0].Derivations))
+		assert.Equal(t, 1, amb[0].Col)
+	}
+
+	amb, _ = g.Ambiguities(lxr.Lex("(1+2+3)+(4+5+6)"))
+	if assert.Len(t, amb, 2) {
+		assert.Equal(t, 1, amb[0].Start)
+		assert.Equal(t, 9, amb[1].Start)
+		assert.Equal(t, 10, amb[1].Col)
+	}
+
+	_, err = g.Ambiguities(lxr.Lex("1+"))
+	assert.Equal(t, parlex.ErrCouldNotParse, err)
+}
+
+func sorted(strs []string) []string {
+	out := append([]string(nil), strs...)
+	sort.Strings(out)
+	return out
+}
+
+func TestParseContext(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	g := New(grmr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pn, err := g.ParseContext(ctx, lxr.Lex("1+2+3"))
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	_, err = g.ParseContext(ctx, lxr.Lex("1+"))
+	assert.Equal(t, parlex.ErrCouldNotParse, err)
+	cancel()
+	pn, err = g.ParseContext(ctx, lxr.Lex("1+2+3"))
+	assert.Nil(t, pn)
+	assert.Equal(t, context.Canceled, err)
+	f, err := g.ParseForestContext(ctx, lxr.Lex("1+2+3"))
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, f.Root)
+}
+
+func TestParseFrom(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p := New(grmr)
+
+	pn, err := parlex.ParseFrom(p, "T", lxr.Lex("(1+2)"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "T", pn.Kind().String())
+	}
+	_, err = p.ParseFrom("T", lxr.Lex("1+2"))
+	assert.Equal(t, parlex.ErrCouldNotParse, err)
+}
@@ -0,0 +1,216 @@
+// Package island parses languages embedded in another language, like SQL in a
+// string of a host language or the code fences of Markdown. The outer lexer
+// captures the embedded source as one lexeme and a Language lexes, parses and
+// reduces its value with its own pipeline.
+//
+// A Language fulfills parlex.PrefixParser, so it can be one of the Islands of
+// the packrat parser. When the outer parser reaches the island's non-terminal,
+// the inner tree becomes its subtree. The positions in the inner tree and in
+// any error are positions in the outer source.
+//
+//   p := packrat.New(hostGrammar)
+//   p.Islands = map[string]parlex.PrefixParser{
+//     "Query": &island.Language{
+//       Region: "string",
+//       Lexer:  sqlLexer,
+//       Parser: sqlParser,
+//       Trim:   island.Quoted,
+//     },
+//   }
+package island
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/tree"
+	"strings"
+)
+
+// Language is an embedded language. Region is the kind of the outer lexeme
+// that holds its source; if it is empty, any lexeme can. The Reducer can be
+// nil. Trim returns the source from the value of the lexeme and the number of
+// bytes removed from the start of the value; if it is nil, the whole value is
+// the source.
+type Language struct {
+	Region  string
+	Lexer   parlex.Lexer
+	Parser  parlex.Parser
+	Reducer parlex.Reducer
+	Trim    func(value string) (string, int)
+}
+
+// Quoted is a Trim that removes the first and last byte, like the quotes around
+// a string.
+func Quoted(value string) (string, int) {
+	if len(value) < 2 {
+		return "", 0
+	}
+	return value[1 : len(value)-1], 1
+}
+
+// Fenced is a Trim that removes the first and last line, like the fences around
+// a block of code in Markdown.
+//   ```sql
+//   SELECT a FROM t
+//   ```
+func Fenced(value string) (string, int) {
+	start := strings.IndexByte(value, '\n') + 1
+	if start == 0 {
+		return "", 0
+	}
+	end := strings.LastIndexByte(value, '\n')
+	if end < start {
+		return "", start
+	}
+	return value[start:end], start
+}
+
+// Parse fulfills parlex.Parser. The lexemes should be a single region.
+func (l *Language) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := l.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. The lexemes should be a single region.
+func (l *Language) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	pn, rest, err := l.ParsePrefix(lexemes)
+	if err == nil && len(rest) > 0 {
+		err = parlex.NewParseError(1, lexemes, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pn, nil
+}
+
+// ParsePrefix fulfills parlex.PrefixParser. It parses the first lexeme as a
+// region and returns the lexemes after it.
+func (l *Language) ParsePrefix(lexemes []parlex.Lexeme) (parlex.ParseNode, []parlex.Lexeme, error) {
+	if len(lexemes) == 0 || (l.Region != "" && lexemes[0].Kind().String() != l.Region) {
+		var expected []parlex.Symbol
+		if l.Region != "" {
+			expected = []parlex.Symbol{lexeme.String(l.Region).K}
+		}
+		return nil, lexemes, parlex.NewParseError(0, lexemes, expected)
+	}
+	pn, err := l.ParseLexeme(lexemes[0])
+	if err != nil {
+		return nil, lexemes, err
+	}
+	return pn, lexemes[1:], nil
+}
+
+// ParseLexeme parses the source held by a lexeme. A *parlex.ParseError or a
+// parlex.LexError from the inner language is returned with the position of the
+// lexeme in the outer source.
+func (l *Language) ParseLexeme(region parlex.Lexeme) (parlex.ParseNode, error) {
+	src, n := region.Value(), 0
+	if l.Trim != nil {
+		src, n = l.Trim(src)
+	}
+	o := originOf(region, n)
+	pn, err := parlex.Run(src, l.Lexer, l.Parser, l.Reducer)
+	if err != nil {
+		return nil, o.err(err)
+	}
+	cp := tree.Copy(pn)
+	o.tree(cp)
+	return cp, nil
+}
+
+// origin is where the inner source starts in the outer source. An offset of -1
+// is not known.
+type origin struct {
+	line, col, offset int
+}
+
+// originOf finds the origin of a source that starts n bytes into the value of
+// a lexeme.
+func originOf(region parlex.Lexeme, n int) origin {
+	var o origin
+	o.line, o.col = region.Pos()
+	o.offset = parlex.Offset(region)
+	if o.offset >= 0 {
+		o.offset += n
+	}
+	for _, r := range region.Value()[:n] {
+		if r == '\n' {
+			o.line++
+			o.col = 1
+		} else {
+			o.col++
+		}
+	}
+	return o
+}
+
+// lexeme copies a lexeme from the inner source with its position in the outer
+// source.
+func (o origin) lexeme(lx parlex.Lexeme) *lexeme.Lexeme {
+	cp := lexeme.Copy(lx)
+	if cp.L == 1 {
+		cp.C += o.col - 1
+	}
+	if cp.L > 0 {
+		cp.L += o.line - 1
+	}
+	if cp.O >= 0 && o.offset >= 0 {
+		cp.O += o.offset
+	} else {
+		cp.O = -1
+	}
+	cp.Lead = o.lexemes(cp.Lead)
+	cp.Trail = o.lexemes(cp.Trail)
+	return cp
+}
+
+func (o origin) lexemes(lxs []parlex.Lexeme) []parlex.Lexeme {
+	if lxs == nil {
+		return nil
+	}
+	cp := make([]parlex.Lexeme, len(lxs))
+	for i, lx := range lxs {
+		cp[i] = o.lexeme(lx)
+	}
+	return cp
+}
+
+func (o origin) tree(pn *tree.PN) {
+	pn.Lexeme = o.lexeme(pn.Lexeme)
+	if !pn.S.Empty() {
+		if o.offset < 0 {
+			pn.S = tree.Span{}
+		} else {
+			pn.S.Start += o.offset
+			pn.S.End += o.offset
+		}
+	}
+	for _, c := range pn.C {
+		o.tree(c)
+	}
+}
+
+func (o origin) err(err error) error {
+	switch e := err.(type) {
+	case *parlex.ParseError:
+		cp := *e
+		if cp.Lexeme != nil {
+			cp.Lexeme = o.lexeme(cp.Lexeme)
+		}
+		return &cp
+	case parlex.LexError:
+		return lexError{o.lexeme(e)}
+	}
+	return err
+}
+
+// lexError is a parlex.LexError from the inner source at its position in the
+// outer source.
+type lexError struct {
+	*lexeme.Lexeme
+}
+
+func (e lexError) Error() string {
+	return fmt.Sprintf("Lex Error %d:%d) %s", e.L, e.C, e.V)
+}
@@ -0,0 +1,139 @@
+package island
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var sql = &Language{
+	Region: "string",
+	Lexer: parlex.MustLexer(simplelexer.New(`
+    keywords select from
+    , /,/
+    name /\w+/
+    space /\s+/ -
+  `)),
+	Parser: packrat.New(parlex.MustGrammar(grammar.New(`
+    Select -> select Names from name
+    Names  -> name , Names
+           -> name
+  `))),
+	Trim: Quoted,
+}
+
+var host = packrat.New(parlex.MustGrammar(grammar.New(`
+  Prog -> Stmt Prog
+       ->
+  Stmt -> name = Query ;
+       -> name = string ;
+`)))
+
+var hostLexer = parlex.MustLexer(simplelexer.New(`
+  = /=/
+  ; /;/
+  string /"[^"]*"/
+  name /\w+/
+  space /\s+/ -
+`))
+
+func init() {
+	host.Islands = map[string]parlex.PrefixParser{"Query": sql}
+}
+
+func TestIsland(t *testing.T) {
+	src := "q = \"select a, b from t\";\nr = \"x\";"
+	pn, err := host.ParseErr(hostLexer.Lex(src))
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected, _ := tree.New(`
+    Prog {
+      Stmt {
+        name: "q"
+        =: "="
+        Query {
+          Select {
+            select: "select"
+            Names {
+              name: "a"
+              ,: ","
+              Names {
+                name: "b"
+              }
+            }
+            from: "from"
+            name: "t"
+          }
+        }
+        ;: ";"
+      }
+      Prog {
+        Stmt {
+          name: "r"
+          =: "="
+          string: "\"x\""
+          ;: ";"
+        }
+        Prog
+      }
+    }
+  `)
+	assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+
+	// positions in the inner tree are in the outer source
+	b := pn.Child(0).Child(2).Child(0).Child(1).Child(2).Child(0)
+	assert.Equal(t, "b", b.Value())
+	l, c := b.Pos()
+	assert.Equal(t, 1, l)
+	assert.Equal(t, 16, c)
+	assert.Equal(t, tree.Span{Start: 15, End: 16}, b.(*tree.PN).S)
+	assert.Equal(t, "b", src[15:16])
+}
+
+func TestErrors(t *testing.T) {
+	lxs := hostLexer.Lex(`q = "select a b from t"`)
+	_, err := sql.ParseErr(lxs[2:])
+	assert.EqualError(t, err, "Could Not Parse 1:15) found name: b, expected ,, from")
+
+	_, err = sql.ParseErr(hostLexer.Lex(`"select a from t" x`))
+	assert.EqualError(t, err, "Could Not Parse 1:19) found name: x, expected end of input")
+
+	_, err = sql.ParseErr(lxs[:1])
+	assert.EqualError(t, err, "Could Not Parse 1:1) found name: q, expected string")
+
+	_, err = sql.ParseErr(hostLexer.Lex(`"select ! from t"`))
+	assert.EqualError(t, err, "Lex Error 1:9) !")
+	_, ok := err.(parlex.LexError)
+	assert.True(t, ok)
+}
+
+func TestFenced(t *testing.T) {
+	md := &Language{
+		Lexer:  sql.Lexer,
+		Parser: sql.Parser,
+		Trim:   Fenced,
+	}
+	fence := parlex.MustLexer(simplelexer.New("fence /```\\w*\\n[^`]*```/"))
+	src := "```sql\nselect a\n  from t\n```"
+	pn, err := md.ParseErr(fence.Lex(src))
+	if assert.NoError(t, err) {
+		from := pn.Child(2)
+		assert.Equal(t, "from", from.Value())
+		l, c := from.Pos()
+		assert.Equal(t, 3, l)
+		assert.Equal(t, 3, c)
+		assert.Equal(t, "from", src[from.(*tree.PN).S.Start:from.(*tree.PN).S.End])
+	}
+
+	s, n := Fenced("```\n```")
+	assert.Equal(t, "", s)
+	assert.Equal(t, 4, n)
+	s, n = Fenced("```")
+	assert.Equal(t, "", s)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,108 @@
+package lalr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/internal/bincodec"
+)
+
+// binaryMagic starts the binary form of the parser Tables, the last byte is the
+// version.
+const binaryMagic = "plxP\x01"
+
+// MarshalBinary fulfills encoding.BinaryMarshaler. The binary form is compact,
+// is the same each time for the same tables and can be embedded in a program
+// and loaded by FromBinary.
+func (t *Tables) MarshalBinary() ([]byte, error) {
+	w := bincodec.NewWriter(binaryMagic)
+	w.Int(len(t.Symbols))
+	for _, s := range t.Symbols {
+		w.Str(s)
+	}
+	w.Int(t.End)
+	w.Int(len(t.Prods))
+	for _, p := range t.Prods {
+		w.Int(p[0] + 1)
+		w.Int(p[1])
+	}
+	w.Int(len(t.Actions))
+	for _, acts := range t.Actions {
+		w.IntMap(acts)
+	}
+	w.Int(len(t.Gotos))
+	for _, gotos := range t.Gotos {
+		w.IntMap(gotos)
+	}
+	return w.Buf, nil
+}
+
+// UnmarshalBinary fulfills encoding.BinaryUnmarshaler. It reads the form
+// written by MarshalBinary and checks that the tables refer to symbols, states
+// and productions that exist.
+func (t *Tables) UnmarshalBinary(data []byte) error {
+	r, err := bincodec.NewReader(data, binaryMagic, "lalr")
+	if err != nil {
+		return err
+	}
+	var out Tables
+	out.Symbols = make([]string, r.Count())
+	for i := range out.Symbols {
+		out.Symbols[i] = r.Str()
+	}
+	// the end of the input is the symbol after the last one
+	out.End = r.Idx(len(out.Symbols) + 1)
+	symbols := out.End + 1
+	out.Prods = make([][2]int, r.Count())
+	for i := range out.Prods {
+		// the start production has no non-terminal
+		out.Prods[i] = [2]int{r.Idx(symbols+1) - 1, r.Int()}
+		if r.Err == nil && out.Prods[i][1] < 0 {
+			r.Err = fmt.Errorf("Bad Tables: production %d has %d symbols", i, out.Prods[i][1])
+		}
+	}
+	out.Actions = make([]map[int]int, r.Count())
+	states := len(out.Actions)
+	for i := range out.Actions {
+		out.Actions[i] = r.IntMap(symbols, func(n int) bool {
+			if n > 0 {
+				return n-1 < states
+			}
+			return n < 0 && -n-1 < len(out.Prods)
+		})
+	}
+	out.Gotos = make([]map[int]int, r.Count())
+	for i := range out.Gotos {
+		out.Gotos[i] = r.IntMap(symbols, func(n int) bool {
+			return n >= 0 && n < states
+		})
+	}
+	if r.Err == nil && (states == 0 || len(out.Prods) == 0) {
+		r.Err = fmt.Errorf("Bad Tables: there are no states")
+	}
+	if r.Err == nil && len(out.Gotos) != states {
+		r.Err = fmt.Errorf("Bad Tables: %d states with %d gotos", states, len(out.Gotos))
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+	*t = out
+	return nil
+}
+
+// MarshalBinary fulfills encoding.BinaryMarshaler by writing the Tables of the
+// parser.
+func (l *LALR) MarshalBinary() ([]byte, error) {
+	t := l.Tables()
+	if t == nil {
+		return nil, fmt.Errorf("Empty Grammar: the parser has no tables")
+	}
+	return t.MarshalBinary()
+}
+
+// FromBinary returns a parser from tables written by MarshalBinary.
+func FromBinary(data []byte) (*LALR, error) {
+	t := &Tables{}
+	if err := t.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return FromTables(t), nil
+}
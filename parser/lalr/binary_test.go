@@ -0,0 +1,97 @@
+package lalr
+
+import (
+	"encoding"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    * /\*/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    E -> E + T
+      -> T
+    T -> T * F
+      -> F
+    F -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := p.MarshalBinary()
+	if !assert.NoError(t, err) {
+		return
+	}
+	again, err := p.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	loaded, err := FromBinary(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, in := range []string{"1+2*3", "(1+2)*3", "1+", "1 2"} {
+		expected, expectedErr := p.ParseErr(lxr.Lex(in))
+		pn, err := loaded.ParseErr(lxr.Lex(in))
+		if expectedErr != nil {
+			assert.Equal(t, expectedErr.Error(), err.Error(), in)
+			continue
+		}
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String(), in)
+		}
+	}
+
+	tbls := &Tables{}
+	assert.NoError(t, tbls.UnmarshalBinary(data))
+	assert.Equal(t, p.Tables(), tbls)
+
+	var _ encoding.BinaryMarshaler = p
+	var _ encoding.BinaryUnmarshaler = tbls
+}
+
+func TestBinaryErrors(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E + int
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	assert.NoError(t, err)
+	data, err := p.MarshalBinary()
+	assert.NoError(t, err)
+
+	for i := range data {
+		_, err := FromBinary(data[:i])
+		assert.Error(t, err, i)
+	}
+	_, err = FromBinary(append(data, 0))
+	assert.Equal(t, "Bad Tables: 1 bytes after the tables", err.Error())
+
+	_, err = FromBinary([]byte("plxL\x01"))
+	assert.Equal(t, "Bad Tables: not lalr tables or an unsupported version", err.Error())
+
+	tbls := p.Tables()
+	tbls.Actions[0][tbls.End] = len(tbls.Actions) + 1
+	data, err = tbls.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = FromBinary(data)
+	assert.Error(t, err)
+
+	_, err = Resolve(grammar.Empty()).MarshalBinary()
+	assert.Equal(t, "Empty Grammar: the parser has no tables", err.Error())
+}
@@ -0,0 +1,152 @@
+package lalr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/parser/lr"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"sort"
+	"strings"
+)
+
+// Tables are the parse tables of an LALR parser in a form that can be written
+// as Go code. A parser made by FromTables parses the same as the parser the
+// tables came from without building them; cmd/parlexc uses them to compile a
+// grammar into a program.
+//
+// Symbols are referred to by their index in Symbols and End is the index used
+// for the end of the input.
+type Tables struct {
+	Symbols []string
+	End     int
+	// Prods hold the non-terminal and the number of symbols of each
+	// production. Production 0 is the start production.
+	Prods [][2]int
+	// Actions hold the action for each state and lookahead. An action n > 0
+	// shifts and moves to state n-1, n < 0 reduces by production -n-1. Reducing
+	// by production 0 accepts the input.
+	Actions []map[int]int
+	// Gotos hold the state moved to from each state after reducing to a
+	// non-terminal.
+	Gotos []map[int]int
+}
+
+// Tables returns the parse tables of the parser. If the grammar has no
+// non-terminals, nil is returned.
+func (l *LALR) Tables() *Tables {
+	if l.tables == nil {
+		return nil
+	}
+	t := &Tables{
+		End:     l.end,
+		Prods:   make([][2]int, len(l.Prods)),
+		Actions: make([]map[int]int, len(l.actions)),
+		Gotos:   make([]map[int]int, len(l.Automaton.States)),
+	}
+	for i := 0; i < l.Set.Size(); i++ {
+		t.Symbols = append(t.Symbols, l.Set.ByIdx(i).String())
+	}
+	for i, p := range l.Prods {
+		t.Prods[i] = [2]int{p.NT, len(p.Symbols)}
+	}
+	for sIdx, acts := range l.actions {
+		t.Actions[sIdx] = make(map[int]int, len(acts))
+		for la, act := range acts {
+			switch act.kind {
+			case shift:
+				t.Actions[sIdx][la] = act.to + 1
+			case reduce:
+				t.Actions[sIdx][la] = -act.to - 1
+			case accept:
+				t.Actions[sIdx][la] = -1
+			}
+		}
+	}
+	for sIdx, s := range l.Automaton.States {
+		t.Gotos[sIdx] = make(map[int]int)
+		for sym, to := range s.Trans {
+			if l.IsNonTerminal(sym) {
+				t.Gotos[sIdx][sym] = to
+			}
+		}
+	}
+	return t
+}
+
+// FromTables returns a parser that uses the tables. It does not have the
+// grammar, so it cannot ParseFrom another non-terminal.
+func FromTables(t *Tables) *LALR {
+	set := setsymbol.New()
+	for _, s := range t.Symbols {
+		set.Str(s)
+	}
+	a := &lr.Automaton{
+		Set:    set,
+		Prods:  make([]lr.Prod, len(t.Prods)),
+		States: make([]*lr.State, len(t.Gotos)),
+	}
+	for i, p := range t.Prods {
+		a.Prods[i] = lr.Prod{NT: p[0], Symbols: make([]int, p[1])}
+	}
+	for i, gotos := range t.Gotos {
+		a.States[i] = &lr.State{Trans: gotos}
+	}
+	tbls := &tables{
+		Automaton: a,
+		end:       t.End,
+		actions:   make([]map[int]action, len(t.Actions)),
+	}
+	for sIdx, acts := range t.Actions {
+		tbls.actions[sIdx] = make(map[int]action, len(acts))
+		for la, n := range acts {
+			switch {
+			case n > 0:
+				tbls.actions[sIdx][la] = action{kind: shift, to: n - 1}
+			case n == -1:
+				tbls.actions[sIdx][la] = action{kind: accept}
+			default:
+				tbls.actions[sIdx][la] = action{kind: reduce, to: -n - 1}
+			}
+		}
+	}
+	return &LALR{tables: tbls}
+}
+
+// GoString returns the tables as a Go expression so they can be written into a
+// program. It fulfills fmt.GoStringer.
+func (t *Tables) GoString() string {
+	var buf strings.Builder
+	buf.WriteString("&lalr.Tables{\n")
+	fmt.Fprintf(&buf, "Symbols: %#v,\n", t.Symbols)
+	fmt.Fprintf(&buf, "End: %d,\n", t.End)
+	buf.WriteString("Prods: [][2]int{")
+	for i, p := range t.Prods {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "{%d, %d}", p[0], p[1])
+	}
+	buf.WriteString("},\nActions: []map[int]int{\n")
+	for i, acts := range t.Actions {
+		fmt.Fprintf(&buf, "/* %d */ %s,\n", i, intMap(acts))
+	}
+	buf.WriteString("},\nGotos: []map[int]int{\n")
+	for i, gotos := range t.Gotos {
+		fmt.Fprintf(&buf, "/* %d */ %s,\n", i, intMap(gotos))
+	}
+	buf.WriteString("},\n}")
+	return buf.String()
+}
+
+// intMap writes a map with the keys in order.
+func intMap(m map[int]int) string {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	strs := make([]string, len(keys))
+	for i, k := range keys {
+		strs[i] = fmt.Sprintf("%d: %d", k, m[k])
+	}
+	return "{" + strings.Join(strs, ", ") + "}"
+}
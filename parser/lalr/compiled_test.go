@@ -0,0 +1,70 @@
+package lalr
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"go/parser"
+	"strings"
+	"testing"
+)
+
+func TestTables(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    * /\*/
+    == /==/
+    ; /;/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    %nonassoc ==
+    %left +
+    %left *
+    L -> L S
+      ->
+    S -> E ;
+    E -> E + E
+      -> E * E
+      -> E == E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	tbls := p.Tables()
+	compiled := FromTables(tbls)
+
+	for _, in := range []string{"1+2*3; (1+2)*3 == 9;", "", "1 == 2 == 3;", "1+(2 3);", "1+"} {
+		expected, expectedErr := p.ParseErr(lxr.Lex(in))
+		pn, err := compiled.ParseErr(lxr.Lex(in))
+		if expectedErr != nil {
+			assert.Nil(t, pn, in)
+			assert.Equal(t, expectedErr.Error(), err.Error(), in)
+			continue
+		}
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String(), in)
+		}
+	}
+
+	_, err = compiled.ParseFrom("E", lxr.Lex("1"))
+	assert.EqualError(t, err, "Cannot Parse From: E, the parser has no grammar")
+
+	str := tbls.GoString()
+	assert.True(t, strings.HasPrefix(str, "&lalr.Tables{\n"))
+	_, err = parser.ParseExpr(str)
+	assert.NoError(t, err)
+
+	assert.Nil(t, Resolve(grammar.Empty()).Tables())
+	var _ parlex.ErrParser = compiled
+}
@@ -0,0 +1,227 @@
+package lalr
+
+import (
+	"github.com/adamcolton/parlex/parser/lr"
+	"strings"
+	"unicode/utf8"
+)
+
+// Explanation shows how the parser reaches a conflict. There is a Derivation
+// for each of the competing items.
+type Explanation struct {
+	Conflict
+	Derivations []Derivation
+}
+
+// Derivation is one way to reach the state of a conflict. Item is the item in
+// the conflict state, like "E -> E op E •". Example is a sentential form that
+// reaches the item with • where the parser is, and Tree is the derivation of
+// the example with each production below the symbol it derives.
+//   E
+//   ↳ E op E
+//          ↳ E • op E
+type Derivation struct {
+	Action  string
+	Item    string
+	Example string
+	Tree    string
+}
+
+// String fulfills Stringer
+func (e Explanation) String() string {
+	var buf strings.Builder
+	buf.WriteString(e.Conflict.String())
+	for _, d := range e.Derivations {
+		buf.WriteString("\n  ")
+		buf.WriteString(d.Action)
+		buf.WriteString(" ")
+		buf.WriteString(d.Item)
+		buf.WriteString("\n    Example: ")
+		buf.WriteString(d.Example)
+		buf.WriteString("\n    Derivation:")
+		for _, line := range strings.Split(d.Tree, "\n") {
+			buf.WriteString("\n      ")
+			buf.WriteString(line)
+		}
+	}
+	return buf.String()
+}
+
+// Explain finds an example of each conflict, similar to the counterexamples of
+// bison. For each item that competes in the conflict, it finds the shortest
+// derivation from the start symbol that reaches the item in the conflict state
+// with the lookahead of the conflict next. When the examples of a shift/reduce
+// conflict are the same, the input is ambiguous; the two trees are the two ways
+// to parse it.
+func (l *LALR) Explain() []Explanation {
+	cs := l.Conflicts()
+	out := make([]Explanation, len(cs))
+	for i, c := range cs {
+		out[i] = l.explain(c)
+	}
+	return out
+}
+
+func (l *LALR) explain(c Conflict) Explanation {
+	e := Explanation{Conflict: c}
+	la := l.end
+	if c.Lookahead != "$end" {
+		la = l.Set.Str(c.Lookahead).Idx()
+	}
+	prods := make(map[string]bool, len(c.Productions))
+	for _, p := range c.Productions {
+		prods[p] = true
+	}
+	for _, it := range l.Automaton.States[c.State].Items {
+		next := l.Next(it)
+		action := "Reduce"
+		if next == la {
+			action = "Shift"
+		} else if next != -1 || !prods[l.ProdString(it.Prod)] {
+			continue
+		}
+		path := l.search(c.State, it, la)
+		if path == nil {
+			continue
+		}
+		d := l.derivation(path)
+		d.Action = action
+		e.Derivations = append(e.Derivations, d)
+	}
+	return e
+}
+
+// node is an item in a state with the lookahead that follows the production
+// of the item.
+type node struct {
+	state int
+	item  lr.Item
+	la    int
+}
+
+// search finds the shortest path from the start to the item in the state. A
+// path either moves the dot over a symbol or steps into a production of the
+// non-terminal after the dot. For a complete item, the lookahead must be la.
+func (l *LALR) search(state int, target lr.Item, la int) []node {
+	start := node{item: lr.Item{Prod: 0}, la: l.end}
+	from := map[node]node{start: start}
+	queue := []node{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.state == state && n.item == target && (l.Next(target) != -1 || n.la == la) {
+			var path []node
+			for ; n != start; n = from[n] {
+				path = append(path, n)
+			}
+			path = append(path, start)
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+		next := l.Next(n.item)
+		if next == -1 {
+			continue
+		}
+		var steps []node
+		steps = append(steps, node{
+			state: l.Automaton.States[n.state].Trans[next],
+			item:  lr.Item{Prod: n.item.Prod, Dot: n.item.Dot + 1},
+			la:    n.la,
+		})
+		if l.IsNonTerminal(next) {
+			firsts := make(map[int]bool)
+			l.firstOf(l.Prods[n.item.Prod].Symbols[n.item.Dot+1:], n.la, firsts)
+			for _, pIdx := range l.ByNT[next] {
+				for f := range firsts {
+					steps = append(steps, node{
+						state: n.state,
+						item:  lr.Item{Prod: pIdx},
+						la:    f,
+					})
+				}
+			}
+		}
+		for _, s := range steps {
+			if _, seen := from[s]; !seen {
+				from[s] = n
+				queue = append(queue, s)
+			}
+		}
+	}
+	return nil
+}
+
+// derivation turns a path into the example and tree. Each step into a
+// production opens a frame below the symbol it derives and the dot of the last
+// frame is where the parser is.
+func (l *LALR) derivation(path []node) Derivation {
+	type frame struct {
+		prod, dot int
+	}
+	frames := []frame{{}}
+	for _, n := range path[1:] {
+		if n.item.Dot == 0 {
+			frames = append(frames, frame{prod: n.item.Prod})
+		} else {
+			frames[len(frames)-1].dot++
+		}
+	}
+	last := frames[len(frames)-1]
+	d := Derivation{
+		Item: l.itemString(lr.Item{Prod: last.prod, Dot: last.dot}),
+	}
+
+	var example []string
+	for _, f := range frames {
+		example = append(example, l.symbols(f.prod, 0, f.dot)...)
+	}
+	example = append(example, "•")
+	example = append(example, l.symbols(last.prod, last.dot, -1)...)
+	for i := len(frames) - 2; i >= 0; i-- {
+		example = append(example, l.symbols(frames[i].prod, frames[i].dot+1, -1)...)
+	}
+	d.Example = strings.Join(example, " ")
+
+	// the frame of the augmented start production is left out
+	lines := []string{l.Set.ByIdx(l.Prods[frames[1].prod].NT).String()}
+	col := 0
+	for i, f := range frames[1:] {
+		syms := l.symbols(f.prod, 0, -1)
+		if i == len(frames)-2 {
+			syms = append(syms[:f.dot], append([]string{"•"}, syms[f.dot:]...)...)
+		}
+		line := strings.Repeat(" ", col) + "↳ " + strings.Join(syms, " ")
+		lines = append(lines, strings.TrimRight(line, " "))
+		// the next frame goes below the symbol after the dot
+		col += 2
+		for _, s := range syms[:f.dot] {
+			col += utf8.RuneCountInString(s) + 1
+		}
+	}
+	d.Tree = strings.Join(lines, "\n")
+	return d
+}
+
+// symbols returns the symbols of a production from start up to end, or to the
+// end of the production if end is -1.
+func (l *LALR) symbols(pIdx, start, end int) []string {
+	syms := l.Prods[pIdx].Symbols
+	if end == -1 {
+		end = len(syms)
+	}
+	out := make([]string, 0, end-start)
+	for _, s := range syms[start:end] {
+		out = append(out, l.Set.ByIdx(s).String())
+	}
+	return out
+}
+
+// itemString returns the item in the form "E -> E • op E".
+func (l *LALR) itemString(it lr.Item) string {
+	p := l.Prods[it.Prod]
+	syms := l.symbols(it.Prod, 0, -1)
+	syms = append(syms[:it.Dot], append([]string{"•"}, syms[it.Dot:]...)...)
+	return l.Set.ByIdx(p.NT).String() + " -> " + strings.Join(syms, " ")
+}
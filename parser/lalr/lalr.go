@@ -0,0 +1,180 @@
+// Package lalr implements a table driven LALR(1) parser. The tables are
+// computed from any parlex.Grammar. Parsing runs in linear time and only keeps
+// a stack, so it is well suited to large inputs.
+package lalr
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/parser/lr"
+	"github.com/adamcolton/parlex/tree"
+	"sync"
+)
+
+// LALR is an LALR(1) parser
+type LALR struct {
+	parlex.Grammar
+	*tables
+	mux  sync.Mutex
+	from map[string]*LALR
+}
+
+// New computes the LALR(1) tables for the grammar. If the grammar has
+// conflicts, a ConflictError is returned describing all of them.
+func New(grmr parlex.Grammar) (*LALR, error) {
+	l := Resolve(grmr)
+	if len(l.conflicts) > 0 {
+		return nil, ConflictError(l.conflicts)
+	}
+	return l, nil
+}
+
+// Resolve computes the LALR(1) tables for the grammar, resolving any conflicts
+// the way yacc does; shift is preferred over reduce and the production
+// declared first is preferred for reduce/reduce conflicts. The conflicts that
+// were resolved are available from Conflicts. Shift/reduce conflicts that are
+// resolved by the precedence declared in a parlex.PrecedenceGrammar are not
+// conflicts.
+func Resolve(grmr parlex.Grammar) *LALR {
+	l := &LALR{
+		Grammar: grmr,
+	}
+	if len(grmr.NonTerminals()) > 0 {
+		l.tables = buildTables(lr.New(grmr), grmr)
+	}
+	return l
+}
+
+// Constructor fulfills parlex.ParserConstructor
+func Constructor(grmr parlex.Grammar) (parlex.Parser, error) {
+	return New(grmr)
+}
+
+// Conflicts returns the conflicts that were found in the grammar.
+func (l *LALR) Conflicts() []Conflict {
+	if l.tables == nil {
+		return nil
+	}
+	return l.conflicts
+}
+
+// States returns the number of states in the parse tables.
+func (l *LALR) States() int {
+	if l.tables == nil {
+		return 0
+	}
+	return len(l.Automaton.States)
+}
+
+// Parse fulfills parlex.Parser. If the lexemes cannot be parsed, nil is
+// returned.
+func (l *LALR) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := l.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. If the parse fails, a *parlex.ParseError
+// is returned with the lexemes that the parser could have shifted or reduced
+// in the state where it failed.
+func (l *LALR) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return l.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops and returns the context's error if the context is done before the
+// parse finishes.
+func (l *LALR) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	if l.tables == nil {
+		return nil, parlex.ErrBadGrammar
+	}
+	intr := parlex.Interrupt{Ctx: ctx}
+	states := []int{0}
+	var nodes []*tree.PN
+	for pos := 0; ; {
+		if intr.Check() {
+			return nil, intr.Err
+		}
+		la := l.end
+		if pos < len(lexemes) {
+			la = l.Set.Idx(lexemes[pos].Kind())
+		}
+		act, ok := l.actions[states[len(states)-1]][la]
+		if !ok || la < 0 || act.kind == fail {
+			return nil, l.parseError(pos, lexemes, states[len(states)-1])
+		}
+		switch act.kind {
+		case shift:
+			pn := &tree.PN{
+				Lexeme: lexemes[pos],
+			}
+			pn.UpdateSpan()
+			nodes = append(nodes, pn)
+			states = append(states, act.to)
+			pos++
+		case reduce:
+			p := l.Prods[act.to]
+			ln := len(nodes) - len(p.Symbols)
+			pn := l.node(p.NT, nodes[ln:])
+			nodes = append(nodes[:ln], pn)
+			states = states[:len(states)-len(p.Symbols)]
+			states = append(states, l.Automaton.States[states[len(states)-1]].Trans[p.NT])
+		case accept:
+			return nodes[0], nil
+		}
+	}
+}
+
+// ParseFrom fulfills parlex.FromParser. It is the same as ParseErr but the
+// lexemes are parsed as the non-terminal named start. The tables for each
+// start symbol are computed the first time it is used, resolving any conflicts
+// the way Resolve does.
+func (l *LALR) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	if l.Grammar == nil {
+		return nil, fmt.Errorf("Cannot Parse From: %s, the parser has no grammar", start)
+	}
+	l.mux.Lock()
+	sub, ok := l.from[start]
+	if !ok {
+		grmr, err := parlex.StartFrom(l.Grammar, start)
+		if err != nil {
+			l.mux.Unlock()
+			return nil, err
+		}
+		sub = Resolve(grmr)
+		if l.from == nil {
+			l.from = make(map[string]*LALR)
+		}
+		l.from[start] = sub
+	}
+	l.mux.Unlock()
+	return sub.ParseErr(lexemes)
+}
+
+func (l *LALR) parseError(pos int, lexemes []parlex.Lexeme, state int) *parlex.ParseError {
+	var expected []parlex.Symbol
+	for sym, act := range l.actions[state] {
+		if sym != l.end && act.kind != fail {
+			expected = append(expected, l.Set.ByIdx(sym))
+		}
+	}
+	return parlex.NewParseError(pos, lexemes, expected)
+}
+
+func (l *LALR) node(nt int, children []*tree.PN) *tree.PN {
+	lx := lexeme.New(l.Set.ByIdx(nt))
+	pn := &tree.PN{
+		Lexeme: lx,
+		C:      make([]*tree.PN, len(children)),
+	}
+	copy(pn.C, children)
+	for _, c := range pn.C {
+		c.P = pn
+	}
+	if len(pn.C) > 0 {
+		lx.At(pn.C[0].Pos()).AtOffset(pn.C[0].Offset())
+	}
+	pn.UpdateSpan()
+	return pn
+}
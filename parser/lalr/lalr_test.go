@@ -0,0 +1,373 @@
+package lalr
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    * /\*/
+    int /\d+/
+    space /\s+/ -
+  `))
+
+func TestParse(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E + T
+      -> T
+    T -> T * F
+      -> F
+    F -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pn := p.Parse(lxr.Lex("1+2*(3+4)"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      E {
+        E {
+          T {
+            F {
+              int: "1"
+            }
+          }
+        }
+        +: "+"
+        T {
+          T {
+            F {
+              int: "2"
+            }
+          }
+          *: "*"
+          F {
+            (: "("
+            E {
+              E {
+                T {
+                  F {
+                    int: "3"
+                  }
+                }
+              }
+              +: "+"
+              T {
+                F {
+                  int: "4"
+                }
+              }
+            }
+            ): ")"
+          }
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+		assert.Equal(t, tree.Span{Start: 0, End: 9}, pn.(*tree.PN).S)
+	}
+
+	assert.Nil(t, p.Parse(lxr.Lex("1+")))
+	assert.Nil(t, p.Parse(lxr.Lex("1 2")))
+}
+
+func TestEmptyProductions(t *testing.T) {
+	grmr, err := grammar.New(`
+    L    -> L Item
+         ->
+    Item -> Sign int
+    Sign -> +
+         ->
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	pn := p.Parse(lxr.Lex("1 +2"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      L {
+        L {
+          L
+          Item {
+            Sign
+            int: "1"
+          }
+        }
+        Item {
+          Sign {
+            +: "+"
+          }
+          int: "2"
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+}
+
+func TestLALRButNotSLR(t *testing.T) {
+	lxr := parlex.MustLexer(simplelexer.New(`
+    = /=/
+    * /\*/
+    id /\w+/
+    space /\s+/ -
+  `))
+	grmr, err := grammar.New(`
+    S -> L = R
+      -> R
+    L -> * R
+      -> id
+    R -> L
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, p.Parse(lxr.Lex("*a = b")))
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E + E
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	assert.Nil(t, p)
+	if assert.Error(t, err) {
+		ce := err.(ConflictError)
+		if assert.Len(t, ce, 1) {
+			assert.Equal(t, "shift/reduce", ce[0].Kind)
+			assert.Equal(t, "+", ce[0].Lookahead)
+			assert.Equal(t, []string{"E -> E + E", "E -> E + E"}, ce[0].Productions)
+		}
+		assert.True(t, strings.Contains(err.Error(), "shift/reduce conflict"))
+	}
+
+	// resolved by shifting which makes + right associative
+	r := Resolve(grmr)
+	assert.Len(t, r.Conflicts(), 1)
+	pn := r.Parse(lxr.Lex("1+2+3"))
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, "int", pn.Child(0).Child(0).Kind().String())
+		assert.Equal(t, "E", pn.Child(2).Kind().String())
+		assert.Equal(t, 3, pn.Child(2).Children())
+	}
+
+	grmr, err = grammar.New(`
+    S -> A
+      -> B
+    A -> int
+    B -> int
+  `)
+	assert.NoError(t, err)
+	_, err = New(grmr)
+	if assert.Error(t, err) {
+		ce := err.(ConflictError)
+		if assert.Len(t, ce, 1) {
+			assert.Equal(t, "reduce/reduce", ce[0].Kind)
+			assert.Equal(t, "A -> int", ce[0].Resolution)
+		}
+	}
+}
+
+func TestParseErr(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E + T
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	assert.NoError(t, err)
+
+	_, err = p.ParseErr(lxr.Lex("1+(2 3)"))
+	if pe, ok := err.(*parlex.ParseError); assert.True(t, ok) {
+		assert.Equal(t, 4, pe.Pos)
+		assert.Equal(t, "3", pe.Lexeme.Value())
+		assert.Equal(t, "[) +]", fmt.Sprint(pe.Expected))
+	}
+}
+
+// sexpr prints a binary expression tree with parenthesis
+func sexpr(pn parlex.ParseNode) string {
+	switch pn.Children() {
+	case 1:
+		return pn.Child(0).Value()
+	case 3:
+		if pn.Child(0).Kind().String() == "(" {
+			return sexpr(pn.Child(1))
+		}
+		return "(" + sexpr(pn.Child(0)) + " " + pn.Child(1).Value() + " " + sexpr(pn.Child(2)) + ")"
+	}
+	return pn.Value()
+}
+
+func TestPrecedence(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    - /-/
+    * /\*/
+    ^ /\^/
+    == /==/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    %nonassoc ==
+    %left + -
+    %left *
+    %right ^
+    E -> E + E
+      -> E - E
+      -> E * E
+      -> E ^ E
+      -> E == E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	prsr, err := New(grmr)
+	assert.NoError(t, err)
+
+	tests := map[string]string{
+		"1 + 2 * 3":         "(1 + (2 * 3))",
+		"1 * 2 + 3":         "((1 * 2) + 3)",
+		"1 - 2 - 3":         "((1 - 2) - 3)",
+		"1 - 2 + 3 - 4":     "(((1 - 2) + 3) - 4)",
+		"2 ^ 3 ^ 4":         "(2 ^ (3 ^ 4))",
+		"1 + 2 ^ 3 * 4":     "(1 + ((2 ^ 3) * 4))",
+		"(1 + 2) * 3":       "((1 + 2) * 3)",
+		"1 + 2 == 3 * 1":    "((1 + 2) == (3 * 1))",
+		"1 * 2 - 3 ^ 2 * 4": "((1 * 2) - ((3 ^ 2) * 4))",
+	}
+	for in, expected := range tests {
+		pn := prsr.Parse(lxr.Lex(in))
+		if assert.NotNil(t, pn, in) {
+			assert.Equal(t, expected, sexpr(pn), in)
+		}
+	}
+
+	assert.Nil(t, prsr.Parse(lxr.Lex("1 == 2 == 3")))
+}
+
+func TestParseContext(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E + int
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pn, err := p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	cancel()
+	pn, err = p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.Nil(t, pn)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParseFrom(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E + T
+      -> T
+    T -> T * F
+      -> F
+    F -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pn, err := parlex.ParseFrom(p, "T", lxr.Lex("2*3"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "T", pn.Kind().String())
+		assert.Equal(t, 3, pn.Children())
+	}
+
+	_, err = p.ParseFrom("T", lxr.Lex("2+3"))
+	assert.Error(t, err)
+
+	pn, err = p.ParseFrom("F", lxr.Lex("(1+2)"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "F", pn.Kind().String())
+	}
+
+	_, err = p.ParseFrom("int", lxr.Lex("1"))
+	assert.EqualError(t, err, "Unknown Non-Terminal: int")
+}
+
+func TestExplain(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E + E
+      -> int
+  `)
+	assert.NoError(t, err)
+	es := Resolve(grmr).Explain()
+	if !assert.Len(t, es, 1) {
+		return
+	}
+	e := es[0]
+	assert.Equal(t, "shift/reduce", e.Kind)
+	if assert.Len(t, e.Derivations, 2) {
+		var reduce, shift Derivation
+		for _, d := range e.Derivations {
+			if d.Action == "Reduce" {
+				reduce = d
+			} else {
+				shift = d
+			}
+		}
+		assert.Equal(t, "E -> E + E •", reduce.Item)
+		assert.Equal(t, "E -> E • + E", shift.Item)
+		// the same input with two trees is ambiguous
+		assert.Equal(t, "E + E • + E", reduce.Example)
+		assert.Equal(t, reduce.Example, shift.Example)
+		assert.Equal(t, "E\n↳ E + E\n  ↳ E + E •", reduce.Tree)
+		assert.Equal(t, "E\n↳ E + E\n      ↳ E • + E", shift.Tree)
+	}
+	assert.True(t, strings.Contains(e.String(), "Example: E + E • + E"))
+
+	grmr, err = grammar.New(`
+    S -> A
+      -> B
+    A -> int
+    B -> int
+  `)
+	assert.NoError(t, err)
+	es = Resolve(grmr).Explain()
+	if assert.Len(t, es, 1) && assert.Len(t, es[0].Derivations, 2) {
+		assert.Equal(t, "A -> int •", es[0].Derivations[0].Item)
+		assert.Equal(t, "B -> int •", es[0].Derivations[1].Item)
+		assert.Equal(t, "int •", es[0].Derivations[0].Example)
+		assert.Equal(t, "S\n↳ A\n  ↳ int •", es[0].Derivations[0].Tree)
+	}
+
+	assert.Len(t, Resolve(grammar.Empty()).Explain(), 0)
+}
@@ -0,0 +1,319 @@
+package lalr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/parser/lr"
+	"sort"
+	"strings"
+)
+
+type actionKind byte
+
+const (
+	shift actionKind = iota + 1
+	reduce
+	accept
+	// fail is used when a non-associative operator is chained
+	fail
+)
+
+type action struct {
+	kind actionKind
+	to   int // state for shift, production for reduce
+}
+
+// Conflict describes a state in which the parser could take more than one
+// action on the same lookahead. Productions holds the competing productions in
+// the form "E -> E op E". For a shift/reduce conflict, the first production is
+// the one that would be reduced.
+type Conflict struct {
+	State       int
+	Lookahead   string
+	Kind        string
+	Productions []string
+	// Resolution is the production used to resolve the conflict or "shift"
+	Resolution string
+}
+
+// String fulfills Stringer
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s conflict in state %d on %s between (%s), resolved as %s", c.Kind, c.State, c.Lookahead, strings.Join(c.Productions, "), ("), c.Resolution)
+}
+
+// ConflictError is returned by New when the grammar is not LALR(1).
+type ConflictError []Conflict
+
+func (ce ConflictError) Error() string {
+	strs := make([]string, len(ce))
+	for i, c := range ce {
+		strs[i] = c.String()
+	}
+	return "LALR(1) conflicts:\n" + strings.Join(strs, "\n")
+}
+
+// tables are the LALR(1) parse tables.
+type tables struct {
+	*lr.Automaton
+	grmr      parlex.Grammar
+	end       int // symbol index of end of input
+	nullable  []bool
+	first     [][]bool
+	actions   []map[int]action
+	conflicts []Conflict
+}
+
+const propagate = -1
+
+type laItem struct {
+	lr.Item
+	la int
+}
+
+func buildTables(a *lr.Automaton, grmr parlex.Grammar) *tables {
+	t := &tables{
+		Automaton: a,
+		grmr:      grmr,
+		end:       a.Set.Size(),
+	}
+	t.findFirsts()
+	lookaheads := t.lookaheads()
+
+	t.actions = make([]map[int]action, len(a.States))
+	for sIdx, s := range a.States {
+		t.actions[sIdx] = make(map[int]action)
+		for sym, to := range s.Trans {
+			if !a.IsNonTerminal(sym) {
+				t.actions[sIdx][sym] = action{kind: shift, to: to}
+			}
+		}
+		var reductions []laItem
+		for _, it := range t.closure(a.Kernel(s), lookaheads[sIdx]) {
+			if a.Next(it.Item) == -1 {
+				reductions = append(reductions, it)
+			}
+		}
+		sort.Slice(reductions, func(i, j int) bool {
+			if reductions[i].Prod != reductions[j].Prod {
+				return reductions[i].Prod < reductions[j].Prod
+			}
+			return reductions[i].la < reductions[j].la
+		})
+		for _, it := range reductions {
+			if it.Prod == 0 {
+				t.actions[sIdx][t.end] = action{kind: accept}
+				continue
+			}
+			t.addReduce(sIdx, it.la, it.Prod)
+		}
+	}
+	return t
+}
+
+func (t *tables) symbolString(idx int) string {
+	if idx == t.end {
+		return "$end"
+	}
+	return t.Set.ByIdx(idx).String()
+}
+
+func (t *tables) addReduce(sIdx, la, pIdx int) {
+	old, ok := t.actions[sIdx][la]
+	if !ok {
+		t.actions[sIdx][la] = action{kind: reduce, to: pIdx}
+		return
+	}
+	if old.kind == fail {
+		return
+	}
+	if old.kind == shift && t.resolvePrecedence(sIdx, la, pIdx) {
+		return
+	}
+	c := Conflict{
+		State:     sIdx,
+		Lookahead: t.symbolString(la),
+	}
+	if old.kind == shift {
+		// like yacc, prefer shift
+		c.Kind = "shift/reduce"
+		c.Resolution = "shift"
+		c.Productions = append(c.Productions, t.ProdString(pIdx))
+		for _, it := range t.States[sIdx].Items {
+			if t.Next(it) == la {
+				c.Productions = append(c.Productions, t.ProdString(it.Prod))
+			}
+		}
+	} else {
+		// reductions are added in order, so the existing reduction was declared
+		// first and is kept
+		c.Kind = "reduce/reduce"
+		c.Resolution = t.ProdString(old.to)
+		c.Productions = []string{t.ProdString(old.to), t.ProdString(pIdx)}
+	}
+	t.conflicts = append(t.conflicts, c)
+}
+
+// resolvePrecedence resolves a shift/reduce conflict like yacc if both the
+// production and the lookahead have a precedence. The higher precedence wins,
+// if they are the same then left associativity reduces, right associativity
+// shifts and non-associative operators are an error. It returns false if the
+// conflict could not be resolved.
+func (t *tables) resolvePrecedence(sIdx, la, pIdx int) bool {
+	pg, ok := t.grmr.(parlex.PrecedenceGrammar)
+	if !ok || la == t.end {
+		return false
+	}
+	laLevel, assoc := pg.Precedence(t.Set.ByIdx(la))
+	if laLevel == 0 {
+		return false
+	}
+	p := t.Prods[pIdx]
+	prodLevel, _ := parlex.ProductionPrecedence(t.grmr, t.grmr.Productions(t.Set.ByIdx(p.NT)).Production(p.Idx))
+	if prodLevel == 0 {
+		return false
+	}
+	switch {
+	case prodLevel > laLevel || (prodLevel == laLevel && assoc == parlex.Left):
+		t.actions[sIdx][la] = action{kind: reduce, to: pIdx}
+	case prodLevel == laLevel && assoc == parlex.NonAssoc:
+		t.actions[sIdx][la] = action{kind: fail}
+	}
+	return true
+}
+
+// findFirsts computes nullable and the FIRST set of every non-terminal.
+func (t *tables) findFirsts() {
+	ln := t.end + 1
+	t.nullable = make([]bool, ln)
+	t.first = make([][]bool, ln)
+	for i := range t.first {
+		t.first[i] = make([]bool, ln)
+		if !t.IsNonTerminal(i) {
+			t.first[i][i] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, p := range t.Prods[1:] {
+			allNullable := true
+			for _, s := range p.Symbols {
+				for f, in := range t.first[s] {
+					if in && !t.first[p.NT][f] {
+						t.first[p.NT][f] = true
+						changed = true
+					}
+				}
+				if !t.nullable[s] {
+					allNullable = false
+					break
+				}
+			}
+			if allNullable && !t.nullable[p.NT] {
+				t.nullable[p.NT] = true
+				changed = true
+			}
+		}
+	}
+}
+
+// firstOf adds FIRST(syms la) to out. If la is propagate, it is added when syms
+// are all nullable.
+func (t *tables) firstOf(syms []int, la int, out map[int]bool) {
+	for _, s := range syms {
+		for f, in := range t.first[s] {
+			if in {
+				out[f] = true
+			}
+		}
+		if !t.nullable[s] {
+			return
+		}
+	}
+	out[la] = true
+}
+
+// closure computes the LR(1) closure of the kernel items with their
+// lookaheads.
+func (t *tables) closure(kernel []lr.Item, las map[lr.Item]map[int]bool) []laItem {
+	var items []laItem
+	seen := make(map[laItem]bool)
+	for _, k := range kernel {
+		for la := range las[k] {
+			it := laItem{k, la}
+			seen[it] = true
+			items = append(items, it)
+		}
+	}
+	for i := 0; i < len(items); i++ {
+		it := items[i]
+		next := t.Next(it.Item)
+		if !t.IsNonTerminal(next) {
+			continue
+		}
+		firsts := make(map[int]bool)
+		t.firstOf(t.Prods[it.Prod].Symbols[it.Dot+1:], it.la, firsts)
+		for _, pIdx := range t.ByNT[next] {
+			for la := range firsts {
+				n := laItem{lr.Item{Prod: pIdx}, la}
+				if !seen[n] {
+					seen[n] = true
+					items = append(items, n)
+				}
+			}
+		}
+	}
+	return items
+}
+
+type propKey struct {
+	state int
+	item  lr.Item
+}
+
+// lookaheads computes the LALR(1) lookaheads for the kernel items of every
+// state by spontaneous generation and propagation.
+func (t *tables) lookaheads() []map[lr.Item]map[int]bool {
+	las := make([]map[lr.Item]map[int]bool, len(t.States))
+	for i, s := range t.States {
+		las[i] = make(map[lr.Item]map[int]bool)
+		for _, k := range t.Kernel(s) {
+			las[i][k] = make(map[int]bool)
+		}
+	}
+	las[0][lr.Item{}][t.end] = true
+
+	props := make(map[propKey][]propKey)
+	for sIdx, s := range t.States {
+		for _, k := range t.Kernel(s) {
+			from := propKey{sIdx, k}
+			dummy := map[lr.Item]map[int]bool{k: {propagate: true}}
+			for _, it := range t.closure([]lr.Item{k}, dummy) {
+				next := t.Next(it.Item)
+				if next == -1 {
+					continue
+				}
+				to := propKey{s.Trans[next], lr.Item{Prod: it.Prod, Dot: it.Dot + 1}}
+				if it.la == propagate {
+					props[from] = append(props[from], to)
+				} else {
+					las[to.state][to.item][it.la] = true
+				}
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for from, tos := range props {
+			for la := range las[from.state][from.item] {
+				for _, to := range tos {
+					if !las[to.state][to.item][la] {
+						las[to.state][to.item][la] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return las
+}
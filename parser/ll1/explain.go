@@ -0,0 +1,171 @@
+package ll1
+
+import (
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"strings"
+)
+
+// maxForms limits how many sentential forms Explain will try for a conflict.
+const maxForms = 10000
+
+// Explanation shows where a conflict is reached. Example is a sentential form
+// from the start symbol with the non-terminal of the conflict next, marked by •,
+// and the lookahead able to start what follows. There is a Derivation for each
+// of the competing productions.
+//   if c if c x • Else Else
+type Explanation struct {
+	Conflict
+	Example     string
+	Derivations []Derivation
+}
+
+// Derivation is the example with the non-terminal replaced by one of the
+// competing productions.
+type Derivation struct {
+	Production string
+	Example    string
+}
+
+// String fulfills Stringer
+func (e Explanation) String() string {
+	var buf strings.Builder
+	buf.WriteString(e.Conflict.String())
+	buf.WriteString("\n  Example: ")
+	buf.WriteString(e.Example)
+	for _, d := range e.Derivations {
+		buf.WriteString("\n  ")
+		buf.WriteString(d.Production)
+		buf.WriteString("\n    ")
+		buf.WriteString(d.Example)
+	}
+	return buf.String()
+}
+
+// Explain finds an example of each conflict. The example is the shortest
+// leftmost derivation from the start symbol that reaches the non-terminal of
+// the conflict where the lookahead can come next. If no example is found, the
+// Example is empty.
+func (l *LL1) Explain() []Explanation {
+	if len(l.conflicts) == 0 {
+		return nil
+	}
+	ex := &explainer{
+		LL1: l,
+		a:   analysis.New(l.Grammar),
+	}
+	out := make([]Explanation, len(l.conflicts))
+	for i, c := range l.conflicts {
+		out[i] = ex.explain(c)
+	}
+	return out
+}
+
+type explainer struct {
+	*LL1
+	a *analysis.Analysis
+}
+
+func (ex *explainer) explain(c Conflict) Explanation {
+	e := Explanation{Conflict: c}
+	nt := ex.set.Str(c.NonTerminal).Idx()
+	la := ex.set.Str(c.Lookahead).Idx()
+	form, at := ex.search(nt, la)
+	if form == nil {
+		return e
+	}
+	e.Example = ex.formString(form, at, nil)
+	for _, p := range c.Productions {
+		for _, pIdx := range ex.ntProds(nt) {
+			if ex.prodString(nt, pIdx) == p {
+				e.Derivations = append(e.Derivations, Derivation{
+					Production: p,
+					Example:    ex.formString(form, at, ex.prods[pIdx]),
+				})
+				break
+			}
+		}
+	}
+	return e
+}
+
+// search expands the leftmost non-terminal of each form until the leftmost is
+// nt and the rest of the form can start with la. It returns the form and the
+// index of nt in it.
+func (ex *explainer) search(nt, la int) ([]int, int) {
+	queue := [][]int{{ex.start}}
+	seen := map[string]bool{}
+	for tried := 0; len(queue) > 0 && tried < maxForms; tried++ {
+		form := queue[0]
+		queue = queue[1:]
+		at := 0
+		for at < len(form) && !ex.isNT[form[at]] {
+			at++
+		}
+		if at == len(form) {
+			continue
+		}
+		if form[at] == nt && ex.canStart(form[at+1:], la) {
+			return form, at
+		}
+		for _, pIdx := range ex.ntProds(form[at]) {
+			next := make([]int, 0, len(form)+len(ex.prods[pIdx]))
+			next = append(next, form[:at]...)
+			next = append(next, ex.prods[pIdx]...)
+			next = append(next, form[at+1:]...)
+			key := ex.formString(next, -1, nil)
+			if !seen[key] {
+				seen[key] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil, -1
+}
+
+// ntProds returns the productions of a non-terminal in the order they are
+// declared.
+func (ex *explainer) ntProds(nt int) []int {
+	var out []int
+	for pIdx, lhs := range ex.lhs {
+		if lhs == nt {
+			out = append(out, pIdx)
+		}
+	}
+	return out
+}
+
+// canStart returns true if la can be the first terminal of the symbols, or the
+// end of the input if they are nullable.
+func (ex *explainer) canStart(symbols []int, la int) bool {
+	for _, s := range symbols {
+		sym := ex.set.ByIdx(s)
+		for _, f := range ex.a.First(sym) {
+			if f.String() == ex.set.ByIdx(la).String() {
+				return true
+			}
+		}
+		if !ex.a.Nullable(sym) {
+			return false
+		}
+	}
+	return la == ex.end
+}
+
+// formString writes the symbols of a form with • before the symbol at index at.
+// If prod is not nil, it replaces that symbol.
+func (ex *explainer) formString(form []int, at int, prod []int) string {
+	var strs []string
+	for i, s := range form {
+		if i == at {
+			strs = append(strs, "•")
+			if prod != nil {
+				for _, p := range prod {
+					strs = append(strs, ex.set.ByIdx(p).String())
+				}
+				continue
+			}
+		}
+		strs = append(strs, ex.set.ByIdx(s).String())
+	}
+	return strings.Join(strs, " ")
+}
@@ -0,0 +1,262 @@
+// Package ll1 implements a predictive LL(1) parser. Each non-terminal chooses
+// its production from a table indexed by the next lexeme, so parsing never
+// backtracks, runs in linear time and keeps no memo table. Many grammars that
+// are written for a top down parser are already LL(1).
+package ll1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/adamcolton/parlex/tree"
+	"strings"
+)
+
+// ErrLeftRecursion is returned for a left recursive grammar, which can never be
+// LL(1).
+var ErrLeftRecursion = errors.New("LL(1) parser cannot handle left recursion")
+
+// LL1 is an LL(1) parser
+type LL1 struct {
+	parlex.Grammar
+	set   *setsymbol.Set
+	start int
+	end   int
+	isNT  []bool
+	// prods holds the symbols of every production, lhs the non-terminal of
+	// each and table the index in prods to use for each non-terminal and
+	// lookahead, or -1; named is the same table by the names of the symbols
+	prods     [][]int
+	lhs       []int
+	table     [][]int
+	named     Table
+	conflicts []Conflict
+	// nullable is the index in prods of the first nullable production of each
+	// non-terminal, or -1
+	nullable []int
+}
+
+// New builds the LL(1) table for the grammar. If the grammar has conflicts, a
+// ConflictError is returned describing all of them.
+func New(grmr parlex.Grammar) (*LL1, error) {
+	l, err := Resolve(grmr)
+	if err != nil {
+		return nil, err
+	}
+	if len(l.conflicts) > 0 {
+		return nil, ConflictError(l.conflicts)
+	}
+	return l, nil
+}
+
+// Resolve builds the LL(1) table for the grammar, resolving any conflicts by
+// using the production declared first. That is how the dangling else is
+// usually resolved; the else goes with the closest if. The conflicts that were
+// resolved are available from Conflicts. A left recursive grammar returns
+// ErrLeftRecursion, because no choice of production would make progress.
+func Resolve(grmr parlex.Grammar) (*LL1, error) {
+	start := parlex.StartSymbol(grmr)
+	if start == nil {
+		return nil, parlex.ErrBadGrammar
+	}
+	if parlex.IsLeftRecursive(grmr) {
+		return nil, ErrLeftRecursion
+	}
+	l := &LL1{
+		Grammar: grmr,
+		set:     setsymbol.New(),
+	}
+	a := analysis.New(grmr)
+	l.named, l.conflicts = buildTable(a)
+	l.set.LoadGrammar(grmr)
+	l.start = l.set.Symbol(start).Idx()
+	l.end = l.set.Str(analysis.EOF).Idx()
+	ln := l.set.Size()
+	l.isNT = make([]bool, ln)
+	l.table = make([][]int, ln)
+	l.nullable = make([]int, ln)
+	for i := range l.nullable {
+		l.nullable[i] = -1
+	}
+	for _, nt := range grmr.NonTerminals() {
+		ntIdx := l.set.Symbol(nt).Idx()
+		l.isNT[ntIdx] = true
+		row := make([]int, ln)
+		for i := range row {
+			row[i] = -1
+		}
+		l.table[ntIdx] = row
+		// the productions of nt start at base in prods
+		base := len(l.prods)
+		for i := grmr.Productions(nt).Iter(); i.Next(); {
+			if _, nullable := a.FirstOf(i.Production); nullable && l.nullable[ntIdx] < 0 {
+				l.nullable[ntIdx] = len(l.prods)
+			}
+			symbols := make([]int, 0, i.Symbols())
+			for j := i.Iter(); j.Next(); {
+				symbols = append(symbols, l.set.Symbol(j.Symbol).Idx())
+			}
+			l.prods = append(l.prods, symbols)
+			l.lhs = append(l.lhs, ntIdx)
+		}
+		for la, pIdx := range l.named[nt.String()] {
+			row[l.set.Str(la).Idx()] = base + pIdx
+		}
+	}
+	return l, nil
+}
+
+// Constructor fulfills parlex.ParserConstructor
+func Constructor(grmr parlex.Grammar) (parlex.Parser, error) {
+	return New(grmr)
+}
+
+func (l *LL1) prodString(nt, pIdx int) string {
+	strs := []string{l.set.ByIdx(nt).String(), "->"}
+	for _, s := range l.prods[pIdx] {
+		strs = append(strs, l.set.ByIdx(s).String())
+	}
+	return strings.Join(strs, " ")
+}
+
+// Table returns the LL(1) table of the grammar. When there is a conflict, it
+// holds the production declared first. It should not be modified.
+func (l *LL1) Table() Table {
+	return l.named
+}
+
+// Conflicts returns the conflicts that were found in the grammar.
+func (l *LL1) Conflicts() []Conflict {
+	return l.conflicts
+}
+
+// Parse fulfills parlex.Parser. If the lexemes cannot be parsed, nil is
+// returned.
+func (l *LL1) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := l.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. If the parse fails, a *parlex.ParseError
+// is returned with the lexemes that the non-terminal being parsed could have
+// started with.
+func (l *LL1) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return l.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops and returns the context's error if the context is done before the
+// parse finishes.
+func (l *LL1) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return l.parseFrom(ctx, l.start, lexemes)
+}
+
+// ParseFrom fulfills parlex.FromParser. It is the same as ParseErr but the
+// lexemes are parsed as the non-terminal named start. The same table is used;
+// at the end of the input a non-terminal that has no entry for it takes its
+// first nullable production, as it would if start were the start symbol.
+func (l *LL1) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	if !l.set.Has(start) || !l.isNT[l.set.Str(start).Idx()] {
+		return nil, fmt.Errorf("Unknown Non-Terminal: %s", start)
+	}
+	return l.parseFrom(context.Background(), l.set.Str(start).Idx(), lexemes)
+}
+
+func (l *LL1) parseFrom(ctx context.Context, start int, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	op := &parseOp{
+		LL1:     l,
+		lexemes: lexemes,
+		intr:    parlex.Interrupt{Ctx: ctx},
+	}
+	pn := op.parse(start)
+	if pn != nil && op.pos < len(lexemes) {
+		op.err = parlex.NewParseError(op.pos, lexemes, nil)
+	}
+	if op.err != nil {
+		return nil, op.err
+	}
+	return pn, nil
+}
+
+type parseOp struct {
+	*LL1
+	lexemes []parlex.Lexeme
+	pos     int
+	intr    parlex.Interrupt
+	err     error
+}
+
+// lookahead returns the symbol index of the next lexeme, the end of input or -1
+// if the kind is not in the grammar.
+func (op *parseOp) lookahead() int {
+	if op.pos >= len(op.lexemes) {
+		return op.end
+	}
+	return op.set.Idx(op.lexemes[op.pos].Kind())
+}
+
+func (op *parseOp) parse(sym int) *tree.PN {
+	if op.intr.Check() {
+		op.err = op.intr.Err
+		return nil
+	}
+	la := op.lookahead()
+	if !op.isNT[sym] {
+		if la != sym {
+			op.err = parlex.NewParseError(op.pos, op.lexemes, []parlex.Symbol{op.set.ByIdx(sym)})
+			return nil
+		}
+		pn := &tree.PN{
+			Lexeme: op.lexemes[op.pos],
+		}
+		pn.UpdateSpan()
+		op.pos++
+		return pn
+	}
+
+	pIdx := -1
+	if la >= 0 {
+		pIdx = op.table[sym][la]
+	}
+	if pIdx < 0 && la == op.end {
+		// the end of the input is only in the FOLLOW sets of the start symbol
+		pIdx = op.nullable[sym]
+	}
+	if pIdx < 0 {
+		op.err = op.parseError(sym)
+		return nil
+	}
+	lx := lexeme.New(op.set.ByIdx(sym))
+	pn := &tree.PN{
+		Lexeme: lx,
+		C:      make([]*tree.PN, 0, len(op.prods[pIdx])),
+	}
+	for _, s := range op.prods[pIdx] {
+		c := op.parse(s)
+		if c == nil {
+			return nil
+		}
+		c.P = pn
+		pn.C = append(pn.C, c)
+	}
+	if len(pn.C) > 0 {
+		lx.At(pn.C[0].Pos()).AtOffset(pn.C[0].Offset())
+	}
+	pn.UpdateSpan()
+	return pn
+}
+
+// parseError lists the terminals the non-terminal could start with.
+func (op *parseOp) parseError(nt int) *parlex.ParseError {
+	var expected []parlex.Symbol
+	for la, pIdx := range op.table[nt] {
+		if pIdx >= 0 && la != op.end {
+			expected = append(expected, op.set.ByIdx(la))
+		}
+	}
+	return parlex.NewParseError(op.pos, op.lexemes, expected)
+}
@@ -0,0 +1,257 @@
+package ll1
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    * /\*/
+    ; /;/
+    int /\d+/
+    space /\s+/ -
+  `))
+
+var exprGrammar = parlex.MustGrammar(grammar.New(`
+    E  -> T E'
+    E' -> + T E'
+       ->
+    T  -> F T'
+    T' -> * F T'
+       ->
+    F  -> ( E )
+       -> int
+  `))
+
+func TestParse(t *testing.T) {
+	p, err := New(exprGrammar)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, p.Conflicts())
+	assert.Equal(t, map[string]int{"+": 0, ")": 1, analysis.EOF: 1}, p.Table()["E'"])
+
+	pn := p.Parse(lxr.Lex("1+2"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      E {
+        T {
+          F {
+            int: "1"
+          }
+          T'
+        }
+        E' {
+          +: "+"
+          T {
+            F {
+              int: "2"
+            }
+            T'
+          }
+          E'
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+
+	// the same tree as packrat
+	pk := packrat.New(exprGrammar)
+	for _, src := range []string{"1", "1+2*3", "(1+2)*(3+4*5)", "((1))"} {
+		lxs := lxr.Lex(src)
+		pn := p.Parse(lxs)
+		if assert.NotNil(t, pn, src) {
+			assert.Equal(t, pk.Parse(lxs).(*tree.PN).String(), pn.(*tree.PN).String(), src)
+		}
+	}
+
+	pn = p.Parse(lxr.Lex("12 * 3"))
+	if assert.NotNil(t, pn) {
+		l, c := pn.Pos()
+		assert.Equal(t, 1, l)
+		assert.Equal(t, 1, c)
+		assert.Equal(t, tree.Span{Start: 0, End: 6}, pn.(*tree.PN).S)
+	}
+}
+
+func TestParseErr(t *testing.T) {
+	p, err := New(exprGrammar)
+	if !assert.NoError(t, err) {
+		return
+	}
+	tt := map[string]string{
+		"1+":    "Could Not Parse) found end of input, expected (, int",
+		"1+*2":  "Could Not Parse 1:3) found *: *, expected (, int",
+		"(1+2":  "Could Not Parse) found end of input, expected )",
+		"1 2":   "Could Not Parse 1:3) found int: 2, expected ), *, +",
+		"(1))":  "Could Not Parse 1:4) found ): ), expected end of input",
+		"":      "Could Not Parse) found end of input, expected (, int",
+		"1 + x": "Could Not Parse 1:5) found Error: x, expected (, int",
+	}
+	for src, msg := range tt {
+		_, err := p.ParseErr(lxr.Lex(src))
+		if assert.Error(t, err, src) {
+			assert.Equal(t, msg, err.Error(), src)
+		}
+	}
+	assert.True(t, parlex.IsIncomplete(func() error { _, err := p.ParseErr(lxr.Lex("1+")); return err }()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParseFrom(t *testing.T) {
+	grmr := parlex.MustGrammar(grammar.New(`
+    S  -> E ;
+    E  -> T E'
+    E' -> + T E'
+       ->
+    T  -> int
+       -> ( E )
+  `))
+	p, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var fp parlex.FromParser = p
+
+	// the end of the input is not in the FOLLOW set of E', so it takes its
+	// empty production
+	pk := packrat.New(grmr)
+	for _, src := range []string{"1+2", "(1+2)+3", "1"} {
+		lxs := lxr.Lex(src)
+		pn, err := parlex.ParseFrom(fp, "E", lxs)
+		if assert.NoError(t, err, src) {
+			expected, _ := pk.ParseFrom("E", lxs)
+			assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String(), src)
+		}
+	}
+	pn, err := p.ParseFrom("S", lxr.Lex("1+2;"))
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+
+	_, err = p.ParseFrom("T", lxr.Lex("1+2"))
+	if assert.Error(t, err) {
+		assert.Equal(t, "Could Not Parse 1:2) found +: +, expected end of input", err.Error())
+	}
+	_, err = p.ParseFrom("E", lxr.Lex("1+"))
+	assert.True(t, parlex.IsIncomplete(err))
+
+	_, err = p.ParseFrom("int", lxr.Lex("1"))
+	assert.Equal(t, "Unknown Non-Terminal: int", err.Error())
+	_, err = p.ParseFrom("X", lxr.Lex("1"))
+	assert.Equal(t, "Unknown Non-Terminal: X", err.Error())
+}
+
+func TestConflicts(t *testing.T) {
+	_, err := New(parlex.MustGrammar(grammar.New(`
+    E -> E + int
+      -> int
+  `)))
+	assert.Equal(t, ErrLeftRecursion, err)
+
+	_, err = New(parlex.MustGrammar(grammar.New(``)))
+	assert.Equal(t, parlex.ErrBadGrammar, err)
+
+	// the dangling else
+	ifGrammar := parlex.MustGrammar(grammar.New(`
+    S -> if c S Else
+      -> x
+    Else -> else S
+         ->
+  `))
+	_, err = New(ifGrammar)
+	if ce, ok := err.(ConflictError); assert.True(t, ok) && assert.Len(t, ce, 1) {
+		assert.Equal(t, "Else", ce[0].NonTerminal)
+		assert.Equal(t, "else", ce[0].Lookahead)
+		assert.Equal(t, "LL(1) conflicts:\nElse on else between (Else -> else S), (Else ->), resolved as (Else -> else S)", err.Error())
+	}
+
+	p, err := Resolve(ifGrammar)
+	if assert.NoError(t, err) {
+		assert.Len(t, p.Conflicts(), 1)
+		lxs := parlex.MustLexer(simplelexer.New(`
+      keywords if else c
+      x /\w+/
+      space /\s+/ -
+    `)).Lex("if c if c x else x")
+		pn := p.Parse(lxs)
+		if assert.NotNil(t, pn) {
+			// the else goes with the inner if
+			inner := pn.Child(2)
+			assert.Equal(t, "S", inner.Kind().String())
+			assert.Equal(t, 0, pn.Child(3).Children())
+			assert.Equal(t, 2, inner.Child(3).Children())
+		}
+	}
+}
+
+func benchLexemes() []parlex.Lexeme {
+	src := strings.Repeat("(1+2*3)*4+", 200) + "5"
+	return lxr.Lex(src)
+}
+
+func BenchmarkParseLL1(b *testing.B) {
+	p, _ := New(exprGrammar)
+	lxs := benchLexemes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Parse(lxs)
+	}
+}
+
+func BenchmarkParsePackrat(b *testing.B) {
+	p := packrat.New(exprGrammar)
+	lxs := benchLexemes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Parse(lxs)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	p, err := Resolve(parlex.MustGrammar(grammar.New(`
+    S -> if c S Else
+      -> x
+    Else -> else S
+         ->
+  `)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	es := p.Explain()
+	if assert.Len(t, es, 1) {
+		e := es[0]
+		assert.Equal(t, "if c if c x • Else Else", e.Example)
+		if assert.Len(t, e.Derivations, 2) {
+			assert.Equal(t, "Else -> else S", e.Derivations[0].Production)
+			assert.Equal(t, "if c if c x • else S Else", e.Derivations[0].Example)
+			assert.Equal(t, "Else ->", e.Derivations[1].Production)
+			assert.Equal(t, "if c if c x • Else", e.Derivations[1].Example)
+		}
+		assert.True(t, strings.Contains(e.String(), "Example: if c if c x • Else Else"))
+	}
+
+	p, err = Resolve(parlex.MustGrammar(grammar.New(`
+    E -> T E'
+    E' -> + T E'
+       ->
+    T -> int
+  `)))
+	assert.NoError(t, err)
+	assert.Nil(t, p.Explain())
+}
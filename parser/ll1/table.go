@@ -0,0 +1,102 @@
+package ll1
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"sort"
+	"strings"
+)
+
+// Table is an LL(1) parse table. For each non-terminal and lookahead it holds
+// the index of the production of the non-terminal to use. At the end of the
+// input the lookahead is analysis.EOF. A lookahead that is not in the row of a
+// non-terminal is a parse error.
+type Table map[string]map[string]int
+
+// Conflict describes a non-terminal that could use more than one production
+// for the same lookahead. Productions are in the form "E -> E op E", in the
+// order they are declared; the first one is used by Resolve.
+type Conflict struct {
+	NonTerminal string
+	Lookahead   string
+	Productions []string
+}
+
+// String fulfills Stringer
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s on %s between (%s), resolved as (%s)", c.NonTerminal, c.Lookahead, strings.Join(c.Productions, "), ("), c.Productions[0])
+}
+
+// ConflictError is returned by New when the grammar is not LL(1).
+type ConflictError []Conflict
+
+func (ce ConflictError) Error() string {
+	strs := make([]string, len(ce))
+	for i, c := range ce {
+		strs[i] = c.String()
+	}
+	return "LL(1) conflicts:\n" + strings.Join(strs, "\n")
+}
+
+// buildTable builds the table from the FIRST and FOLLOW sets of the grammar,
+// keeping the production declared first when there is a conflict. The
+// conflicts of each non-terminal are sorted by lookahead.
+func buildTable(a *analysis.Analysis) (Table, []Conflict) {
+	t := make(Table)
+	var conflicts []Conflict
+	for _, nt := range a.NonTerminals() {
+		row := make(map[string]int)
+		t[nt.String()] = row
+		prods := a.Productions(nt)
+		rowConflicts := make(map[string]*Conflict)
+		var order []string
+		add := func(la string, pIdx int) {
+			old, ok := row[la]
+			if !ok {
+				row[la] = pIdx
+				return
+			}
+			if old == pIdx {
+				return
+			}
+			if c, ok := rowConflicts[la]; ok {
+				c.Productions = append(c.Productions, productionString(nt, prods.Production(pIdx)))
+				return
+			}
+			rowConflicts[la] = &Conflict{
+				NonTerminal: nt.String(),
+				Lookahead:   la,
+				Productions: []string{
+					productionString(nt, prods.Production(old)),
+					productionString(nt, prods.Production(pIdx)),
+				},
+			}
+			order = append(order, la)
+		}
+		for i := prods.Iter(); i.Next(); {
+			first, nullable := a.FirstOf(i.Production)
+			for _, la := range first {
+				add(la.String(), i.Idx)
+			}
+			if nullable {
+				for _, la := range a.Follow(nt) {
+					add(la.String(), i.Idx)
+				}
+			}
+		}
+		sort.Strings(order)
+		for _, la := range order {
+			conflicts = append(conflicts, *rowConflicts[la])
+		}
+	}
+	return t, conflicts
+}
+
+func productionString(nt parlex.Symbol, prod parlex.Production) string {
+	strs := []string{nt.String(), "->"}
+	for i := prod.Iter(); i.Next(); {
+		strs = append(strs, i.Symbol.String())
+	}
+	return strings.Join(strs, " ")
+}
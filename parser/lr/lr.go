@@ -0,0 +1,200 @@
+// Package lr builds the LR(0) automaton for a grammar. It is shared by the
+// parsers that are built on LR automata.
+package lr
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"sort"
+	"strings"
+)
+
+// Prod is a production along with it's non-terminal. Prods[0] is always the
+// augmented start production which has NT == -1.
+type Prod struct {
+	NT      int
+	Idx     int // index of the production within the non-terminal
+	Symbols []int
+}
+
+// Item is an LR(0) item; the production at Prods[Prod] with the dot before the
+// symbol at Dot.
+type Item struct {
+	Prod, Dot int
+}
+
+// State of the automaton. Items is the closure of the state sorted by
+// production, Trans maps a symbol to the state reached by it and Reductions
+// holds the index of every production that is complete in the state.
+type State struct {
+	Items      []Item
+	Trans      map[int]int
+	Reductions []int
+}
+
+// Automaton is the LR(0) automaton for a grammar. All symbols are represented
+// by their index in Set.
+type Automaton struct {
+	Set      *setsymbol.Set
+	Start    int
+	Prods    []Prod
+	ByNT     [][]int // [nonterminal] -> indexes into Prods
+	States   []*State
+	stateIdx map[string]int
+}
+
+// New builds the LR(0) automaton for a grammar. The grammar must have at least
+// one non-terminal.
+func New(grmr parlex.Grammar) *Automaton {
+	set := setsymbol.New()
+	set.LoadGrammar(grmr)
+	nts := grmr.NonTerminals()
+	a := &Automaton{
+		Set:      set,
+		Start:    set.Symbol(nts[0]).Idx(),
+		ByNT:     make([][]int, set.Size()),
+		stateIdx: make(map[string]int),
+	}
+	a.Prods = append(a.Prods, Prod{NT: -1, Symbols: []int{a.Start}})
+	for _, nt := range nts {
+		ntIdx := set.Symbol(nt).Idx()
+		for i := grmr.Productions(nt).Iter(); i.Next(); {
+			p := Prod{
+				NT:      ntIdx,
+				Idx:     i.Idx,
+				Symbols: make([]int, i.Symbols()),
+			}
+			for j := i.Iter(); j.Next(); {
+				p.Symbols[j.Idx] = set.Symbol(j.Symbol).Idx()
+			}
+			a.ByNT[ntIdx] = append(a.ByNT[ntIdx], len(a.Prods))
+			a.Prods = append(a.Prods, p)
+		}
+	}
+
+	a.addState([]Item{{Prod: 0}})
+	for i := 0; i < len(a.States); i++ {
+		a.buildTransitions(a.States[i])
+	}
+	return a
+}
+
+// IsNonTerminal returns true if the symbol index is a non-terminal in the
+// grammar.
+func (a *Automaton) IsNonTerminal(idx int) bool {
+	return idx >= 0 && idx < len(a.ByNT) && a.ByNT[idx] != nil
+}
+
+// Next returns the symbol after the dot in an item. If the item is complete,
+// -1 is returned.
+func (a *Automaton) Next(it Item) int {
+	syms := a.Prods[it.Prod].Symbols
+	if it.Dot == len(syms) {
+		return -1
+	}
+	return syms[it.Dot]
+}
+
+// Kernel returns the kernel items of a state; the items where the dot is not
+// at the start and the start item.
+func (a *Automaton) Kernel(s *State) []Item {
+	var out []Item
+	for _, it := range s.Items {
+		if it.Dot > 0 || it.Prod == 0 {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// ProdString returns the production in the form "E -> E op E".
+func (a *Automaton) ProdString(pIdx int) string {
+	p := a.Prods[pIdx]
+	strs := make([]string, 0, len(p.Symbols)+2)
+	if p.NT == -1 {
+		strs = append(strs, "START'")
+	} else {
+		strs = append(strs, a.Set.ByIdx(p.NT).String())
+	}
+	strs = append(strs, "->")
+	for _, s := range p.Symbols {
+		strs = append(strs, a.Set.ByIdx(s).String())
+	}
+	return strings.Join(strs, " ")
+}
+
+func (a *Automaton) closure(kernel []Item) []Item {
+	items := append([]Item(nil), kernel...)
+	seen := make(map[Item]bool)
+	for _, it := range items {
+		seen[it] = true
+	}
+	for i := 0; i < len(items); i++ {
+		next := a.Next(items[i])
+		if !a.IsNonTerminal(next) {
+			continue
+		}
+		for _, pIdx := range a.ByNT[next] {
+			it := Item{Prod: pIdx}
+			if !seen[it] {
+				seen[it] = true
+				items = append(items, it)
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Prod != items[j].Prod {
+			return items[i].Prod < items[j].Prod
+		}
+		return items[i].Dot < items[j].Dot
+	})
+	return items
+}
+
+func itemsKey(items []Item) string {
+	strs := make([]string, len(items))
+	for i, it := range items {
+		strs[i] = fmt.Sprintf("%d.%d", it.Prod, it.Dot)
+	}
+	return strings.Join(strs, ",")
+}
+
+func (a *Automaton) addState(kernel []Item) int {
+	items := a.closure(kernel)
+	key := itemsKey(items)
+	if idx, ok := a.stateIdx[key]; ok {
+		return idx
+	}
+	s := &State{
+		Items: items,
+		Trans: make(map[int]int),
+	}
+	for _, it := range items {
+		if it.Prod != 0 && a.Next(it) == -1 {
+			s.Reductions = append(s.Reductions, it.Prod)
+		}
+	}
+	idx := len(a.States)
+	a.stateIdx[key] = idx
+	a.States = append(a.States, s)
+	return idx
+}
+
+func (a *Automaton) buildTransitions(s *State) {
+	var order []int
+	kernels := make(map[int][]Item)
+	for _, it := range s.Items {
+		sym := a.Next(it)
+		if sym == -1 {
+			continue
+		}
+		if _, ok := kernels[sym]; !ok {
+			order = append(order, sym)
+		}
+		kernels[sym] = append(kernels[sym], Item{Prod: it.Prod, Dot: it.Dot + 1})
+	}
+	for _, sym := range order {
+		s.Trans[sym] = a.addState(kernels[sym])
+	}
+}
@@ -0,0 +1,36 @@
+package lr
+
+import (
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAutomaton(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op T
+      -> T
+    T -> int
+  `)
+	assert.NoError(t, err)
+	a := New(grmr)
+
+	assert.Equal(t, "E", a.Set.ByIdx(a.Start).String())
+	assert.Len(t, a.Prods, 4)
+	assert.Equal(t, "START' -> E", a.ProdString(0))
+	assert.Equal(t, "E -> E op T", a.ProdString(1))
+	assert.True(t, a.IsNonTerminal(a.Set.Str("T").Idx()))
+	assert.False(t, a.IsNonTerminal(a.Set.Str("int").Idx()))
+
+	// state 0 has only the start item as it's kernel and closes over all
+	// productions
+	s0 := a.States[0]
+	assert.Equal(t, []Item{{Prod: 0}}, a.Kernel(s0))
+	assert.Len(t, s0.Items, 4)
+
+	afterE := a.States[s0.Trans[a.Set.Str("E").Idx()]]
+	assert.Equal(t, []Item{{Prod: 0, Dot: 1}, {Prod: 1, Dot: 1}}, a.Kernel(afterE))
+
+	afterInt := a.States[s0.Trans[a.Set.Str("int").Idx()]]
+	assert.Equal(t, []int{3}, afterInt.Reductions)
+}
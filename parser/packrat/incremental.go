@@ -0,0 +1,259 @@
+package packrat
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+)
+
+// Edit replaces the source from the byte offset Start up to End with Text.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// Incremental parses a source and keeps the memo table so that after an edit
+// only the part of the table affected by the edit is rebuilt.
+//
+// Every entry in the memo table depends only on the kinds of the lexemes it
+// spans, unless the grammar has predicates which can also read their values.
+// After an edit, the source is lexed again and compared to the previous
+// lexemes to find the range of lexemes that changed. Entries that end before
+// the change are kept and entries that start after it are shifted and kept.
+// Symbols that were fully explored after the change are not derived again.
+//
+// Symbols before the change that were fully explored without reading any of
+// the changed lexemes are not derived again either.
+//
+// The whole source is lexed for each edit because a lexer may look ahead
+// arbitrarily far, so only parsing is incremental. If the parser has Islands,
+// there is no way to know which lexemes they read, so each edit parses the
+// whole source again.
+type Incremental struct {
+	*Packrat
+	Lexer parlex.Lexer
+	src   string
+	lxms  []parlex.Lexeme
+	set   *setsymbol.Set
+	op    *prOp
+	// reused is the number of memo entries kept by the last Reparse
+	reused int
+}
+
+// Incremental returns an Incremental parser that will use the lexer.
+func (p *Packrat) Incremental(lxr parlex.Lexer) *Incremental {
+	set := setsymbol.New()
+	set.LoadGrammar(p.Grammar)
+	return &Incremental{
+		Packrat: p,
+		Lexer:   lxr,
+		set:     set,
+	}
+}
+
+// Source returns the current source.
+func (inc *Incremental) Source() string { return inc.src }
+
+// Lexemes returns the lexemes of the current source.
+func (inc *Incremental) Lexemes() []parlex.Lexeme { return inc.lxms }
+
+// ParseSource lexes and parses the whole source, replacing any previous state.
+func (inc *Incremental) ParseSource(src string) (parlex.ParseNode, error) {
+	if len(inc.Grammar.NonTerminals()) == 0 {
+		return nil, parlex.ErrBadGrammar
+	}
+	inc.src = src
+	inc.lxms = inc.Lexer.Lex(src)
+	inc.op = inc.newIncOp(inc.lxms)
+	inc.reused = 0
+	return inc.op.run(inc.lxms)
+}
+
+// Reparse applies the edit to the source and parses it, reusing the parts of
+// the previous parse that were not affected. If the parse fails, the error is
+// the same as ParseErr would return.
+func (inc *Incremental) Reparse(e Edit) (parlex.ParseNode, error) {
+	if e.Start < 0 || e.End < e.Start || e.End > len(inc.src) {
+		return nil, fmt.Errorf("Bad Edit: [%d:%d] of %d bytes", e.Start, e.End, len(inc.src))
+	}
+	src := inc.src[:e.Start] + e.Text + inc.src[e.End:]
+	if inc.op == nil || len(inc.Islands) > 0 {
+		return inc.ParseSource(src)
+	}
+	lxms := inc.Lexer.Lex(src)
+
+	// find the range of lexemes that changed, old[a:b] was replaced by
+	// lxms[a:b+delta]
+	old := inc.lxms
+	same := sameKind
+	if inc.op.preds != nil {
+		same = sameLexeme
+	}
+	a := 0
+	for a < len(old) && a < len(lxms) && same(old[a], lxms[a]) {
+		a++
+	}
+	k := 0
+	for k < len(old)-a && k < len(lxms)-a && same(old[len(old)-1-k], lxms[len(lxms)-1-k]) {
+		k++
+	}
+	b, delta := len(old)-k, len(lxms)-len(old)
+
+	op := inc.newIncOp(lxms)
+	inc.reused = op.seed(inc.op, a, b, delta)
+	inc.src, inc.lxms, inc.op = src, lxms, op
+	pn, err := op.run(lxms)
+	if err != nil {
+		// seeded entries skip the work that records what was expected, so the
+		// error comes from a full parse
+		inc.op = inc.newIncOp(lxms)
+		return inc.op.run(lxms)
+	}
+	return pn, nil
+}
+
+func (inc *Incremental) newIncOp(lxms []parlex.Lexeme) *prOp {
+	op := inc.newOp(inc.set, lxms)
+	op.deps = &deps{
+		reach: make(map[treeMarker]int),
+		rdeps: make(map[treeMarker][]treeMarker),
+	}
+	return op
+}
+
+func sameKind(l1, l2 parlex.Lexeme) bool {
+	return l1.Kind().String() == l2.Kind().String()
+}
+
+func sameLexeme(l1, l2 parlex.Lexeme) bool {
+	return sameKind(l1, l2) && l1.Value() == l2.Value()
+}
+
+// seed takes the entries from a previous parse that are not affected by
+// replacing the lexemes from a up to b. Entries after b are shifted by delta.
+// The previous parse should not be used after this. It returns the number of
+// memo entries that were kept.
+func (op *prOp) seed(prev *prOp, a, b, delta int) int {
+	shift := func(tk treeKey) (treeKey, bool) {
+		if tk.start < a && tk.end <= a {
+			return tk, true
+		}
+		if tk.start >= b {
+			tk.start += delta
+			tk.end += delta
+			return tk, true
+		}
+		return tk, false
+	}
+
+	// the maps are updated in place, entries that move are removed first and
+	// added back once all of them are removed so they do not collide
+	op.memo = prev.memo
+	var moved []treeDef
+	for tk, td := range op.memo {
+		nk, ok := shift(tk)
+		if ok && nk == tk {
+			continue
+		}
+		delete(op.memo, tk)
+		if ok {
+			td.treeKey = nk
+			children := make([]treeKey, len(td.children))
+			for i, ck := range td.children {
+				children[i], _ = shift(ck)
+			}
+			td.children = children
+			moved = append(moved, td)
+		}
+	}
+	for _, td := range moved {
+		op.memo[td.treeKey] = td
+	}
+	for _, td := range op.memo {
+		if len(td.children) > 0 {
+			op.live++
+		}
+	}
+	op.stats.Peak = op.live
+
+	op.markers = prev.markers
+	var movedMarkers [][]treeKey
+	for m, tks := range op.markers {
+		kept := tks[:0]
+		for _, tk := range tks {
+			if nk, ok := shift(tk); ok {
+				kept = append(kept, nk)
+			}
+		}
+		if len(kept) == 0 || kept[0].treeMarker != m {
+			delete(op.markers, m)
+			if len(kept) > 0 {
+				movedMarkers = append(movedMarkers, kept)
+			}
+		} else {
+			op.markers[m] = kept
+		}
+	}
+	for _, tks := range movedMarkers {
+		op.markers[tks[0].treeMarker] = tks
+	}
+
+	// A symbol that was fully explored will derive the same trees if it did
+	// not read any of the lexemes that changed, so it does not need to be
+	// explored again. That is true of every symbol after the change.
+	reach := prev.deps.resolve()
+	for m, q := range prev.queued {
+		if !q {
+			continue
+		}
+		r := reach[m]
+		if m.start >= b {
+			m.start += delta
+			r += delta
+		} else if m.start >= a || r > a {
+			continue
+		}
+		op.queued[m] = true
+		op.deps.reach[m] = r
+	}
+	return len(op.memo)
+}
+
+// deps tracks which lexemes were read while exploring each symbol. Reach is
+// the index after the last lexeme read directly and rdeps maps a marker to the
+// markers whose exploration waited on it.
+type deps struct {
+	reach map[treeMarker]int
+	rdeps map[treeMarker][]treeMarker
+}
+
+func (d *deps) add(root, requires treeMarker, nonterm bool) {
+	if nonterm {
+		d.rdeps[requires] = append(d.rdeps[requires], root)
+	} else if r := requires.start + 1; r > d.reach[root] {
+		d.reach[root] = r
+	}
+}
+
+// resolve returns the index after the last lexeme read while exploring each
+// marker, including the markers it depended on.
+func (d *deps) resolve() map[treeMarker]int {
+	reach := make(map[treeMarker]int, len(d.reach))
+	var queue []treeMarker
+	for m, r := range d.reach {
+		reach[m] = r
+		queue = append(queue, m)
+	}
+	for len(queue) > 0 {
+		m := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		r := reach[m]
+		for _, root := range d.rdeps[m] {
+			if r > reach[root] {
+				reach[root] = r
+				queue = append(queue, root)
+			}
+		}
+	}
+	return reach
+}
@@ -0,0 +1,112 @@
+package packrat
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var incLxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `))
+
+var incGrmr = parlex.MustGrammar(grammar.New(`
+    L -> L E
+      ->
+    E -> E op T
+      -> T
+    T -> ( E )
+      -> int
+  `))
+
+func TestIncremental(t *testing.T) {
+	p := New(incGrmr)
+	inc := p.Incremental(incLxr)
+	pn, err := inc.ParseSource("1+2 (3*4) 5")
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+
+	edits := []Edit{
+		{Start: 0, End: 1, Text: "10"},                 // change a value
+		{Start: 12, End: 12, Text: " 6-7"},             // append
+		{Start: 0, End: 0, Text: "(8) "},               // prepend
+		{Start: 7, End: 8, Text: "*"},                  // change an operator
+		{Start: 9, End: 9, Text: ")"},                  // unbalanced
+		{Start: 9, End: 10, Text: ""},                  // balanced again
+		{Start: 4, End: 16, Text: ""},                  // delete a range
+		{Start: 0, End: 0, Text: "1+(2+(3+(4+5))) 6 "}, // nest
+	}
+	for _, e := range edits {
+		src := inc.Source()
+		expectedSrc := src[:e.Start] + e.Text + src[e.End:]
+		pn, err := inc.Reparse(e)
+		assert.Equal(t, expectedSrc, inc.Source())
+		full, fullErr := p.ParseErr(incLxr.Lex(expectedSrc))
+		if fullErr != nil {
+			assert.Nil(t, pn, expectedSrc)
+			assert.Equal(t, fullErr, err, expectedSrc)
+			continue
+		}
+		assert.NoError(t, err, expectedSrc)
+		if assert.NotNil(t, pn, expectedSrc) {
+			assert.Equal(t, full.(*tree.PN).String(), pn.(*tree.PN).String(), expectedSrc)
+			assert.Equal(t, full.(*tree.PN).S, pn.(*tree.PN).S, expectedSrc)
+		}
+	}
+
+	_, err = inc.Reparse(Edit{Start: 3, End: 1000})
+	assert.Equal(t, "Bad Edit: [3:1000] of 26 bytes", err.Error())
+}
+
+func TestIncrementalReuse(t *testing.T) {
+	src := strings.Repeat("1+2*(3-4) ", 20)
+	inc := New(incGrmr).Incremental(incLxr)
+	_, err := inc.ParseSource(src)
+	assert.NoError(t, err)
+	total := len(inc.op.memo)
+
+	// changing a value does not change any kinds so the whole table is kept
+	_, err = inc.Reparse(Edit{Start: 0, End: 1, Text: "7"})
+	assert.NoError(t, err)
+	assert.Equal(t, total, inc.reused)
+
+	// an edit in the middle keeps most of the table
+	mid := len(src) / 2
+	pn, err := inc.Reparse(Edit{Start: mid, End: mid, Text: "5 "})
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	assert.True(t, inc.reused > total/2)
+	full := New(incGrmr).Parse(incLxr.Lex(inc.Source()))
+	assert.Equal(t, full.(*tree.PN).String(), pn.(*tree.PN).String())
+}
+
+func BenchmarkParseSource(b *testing.B) {
+	src := strings.Repeat("1+2*(3-4) ", 50)
+	p := New(incGrmr)
+	for i := 0; i < b.N; i++ {
+		p.Parse(incLxr.Lex(src))
+	}
+}
+
+func BenchmarkReparse(b *testing.B) {
+	src := strings.Repeat("1+2*(3-4) ", 50)
+	inc := New(incGrmr).Incremental(incLxr)
+	inc.ParseSource(src)
+	mid := len(src) / 2
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			inc.Reparse(Edit{Start: mid, End: mid, Text: "5 "})
+		} else {
+			inc.Reparse(Edit{Start: mid, End: mid + 2})
+		}
+	}
+}
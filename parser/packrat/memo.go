@@ -0,0 +1,154 @@
+package packrat
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"sort"
+)
+
+// Stats describes the memo table after a parse. An entry is live if it holds
+// its children.
+type Stats struct {
+	// Entries is the number of entries in the memo table
+	Entries int
+	// Live is the number of live entries when the parse finished
+	Live int
+	// Peak is the most entries that were live at once
+	Peak int
+	// Evicted is the number of times an entry had its children evicted
+	Evicted int
+	// Rederived is the number of times an evicted entry was parsed again
+	Rederived int
+}
+
+// ParseStats is the same as ParseErr and also returns the statistics of the
+// memo table.
+func (p *Packrat) ParseStats(lexemes []parlex.Lexeme) (parlex.ParseNode, Stats, error) {
+	if len(p.Grammar.NonTerminals()) == 0 {
+		return nil, Stats{}, parlex.ErrBadGrammar
+	}
+	set := setsymbol.New()
+	set.LoadGrammar(p.Grammar)
+	op := p.newOp(set, lexemes)
+	pn, err := op.run(lexemes)
+	return pn, *op.stats, err
+}
+
+// store puts the treeDef in the memo table. If it replaces an entry, wasLive
+// should be true if that entry held its children.
+func (op *prOp) store(td treeDef, wasLive bool) {
+	if wasLive {
+		op.live--
+	}
+	if len(td.children) > 0 {
+		op.live++
+	}
+	if op.budget > 0 {
+		op.tick++
+		td.used = op.tick
+	}
+	op.memo[td.treeKey] = td
+	if op.budget > 0 && op.live > op.budget {
+		op.evict()
+	}
+	if op.live > op.stats.Peak {
+		op.stats.Peak = op.live
+	}
+}
+
+// get returns the treeDef for a key from the memo table. If the entry was
+// evicted, it is derived again.
+func (op *prOp) get(tk treeKey) (treeDef, bool) {
+	td, ok := op.memo[tk]
+	if !ok || op.budget == 0 {
+		return td, ok
+	}
+	if td.evicted {
+		td.children = op.rederive(tk)
+		td.evicted = false
+		op.store(td, false)
+		return td, true
+	}
+	op.tick++
+	td.used = op.tick
+	op.memo[tk] = td
+	return td, true
+}
+
+// evict removes the children from the least recently used entries until a
+// quarter of the budget is free so that evictions happen in batches. Entries
+// that span all the lexemes are never evicted because deriving them again
+// would mean parsing everything again.
+func (op *prOp) evict() {
+	lru := make([]treeKey, 0, op.live)
+	for tk, td := range op.memo {
+		if len(td.children) > 0 && (tk.start > 0 || tk.end < len(op.lxms)) {
+			lru = append(lru, tk)
+		}
+	}
+	sort.Slice(lru, func(i, j int) bool {
+		return op.memo[lru[i]].used < op.memo[lru[j]].used
+	})
+	n := op.live - (op.budget - op.budget/4)
+	if n > len(lru) {
+		n = len(lru)
+	}
+	for _, tk := range lru[:n] {
+		td := op.memo[tk]
+		td.children = nil
+		td.evicted = true
+		op.memo[tk] = td
+	}
+	op.live -= n
+	op.stats.Evicted += n
+}
+
+// rederive parses the lexemes spanned by an evicted entry again and returns
+// its children. The children are still in the memo table because only the
+// children of an entry are evicted, never the entry.
+func (op *prOp) rederive(tk treeKey) []treeKey {
+	op.stats.Rederived++
+	sub := &prOp{
+		grmr:     op.grmr,
+		lxms:     op.lxms[tk.start:tk.end],
+		memo:     make(map[treeKey]treeDef),
+		markers:  make(map[treeMarker][]treeKey),
+		partials: make(map[treeMarker][]treePartial),
+		queued:   make(map[treeMarker]bool),
+		nonterms: op.nonterms,
+		set:      op.set,
+		precs:    op.precs,
+		preds:    op.preds,
+		budget:   op.budget,
+		stats:    &Stats{},
+		islands:  op.islands,
+	}
+	if op.islands != nil {
+		sub.src = op.src[tk.start:tk.end]
+		sub.islandNodes = make(map[treeKey]parlex.ParseNode)
+	}
+	sub.furthest.expected = make([]bool, len(op.furthest.expected))
+	if op.rejected != nil {
+		sub.rejected = make(map[derivation]bool)
+		for d := range op.rejected {
+			if d.start >= tk.start && d.end <= tk.end {
+				d.start -= tk.start
+				d.end -= tk.start
+				sub.rejected[d] = true
+			}
+		}
+	}
+	root := treeMarker{idx: tk.idx}
+	sub.explore(root)
+	op.stats.Evicted += sub.stats.Evicted
+	op.stats.Rederived += sub.stats.Rederived
+
+	td, _ := sub.get(treeKey{treeMarker: root, end: len(sub.lxms)})
+	children := make([]treeKey, len(td.children))
+	for i, ck := range td.children {
+		ck.start += tk.start
+		ck.end += tk.start
+		children[i] = ck
+	}
+	return children
+}
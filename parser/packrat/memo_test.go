@@ -0,0 +1,69 @@
+package packrat
+
+import (
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemoBudget(t *testing.T) {
+	src := "1+2 (3*4) 5 ((6-7)/8) 9*(10+11)-12 13 (14) 15+16+17 ((18))"
+	lxms := incLxr.Lex(src)
+
+	expected, stats, err := New(incGrmr).ParseStats(lxms)
+	assert.NoError(t, err)
+	assert.Zero(t, stats.Evicted)
+	assert.Zero(t, stats.Rederived)
+	assert.Equal(t, stats.Live, stats.Peak)
+	assert.True(t, stats.Entries >= stats.Live)
+
+	for _, budget := range []int{1, 5, 20, 50} {
+		p := New(incGrmr)
+		p.MemoBudget = budget
+		pn, bStats, err := p.ParseStats(lxms)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String())
+		assert.True(t, bStats.Evicted > 0)
+		assert.True(t, bStats.Rederived > 0)
+		assert.True(t, bStats.Peak < stats.Peak)
+	}
+}
+
+func TestMemoBudgetPrecedence(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    * /\*/
+    ^ /\^/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    %left +
+    %left *
+    %right ^
+    E -> E + E
+      -> E * E
+      -> E ^ E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	lxms := lxr.Lex("1 + 2 * 3 ^ 4 ^ 5 + (6 + 7) * 8 + 9")
+
+	expected, err := New(grmr).ParseErr(lxms)
+	assert.NoError(t, err)
+
+	p := New(grmr)
+	p.MemoBudget = 10
+	pn, stats, err := p.ParseStats(lxms)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String())
+	assert.True(t, stats.Evicted > 0)
+}
@@ -1,18 +1,59 @@
 // Package packrat implements a packrat parser based on
 // http://web.cs.ucla.edu/~todd/research/pepm08.pdf . It can handle left
 // recursion.
+//
+// If the grammar is a parlex.PredicateGrammar, a production with a predicate
+// only applies when the predicate accepts its children. The predicate is
+// checked when the production is completed, but a child can later be replaced
+// by a derivation with a higher priority. So once a tree is found, every
+// predicate in it is checked again against its final children. If one fails,
+// that derivation is rejected and the lexemes are parsed again without it.
+//
+// Islands hand a non-terminal to another parser. When the non-terminal is
+// reached, its parser parses the longest prefix of the lexemes it can and that
+// tree is used as the non-terminal, so expressions can be parsed by a
+// pratt.Pratt while the statements around them are parsed by the grammar.
+//
+// Resolvers choose between the ways a non-terminal can be built over the same
+// lexemes, when the order of the productions does not give the tree that is
+// wanted.
 package packrat
 
 import (
+	"context"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/lexeme"
 	"github.com/adamcolton/parlex/symbol/setsymbol"
 	"github.com/adamcolton/parlex/tree"
 )
 
-// Packrat is a Packrat parser
+// Packrat is a Packrat parser. MemoBudget limits the number of entries in the
+// memo table that hold their children. When the limit is reached, the children
+// of the least recently used entries are evicted and are parsed again if they
+// are needed. A MemoBudget of 0 does not limit the memo table.
+//
+// If Tracer is set, every production that is tried, every tree that is found,
+// every use of the memo table and every terminal that does not match is sent
+// to it.
+//
+// Islands maps the name of a non-terminal to a parser that parses it instead of
+// its productions. The island's tree is used in place of the non-terminal; if
+// its root is a different kind, it is the only child of a node for the
+// non-terminal.
+//
+// Resolvers maps the name of a non-terminal to a Resolver that chooses between
+// the trees for it that cover the same lexemes.
+//
+// A Packrat is safe to use from many goroutines at once as long as it is not
+// changed while parsing. Each parse builds its own memo table and the grammar
+// is only read. A Tracer set on a shared parser receives the events of every
+// parse, so it must be safe for concurrent use too.
 type Packrat struct {
 	parlex.Grammar
+	MemoBudget int
+	Tracer     parlex.Tracer
+	Islands    map[string]parlex.PrefixParser
+	Resolvers  map[string]Resolver
 }
 
 type treeMarker struct {
@@ -29,6 +70,8 @@ type treeDef struct {
 	treeKey
 	children []treeKey
 	priority int
+	used     int
+	evicted  bool
 }
 
 type treePartial struct {
@@ -49,12 +92,45 @@ type prOp struct {
 	grmr     parlex.Grammar
 	lxms     []*lexeme.Lexeme
 	memo     map[treeKey]treeDef
-	markers  map[treeMarker][]treeDef
+	markers  map[treeMarker][]treeKey
 	partials map[treeMarker][]treePartial
 	queued   map[treeMarker]bool
 	nonterms []bool
 	stack    *updater
 	set      *setsymbol.Set
+	precs    [][]prec
+	preds    [][]parlex.Predicate
+	rejected map[derivation]bool
+	deps     *deps
+	intr     parlex.Interrupt
+	tracer   parlex.Tracer
+	budget   int
+	live     int
+	// if prefix is set, run accepts the longest prefix of the lexemes and end
+	// is set to where it ends
+	prefix bool
+	end    int
+	tick   int
+	stats  *Stats
+	// islands is indexed by symbol and is nil if there are none. The trees they
+	// return are held in islandNodes by the key of their leaf in the memo table.
+	islands     []parlex.PrefixParser
+	src         []parlex.Lexeme
+	islandNodes map[treeKey]parlex.ParseNode
+	// resolvers is indexed by symbol and is nil if there are none
+	resolvers []Resolver
+	// depth is how deeply addProds is nested, for tracing
+	depth    int
+	furthest struct {
+		end      int
+		req      int
+		expected []bool
+		// nonAssoc is the position of the operator in the furthest reaching
+		// tree dropped by violatesNonAssoc and nonAssocEnd is where that tree
+		// ends, 0 if none was dropped.
+		nonAssoc    int
+		nonAssocEnd int
+	}
 }
 
 // New returns a Packrat parser
@@ -74,58 +150,295 @@ func Constructor(grmr parlex.Grammar) (parlex.Parser, error) {
 // Parse fulfills the parlex.Parser. The Packrat parser will try to parse the
 // lexemes.
 func (p *Packrat) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
-	nts := p.Grammar.NonTerminals()
-	if len(nts) == 0 {
-		return nil
+	pn, _ := p.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. If the parse fails, a *parlex.ParseError
+// is returned with the furthest position the parser reached and the kinds of
+// lexemes it would have accepted there.
+func (p *Packrat) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return p.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops and returns the context's error if the context is done before the
+// parse finishes.
+func (p *Packrat) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	if len(p.Grammar.NonTerminals()) == 0 {
+		return nil, parlex.ErrBadGrammar
+	}
+	set := setsymbol.New()
+	set.LoadGrammar(p.Grammar)
+	op := p.newOp(set, lexemes)
+	op.intr.Ctx = ctx
+	return op.run(lexemes)
+}
+
+// ParseFrom fulfills parlex.FromParser. It is the same as ParseErr but the
+// lexemes are parsed as the non-terminal named start.
+func (p *Packrat) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	grmr, err := parlex.StartFrom(p.Grammar, start)
+	if err != nil {
+		return nil, err
+	}
+	sub := &Packrat{
+		Grammar:    grmr,
+		MemoBudget: p.MemoBudget,
+		Tracer:     p.Tracer,
+		Islands:    p.Islands,
+		Resolvers:  p.Resolvers,
+	}
+	return sub.ParseErr(lexemes)
+}
+
+// ParsePrefix fulfills parlex.PrefixParser. It parses the longest prefix of the
+// lexemes that can be derived from the start symbol and returns the lexemes
+// after it. If no prefix can be parsed, all the lexemes are returned with a
+// *parlex.ParseError.
+func (p *Packrat) ParsePrefix(lexemes []parlex.Lexeme) (parlex.ParseNode, []parlex.Lexeme, error) {
+	if len(p.Grammar.NonTerminals()) == 0 {
+		return nil, lexemes, parlex.ErrBadGrammar
 	}
 	set := setsymbol.New()
 	set.LoadGrammar(p.Grammar)
+	op := p.newOp(set, lexemes)
+	op.prefix = true
+	pn, err := op.run(lexemes)
+	if err != nil {
+		return nil, lexemes, err
+	}
+	return pn, lexemes[op.end:], nil
+}
+
+func (p *Packrat) newOp(set *setsymbol.Set, lexemes []parlex.Lexeme) *prOp {
 	op := &prOp{
 		grmr:     p.Grammar,
 		lxms:     set.LoadLexemes(lexemes),
 		memo:     make(map[treeKey]treeDef),
-		markers:  make(map[treeMarker][]treeDef),     // maps marker to treeDef containing that marker
+		markers:  make(map[treeMarker][]treeKey),     // maps marker to treeKeys containing that marker
 		partials: make(map[treeMarker][]treePartial), // maps a marker to a treePartial looking for that marker
 		queued:   make(map[treeMarker]bool),
 		set:      set,
+		tracer:   p.Tracer,
+		budget:   p.MemoBudget,
+		stats:    &Stats{},
+	}
+	var islands map[int]parlex.PrefixParser
+	if len(p.Islands) > 0 {
+		// the island may not be used by the grammar, so it is added to the set
+		// before anything is sized by it
+		islands = make(map[int]parlex.PrefixParser, len(p.Islands))
+		for name, island := range p.Islands {
+			islands[set.Str(name).Idx()] = island
+		}
 	}
 	op.nonterms = make([]bool, set.Size())
+	op.furthest.expected = make([]bool, set.Size())
 	for _, nonterm := range p.Grammar.NonTerminals() {
 		op.nonterms[op.set.Symbol(nonterm).Idx()] = true
 	}
+	if islands != nil {
+		op.islands = make([]parlex.PrefixParser, set.Size())
+		for idx, island := range islands {
+			op.islands[idx] = island
+		}
+		op.src = lexemes
+		op.islandNodes = make(map[treeKey]parlex.ParseNode)
+	}
+	if len(p.Resolvers) > 0 {
+		op.resolvers = make([]Resolver, set.Size())
+		for name, r := range p.Resolvers {
+			if set.Has(name) {
+				op.resolvers[set.Str(name).Idx()] = r
+			}
+		}
+	}
+	if _, ok := p.Grammar.(parlex.PrecedenceGrammar); ok {
+		op.loadPrecedence()
+	}
+	if _, ok := p.Grammar.(parlex.PredicateGrammar); ok {
+		op.loadPredicates()
+	}
+	return op
+}
 
+func (op *prOp) run(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
 	start := treeMarker{
-		idx: op.set.Symbol(nts[0]).Idx(),
+		idx: op.set.Symbol(op.grmr.NonTerminals()[0]).Idx(),
 	}
-	op.addProds(start)
+	if !op.explore(start) {
+		return nil, op.intr.Err
+	}
+	op.stats.Entries, op.stats.Live = len(op.memo), op.live
 
+	var accept treeKey
+	accept.treeMarker = start
+	accept.end = len(lexemes)
+	if op.prefix {
+		accept.end = op.longest(start)
+	}
+	accepted, ok := op.get(accept)
+	if !ok {
+		return nil, op.parseError(lexemes)
+	}
+	if op.preds != nil {
+		if d, failed := op.recheck(&accepted); failed {
+			op.trace(parlex.TraceBacktrack, d.idx, d.priority, d.start, d.end)
+			op.rejected[d] = true
+			op.reset()
+			return op.run(lexemes)
+		}
+	}
+	op.end = accept.end
+	return op.toPN(&accepted), nil
+}
+
+// longest returns the end of the longest tree found for the marker or -1 if
+// none was found.
+func (op *prOp) longest(at treeMarker) int {
+	end := -1
+	for _, tk := range op.markers[at] {
+		if tk.end > end {
+			end = tk.end
+		}
+	}
+	return end
+}
+
+// explore finds every tree that can be derived from the marker. It returns
+// false if it was interrupted.
+func (op *prOp) explore(start treeMarker) bool {
+	op.addProds(start)
 	var u *updater
 	for op.stack != nil {
+		if op.intr.Check() {
+			return false
+		}
 		u, op.stack = op.stack, op.stack.next
 		u.update(op)
 	}
+	return true
+}
 
-	var accept treeKey
-	accept.idx = start.idx
-	accept.end = len(lexemes)
-	accepted := op.memo[accept]
-	if accepted.end != accept.end {
-		return nil
+// expect records that a terminal was required at a position. Only the
+// requirements at the furthest position are kept.
+func (op *prOp) expect(at treeMarker) {
+	if at.start < op.furthest.req {
+		return
+	}
+	if at.start > op.furthest.req {
+		op.furthest.req = at.start
+		for i := range op.furthest.expected {
+			op.furthest.expected[i] = false
+		}
 	}
-	return accepted.toPN(op.lxms, op.memo, op.set)
+	op.furthest.expected[at.idx] = true
+}
+
+// prec is the precedence of a production
+type prec struct {
+	level int
+	assoc parlex.Assoc
+}
+
+// loadPrecedence finds the precedence of every production.
+func (op *prOp) loadPrecedence() {
+	op.precs = make([][]prec, op.set.Size())
+	for _, nonterm := range op.grmr.NonTerminals() {
+		prods := op.grmr.Productions(nonterm)
+		precs := make([]prec, prods.Productions())
+		for i := prods.Iter(); i.Next(); {
+			precs[i.Idx].level, precs[i.Idx].assoc = parlex.ProductionPrecedence(op.grmr, i.Production)
+		}
+		op.precs[op.set.Symbol(nonterm).Idx()] = precs
+	}
+}
+
+// precOf returns the precedence of the production of a treeDef.
+func (op *prOp) precOf(td *treeDef) prec {
+	if op.precs == nil || !op.nonterms[td.idx] || (len(td.children) == 0 && !td.evicted) {
+		return prec{}
+	}
+	return op.precs[td.idx][td.priority]
+}
+
+// violatesNonAssoc is true if a treeDef for a non-associative operator has an
+// operand with the same precedence, as in "a == b == c". The position of the
+// second operator is recorded as a failure.
+func (op *prOp) violatesNonAssoc(td *treeDef) bool {
+	p := op.precOf(td)
+	if p.level == 0 || p.assoc != parlex.NonAssoc {
+		return false
+	}
+	first, last := td.children[0], td.children[len(td.children)-1]
+	if c, ok := op.memo[first]; ok && op.precOf(&c).level == p.level {
+		// the operator of td follows the left operand
+		op.failNonAssoc(first.end, td.end)
+		return true
+	}
+	if c, ok := op.memo[last]; ok && op.precOf(&c).level == p.level {
+		pos := last.start
+		if c, _ = op.get(last); len(c.children) > 1 {
+			pos = c.children[0].end
+		}
+		op.failNonAssoc(pos, td.end)
+		return true
+	}
+	return false
+}
+
+// failNonAssoc keeps the operator of the dropped tree that reaches furthest.
+func (op *prOp) failNonAssoc(pos, end int) {
+	f := &op.furthest
+	if end > f.nonAssocEnd || (end == f.nonAssocEnd && pos < f.nonAssoc) {
+		f.nonAssoc, f.nonAssocEnd = pos, end
+	}
+}
+
+// parseError reports the furthest position the parser reached. If a tree that
+// reached at least as far was dropped for a non-associative operator, that
+// operator is reported instead; otherwise the input would look incomplete when
+// it is wrong.
+func (op *prOp) parseError(lexemes []parlex.Lexeme) *parlex.ParseError {
+	pos := op.furthest.end
+	var expected []parlex.Symbol
+	if op.furthest.req >= pos {
+		pos = op.furthest.req
+		for idx, e := range op.furthest.expected {
+			if e {
+				expected = append(expected, op.set.ByIdx(idx))
+			}
+		}
+	}
+	if end := op.furthest.nonAssocEnd; end > 0 && end >= pos {
+		return parlex.NewParseError(op.furthest.nonAssoc, lexemes, nil)
+	}
+	return parlex.NewParseError(pos, lexemes, expected)
 }
 
 func (op *prOp) addProds(root treeMarker) {
 	if op.queued[root] {
+		if op.nonterms[root.idx] {
+			op.trace(parlex.TraceMemoHit, root.idx, -1, root.start, root.start)
+		}
 		return
 	}
 	op.queued[root] = true
+	if island := op.island(root.idx); island != nil {
+		op.runIsland(root, island)
+		return
+	}
 	rootSymbol := op.set.ByIdx(root.idx)
 	prods := op.grmr.Productions(rootSymbol)
 	if prods == nil {
 		return
 	}
+	op.depth++
+	defer func() { op.depth-- }()
+	op.trace(parlex.TraceMemoMiss, root.idx, -1, root.start, root.start)
 	for i := prods.Iter(); i.Next(); {
+		op.trace(parlex.TraceAttempt, root.idx, i.Idx, root.start, root.start)
 		if i.Symbols() == 0 {
 			var nilTreeDef treeDef
 			nilTreeDef.treeMarker = root
@@ -178,21 +491,33 @@ func (u *updater) update(op *prOp) {
 }
 
 func (op *prOp) addToMemo(td treeDef) {
+	if (op.precs != nil && op.violatesNonAssoc(&td)) || (op.preds != nil && !op.accepts(&td)) {
+		op.trace(parlex.TraceBacktrack, td.idx, td.priority, td.start, td.end)
+		return
+	}
+	if td.end > op.furthest.end {
+		op.furthest.end = td.end
+	}
 	old, ok := op.memo[td.treeKey]
 	if !ok {
-		op.memo[td.treeKey] = td
-		op.markers[td.treeMarker] = append(op.markers[td.treeMarker], td)
+		op.traceMatch(&td)
+		op.store(td, false)
+		op.markers[td.treeMarker] = append(op.markers[td.treeMarker], td.treeKey)
 		for _, tp := range op.partials[td.treeMarker] {
 			op.push(tp, td.treeKey)
 		}
-	} else if td.comparePriority(&old, op) == 1 && !op.createsCircularDep(td, &td) {
-		op.memo[td.treeKey] = td
+	} else if op.prefer(&td, &old) && !op.createsCircularDep(td, &td) {
+		op.traceMatch(&td)
+		op.store(td, !old.evicted && len(old.children) > 0)
 	}
 }
 
 func (op *prOp) createsCircularDep(node treeDef, root *treeDef) bool {
 	for _, ck := range node.children {
-		if ck == root.treeKey || op.createsCircularDep(op.memo[ck], root) {
+		if ck == root.treeKey {
+			return true
+		}
+		if c, _ := op.get(ck); op.createsCircularDep(c, root) {
 			return true
 		}
 	}
@@ -202,7 +527,29 @@ func (op *prOp) createsCircularDep(node treeDef, root *treeDef) bool {
 //  1: td > td2    which actually means td.priority < td2.priority
 //  0: td == td2   because 0 is the highest priority
 // -1: td < td2
+// When both trees use productions with a precedence, the operator that binds
+// least tightly should be at the top of the tree. For operators with the same
+// precedence, the associativity decides.
 func (td *treeDef) comparePriority(td2 *treeDef, op *prOp) int8 {
+	if td2.evicted {
+		*td2, _ = op.get(td2.treeKey)
+	}
+	if p1, p2 := op.precOf(td), op.precOf(td2); p1.level > 0 && p2.level > 0 {
+		if p1.level != p2.level {
+			if p1.level < p2.level {
+				return 1
+			}
+			return -1
+		}
+		// for left associativity, the tree that splits furthest to the right
+		// is preferred
+		if e1, e2 := td.children[0].end, td2.children[0].end; e1 != e2 && p1.assoc != parlex.NonAssoc {
+			if (e1 > e2) == (p1.assoc == parlex.Left) {
+				return 1
+			}
+			return -1
+		}
+	}
 	if td.priority != td2.priority {
 		if td.priority < td2.priority {
 			return 1
@@ -217,7 +564,8 @@ func (td *treeDef) comparePriority(td2 *treeDef, op *prOp) int8 {
 		if ck1 == ck2 {
 			continue
 		}
-		c1, c2 := op.memo[ck1], op.memo[ck2]
+		c1, _ := op.get(ck1)
+		c2, _ := op.get(ck2)
 		p := c1.comparePriority(&c2, op)
 		if p != 0 {
 			return p
@@ -227,14 +575,21 @@ func (td *treeDef) comparePriority(td2 *treeDef, op *prOp) int8 {
 }
 
 func (op *prOp) addPartial(tp treePartial, requires treeMarker) {
-	if op.nonterms[requires.idx] {
+	nonterm := op.nonterms[requires.idx] || op.island(requires.idx) != nil
+	if op.deps != nil {
+		op.deps.add(tp.treeMarker, requires, nonterm)
+	}
+	if nonterm {
 		op.partials[requires] = append(op.partials[requires], tp)
 	} else {
-		op.checkNonTerminal(requires)
+		op.expect(requires)
+		if op.checkNonTerminal(requires) == nil {
+			op.trace(parlex.TraceFail, requires.idx, -1, requires.start, requires.start)
+		}
 	}
 
-	for _, td := range op.markers[requires] {
-		op.push(tp, td.treeKey)
+	for _, tk := range op.markers[requires] {
+		op.push(tp, tk)
 	}
 
 	op.addProds(requires)
@@ -252,6 +607,82 @@ func (op *prOp) checkNonTerminal(at treeMarker) *treeDef {
 	return &td
 }
 
+// island returns the parser for a symbol that is an island or nil.
+func (op *prOp) island(idx int) parlex.PrefixParser {
+	if op.islands == nil {
+		return nil
+	}
+	return op.islands[idx]
+}
+
+// runIsland parses the longest prefix of the lexemes at the marker with the
+// island's parser. The memo table gets a tree without children spanning the
+// lexemes that were consumed and the island's tree is kept for toPN.
+func (op *prOp) runIsland(at treeMarker, island parlex.PrefixParser) {
+	op.trace(parlex.TraceMemoMiss, at.idx, -1, at.start, at.start)
+	pn, rest, err := island.ParsePrefix(op.src[at.start:])
+	if err != nil || pn == nil {
+		op.expect(at)
+		op.trace(parlex.TraceFail, at.idx, -1, at.start, at.start)
+		return
+	}
+	var td treeDef
+	td.treeMarker = at
+	td.end = len(op.src) - len(rest)
+	op.islandNodes[td.treeKey] = pn
+	op.addToMemo(td)
+}
+
+// islandPN copies the tree an island returned. If its root is not the island's
+// kind, it is put under a node that is.
+func (op *prOp) islandPN(td *treeDef, node parlex.ParseNode) *tree.PN {
+	pn := tree.Copy(node)
+	sym := op.set.ByIdx(td.idx)
+	if pn.Kind().String() == sym.String() {
+		return pn
+	}
+	lx := lexeme.New(sym)
+	lx.L, lx.C = pn.Pos()
+	lx.O = pn.Offset()
+	root := &tree.PN{
+		Lexeme: lx,
+		C:      []*tree.PN{pn},
+	}
+	pn.P = root
+	root.UpdateSpan()
+	return root
+}
+
+// trace sends an event to the tracer, if there is one. The production is found
+// from the priority, a priority of -1 is an event without a production.
+func (op *prOp) trace(kind parlex.TraceKind, idx, priority, start, end int) {
+	if op.tracer == nil {
+		return
+	}
+	e := parlex.TraceEvent{
+		Kind:   kind,
+		Symbol: op.set.ByIdx(idx),
+		Start:  start,
+		End:    end,
+		Depth:  op.depth,
+	}
+	if priority >= 0 {
+		e.Production = op.grmr.Productions(e.Symbol).Production(priority)
+	}
+	op.tracer.Trace(e)
+}
+
+func (op *prOp) traceMatch(td *treeDef) {
+	if op.tracer == nil {
+		return
+	}
+	priority := td.priority
+	if !op.nonterms[td.idx] {
+		priority = -1
+	}
+	op.trace(parlex.TraceMatch, td.idx, priority, td.start, td.end)
+}
+
 func (op *prOp) push(tp treePartial, tk treeKey) {
 	op.stack = &updater{
 		next:      op.stack,
@@ -260,27 +691,35 @@ func (op *prOp) push(tp treePartial, tk treeKey) {
 	}
 }
 
-func (td *treeDef) toPN(lxms []*lexeme.Lexeme, memo map[treeKey]treeDef, set *setsymbol.Set) *tree.PN {
+func (op *prOp) toPN(td *treeDef) *tree.PN {
+	if node, ok := op.islandNodes[td.treeKey]; ok {
+		return op.islandPN(td, node)
+	}
 	var lx *lexeme.Lexeme
 	var setPos bool
-	if td.start < len(lxms) && lxms[td.start].K.(*setsymbol.Symbol).Idx() == td.idx {
-		lx = lxms[td.start]
+	if td.start < len(op.lxms) && op.lxms[td.start].K.(*setsymbol.Symbol).Idx() == td.idx {
+		lx = op.lxms[td.start]
 	} else {
-		lx = lexeme.New(set.ByIdx(td.idx))
+		lx = lexeme.New(op.set.ByIdx(td.idx))
 		setPos = true
 	}
 	pn := &tree.PN{
 		Lexeme: lx,
 		C:      make([]*tree.PN, len(td.children)),
 	}
+	if op.nonterms[td.idx] {
+		pn.SetProduction(td.priority)
+	}
 	for i, c := range td.children {
-		ct := memo[c]
-		cpn := ct.toPN(lxms, memo, set)
+		ct, _ := op.get(c)
+		cpn := op.toPN(&ct)
 		cpn.P = pn
 		pn.C[i] = cpn
 	}
 	if setPos && len(pn.C) > 0 {
 		lx.L, lx.C = pn.C[0].Pos()
+		lx.O = pn.C[0].Offset()
 	}
+	pn.UpdateSpan()
 	return pn
 }
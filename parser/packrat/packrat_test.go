@@ -1,11 +1,17 @@
 package packrat
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/grammar"
 	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/pratt"
 	"github.com/adamcolton/parlex/tree"
 	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -339,3 +345,516 @@ func TestConstructor(t *testing.T) {
 	pc = Constructor
 	assert.NotNil(t, pc)
 }
+
+func TestSpans(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	s := "5 * (10 + 2)"
+	pn := New(grmr).Parse(lxr.Lex(s)).(*tree.PN)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, tree.Span{Start: 0, End: len(s)}, pn.S)
+		paren := pn.C[2]
+		assert.Equal(t, "(10 + 2)", paren.S.Src(s))
+		assert.Equal(t, "10", paren.C[1].C[0].S.Src(s))
+		l, c := paren.Pos()
+		assert.Equal(t, 1, l)
+		assert.Equal(t, 5, c)
+	}
+}
+
+func TestIndirectLeftRecursion(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    + /\+/
+    x
+    y
+    z
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		grammar, input, expected string
+	}{
+		{
+			grammar: `
+        A -> B x
+          -> y
+        B -> A z
+      `,
+			input: "y z x",
+			expected: `
+        A {
+          B {
+            A {
+              y: "y"
+            }
+            z: "z"
+          }
+          x: "x"
+        }
+      `,
+		},
+		{
+			grammar: `
+        A -> B + int
+          -> int
+        B -> C
+        C -> A
+      `,
+			input: "1+2",
+			expected: `
+        A {
+          B {
+            C {
+              A {
+                int: "1"
+              }
+            }
+          }
+          +: "+"
+          int: "2"
+        }
+      `,
+		},
+		{
+			// hidden left recursion through a nullable symbol
+			grammar: `
+        A -> N A x
+          -> y
+        N ->
+          -> z
+      `,
+			input: "y x x",
+			expected: `
+        A {
+          N
+          A {
+            N
+            A {
+              y: "y"
+            }
+            x: "x"
+          }
+          x: "x"
+        }
+      `,
+		},
+	}
+
+	for _, tc := range tests {
+		grmr, err := grammar.New(tc.grammar)
+		assert.NoError(t, err)
+		pn := New(grmr).Parse(lxr.Lex(tc.input))
+		if assert.NotNil(t, pn, tc.input) {
+			expected, _ := tree.New(tc.expected)
+			assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+		}
+	}
+}
+
+func TestParseErr(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p := New(grmr)
+
+	_, err = p.ParseErr(lxr.Lex("1 + (2 3)"))
+	if pe, ok := err.(*parlex.ParseError); assert.True(t, ok) {
+		assert.Equal(t, 4, pe.Pos)
+		assert.Equal(t, "3", pe.Lexeme.Value())
+		assert.Equal(t, "[) op]", fmt.Sprint(pe.Expected))
+		assert.Equal(t, `Could Not Parse 1:8) found int: 3, expected ), op`, pe.Error())
+	}
+
+	_, err = p.ParseErr(lxr.Lex("1 +"))
+	if pe, ok := err.(*parlex.ParseError); assert.True(t, ok) {
+		assert.Equal(t, 2, pe.Pos)
+		assert.Nil(t, pe.Lexeme)
+		assert.Equal(t, "[( int]", fmt.Sprint(pe.Expected))
+	}
+
+	_, err = parlex.Run("1 + (2 3)", lxr, p, nil)
+	assert.IsType(t, &parlex.ParseError{}, err)
+}
+
+// sexpr prints a binary expression tree with parenthesis
+func sexpr(pn parlex.ParseNode) string {
+	switch pn.Children() {
+	case 1:
+		return pn.Child(0).Value()
+	case 3:
+		if pn.Child(0).Kind().String() == "(" {
+			return sexpr(pn.Child(1))
+		}
+		return "(" + sexpr(pn.Child(0)) + " " + pn.Child(1).Value() + " " + sexpr(pn.Child(2)) + ")"
+	}
+	return pn.Value()
+}
+
+func TestPrecedence(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    + /\+/
+    - /-/
+    * /\*/
+    ^ /\^/
+    == /==/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    %nonassoc ==
+    %left + -
+    %left *
+    %right ^
+    E -> E + E
+      -> E - E
+      -> E * E
+      -> E ^ E
+      -> E == E
+      -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	prsr := New(grmr)
+
+	tests := map[string]string{
+		"1 + 2 * 3":         "(1 + (2 * 3))",
+		"1 * 2 + 3":         "((1 * 2) + 3)",
+		"1 - 2 - 3":         "((1 - 2) - 3)",
+		"1 - 2 + 3 - 4":     "(((1 - 2) + 3) - 4)",
+		"2 ^ 3 ^ 4":         "(2 ^ (3 ^ 4))",
+		"1 + 2 ^ 3 * 4":     "(1 + ((2 ^ 3) * 4))",
+		"(1 + 2) * 3":       "((1 + 2) * 3)",
+		"1 + 2 == 3 * 1":    "((1 + 2) == (3 * 1))",
+		"1 * 2 - 3 ^ 2 * 4": "((1 * 2) - ((3 ^ 2) * 4))",
+	}
+	for in, expected := range tests {
+		pn := prsr.Parse(lxr.Lex(in))
+		if assert.NotNil(t, pn, in) {
+			assert.Equal(t, expected, sexpr(pn), in)
+		}
+	}
+
+	assert.Nil(t, prsr.Parse(lxr.Lex("1 == 2 == 3")))
+	_, err = prsr.ParseErr(lxr.Lex("1 == 2 == 3"))
+	pe, ok := err.(*parlex.ParseError)
+	if assert.True(t, ok) {
+		assert.Equal(t, 3, pe.Pos)
+		if assert.NotNil(t, pe.Lexeme) {
+			assert.Equal(t, "==", pe.Lexeme.Value())
+		}
+	}
+	assert.False(t, parlex.IsIncomplete(err))
+}
+
+func TestParseContext(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E E
+      -> int
+  `)
+	assert.NoError(t, err)
+	p := New(grmr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pn, err := p.ParseContext(ctx, incLxr.Lex("1 2 3"))
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	cancel()
+	pn, err = p.ParseContext(ctx, incLxr.Lex("1 2 3"))
+	assert.Nil(t, pn)
+	assert.Equal(t, context.Canceled, err)
+
+	// this would take seconds to parse
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = p.ParseContext(ctx, incLxr.Lex(strings.Repeat("1 ", 300)))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestTrace(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	p := New(grmr)
+	p.Tracer = parlex.WriterTracer{Writer: &buf}
+	assert.Nil(t, p.Parse(incLxr.Lex("1 +")))
+	assert.Equal(t, `memo-miss E @0
+attempt E -> E op E @0
+memo-hit E @0
+attempt E -> int @0
+match int @0:1
+match E -> int @0:1
+match op @1:2
+memo-miss E @2
+attempt E -> E op E @2
+memo-hit E @2
+attempt E -> int @2
+fail int @2
+`, buf.String())
+}
+
+func TestTraceDepth(t *testing.T) {
+	grmr, err := grammar.New(`
+    E -> T op T
+    T -> int
+  `)
+	assert.NoError(t, err)
+	m := &parlex.Metrics{}
+	p := New(grmr)
+	p.Tracer = m
+	assert.NotNil(t, p.Parse(incLxr.Lex("1 + 2")))
+	assert.Equal(t, 2, m.MaxDepth)
+	assert.Equal(t, 3, m.MemoMisses)
+	assert.Equal(t, 3, m.Attempts)
+}
+
+func TestParseFrom(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p := New(grmr)
+
+	pn, err := p.ParseFrom("T", lxr.Lex("(1+2)"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "T", pn.Kind().String())
+		assert.Equal(t, 3, pn.Children())
+	}
+
+	_, err = p.ParseFrom("T", lxr.Lex("1+2"))
+	if assert.Error(t, err) {
+		assert.Equal(t, 1, err.(*parlex.ParseError).Pos)
+	}
+
+	_, err = p.ParseFrom("X", lxr.Lex("1"))
+	assert.EqualError(t, err, "Unknown Non-Terminal: X")
+}
+
+func TestParsePrefix(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    E -> T op E
+      -> T
+    T -> ( E )
+      -> int
+  `)
+	assert.NoError(t, err)
+	p := New(grmr)
+
+	pn, rest, err := p.ParsePrefix(lxr.Lex("1+(2*3) 4 5"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "E", pn.Kind().String())
+		assert.Equal(t, 3, pn.Children())
+		assert.Equal(t, "[int: 4, int: 5]", parlex.LexemeString(rest...))
+	}
+
+	pn, rest, err = p.ParsePrefix(lxr.Lex("1+2"))
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	assert.Len(t, rest, 0)
+
+	lxs := lxr.Lex(") 1")
+	pn, rest, err = p.ParsePrefix(lxs)
+	assert.Error(t, err)
+	assert.Nil(t, pn)
+	assert.Equal(t, lxs, rest)
+}
+
+func TestIslands(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    keywords print
+    ( /\(/
+    ) /\)/
+    = /=/
+    ; /;/
+    + /\+/
+    * /\*/
+    int /\d+/
+    ident /[a-z]\w*/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    Prog -> Stmt Prog
+         ->
+    Stmt -> ident = Expr ;
+         -> print Expr ;
+  `)
+	assert.NoError(t, err)
+	expr, err := pratt.New("Expr", `
+    %operand int ident
+    %group ( )
+    %left +
+    %left *
+  `)
+	assert.NoError(t, err)
+	p := New(grmr)
+	p.Islands = map[string]parlex.PrefixParser{"Expr": expr}
+
+	src := "x = 1+2*3; print x;"
+	pn, err := p.ParseErr(lxr.Lex(src))
+	if assert.NoError(t, err) {
+		expected, _ := tree.New(`
+      Prog {
+        Stmt {
+          ident: "x"
+          =: "="
+          Expr {
+            int: "1"
+            +: "+"
+            Expr {
+              int: "2"
+              *: "*"
+              int: "3"
+            }
+          }
+          ;: ";"
+        }
+        Prog {
+          Stmt {
+            print: "print"
+            Expr {
+              ident: "x"
+            }
+            ;: ";"
+          }
+          Prog
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+		e := pn.Child(0).Child(2)
+		assert.Equal(t, tree.Span{Start: 4, End: 9}, e.(*tree.PN).S)
+		l, c := e.Child(2).Child(2).Pos()
+		assert.Equal(t, 1, l)
+		assert.Equal(t, 9, c)
+		l, c = pn.Child(1).Child(0).Child(1).Pos()
+		assert.Equal(t, 1, l)
+		assert.Equal(t, 18, c)
+	}
+
+	_, err = p.ParseErr(lxr.Lex("x = ;"))
+	assert.EqualError(t, err, "Could Not Parse 1:5) found ;: ;, expected Expr")
+	_, err = p.ParseErr(lxr.Lex("x = 1 +;"))
+	assert.EqualError(t, err, "Could Not Parse 1:7) found +: +, expected ;")
+
+	// the islands are kept when entries are evicted and derived again
+	p.MemoBudget = 2
+	pn2, err := p.ParseErr(lxr.Lex(src))
+	if assert.NoError(t, err) {
+		assert.Equal(t, pn.(*tree.PN).String(), pn2.(*tree.PN).String())
+	}
+	p.MemoBudget = 0
+
+	pn, err = p.ParseFrom("Stmt", lxr.Lex("print (1+2)*3;"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, pn.Child(1).Children())
+	}
+
+	inc := p.Incremental(lxr)
+	_, err = inc.ParseSource(src)
+	assert.NoError(t, err)
+	pn, err = inc.Reparse(Edit{Start: 4, End: 5, Text: "y*1"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "y", pn.Child(0).Child(2).Child(0).Child(0).Value())
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	assert.NoError(t, lxr.UseDFA())
+	p := New(incGrmr)
+	p.MemoBudget = 8
+	rdcr := tree.Reducer{
+		"L": tree.PromoteSingleChild,
+		"E": tree.PromoteSingleChild,
+		"T": tree.PromoteSingleChild,
+	}
+
+	srcs := []string{"1+2", "(1+2)*3", "1 2 (3*4) 5-6", "((1))", "1+", "1+(2*(3-4))/5"}
+	expected := make([]string, len(srcs))
+	for i, src := range srcs {
+		pn, err := p.ParseErr(lxr.Lex(src))
+		if err != nil {
+			expected[i] = err.Error()
+		} else {
+			expected[i] = rdcr.Reduce(pn).(*tree.PN).String()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				i := (g + n) % len(srcs)
+				pn, err := p.ParseErr(lxr.Lex(srcs[i]))
+				if err != nil {
+					assert.Equal(t, expected[i], err.Error(), srcs[i])
+					continue
+				}
+				assert.Equal(t, expected[i], rdcr.Reduce(pn).(*tree.PN).String(), srcs[i])
+			}
+		}(g)
+	}
+	wg.Wait()
+}
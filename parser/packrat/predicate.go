@@ -0,0 +1,115 @@
+package packrat
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+)
+
+// derivation identifies a treeDef by its production and children. The children
+// are relative to the start of the tree so that a derivation can be moved.
+type derivation struct {
+	treeKey
+	priority int
+	children string
+}
+
+func derivationOf(td *treeDef) derivation {
+	children := make([]treeKey, len(td.children))
+	for i, ck := range td.children {
+		ck.start -= td.start
+		ck.end -= td.start
+		children[i] = ck
+	}
+	return derivation{
+		treeKey:  td.treeKey,
+		priority: td.priority,
+		children: fmt.Sprint(children),
+	}
+}
+
+// loadPredicates finds the predicate of every production. If none of them has
+// a predicate, op.preds is left nil.
+func (op *prOp) loadPredicates() {
+	pg := op.grmr.(parlex.PredicateGrammar)
+	preds := make([][]parlex.Predicate, op.set.Size())
+	var found bool
+	for _, nonterm := range op.grmr.NonTerminals() {
+		prods := op.grmr.Productions(nonterm)
+		ntPreds := make([]parlex.Predicate, prods.Productions())
+		for i := range ntPreds {
+			ntPreds[i] = pg.Predicate(nonterm, i)
+			found = found || ntPreds[i] != nil
+		}
+		preds[op.set.Symbol(nonterm).Idx()] = ntPreds
+	}
+	if found {
+		op.preds = preds
+		op.rejected = make(map[derivation]bool)
+	}
+}
+
+// predOf returns the predicate of the production of a treeDef.
+func (op *prOp) predOf(td *treeDef) parlex.Predicate {
+	if !op.nonterms[td.idx] {
+		return nil
+	}
+	return op.preds[td.idx][td.priority]
+}
+
+// accepts checks the predicate of a treeDef against the trees its children
+// currently hold.
+func (op *prOp) accepts(td *treeDef) bool {
+	pred := op.predOf(td)
+	if pred == nil {
+		return true
+	}
+	if op.rejected[derivationOf(td)] {
+		return false
+	}
+	children := make([]parlex.ParseNode, len(td.children))
+	for i, ck := range td.children {
+		c, _ := op.get(ck)
+		children[i] = op.toPN(&c)
+	}
+	return pred(children)
+}
+
+// recheck checks the predicates in a tree against its final children, from the
+// bottom up. It returns the first derivation that fails.
+func (op *prOp) recheck(td *treeDef) (derivation, bool) {
+	for _, ck := range td.children {
+		c, _ := op.get(ck)
+		if d, failed := op.recheck(&c); failed {
+			return d, true
+		}
+	}
+	if !op.accepts(td) {
+		return derivationOf(td), true
+	}
+	return derivation{}, false
+}
+
+// reset clears everything but the rejected derivations so the lexemes can be
+// parsed again.
+func (op *prOp) reset() {
+	op.memo = make(map[treeKey]treeDef)
+	op.markers = make(map[treeMarker][]treeKey)
+	op.partials = make(map[treeMarker][]treePartial)
+	op.queued = make(map[treeMarker]bool)
+	op.stack = nil
+	op.live = 0
+	if op.islandNodes != nil {
+		op.islandNodes = make(map[treeKey]parlex.ParseNode)
+	}
+	op.furthest.end, op.furthest.req = 0, 0
+	op.furthest.nonAssoc, op.furthest.nonAssocEnd = 0, 0
+	for i := range op.furthest.expected {
+		op.furthest.expected[i] = false
+	}
+	if op.deps != nil {
+		op.deps = &deps{
+			reach: make(map[treeMarker]int),
+			rdeps: make(map[treeMarker][]treeMarker),
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package packrat
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var kwLxr = parlex.MustLexer(simplelexer.New(`
+    word /[a-z]+/
+    space /\s+/ -
+  `))
+
+// kwGrammar has one kind of lexeme, the predicates decide which words are the
+// keyword "if".
+func kwGrammar(t *testing.T) *grammar.Grammar {
+	g, err := grammar.New(`
+    S    -> S Stmt
+         -> Stmt
+    Stmt -> Kw word
+         -> word
+    Kw   -> word
+  `)
+	assert.NoError(t, err)
+	isIf := func(children []parlex.ParseNode) bool { return children[0].Value() == "if" }
+	notIf := func(children []parlex.ParseNode) bool { return !isIf(children) }
+	word := stringsymbol.Symbol("word")
+	assert.NoError(t, g.AddPredicate(stringsymbol.Symbol("Kw"), stringsymbol.Production{word}, isIf))
+	assert.NoError(t, g.AddPredicate(stringsymbol.Symbol("Stmt"), stringsymbol.Production{word}, notIf))
+	return g
+}
+
+// stmts describes the statements in a tree from kwGrammar.
+func stmts(pn parlex.ParseNode) []string {
+	stmt := func(pn parlex.ParseNode) string {
+		if pn.Children() == 2 {
+			return pn.Child(0).Child(0).Value() + " " + pn.Child(1).Value()
+		}
+		return pn.Child(0).Value()
+	}
+	var out []string
+	for pn.Children() == 2 {
+		out = append([]string{stmt(pn.Child(1))}, out...)
+		pn = pn.Child(0)
+	}
+	return append([]string{stmt(pn.Child(0))}, out...)
+}
+
+func TestPredicate(t *testing.T) {
+	p := New(kwGrammar(t))
+
+	pn, err := p.ParseErr(kwLxr.Lex("if a b if c"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, []string{"if a", "b", "if c"}, stmts(pn))
+	}
+
+	pn, err = p.ParseErr(kwLxr.Lex("a if"))
+	assert.Nil(t, pn)
+	assert.Error(t, err)
+}
+
+func TestPredicateRecheck(t *testing.T) {
+	g, err := grammar.New(`
+    E -> E op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	// the left operand cannot be an operation, which makes op right
+	// associative
+	err = g.AddPredicate(stringsymbol.Symbol("E"), stringsymbol.Production{"E", "op", "E"}, func(children []parlex.ParseNode) bool {
+		return children[0].Children() == 1
+	})
+	assert.NoError(t, err)
+	p := New(g)
+
+	for in, expected := range map[string]string{
+		"1 - 2":         "(1 - 2)",
+		"1 - 2 - 3":     "(1 - (2 - 3))",
+		"1 - 2 - 3 - 4": "(1 - (2 - (3 - 4)))",
+	} {
+		pn := p.Parse(incLxr.Lex(in))
+		if assert.NotNil(t, pn, in) {
+			assert.Equal(t, expected, sexpr(pn), in)
+		}
+	}
+}
+
+func TestPredicateReplacedChild(t *testing.T) {
+	lxr := parlex.MustLexer(simplelexer.New(`x /x/`))
+	g, err := grammar.New(`
+    S -> A
+      -> B B
+    A -> B B
+      -> C
+    B -> x
+    C -> x x
+  `)
+	assert.NoError(t, err)
+	// S -> A can accept A -> C before it is replaced by A -> B B, which has a
+	// higher priority
+	err = g.AddPredicate(stringsymbol.Symbol("S"), stringsymbol.Production{"A"}, func(children []parlex.ParseNode) bool {
+		return children[0].Child(0).Kind().String() == "C"
+	})
+	assert.NoError(t, err)
+
+	pn := New(g).Parse(lxr.Lex("xx"))
+	if assert.NotNil(t, pn) && assert.Equal(t, 2, pn.Children()) {
+		assert.Equal(t, "B", pn.Child(0).Kind().String())
+	}
+}
+
+func TestPredicateIncremental(t *testing.T) {
+	p := New(kwGrammar(t))
+	inc := p.Incremental(kwLxr)
+	_, err := inc.ParseSource("if a b")
+	assert.NoError(t, err)
+
+	// the kinds do not change but the value changes what the predicates accept
+	for _, e := range []Edit{
+		{Start: 0, End: 2, Text: "of"},
+		{Start: 5, End: 6, Text: "if"},
+		{Start: 0, End: 2, Text: "if"},
+		{Start: 7, End: 7, Text: " b"},
+	} {
+		pn, err := inc.Reparse(e)
+		full, fullErr := p.ParseErr(kwLxr.Lex(inc.Source()))
+		assert.Equal(t, fullErr, err, inc.Source())
+		if full == nil {
+			assert.Nil(t, pn, inc.Source())
+			continue
+		}
+		if assert.NotNil(t, pn, inc.Source()) {
+			assert.Equal(t, full.(*tree.PN).String(), pn.(*tree.PN).String(), inc.Source())
+		}
+	}
+	assert.Equal(t, "if a if b", inc.Source())
+}
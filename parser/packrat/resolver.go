@@ -0,0 +1,34 @@
+package packrat
+
+import (
+	"github.com/adamcolton/parlex/tree"
+)
+
+// Resolver chooses between two trees for a non-terminal that cover the same
+// lexemes and returns the index of the one to keep. Any other return leaves
+// the choice to the parser, which prefers the production declared first.
+// Production returns the index of the production that built each candidate.
+//
+// The trees are offered as they are found, so the first candidate is the tree
+// kept so far and the second is a new one. Each call builds both trees, so a
+// Resolver is best kept to the non-terminals that are ambiguous. The children
+// are the best trees found so far and can still be replaced by trees with a
+// higher priority.
+type Resolver func(candidates []*tree.PN) int
+
+// prefer returns true if the new tree should replace the old tree for the same
+// span. The Resolver for the symbol decides if there is one and it chooses.
+func (op *prOp) prefer(td, old *treeDef) bool {
+	if op.resolvers != nil && op.resolvers[td.idx] != nil {
+		if old.evicted {
+			*old, _ = op.get(old.treeKey)
+		}
+		switch op.resolvers[td.idx]([]*tree.PN{op.toPN(old), op.toPN(td)}) {
+		case 0:
+			return false
+		case 1:
+			return true
+		}
+	}
+	return td.comparePriority(old, op) == 1
+}
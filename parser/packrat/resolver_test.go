@@ -0,0 +1,85 @@
+package packrat
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func leaves(pn parlex.ParseNode) int {
+	if pn.Children() == 0 {
+		return 1
+	}
+	n := 0
+	for i := 0; i < pn.Children(); i++ {
+		n += leaves(pn.Child(i))
+	}
+	return n
+}
+
+func TestResolvers(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    + /\+/
+    * /\*/
+    int /\d+/
+    space /\s+/ -
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    E -> E + E
+      -> E * E
+      -> int
+  `)
+	assert.NoError(t, err)
+
+	calls := 0
+	p := New(grmr)
+	p.Resolvers = map[string]Resolver{
+		"E": func(candidates []*tree.PN) int {
+			calls++
+			// the reverse of the order of the productions; * binds less
+			// tightly than + so it goes on top
+			p0, p1 := candidates[0].Production(), candidates[1].Production()
+			if p0 != p1 {
+				if p0 > p1 {
+					return 0
+				}
+				return 1
+			}
+			// right associative, so the left operand is the shortest
+			if leaves(candidates[0].C[0]) <= leaves(candidates[1].C[0]) {
+				return 0
+			}
+			return 1
+		},
+	}
+
+	tests := map[string]string{
+		"1 + 2 * 3":         "((1 + 2) * 3)",
+		"1 * 2 + 3 * 4":     "(1 * ((2 + 3) * 4))",
+		"1 + 2 + 3":         "(1 + (2 + 3))",
+		"1 * 2 * 3 + 4 * 5": "(1 * (2 * ((3 + 4) * 5)))",
+	}
+	for in, expected := range tests {
+		pn := p.Parse(lxr.Lex(in))
+		if assert.NotNil(t, pn, in) {
+			assert.Equal(t, expected, sexpr(pn), in)
+		}
+	}
+	assert.True(t, calls > 0)
+
+	// a resolver that does not choose leaves the choice to the parser
+	in := "1 * 2 + 3 + 4"
+	expected := sexpr(New(grmr).Parse(lxr.Lex(in)))
+	p.Resolvers["E"] = func([]*tree.PN) int { return -1 }
+	assert.Equal(t, expected, sexpr(p.Parse(lxr.Lex(in))))
+
+	pn, err := p.ParseFrom("E", lxr.Lex("1 + 2"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pn.(*tree.PN).Production())
+	assert.Equal(t, 2, pn.(*tree.PN).C[0].Production())
+	assert.Equal(t, -1, pn.(*tree.PN).C[1].Production())
+}
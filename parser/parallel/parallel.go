@@ -0,0 +1,283 @@
+// Package parallel parses input that is a sequence of independent units, like
+// the records of a data file or the top level declarations of a source file,
+// on many goroutines at once.
+//
+// The lexemes are split into units at delimiter lexemes, each unit is parsed
+// on its own by a pool of workers and the trees of the units are stitched
+// together as the children of a single root node. Because the units do not
+// depend on each other, large inputs parse in close to linear time with the
+// number of cores.
+//
+// The delimiters are kinds of lexemes, not a production of the grammar, so the
+// split does not parse. A unit that ends in the middle of a derivation of the
+// start non-terminal, like "do { ... }" before "while x;", is joined with the
+// next unit and parsed again, so only the boundaries after a complete
+// derivation are kept.
+package parallel
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/tree"
+	"runtime"
+	"sync"
+)
+
+// DefaultRootString is the kind that will be assigned to the root node
+var DefaultRootString = "Units"
+
+// Parallel wraps a Parser to parse units on many goroutines. Each unit is
+// parsed from the non-terminal Start, which needs the Parser to be a
+// parlex.FromParser, or from the start symbol if Start is empty. Root is the
+// kind of the node that holds the trees of the units. Workers is the number of
+// units parsed at once; if it is 0, runtime.GOMAXPROCS is used.
+//
+// The Parser is shared by the workers so it must be safe for concurrent use.
+type Parallel struct {
+	parlex.Parser
+	*Splitter
+	Start   string
+	Root    string
+	Workers int
+}
+
+// New wraps a parser to parse units from the start non-terminal. A unit ends
+// after each lexeme with one of the end kinds.
+func New(parser parlex.Parser, start string, end ...string) *Parallel {
+	return &Parallel{
+		Parser:   parser,
+		Splitter: NewSplitter(end...),
+		Start:    start,
+		Root:     DefaultRootString,
+	}
+}
+
+// Splitter splits lexemes into units. A unit ends after each lexeme with a kind
+// in End. Lexemes between a kind in Open and the matching kind in Close, like
+// braces, are nested and a unit does not end inside them. Any lexemes after the
+// last delimiter are the last unit.
+type Splitter struct {
+	End   map[string]bool
+	Open  map[string]string
+	Close map[string]bool
+}
+
+// NewSplitter returns a Splitter that ends a unit after each lexeme with one of
+// the end kinds.
+func NewSplitter(end ...string) *Splitter {
+	s := &Splitter{
+		End:   make(map[string]bool),
+		Open:  make(map[string]string),
+		Close: make(map[string]bool),
+	}
+	for _, e := range end {
+		s.End[e] = true
+	}
+	return s
+}
+
+// Nest keeps units from ending between lexemes of the open and close kinds.
+// It returns the Splitter so calls can be chained.
+func (s *Splitter) Nest(open, close string) *Splitter {
+	s.Open[open] = close
+	s.Close[close] = true
+	return s
+}
+
+// Split the lexemes into units. The units are slices of lexemes.
+func (s *Splitter) Split(lexemes []parlex.Lexeme) [][]parlex.Lexeme {
+	var units [][]parlex.Lexeme
+	var stack []string
+	start := 0
+	for i, lx := range lexemes {
+		k := lx.Kind().String()
+		if c, ok := s.Open[k]; ok {
+			stack = append(stack, c)
+			continue
+		}
+		if l := len(stack); l > 0 {
+			if !s.Close[k] || stack[l-1] != k {
+				continue
+			}
+			stack = stack[:l-1]
+			if l > 1 {
+				continue
+			}
+		}
+		if s.End[k] {
+			units = append(units, lexemes[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(lexemes) {
+		units = append(units, lexemes[start:])
+	}
+	return units
+}
+
+// Parse fulfills parlex.Parser.
+func (p *Parallel) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, err := p.ParseContext(context.Background(), lexemes)
+	if err != nil {
+		return nil
+	}
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. If more than one unit fails, the error
+// of the first one is returned, the same as parsing them one after another.
+// The Pos of a *parlex.ParseError is the index in all the lexemes. Because a
+// unit that ends early is joined with the next, only the last unit can fail
+// as incomplete.
+func (p *Parallel) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return p.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops if the context is done. The context is passed to the Parser if it is a
+// parlex.ContextParser and Start is empty.
+func (p *Parallel) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	units := p.Split(lexemes)
+	op := &parseOp{
+		Parallel: p,
+		ctx:      ctx,
+		units:    units,
+		trees:    make([]*tree.PN, len(units)),
+		errs:     make([]error, len(units)),
+	}
+	for {
+		op.failed = len(op.units)
+		op.run()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if op.failed == len(op.units) || !op.join() {
+			break
+		}
+	}
+	if op.failed < len(op.units) {
+		return nil, op.shift(op.errs[op.failed])
+	}
+
+	root := &tree.PN{
+		Lexeme: lexeme.String(p.Root),
+		C:      op.trees,
+	}
+	for _, c := range root.C {
+		c.P = root
+	}
+	root.UpdateSpan()
+	return root, nil
+}
+
+type parseOp struct {
+	*Parallel
+	ctx   context.Context
+	units [][]parlex.Lexeme
+	trees []*tree.PN
+	errs  []error
+
+	// failed is the index of the first unit that failed, units after it are
+	// not parsed
+	mux    sync.Mutex
+	failed int
+}
+
+func (op *parseOp) run() {
+	workers := op.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(op.units) {
+		workers = len(op.units)
+	}
+	idxs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idxs {
+				op.parse(i)
+			}
+		}()
+	}
+	for i, t := range op.trees {
+		if op.ctx.Err() != nil {
+			break
+		}
+		// units parsed before a join are not parsed again
+		if t == nil {
+			idxs <- i
+		}
+	}
+	close(idxs)
+	wg.Wait()
+}
+
+func (op *parseOp) parse(i int) {
+	op.mux.Lock()
+	skip := i > op.failed
+	op.mux.Unlock()
+	if skip || op.ctx.Err() != nil {
+		return
+	}
+
+	var pn parlex.ParseNode
+	var err error
+	if op.Start == "" {
+		pn, err = parlex.ParseContext(op.ctx, op.Parser, op.units[i])
+	} else {
+		pn, err = parlex.ParseFrom(op.Parser, op.Start, op.units[i])
+	}
+	if err == nil && pn == nil {
+		err = parlex.ErrCouldNotParse
+	}
+	if err != nil {
+		op.errs[i] = err
+		op.mux.Lock()
+		if i < op.failed {
+			op.failed = i
+		}
+		op.mux.Unlock()
+		return
+	}
+
+	t, ok := pn.(*tree.PN)
+	if !ok {
+		t = tree.Reducer{}.RawReduce(pn)
+	}
+	op.trees[i] = t
+}
+
+// join merges the unit that failed with the next one if it failed because it
+// ended while more was expected. It returns false if the error is not from
+// splitting the unit too soon.
+func (op *parseOp) join() bool {
+	i := op.failed
+	if i+1 == len(op.units) || !parlex.IsIncomplete(op.errs[i]) {
+		return false
+	}
+	// the units are consecutive slices of the same lexemes
+	op.units[i] = op.units[i][:len(op.units[i])+len(op.units[i+1])]
+	op.units = append(op.units[:i+1], op.units[i+2:]...)
+	op.trees = append(op.trees[:i+1], op.trees[i+2:]...)
+	op.errs = append(op.errs[:i+1], op.errs[i+2:]...)
+	op.trees[i], op.errs[i] = nil, nil
+	return true
+}
+
+// shift moves the position of a *parlex.ParseError from the failed unit to all
+// the lexemes. The rest of the error is kept.
+func (op *parseOp) shift(err error) error {
+	pe, ok := err.(*parlex.ParseError)
+	if !ok {
+		return err
+	}
+	shifted := *pe
+	for _, u := range op.units[:op.failed] {
+		shifted.Pos += len(u)
+	}
+	return &shifted
+}
@@ -0,0 +1,188 @@
+package parallel
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const lexerRules = `
+  do /do/
+  while /while/
+  id /[a-z]+/
+  int /\d+/
+  = /=/
+  ; /;/
+  { /\{/
+  } /\}/
+  op /[+\-]/
+  space /\s+/ -
+`
+
+const grammarRules = `
+  Stmts -> Stmt Stmts
+        ->
+  Stmt  -> id = E ;
+        -> id { Stmts }
+        -> do { Stmts } while E ;
+  E     -> int op E
+        -> int
+`
+
+func setup(t *testing.T) (*simplelexer.Lexer, *grammar.Grammar) {
+	lxr, err := simplelexer.New(lexerRules)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(grammarRules)
+	assert.NoError(t, err)
+	return lxr, grmr
+}
+
+func TestSplit(t *testing.T) {
+	lxr, _ := setup(t)
+	s := NewSplitter(";", "}").Nest("{", "}")
+
+	units := s.Split(lxr.Lex("a = 1; b { c = 2; d = 3; } e = 4"))
+	var strs []string
+	for _, u := range units {
+		var vals []string
+		for _, lx := range u {
+			vals = append(vals, lx.Value())
+		}
+		strs = append(strs, strings.Join(vals, " "))
+	}
+	assert.Equal(t, []string{
+		"a = 1 ;",
+		"b { c = 2 ; d = 3 ; }",
+		"e = 4",
+	}, strs)
+
+	assert.Len(t, s.Split(nil), 0)
+}
+
+func TestParse(t *testing.T) {
+	lxr, grmr := setup(t)
+	p := New(packrat.New(grmr), "Stmt", ";", "}")
+	p.Nest("{", "}")
+	p.Workers = 3
+
+	var src strings.Builder
+	for i := 0; i < 50; i++ {
+		src.WriteString("a = 1 + 2; b { c = 3; } ")
+	}
+	lxs := lxr.Lex(src.String())
+	pn, err := p.ParseErr(lxs)
+	if !assert.NoError(t, err) {
+		return
+	}
+	root := pn.(*tree.PN)
+	assert.Equal(t, "Units", root.Kind().String())
+	assert.Len(t, root.C, 100)
+	assert.Equal(t, tree.Span{Start: 0, End: len(strings.TrimSpace(src.String()))}, root.S)
+
+	seq := packrat.New(grmr)
+	for i, c := range root.C {
+		assert.True(t, c.P == root)
+		unit := "a = 1 + 2;"
+		if i%2 == 1 {
+			unit = "b { c = 3; }"
+		}
+		expected, err := seq.ParseFrom("Stmt", lxr.Lex(unit))
+		assert.NoError(t, err)
+		assert.Equal(t, expected.(*tree.PN).String(), c.String())
+	}
+
+	pn, err = p.ParseErr(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pn.Children())
+}
+
+func TestParseErr(t *testing.T) {
+	lxr, grmr := setup(t)
+	p := New(packrat.New(grmr), "Stmt", ";")
+	p.Workers = 2
+
+	lxs := lxr.Lex("a = 1; b = 2; c = = 3; d = 4; e 5;")
+	pn, err := p.ParseErr(lxs)
+	assert.Nil(t, pn)
+	pe, ok := err.(*parlex.ParseError)
+	if assert.True(t, ok) {
+		// the first error, not the one in the last unit
+		assert.Equal(t, 10, pe.Pos)
+		assert.Equal(t, "=", pe.Lexeme.Kind().String())
+		if assert.Len(t, pe.Expected, 1) {
+			assert.Equal(t, "int", pe.Expected[0].String())
+		}
+	}
+	assert.Nil(t, p.Parse(lxs))
+
+	// the second unit is "b { c = 2 ;", it is joined with the units after it
+	// and the input ends before the block is closed
+	_, err = p.ParseErr(lxr.Lex("a = 1; b { c = 2; d = 3;"))
+	pe, ok = err.(*parlex.ParseError)
+	if assert.True(t, ok) {
+		assert.Equal(t, 14, pe.Pos)
+		assert.Nil(t, pe.Lexeme)
+		assert.True(t, pe.Incomplete())
+	}
+
+	_, err = p.ParseErr(lxr.Lex("a = 1; b { c = 2; d = = 3; } e = 4;"))
+	pe, ok = err.(*parlex.ParseError)
+	if assert.True(t, ok) {
+		assert.Equal(t, 12, pe.Pos)
+		assert.Equal(t, "=", pe.Lexeme.Kind().String())
+	}
+	assert.False(t, parlex.IsIncomplete(err))
+}
+
+func TestJoin(t *testing.T) {
+	lxr, grmr := setup(t)
+	p := New(packrat.New(grmr), "Stmt", ";", "}")
+	p.Nest("{", "}")
+	p.Workers = 2
+
+	// the "}" ends a unit in the middle of the do statement
+	src := "a = 1; do { b = 2; } while 3; c = 4; do { d = 5; } while 6;"
+	pn, err := p.ParseErr(lxr.Lex(src))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 4, pn.Children())
+	root := pn.(*tree.PN)
+	for _, c := range root.C {
+		assert.True(t, c.P == root)
+	}
+	assert.Equal(t, "do", root.C[1].C[0].Kind().String())
+
+	_, err = p.ParseErr(lxr.Lex("a = 1; do { b = 2; } c = 4;"))
+	pe, ok := err.(*parlex.ParseError)
+	if assert.True(t, ok) {
+		assert.Equal(t, 11, pe.Pos)
+		assert.Equal(t, "c", pe.Lexeme.Value())
+	}
+	assert.False(t, parlex.IsIncomplete(err))
+}
+
+func TestParseContext(t *testing.T) {
+	lxr, grmr := setup(t)
+	p := New(packrat.New(grmr), "", ";")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pn, err := p.ParseContext(ctx, lxr.Lex("a = 1; b = 2;"))
+	assert.Nil(t, pn)
+	assert.Equal(t, context.Canceled, err)
+
+	// without Start, each unit is parsed from the start symbol
+	pn, err = p.ParseContext(context.Background(), lxr.Lex("a = 1; b = 2;"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, 2, pn.Children())
+		assert.Equal(t, "Stmts", pn.Child(0).Kind().String())
+	}
+}
@@ -0,0 +1,434 @@
+// Package peg implements a parser that reads a grammar as a parsing expression
+// grammar.
+//
+// The productions of a non-terminal are tried in the order they are defined
+// and the first one that matches is used; once a non-terminal matches at a
+// position, the parser never comes back to try a different production there.
+// So with
+//
+//	A -> x
+//	  -> x y
+//
+// A never matches x y, the first production always wins. Optional and
+// repeated symbols, like the ones regexgram adds, are greedy.
+//
+// Symbols in a production that start with & or ! are syntactic predicates.
+// &X matches if X matches at the current position and !X matches if it does
+// not. Neither consumes any lexemes or adds a child to the tree.
+//
+// The symbol ~ is a cut. Once a production passes a cut, it is committed: if
+// the rest of the production fails, the non-terminal fails without trying the
+// productions after it. A cut placed after the part of a production that
+// identifies it stops useless backtracking and keeps the parse error close to
+// the real problem.
+//
+// Results are memoized so the parse takes linear time. Left recursion is not
+// allowed, New returns ErrLeftRecursion.
+package peg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/adamcolton/parlex/tree"
+)
+
+// Cut is the symbol that commits a production to the choice it has made.
+const Cut = "~"
+
+// ErrLeftRecursion is returned if the grammar is left recursive, including
+// through nullable symbols and predicates.
+var ErrLeftRecursion = errors.New("PEG parser cannot handle left recursion")
+
+type itemKind byte
+
+const (
+	symbolItem itemKind = iota
+	andItem
+	notItem
+	cutItem
+)
+
+type item struct {
+	kind itemKind
+	idx  int
+}
+
+type production []item
+
+// Peg is a parsing expression grammar parser. If Tracer is set, every
+// production that is tried, matched or backtracked, every use of the memo table
+// and every terminal that does not match is sent to it.
+type Peg struct {
+	parlex.Grammar
+	Tracer parlex.Tracer
+	set    *setsymbol.Set
+	// prods is indexed by symbol, the non-terminals are loaded first so any
+	// index past the end is a terminal
+	prods [][]production
+}
+
+// New returns a PEG parser for the grammar.
+func New(grmr parlex.Grammar) (*Peg, error) {
+	nts := grmr.NonTerminals()
+	if len(nts) == 0 {
+		return nil, parlex.ErrBadGrammar
+	}
+	p := &Peg{
+		Grammar: grmr,
+		set:     setsymbol.New(),
+		prods:   make([][]production, len(nts)),
+	}
+	for _, nt := range nts {
+		p.set.Symbol(nt)
+	}
+	for i, nt := range nts {
+		for pi := grmr.Productions(nt).Iter(); pi.Next(); {
+			prod := make(production, 0, pi.Production.Symbols())
+			for j := pi.Iter(); j.Next(); {
+				prod = append(prod, p.item(j.Symbol.String()))
+			}
+			p.prods[i] = append(p.prods[i], prod)
+		}
+	}
+	if p.leftRecursive() {
+		return nil, ErrLeftRecursion
+	}
+	return p, nil
+}
+
+// Constructor fulfills parlex.ParserConstructor
+func Constructor(grmr parlex.Grammar) (parlex.Parser, error) {
+	return New(grmr)
+}
+
+func (p *Peg) item(s string) item {
+	switch {
+	case s == Cut:
+		return item{kind: cutItem}
+	case len(s) > 1 && s[0] == '&':
+		return item{kind: andItem, idx: p.set.Str(s[1:]).Idx()}
+	case len(s) > 1 && s[0] == '!':
+		return item{kind: notItem, idx: p.set.Str(s[1:]).Idx()}
+	}
+	return item{kind: symbolItem, idx: p.set.Str(s).Idx()}
+}
+
+func (p *Peg) isNT(idx int) bool { return idx < len(p.prods) }
+
+// nullable finds the non-terminals that can match without consuming any
+// lexemes.
+func (p *Peg) nullable() []bool {
+	nullable := make([]bool, len(p.prods))
+	for changed := true; changed; {
+		changed = false
+		for nt, prods := range p.prods {
+			if nullable[nt] {
+				continue
+			}
+			for _, prod := range prods {
+				if p.prodNullable(prod, nullable) {
+					nullable[nt], changed = true, true
+					break
+				}
+			}
+		}
+	}
+	return nullable
+}
+
+func (p *Peg) prodNullable(prod production, nullable []bool) bool {
+	for _, it := range prod {
+		if it.kind == symbolItem && !(p.isNT(it.idx) && nullable[it.idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// leftRecursive checks if a non-terminal can be called again at the same
+// position. Predicates and nullable symbols do not consume lexemes, so the
+// symbols after them are also checked.
+func (p *Peg) leftRecursive() bool {
+	nullable := p.nullable()
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]byte, len(p.prods))
+	var visit func(nt int) bool
+	visit = func(nt int) bool {
+		switch state[nt] {
+		case visiting:
+			return true
+		case visited:
+			return false
+		}
+		state[nt] = visiting
+		for _, prod := range p.prods[nt] {
+			for _, it := range prod {
+				if it.kind != cutItem && p.isNT(it.idx) && visit(it.idx) {
+					return true
+				}
+				if it.kind == symbolItem && !(p.isNT(it.idx) && nullable[it.idx]) {
+					break
+				}
+			}
+		}
+		state[nt] = visited
+		return false
+	}
+	for nt := range p.prods {
+		if visit(nt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse fulfills parlex.Parser.
+func (p *Peg) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := p.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. If the parse fails, a *parlex.ParseError
+// is returned with the furthest position a lexeme failed to match and the
+// kinds of lexemes that were tried there.
+func (p *Peg) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return p.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops and returns the context's error if the context is done before the
+// parse finishes.
+func (p *Peg) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return p.parse(ctx, 0, lexemes)
+}
+
+// ParseFrom fulfills parlex.FromParser. It is the same as ParseErr but the
+// lexemes are parsed as the non-terminal named start.
+func (p *Peg) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	for i, nt := range p.NonTerminals() {
+		if nt.String() == start {
+			return p.parse(context.Background(), i, lexemes)
+		}
+	}
+	return nil, fmt.Errorf("Unknown Non-Terminal: %s", start)
+}
+
+func (p *Peg) parse(ctx context.Context, start int, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	op := p.newOp(ctx, lexemes)
+	r := op.match(start, 0)
+	if op.intr.Err != nil {
+		return nil, op.intr.Err
+	}
+	if r != nil && r.end == len(lexemes) {
+		setParents(r.node)
+		return r.node, nil
+	}
+	if r != nil && r.end > op.furthest {
+		// the start symbol matched but did not consume all the lexemes
+		return nil, parlex.NewParseError(r.end, lexemes, nil)
+	}
+	return nil, op.parseError()
+}
+
+// ParsePrefix fulfills parlex.PrefixParser. Because a PEG never backtracks into
+// a non-terminal that matched, the prefix is whatever the start symbol matches,
+// which is not always the longest prefix the grammar could derive. The lexemes
+// after the prefix are returned. If the start symbol does not match, all the
+// lexemes are returned with a *parlex.ParseError.
+func (p *Peg) ParsePrefix(lexemes []parlex.Lexeme) (parlex.ParseNode, []parlex.Lexeme, error) {
+	op := p.newOp(context.Background(), lexemes)
+	r := op.match(0, 0)
+	if r == nil {
+		return nil, lexemes, op.parseError()
+	}
+	setParents(r.node)
+	return r.node, lexemes[r.end:], nil
+}
+
+func (p *Peg) newOp(ctx context.Context, lexemes []parlex.Lexeme) *pegOp {
+	op := &pegOp{
+		Peg:      p,
+		lxs:      lexemes,
+		kinds:    make([]int, len(lexemes)),
+		memo:     make(map[memoKey]*result),
+		expected: make(map[int]bool),
+		intr:     parlex.Interrupt{Ctx: ctx},
+	}
+	for i, lx := range lexemes {
+		// kinds that are not in the grammar are -1
+		op.kinds[i] = p.set.Idx(lx.Kind())
+	}
+	return op
+}
+
+func (op *pegOp) parseError() *parlex.ParseError {
+	expected := make([]parlex.Symbol, 0, len(op.expected))
+	for idx := range op.expected {
+		expected = append(expected, op.set.ByIdx(idx))
+	}
+	return parlex.NewParseError(op.furthest, op.lxs, expected)
+}
+
+// setParents is called on the finished tree because a memoized node may have
+// been a child of a production that failed.
+func setParents(pn *tree.PN) {
+	for _, c := range pn.C {
+		c.P = pn
+		setParents(c)
+	}
+}
+
+type memoKey struct {
+	idx int
+	pos int
+}
+
+type result struct {
+	node *tree.PN
+	end  int
+}
+
+// PEG parse operation
+type pegOp struct {
+	*Peg
+	lxs   []parlex.Lexeme
+	kinds []int
+	memo  map[memoKey]*result
+	intr  parlex.Interrupt
+	// furthest is the furthest position a terminal failed to match and
+	// expected holds the terminals tried there
+	furthest int
+	expected map[int]bool
+	// predicates is the depth of predicates, failures inside a predicate are
+	// not reported
+	predicates int
+	// depth is how deeply match is nested for non-terminals, for tracing
+	depth int
+}
+
+func (op *pegOp) fail(idx, pos int) {
+	if op.predicates > 0 || pos < op.furthest {
+		return
+	}
+	if pos > op.furthest {
+		op.furthest = pos
+		op.expected = make(map[int]bool)
+	}
+	op.expected[idx] = true
+}
+
+// match returns the result of matching a symbol at a position or nil if it
+// does not match.
+func (op *pegOp) match(idx, pos int) *result {
+	if !op.isNT(idx) {
+		if pos < len(op.lxs) && op.kinds[pos] == idx {
+			pn := &tree.PN{
+				Lexeme: op.lxs[pos],
+			}
+			pn.UpdateSpan()
+			op.trace(parlex.TraceMatch, idx, -1, pos, pos+1)
+			return &result{
+				node: pn,
+				end:  pos + 1,
+			}
+		}
+		op.trace(parlex.TraceFail, idx, -1, pos, pos)
+		op.fail(idx, pos)
+		return nil
+	}
+
+	key := memoKey{idx, pos}
+	if r, ok := op.memo[key]; ok {
+		op.trace(parlex.TraceMemoHit, idx, -1, pos, pos)
+		return r
+	}
+	if op.intr.Check() {
+		return nil
+	}
+	op.depth++
+	defer func() { op.depth-- }()
+	op.trace(parlex.TraceMemoMiss, idx, -1, pos, pos)
+	var r *result
+	for i, prod := range op.prods[idx] {
+		op.trace(parlex.TraceAttempt, idx, i, pos, pos)
+		var cut bool
+		r, cut = op.matchProd(idx, prod, pos)
+		if r != nil {
+			op.trace(parlex.TraceMatch, idx, i, pos, r.end)
+			break
+		}
+		op.trace(parlex.TraceBacktrack, idx, i, pos, pos)
+		if cut {
+			break
+		}
+	}
+	op.memo[key] = r
+	return r
+}
+
+// trace sends an event to the Tracer, if there is one. A production index of
+// -1 is an event without a production.
+func (op *pegOp) trace(kind parlex.TraceKind, idx, prod, start, end int) {
+	if op.Tracer == nil {
+		return
+	}
+	e := parlex.TraceEvent{
+		Kind:   kind,
+		Symbol: op.set.ByIdx(idx),
+		Start:  start,
+		End:    end,
+		Depth:  op.depth,
+	}
+	if prod >= 0 {
+		e.Production = op.Productions(e.Symbol).Production(prod)
+	}
+	op.Tracer.Trace(e)
+}
+
+// matchProd matches the items of a production in order. If it fails after
+// passing a cut, cut is true.
+func (op *pegOp) matchProd(idx int, prod production, pos int) (r *result, cut bool) {
+	children := make([]*tree.PN, 0, len(prod))
+	for _, it := range prod {
+		switch it.kind {
+		case cutItem:
+			cut = true
+		case andItem, notItem:
+			op.predicates++
+			matched := op.match(it.idx, pos) != nil
+			op.predicates--
+			if matched != (it.kind == andItem) {
+				return nil, cut
+			}
+		default:
+			c := op.match(it.idx, pos)
+			if c == nil {
+				return nil, cut
+			}
+			children = append(children, c.node)
+			pos = c.end
+		}
+	}
+
+	lx := lexeme.New(op.set.ByIdx(idx))
+	if len(children) > 0 {
+		lx.At(children[0].Pos()).AtOffset(children[0].Offset())
+	}
+	pn := &tree.PN{
+		Lexeme: lx,
+		C:      children,
+	}
+	pn.UpdateSpan()
+	return &result{
+		node: pn,
+		end:  pos,
+	}, false
+}
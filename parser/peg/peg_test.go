@@ -0,0 +1,237 @@
+package peg
+
+import (
+	"bytes"
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    = /=/
+    word /[a-z]+/
+    space /\s+/ -
+  `))
+
+func parser(t *testing.T, grmr string) *Peg {
+	g, err := grammar.New(grmr)
+	assert.NoError(t, err)
+	p, err := New(g)
+	assert.NoError(t, err)
+	return p
+}
+
+func TestOrderedChoice(t *testing.T) {
+	p := parser(t, `
+    S -> A )
+    A -> (
+      -> ( word
+  `)
+
+	// A always takes its first production, so A ) never matches ( word )
+	_, err := p.ParseErr(lxr.Lex("( a )"))
+	if assert.Error(t, err) {
+		pe := err.(*parlex.ParseError)
+		assert.Equal(t, 1, pe.Pos)
+		assert.Equal(t, "Could Not Parse 1:3) found word: a, expected )", err.Error())
+	}
+
+	pn, err := p.ParseErr(lxr.Lex("( )"))
+	assert.NoError(t, err)
+	expected, err := tree.New(`
+    S {
+      A {
+        (: "("
+      }
+      ): ")"
+    }
+  `)
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+		assert.Equal(t, pn, pn.Child(0).Parent())
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	p := parser(t, `
+    S    -> &Pair Pair
+         -> !Pair word
+    Pair -> word = word
+  `)
+
+	pn := p.Parse(lxr.Lex("a = b"))
+	expected, err := tree.New(`
+    S {
+      Pair {
+        word: "a"
+        =: "="
+        word: "b"
+      }
+    }
+  `)
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+
+	pn = p.Parse(lxr.Lex("a"))
+	expected, err = tree.New(`
+    S {
+      word: "a"
+    }
+  `)
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+	}
+
+	// failures inside a predicate are not expected lexemes
+	_, err = p.ParseErr(lxr.Lex("a ="))
+	assert.Equal(t, "Could Not Parse 1:3) found =: =, expected end of input", err.Error())
+}
+
+func TestCut(t *testing.T) {
+	withCut := parser(t, `
+    E -> ( ~ E )
+      -> ( word
+      -> word
+  `)
+	without := parser(t, `
+    E -> ( E )
+      -> ( word
+      -> word
+  `)
+
+	lxs := lxr.Lex("( a")
+	assert.NotNil(t, without.Parse(lxs))
+	_, err := withCut.ParseErr(lxs)
+	assert.Equal(t, "Could Not Parse) found end of input, expected )", err.Error())
+
+	// a failure before the cut still tries the next production
+	assert.NotNil(t, withCut.Parse(lxr.Lex("( ( a ) )")))
+	assert.NotNil(t, withCut.Parse(lxr.Lex("a")))
+}
+
+func TestLeftRecursion(t *testing.T) {
+	for _, grmr := range []string{
+		"E -> E word\n  -> word",
+		"E -> N E word\n  -> word\nN -> word\n  ->",
+		"E -> &E word\n  -> word",
+		"E -> ~ A\nA -> E",
+	} {
+		g, err := grammar.New(grmr)
+		assert.NoError(t, err)
+		_, err = New(g)
+		assert.Equal(t, ErrLeftRecursion, err, grmr)
+	}
+}
+
+func TestContext(t *testing.T) {
+	p := parser(t, `
+    S -> word S
+      ->
+  `)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := p.ParseContext(ctx, lxr.Lex("a b c"))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestTrace(t *testing.T) {
+	p := parser(t, `
+    S -> A =
+      -> A word
+    A -> word
+  `)
+	var buf bytes.Buffer
+	p.Tracer = parlex.WriterTracer{Writer: &buf}
+	assert.NotNil(t, p.Parse(lxr.Lex("a b")))
+	assert.Equal(t, `memo-miss S @0
+attempt S -> A = @0
+memo-miss A @0
+attempt A -> word @0
+match word @0:1
+match A -> word @0:1
+fail = @1
+backtrack S -> A = @0
+attempt S -> A word @0
+memo-hit A @0
+match word @1:2
+match S -> A word @0:2
+`, buf.String())
+}
+
+func TestTraceDepth(t *testing.T) {
+	p := parser(t, `
+    S -> A word
+    A -> word
+  `)
+	depths := make(map[string]int)
+	p.Tracer = parlex.TracerFunc(func(e parlex.TraceEvent) {
+		if e.Kind == parlex.TraceMemoMiss {
+			depths[e.Symbol.String()] = e.Depth
+		}
+	})
+	assert.NotNil(t, p.Parse(lxr.Lex("a b")))
+	assert.Equal(t, map[string]int{"S": 1, "A": 2}, depths)
+}
+
+func TestParseFrom(t *testing.T) {
+	p := parser(t, `
+    S -> A = A
+    A -> ( word )
+      -> word
+  `)
+
+	pn, err := p.ParseFrom("A", lxr.Lex("( a )"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "A", pn.Kind().String())
+		assert.Equal(t, 3, pn.Children())
+	}
+
+	_, err = p.ParseFrom("A", lxr.Lex("a = b"))
+	assert.Error(t, err)
+
+	_, err = p.ParseFrom("word", lxr.Lex("a"))
+	assert.EqualError(t, err, "Unknown Non-Terminal: word")
+}
+
+func TestParsePrefix(t *testing.T) {
+	p := parser(t, `
+    S -> word = word
+  `)
+
+	pn, rest, err := p.ParsePrefix(lxr.Lex("a = b c = d"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "S", pn.Kind().String())
+		assert.Equal(t, "[word: c, =: =, word: d]", parlex.LexemeString(rest...))
+	}
+
+	lxs := lxr.Lex("a b")
+	_, rest, err = p.ParsePrefix(lxs)
+	if assert.Error(t, err) {
+		assert.Equal(t, 1, err.(*parlex.ParseError).Pos)
+	}
+	assert.Equal(t, lxs, rest)
+}
+
+func TestConstructor(t *testing.T) {
+	var pc parlex.ParserConstructor
+	pc = Constructor
+	g, err := grammar.New(`S -> ( word )`)
+	assert.NoError(t, err)
+	p := parlex.MustParser(pc(g))
+	assert.NotNil(t, p.Parse(lxr.Lex("( a )")))
+
+	g, err = grammar.New("")
+	assert.NoError(t, err)
+	_, err = pc(g)
+	assert.Equal(t, parlex.ErrBadGrammar, err)
+}
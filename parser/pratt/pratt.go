@@ -0,0 +1,363 @@
+// Package pratt implements an operator precedence parser for expressions, using
+// Pratt's top down operator precedence. It is configured by a precedence table
+// instead of a grammar and builds one node per operation, so an expression
+// like 1+2*3 is two nodes instead of a chain of E, T and F.
+//
+// A Pratt parser fulfills parlex.PrefixParser, so it can parse the expressions
+// inside a larger grammar; see the Islands of the packrat parser.
+//
+//	prsr, err := pratt.New("Expr", `
+//	  %operand int ident
+//	  %group ( )
+//	  %left + -
+//	  %left * /
+//	  %prefix -
+//	  %right ^
+//	  %postfix !
+//	`)
+package pratt
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/tree"
+	"strings"
+)
+
+// Pratt is an operator precedence parser. Each level of operators binds more
+// tightly than the levels declared before it. The nodes for operations and
+// groups have the Kind; an operand is a leaf with the lexeme.
+//
+//	1+2*3   =>   Expr { int: "1", +: "+", Expr { int: "2", *: "*", int: "3" } }
+//	-a      =>   Expr { -: "-", ident: "a" }
+//	a!      =>   Expr { ident: "a", !: "!" }
+//	(a)     =>   Expr { (: "(", ident: "a", ): ")" }
+type Pratt struct {
+	Kind     string
+	operands map[string]bool
+	groups   map[string]string
+	prefix   map[string]int
+	infix    map[string]level
+	postfix  map[string]int
+	levels   int
+}
+
+type level struct {
+	n     int
+	assoc parlex.Assoc
+}
+
+// Empty returns a Pratt parser with no operators for nodes of the kind.
+func Empty(kind string) *Pratt {
+	return &Pratt{
+		Kind:     kind,
+		operands: make(map[string]bool),
+		groups:   make(map[string]string),
+		prefix:   make(map[string]int),
+		infix:    make(map[string]level),
+		postfix:  make(map[string]int),
+	}
+}
+
+// New returns a Pratt parser for nodes of the kind from a precedence table.
+// Each line declares either operands or one level of operators:
+//
+//	%operand int ident
+//	%group ( )
+//	%left + -
+//	%right ^
+//	%nonassoc == <
+//	%prefix - !
+//	%postfix ++
+//
+// A group is an open and a close kind around an expression. The lines that
+// declare operators are levels, each binding more tightly than the ones before
+// it. An error is a *parlex.DefinitionError for the line.
+func New(kind, definition string) (*Pratt, error) {
+	p := Empty(kind)
+	for i, line := range strings.Split(definition, "\n") {
+		if err := p.line(line); err != nil {
+			return nil, parlex.DefinitionErrorAt("", i+1, line, err)
+		}
+	}
+	return p, nil
+}
+
+func (p *Pratt) line(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	kinds := fields[1:]
+	if len(kinds) == 0 {
+		return fmt.Errorf("Bad Precedence: %s needs at least one kind", fields[0])
+	}
+	switch fields[0] {
+	case "%operand":
+		p.Operand(kinds...)
+	case "%group":
+		if len(kinds) != 2 {
+			return fmt.Errorf("Bad Precedence: %%group takes an open and a close kind")
+		}
+		p.Group(kinds[0], kinds[1])
+	case "%left":
+		p.Infix(parlex.Left, kinds...)
+	case "%right":
+		p.Infix(parlex.Right, kinds...)
+	case "%nonassoc":
+		p.Infix(parlex.NonAssoc, kinds...)
+	case "%prefix":
+		p.Prefix(kinds...)
+	case "%postfix":
+		p.Postfix(kinds...)
+	default:
+		return fmt.Errorf("Bad Precedence: unknown declaration %s", fields[0])
+	}
+	return nil
+}
+
+// Operand adds kinds of lexemes that are operands.
+func (p *Pratt) Operand(kinds ...string) *Pratt {
+	for _, k := range kinds {
+		p.operands[k] = true
+	}
+	return p
+}
+
+// Group adds an open and close kind, like parentheses, around an expression.
+func (p *Pratt) Group(open, closing string) *Pratt {
+	p.groups[open] = closing
+	return p
+}
+
+// Infix adds a level of binary operators that binds more tightly than the
+// levels before it.
+func (p *Pratt) Infix(assoc parlex.Assoc, operators ...string) *Pratt {
+	p.levels++
+	for _, o := range operators {
+		p.infix[o] = level{n: p.levels, assoc: assoc}
+	}
+	return p
+}
+
+// Prefix adds a level of prefix operators that binds more tightly than the
+// levels before it. The operand of a prefix operator only holds operators from
+// the levels after it.
+func (p *Pratt) Prefix(operators ...string) *Pratt {
+	p.levels++
+	for _, o := range operators {
+		p.prefix[o] = p.levels
+	}
+	return p
+}
+
+// Postfix adds a level of postfix operators that binds more tightly than the
+// levels before it.
+func (p *Pratt) Postfix(operators ...string) *Pratt {
+	p.levels++
+	for _, o := range operators {
+		p.postfix[o] = p.levels
+	}
+	return p
+}
+
+// Parse fulfills parlex.Parser. If the lexemes cannot be parsed, nil is
+// returned.
+func (p *Pratt) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := p.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser. If the lexemes are not one expression, a
+// *parlex.ParseError is returned.
+func (p *Pratt) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return p.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as ParseErr but
+// stops and returns the context's error if the context is done before the
+// parse finishes.
+func (p *Pratt) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	op := &parseOp{
+		Pratt:   p,
+		lexemes: lexemes,
+		intr:    parlex.Interrupt{Ctx: ctx},
+	}
+	pn := op.expr(1)
+	if op.err == nil && op.pos < len(lexemes) {
+		op.fail(op.following())
+	}
+	if op.err != nil {
+		return nil, op.err
+	}
+	return pn, nil
+}
+
+// ParsePrefix fulfills parlex.PrefixParser. It parses the longest expression at
+// the start of the lexemes and returns the lexemes after it.
+func (p *Pratt) ParsePrefix(lexemes []parlex.Lexeme) (parlex.ParseNode, []parlex.Lexeme, error) {
+	op := &parseOp{
+		Pratt:   p,
+		lexemes: lexemes,
+		longest: true,
+	}
+	pn := op.expr(1)
+	if op.err != nil {
+		return nil, lexemes, op.err
+	}
+	return pn, lexemes[op.pos:], nil
+}
+
+type parseOp struct {
+	*Pratt
+	lexemes []parlex.Lexeme
+	pos     int
+	intr    parlex.Interrupt
+	err     error
+	// if longest is set, an operator without a right operand is left for the
+	// lexemes after the expression
+	longest bool
+}
+
+func (op *parseOp) peek() string {
+	if op.pos >= len(op.lexemes) {
+		return ""
+	}
+	return op.lexemes[op.pos].Kind().String()
+}
+
+func (op *parseOp) leaf() *tree.PN {
+	pn := &tree.PN{
+		Lexeme: op.lexemes[op.pos],
+	}
+	pn.UpdateSpan()
+	op.pos++
+	return pn
+}
+
+func (op *parseOp) node(children ...*tree.PN) *tree.PN {
+	lx := lexeme.String(op.Kind)
+	pn := &tree.PN{
+		Lexeme: lx,
+		C:      children,
+	}
+	for _, c := range children {
+		c.P = pn
+	}
+	lx.At(children[0].Pos()).AtOffset(children[0].Offset())
+	pn.UpdateSpan()
+	return pn
+}
+
+func (op *parseOp) fail(expected []string) {
+	syms := make([]parlex.Symbol, len(expected))
+	for i, e := range expected {
+		syms[i] = lexeme.String(e).K
+	}
+	op.err = parlex.NewParseError(op.pos, op.lexemes, syms)
+}
+
+// starts are the kinds an expression can start with.
+func (op *parseOp) starts() []string {
+	var kinds []string
+	for k := range op.operands {
+		kinds = append(kinds, k)
+	}
+	for k := range op.groups {
+		kinds = append(kinds, k)
+	}
+	for k := range op.prefix {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+// following are the kinds that can follow an operand.
+func (op *parseOp) following() []string {
+	var kinds []string
+	for k := range op.infix {
+		kinds = append(kinds, k)
+	}
+	for k := range op.postfix {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+// expr parses an expression that only holds operators of at least the level
+// min.
+func (op *parseOp) expr(min int) *tree.PN {
+	if op.intr.Check() {
+		op.err = op.intr.Err
+		return nil
+	}
+	left := op.operand()
+	if left == nil {
+		return nil
+	}
+	// the level of a non-associative operator that was just used, which cannot
+	// be used again directly
+	nonAssoc := 0
+	for {
+		kind := op.peek()
+		if l, ok := op.postfix[kind]; ok && l >= min {
+			left = op.node(left, op.leaf())
+			continue
+		}
+		l, ok := op.infix[kind]
+		if !ok || l.n < min || l.n == nonAssoc {
+			return left
+		}
+		at := op.pos
+		o := op.leaf()
+		next := l.n + 1
+		if l.assoc == parlex.Right {
+			next = l.n
+		}
+		right := op.expr(next)
+		if right == nil {
+			if op.longest && op.intr.Err == nil {
+				op.pos, op.err = at, nil
+				return left
+			}
+			return nil
+		}
+		left = op.node(left, o, right)
+		nonAssoc = 0
+		if l.assoc == parlex.NonAssoc {
+			nonAssoc = l.n
+		}
+	}
+}
+
+// operand parses an operand, a group or a prefix operator and its operand.
+func (op *parseOp) operand() *tree.PN {
+	kind := op.peek()
+	if op.operands[kind] {
+		return op.leaf()
+	}
+	if l, ok := op.prefix[kind]; ok {
+		o := op.leaf()
+		e := op.expr(l)
+		if e == nil {
+			return nil
+		}
+		return op.node(o, e)
+	}
+	if closing, ok := op.groups[kind]; ok {
+		open := op.leaf()
+		e := op.expr(1)
+		if e == nil {
+			return nil
+		}
+		if op.peek() != closing {
+			op.fail(append(op.following(), closing))
+			return nil
+		}
+		return op.node(open, e, op.leaf())
+	}
+	op.fail(op.starts())
+	return nil
+}
@@ -0,0 +1,192 @@
+package pratt
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    ( /\(/
+    ) /\)/
+    == /==/
+    + /\+/
+    - /-/
+    * /\*/
+    ^ /\^/
+    ! /!/
+    int /\d+/
+    ident /[a-z]\w*/
+    space /\s+/ -
+  `))
+
+func expr(t *testing.T) *Pratt {
+	p, err := New("Expr", `
+    %operand int ident
+    %group ( )
+    %nonassoc ==
+    %left + -
+    %left *
+    %prefix -
+    %right ^
+    %postfix !
+  `)
+	assert.NoError(t, err)
+	return p
+}
+
+// sexpr writes the operations as s-expressions with the operator first.
+func sexpr(pn parlex.ParseNode) string {
+	switch pn.Children() {
+	case 0:
+		return pn.Value()
+	case 2:
+		if pn.Child(1).Kind().String() == "!" {
+			return "(! " + sexpr(pn.Child(0)) + ")"
+		}
+		return "(" + pn.Child(0).Value() + " " + sexpr(pn.Child(1)) + ")"
+	}
+	if pn.Child(0).Kind().String() == "(" {
+		return sexpr(pn.Child(1))
+	}
+	return "(" + pn.Child(1).Value() + " " + sexpr(pn.Child(0)) + " " + sexpr(pn.Child(2)) + ")"
+}
+
+func TestParse(t *testing.T) {
+	p := expr(t)
+
+	pn := p.Parse(lxr.Lex("1+2*3"))
+	if assert.NotNil(t, pn) {
+		expected, _ := tree.New(`
+      Expr {
+        int: "1"
+        +: "+"
+        Expr {
+          int: "2"
+          *: "*"
+          int: "3"
+        }
+      }
+    `)
+		assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+		l, c := pn.Pos()
+		assert.Equal(t, 1, l)
+		assert.Equal(t, 1, c)
+		assert.Equal(t, tree.Span{Start: 0, End: 5}, pn.(*tree.PN).S)
+	}
+
+	tt := map[string]string{
+		"a":           "a",
+		"1-2-3":       "(- (- 1 2) 3)",
+		"2^3^4":       "(^ 2 (^ 3 4))",
+		"1+2*3-4":     "(- (+ 1 (* 2 3)) 4)",
+		"(1+2)*3":     "(* (+ 1 2) 3)",
+		"-a*b":        "(* (- a) b)",
+		"-a^b":        "(- (^ a b))",
+		"--a":         "(- (- a))",
+		"a!^b":        "(^ (! a) b)",
+		"a^b!":        "(^ a (! b))",
+		"a+b==c*d":    "(== (+ a b) (* c d))",
+		"1 - (2 - 3)": "(- 1 (- 2 3))",
+	}
+	for src, expected := range tt {
+		pn, err := p.ParseErr(lxr.Lex(src))
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, expected, sexpr(pn), src)
+		}
+	}
+}
+
+func TestParseErr(t *testing.T) {
+	p := expr(t)
+	tt := map[string]string{
+		"1+":      "Could Not Parse) found end of input, expected (, -, ident, int",
+		"1+*2":    "Could Not Parse 1:3) found *: *, expected (, -, ident, int",
+		"(1+2":    "Could Not Parse) found end of input, expected !, ), *, +, -, ==, ^",
+		"1 2":     "Could Not Parse 1:3) found int: 2, expected !, *, +, -, ==, ^",
+		"a==b==c": "Could Not Parse 1:5) found ==: ==, expected !, *, +, -, ==, ^",
+		"":        "Could Not Parse) found end of input, expected (, -, ident, int",
+	}
+	for src, msg := range tt {
+		_, err := p.ParseErr(lxr.Lex(src))
+		if assert.Error(t, err, src) {
+			assert.Equal(t, msg, err.Error(), src)
+		}
+	}
+	assert.Nil(t, p.Parse(lxr.Lex("1+")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParsePrefix(t *testing.T) {
+	p := expr(t)
+
+	pn, rest, err := p.ParsePrefix(lxr.Lex("1+2 3"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "(+ 1 2)", sexpr(pn))
+		if assert.Len(t, rest, 1) {
+			assert.Equal(t, "3", rest[0].Value())
+		}
+	}
+
+	// an operator without a right operand is left after the expression
+	pn, rest, err = p.ParsePrefix(lxr.Lex("a*b+)"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "(* a b)", sexpr(pn))
+		assert.Len(t, rest, 2)
+	}
+
+	lxs := lxr.Lex(")")
+	pn, rest, err = p.ParsePrefix(lxs)
+	assert.Error(t, err)
+	assert.Nil(t, pn)
+	assert.Equal(t, lxs, rest)
+}
+
+func TestNew(t *testing.T) {
+	p := Empty("E").
+		Operand("int").
+		Infix(parlex.Left, "+").
+		Infix(parlex.Left, "*")
+	pn := p.Parse(lxr.Lex("1*2+3"))
+	if assert.NotNil(t, pn) {
+		assert.Equal(t, "(+ (* 1 2) 3)", sexpr(pn))
+		assert.Equal(t, "E", pn.Kind().String())
+	}
+
+	_, err := New("E", `
+    %operand int
+    %left
+  `)
+	if de, ok := err.(*parlex.DefinitionError); assert.True(t, ok) {
+		assert.Equal(t, 3, de.Line)
+		assert.Contains(t, err.Error(), "Bad Precedence: %left needs at least one kind")
+	}
+
+	_, err = New("E", "%group (")
+	assert.Contains(t, err.Error(), "Bad Precedence: %group takes an open and a close kind")
+
+	_, err = New("E", "%infix +")
+	assert.Contains(t, err.Error(), "Bad Precedence: unknown declaration %infix")
+}
+
+func BenchmarkParse(b *testing.B) {
+	p, _ := New("Expr", `
+    %operand int
+    %group ( )
+    %left +
+    %left *
+  `)
+	lxs := lxr.Lex(strings.Repeat("(1+2*3)*4+", 200) + "5")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Parse(lxs)
+	}
+}
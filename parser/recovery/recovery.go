@@ -0,0 +1,300 @@
+// Package recovery wraps a parser so that it produces a best-effort tree for
+// input with syntax errors. This is useful for tools like editors that need a
+// tree even when the input is broken.
+//
+// Recovery uses panic mode. When the parse fails, the lexemes around the
+// failure are skipped back to the last synchronization lexeme and forward
+// through the next one, then the parse is retried. Once the parse succeeds, the
+// skipped lexemes are placed in the tree under Error nodes where they were
+// removed.
+package recovery
+
+import (
+	"context"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+)
+
+// DefaultErrorString is the kind that will be assigned to error nodes
+var DefaultErrorString = "Error"
+
+// DefaultMaxErrors is the number of errors that will be recovered from before
+// giving up.
+var DefaultMaxErrors = 100
+
+// Recovery wraps an ErrParser. Sync holds the kinds of lexemes that end a
+// section of input, like ";", that parsing can resume after. If Sync is empty,
+// only the offending lexeme is skipped. Error is the kind of the error nodes.
+type Recovery struct {
+	parlex.ErrParser
+	Sync      map[string]bool
+	Error     string
+	MaxErrors int
+}
+
+// New wraps a parser to recover from errors using the sync lexeme kinds.
+func New(parser parlex.ErrParser, sync ...string) *Recovery {
+	r := &Recovery{
+		ErrParser: parser,
+		Sync:      make(map[string]bool),
+		Error:     DefaultErrorString,
+		MaxErrors: DefaultMaxErrors,
+	}
+	for _, s := range sync {
+		r.Sync[s] = true
+	}
+	return r
+}
+
+// ErrorLexeme is the Lexeme of an error node. The value is the error message
+// and the children of the error node are the lexemes that were skipped. It
+// fulfills parlex.LexError.
+type ErrorLexeme struct {
+	*lexeme.Lexeme
+	Err *parlex.ParseError
+}
+
+func (e *ErrorLexeme) Error() string {
+	return e.Err.Error()
+}
+
+// errRange is a range of original lexemes, [start,end), that were skipped.
+type errRange struct {
+	start, end int
+	err        *parlex.ParseError
+}
+
+// Parse fulfills parlex.Parser. If the lexemes can be parsed without error, the
+// result is the same as the underlying parser. Otherwise the tree will contain
+// Error nodes. If recovery fails, the root will be an Error node holding all
+// the lexemes.
+func (r *Recovery) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := r.ParseContext(context.Background(), lexemes)
+	return pn
+}
+
+// ParseContext fulfills parlex.ContextParser. It is the same as Parse but if the
+// context is done before recovery finishes, the context's error is returned. The
+// context is passed to the underlying parser if it is a parlex.ContextParser.
+func (r *Recovery) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	var errs []errRange
+	for {
+		kept := keptIdxs(len(lexemes), errs)
+		lxms := make([]parlex.Lexeme, len(kept))
+		for i, k := range kept {
+			lxms[i] = lexemes[k]
+		}
+		pn, err := parlex.ParseContext(ctx, r.ErrParser, lxms)
+		if err == nil {
+			root, ok := pn.(*tree.PN)
+			if !ok {
+				root = tree.Reducer{}.RawReduce(pn)
+			}
+			r.insertErrors(root, lexemes, lxms, kept, errs)
+			return root, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		pe, ok := err.(*parlex.ParseError)
+		if !ok || len(errs) >= r.MaxErrors {
+			break
+		}
+		start, end := r.skip(lxms, pe.Pos)
+		if start == end {
+			break
+		}
+		if pe.Pos < len(kept) {
+			pe.Pos = kept[pe.Pos]
+		} else {
+			pe.Pos = len(lexemes)
+		}
+		errs = merge(errs, errRange{kept[start], kept[end-1] + 1, pe}, len(r.Sync) == 0)
+	}
+	return r.errorNode(lexemes, errRange{0, len(lexemes), parlex.NewParseError(0, lexemes, nil)}), nil
+}
+
+// skip finds the range of lexemes to skip for an error at pos.
+func (r *Recovery) skip(lxms []parlex.Lexeme, pos int) (int, int) {
+	if len(r.Sync) == 0 {
+		if pos == len(lxms) {
+			pos--
+		}
+		if pos < 0 {
+			return 0, 0
+		}
+		return pos, pos + 1
+	}
+	start := pos
+	if start == len(lxms) {
+		start--
+	}
+	for ; start > 0 && !r.Sync[lxms[start-1].Kind().String()]; start-- {
+	}
+	end := pos
+	for ; end < len(lxms); end++ {
+		if r.Sync[lxms[end].Kind().String()] {
+			end++
+			break
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start, end
+}
+
+// merge adds an errRange to errs, combining any ranges that overlap. If touch
+// is true, ranges that are next to each other are also combined.
+func merge(errs []errRange, er errRange, touch bool) []errRange {
+	var out []errRange
+	for _, e := range errs {
+		if e.end < er.start || e.start > er.end || (!touch && (e.end == er.start || e.start == er.end)) {
+			out = append(out, e)
+			continue
+		}
+		if e.start < er.start {
+			er.start, er.err = e.start, e.err
+		}
+		if e.end > er.end {
+			er.end = e.end
+		}
+	}
+	out = append(out, er)
+	for i := len(out) - 1; i > 0 && out[i].start < out[i-1].start; i-- {
+		out[i], out[i-1] = out[i-1], out[i]
+	}
+	return out
+}
+
+func keptIdxs(ln int, errs []errRange) []int {
+	kept := make([]int, 0, ln)
+	e := 0
+	for i := 0; i < ln; i++ {
+		for e < len(errs) && errs[e].end <= i {
+			e++
+		}
+		if e < len(errs) && errs[e].start <= i {
+			continue
+		}
+		kept = append(kept, i)
+	}
+	return kept
+}
+
+func (r *Recovery) errorNode(lexemes []parlex.Lexeme, er errRange) *tree.PN {
+	lx := &ErrorLexeme{
+		Lexeme: lexeme.New(stringsymbol.Symbol(r.Error)).Set(er.err.Error()),
+		Err:    er.err,
+	}
+	pn := &tree.PN{
+		Lexeme: lx,
+	}
+	for _, l := range lexemes[er.start:er.end] {
+		c := &tree.PN{
+			Lexeme: l,
+			P:      pn,
+		}
+		c.UpdateSpan()
+		pn.C = append(pn.C, c)
+	}
+	if len(pn.C) > 0 {
+		lx.At(pn.C[0].Pos()).AtOffset(pn.C[0].Offset())
+	}
+	pn.UpdateSpan()
+	return pn
+}
+
+// insertErrors places an error node for each errRange after the lexeme that
+// preceded it. If that lexeme ends a node, the error node is placed after the
+// highest node it ends, so an error between two statements is not placed
+// inside the first statement. Errors at the start are placed before the first
+// lexeme in the same way.
+func (r *Recovery) insertErrors(root *tree.PN, lexemes, lxms []parlex.Lexeme, kept []int, errs []errRange) {
+	leaves := findLeaves(root, lxms)
+	// errors that share a position are inserted at the same index, so going in
+	// reverse keeps them in order
+	for i := len(errs) - 1; i >= 0; i-- {
+		er := errs[i]
+		en := r.errorNode(lexemes, er)
+		// count the kept lexemes before the error
+		k := 0
+		for k < len(kept) && kept[k] < er.start {
+			k++
+		}
+		switch {
+		case len(leaves) == 0:
+			insert(root, len(root.C), en)
+		case k == 0:
+			n := leaves[0]
+			for n.P.P != nil && indexOf(n) == 0 {
+				n = n.P
+			}
+			insert(n.P, indexOf(n), en)
+		default:
+			n := leaves[k-1]
+			for n.P.P != nil && indexOf(n) == len(n.P.C)-1 {
+				n = n.P
+			}
+			insert(n.P, indexOf(n)+1, en)
+		}
+	}
+}
+
+// findLeaves finds the node in the tree for each lexeme. Childless nodes are
+// matched to the lexemes in order by kind and value, which skips the nodes for
+// empty productions.
+func findLeaves(root *tree.PN, lxms []parlex.Lexeme) []*tree.PN {
+	var leaves []*tree.PN
+	var walk func(*tree.PN)
+	walk = func(pn *tree.PN) {
+		for _, c := range pn.C {
+			c.P = pn
+			walk(c)
+		}
+		if l := len(leaves); len(pn.C) == 0 && pn.P != nil && l < len(lxms) &&
+			pn.Kind().String() == lxms[l].Kind().String() && pn.Value() == lxms[l].Value() {
+			leaves = append(leaves, pn)
+		}
+	}
+	walk(root)
+	return leaves
+}
+
+func indexOf(pn *tree.PN) int {
+	for i, c := range pn.P.C {
+		if c == pn {
+			return i
+		}
+	}
+	return len(pn.P.C)
+}
+
+func insert(parent *tree.PN, idx int, en *tree.PN) {
+	en.P = parent
+	parent.C = append(parent.C, nil)
+	copy(parent.C[idx+1:], parent.C[idx:])
+	parent.C[idx] = en
+	for p := parent; p != nil; p = p.P {
+		p.S = p.S.Merge(en.S)
+	}
+}
+
+// Errors returns the error lexemes of all the error nodes in a tree.
+func Errors(node parlex.ParseNode) []*ErrorLexeme {
+	if node == nil {
+		return nil
+	}
+	var errs []*ErrorLexeme
+	if pn, ok := node.(*tree.PN); ok {
+		if el, ok := pn.Lexeme.(*ErrorLexeme); ok {
+			errs = append(errs, el)
+		}
+	}
+	for i := 0; i < node.Children(); i++ {
+		errs = append(errs, Errors(node.Child(i))...)
+	}
+	return errs
+}
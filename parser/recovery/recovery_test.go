@@ -0,0 +1,158 @@
+package recovery
+
+import (
+	"context"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const lexerRules = `
+  id /[a-z]+/
+  int /\d+/
+  = /=/
+  ; /;/
+  op /[+\-]/
+  space /\s+/ -
+`
+
+const grammarRules = `
+  Stmts -> Stmt Stmts
+        ->
+  Stmt  -> id = E ;
+  E     -> int op E
+        -> int
+`
+
+func setup(t *testing.T) (*simplelexer.Lexer, *grammar.Grammar) {
+	lxr, err := simplelexer.New(lexerRules)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(grammarRules)
+	assert.NoError(t, err)
+	return lxr, grmr
+}
+
+func TestNoErrors(t *testing.T) {
+	lxr, grmr := setup(t)
+	r := New(packrat.New(grmr), ";")
+
+	pn := r.Parse(lxr.Lex("a = 1; b = 2 + 3;"))
+	if assert.NotNil(t, pn) {
+		assert.Len(t, Errors(pn), 0)
+		assert.Equal(t, packrat.New(grmr).Parse(lxr.Lex("a = 1; b = 2 + 3;")).(*tree.PN).String(), pn.(*tree.PN).String())
+	}
+}
+
+func TestSync(t *testing.T) {
+	lxr, grmr := setup(t)
+	r := New(packrat.New(grmr), ";")
+
+	pn := r.Parse(lxr.Lex("a = 1; b = = 2; c = 3;"))
+	if !assert.NotNil(t, pn) {
+		return
+	}
+	errs := Errors(pn)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, 6, errs[0].Err.Pos)
+		assert.Equal(t, "Error", errs[0].Kind().String())
+	}
+
+	expected, _ := tree.New(`
+    Stmts {
+      Stmt {
+        id: "a"
+        =: "="
+        E {
+          int: "1"
+        }
+        ;: ";"
+      }
+      Error {
+        id: "b"
+        =: "="
+        =: "="
+        int: "2"
+        ;: ";"
+      }
+      Stmts {
+        Stmt {
+          id: "c"
+          =: "="
+          E {
+            int: "3"
+          }
+          ;: ";"
+        }
+        Stmts
+      }
+    }
+  `)
+	// error node values hold the message, clear them for comparison
+	errNode := pn.(*tree.PN).C[1]
+	assert.Equal(t, errs[0].Error(), errNode.Value())
+	errNode.Lexeme = errNode.Lexeme.(*ErrorLexeme).Lexeme.Set("")
+	assert.Equal(t, expected.String(), pn.(*tree.PN).String())
+}
+
+func TestMultipleErrors(t *testing.T) {
+	lxr, grmr := setup(t)
+	r := New(packrat.New(grmr), ";")
+
+	pn := r.Parse(lxr.Lex("a = ; b = 1; c 2; d = 3 +"))
+	if assert.NotNil(t, pn) {
+		errs := Errors(pn)
+		if assert.Len(t, errs, 3) {
+			assert.Equal(t, 2, errs[0].Err.Pos)
+			assert.Equal(t, 8, errs[1].Err.Pos)
+			assert.Equal(t, 14, errs[2].Err.Pos)
+		}
+		assert.Equal(t, "Error", pn.Child(0).Kind().String())
+		assert.Equal(t, "b", pn.Child(1).Child(0).Value())
+	}
+}
+
+func TestNoSync(t *testing.T) {
+	lxr, grmr := setup(t)
+	r := New(packrat.New(grmr))
+
+	pn := r.Parse(lxr.Lex("a = 1 + ; b = 2;"))
+	if assert.NotNil(t, pn) {
+		errs := Errors(pn)
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, 4, errs[0].Err.Pos)
+			assert.Equal(t, "Could Not Parse 1:9) found ;: ;, expected int", errs[0].Error())
+		}
+		// the lexemes are skipped one at a time until "a = 1 + 2;" parses
+		assert.Equal(t, "Error", pn.Child(0).Child(2).Child(2).Kind().String())
+		assert.Equal(t, 3, pn.Child(0).Child(2).Child(2).Children())
+	}
+}
+
+func TestSpan(t *testing.T) {
+	lxr, grmr := setup(t)
+	r := New(packrat.New(grmr), ";")
+
+	src := "a = 1; b = = 2;"
+	pn := r.Parse(lxr.Lex(src)).(*tree.PN)
+	assert.Equal(t, tree.Span{Start: 0, End: len(src)}, pn.S)
+	assert.Equal(t, "b = = 2;", pn.C[1].S.Src(src))
+}
+
+func TestParseContext(t *testing.T) {
+	lxr, grmr := setup(t)
+	r := New(packrat.New(grmr), ";")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pn, err := r.ParseContext(ctx, lxr.Lex("a = 1; b = ; c = 2;"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, pn) {
+		assert.Len(t, Errors(pn), 1)
+	}
+	cancel()
+	pn, err = r.ParseContext(ctx, lxr.Lex("a = 1;"))
+	assert.Nil(t, pn)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,314 @@
+// Package stream parses lexemes as they arrive and reports the parse as a
+// sequence of events instead of building a tree, so input of any size can be
+// processed in constant memory.
+//
+// The parser is a table driven LL(1) parser. It reads one lexeme at a time
+// from a Source, like the Stream returned by simplelexer's LexReader, and calls
+// a Handler with an Open event when it starts a non-terminal, a Token event for
+// each lexeme and a Close event when the non-terminal ends. The memory used
+// depends on how deeply the input nests, not on its length.
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar/analysis"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/parser/ll1"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/adamcolton/parlex/tree"
+)
+
+// EventKind is the kind of an Event.
+type EventKind byte
+
+// The kinds of events
+const (
+	Open EventKind = iota
+	Token
+	Close
+)
+
+var eventKindStrings = []string{"Open", "Token", "Close"}
+
+// String fulfills Stringer
+func (k EventKind) String() string {
+	if int(k) < len(eventKindStrings) {
+		return eventKindStrings[k]
+	}
+	return fmt.Sprintf("EventKind(%d)", k)
+}
+
+// Event is a step in the parse. Symbol is the non-terminal of an Open or Close
+// event and the kind of the lexeme of a Token event. Lexeme is only set for a
+// Token event.
+type Event struct {
+	Kind   EventKind
+	Symbol string
+	Lexeme parlex.Lexeme
+}
+
+// String fulfills Stringer
+func (e Event) String() string {
+	if e.Kind == Token {
+		return "Token " + parlex.LexemeString(e.Lexeme)
+	}
+	return e.Kind.String() + " " + e.Symbol
+}
+
+// Handler is called with each event in the order of the input. If it returns
+// an error, the parse stops and returns that error.
+type Handler func(Event) error
+
+// Source provides the lexemes to parse one at a time. Next returns nil at the
+// end of the input. If the Source also has an Err() error method, like
+// simplelexer.Stream, it is checked when the input ends.
+type Source interface {
+	Next() parlex.Lexeme
+}
+
+type sliceSource struct {
+	lexemes []parlex.Lexeme
+}
+
+func (s *sliceSource) Next() parlex.Lexeme {
+	if len(s.lexemes) == 0 {
+		return nil
+	}
+	lx := s.lexemes[0]
+	s.lexemes = s.lexemes[1:]
+	return lx
+}
+
+// Lexemes returns a Source that reads from a slice.
+func Lexemes(lexemes []parlex.Lexeme) Source {
+	return &sliceSource{lexemes}
+}
+
+// Stream is an LL(1) parser. Non-terminals in Inline do not get Open and Close
+// events, their children belong to the node that holds them; the root always
+// gets them. A list written as a right recursive non-terminal, like the ones
+// regexgram adds for E*, nests once for each item. Making it Inline keeps the
+// nesting and the memory the same however long the list is, and gives the same
+// shape as the reducer from regexgram.
+//
+// A Stream is safe for concurrent use as long as Inline is not changed.
+type Stream struct {
+	parlex.Grammar
+	Inline map[string]bool
+	start  string
+	table  ll1.Table
+	// prods holds the symbols of the productions of each non-terminal
+	prods map[string][][]string
+}
+
+// New builds the LL(1) table for the grammar with the ll1 package. If the
+// grammar has conflicts, an ll1.ConflictError is returned describing all of
+// them. A left recursive grammar returns ll1.ErrLeftRecursion;
+// grammar.RemoveLeftRecursion can rewrite it.
+func New(grmr parlex.Grammar) (*Stream, error) {
+	l, err := ll1.New(grmr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Stream{
+		Grammar: grmr,
+		Inline:  make(map[string]bool),
+		start:   parlex.StartSymbol(grmr).String(),
+		table:   l.Table(),
+		prods:   make(map[string][][]string),
+	}
+	for _, nt := range grmr.NonTerminals() {
+		var prods [][]string
+		for i := grmr.Productions(nt).Iter(); i.Next(); {
+			symbols := make([]string, 0, i.Symbols())
+			for j := i.Iter(); j.Next(); {
+				symbols = append(symbols, j.Symbol.String())
+			}
+			prods = append(prods, symbols)
+		}
+		s.prods[nt.String()] = prods
+	}
+	return s, nil
+}
+
+// InlineSymbols adds the non-terminals to Inline. It returns the Stream so
+// calls can be chained.
+func (s *Stream) InlineSymbols(symbols ...string) *Stream {
+	for _, sym := range symbols {
+		s.Inline[sym] = true
+	}
+	return s
+}
+
+// Run parses the lexemes from the source and calls the handler with each
+// event. If the parse fails, the error is a *parlex.ParseError with Pos
+// counting the lexemes read; the events before the error have already been
+// sent.
+func (s *Stream) Run(src Source, h Handler) error {
+	return s.RunContext(context.Background(), src, h)
+}
+
+// RunContext is the same as Run but stops and returns the context's error if
+// the context is done before the parse finishes.
+func (s *Stream) RunContext(ctx context.Context, src Source, h Handler) error {
+	intr := parlex.Interrupt{Ctx: ctx}
+	// a stack entry is a symbol to match or, if close is true, the end of a
+	// non-terminal
+	type entry struct {
+		symbol string
+		close  bool
+	}
+	stack := []entry{{symbol: s.start}}
+	root := true
+	// skipped holds the lookaheads that would have started the nullable
+	// non-terminals that derived nothing since the last lexeme, so an error
+	// can list them as expected
+	var skipped []parlex.Symbol
+	lx := src.Next()
+	pos := 0
+	for len(stack) > 0 {
+		if intr.Check() {
+			return intr.Err
+		}
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e.close {
+			if err := h(Event{Kind: Close, Symbol: e.symbol}); err != nil {
+				return err
+			}
+			continue
+		}
+		row, isNT := s.table[e.symbol]
+		if !isNT {
+			if lx == nil || lx.Kind().String() != e.symbol {
+				return s.parseError(src, pos, lx, append(skipped, stringsymbol.Symbol(e.symbol)))
+			}
+			if err := h(Event{Kind: Token, Symbol: e.symbol, Lexeme: lx}); err != nil {
+				return err
+			}
+			lx = src.Next()
+			pos++
+			skipped = skipped[:0]
+			continue
+		}
+		la := analysis.EOF
+		if lx != nil {
+			la = lx.Kind().String()
+		}
+		pIdx, ok := row[la]
+		if !ok {
+			return s.parseError(src, pos, lx, append(skipped, expected(row, -1)...))
+		}
+		symbols := s.prods[e.symbol][pIdx]
+		if len(symbols) == 0 {
+			skipped = append(skipped, expected(row, pIdx)...)
+		}
+		if !s.Inline[e.symbol] || root {
+			root = false
+			if err := h(Event{Kind: Open, Symbol: e.symbol}); err != nil {
+				return err
+			}
+			stack = append(stack, entry{symbol: e.symbol, close: true})
+		}
+		for i := len(symbols) - 1; i >= 0; i-- {
+			stack = append(stack, entry{symbol: symbols[i]})
+		}
+	}
+	if lx != nil {
+		return s.parseError(src, pos, lx, skipped)
+	}
+	return srcErr(src)
+}
+
+// expected returns the lookaheads of a row of the table other than the end of
+// the input and the ones that choose the production not.
+func expected(row map[string]int, not int) []parlex.Symbol {
+	var out []parlex.Symbol
+	for la, pIdx := range row {
+		if la != analysis.EOF && pIdx != not {
+			out = append(out, stringsymbol.Symbol(la))
+		}
+	}
+	return out
+}
+
+// parseError returns the error from the source if reading it failed, as that
+// is the reason the input looks wrong.
+func (s *Stream) parseError(src Source, pos int, lx parlex.Lexeme, expected []parlex.Symbol) error {
+	if err := srcErr(src); err != nil {
+		return err
+	}
+	pe := parlex.NewParseError(pos, nil, expected)
+	pe.Lexeme = lx
+	return pe
+}
+
+func srcErr(src Source) error {
+	if e, ok := src.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+// Parse fulfills parlex.Parser. It builds the tree from the events, so it is
+// mostly useful to check a grammar against other parsers.
+func (s *Stream) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := s.ParseErr(lexemes)
+	return pn
+}
+
+// ParseErr fulfills parlex.ErrParser.
+func (s *Stream) ParseErr(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	return s.ParseContext(context.Background(), lexemes)
+}
+
+// ParseContext fulfills parlex.ContextParser.
+func (s *Stream) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	b := &builder{}
+	if err := s.RunContext(ctx, Lexemes(lexemes), b.handle); err != nil {
+		if pe, ok := err.(*parlex.ParseError); ok && pe.Pos < len(lexemes) {
+			pe.Lexeme = lexemes[pe.Pos]
+		}
+		return nil, err
+	}
+	return b.root, nil
+}
+
+// builder makes a tree from the events the same as the tree the other parsers
+// return.
+type builder struct {
+	stack []*tree.PN
+	root  *tree.PN
+}
+
+func (b *builder) handle(e Event) error {
+	switch e.Kind {
+	case Open:
+		b.stack = append(b.stack, &tree.PN{Lexeme: lexeme.String(e.Symbol)})
+	case Token:
+		pn := &tree.PN{Lexeme: e.Lexeme}
+		pn.UpdateSpan()
+		b.add(pn)
+	case Close:
+		pn := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+		if len(pn.C) > 0 {
+			pn.Lexeme.(*lexeme.Lexeme).At(pn.C[0].Pos()).AtOffset(pn.C[0].Offset())
+		}
+		pn.UpdateSpan()
+		if len(b.stack) == 0 {
+			b.root = pn
+		} else {
+			b.add(pn)
+		}
+	}
+	return nil
+}
+
+func (b *builder) add(pn *tree.PN) {
+	parent := b.stack[len(b.stack)-1]
+	pn.P = parent
+	parent.C = append(parent.C, pn)
+}
@@ -0,0 +1,241 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/grammar/regexgram"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/lalr"
+	"github.com/adamcolton/parlex/parser/ll1"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+  key   /\w+/
+  colon /:/
+  str   /"[^"]*"/
+  lb    /\{/
+  rb    /\}/
+  space /\s+/ -
+`))
+
+const docGrammar = `
+  Doc   -> Entry*
+  Entry -> key colon Value
+  Value -> str
+        -> lb Entry* rb
+`
+
+func collect(s *Stream, lexemes []parlex.Lexeme) ([]string, error) {
+	var events []string
+	err := s.Run(Lexemes(lexemes), func(e Event) error {
+		events = append(events, e.String())
+		return nil
+	})
+	return events, err
+}
+
+func TestEvents(t *testing.T) {
+	grmr, err := grammar.New(`
+    Entry -> key colon Value
+    Value -> str
+          -> lb Entry rb
+  `)
+	assert.NoError(t, err)
+	s, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	events, err := collect(s, lxr.Lex(`a: {b: "c"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"Open Entry",
+		"Token key: a",
+		"Token colon: :",
+		"Open Value",
+		"Token lb: {",
+		"Open Entry",
+		"Token key: b",
+		"Token colon: :",
+		"Open Value",
+		`Token str: "c"`,
+		"Close Value",
+		"Close Entry",
+		"Token rb: }",
+		"Close Value",
+		"Close Entry",
+	}, events)
+
+	// the tree matches the one from another parser
+	p, err := lalr.New(grmr)
+	assert.NoError(t, err)
+	for _, in := range []string{`a: {b: "c"}`, `a: "b"`} {
+		expected := p.Parse(lxr.Lex(in))
+		pn, err := s.ParseErr(lxr.Lex(in))
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String(), in)
+		}
+	}
+}
+
+func TestInline(t *testing.T) {
+	grmr, rdcr, err := regexgram.New(docGrammar)
+	assert.NoError(t, err)
+	repeats, err := regexgram.Repeats(docGrammar)
+	assert.NoError(t, err)
+	s, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, symbols := range repeats {
+		s.InlineSymbols(symbols...)
+	}
+
+	in := `a: "1" b: {c: "2" d: {} e: "3"} f: "4"`
+	expected := rdcr.Reduce(packrat.New(grmr).Parse(lxr.Lex(in)))
+	pn, err := s.ParseErr(lxr.Lex(in))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected.(*tree.PN).String(), pn.(*tree.PN).String())
+	}
+
+	// the lexemes can come from a reader
+	expectedEvents, err := collect(s, lxr.Lex(in))
+	assert.NoError(t, err)
+	var events []string
+	err = s.Run(lxr.(*simplelexer.Lexer).LexReader(strings.NewReader(in)), func(e Event) error {
+		events = append(events, e.String())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expectedEvents, events)
+
+	pn, err = s.ParseErr(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Doc", pn.Kind().String())
+		assert.Equal(t, 0, pn.Children())
+	}
+
+	// a long list does not nest
+	src := &entries{n: 10000}
+	depth, maxDepth, tokens := 0, 0, 0
+	err = s.Run(src, func(e Event) error {
+		switch e.Kind {
+		case Open:
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+		case Close:
+			depth--
+		case Token:
+			tokens++
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, depth)
+	assert.Equal(t, 3, maxDepth)
+	assert.Equal(t, 30000, tokens)
+}
+
+// entries is a Source of n entries that are made as they are read.
+type entries struct {
+	n, i int
+	err  error
+}
+
+func (e *entries) Next() parlex.Lexeme {
+	if e.i >= 3*e.n {
+		return nil
+	}
+	kinds := []string{"key", "colon", "str"}
+	lx := lexeme.String(kinds[e.i%3])
+	e.i++
+	return lx
+}
+
+func (e *entries) Err() error {
+	return e.err
+}
+
+func TestErrors(t *testing.T) {
+	grmr, _, err := regexgram.New(docGrammar)
+	assert.NoError(t, err)
+	s, err := New(grmr)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := map[string]string{
+		`a: {b "c"}`: `Could Not Parse 1:7) found str: "c", expected colon`,
+		`a: }`:       `Could Not Parse 1:4) found rb: }, expected lb, str`,
+		`a: "b" }`:   `Could Not Parse 1:8) found rb: }, expected key`,
+		`a: {`:       `Could Not Parse) found end of input, expected key, rb`,
+	}
+	for in, expected := range tests {
+		_, err := s.ParseErr(lxr.Lex(in))
+		if assert.Error(t, err, in) {
+			assert.Equal(t, expected, err.Error(), in)
+		}
+	}
+
+	_, err = s.ParseErr(lxr.Lex(`a: {b "c"}`))
+	if pe, ok := err.(*parlex.ParseError); assert.True(t, ok) {
+		assert.Equal(t, 4, pe.Pos)
+	}
+
+	stop := errors.New("stop")
+	var events []string
+	err = s.Run(Lexemes(lxr.Lex(`a: "b" c: "d"`)), func(e Event) error {
+		events = append(events, e.String())
+		if e.Kind == Close && e.Symbol == "Entry" {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Len(t, events, 9)
+
+	readErr := errors.New("read failed")
+	src := &entries{n: 1, err: readErr}
+	src.i = 1 // start after the key
+	assert.Equal(t, readErr, s.Run(src, func(Event) error { return nil }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, s.RunContext(ctx, &entries{n: 10}, func(Event) error { return nil }))
+}
+
+func TestConflicts(t *testing.T) {
+	grmr, err := grammar.New(`
+    S -> a b
+      -> a c
+      -> a
+  `)
+	assert.NoError(t, err)
+	_, err = New(grmr)
+	ce, ok := err.(ll1.ConflictError)
+	if assert.True(t, ok) {
+		assert.Equal(t, []ll1.Conflict{
+			{NonTerminal: "S", Lookahead: "a", Productions: []string{"S -> a b", "S -> a c", "S -> a"}},
+		}, []ll1.Conflict(ce))
+		assert.True(t, strings.HasPrefix(err.Error(), "LL(1) conflicts:\nS on a between (S -> a b), (S -> a c), (S -> a)"))
+	}
+
+	_, err = New(parlex.MustGrammar(grammar.New(`
+    E -> E + T
+      -> T
+    T -> int
+  `)))
+	assert.Equal(t, ll1.ErrLeftRecursion, err)
+
+	_, err = New(grammar.Empty())
+	assert.Equal(t, parlex.ErrBadGrammar, err)
+}
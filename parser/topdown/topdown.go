@@ -2,6 +2,7 @@
 package topdown
 
 import (
+	"context"
 	"errors"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/lexeme"
@@ -30,9 +31,17 @@ func New(grmr parlex.Grammar) (*Topdown, error) {
 
 // Parse implements parlex.Parser
 func (t *Topdown) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
+	pn, _ := t.ParseContext(context.Background(), lexemes)
+	return pn
+}
+
+// ParseContext fulfills parlex.ContextParser. If the context is done before the
+// parse finishes, the context's error is returned and if the lexemes cannot be
+// parsed, ErrCouldNotParse is returned.
+func (t *Topdown) ParseContext(ctx context.Context, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
 	nts := t.NonTerminals()
 	if len(nts) == 0 {
-		return nil
+		return nil, parlex.ErrBadGrammar
 	}
 	set := setsymbol.New()
 	set.LoadGrammar(t.Grammar)
@@ -41,13 +50,31 @@ func (t *Topdown) Parse(lexemes []parlex.Lexeme) parlex.ParseNode {
 		lxs:     set.LoadLexemes(lexemes),
 		memo:    make(map[treeKey]*acceptResp),
 		set:     set,
+		intr:    parlex.Interrupt{Ctx: ctx},
 	}
 	start := op.set.Symbol(nts[0]).Idx()
 	node := op.accept(treeKey{start, 0}, true).node()
+	if op.intr.Err != nil {
+		return nil, op.intr.Err
+	}
 	if node == nil {
-		return nil
+		return nil, parlex.ErrCouldNotParse
 	}
-	return node
+	return node, nil
+}
+
+// ParseFrom fulfills parlex.FromParser. The lexemes are parsed as the
+// non-terminal named start. If they cannot be parsed, ErrCouldNotParse is
+// returned.
+func (t *Topdown) ParseFrom(start string, lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+	grmr, err := parlex.StartFrom(t.Grammar, start)
+	if err != nil {
+		return nil, err
+	}
+	sub := &Topdown{
+		Grammar: grmr,
+	}
+	return sub.ParseContext(context.Background(), lexemes)
 }
 
 type treeKey struct {
@@ -61,11 +88,13 @@ type acceptResp struct {
 }
 
 func resp(lx parlex.Lexeme, end int, children ...*tree.PN) *acceptResp {
+	pn := &tree.PN{
+		Lexeme: lx,
+		C:      children,
+	}
+	pn.UpdateSpan()
 	return &acceptResp{
-		PN: &tree.PN{
-			Lexeme: lx,
-			C:      children,
-		},
+		PN:  pn,
 		end: end,
 	}
 }
@@ -83,12 +112,16 @@ type tdOp struct {
 	lxs  []*lexeme.Lexeme
 	memo map[treeKey]*acceptResp
 	set  *setsymbol.Set
+	intr parlex.Interrupt
 }
 
 func (op *tdOp) accept(key treeKey, all bool) *acceptResp {
 	if resp, ok := op.memo[key]; ok {
 		return resp
 	}
+	if op.intr.Check() {
+		return nil
+	}
 	resp := op.tryAccept(key, all)
 	op.memo[key] = resp
 	return resp
@@ -132,6 +165,9 @@ func (op *tdOp) acceptProd(key treeKey, prod parlex.Production) *acceptResp {
 		children[i.Idx], pos = resp.PN, resp.end
 	}
 
-	symbol := op.set.ByIdx(key.idx)
-	return resp(lexeme.New(symbol), pos, children...)
+	lx := lexeme.New(op.set.ByIdx(key.idx))
+	if len(children) > 0 {
+		lx.At(children[0].Pos()).AtOffset(children[0].Offset())
+	}
+	return resp(lx, pos, children...)
 }
@@ -1,6 +1,8 @@
 package topdown
 
 import (
+	"context"
+	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/grammar"
 	"github.com/adamcolton/parlex/lexer/simplelexer"
 	"github.com/adamcolton/parlex/tree"
@@ -112,3 +114,29 @@ func TestNil(t *testing.T) {
 	pn := p.Parse(lxs)
 	assert.NotNil(t, pn)
 }
+
+func TestParseContext(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    op /[+\-]/
+    int /\d+/
+  `)
+	assert.NoError(t, err)
+	grmr, err := grammar.New(`
+    E -> int op E
+      -> int
+  `)
+	assert.NoError(t, err)
+	p, err := New(grmr)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pn, err := p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.NoError(t, err)
+	assert.NotNil(t, pn)
+	_, err = p.ParseContext(ctx, lxr.Lex("1+"))
+	assert.Equal(t, parlex.ErrCouldNotParse, err)
+	cancel()
+	pn, err = p.ParseContext(ctx, lxr.Lex("1+2"))
+	assert.Nil(t, pn)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,36 @@
+package parlex
+
+// ParsePrefix parses the longest prefix of the lexemes that can be derived from
+// the start symbol and returns the lexemes after it. If the parser is not a
+// PrefixParser, every prefix is tried from the longest to the shortest, which
+// means parsing the lexemes once for each prefix that fails. If no prefix can
+// be parsed, all the lexemes are returned with the error from parsing all of
+// them.
+func ParsePrefix(parser Parser, lexemes []Lexeme) (ParseNode, []Lexeme, error) {
+	if pp, ok := parser.(PrefixParser); ok {
+		return pp.ParsePrefix(lexemes)
+	}
+	var first error
+	for end := len(lexemes); end >= 0; end-- {
+		pn, err := parseErr(parser, lexemes[:end])
+		if err == nil {
+			return pn, lexemes[end:], nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return nil, lexemes, first
+}
+
+// parseErr uses ParseErr if the parser is an ErrParser, otherwise
+// ErrCouldNotParse is returned if Parse fails.
+func parseErr(parser Parser, lexemes []Lexeme) (ParseNode, error) {
+	if ep, ok := parser.(ErrParser); ok {
+		return ep.ParseErr(lexemes)
+	}
+	if pn := parser.Parse(lexemes); pn != nil {
+		return pn, nil
+	}
+	return nil, ErrCouldNotParse
+}
@@ -0,0 +1,39 @@
+package parlex
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// evenParser only parses an even number of lexemes.
+type evenParser struct{}
+
+func (evenParser) Parse(lexemes []Lexeme) ParseNode {
+	if len(lexemes)%2 != 0 {
+		return nil
+	}
+	return &testNode{}
+}
+
+type testNode struct{ lx }
+
+func (*testNode) Parent() ParseNode   { return nil }
+func (*testNode) Children() int       { return 0 }
+func (*testNode) Child(int) ParseNode { return nil }
+
+func TestParsePrefix(t *testing.T) {
+	lxs := []Lexeme{
+		&lx{k: "a"},
+		&lx{k: "b"},
+		&lx{k: "c"},
+	}
+	node, rest, err := ParsePrefix(evenParser{}, lxs)
+	assert.NoError(t, err)
+	assert.NotNil(t, node)
+	assert.Equal(t, lxs[2:], rest)
+
+	node, rest, err = ParsePrefix(nilParser{}, lxs)
+	assert.Equal(t, ErrCouldNotParse, err)
+	assert.Nil(t, node)
+	assert.Equal(t, lxs, rest)
+}
@@ -0,0 +1,69 @@
+package parlex
+
+import (
+	"fmt"
+)
+
+// ParseFrom parses the lexemes starting from the non-terminal named start. The
+// parser must be a FromParser.
+func ParseFrom(parser Parser, start string, lexemes []Lexeme) (ParseNode, error) {
+	if fp, ok := parser.(FromParser); ok {
+		return fp.ParseFrom(start, lexemes)
+	}
+	return nil, fmt.Errorf("Cannot Parse From: %T", parser)
+}
+
+// StartFrom returns a view of the grammar that uses the non-terminal named
+// start as the start symbol. The other non-terminals keep their order after
+// it. Precedence and predicates of the grammar are kept. If start is not a
+// non-terminal in the grammar, an error is returned.
+//
+// A parser that builds tables from its grammar can use StartFrom to build a
+// parser for ParseFrom.
+func StartFrom(grammar Grammar, start string) (Grammar, error) {
+	nts := grammar.NonTerminals()
+	for i, nt := range nts {
+		if nt.String() != start {
+			continue
+		}
+		if i == 0 {
+			return grammar, nil
+		}
+		sf := &startFrom{
+			Grammar: grammar,
+			nts:     make([]Symbol, 0, len(nts)),
+		}
+		sf.nts = append(sf.nts, nt)
+		sf.nts = append(sf.nts, nts[:i]...)
+		sf.nts = append(sf.nts, nts[i+1:]...)
+		return sf, nil
+	}
+	return nil, fmt.Errorf("Unknown Non-Terminal: %s", start)
+}
+
+type startFrom struct {
+	Grammar
+	nts []Symbol
+}
+
+func (sf *startFrom) NonTerminals() []Symbol { return sf.nts }
+
+func (sf *startFrom) Start() Symbol { return sf.nts[0] }
+
+func (sf *startFrom) IsTerminal(symbol Symbol) bool { return IsTerminal(sf.Grammar, symbol) }
+
+func (sf *startFrom) Terminals() []Symbol { return Terminals(sf.Grammar) }
+
+func (sf *startFrom) Precedence(symbol Symbol) (int, Assoc) {
+	if pg, ok := sf.Grammar.(PrecedenceGrammar); ok {
+		return pg.Precedence(symbol)
+	}
+	return 0, NonAssoc
+}
+
+func (sf *startFrom) Predicate(nonterminal Symbol, production int) Predicate {
+	if pg, ok := sf.Grammar.(PredicateGrammar); ok {
+		return pg.Predicate(nonterminal, production)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package parlex
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStartFrom(t *testing.T) {
+	g := &testGrammar{}
+	g.reset()
+	g.new("A")
+	g.add("B", "x")
+	g.new("B")
+	g.add("y")
+	g.new("C")
+	g.add("z")
+
+	sf, err := StartFrom(g, "B")
+	assert.NoError(t, err)
+	assert.Equal(t, []Symbol{symbol("B"), symbol("A"), symbol("C")}, sf.NonTerminals())
+	assert.Equal(t, symbol("B"), StartSymbol(sf))
+	assert.True(t, IsTerminal(sf, symbol("x")))
+	assert.False(t, IsTerminal(sf, symbol("A")))
+	assert.Equal(t, g.Productions(symbol("A")), sf.Productions(symbol("A")))
+	level, _ := ProductionPrecedence(sf, production{"x"})
+	assert.Equal(t, 0, level)
+
+	sf, err = StartFrom(g, "A")
+	assert.NoError(t, err)
+	assert.Equal(t, g, sf)
+
+	_, err = StartFrom(g, "x")
+	assert.EqualError(t, err, "Unknown Non-Terminal: x")
+
+	_, err = ParseFrom(nilParser{}, "A", nil)
+	assert.Error(t, err)
+}
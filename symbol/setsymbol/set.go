@@ -294,7 +294,7 @@ func (s *Set) LoadGrammar(grammar parlex.Grammar) {
 func (s *Set) LoadLexemes(lexemes []parlex.Lexeme) []*lexeme.Lexeme {
 	out := make([]*lexeme.Lexeme, len(lexemes))
 	for i, lx := range lexemes {
-		out[i] = lexeme.New(s.Symbol(lx.Kind())).Set(lx.Value()).At(lx.Pos())
+		out[i] = lexeme.New(s.Symbol(lx.Kind())).Set(lx.Value()).At(lx.Pos()).AtOffset(parlex.Offset(lx))
 	}
 	return out
 }
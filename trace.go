@@ -0,0 +1,126 @@
+package parlex
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// TraceKind is the kind of a TraceEvent.
+type TraceKind byte
+
+// Kinds of TraceEvents. Not every parser produces every kind.
+const (
+	// TraceAttempt is sent when a parser starts trying a production
+	TraceAttempt TraceKind = iota
+	// TraceMatch is sent when a production matches from Start to End
+	TraceMatch
+	// TraceFail is sent when a terminal the parser required is not at Start
+	TraceFail
+	// TraceMemoHit is sent when the result for a symbol at Start is already
+	// known
+	TraceMemoHit
+	// TraceMemoMiss is sent when a symbol has to be parsed at Start
+	TraceMemoMiss
+	// TraceBacktrack is sent when a production is given up, either because
+	// it failed part way or because a match was rejected
+	TraceBacktrack
+)
+
+var traceKindStrs = []string{"attempt", "match", "fail", "memo-hit", "memo-miss", "backtrack"}
+
+// String returns the name of the kind.
+func (k TraceKind) String() string {
+	if int(k) < len(traceKindStrs) {
+		return traceKindStrs[k]
+	}
+	return fmt.Sprintf("TraceKind(%d)", k)
+}
+
+// TraceEvent is one step of a parse. Start and End are indexes into the
+// lexemes. End is the same as Start except for TraceMatch and for a
+// TraceBacktrack of a match that was rejected. Production is nil if the event
+// is about a symbol rather than one of its productions. Depth is how many
+// symbols the parser is nested in when the event is sent, starting at 1 for the
+// start symbol; it is 0 if the parser does not track it.
+type TraceEvent struct {
+	Kind       TraceKind
+	Symbol     Symbol
+	Production Production
+	Start, End int
+	Depth      int
+}
+
+// String describes the event on one line, as in "match E -> E op E @0:3".
+func (e TraceEvent) String() string {
+	var buf strings.Builder
+	buf.WriteString(e.Kind.String())
+	buf.WriteString(" ")
+	buf.WriteString(e.Symbol.String())
+	if e.Production != nil {
+		buf.WriteString(" ->")
+		for i := e.Production.Iter(); i.Next(); {
+			buf.WriteString(" ")
+			buf.WriteString(i.String())
+		}
+	}
+	fmt.Fprintf(&buf, " @%d", e.Start)
+	if e.Kind == TraceMatch || e.End != e.Start {
+		fmt.Fprintf(&buf, ":%d", e.End)
+	}
+	return buf.String()
+}
+
+// Tracer receives the steps of a parse. Parsers that support tracing have a
+// Tracer field that is nil by default.
+type Tracer interface {
+	Trace(TraceEvent)
+}
+
+// TracerFunc fulfills Tracer with a function.
+type TracerFunc func(TraceEvent)
+
+// Trace calls the function.
+func (fn TracerFunc) Trace(e TraceEvent) { fn(e) }
+
+// WriterTracer writes each event to the Writer on its own line.
+type WriterTracer struct {
+	io.Writer
+}
+
+// Trace fulfills Tracer.
+func (t WriterTracer) Trace(e TraceEvent) {
+	fmt.Fprintln(t.Writer, e)
+}
+
+// SlogTracer logs each event to a slog.Logger at Level. The message is the
+// kind of the event and the rest of the event is logged as attributes.
+type SlogTracer struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+// Trace fulfills Tracer.
+func (t SlogTracer) Trace(e TraceEvent) {
+	ctx := context.Background()
+	if !t.Logger.Enabled(ctx, t.Level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("symbol", e.Symbol.String()),
+		slog.Int("start", e.Start),
+	}
+	if e.Production != nil {
+		strs := make([]string, 0, e.Production.Symbols())
+		for i := e.Production.Iter(); i.Next(); {
+			strs = append(strs, i.String())
+		}
+		attrs = append(attrs, slog.String("production", strings.Join(strs, " ")))
+	}
+	if e.Kind == TraceMatch || e.End != e.Start {
+		attrs = append(attrs, slog.Int("end", e.End))
+	}
+	t.Logger.LogAttrs(ctx, t.Level, e.Kind.String(), attrs...)
+}
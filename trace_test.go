@@ -0,0 +1,52 @@
+package parlex
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestTraceEvent(t *testing.T) {
+	tt := map[string]TraceEvent{
+		"attempt E -> E op E @2":  {Kind: TraceAttempt, Symbol: symbol("E"), Production: production{"E", "op", "E"}, Start: 2, End: 2},
+		"match E -> @2:2":         {Kind: TraceMatch, Symbol: symbol("E"), Production: production{}, Start: 2, End: 2},
+		"fail int @3":             {Kind: TraceFail, Symbol: symbol("int"), Start: 3, End: 3},
+		"memo-hit E @0":           {Kind: TraceMemoHit, Symbol: symbol("E")},
+		"backtrack E -> int @1:2": {Kind: TraceBacktrack, Symbol: symbol("E"), Production: production{"int"}, Start: 1, End: 2},
+	}
+	for expected, e := range tt {
+		assert.Equal(t, expected, e.String())
+	}
+	assert.Equal(t, "TraceKind(9)", TraceKind(9).String())
+}
+
+func TestTracers(t *testing.T) {
+	e := TraceEvent{Kind: TraceMatch, Symbol: symbol("E"), Production: production{"E", "op", "E"}, Start: 0, End: 3}
+
+	var buf bytes.Buffer
+	WriterTracer{Writer: &buf}.Trace(e)
+	assert.Equal(t, "match E -> E op E @0:3\n", buf.String())
+
+	var got TraceEvent
+	TracerFunc(func(e TraceEvent) { got = e }).Trace(e)
+	assert.Equal(t, e, got)
+
+	buf.Reset()
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	SlogTracer{Logger: slog.New(h), Level: slog.LevelDebug}.Trace(e)
+	assert.Equal(t, "level=DEBUG msg=match symbol=E start=0 production=\"E op E\" end=3\n", buf.String())
+
+	// events below the logger's level are dropped
+	buf.Reset()
+	SlogTracer{Logger: slog.New(slog.NewTextHandler(&buf, nil)), Level: slog.LevelDebug}.Trace(e)
+	assert.Equal(t, "", buf.String())
+}
@@ -21,10 +21,12 @@ type Condition func(node *PN) bool
 func If(condition Condition, then, otherwise Reduction) Reduction {
 	return func(node *PN) {
 		if condition(node) {
+			traceOp(node, "If", true)
 			if then != nil {
 				then(node)
 			}
 		} else {
+			traceOp(node, "If", false)
 			if otherwise != nil {
 				otherwise(node)
 			}
@@ -44,11 +46,66 @@ func ChildIs(cIdx int, kind string) Condition {
 	}
 }
 
+// ValueIs returns true if the child at cIdx has the value. The cIdx value uses
+// GetIdx.
+func ValueIs(cIdx int, value string) Condition {
+	return func(node *PN) bool {
+		cIdx, _, ok := node.GetIdx(cIdx)
+		return ok && node.C[cIdx].Value() == value
+	}
+}
+
+// ChildCount returns true if the node has n children
+func ChildCount(n int) Condition {
+	return func(node *PN) bool {
+		return len(node.C) == n
+	}
+}
+
+// KindIs returns true if the node is of type kind
+func KindIs(kind string) Condition {
+	return func(node *PN) bool {
+		return node.Kind().String() == kind
+	}
+}
+
+// And returns true if all the conditions are true
+func And(conditions ...Condition) Condition {
+	return func(node *PN) bool {
+		for _, c := range conditions {
+			if !c(node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns true if any of the conditions are true
+func Or(conditions ...Condition) Condition {
+	return func(node *PN) bool {
+		for _, c := range conditions {
+			if c(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns true if the condition is false
+func Not(condition Condition) Condition {
+	return func(node *PN) bool {
+		return !condition(node)
+	}
+}
+
 // PromoteChild removes the node with the child at cIdx and replaces it's own
 // lexeme with the value. The grandchildren are spliced into the replaced childs
 // position. The cIdx value uses GetIdx.
 func PromoteChild(cIdx int) Reduction {
 	return func(node *PN) {
+		traceOp(node, "PromoteChild", cIdx)
 		node.PromoteChild(cIdx)
 	}
 }
@@ -108,19 +165,49 @@ func (r Reduction) PromoteChild(cIdx int) Reduction {
 	return Chain(r, PromoteChild(cIdx))
 }
 
+// Flatten replaces each child of the same kind as the node with its children,
+// recursively.
+func (r Reduction) Flatten() Reduction {
+	return Chain(r, Flatten)
+}
+
+// Rename changes the kind of the node, keeping its value and position.
+func (r Reduction) Rename(kind string) Reduction {
+	return Chain(r, Rename(kind))
+}
+
+// MergeAdjacent joins each run of adjacent childless children of the kind
+// into a single child with the concatenated value.
+func (r Reduction) MergeAdjacent(kind string) Reduction {
+	return Chain(r, MergeAdjacent(kind))
+}
+
+// Lift replaces the node with the child at cIdx and appends the other
+// children after the children of the lifted child. The cIdx value uses GetIdx.
+func (r Reduction) Lift(cIdx int) Reduction {
+	return Chain(r, Lift(cIdx))
+}
+
 // RemoveChild produces a Reduction for removing a child at cIdx.
 func RemoveChild(cIdx int) Reduction {
-	return func(node *PN) { node.RemoveChild(cIdx) }
+	return func(node *PN) {
+		traceOp(node, "RemoveChild", cIdx)
+		node.RemoveChild(cIdx)
+	}
 }
 
 // RemoveChildren produces a Reduction for removing a child at cIdx.
 func RemoveChildren(cIdxs ...int) Reduction {
-	return func(node *PN) { node.RemoveChildren(cIdxs...) }
+	return func(node *PN) {
+		traceOp(node, "RemoveChildren", cIdxs)
+		node.RemoveChildren(cIdxs...)
+	}
 }
 
 // RemoveAll removes all child that match any symbol in symbols
 func RemoveAll(symbols ...string) Reduction {
 	return func(node *PN) {
+		traceOp(node, "RemoveAll", symbols)
 		node.RemoveAll(symbols...)
 	}
 }
@@ -130,13 +217,19 @@ func RemoveAll(symbols ...string) Reduction {
 // is negative, it will find the child relative to the end. If cIdx is out of
 // bounds, no action will be taken.
 func PromoteChildValue(cIdx int) Reduction {
-	return func(node *PN) { node.PromoteChildValue(cIdx) }
+	return func(node *PN) {
+		traceOp(node, "PromoteChildValue", cIdx)
+		node.PromoteChildValue(cIdx)
+	}
 }
 
 // ReplaceWithChild replaces the node with the child at position cIdx, using
 // GetIdx
 func ReplaceWithChild(cIdx int) Reduction {
-	return func(node *PN) { node.ReplaceWithChild(cIdx) }
+	return func(node *PN) {
+		traceOp(node, "ReplaceWithChild", cIdx)
+		node.ReplaceWithChild(cIdx)
+	}
 }
 
 // PromoteChildrenOf will remove the child at cIdx and splice in all it's
@@ -144,6 +237,7 @@ func ReplaceWithChild(cIdx int) Reduction {
 // cIdx is out of bounds, no action will be taken.
 func PromoteChildrenOf(cIdx int) Reduction {
 	return func(node *PN) {
+		traceOp(node, "PromoteChildrenOf", cIdx)
 		node.PromoteChildrenOf(cIdx)
 	}
 }
@@ -151,11 +245,45 @@ func PromoteChildrenOf(cIdx int) Reduction {
 // PromoteGrandChildren will remove all the immediate children and replace them
 // with the grand children.
 func PromoteGrandChildren(node *PN) {
+	traceOp(node, "PromoteGrandChildren")
 	node.PromoteGrandChildren()
 }
 
 // PromoteSingleChild fulfills Reduce. If the node has a single child, that
 // child will be promoted to replace the node.
 func PromoteSingleChild(node *PN) {
+	traceOp(node, "PromoteSingleChild")
 	node.PromoteSingleChild()
-}
\ No newline at end of file
+}
+// Flatten replaces each child of the same kind as the node with its children,
+// recursively.
+func Flatten(node *PN) {
+	traceOp(node, "Flatten")
+	node.Flatten()
+}
+
+// Rename changes the kind of the node, keeping its value and position.
+func Rename(kind string) Reduction {
+	return func(node *PN) {
+		traceOp(node, "Rename", kind)
+		node.Rename(kind)
+	}
+}
+
+// MergeAdjacent joins each run of adjacent childless children of the kind
+// into a single child with the concatenated value.
+func MergeAdjacent(kind string) Reduction {
+	return func(node *PN) {
+		traceOp(node, "MergeAdjacent", kind)
+		node.MergeAdjacent(kind)
+	}
+}
+
+// Lift replaces the node with the child at cIdx and appends the other
+// children after the children of the lifted child. The cIdx value uses GetIdx.
+func Lift(cIdx int) Reduction {
+	return func(node *PN) {
+		traceOp(node, "Lift", cIdx)
+		node.Lift(cIdx)
+	}
+}
@@ -31,3 +31,88 @@ func TestConditional(t *testing.T) {
 
   assert.Equal(t, "bar", pn.Value())
 }
+
+func TestConditions(t *testing.T) {
+	pn, _ := New(`
+    E {
+      num: "6"
+      op: "+"
+      num: "7"
+    }
+  `)
+
+	tests := map[string]struct {
+		Condition
+		expected bool
+	}{
+		"ValueIs":         {ValueIs(1, "+"), true},
+		"ValueIs-neg":     {ValueIs(-1, "7"), true},
+		"ValueIs-false":   {ValueIs(1, "-"), false},
+		"ValueIs-bounds":  {ValueIs(3, "+"), false},
+		"ChildCount":      {ChildCount(3), true},
+		"ChildCount-fail": {ChildCount(2), false},
+		"KindIs":          {KindIs("E"), true},
+		"KindIs-false":    {KindIs("num"), false},
+		"And":             {And(KindIs("E"), ChildIs(0, "num")), true},
+		"And-false":       {And(KindIs("E"), ChildIs(1, "num")), false},
+		"Or":              {Or(KindIs("T"), ValueIs(0, "6")), true},
+		"Or-false":        {Or(KindIs("T"), ValueIs(0, "7")), false},
+		"Not":             {Not(ChildCount(2)), true},
+	}
+
+	for n, tc := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.Condition(pn))
+		})
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	tt := map[string]struct {
+		reduction     Reduction
+		before, after string
+	}{
+		"Flatten": {
+			reduction: Flatten,
+			before:    `(L (a "1") (L (a "2") (L (a "3"))) (M (L (a "4"))))`,
+			after:     `(L (a "1") (a "2") (a "3") (M (L (a "4"))))`,
+		},
+		"Rename": {
+			reduction: Rename("Num"),
+			before:    `(int "12" (a "1"))`,
+			after:     `(Num "12" (a "1"))`,
+		},
+		"MergeAdjacent": {
+			reduction: MergeAdjacent("chr"),
+			before:    `(S (chr "a") (chr "b") (sep ",") (chr "c") (chr "d" (x)) (chr "e") (chr "f"))`,
+			after:     `(S (chr "ab") (sep ",") (chr "c") (chr "d" x) (chr "ef"))`,
+		},
+		"Lift": {
+			reduction: Lift(1),
+			before:    `(E (int "1") (op "+" (a) (b)) (int "2"))`,
+			after:     `(op "+" a b (int "1") (int "2"))`,
+		},
+		"Lift-out-of-bounds": {
+			reduction: Lift(3),
+			before:    `(E (int "1"))`,
+			after:     `(E (int "1"))`,
+		},
+		"Chain": {
+			reduction: Reduction(nil).Rename("Str").MergeAdjacent("chr").Lift(0),
+			before:    `(S (chr "a") (chr "b"))`,
+			after:     `(chr "ab")`,
+		},
+	}
+
+	for n, tc := range tt {
+		t.Run(n, func(t *testing.T) {
+			pn, err := FromSExpr(tc.before, nil)
+			assert.NoError(t, err)
+			tc.reduction(pn)
+			assert.Equal(t, tc.after, ToSExpr(pn))
+			for _, c := range pn.C {
+				assert.Equal(t, pn, c.P)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"strings"
+)
+
+// Comments holds the comment lexemes attached to the nodes of a tree.
+type Comments struct {
+	Leading  map[*PN][]parlex.Lexeme
+	Trailing map[*PN][]parlex.Lexeme
+}
+
+// AttachComments attaches the comments in the trivia of the lexemes, like the
+// ones kept by simplelexer.KeepTrivia, to the syntactically nearest node in
+// the tree. A comment is any hidden lexeme of one of the kinds given. The
+// lexemes should be the ones the tree was parsed from; they are needed because
+// a reducer may have removed the leaf that held a comment.
+//
+// A leading comment is attached to the outermost node below the root that
+// starts with the lexeme it leads, so a doc comment before "func" goes to the
+// function declaration. A trailing comment is attached to the outermost node
+// below the root that ends with the lexeme and starts on the same line. If no
+// node qualifies, the comment goes to the innermost node that holds the lexeme.
+// Trailing comments on a later line than the last lexeme go to the root.
+//
+// The spans of the nodes are used to find them, so this works best on a
+// reduced tree where lists have been flattened.
+func AttachComments(root *PN, lexemes []parlex.Lexeme, kinds ...string) *Comments {
+	c := &Comments{
+		Leading:  make(map[*PN][]parlex.Lexeme),
+		Trailing: make(map[*PN][]parlex.Lexeme),
+	}
+	if root == nil {
+		return c
+	}
+	isComment := make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		isComment[k] = true
+	}
+	lines := make(map[int]int, len(lexemes))
+	for _, lx := range lexemes {
+		line, _ := lx.Pos()
+		lines[parlex.Offset(lx)] = line
+	}
+
+	for _, lx := range lexemes {
+		start := parlex.Offset(lx)
+		if start < 0 {
+			continue
+		}
+		end := start + len(lx.Value())
+		line, _ := lx.Pos()
+
+		var pn *PN
+		for _, t := range parlex.Leading(lx) {
+			if !isComment[t.Kind().String()] {
+				continue
+			}
+			if pn == nil {
+				pn = root.nearest(start, func(n *PN) bool {
+					return n.S.Start == start
+				})
+			}
+			c.Leading[pn] = append(c.Leading[pn], t)
+		}
+
+		pn = nil
+		sameLine := true
+		for _, t := range parlex.Trailing(lx) {
+			if !isComment[t.Kind().String()] {
+				if strings.Contains(t.Value(), "\n") {
+					sameLine = false
+				}
+				continue
+			}
+			if !sameLine {
+				c.Trailing[root] = append(c.Trailing[root], t)
+				continue
+			}
+			if pn == nil {
+				pn = root.nearest(start, func(n *PN) bool {
+					return n.S.End == end && lines[n.S.Start] == line
+				})
+			}
+			c.Trailing[pn] = append(c.Trailing[pn], t)
+		}
+	}
+	return c
+}
+
+// nearest follows the nodes that hold the offset down from p and returns the
+// first one below p that passes ok. If none does, the last node holding the
+// offset is returned.
+func (p *PN) nearest(offset int, ok func(*PN) bool) *PN {
+	cur := p
+	for {
+		var next *PN
+		for _, ch := range cur.C {
+			if ch != nil && ch.S.Start <= offset && offset < ch.S.End {
+				next = ch
+				break
+			}
+		}
+		if next == nil {
+			return cur
+		}
+		if ok(next) {
+			return next
+		}
+		cur = next
+	}
+}
@@ -0,0 +1,64 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAttachComments(t *testing.T) {
+	lxr, err := simplelexer.New(`
+    func
+    word    /\w+/
+    lb      /\{/
+    rb      /\}/
+    space   /\s+/ -
+    comment /\/\/[^\n]*/ -
+  `)
+	if !assert.NoError(t, err) {
+		return
+	}
+	lxr.KeepTrivia()
+	src := "// doc\nfunc foo {\n  x // about x\n}\nfunc bar { } // end bar\n// last"
+	lxs := lxr.Lex(src)
+	if !assert.Len(t, lxs, 9) {
+		return
+	}
+
+	node := func(kind string, children ...*PN) *PN {
+		pn := &PN{Lexeme: lexeme.String(kind), C: children}
+		for _, c := range children {
+			c.P = pn
+		}
+		pn.UpdateSpan()
+		return pn
+	}
+	leaf := func(lx parlex.Lexeme) *PN {
+		pn := &PN{Lexeme: lx}
+		pn.UpdateSpan()
+		return pn
+	}
+	x := leaf(lxs[3])
+	foo := node("Func", leaf(lxs[0]), leaf(lxs[1]), node("Body", leaf(lxs[2]), x, leaf(lxs[4])))
+	bar := node("Func", leaf(lxs[5]), leaf(lxs[6]), node("Body", leaf(lxs[7]), leaf(lxs[8])))
+	root := node("File", foo, bar)
+	// a reducer removing the keyword does not lose the doc comment
+	foo.C = foo.C[1:]
+
+	c := AttachComments(root, lxs, "comment")
+	values := func(lxs []parlex.Lexeme) []string {
+		var strs []string
+		for _, lx := range lxs {
+			strs = append(strs, lx.Value())
+		}
+		return strs
+	}
+	assert.Equal(t, []string{"// doc"}, values(c.Leading[foo]))
+	assert.Equal(t, []string{"// about x"}, values(c.Trailing[x]))
+	assert.Equal(t, []string{"// end bar"}, values(c.Trailing[bar]))
+	assert.Equal(t, []string{"// last"}, values(c.Trailing[root]))
+	assert.Len(t, c.Leading, 1)
+	assert.Len(t, c.Trailing, 3)
+}
@@ -0,0 +1,198 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"strconv"
+)
+
+// EditOp is the kind of change an Edit makes.
+type EditOp byte
+
+// Operations of an Edit
+const (
+	// Insert adds B, which is only in the second tree
+	Insert EditOp = iota
+	// Delete removes A, which is only in the first tree. Its children take its
+	// place in its parent.
+	Delete
+	// Relabel changes the kind or value of A to those of B.
+	Relabel
+)
+
+var editOpNames = []string{"insert", "delete", "relabel"}
+
+func (op EditOp) String() string {
+	if int(op) < len(editOpNames) {
+		return editOpNames[op]
+	}
+	return "EditOp(" + strconv.Itoa(int(op)) + ")"
+}
+
+// Edit is one change from the first tree passed to Diff to the second. A is
+// the node in the first tree and B is the node in the second; the one that is
+// not part of the change is nil.
+type Edit struct {
+	Op   EditOp
+	A, B parlex.ParseNode
+}
+
+func (e Edit) String() string {
+	switch e.Op {
+	case Insert:
+		return "insert " + parlex.LexemeString(e.B)
+	case Delete:
+		return "delete " + parlex.LexemeString(e.A)
+	}
+	return e.Op.String() + " " + parlex.LexemeString(e.A) + " -> " + parlex.LexemeString(e.B)
+}
+
+// Diff returns a minimal list of edits that change tree a into tree b. Nodes
+// are the same if they have the same kind and value. Deleting a node moves its
+// children up to its parent and inserting a node can take a run of siblings as
+// its children. The edits are ordered from the left of the trees to the right.
+// It uses the Zhang-Shasha tree edit distance, which takes time proportional
+// to the product of the sizes of the trees.
+func Diff(a, b parlex.ParseNode) []Edit {
+	d := &differ{}
+	d.a.load(a)
+	d.b.load(b)
+	n, m := len(d.a.nodes), len(d.b.nodes)
+	d.td = make([][]int, n)
+	for i := range d.td {
+		d.td[i] = make([]int, m)
+	}
+	for _, i := range d.a.keyroots() {
+		for _, j := range d.b.keyroots() {
+			d.forest(d.a.left[i], i, d.b.left[j], j)
+		}
+	}
+	d.backtrack(0, n-1, 0, m-1)
+	for i, j := 0, len(d.edits)-1; i < j; i, j = i+1, j-1 {
+		d.edits[i], d.edits[j] = d.edits[j], d.edits[i]
+	}
+	return d.edits
+}
+
+// postorder holds the nodes of a tree in post order with the index of the
+// leftmost leaf under each one.
+type postorder struct {
+	nodes []parlex.ParseNode
+	left  []int
+}
+
+func (p *postorder) load(node parlex.ParseNode) int {
+	if isNilNode(node) {
+		return -1
+	}
+	left := -1
+	for i := 0; i < node.Children(); i++ {
+		if l := p.load(node.Child(i)); left < 0 {
+			left = l
+		}
+	}
+	if left < 0 {
+		left = len(p.nodes)
+	}
+	p.nodes = append(p.nodes, node)
+	p.left = append(p.left, left)
+	return left
+}
+
+// keyroots are the root and every node that has a left sibling.
+func (p *postorder) keyroots() []int {
+	last := make(map[int]int)
+	for i, l := range p.left {
+		last[l] = i
+	}
+	var roots []int
+	for i, l := range p.left {
+		if last[l] == i {
+			roots = append(roots, i)
+		}
+	}
+	return roots
+}
+
+type differ struct {
+	a, b  postorder
+	td    [][]int
+	edits []Edit
+}
+
+func (d *differ) relabel(i, j int) int {
+	a, b := d.a.nodes[i], d.b.nodes[j]
+	if a.Kind().String() == b.Kind().String() && a.Value() == b.Value() {
+		return 0
+	}
+	return 1
+}
+
+// forest computes the distance between the forests a[i1:i2+1] and b[j1:j2+1].
+// Where both forests are whole trees, the tree distance is recorded. The
+// returned table is offset by one so fd[0][0] is the distance between empty
+// forests.
+func (d *differ) forest(i1, i2, j1, j2 int) [][]int {
+	fd := make([][]int, i2-i1+2)
+	for x := range fd {
+		fd[x] = make([]int, j2-j1+2)
+		fd[x][0] = x
+	}
+	for y := range fd[0] {
+		fd[0][y] = y
+	}
+	for i := i1; i <= i2; i++ {
+		x := i - i1 + 1
+		for j := j1; j <= j2; j++ {
+			y := j - j1 + 1
+			best := fd[x-1][y] + 1
+			if ins := fd[x][y-1] + 1; ins < best {
+				best = ins
+			}
+			if d.a.left[i] == i1 && d.b.left[j] == j1 {
+				if m := fd[x-1][y-1] + d.relabel(i, j); m < best {
+					best = m
+				}
+				fd[x][y] = best
+				d.td[i][j] = best
+				continue
+			}
+			if m := fd[d.a.left[i]-i1][d.b.left[j]-j1] + d.td[i][j]; m < best {
+				best = m
+			}
+			fd[x][y] = best
+		}
+	}
+	return fd
+}
+
+// backtrack finds the edits between two forests from the right.
+func (d *differ) backtrack(i1, i2, j1, j2 int) {
+	fd := d.forest(i1, i2, j1, j2)
+	i, j := i2, j2
+	for i >= i1 || j >= j1 {
+		x, y := i-i1+1, j-j1+1
+		if i >= i1 && j >= j1 {
+			li, lj := d.a.left[i], d.b.left[j]
+			if li == i1 && lj == j1 {
+				if r := d.relabel(i, j); fd[x][y] == fd[x-1][y-1]+r {
+					if r > 0 {
+						d.edits = append(d.edits, Edit{Op: Relabel, A: d.a.nodes[i], B: d.b.nodes[j]})
+					}
+					i, j = i-1, j-1
+					continue
+				}
+			} else if fd[x][y] == fd[li-i1][lj-j1]+d.td[i][j] {
+				d.backtrack(li, i, lj, j)
+				i, j = li-1, lj-1
+				continue
+			}
+		}
+		if i >= i1 && fd[x][y] == fd[x-1][y]+1 {
+			d.edits = append(d.edits, Edit{Op: Delete, A: d.a.nodes[i]})
+			i--
+		} else {
+			d.edits = append(d.edits, Edit{Op: Insert, B: d.b.nodes[j]})
+			j--
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package tree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func diffStrings(a, b string) []string {
+	ta, _ := FromSExpr(a, nil)
+	tb, _ := FromSExpr(b, nil)
+	var strs []string
+	for _, e := range Diff(ta, tb) {
+		strs = append(strs, e.String())
+	}
+	return strs
+}
+
+func TestDiff(t *testing.T) {
+	assert.Nil(t, diffStrings(`(E (int "1") + (int "2"))`, `(E (int "1") + (int "2"))`))
+
+	assert.Equal(t, []string{`relabel int: 2 -> int: 3`},
+		diffStrings(`(E (int "1") + (int "2"))`, `(E (int "1") + (int "3"))`))
+
+	assert.Equal(t, []string{`delete T`},
+		diffStrings(`(E (T (int "1") * (int "2")))`, `(E (int "1") * (int "2"))`))
+
+	assert.Equal(t, []string{`insert P`},
+		diffStrings(`(E a b c)`, `(E a (P b c))`))
+
+	assert.Equal(t, []string{`delete (`, `relabel ) -> x`},
+		diffStrings(`(E \( a \))`, `(E a x)`))
+
+	assert.Equal(t, []string{`delete B`, `insert C`},
+		diffStrings(`(A (B x y) z)`, `(A x (C y z))`))
+
+	a, _ := FromSExpr(`(E (int "1"))`, nil)
+	edits := Diff(a, nil)
+	if assert.Len(t, edits, 2) {
+		assert.Equal(t, Delete, edits[0].Op)
+		assert.Equal(t, a.C[0], edits[0].A)
+		assert.Equal(t, a, edits[1].A)
+	}
+	assert.Len(t, Diff(nil, a), 2)
+	assert.Nil(t, Diff(nil, nil))
+	assert.Equal(t, "relabel", Relabel.String())
+}
@@ -0,0 +1,45 @@
+package tree
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"strings"
+)
+
+// ToDot returns the tree as a Graphviz DOT graph. Non-terminals are labeled with
+// their kind and leaves are labeled with their value, or their kind if they
+// have no value. Leaves are drawn as boxes.
+//   dot -Tsvg tree.dot > tree.svg
+func ToDot(node parlex.ParseNode) string {
+	d := &dotOp{}
+	d.WriteString("digraph {\n")
+	if node != nil {
+		d.node(node)
+	}
+	d.WriteString("}\n")
+	return d.String()
+}
+
+type dotOp struct {
+	strings.Builder
+	next int
+}
+
+func (d *dotOp) node(node parlex.ParseNode) int {
+	id := d.next
+	d.next++
+	if node.Children() == 0 {
+		label := node.Value()
+		if label == "" {
+			label = node.Kind().String()
+		}
+		fmt.Fprintf(d, "\tn%d [label=%q shape=box];\n", id, label)
+		return id
+	}
+	fmt.Fprintf(d, "\tn%d [label=%q];\n", id, node.Kind().String())
+	for i := 0; i < node.Children(); i++ {
+		c := d.node(node.Child(i))
+		fmt.Fprintf(d, "\tn%d -> n%d;\n", id, c)
+	}
+	return id
+}
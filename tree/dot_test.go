@@ -0,0 +1,37 @@
+package tree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestToDot(t *testing.T) {
+	pn, err := New(`
+    E {
+      int: "1"
+      op: "+"
+      E {
+        Empty
+        str: "\"x\""
+      }
+    }
+  `)
+	assert.NoError(t, err)
+
+	expected := `digraph {
+	n0 [label="E"];
+	n1 [label="1" shape=box];
+	n0 -> n1;
+	n2 [label="+" shape=box];
+	n0 -> n2;
+	n3 [label="E"];
+	n4 [label="Empty" shape=box];
+	n3 -> n4;
+	n5 [label="\"x\"" shape=box];
+	n3 -> n5;
+	n0 -> n3;
+}
+`
+	assert.Equal(t, expected, ToDot(pn))
+	assert.Equal(t, "digraph {\n}\n", ToDot(nil))
+}
@@ -0,0 +1,86 @@
+package tree
+
+import (
+	"encoding/json"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+)
+
+// jsonPN is the form a *PN takes when encoded as JSON.
+type jsonPN struct {
+	Kind     string    `json:"kind"`
+	Value    string    `json:"value,omitempty"`
+	Span     Span      `json:"span"`
+	Children []*jsonPN `json:"children,omitempty"`
+}
+
+func toJSON(p *PN) *jsonPN {
+	j := &jsonPN{
+		Kind:  p.Kind().String(),
+		Value: p.Value(),
+		Span:  p.S,
+	}
+	if len(p.C) > 0 {
+		j.Children = make([]*jsonPN, len(p.C))
+		for i, c := range p.C {
+			j.Children[i] = toJSON(c)
+		}
+	}
+	return j
+}
+
+func (j *jsonPN) toPN(parent *PN, symbol func(string) parlex.Symbol) *PN {
+	lx := lexeme.New(symbol(j.Kind)).Set(j.Value)
+	if !j.Span.Empty() {
+		lx.AtOffset(j.Span.Start)
+	}
+	pn := &PN{
+		Lexeme: lx,
+		P:      parent,
+		S:      j.Span,
+	}
+	if len(j.Children) > 0 {
+		pn.C = make([]*PN, len(j.Children))
+		for i, c := range j.Children {
+			pn.C[i] = c.toPN(pn, symbol)
+		}
+	}
+	return pn
+}
+
+// MarshalJSON encodes the tree as nested objects with the fields kind, value,
+// span and children. Value and children are omitted when empty.
+func (p *PN) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toJSON(p))
+}
+
+// UnmarshalJSON decodes a tree written by MarshalJSON. The kinds are decoded
+// as stringsymbol.Symbol; use FromJSON to decode the kinds into a symbol set.
+func (p *PN) UnmarshalJSON(data []byte) error {
+	j := &jsonPN{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return err
+	}
+	*p = *j.toPN(nil, func(s string) parlex.Symbol { return stringsymbol.Symbol(s) })
+	for _, c := range p.C {
+		c.P = p
+	}
+	return nil
+}
+
+// FromJSON decodes a tree written by MarshalJSON. The kinds are taken from the
+// set so they are the same symbols used by the lexer and grammar that produced
+// the tree. If set is nil, it behaves the same as UnmarshalJSON.
+func FromJSON(data []byte, set *setsymbol.Set) (*PN, error) {
+	j := &jsonPN{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, err
+	}
+	symbol := func(s string) parlex.Symbol { return stringsymbol.Symbol(s) }
+	if set != nil {
+		symbol = func(s string) parlex.Symbol { return set.Str(s) }
+	}
+	return j.toPN(nil, symbol), nil
+}
@@ -0,0 +1,55 @@
+package tree
+
+import (
+	"encoding/json"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	set := setsymbol.New()
+	leaf := func(kind, val string, offset int) *PN {
+		pn := &PN{Lexeme: lexeme.New(set.Str(kind)).Set(val).AtOffset(offset)}
+		pn.UpdateSpan()
+		return pn
+	}
+	pn := &PN{
+		Lexeme: lexeme.New(set.Str("E")),
+		C: []*PN{
+			leaf("int", "1", 0),
+			leaf("op", "+", 1),
+			leaf("int", "23", 2),
+		},
+	}
+	for _, c := range pn.C {
+		c.P = pn
+	}
+	pn.UpdateSpan()
+
+	data, err := json.Marshal(pn)
+	assert.NoError(t, err)
+	expected := `{"kind":"E","span":{"start":0,"end":4},"children":[` +
+		`{"kind":"int","value":"1","span":{"start":0,"end":1}},` +
+		`{"kind":"op","value":"+","span":{"start":1,"end":2}},` +
+		`{"kind":"int","value":"23","span":{"start":2,"end":4}}]}`
+	assert.Equal(t, expected, string(data))
+
+	var decoded PN
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, pn.String(), decoded.String())
+	assert.Equal(t, pn.S, decoded.S)
+	assert.Equal(t, &decoded, decoded.C[1].P)
+	assert.Equal(t, 2, decoded.C[2].Offset())
+
+	fromSet, err := FromJSON(data, set)
+	assert.NoError(t, err)
+	assert.Equal(t, pn.String(), fromSet.String())
+	assert.Equal(t, set.Str("int").Idx(), fromSet.C[2].Kind().(*setsymbol.Symbol).Idx())
+	assert.Equal(t, pn.C[1].Kind(), fromSet.C[1].Kind())
+	assert.Equal(t, fromSet, fromSet.C[0].P)
+
+	_, err = FromJSON([]byte(`{"kind":`), set)
+	assert.Error(t, err)
+}
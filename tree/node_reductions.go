@@ -1,16 +1,22 @@
 package tree
 
 import (
+	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/lexeme"
 )
 
-// ReplaceWithChild replaces the node with the child at cIdx.
+// ReplaceWithChild replaces the node with the child at cIdx. The node keeps
+// the span it covered before the replacement.
 func (p *PN) ReplaceWithChild(cIdx int) bool {
 	cIdx, _, ok := p.GetIdx(cIdx)
 	if !ok {
 		return false
 	}
+	s, parent := p.S, p.P
 	*p = *(p.C[cIdx])
+	p.S = p.S.Merge(s)
+	p.P = parent
+	p.adopt()
 	return true
 }
 
@@ -53,6 +59,7 @@ func (p *PN) RemoveChild(cIdx int) bool {
 	if !ok {
 		return false
 	}
+	p.C[cIdx].P = nil
 	if cIdx == l-1 {
 		p.C = p.C[:cIdx]
 	} else {
@@ -104,6 +111,9 @@ func (p *PN) PromoteChildrenOf(cIdx int) bool {
 	if !ok {
 		return false
 	}
+	for _, gc := range p.C[cIdx].C {
+		gc.P = p
+	}
 	if cIdx == l-1 {
 		p.C = append(p.C[:cIdx], p.C[cIdx].C...)
 	} else {
@@ -139,13 +149,103 @@ func (p *PN) PromoteChild(cIdx int) bool {
 	}
 
 	p.Lexeme = p.C[cIdx].Lexeme
+	p.prod = p.C[cIdx].prod
 	tail := p.C[cIdx].C
 	if cIdx+1 < l {
 		tail = append(tail, p.C[cIdx+1:]...)
 	}
 	p.C = append(p.C[0:cIdx], tail...)
+	p.adopt()
+	return true
+}
+
+// Flatten replaces each child of the same kind as the node with its children.
+// It is recursive, so a child of the same kind under one that is replaced is
+// also replaced.
+func (p *PN) Flatten() {
+	kind := p.Kind().String()
+	cs := make([]*PN, 0, len(p.C))
 	for _, c := range p.C {
-		c.P = p
+		if c.Kind().String() == kind {
+			c.Flatten()
+			cs = append(cs, c.C...)
+		} else {
+			cs = append(cs, c)
+		}
+	}
+	p.C = cs
+	p.adopt()
+}
+
+// Rename changes the kind of the node, keeping its value and position.
+func (p *PN) Rename(kind string) {
+	lx := lexeme.Copy(p.Lexeme)
+	lx.K = lexeme.String(kind).Kind()
+	p.Lexeme = lx
+}
+
+// MergeAdjacent joins each run of adjacent children of the kind that have no
+// children into a single child. The values are concatenated and the span covers
+// the whole run.
+func (p *PN) MergeAdjacent(kind string) {
+	cs := make([]*PN, 0, len(p.C))
+	for i := 0; i < len(p.C); i++ {
+		c := p.C[i]
+		j := i + 1
+		for c.isLeaf(kind) && j < len(p.C) && p.C[j].isLeaf(kind) {
+			j++
+		}
+		if j-i > 1 {
+			lx := lexeme.Copy(c.Lexeme)
+			s := c.S
+			for _, m := range p.C[i+1 : j] {
+				lx.V += m.Value()
+				s = s.Merge(m.S)
+				m.P = nil
+			}
+			lx.Trail = parlex.Trailing(p.C[j-1].Lexeme)
+			c = &PN{
+				Lexeme: lx,
+				P:      p,
+				S:      s,
+			}
+			i = j - 1
+		}
+		cs = append(cs, c)
 	}
+	p.C = cs
+}
+
+func (p *PN) isLeaf(kind string) bool {
+	return len(p.C) == 0 && p.Kind().String() == kind
+}
+
+// Lift replaces the node with the child at cIdx, the same as ReplaceWithChild,
+// but the other children are kept and appended after the children of the
+// lifted child. The cIdx value uses GetIdx.
+func (p *PN) Lift(cIdx int) bool {
+	cIdx, _, ok := p.GetIdx(cIdx)
+	if !ok {
+		return false
+	}
+	lifted := p.C[cIdx]
+	rest := append(append([]*PN(nil), p.C[:cIdx]...), p.C[cIdx+1:]...)
+	p.Lexeme = lifted.Lexeme
+	p.C = append(lifted.C, rest...)
+	p.S = p.S.Merge(lifted.S)
+	p.adopt()
 	return true
 }
+
+// adopt sets the parent of each child to the node. A child without a source,
+// like one made by a reduction, gets the source of the node.
+func (p *PN) adopt() {
+	for _, c := range p.C {
+		if c != nil {
+			c.P = p
+			if c.src == nil {
+				c.src = p.src
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"io"
+)
+
+// Order is when a reduction runs relative to the reductions of the children of
+// its node.
+type Order byte
+
+// Orders of reduction
+const (
+	// PostOrder runs a reduction after the children are reduced, so it sees
+	// the reduced children. It is the order Reducer uses.
+	PostOrder Order = iota
+	// PreOrder runs a reduction before the children are reduced, so it sees
+	// the children as they were parsed. The children the node has after the
+	// reduction are the ones that are reduced. If the reduction changes the
+	// kind of the node, the reduction for the new kind is not run.
+	PreOrder
+)
+
+func (o Order) String() string {
+	if o == PreOrder {
+		return "PreOrder"
+	}
+	return "PostOrder"
+}
+
+// Ordered reduces a tree with a Reducer, choosing the order each reduction runs
+// in. Orders sets the order for a kind and Order is used for every kind that is
+// not in Orders. It implements parlex.Reducer.
+//   tree.Ordered{Reducer: rdcr, Order: tree.PreOrder}.Reduce(pn)
+type Ordered struct {
+	Reducer Reducer
+	Order   Order
+	Orders  map[string]Order
+}
+
+// Can returns true if the Reducer has a rule for the given node.
+func (o Ordered) Can(node parlex.ParseNode) bool {
+	return o.Reducer.Can(node)
+}
+
+// Reduce performs a reduction on a copy of the tree, the same as
+// Reducer.Reduce, but each reduction runs in its order.
+func (o Ordered) Reduce(node parlex.ParseNode) parlex.ParseNode {
+	if node == nil {
+		return nil
+	}
+	return o.RawReduce(node)
+}
+
+// RawReduce performs a reduction the same way as Reduce, but the result comes
+// back as the concrete type *PN.
+func (o Ordered) RawReduce(node parlex.ParseNode) *PN {
+	if node == nil {
+		return nil
+	}
+	cp := Copy(node)
+	o.inPlace(o.Reducer.op(nil), cp)
+	return cp
+}
+
+// ReduceSource performs a reduction the same as Reduce and gives the nodes the
+// source they were parsed from, the same as Reducer.ReduceSource.
+func (o Ordered) ReduceSource(node parlex.ParseNode, src string) parlex.ParseNode {
+	if node == nil {
+		return nil
+	}
+	cp := Copy(node)
+	cp.SetSource(NewSource(src))
+	o.inPlace(o.Reducer.op(nil), cp)
+	return cp
+}
+
+// Trace performs a reduction the same as RawReduce and records the steps the
+// same as Reducer.Trace.
+func (o Ordered) Trace(node parlex.ParseNode, w io.Writer) (*PN, Trace) {
+	if node == nil {
+		return nil, nil
+	}
+	t := &tracer{w: w}
+	cp := Copy(node)
+	o.inPlace(o.Reducer.op(t), cp)
+	return cp, t.steps
+}
+
+func (o Ordered) order(kind string) Order {
+	if order, ok := o.Orders[kind]; ok {
+		return order
+	}
+	return o.Order
+}
+
+// inPlace reduces a tree that is already a copy.
+func (o Ordered) inPlace(op *reduceOp, node *PN) {
+	kind := node.Kind().String()
+	reduction := op.reduction(kind)
+	pre := reduction != nil && o.order(kind) == PreOrder
+	if pre {
+		op.apply(node, reduction)
+	}
+	for _, c := range node.C {
+		o.inPlace(op, c)
+	}
+	node.adopt()
+	if reduction != nil && !pre {
+		op.apply(node, reduction)
+	}
+}
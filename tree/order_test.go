@@ -0,0 +1,47 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOrdered(t *testing.T) {
+	pn, err := FromSExpr(`(S (E (T (int "1"))) (E (T (int "2"))))`, nil)
+	assert.NoError(t, err)
+
+	rdcr := Reducer{
+		"E": If(ChildIs(0, "T"), Rename("ET"), nil),
+		"T": PromoteSingleChild,
+	}
+
+	assert.Equal(t, `(S (E (int "1")) (E (int "2")))`, ToSExpr(rdcr.Reduce(pn)))
+	assert.Equal(t, `(S (E (int "1")) (E (int "2")))`, ToSExpr(Ordered{Reducer: rdcr}.Reduce(pn)))
+	assert.Equal(t, `(S (ET (int "1")) (ET (int "2")))`, ToSExpr(Ordered{Reducer: rdcr, Order: PreOrder}.Reduce(pn)))
+
+	o := Ordered{
+		Reducer: rdcr,
+		Orders:  map[string]Order{"E": PreOrder},
+	}
+	out := o.RawReduce(pn)
+	assert.Equal(t, `(S (ET (int "1")) (ET (int "2")))`, ToSExpr(out))
+	for _, c := range out.C {
+		assert.Equal(t, out, c.P)
+	}
+
+	_, trace := o.Trace(pn, nil)
+	if assert.Len(t, trace, 4) {
+		assert.Equal(t, "E", trace[0].Entry)
+		assert.Equal(t, "T", trace[1].Entry)
+	}
+
+	// the reduction for the new kind is not run
+	rdcr = Reducer{
+		"E": PromoteSingleChild,
+		"T": RemoveChild(0),
+	}
+	assert.Equal(t, `(S (T (int "1")) (T (int "2")))`, ToSExpr(Ordered{Reducer: rdcr, Order: PreOrder}.Reduce(pn)))
+	assert.Nil(t, Ordered{}.Reduce(nil))
+}
+
+var _ parlex.Reducer = Ordered{}
@@ -47,11 +47,29 @@ func New(str string) (*PN, error) {
 	return cur, nil
 }
 
-// PN parse node forms a tree
+// PN parse node forms a tree. S is the span of the input covered by the node.
 type PN struct {
 	parlex.Lexeme
-	P *PN
-	C []*PN
+	P   *PN
+	C   []*PN
+	S   Span
+	src *Source
+	// prod is the index of the production plus one, so the zero value is not
+	// known
+	prod int
+}
+
+// Production returns the index of the production of the node's non-terminal
+// that produced it, or -1 if it is not known. It fulfills
+// parlex.ProductionNode.
+func (p *PN) Production() int {
+	return p.prod - 1
+}
+
+// SetProduction records the index of the production that produced the node.
+func (p *PN) SetProduction(idx int) *PN {
+	p.prod = idx + 1
+	return p
 }
 
 // Parent returns a reference to the nodes parent. If parent is nil, this is the
@@ -125,6 +143,44 @@ func (p *PN) sliceReq() int {
 	return r
 }
 
+// Index returns the position of the node in its parent's children. If the node
+// is the root, -1 is returned.
+func (p *PN) Index() int {
+	if p.P == nil {
+		return -1
+	}
+	for i, c := range p.P.C {
+		if c == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// NextSibling returns the child of the node's parent after the node or nil if
+// it is the last child or the root.
+func (p *PN) NextSibling() *PN {
+	return p.sibling(1)
+}
+
+// PrevSibling returns the child of the node's parent before the node or nil if
+// it is the first child or the root.
+func (p *PN) PrevSibling() *PN {
+	return p.sibling(-1)
+}
+
+func (p *PN) sibling(offset int) *PN {
+	idx := p.Index()
+	if idx < 0 {
+		return nil
+	}
+	idx += offset
+	if idx < 0 || idx >= len(p.P.C) {
+		return nil
+	}
+	return p.P.C[idx]
+}
+
 // Size counts the number of nodes in a tree
 func (p *PN) Size() int {
 	if p == nil {
@@ -153,6 +209,21 @@ func (p *PN) ChildAt(cIdx int, symbs ...string) bool {
 	return false
 }
 
+// Copy copies a tree the same as Clone but keeps the positions of the lexemes.
+func Copy(node parlex.ParseNode) *PN {
+	cp := &PN{
+		Lexeme: lexeme.Copy(node),
+		C:      make([]*PN, node.Children()),
+		S:      SpanOf(node),
+		prod:   parlex.ProductionOf(node) + 1,
+	}
+	for i := range cp.C {
+		cp.C[i] = Copy(node.Child(i))
+	}
+	cp.adopt()
+	return cp
+}
+
 // Clone takes a node and clones it and all it's children.
 func Clone(node parlex.ParseNode) *PN {
 	pn := &PN{
@@ -160,7 +231,9 @@ func Clone(node parlex.ParseNode) *PN {
 			K: node.Kind(),
 			V: node.Value(),
 		},
-		C: make([]*PN, node.Children()),
+		C:    make([]*PN, node.Children()),
+		S:    SpanOf(node),
+		prod: parlex.ProductionOf(node) + 1,
 	}
 	for i := 0; i < node.Children(); i++ {
 		c := Clone(node.Child(i))
@@ -169,3 +242,25 @@ func Clone(node parlex.ParseNode) *PN {
 	}
 	return pn
 }
+
+// Substitute copies a tree the same as Copy, but a node that fn returns a
+// replacement for is replaced with a copy of the replacement, so one
+// replacement can be used in many places and changing the result never changes
+// it. The children of a replaced node are not visited. fn returns nil to keep a
+// node. The result can be reduced again, for instance to expand a macro.
+func Substitute(node parlex.ParseNode, fn func(node parlex.ParseNode) parlex.ParseNode) *PN {
+	if r := fn(node); r != nil {
+		return Copy(r)
+	}
+	cp := &PN{
+		Lexeme: lexeme.Copy(node),
+		C:      make([]*PN, node.Children()),
+		S:      SpanOf(node),
+		prod:   parlex.ProductionOf(node) + 1,
+	}
+	for i := range cp.C {
+		cp.C[i] = Substitute(node.Child(i), fn)
+	}
+	cp.adopt()
+	return cp
+}
@@ -107,4 +107,34 @@ func TestParseNodeClone(t *testing.T) {
 	assert.NoError(t, err)
 	pn2 := Clone(pn1)
 	assert.Equal(t, pn1.String(), pn2.String())
-}
\ No newline at end of file
+}
+
+func TestSubstitute(t *testing.T) {
+	pn, _ := FromSExpr(`(E x (op "+") (E x (op "*") (int "2")))`, nil)
+	arg, _ := FromSExpr(`(E (int "1") (op "-") (int "3"))`, nil)
+	got := Substitute(pn, func(node parlex.ParseNode) parlex.ParseNode {
+		if node.Kind().String() == "x" {
+			return arg
+		}
+		return nil
+	})
+	assert.Equal(t, `(E (E (int "1") (op "-") (int "3")) (op "+") (E (E (int "1") (op "-") (int "3")) (op "*") (int "2")))`, ToSExpr(got))
+	assert.Equal(t, `(E x (op "+") (E x (op "*") (int "2")))`, ToSExpr(pn))
+	assert.False(t, got.C[0] == got.C[2].C[0])
+	assert.True(t, got.C[2].C[0].P == got.C[2])
+	assert.Nil(t, got.P)
+}
+
+func TestSiblings(t *testing.T) {
+	pn, _ := FromSExpr(`(E (A a) b (C c))`, nil)
+	a, b, c := pn.C[0], pn.C[1], pn.C[2]
+	assert.Equal(t, -1, pn.Index())
+	assert.Equal(t, 1, b.Index())
+	assert.Equal(t, b, a.NextSibling())
+	assert.Equal(t, c, b.NextSibling())
+	assert.Nil(t, c.NextSibling())
+	assert.Equal(t, b, c.PrevSibling())
+	assert.Nil(t, a.PrevSibling())
+	assert.Nil(t, pn.NextSibling())
+	assert.Equal(t, pn, b.Parent())
+}
@@ -0,0 +1,64 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+)
+
+// MarkProductions records on each node of a parse tree the index of the
+// production that produced it, so it can be read with Production. The
+// production is found by matching the kinds of the node's children against the
+// productions of the node's non-terminal, so it must be run on the tree as it
+// came from the parser, before it is reduced. A node that does not match any
+// production is left unknown.
+func MarkProductions(grammar parlex.Grammar, node *PN) {
+	if node == nil {
+		return
+	}
+	if prods := grammar.Productions(node.Kind()); prods != nil {
+		for i := prods.Iter(); i.Next(); {
+			if matches(i.Production, node) {
+				node.SetProduction(i.Idx)
+				break
+			}
+		}
+	}
+	for _, c := range node.C {
+		MarkProductions(grammar, c)
+	}
+}
+
+func matches(prod parlex.Production, node *PN) bool {
+	if prod.Symbols() != len(node.C) {
+		return false
+	}
+	for i := prod.Iter(); i.Next(); {
+		if i.Symbol.String() != node.C[i.Idx].Kind().String() {
+			return false
+		}
+	}
+	return true
+}
+
+// Productions returns parlex.Middleware that runs MarkProductions on every
+// tree that is parsed, before it is reduced. With it, a reduction can tell
+// which production built a node with Production instead of looking at the
+// children. If the parser does not return a *PN, the tree is copied.
+//   runner := parlex.New(lxr, prsr, rdcr, tree.Productions(grmr))
+func Productions(grammar parlex.Grammar) parlex.Middleware {
+	return parlex.Middleware{
+		Parse: func(next parlex.ParseFunc) parlex.ParseFunc {
+			return func(lexemes []parlex.Lexeme) (parlex.ParseNode, error) {
+				node, err := next(lexemes)
+				if err != nil || node == nil {
+					return node, err
+				}
+				pn, ok := node.(*PN)
+				if !ok {
+					pn = Copy(node)
+				}
+				MarkProductions(grammar, pn)
+				return pn, nil
+			}
+		},
+	}
+}
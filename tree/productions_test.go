@@ -0,0 +1,100 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var prodGrammar = testGrammar{
+	nts: []parlex.Symbol{stringsymbol.Symbol("E"), stringsymbol.Symbol("P")},
+	prods: map[string]stringsymbol.Productions{
+		"E": {
+			{"E", "op", "E"},
+			{"P"},
+			{"int"},
+		},
+		"P": {
+			{"lp", "E", "rp"},
+		},
+	},
+}
+
+var prodTree = `
+  E {
+    E {
+      int: "1"
+    }
+    op: "+"
+    E {
+      P {
+        lp: "("
+        E {
+          int: "2"
+        }
+        rp: ")"
+      }
+    }
+  }
+`
+
+func TestMarkProductions(t *testing.T) {
+	pn, err := New(prodTree)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, pn.Production())
+
+	MarkProductions(prodGrammar, pn)
+	assert.Equal(t, 0, pn.Production())
+	assert.Equal(t, 2, pn.C[0].Production())
+	assert.Equal(t, -1, pn.C[1].Production())
+	assert.Equal(t, 1, pn.C[2].Production())
+	assert.Equal(t, 0, pn.C[2].C[0].Production())
+	assert.Equal(t, 2, pn.C[2].C[0].C[1].Production())
+	assert.Equal(t, 2, parlex.ProductionOf(pn.C[0]))
+
+	// copies keep the production and a promoted child brings its own
+	assert.Equal(t, 1, Copy(pn).C[2].Production())
+	e := Copy(pn.C[2])
+	e.PromoteSingleChild()
+	assert.Equal(t, "P", e.Kind().String())
+	assert.Equal(t, 0, e.Production())
+
+	bad, err := New(`
+    E {
+      int: "1"
+      int: "2"
+    }
+  `)
+	assert.NoError(t, err)
+	MarkProductions(prodGrammar, bad)
+	assert.Equal(t, -1, bad.Production())
+}
+
+func TestProductions(t *testing.T) {
+	var prods []int
+	r := Reducer{
+		"E": func(node *PN) {
+			prods = append(prods, node.Production())
+			if node.Production() == 1 {
+				node.PromoteSingleChild()
+			}
+		},
+	}
+	parse := Productions(prodGrammar).Parse(func([]parlex.Lexeme) (parlex.ParseNode, error) {
+		return New(prodTree)
+	})
+	pn, err := parse(nil)
+	assert.NoError(t, err)
+	pn = r.Reduce(pn)
+	assert.Equal(t, []int{2, 2, 1, 0}, prods)
+	assert.Equal(t, "P", pn.Child(2).Kind().String())
+	assert.Equal(t, 0, parlex.ProductionOf(pn.Child(2)))
+
+	parse = Productions(prodGrammar).Parse(func([]parlex.Lexeme) (parlex.ParseNode, error) {
+		return nil, parlex.ErrCouldNotParse
+	})
+	pn, err = parse(nil)
+	assert.Nil(t, pn)
+	assert.Equal(t, parlex.ErrCouldNotParse, err)
+}
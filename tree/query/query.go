@@ -0,0 +1,350 @@
+// Package query selects nodes from a parse tree using paths similar to XPath.
+//
+// A path is a list of steps separated by / to select children or // to select
+// descendants. Each step names a kind or uses * to match any kind. The first
+// step is matched against the root, so "Stack/Number" selects the Number
+// children of a root of kind Stack. A path starting with // searches the whole
+// tree.
+//
+// Steps can be followed by predicates in square brackets:
+//   [2]             the third node matched by the step, negative counts from
+//                   the end
+//   [kind='int']    the kind of the node
+//   [value='+']     the value of the node
+//   [int]           the node has a child matching the path
+//   [int='1']       the node has a child matching the path with the value
+// Comparisons can use = or != and strings can be quoted with ' or ".
+package query
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled path.
+type Query struct {
+	path  string
+	steps []*step
+}
+
+// Compile parses a path into a Query.
+func Compile(path string) (*Query, error) {
+	p := &parser{s: path}
+	steps, err := p.path()
+	if err == nil && p.pos < len(p.s) {
+		err = p.err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Query{
+		path:  path,
+		steps: steps,
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the path cannot be parsed.
+func MustCompile(path string) *Query {
+	q, err := Compile(path)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Select compiles the path and returns the matching nodes.
+func Select(root parlex.ParseNode, path string) ([]parlex.ParseNode, error) {
+	q, err := Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	return q.Select(root), nil
+}
+
+// String returns the path the Query was compiled from.
+func (q *Query) String() string { return q.path }
+
+// Select returns the nodes matching the query in the order they are found.
+func (q *Query) Select(root parlex.ParseNode) []parlex.ParseNode {
+	if root == nil {
+		return nil
+	}
+	return eval(q.steps, []parlex.ParseNode{root}, true)
+}
+
+// First returns the first node matching the query or nil if there is no match.
+func (q *Query) First(root parlex.ParseNode) parlex.ParseNode {
+	if ns := q.Select(root); len(ns) > 0 {
+		return ns[0]
+	}
+	return nil
+}
+
+type step struct {
+	desc  bool
+	kind  string
+	preds []pred
+}
+
+// pred filters the nodes matched by a step.
+type pred interface {
+	filter(ns []parlex.ParseNode) []parlex.ParseNode
+}
+
+type idxPred int
+
+func (p idxPred) filter(ns []parlex.ParseNode) []parlex.ParseNode {
+	i := int(p)
+	if i < 0 {
+		i += len(ns)
+	}
+	if i < 0 || i >= len(ns) {
+		return nil
+	}
+	return ns[i : i+1]
+}
+
+type cmp struct {
+	neg bool
+	val string
+}
+
+func (c *cmp) match(str string) bool {
+	return (str == c.val) != c.neg
+}
+
+type attrPred struct {
+	kind bool
+	*cmp
+}
+
+func (p attrPred) filter(ns []parlex.ParseNode) []parlex.ParseNode {
+	var out []parlex.ParseNode
+	for _, n := range ns {
+		str := n.Value()
+		if p.kind {
+			str = n.Kind().String()
+		}
+		if p.match(str) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+type pathPred struct {
+	steps []*step
+	*cmp
+}
+
+func (p pathPred) filter(ns []parlex.ParseNode) []parlex.ParseNode {
+	var out []parlex.ParseNode
+	for _, n := range ns {
+		for _, m := range eval(p.steps, []parlex.ParseNode{n}, false) {
+			if p.cmp == nil || p.match(m.Value()) {
+				out = append(out, n)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// eval applies the steps starting from the context nodes. If doc is true, the
+// context nodes are treated as the children of the first step's context so the
+// first step is matched against them.
+func eval(steps []*step, ctx []parlex.ParseNode, doc bool) []parlex.ParseNode {
+	for i, s := range steps {
+		var next []parlex.ParseNode
+		seen := make(map[parlex.ParseNode]bool)
+		for _, c := range ctx {
+			for _, n := range s.match(c, doc && i == 0) {
+				if !seen[n] {
+					seen[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		ctx = next
+	}
+	return ctx
+}
+
+func (s *step) match(ctx parlex.ParseNode, self bool) []parlex.ParseNode {
+	var ns []parlex.ParseNode
+	if self {
+		ns = append(ns, ctx)
+	}
+	if s.desc || !self {
+		for i := 0; i < ctx.Children(); i++ {
+			if c := ctx.Child(i); c != nil {
+				ns = append(ns, c)
+				if s.desc {
+					ns = descendants(c, ns)
+				}
+			}
+		}
+	}
+	if s.kind != "*" {
+		out := ns[:0]
+		for _, n := range ns {
+			if n.Kind().String() == s.kind {
+				out = append(out, n)
+			}
+		}
+		ns = out
+	}
+	for _, p := range s.preds {
+		ns = p.filter(ns)
+	}
+	return ns
+}
+
+func descendants(node parlex.ParseNode, ns []parlex.ParseNode) []parlex.ParseNode {
+	for i := 0; i < node.Children(); i++ {
+		if c := node.Child(i); c != nil {
+			ns = descendants(c, append(ns, c))
+		}
+	}
+	return ns
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) err() error {
+	if p.pos >= len(p.s) {
+		return fmt.Errorf("Bad Query: unexpected end of %q", p.s)
+	}
+	return fmt.Errorf("Bad Query: unexpected %q at %d in %q", p.s[p.pos], p.pos, p.s)
+}
+
+func (p *parser) peek(str string) bool {
+	return strings.HasPrefix(p.s[p.pos:], str)
+}
+
+func (p *parser) space() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) path() ([]*step, error) {
+	var steps []*step
+	desc := false
+	if p.peek("//") {
+		desc = true
+		p.pos += 2
+	} else if p.peek("/") {
+		p.pos++
+	}
+	for {
+		s, err := p.step(desc)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+		if p.peek("//") {
+			desc = true
+			p.pos += 2
+		} else if p.peek("/") {
+			desc = false
+			p.pos++
+		} else {
+			return steps, nil
+		}
+	}
+}
+
+const notName = "/[]=!'\" \t"
+
+func (p *parser) step(desc bool) (*step, error) {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(notName, rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.err()
+	}
+	s := &step{
+		desc: desc,
+		kind: p.s[start:p.pos],
+	}
+	for p.peek("[") {
+		p.pos++
+		p.space()
+		pd, err := p.pred()
+		if err != nil {
+			return nil, err
+		}
+		p.space()
+		if !p.peek("]") {
+			return nil, p.err()
+		}
+		p.pos++
+		s.preds = append(s.preds, pd)
+	}
+	return s, nil
+}
+
+func (p *parser) pred() (pred, error) {
+	start := p.pos
+	if p.peek("-") {
+		p.pos++
+	}
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if i, err := strconv.Atoi(p.s[start:p.pos]); err == nil {
+		return idxPred(i), nil
+	}
+	p.pos = start
+
+	steps, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	c, err := p.cmp()
+	if err != nil {
+		return nil, err
+	}
+	if c != nil && len(steps) == 1 && !steps[0].desc && len(steps[0].preds) == 0 {
+		switch steps[0].kind {
+		case "kind", "@kind":
+			return attrPred{kind: true, cmp: c}, nil
+		case "value", "@value":
+			return attrPred{cmp: c}, nil
+		}
+	}
+	return pathPred{steps: steps, cmp: c}, nil
+}
+
+func (p *parser) cmp() (*cmp, error) {
+	c := &cmp{}
+	if p.peek("!=") {
+		c.neg = true
+		p.pos += 2
+	} else if p.peek("=") {
+		p.pos++
+	} else {
+		return nil, nil
+	}
+	p.space()
+	if p.pos >= len(p.s) || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+		return nil, p.err()
+	}
+	q := p.s[p.pos]
+	end := strings.IndexByte(p.s[p.pos+1:], q)
+	if end == -1 {
+		p.pos = len(p.s)
+		return nil, p.err()
+	}
+	c.val = p.s[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return c, nil
+}
@@ -0,0 +1,98 @@
+package query
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var root = func() *tree.PN {
+	pn, err := tree.New(`
+    Stack {
+      Number {
+        int: "1"
+      }
+      bop: "+" {
+        Number {
+          int: "2"
+          dec: ".5"
+        }
+        Number {
+          int: "3"
+        }
+      }
+      Number {
+        int: "4"
+      }
+      smp: "swap"
+    }
+  `)
+	if err != nil {
+		panic(err)
+	}
+	return pn
+}()
+
+func values(ns []parlex.ParseNode) []string {
+	out := make([]string, len(ns))
+	for i, n := range ns {
+		out[i] = n.Kind().String() + ":" + n.Value()
+	}
+	return out
+}
+
+func TestSelect(t *testing.T) {
+	tests := map[string][]string{
+		"Stack":                         {"Stack:"},
+		"/Stack":                        {"Stack:"},
+		"Number":                        {},
+		"Stack/Number/int":              {"int:1", "int:4"},
+		"Stack//int":                    {"int:1", "int:2", "int:3", "int:4"},
+		"//Number/dec":                  {"dec:.5"},
+		"Stack/*[kind='smp']":           {"smp:swap"},
+		"Stack/*[kind!='Number']":       {"bop:+", "smp:swap"},
+		"Stack/bop[value='+']/Number":   {"Number:", "Number:"},
+		"Stack/bop[value=\"-\"]":        {},
+		"Stack/Number[0]/int":           {"int:1"},
+		"Stack/Number[-1]/int":          {"int:4"},
+		"Stack/Number[5]":               {},
+		"//Number[dec]/int":             {"int:2"},
+		"//Number[int='3']":             {"Number:"},
+		"//Number[int!='3'][-1]/int":    {"int:4"},
+		"Stack/bop/Number[ int = '3' ]": {"Number:"},
+		"//*[kind='Number']/int":        {"int:1", "int:2", "int:3", "int:4"},
+	}
+	for path, expected := range tests {
+		ns, err := Select(root, path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, values(ns), path)
+	}
+
+	q := MustCompile("//Number/int")
+	assert.Equal(t, "//Number/int", q.String())
+	assert.Equal(t, "1", q.First(root).Value())
+	assert.Nil(t, MustCompile("Foo").First(root))
+	assert.Nil(t, q.Select(nil))
+}
+
+func TestCompileErr(t *testing.T) {
+	tests := map[string]string{
+		"":                 `Bad Query: unexpected end of ""`,
+		"Stack/":           `Bad Query: unexpected end of "Stack/"`,
+		"Stack[":           `Bad Query: unexpected end of "Stack["`,
+		"Stack[kind='Foo'": `Bad Query: unexpected end of "Stack[kind='Foo'"`,
+		"Stack[kind='Foo]": `Bad Query: unexpected end of "Stack[kind='Foo]"`,
+		"Stack[kind=Foo]":  `Bad Query: unexpected 'F' at 11 in "Stack[kind=Foo]"`,
+		"Stack]":           `Bad Query: unexpected ']' at 5 in "Stack]"`,
+		"Stack/Number Foo": `Bad Query: unexpected ' ' at 12 in "Stack/Number Foo"`,
+	}
+	for path, expected := range tests {
+		q, err := Compile(path)
+		assert.Nil(t, q, path)
+		if assert.Error(t, err, path) {
+			assert.Equal(t, expected, err.Error(), path)
+		}
+	}
+	assert.Panics(t, func() { MustCompile("]") })
+}
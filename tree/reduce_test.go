@@ -2,6 +2,7 @@ package tree
 
 import (
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
@@ -214,4 +215,119 @@ func TestRemoveAll(t *testing.T) {
   }
   pn = r.Reduce(pn).(*PN)
   assert.Len(t, pn.C[0].C, 1)
-}
\ No newline at end of file
+}
+func TestReduceParents(t *testing.T) {
+	pn, _ := FromSExpr(`(E (L (x "1") (y "2")) (R (z "3")) (S (w "4")))`, nil)
+	var seen *PN
+	rdcr := Reducer{
+		"R": func(node *PN) {
+			node.ReplaceWithChild(0)
+		},
+		"E": func(node *PN) {
+			seen = node.C[0].C[0].P
+			node.PromoteChildrenOf(0)
+			node.RemoveChild(-1)
+		},
+	}
+	out := rdcr.RawReduce(pn)
+	assert.Equal(t, `(E (x "1") (y "2") (z "3"))`, ToSExpr(out))
+	assert.Equal(t, "L", seen.Kind().String())
+	assert.Nil(t, out.P)
+	var check func(*PN)
+	check = func(node *PN) {
+		for i, c := range node.C {
+			assert.Equal(t, node, c.P)
+			assert.Equal(t, i, c.Index())
+			check(c)
+		}
+	}
+	check(out)
+	assert.Equal(t, out.C[1], out.C[0].NextSibling())
+}
+
+func TestReduceShared(t *testing.T) {
+	pn, _ := FromSExpr(`(E (L (x "1") (y "2")) (R (z (w "3"))) (S (v "4")))`, nil)
+	before := pn.String()
+	rdcr := Reducer{
+		"R": PromoteChildrenOf(0),
+		"E": RemoveChild(-1).PromoteChildrenOf(0),
+	}
+	out := rdcr.ReduceShared(pn).(*PN)
+	assert.Equal(t, `(E (x "1") (y "2") (R (w "3")))`, ToSExpr(out))
+	assert.Equal(t, ToSExpr(rdcr.Reduce(pn)), ToSExpr(out))
+	assert.Equal(t, before, pn.String())
+	for _, c := range out.C {
+		assert.Equal(t, out, c.P)
+	}
+
+	// a subtree without reductions is shared
+	rdcr = Reducer{
+		"S": RemoveChild(0),
+	}
+	out = rdcr.ReduceShared(pn).(*PN)
+	assert.Equal(t, `(E (L (x "1") (y "2")) (R (z (w "3"))) S)`, ToSExpr(out))
+	assert.True(t, out.C[0] == pn.C[0])
+	assert.True(t, out.C[1] == pn.C[1])
+	assert.Equal(t, out, out.C[2].P)
+	assert.Equal(t, before, pn.String())
+
+	assert.True(t, Reducer{}.ReduceShared(pn) == pn)
+	assert.Nil(t, rdcr.ReduceShared((*PN)(nil)))
+}
+
+func TestReducerPatterns(t *testing.T) {
+	pn, err := FromSExpr(`(Expr (ExprList (T (int "1")) (comma ",") (T (int "2"))) (Neg (minus "-") (int "3")))`, nil)
+	assert.NoError(t, err)
+
+	rdcr := Reducer{
+		"*":        PromoteSingleChild,
+		"Expr*":    RemoveAll("comma"),
+		"ExprLis?": nil,
+		"Neg":      RemoveChild(0),
+	}
+	assert.Nil(t, rdcr.Reduction("ExprList"))
+	assert.NotNil(t, rdcr.Reduction("Exprs"))
+	assert.True(t, rdcr.Can(pn))
+
+	assert.Equal(t, `(Expr (ExprList (int "1") (comma ",") (int "2")) (Neg (int "3")))`, ToSExpr(rdcr.Reduce(pn)))
+	assert.Equal(t, `(Expr (ExprList (int "1") (comma ",") (int "2")) (Neg (int "3")))`, ToSExpr(rdcr.ReduceShared(pn)))
+}
+
+func TestReduceConcurrent(t *testing.T) {
+	src := `
+    E {
+      T {
+        int: "1"
+      }
+      op: "+"
+      E {
+        T {
+          int: "2"
+        }
+      }
+    }
+  `
+	reducer := Reducer{
+		"T": PromoteSingleChild,
+		"E": RemoveAll("op").PromoteSingleChild(),
+	}
+	pn, _ := New(src)
+	expected := reducer.Reduce(pn).(*PN).String()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			pn, _ := New(src)
+			if g%2 == 0 {
+				out, steps := reducer.Trace(pn, nil)
+				assert.Equal(t, expected, out.String())
+				assert.NotEmpty(t, steps)
+				return
+			}
+			assert.Equal(t, expected, reducer.Reduce(pn).(*PN).String())
+		}(g)
+	}
+	wg.Wait()
+}
@@ -3,6 +3,9 @@ package tree
 import (
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/lexeme"
+	"path"
+	"sort"
+	"strings"
 )
 
 // Reduction is a function that reduces a node.
@@ -11,6 +14,13 @@ type Reduction func(node *PN)
 // Reducer is used to reduce a ParseTree to something more useful, generally
 // clearing away symbols that are now represeneted by the tree structure. It
 // implements parlex.Reducer.
+//
+// Keys are kinds or patterns like "Expr*", the key "*" is a default for every
+// kind without a key of its own. See Reduction for how a key is chosen. A key
+// with a nil Reduction keeps a kind from being reduced by a pattern.
+//
+// A Reducer is safe to use from many goroutines at once as long as no
+// goroutine adds to it and each reduces its own tree.
 type Reducer map[string]Reduction
 
 // Add a reduction.
@@ -20,10 +30,64 @@ func (r Reducer) Add(symbol string, reduction Reduction) {
 
 // Can returns true if a reducer has a rule for the given node
 func (r Reducer) Can(node parlex.ParseNode) bool {
-	_, has := r[node.Kind().String()]
+	_, has := r.key(node.Kind().String(), r.patterns())
 	return has
 }
 
+// Reduction returns the reduction for a kind. A key that is the kind is used
+// first, otherwise a key can be a pattern using the syntax of path.Match, such
+// as "Expr*". If more than one pattern matches, the longest is used, so the key
+// "*" is a default that is only used when nothing else matches.
+func (r Reducer) Reduction(kind string) Reduction {
+	k, _ := r.key(kind, r.patterns())
+	return r[k]
+}
+
+// Keys returns the kinds and patterns the Reducer has reductions for, in
+// order. It allows parlex.Check to find keys that are not in the grammar.
+func (r Reducer) Keys() []string {
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// key finds the key used for a kind.
+func (r Reducer) key(kind string, patterns []string) (string, bool) {
+	if _, ok := r[kind]; ok {
+		return kind, true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, kind); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// patterns returns the keys that are patterns, longest first.
+func (r Reducer) patterns() []string {
+	var ps []string
+	for k := range r {
+		if isPattern(k) {
+			ps = append(ps, k)
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if len(ps[i]) != len(ps[j]) {
+			return len(ps[i]) > len(ps[j])
+		}
+		return ps[i] < ps[j]
+	})
+	return ps
+}
+
+func isPattern(key string) bool {
+	return strings.ContainsAny(key, `*?[\`)
+}
+
 // Merge takes two Reducers and returns a single Reducer that is the merged
 // result. If a Kind is present in both r1 and r2, the merged will behave as
 // though running r1 then r2 on the node.
@@ -45,11 +109,11 @@ func Merge(r1, r2 Reducer) Reducer {
 }
 
 // Reduce performs a reduction on the tree. It makes a copy during the process
-// and the result comes back as parlex.ParseNode. For this reason, you don't
-// want to traverse up the tree during a reduction. Instead, use Reduce to
-// traverse the tree once, then handle upward traversals in a second path or
-// with a stack. Though often it can be avoided by adding the reduction logic
-// further up the tree.
+// and the result comes back as parlex.ParseNode. The tree is reduced from the
+// leaves up, so during a reduction the node's children and their parent
+// pointers are in place, but the node's own parent is not set yet. Once a
+// reduction is done, the parent of each child is set to the node. Use Ordered
+// to run reductions before the children are reduced.
 func (r Reducer) Reduce(node parlex.ParseNode) parlex.ParseNode {
 	if node == nil {
 		// RawReduce returning nil is not the same thing
@@ -58,27 +122,168 @@ func (r Reducer) Reduce(node parlex.ParseNode) parlex.ParseNode {
 	return r.RawReduce(node)
 }
 
-// RawReduce performs a reduction on the tree. It makes a copy during the
-// process and the result comes back as the concrete type *PN. For this reason,
-// you don't want to traverse up the tree during a reduction. Instead, use
-// Reduce to traverse the tree once, then handle upward traversals in a second
-// path or with a stack. Though often it can be avoided by adding the reduction
-// logic further up the tree.
+// RawReduce performs a reduction on the tree the same way as Reduce, but the
+// result comes back as the concrete type *PN.
 func (r Reducer) RawReduce(node parlex.ParseNode) *PN {
+	return r.op(nil).reduce(node)
+}
+
+// ReduceSource performs a reduction the same as Reduce, but the nodes have the
+// source they were parsed from while they are reduced, so a reduction can use
+// PN.Src and PN.SrcPos. It fulfills parlex.SourceReducer.
+func (r Reducer) ReduceSource(node parlex.ParseNode, src string) parlex.ParseNode {
+	if node == nil {
+		return nil
+	}
+	op := r.op(nil)
+	op.src = NewSource(src)
+	return op.reduce(node)
+}
+
+// reduceOp holds the state of one reduction. The patterns are found once
+// instead of for every node, t is nil if the reduction is not traced and src
+// is nil if the source is not known.
+type reduceOp struct {
+	Reducer
+	patterns []string
+	t        *tracer
+	src      *Source
+}
+
+func (r Reducer) op(t *tracer) *reduceOp {
+	return &reduceOp{
+		Reducer:  r,
+		patterns: r.patterns(),
+		t:        t,
+	}
+}
+
+func (op *reduceOp) reduction(kind string) Reduction {
+	k, _ := op.key(kind, op.patterns)
+	return op.Reducer[k]
+}
+
+func (op *reduceOp) reduce(node parlex.ParseNode) *PN {
 	if node == nil {
 		return nil
 	}
 	cp := &PN{
 		Lexeme: lexeme.Copy(node),
 		C:      make([]*PN, node.Children()),
+		S:      SpanOf(node),
+		src:    op.src,
+		prod:   parlex.ProductionOf(node) + 1,
 	}
 	for i := range cp.C {
-		cp.C[i] = r.RawReduce(node.Child(i))
+		cp.C[i] = op.reduce(node.Child(i))
+	}
+	cp.adopt()
+
+	if reduction := op.reduction(cp.Kind().String()); reduction != nil {
+		op.apply(cp, reduction)
 	}
 
-	if reduction := r[cp.Kind().String()]; reduction != nil {
-		reduction(cp)
+	return cp
+}
+
+// apply runs a reduction on a node, tracing it if the reduction is traced, and
+// sets the parent of the children after.
+func (op *reduceOp) apply(node *PN, reduction Reduction) {
+	if op.t == nil {
+		reduction(node)
+	} else {
+		op.t.run(node, reduction)
 	}
+	node.adopt()
+}
 
+// ReduceShared performs a reduction like Reduce, but the tree is copied on
+// write; a subtree that no reduction changed is shared with the original tree
+// instead of being copied. The original tree is not modified, so it can be kept
+// to compare against the reduced tree.
+//
+// Before a reduction runs, the node, its children and its grandchildren are
+// copied so the node reductions in this package can be used. A custom
+// Reduction must not modify nodes further down or change a lexeme in place;
+// lexemes are shared too. Nodes that are shared keep
+// their parent pointers into the original tree; use Clone on the result if
+// parent pointers are needed everywhere. If node is not a *PN, the whole tree
+// is copied the same as Reduce.
+func (r Reducer) ReduceShared(node parlex.ParseNode) parlex.ParseNode {
+	pn, ok := node.(*PN)
+	if !ok {
+		return r.Reduce(node)
+	}
+	if pn == nil {
+		return nil
+	}
+	return r.op(nil).shared(pn)
+}
+
+func (op *reduceOp) shared(node *PN) *PN {
+	var cs []*PN
+	// fresh marks the children that are new nodes, not shared with the
+	// original tree
+	var fresh []bool
+	for i, c := range node.C {
+		if rc := op.shared(c); rc != c {
+			if cs == nil {
+				cs = append([]*PN(nil), node.C...)
+				fresh = make([]bool, len(cs))
+			}
+			cs[i], fresh[i] = rc, true
+		}
+	}
+	reduction := op.reduction(node.Kind().String())
+	if reduction == nil && cs == nil {
+		return node
+	}
+	if cs == nil {
+		cs = append([]*PN(nil), node.C...)
+		fresh = make([]bool, len(cs))
+	}
+	cp := &PN{
+		Lexeme: node.Lexeme,
+		C:      cs,
+		S:      node.S,
+		prod:   node.prod,
+	}
+	if reduction == nil {
+		for i, c := range cp.C {
+			if fresh[i] {
+				c.P = cp
+			}
+		}
+		return cp
+	}
+	// the children of a fresh child may still be shared, so every child is
+	// copied along with its children
+	for i, c := range cp.C {
+		cp.C[i] = c.copyTwo()
+	}
+	cp.adopt()
+	reduction(cp)
+	cp.adopt()
+	return cp
+}
+
+// copyTwo copies the node and its children so they can be changed without
+// changing the original.
+func (p *PN) copyTwo() *PN {
+	cp := &PN{
+		Lexeme: p.Lexeme,
+		C:      make([]*PN, len(p.C)),
+		S:      p.S,
+		prod:   p.prod,
+	}
+	for i, c := range p.C {
+		cp.C[i] = &PN{
+			Lexeme: c.Lexeme,
+			C:      append([]*PN(nil), c.C...),
+			S:      c.S,
+			P:      cp,
+			prod:   c.prod,
+		}
+	}
 	return cp
 }
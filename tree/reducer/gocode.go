@@ -0,0 +1,108 @@
+package reducer
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex/tree"
+	"strconv"
+	"strings"
+)
+
+// GoCode parses a reducer definition the same as Parse and returns it as Go
+// code for a tree.Reducer literal that does the same reductions. It is used by
+// cmd/parlexc to compile a reducer into a program, so the definition does not
+// need to be parsed when the program runs.
+func GoCode(str string) (string, error) {
+	if _, err := eval(str); err != nil {
+		return "", err
+	}
+	root, err := runner.Run(str)
+	if err != nil {
+		return "", runErr(err, str)
+	}
+
+	// like Parse, a later rule for the same kind replaces an earlier one
+	var kinds []string
+	code := make(map[string]string)
+	for _, n := range root.(*tree.PN).C {
+		if n.Kind().String() != "Rule" {
+			continue
+		}
+		if _, ok := code[n.Value()]; !ok {
+			kinds = append(kinds, n.Value())
+		}
+		code[n.Value()] = goReduction(n.C...)
+	}
+
+	var buf strings.Builder
+	buf.WriteString("tree.Reducer{\n")
+	for _, k := range kinds {
+		fmt.Fprintf(&buf, "%q: %s,\n", k, code[k])
+	}
+	buf.WriteString("}")
+	return buf.String(), nil
+}
+
+// goReduction mirrors evalReduction. The definition has already been checked
+// by eval.
+func goReduction(ns ...*tree.PN) string {
+	var buf strings.Builder
+	for _, n := range ns {
+		kind := n.Kind().String()
+		switch kind {
+		case "Nil":
+		case "PromoteSingleChild", "PromoteGrandChildren", "Flatten":
+			fmt.Fprintf(&buf, ".%s()", kind)
+		case "RemoveChildren":
+			fmt.Fprintf(&buf, ".RemoveChildren(%s)", goNums(n.C[0].C))
+		case "RemoveAll":
+			fmt.Fprintf(&buf, ".RemoveAll(%s)", goStrings(n.C[0].C))
+		case "Rename", "MergeAdjacent":
+			fmt.Fprintf(&buf, ".%s(%s)", kind, goStrings(n.C[0].C[:1]))
+		case "PromoteChild", "PromoteChildrenOf", "PromoteChildValue", "RemoveChild", "ReplaceWithChild", "Lift":
+			fmt.Fprintf(&buf, ".%s(%s)", kind, goNums(n.C[0].C[:1]))
+		case "If":
+			fmt.Fprintf(&buf, ".If(%s, %s, %s)", goCondition(n.C[0]), goReduction(n.C[1].C...), goReduction(n.C[2].C...))
+		}
+	}
+	if buf.Len() == 0 {
+		return "nil"
+	}
+	return "tree.Reduction(nil)" + buf.String()
+}
+
+// goCondition mirrors evalConditional.
+func goCondition(n *tree.PN) string {
+	kind := n.Kind().String()
+	switch kind {
+	case "ChildIs", "ValueIs":
+		return fmt.Sprintf("tree.%s(%s, %s)", kind, goNums(n.C[:1]), goStrings(n.C[1:2]))
+	case "ChildCount":
+		return fmt.Sprintf("tree.ChildCount(%s)", goNums(n.C[:1]))
+	case "KindIs":
+		return fmt.Sprintf("tree.KindIs(%s)", goStrings(n.C[:1]))
+	}
+	strs := make([]string, len(n.C))
+	for i, c := range n.C {
+		strs[i] = goCondition(c)
+	}
+	return fmt.Sprintf("tree.%s(%s)", kind, strings.Join(strs, ", "))
+}
+
+// goNums writes the numbers as Go ints, so an argument like 08 is not read as
+// octal.
+func goNums(ns []*tree.PN) string {
+	strs := make([]string, len(ns))
+	for i, n := range ns {
+		v, _ := evalNum(n)
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func goStrings(ns []*tree.PN) string {
+	strs := make([]string, len(ns))
+	for i, n := range ns {
+		strs[i] = strconv.Quote(evalString(n))
+	}
+	return strings.Join(strs, ", ")
+}
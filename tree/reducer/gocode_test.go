@@ -0,0 +1,40 @@
+package reducer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go/parser"
+	"testing"
+)
+
+func TestGoCode(t *testing.T) {
+	code, err := GoCode(`
+E*      RemoveAll("op", "\"").PromoteSingleChild
+Object  RemoveChildren(0, -1)
+KeyVal  PromoteChildValue(0).RemoveChild(08)
+Skip    Nil
+Op      If(
+          And(ChildCount(3), Not(ValueIs(1, "-"))),
+          PromoteChildValue(1),
+          If(Or(KindIs("Neg"), ChildIs(0, "minus")), Nil, Lift(0))
+        )
+Object  Rename("x").Flatten
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected := `tree.Reducer{
+"E*": tree.Reduction(nil).RemoveAll("op", "\"").PromoteSingleChild(),
+"Object": tree.Reduction(nil).Rename("x").Flatten(),
+"KeyVal": tree.Reduction(nil).PromoteChildValue(0).RemoveChild(8),
+"Skip": nil,
+"Op": tree.Reduction(nil).If(tree.And(tree.ChildCount(3), tree.Not(tree.ValueIs(1, "-"))), tree.Reduction(nil).PromoteChildValue(1), tree.Reduction(nil).If(tree.Or(tree.KindIs("Neg"), tree.ChildIs(0, "minus")), nil, tree.Reduction(nil).Lift(0))),
+}`
+	assert.Equal(t, expected, code)
+	_, err = parser.ParseExpr(code)
+	assert.NoError(t, err)
+
+	_, err = GoCode("Foo RemoveChild(")
+	assert.Error(t, err)
+	_, ok := err.(*Error)
+	assert.True(t, ok)
+}
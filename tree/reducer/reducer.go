@@ -1,17 +1,27 @@
 package reducer
 
 import (
-	"strconv"
+	"errors"
+	"fmt"
 	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/grammar/regexgram"
 	"github.com/adamcolton/parlex/lexer/simplelexer"
 	"github.com/adamcolton/parlex/parser/packrat"
 	"github.com/adamcolton/parlex/tree"
+	"io/ioutil"
+	"strconv"
+	"strings"
 )
 
 const lexerRules = `
   If
   ChildIs
+  ValueIs
+  ChildCount
+  KindIs
+  And
+  Or
+  Not
   PromoteChild
   PromoteChildrenOf
   PromoteChildValue
@@ -21,9 +31,13 @@ const lexerRules = `
   RemoveChildren
   PromoteSingleChild
   ReplaceWithChild
+  Flatten
+  Rename
+  MergeAdjacent
+  Lift
   Nil
   number  /-?\d*\.?\d+/
-  rule    /(\w+)/
+  rule    /([\w*?]+)/
   string  /\"([^\"\\]|(\\.))*\"/
   lp      /\(/
   rp      /\)/
@@ -44,12 +58,27 @@ const grammarRules = `
                -> PromoteChildValue OneNumArg
                -> RemoveChild OneNumArg
                -> ReplaceWithChild OneNumArg
+               -> PromoteChild OneNumArg
+               -> PromoteChildrenOf OneNumArg
+               -> PromoteGrandChildren
+               -> RemoveAll StrArg
+               -> Flatten
+               -> Rename OneStrArg
+               -> MergeAdjacent OneStrArg
+               -> Lift OneNumArg
                -> Nil
                -> If lp Condition comma Chain comma Chain rp
   VarNumArg    -> lp (number comma)* number rp
   OneNumArg    -> lp number rp
-  Condition    -> ChildIsBlock
-  ChildIsBlock -> ChildIs lp number comma string rp
+  StrArg       -> lp (string comma)* string rp
+  OneStrArg    -> lp string rp
+  Condition    -> ChildIs lp number comma string rp
+               -> ValueIs lp number comma string rp
+               -> ChildCount lp number rp
+               -> KindIs lp string rp
+               -> And lp (Condition comma)* Condition rp
+               -> Or lp (Condition comma)* Condition rp
+               -> Not lp Condition rp
 `
 
 var grmr, grmrRdcr = regexgram.Must(grammarRules)
@@ -60,83 +89,298 @@ var rdcr = tree.Merge(grmrRdcr, tree.Reducer{
 	"Reduction":    tree.RemoveAll("comma","lp","rp").PromoteChild(0),
 	"VarNumArg":    tree.RemoveChildren(0, -1).RemoveAll("comma"),
 	"OneNumArg":    tree.RemoveChildren(0, -1),
-	"ChildIsBlock": tree.RemoveAll("comma","lp","rp").PromoteChild(0),
+	"StrArg":       tree.RemoveChildren(0, -1).RemoveAll("comma"),
+	"OneStrArg":    tree.RemoveChildren(0, -1),
+	"Condition":    tree.RemoveAll("comma", "lp", "rp").PromoteChild(0),
 })
 
 var runner = parlex.New(lxr, prsr, rdcr)
 
+// Parse a string into a tree.Reducer. Each rule is the kind of node followed by
+// a chain of reductions. The kind can be a pattern using * and ?, see
+// tree.Reducer. If the string cannot be parsed or uses an argument that the
+// reduction does not support, the error is an *Error with the position.
 func Parse(str string) (tree.Reducer, error) {
-	root, err := runner.Run(str)
+	return parse(str, "")
+}
+
+// ParseFile reads a file and parses it into a tree.Reducer the same as Parse.
+// An *Error includes the name of the file.
+func ParseFile(name string) (tree.Reducer, error) {
+	b, err := ioutil.ReadFile(name)
 	if err != nil {
 		return nil, err
 	}
+	return parse(string(b), name)
+}
+
+func parse(str, file string) (tree.Reducer, error) {
+	rdcr, err := eval(str)
+	if e, ok := err.(*Error); ok {
+		e.File = file
+	}
+	return rdcr, err
+}
+
+func eval(str string) (tree.Reducer, error) {
+	root, err := runner.Run(str)
+	if err != nil {
+		return nil, runErr(err, str)
+	}
 	rdcr := make(tree.Reducer)
-	for _,n := range root.(*tree.PN).C {
+	for _, n := range root.(*tree.PN).C {
 		if n.Kind().String() == "Rule" {
-			k,v := evalRule(n)
-			rdcr[k]=v
+			r, err := evalReduction(n.C...)
+			if err != nil {
+				return nil, err
+			}
+			rdcr[n.Value()] = r
 		}
 	}
 	return rdcr, nil
 }
 
+// Must calls Parse and panics if there is an error.
 func Must(str string) tree.Reducer {
 	rt, err := Parse(str)
-	if err!=nil{
+	if err != nil {
 		panic(err)
-	} 
+	}
 	return rt
 }
 
-func evalRule(n *tree.PN)(string, tree.Reduction){
-	return n.Value(), evalReduction(n.C...)
+// Error is an error in a reducer definition. Line and Col are where the
+// problem is in the definition, starting at 1. File is the name of the file
+// the definition was read from, if it was read by ParseFile. Err is the error
+// that caused it, such as a *parlex.ParseError, and may be nil.
+type Error struct {
+	File      string
+	Line, Col int
+	Msg       string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	pos := fmt.Sprintf("%d:%d", e.Line, e.Col)
+	if e.File != "" {
+		pos = e.File + ":" + pos
+	}
+	return e.Msg + " at " + pos
+}
+
+// Unwrap returns the error that caused the Error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func errAt(lx parlex.Lexeme, format string, args ...interface{}) *Error {
+	l, c := lx.Pos()
+	return &Error{
+		Line: l,
+		Col:  c,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// runErr gives the position of an error from lexing or parsing the definition.
+// If the parse reached the end, the position is the end of str.
+func runErr(err error, str string) error {
+	var le parlex.LexError
+	if errors.As(err, &le) {
+		e := errAt(le, "Bad Reducer: unexpected %q", le.Value())
+		e.Err = err
+		return e
+	}
+	var pe *parlex.ParseError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	expected := "end of input"
+	if len(pe.Expected) > 0 {
+		strs := make([]string, len(pe.Expected))
+		for i, sym := range pe.Expected {
+			strs[i] = sym.String()
+		}
+		expected = strings.Join(strs, ", ")
+	}
+	if pe.Lexeme != nil {
+		e := errAt(pe.Lexeme, "Bad Reducer: found %s, expected %s", parlex.LexemeString(pe.Lexeme), expected)
+		e.Err = err
+		return e
+	}
+	line := strings.Count(str, "\n") + 1
+	return &Error{
+		Line: line,
+		Col:  len(str) - strings.LastIndexByte(str, '\n'),
+		Msg:  "Bad Reducer: found end of input, expected " + expected,
+		Err:  err,
+	}
+}
+
+func unsupported(n *tree.PN) error {
+	return errAt(n, "Unsupported Construct: %s", n.Kind().String())
 }
 
-func evalReduction(ns ...*tree.PN) tree.Reduction{
+func evalReduction(ns ...*tree.PN) (tree.Reduction, error) {
 	var r tree.Reduction
 	for _, n := range ns {
-			switch n.Kind().String(){
-			case "PromoteSingleChild":
-				r = r.PromoteSingleChild()
-			case "RemoveChildren":
-				r = r.RemoveChildren(evalVarNumArgs(n.C[0])...)
-			case "PromoteChildValue":
-				r = r.PromoteChildValue(evalOneNumArg(n.C[0]))
-			case "RemoveChild":
-				r = r.RemoveChild(evalOneNumArg(n.C[0]))
-			case "ReplaceWithChild":
-				r = r.ReplaceWithChild(evalOneNumArg(n.C[0]))
-			case "If":
-				c := evalConditional(n.C[0])
-				t := evalReduction(n.C[1].C...)
-				e := evalReduction(n.C[2].C...)
-				r = r.If(c,t,e)
+		switch n.Kind().String() {
+		case "PromoteSingleChild":
+			r = r.PromoteSingleChild()
+		case "PromoteGrandChildren":
+			r = r.PromoteGrandChildren()
+		case "Flatten":
+			r = r.Flatten()
+		case "Nil":
+		case "RemoveChildren":
+			args, err := evalVarNumArgs(n.C[0])
+			if err != nil {
+				return nil, err
 			}
+			r = r.RemoveChildren(args...)
+		case "RemoveAll":
+			r = r.RemoveAll(evalStrArgs(n.C[0])...)
+		case "Rename", "MergeAdjacent":
+			if len(n.C[0].C) < 1 {
+				return nil, unsupported(n)
+			}
+			if arg := evalString(n.C[0].C[0]); n.Kind().String() == "Rename" {
+				r = r.Rename(arg)
+			} else {
+				r = r.MergeAdjacent(arg)
+			}
+		case "PromoteChild", "PromoteChildrenOf", "PromoteChildValue", "RemoveChild", "ReplaceWithChild", "Lift":
+			i, err := evalOneNumArg(n.C[0])
+			if err != nil {
+				return nil, err
+			}
+			r = chainIdx(r, n.Kind().String(), i)
+		case "If":
+			c, err := evalConditional(n.C[0])
+			if err != nil {
+				return nil, err
+			}
+			t, err := evalReduction(n.C[1].C...)
+			if err != nil {
+				return nil, err
+			}
+			e, err := evalReduction(n.C[2].C...)
+			if err != nil {
+				return nil, err
+			}
+			r = r.If(c, t, e)
+		default:
+			return nil, unsupported(n)
+		}
 	}
-	return r
+	return r, nil
 }
 
-func evalVarNumArgs(n *tree.PN) []int {
-	args := make( []int, len(n.C) )
-	for i, n := range n.C{
-		args[i], _ = strconv.Atoi(n.Value())
+// chainIdx adds a reduction that takes a single child index to the chain.
+func chainIdx(r tree.Reduction, kind string, i int) tree.Reduction {
+	switch kind {
+	case "PromoteChild":
+		return r.PromoteChild(i)
+	case "PromoteChildrenOf":
+		return r.PromoteChildrenOf(i)
+	case "PromoteChildValue":
+		return r.PromoteChildValue(i)
+	case "RemoveChild":
+		return r.RemoveChild(i)
+	case "Lift":
+		return r.Lift(i)
+	}
+	return r.ReplaceWithChild(i)
+}
+
+func evalNum(n *tree.PN) (int, error) {
+	i, err := strconv.Atoi(n.Value())
+	if err != nil {
+		return 0, errAt(n, "Bad Index: %s", n.Value())
+	}
+	return i, nil
+}
+
+func evalVarNumArgs(n *tree.PN) ([]int, error) {
+	args := make([]int, len(n.C))
+	for i, n := range n.C {
+		var err error
+		if args[i], err = evalNum(n); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+func evalOneNumArg(n *tree.PN) (int, error) {
+	if len(n.C) < 1 {
+		return 0, unsupported(n)
+	}
+	return evalNum(n.C[0])
+}
+
+func evalStrArgs(n *tree.PN) []string {
+	args := make([]string, len(n.C))
+	for i, n := range n.C {
+		args[i] = evalString(n)
 	}
 	return args
 }
 
-func evalOneNumArg(n *tree.PN) int {
-	if len(n.C)<1{
-		return 0
+func evalConditional(n *tree.PN) (tree.Condition, error) {
+	switch n.Kind().String() {
+	case "ChildIs", "ValueIs":
+		i, err := evalNum(n.C[0])
+		if err != nil {
+			return nil, err
+		}
+		if n.Kind().String() == "ChildIs" {
+			return tree.ChildIs(i, evalString(n.C[1])), nil
+		}
+		return tree.ValueIs(i, evalString(n.C[1])), nil
+	case "ChildCount":
+		i, err := evalNum(n.C[0])
+		if err != nil {
+			return nil, err
+		}
+		return tree.ChildCount(i), nil
+	case "KindIs":
+		return tree.KindIs(evalString(n.C[0])), nil
+	case "And", "Or":
+		cs, err := evalConditionals(n.C)
+		if err != nil {
+			return nil, err
+		}
+		if n.Kind().String() == "And" {
+			return tree.And(cs...), nil
+		}
+		return tree.Or(cs...), nil
+	case "Not":
+		c, err := evalConditional(n.C[0])
+		if err != nil {
+			return nil, err
+		}
+		return tree.Not(c), nil
+	}
+	return nil, unsupported(n)
+}
+
+func evalConditionals(ns []*tree.PN) ([]tree.Condition, error) {
+	cs := make([]tree.Condition, len(ns))
+	for i, n := range ns {
+		var err error
+		if cs[i], err = evalConditional(n); err != nil {
+			return nil, err
+		}
 	}
-	i, _ :=strconv.Atoi(n.C[0].Value())
-	return i
+	return cs, nil
 }
 
-func evalConditional(n *tree.PN) tree.Condition{
-	switch n.Kind().String(){
-	case "ChildIs":
-		i,_:=strconv.Atoi(n.C[0].Value())
-		return tree.ChildIs(i, n.C[1].Value())
+// evalString removes the quotes from a string
+func evalString(n *tree.PN) string {
+	s, err := strconv.Unquote(n.Value())
+	if err != nil {
+		return n.Value()[1 : len(n.Value())-1]
 	}
-	return nil
-}
\ No newline at end of file
+	return s
+}
@@ -1,9 +1,12 @@
 package reducer
 
 import (
-	//"github.com/adamcolton/parlex"
+	"errors"
+	"github.com/adamcolton/parlex"
 	"github.com/adamcolton/parlex/tree"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
@@ -45,3 +48,246 @@ Fooo        If(
 		assert.Equal(t, "Bar", pn1.C[0].Value())
 	}
 }
+
+func TestConditions(t *testing.T) {
+	rdcr, err := Parse(`
+Op   If(
+       And(ChildCount(3), Not(ValueIs(1, "-"))),
+       PromoteChildValue(1),
+       If(Or(KindIs("Neg"), ChildIs(0, "minus")), RemoveChild(0), PromoteSingleChild)
+     )
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := map[string]struct {
+		node, expected string
+	}{
+		"and": {
+			node: `
+			Op {
+				num: "1"
+				op: "+"
+				num: "2"
+			}
+			`,
+			expected: `
+			Op: "+" {
+				num: "1"
+				num: "2"
+			}
+			`,
+		},
+		"not": {
+			node: `
+			Op {
+				num: "1"
+				op: "-"
+				num: "2"
+			}
+			`,
+			expected: `
+			Op {
+				num: "1"
+				op: "-"
+				num: "2"
+			}
+			`,
+		},
+		"or": {
+			node: `
+			Op {
+				minus: "-"
+				num: "2"
+			}
+			`,
+			expected: `
+			Op {
+				num: "2"
+			}
+			`,
+		},
+		"else": {
+			node: `
+			Op {
+				P {
+					num: "2"
+				}
+			}
+			`,
+			expected: `
+			P {
+				num: "2"
+			}
+			`,
+		},
+	}
+
+	for n, tc := range tests {
+		t.Run(n, func(t *testing.T) {
+			pn, err := tree.New(tc.node)
+			assert.NoError(t, err)
+			expected, err := tree.New(tc.expected)
+			assert.NoError(t, err)
+			assert.Equal(t, expected.String(), rdcr.Reduce(pn).(*tree.PN).String())
+		})
+	}
+}
+
+func TestReductions(t *testing.T) {
+	rdcr, err := Parse(`
+List   RemoveAll("comma", "lp", "rp").PromoteChildrenOf(0)
+Group  PromoteGrandChildren
+Wrap   PromoteChild(-1)
+Empty  Nil
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Nil(t, rdcr["Empty"])
+
+	tests := map[string]struct {
+		node, expected string
+	}{
+		"RemoveAll": {
+			node: `
+			List {
+				lp: "("
+				Items {
+					num: "1"
+					num: "2"
+				}
+				comma: ","
+				num: "3"
+				rp: ")"
+			}
+			`,
+			expected: `
+			List {
+				num: "1"
+				num: "2"
+				num: "3"
+			}
+			`,
+		},
+		"PromoteGrandChildren": {
+			node: `
+			Group {
+				A {
+					num: "1"
+				}
+				B {
+					num: "2"
+				}
+			}
+			`,
+			expected: `
+			Group {
+				num: "1"
+				num: "2"
+			}
+			`,
+		},
+		"PromoteChild": {
+			node: `
+			Wrap {
+				lp: "("
+				op: "+" {
+					num: "1"
+				}
+			}
+			`,
+			expected: `
+			op: "+" {
+				lp: "("
+				num: "1"
+			}
+			`,
+		},
+	}
+
+	for n, tc := range tests {
+		t.Run(n, func(t *testing.T) {
+			pn, err := tree.New(tc.node)
+			assert.NoError(t, err)
+			expected, err := tree.New(tc.expected)
+			assert.NoError(t, err)
+			assert.Equal(t, expected.String(), rdcr.Reduce(pn).(*tree.PN).String())
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(`A RemoveChild(1.5)`)
+	assert.EqualError(t, err, "Bad Index: 1.5 at 1:15")
+
+	_, err = Parse(`A If(ChildCount(-0.5), Nil, Nil)`)
+	assert.EqualError(t, err, "Bad Index: -0.5 at 1:17")
+
+	_, err = Parse(`A RemoveAll(1)`)
+	assert.EqualError(t, err, "Bad Reducer: found number: 1, expected string at 1:13")
+	var pe *parlex.ParseError
+	assert.True(t, errors.As(err, &pe))
+
+	_, err = Parse("A PromoteSingleChild\nB RemoveChild(0) %")
+	assert.EqualError(t, err, `Bad Reducer: unexpected "%" at 2:18`)
+
+	_, err = Parse("A PromoteSingleChild\nB RemoveChild(")
+	assert.EqualError(t, err, "Bad Reducer: found end of input, expected number at 2:15")
+	assert.True(t, parlex.IsIncomplete(err))
+}
+
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "rules.txt")
+	assert.NoError(t, ioutil.WriteFile(name, []byte("A RemoveChild(0)\nB RemoveChild(x)\n"), 0644))
+	_, err := ParseFile(name)
+	if assert.Error(t, err) {
+		e, ok := err.(*Error)
+		if assert.True(t, ok) {
+			assert.Equal(t, name, e.File)
+			assert.Equal(t, 2, e.Line)
+			assert.Equal(t, 15, e.Col)
+		}
+	}
+
+	assert.NoError(t, ioutil.WriteFile(name, []byte("A RemoveChild(0)\n"), 0644))
+	rdcr, err := ParseFile(name)
+	assert.NoError(t, err)
+	assert.NotNil(t, rdcr["A"])
+
+	_, err = ParseFile(filepath.Join(dir, "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestCombinators(t *testing.T) {
+	rdcr, err := Parse(`
+L    Flatten
+S    MergeAdjacent("chr").Rename("Str")
+E    Lift(1)
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pn, err := tree.FromSExpr(`(E (L (a "1") (L (a "2"))) (op "+") (S (chr "a") (chr "b")))`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `(op "+" (L (a "1") (a "2")) (Str (chr "ab")))`, tree.ToSExpr(rdcr.Reduce(pn)))
+}
+
+func TestPatterns(t *testing.T) {
+	rdcr, err := Parse(`
+*      PromoteSingleChild
+Expr*  RemoveAll("comma")
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotNil(t, rdcr["*"])
+	assert.NotNil(t, rdcr["Expr*"])
+
+	pn, err := tree.FromSExpr(`(ExprList (T (int "1")) (comma ",") (T (int "2")))`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `(ExprList (int "1") (int "2"))`, tree.ToSExpr(rdcr.Reduce(pn)))
+}
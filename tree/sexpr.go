@@ -0,0 +1,198 @@
+package tree
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"strconv"
+	"strings"
+)
+
+// ToSExpr returns a tree in a compact s-expression notation on one line. A node
+// with children is written in parentheses as its kind, an optional quoted value
+// and its children. A leaf without a value is written as just its kind.
+//   (E (int "1") + (T (int "2") * (int "3")))
+// Values use Go string quoting. In a kind, the characters ( ) " \ and white
+// space are escaped with a backslash, so a leaf of kind ( is \(. A nil tree is
+// NIL.
+func ToSExpr(node parlex.ParseNode) string {
+	var b strings.Builder
+	writeSExpr(&b, node)
+	return b.String()
+}
+
+func writeSExpr(b *strings.Builder, node parlex.ParseNode) {
+	if isNilNode(node) {
+		b.WriteString("NIL")
+		return
+	}
+	v := node.Value()
+	if node.Children() == 0 && v == "" {
+		b.WriteString(atom(node.Kind().String()))
+		return
+	}
+	b.WriteString("(")
+	b.WriteString(atom(node.Kind().String()))
+	if v != "" {
+		b.WriteString(" ")
+		b.WriteString(strconv.Quote(v))
+	}
+	for i := 0; i < node.Children(); i++ {
+		b.WriteString(" ")
+		writeSExpr(b, node.Child(i))
+	}
+	b.WriteString(")")
+}
+
+// atom escapes the characters in a kind that are part of the notation.
+func atom(kind string) string {
+	if !strings.ContainsAny(kind, "()\"\\ \t\n") {
+		return kind
+	}
+	var b strings.Builder
+	for _, r := range kind {
+		switch r {
+		case '(', ')', '"', '\\', ' ', '\t', '\n':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// FromSExpr creates a tree from the notation written by ToSExpr. The kinds are
+// taken from the set so they are the same symbols used by the lexer and grammar
+// that produced the tree. If set is nil, the kinds are stringsymbol.Symbol.
+func FromSExpr(str string, set *setsymbol.Set) (*PN, error) {
+	p := &sexprParser{
+		str:    str,
+		symbol: func(s string) parlex.Symbol { return stringsymbol.Symbol(s) },
+	}
+	if set != nil {
+		p.symbol = func(s string) parlex.Symbol { return set.Str(s) }
+	}
+	pn, err := p.node(nil)
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.pos < len(p.str) {
+		return nil, p.err("expected end")
+	}
+	return pn, nil
+}
+
+type sexprParser struct {
+	str    string
+	pos    int
+	symbol func(string) parlex.Symbol
+}
+
+func (p *sexprParser) err(msg string) error {
+	return fmt.Errorf("Bad S-Expression: %s at %d", msg, p.pos)
+}
+
+func (p *sexprParser) skip() {
+	for p.pos < len(p.str) && strings.IndexByte(" \t\r\n", p.str[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *sexprParser) node(parent *PN) (*PN, error) {
+	p.skip()
+	if p.pos == len(p.str) {
+		return nil, p.err("expected node")
+	}
+	if p.str[p.pos] != '(' {
+		kind, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		return p.newPN(parent, kind, ""), nil
+	}
+	p.pos++
+	kind, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	var val string
+	if p.pos < len(p.str) && p.str[p.pos] == '"' {
+		if val, err = p.quoted(); err != nil {
+			return nil, err
+		}
+	}
+	pn := p.newPN(parent, kind, val)
+	for {
+		p.skip()
+		if p.pos == len(p.str) {
+			return nil, p.err("expected )")
+		}
+		if p.str[p.pos] == ')' {
+			p.pos++
+			return pn, nil
+		}
+		ch, err := p.node(pn)
+		if err != nil {
+			return nil, err
+		}
+		pn.C = append(pn.C, ch)
+	}
+}
+
+func (p *sexprParser) atom() (string, error) {
+	var b strings.Builder
+	for p.pos < len(p.str) {
+		c := p.str[p.pos]
+		if strings.IndexByte("()\" \t\r\n", c) >= 0 {
+			break
+		}
+		if c == '\\' && p.pos+1 < len(p.str) {
+			p.pos++
+			c = p.str[p.pos]
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	if b.Len() == 0 {
+		return "", p.err("expected kind")
+	}
+	return b.String(), nil
+}
+
+func (p *sexprParser) quoted() (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.str) && p.str[p.pos] != '"'; p.pos++ {
+		if p.str[p.pos] == '\\' {
+			p.pos++
+		}
+	}
+	if p.pos >= len(p.str) {
+		return "", p.err("expected \"")
+	}
+	p.pos++
+	val, err := strconv.Unquote(p.str[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return "", p.err("bad string")
+	}
+	return val, nil
+}
+
+func (p *sexprParser) newPN(parent *PN, kind, val string) *PN {
+	return &PN{
+		Lexeme: lexeme.New(p.symbol(kind)).Set(val),
+		P:      parent,
+	}
+}
+
+// isNilNode is true for a nil node, including a nil *PN.
+func isNilNode(node parlex.ParseNode) bool {
+	if node == nil {
+		return true
+	}
+	pn, ok := node.(*PN)
+	return ok && pn == nil
+}
@@ -0,0 +1,53 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex/symbol/setsymbol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSExpr(t *testing.T) {
+	str := `(E "v" (int "1") \( (\  "a\"b") x)`
+	pn, err := FromSExpr(str, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "E", pn.Kind().String())
+		assert.Equal(t, "v", pn.Value())
+		assert.Equal(t, "(", pn.C[1].Kind().String())
+		assert.Equal(t, " ", pn.C[2].Kind().String())
+		assert.Equal(t, `a"b`, pn.C[2].Value())
+		assert.Equal(t, pn, pn.C[3].P)
+		assert.Equal(t, str, ToSExpr(pn))
+	}
+	assert.Equal(t, "NIL", ToSExpr(nil))
+	assert.Equal(t, "NIL", ToSExpr((*PN)(nil)))
+
+	for _, bad := range []string{"", "(E", "(E x) y", `(E "a`, "()", `(E "\q")`} {
+		_, err := FromSExpr(bad, nil)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestSExprSet(t *testing.T) {
+	set := setsymbol.New()
+	num := set.Str("Number")
+	str := `(Stack (Number (int "3") (dec ".5")) (Number (int "1")))`
+	pn, err := FromSExpr(str, set)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, num, pn.C[0].Kind())
+	assert.Equal(t, num, pn.C[1].Kind())
+	assert.Equal(t, str, ToSExpr(pn))
+
+	old, _ := New(`
+    Stack {
+      Number {
+        int: "3"
+        dec: ".5"
+      }
+      Number {
+        int: "1"
+      }
+    }`)
+	assert.Equal(t, old.String(), pn.String())
+}
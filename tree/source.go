@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Source is the text a tree was parsed from. During a reduction with
+// ReduceSource, every node can reach it with PN.Source, so a reduction can
+// read the text a node covers, for instance to unescape a string literal.
+type Source struct {
+	Text string
+	// offsets where each line starts, found on first use
+	lines []int
+}
+
+// NewSource returns the Source for a text.
+func NewSource(text string) *Source {
+	return &Source{Text: text}
+}
+
+// Pos returns the line and column of a byte offset. Both start at 1 and the
+// column counts runes, the same as the positions from simplelexer. A negative
+// offset returns 0, 0.
+func (s *Source) Pos(offset int) (line, col int) {
+	if offset < 0 {
+		return 0, 0
+	}
+	if s.lines == nil {
+		s.lines = []int{0}
+		for i := 0; i < len(s.Text); i++ {
+			if s.Text[i] == '\n' {
+				s.lines = append(s.lines, i+1)
+			}
+		}
+	}
+	line = sort.SearchInts(s.lines, offset+1)
+	start, end := s.lines[line-1], offset
+	if end > len(s.Text) {
+		end = len(s.Text)
+	}
+	return line, utf8.RuneCountInString(s.Text[start:end]) + offset - end + 1
+}
+
+// Src returns the text covered by a span.
+func (s *Source) Src(span Span) string {
+	return span.Src(s.Text)
+}
+
+// Source returns the source the tree was parsed from or nil if it is not
+// known.
+func (p *PN) Source() *Source {
+	return p.src
+}
+
+// SetSource sets the source of the node and all of its descendants.
+func (p *PN) SetSource(src *Source) {
+	p.src = src
+	for _, c := range p.C {
+		c.SetSource(src)
+	}
+}
+
+// Src returns the text covered by the node. If the source or the span of the
+// node are not known, it returns an empty string.
+func (p *PN) Src() string {
+	if p.src == nil {
+		return ""
+	}
+	return p.src.Src(p.S)
+}
+
+// SrcPos returns the line and column where the node starts in the source. If
+// the source or the span are not known, the position of the lexeme is
+// returned.
+func (p *PN) SrcPos() (line, col int) {
+	if p.src == nil || p.S.Empty() {
+		return p.Pos()
+	}
+	return p.src.Pos(p.S.Start)
+}
@@ -0,0 +1,85 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestSourcePos(t *testing.T) {
+	src := NewSource("ab\ncd\n\nef")
+	tt := []struct{ offset, line, col int }{
+		{0, 1, 1},
+		{2, 1, 3},
+		{3, 2, 1},
+		{7, 4, 1},
+		{9, 4, 3},
+		{-1, 0, 0},
+	}
+	for _, tc := range tt {
+		line, col := src.Pos(tc.offset)
+		assert.Equal(t, tc.line, line, tc.offset)
+		assert.Equal(t, tc.col, col, tc.offset)
+	}
+
+	line, col := NewSource("π = 3\nλ x").Pos(len("π = 3\nλ "))
+	assert.Equal(t, 2, line)
+	assert.Equal(t, 3, col)
+}
+
+func TestReduceSource(t *testing.T) {
+	src := "x = \"a\\tb\"\ny = 0x1F"
+	leaf := func(kind, val string, offset int) *PN {
+		pn := &PN{Lexeme: lexeme.String(kind).Set(val).AtOffset(offset)}
+		pn.UpdateSpan()
+		return pn
+	}
+	assign := func(cs ...*PN) *PN {
+		pn := &PN{Lexeme: lexeme.String("Assign"), C: cs}
+		pn.UpdateSpan()
+		return pn
+	}
+	pn := &PN{
+		Lexeme: lexeme.String("Prog"),
+		C: []*PN{
+			assign(leaf("id", "x", 0), leaf("eq", "=", 2), leaf("str", `"a\tb"`, 4)),
+			assign(leaf("id", "y", 11), leaf("eq", "=", 13), leaf("hex", "0x1F", 15)),
+		},
+	}
+	pn.UpdateSpan()
+
+	var positions [][2]int
+	rdcr := Reducer{
+		"str": func(node *PN) {
+			s, err := strconv.Unquote(node.Src())
+			assert.NoError(t, err)
+			node.Lexeme = lexeme.String("str").Set(s)
+		},
+		"hex": func(node *PN) {
+			i, err := strconv.ParseInt(node.Src()[2:], 16, 64)
+			assert.NoError(t, err)
+			node.Lexeme = lexeme.String("int").Set(strconv.Itoa(int(i)))
+		},
+		"Assign": func(node *PN) {
+			line, col := node.SrcPos()
+			positions = append(positions, [2]int{line, col})
+			node.RemoveChild(1)
+		},
+	}
+
+	out := rdcr.ReduceSource(pn, src).(*PN)
+	assert.Equal(t, `(Prog (Assign (id "x") (str "a\tb")) (Assign (id "y") (int "31")))`, ToSExpr(out))
+	assert.Equal(t, [][2]int{{1, 1}, {2, 1}}, positions)
+	assert.Equal(t, "y = 0x1F", out.C[1].Src())
+
+	out = Ordered{Reducer: rdcr}.ReduceSource(pn, src).(*PN)
+	assert.Equal(t, `(Prog (Assign (id "x") (str "a\tb")) (Assign (id "y") (int "31")))`, ToSExpr(out))
+
+	// without the source
+	out = rdcr.RawReduce(&PN{Lexeme: lexeme.String("id").Set("z").At(3, 4)})
+	assert.Equal(t, "", out.Src())
+	line, col := out.SrcPos()
+	assert.Equal(t, 3, line)
+	assert.Equal(t, 4, col)
+}
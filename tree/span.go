@@ -0,0 +1,106 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+)
+
+// Span is the section of the original input covered by a node. Start and End
+// are byte offsets and End is exclusive. A Span where End is not greater than
+// Start is empty; the zero value is used when the span is not known.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Empty returns true if the span does not cover any input.
+func (s Span) Empty() bool {
+	return s.End <= s.Start
+}
+
+// Len returns the number of bytes covered by the span.
+func (s Span) Len() int {
+	if s.Empty() {
+		return 0
+	}
+	return s.End - s.Start
+}
+
+// Merge returns the smallest span that covers both spans. Empty spans are
+// ignored.
+func (s Span) Merge(s2 Span) Span {
+	if s2.Empty() {
+		return s
+	}
+	if s.Empty() {
+		return s2
+	}
+	if s2.Start < s.Start {
+		s.Start = s2.Start
+	}
+	if s2.End > s.End {
+		s.End = s2.End
+	}
+	return s
+}
+
+// Src returns the segment of src covered by the span. If the span is empty or
+// does not fit in src, an empty string is returned.
+func (s Span) Src(src string) string {
+	if s.Empty() || s.Start < 0 || s.End > len(src) {
+		return ""
+	}
+	return src[s.Start:s.End]
+}
+
+// LexemeSpan returns the span of a Lexeme. The span ends after the lexeme
+// value. If the lexeme does not know it's offset, the zero value is returned.
+func LexemeSpan(lx parlex.Lexeme) Span {
+	o := parlex.Offset(lx)
+	if o < 0 {
+		return Span{}
+	}
+	return Span{o, o + len(lx.Value())}
+}
+
+// SpanOf returns the span of any parlex.ParseNode. If the node is a *PN, it's
+// span is returned. Otherwise the span is computed from the lexeme and the
+// children.
+func SpanOf(node parlex.ParseNode) Span {
+	if node == nil {
+		return Span{}
+	}
+	if pn, ok := node.(*PN); ok {
+		return pn.S
+	}
+	if node.Children() == 0 {
+		return LexemeSpan(node)
+	}
+	var s Span
+	for i := 0; i < node.Children(); i++ {
+		s = s.Merge(SpanOf(node.Child(i)))
+	}
+	return s
+}
+
+// UpdateSpan sets the span of the node. A node without children takes the span
+// of it's lexeme, otherwise the span of the node is the merged span of it's
+// children. Parsers call this as the tree is built.
+func (p *PN) UpdateSpan() {
+	if len(p.C) == 0 {
+		p.S = LexemeSpan(p.Lexeme)
+		return
+	}
+	p.S = Span{}
+	for _, c := range p.C {
+		p.S = p.S.Merge(c.S)
+	}
+}
+
+// Offset returns the byte offset of the start of the node. It fulfills
+// parlex.LexemePos.
+func (p *PN) Offset() int {
+	if !p.S.Empty() {
+		return p.S.Start
+	}
+	return parlex.Offset(p.Lexeme)
+}
@@ -0,0 +1,47 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSpanMerge(t *testing.T) {
+	assert.Equal(t, Span{2, 9}, Span{2, 5}.Merge(Span{7, 9}))
+	assert.Equal(t, Span{2, 9}, Span{7, 9}.Merge(Span{2, 5}))
+	assert.Equal(t, Span{2, 5}, Span{2, 5}.Merge(Span{}))
+	assert.Equal(t, Span{2, 5}, Span{}.Merge(Span{2, 5}))
+	assert.True(t, Span{3, 3}.Empty())
+	assert.Equal(t, "bc", Span{1, 3}.Src("abcd"))
+	assert.Equal(t, "", Span{1, 9}.Src("abcd"))
+}
+
+func TestUpdateSpan(t *testing.T) {
+	leaf := func(kind, val string, offset int) *PN {
+		pn := &PN{
+			Lexeme: lexeme.New(stringsymbol.Symbol(kind)).Set(val).AtOffset(offset),
+		}
+		pn.UpdateSpan()
+		return pn
+	}
+	pn := &PN{
+		Lexeme: lexeme.New(stringsymbol.Symbol("E")),
+		C: []*PN{
+			leaf("(", "(", 2),
+			leaf("int", "12", 3),
+			leaf(")", ")", 5),
+		},
+	}
+	pn.UpdateSpan()
+	assert.Equal(t, Span{3, 5}, pn.C[1].S)
+	assert.Equal(t, Span{2, 6}, pn.S)
+	assert.Equal(t, 2, pn.Offset())
+
+	pn.ReplaceWithChild(1)
+	assert.Equal(t, "12", pn.Value())
+	assert.Equal(t, Span{2, 6}, pn.S)
+
+	cp := Reducer{}.RawReduce(pn)
+	assert.Equal(t, Span{2, 6}, cp.S)
+}
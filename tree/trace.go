@@ -0,0 +1,147 @@
+package tree
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ReduceStep records one Reducer entry running on one node.
+type ReduceStep struct {
+	// Entry is the kind of the Reducer entry that ran
+	Entry string
+	// Ops are the operations of the entry that ran, in order. The Reductions
+	// in this package are named, a custom Reduction is only recorded if it is
+	// wrapped with Named.
+	Ops []string
+	// Before and After are the node in s-expression notation
+	Before, After string
+	// Node is the node after the reduction
+	Node *PN
+}
+
+// String describes the step on three lines:
+//   E: PromoteChildrenOf(-1), RemoveAll(op)
+//     before: (E (int "1") op (E (int "2")))
+//     after:  (E (int "1") (int "2"))
+func (s ReduceStep) String() string {
+	ops := "?"
+	if len(s.Ops) > 0 {
+		ops = strings.Join(s.Ops, ", ")
+	}
+	return s.Entry + ": " + ops + "\n  before: " + s.Before + "\n  after:  " + s.After
+}
+
+// Trace is the steps of a reduction in the order they ran, from the leaves up.
+type Trace []ReduceStep
+
+// String returns all the steps, one after another.
+func (t Trace) String() string {
+	var b strings.Builder
+	for _, s := range t {
+		b.WriteString(s.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Trace performs a reduction the same as RawReduce and records which entry and
+// which operations ran on each node. If w is not nil, each step is also written
+// to it as it happens, so the steps before a reduction that panics are still
+// seen.
+func (r Reducer) Trace(node parlex.ParseNode, w io.Writer) (*PN, Trace) {
+	t := &tracer{w: w}
+	return r.op(t).reduce(node), t.steps
+}
+
+// Named wraps a Reduction so it is recorded by name when a Reducer is traced.
+func Named(name string, r Reduction) Reduction {
+	return func(node *PN) {
+		traceOp(node, name)
+		r(node)
+	}
+}
+
+type tracer struct {
+	w     io.Writer
+	steps []ReduceStep
+	ops   []string
+}
+
+func (t *tracer) run(cp *PN, reduction Reduction) {
+	step := ReduceStep{
+		Entry:  cp.Kind().String(),
+		Before: ToSExpr(cp),
+		Node:   cp,
+	}
+	t.ops = nil
+	startTrace(cp, t)
+	defer func() {
+		endTrace(cp)
+		step.Ops = t.ops
+		step.After = ToSExpr(cp)
+		t.steps = append(t.steps, step)
+		if t.w != nil {
+			fmt.Fprintln(t.w, step.String())
+		}
+	}()
+	reduction(cp)
+}
+
+var (
+	// tracing is the number of nodes being traced, checked before taking the
+	// lock so operations cost little when nothing is traced
+	tracing  int32
+	traceMux sync.Mutex
+	tracers  = make(map[*PN]*tracer)
+)
+
+func startTrace(node *PN, t *tracer) {
+	traceMux.Lock()
+	tracers[node] = t
+	traceMux.Unlock()
+	atomic.AddInt32(&tracing, 1)
+}
+
+func endTrace(node *PN) {
+	atomic.AddInt32(&tracing, -1)
+	traceMux.Lock()
+	delete(tracers, node)
+	traceMux.Unlock()
+}
+
+// traceOp records an operation on a node if the node is being traced. The
+// args are written in parens after the name, the elements of a slice are
+// written as separate args.
+func traceOp(node *PN, name string, args ...interface{}) {
+	if atomic.LoadInt32(&tracing) == 0 {
+		return
+	}
+	traceMux.Lock()
+	t := tracers[node]
+	traceMux.Unlock()
+	if t == nil {
+		return
+	}
+	if len(args) > 0 {
+		var strs []string
+		for _, a := range args {
+			switch a := a.(type) {
+			case []string:
+				strs = append(strs, a...)
+			case []int:
+				for _, i := range a {
+					strs = append(strs, strconv.Itoa(i))
+				}
+			default:
+				strs = append(strs, fmt.Sprint(a))
+			}
+		}
+		name += "(" + strings.Join(strs, ", ") + ")"
+	}
+	t.ops = append(t.ops, name)
+}
@@ -0,0 +1,57 @@
+package tree
+
+import (
+	"bytes"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	pn, err := FromSExpr(`(E (T (int "1")) (op "+") (E (T (int "2"))))`, nil)
+	assert.NoError(t, err)
+
+	rdcr := Reducer{
+		"T": PromoteSingleChild,
+		"E": If(ChildCount(1), PromoteSingleChild, RemoveAll("op").RemoveChildren(2, 3)),
+		"op": func(node *PN) {
+			node.Lexeme.(*lexeme.Lexeme).Set("plus")
+		},
+	}
+
+	var buf bytes.Buffer
+	out, trace := rdcr.Trace(pn, &buf)
+	assert.Equal(t, `(E (int "1") (int "2"))`, ToSExpr(out))
+	assert.Equal(t, trace.String(), buf.String())
+
+	if assert.Len(t, trace, 5) {
+		assert.Equal(t, "T", trace[0].Entry)
+		assert.Equal(t, []string{"PromoteSingleChild"}, trace[0].Ops)
+		assert.Equal(t, `(T (int "1"))`, trace[0].Before)
+		assert.Equal(t, `(int "1")`, trace[0].After)
+
+		assert.Equal(t, "op", trace[1].Entry)
+		assert.Nil(t, trace[1].Ops)
+
+		assert.Equal(t, []string{"If(true)", "PromoteSingleChild"}, trace[3].Ops)
+		assert.Equal(t, []string{"If(false)", "RemoveAll(op)", "RemoveChildren(2, 3)"}, trace[4].Ops)
+		assert.Equal(t, out, trace[4].Node)
+	}
+}
+
+func TestTraceNamed(t *testing.T) {
+	pn, err := FromSExpr(`(E (int "1"))`, nil)
+	assert.NoError(t, err)
+
+	rdcr := Reducer{
+		"E": Named("drop", func(node *PN) { node.C = nil }).RemoveChild(0),
+	}
+	_, trace := rdcr.Trace(pn, nil)
+	if assert.Len(t, trace, 1) {
+		assert.Equal(t, []string{"drop", "RemoveChild(0)"}, trace[0].Ops)
+		assert.Equal(t, "E: drop, RemoveChild(0)\n  before: (E (int \"1\"))\n  after:  E", trace[0].String())
+	}
+
+	// the same reducer is not traced by Reduce
+	assert.Equal(t, "E", ToSExpr(rdcr.Reduce(pn)))
+}
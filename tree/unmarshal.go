@@ -0,0 +1,127 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"reflect"
+	"strconv"
+)
+
+// ErrUnmarshalTarget is returned by Unmarshal if v is not a non-nil pointer.
+var ErrUnmarshalTarget = errors.New("Unmarshal Target Must Be A Non-Nil Pointer")
+
+var parseNodeType = reflect.TypeOf((*parlex.ParseNode)(nil)).Elem()
+
+// Unmarshal populates v from the node. Struct fields are matched to children by
+// kind with a tag. A field tagged "." is populated from the node itself.
+//   type Number struct {
+//     Int  int      `parlex:"int"`
+//     Dec  string   `parlex:"dec"`
+//   }
+//   type Sum struct {
+//     Op   string   `parlex:"."`
+//     Nums []Number `parlex:"Number"`
+//   }
+// A slice field is populated from every child of the kind, otherwise the first
+// child of the kind is used. Fields that are strings, bools, ints, uints or
+// floats are set by converting the value of the node. Struct fields are
+// populated recursively and a parlex.ParseNode field is set to the node.
+// Pointers are allocated as needed.
+func Unmarshal(node parlex.ParseNode, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrUnmarshalTarget
+	}
+	return unmarshal(node, rv.Elem())
+}
+
+func unmarshal(node parlex.ParseNode, v reflect.Value) error {
+	if v.Type() == parseNodeType {
+		v.Set(reflect.ValueOf(node))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshal(node, v.Elem())
+	case reflect.Struct:
+		return unmarshalStruct(node, v)
+	case reflect.Slice:
+		el := reflect.New(v.Type().Elem()).Elem()
+		if err := unmarshal(node, el); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, el))
+		return nil
+	}
+	return setValue(node, v)
+}
+
+func unmarshalStruct(node parlex.ParseNode, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		kind, ok := f.Tag.Lookup("parlex")
+		if !ok || kind == "-" || f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if kind == "." {
+			if err := unmarshal(node, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		for c := 0; c < node.Children(); c++ {
+			child := node.Child(c)
+			if child == nil || child.Kind().String() != kind {
+				continue
+			}
+			if err := unmarshal(child, fv); err != nil {
+				return err
+			}
+			if fv.Kind() != reflect.Slice {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func setValue(node parlex.ParseNode, v reflect.Value) error {
+	val := node.Value()
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(val)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(val); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(val, 0, v.Type().Bits()); err == nil {
+			v.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(val, 0, v.Type().Bits()); err == nil {
+			v.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(val, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	default:
+		return fmt.Errorf("Cannot Unmarshal: %s into %s", node.Kind(), v.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot Unmarshal: %s %q into %s", node.Kind(), val, v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type umNumber struct {
+	Int int     `parlex:"int"`
+	Dec *string `parlex:"dec"`
+}
+
+type umCall struct {
+	Name     string           `parlex:"name"`
+	Args     []umNumber       `parlex:"Number"`
+	Floats   []float64        `parlex:"float"`
+	Flag     bool             `parlex:"flag"`
+	Small    uint8            `parlex:"small"`
+	Missing  *umNumber        `parlex:"Missing"`
+	Node     parlex.ParseNode `parlex:"."`
+	Skip     string           `parlex:"-"`
+	Untagged string
+}
+
+func TestUnmarshal(t *testing.T) {
+	pn, err := New(`
+    Call {
+      name: "sum"
+      Number {
+        int: "1"
+      }
+      float: "2.5"
+      Number {
+        int: "0x10"
+        dec: ".25"
+      }
+      flag: "true"
+      small: "200"
+      float: "-1"
+    }
+  `)
+	assert.NoError(t, err)
+
+	var c umCall
+	assert.NoError(t, Unmarshal(pn, &c))
+	assert.Equal(t, "sum", c.Name)
+	if assert.Len(t, c.Args, 2) {
+		assert.Equal(t, 1, c.Args[0].Int)
+		assert.Nil(t, c.Args[0].Dec)
+		assert.Equal(t, 16, c.Args[1].Int)
+		if assert.NotNil(t, c.Args[1].Dec) {
+			assert.Equal(t, ".25", *c.Args[1].Dec)
+		}
+	}
+	assert.Equal(t, []float64{2.5, -1}, c.Floats)
+	assert.True(t, c.Flag)
+	assert.Equal(t, uint8(200), c.Small)
+	assert.Nil(t, c.Missing)
+	assert.Equal(t, pn, c.Node)
+
+	var name string
+	assert.NoError(t, Unmarshal(pn.C[0], &name))
+	assert.Equal(t, "sum", name)
+
+	assert.Equal(t, ErrUnmarshalTarget, Unmarshal(pn, c))
+	assert.Equal(t, ErrUnmarshalTarget, Unmarshal(pn, (*umCall)(nil)))
+
+	var bad struct {
+		Small int8 `parlex:"small"`
+	}
+	err = Unmarshal(pn, &bad)
+	assert.Equal(t, `Cannot Unmarshal: small "200" into int8`, err.Error())
+
+	var badType struct {
+		Name map[string]string `parlex:"name"`
+	}
+	err = Unmarshal(pn, &badType)
+	assert.Equal(t, `Cannot Unmarshal: name into map[string]string`, err.Error())
+}
@@ -0,0 +1,199 @@
+// Package unparse reconstructs source text from a parse tree.
+//
+// A tree that has been reduced will often be missing the literal tokens that
+// the structure made redundant, like parentheses or commas. For each
+// non-terminal, the children are matched against the productions in the
+// grammar. A terminal in the production that is missing from the children is
+// put back using the text from Literals. If the node has a value, that value is
+// used for the first missing terminal that is not a literal, which undoes
+// PromoteChildValue.
+//
+// The text between tokens is decided by a Spacer.
+package unparse
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"strings"
+)
+
+// Token is a piece of text in the output and the kind it was produced from.
+type Token struct {
+	Kind, Text string
+}
+
+// Spacer returns the text to place between two tokens.
+type Spacer interface {
+	Space(prev, next Token) string
+}
+
+// Rules is a Spacer. The text after a token is taken from After by it's kind,
+// if that is not set, the text before the next token is taken from Before by
+// it's kind, otherwise Default is used.
+type Rules struct {
+	Default string
+	Before  map[string]string
+	After   map[string]string
+}
+
+// Space fulfills Spacer.
+func (r Rules) Space(prev, next Token) string {
+	if s, ok := r.After[prev.Kind]; ok {
+		return s
+	}
+	if s, ok := r.Before[next.Kind]; ok {
+		return s
+	}
+	return r.Default
+}
+
+// Unparser converts a tree back to source using a grammar.
+type Unparser struct {
+	Grammar parlex.Grammar
+	// Literals maps the kind of a terminal to its text, for terminals that
+	// always have the same text.
+	Literals map[string]string
+	Spacer
+	nts map[string]parlex.Symbol
+}
+
+// New returns an Unparser that separates tokens with a single space.
+func New(grmr parlex.Grammar, literals map[string]string) *Unparser {
+	u := &Unparser{
+		Grammar:  grmr,
+		Literals: literals,
+		Spacer:   Rules{Default: " "},
+		nts:      make(map[string]parlex.Symbol),
+	}
+	for _, nt := range grmr.NonTerminals() {
+		u.nts[nt.String()] = nt
+	}
+	return u
+}
+
+// Unparse returns the source for the tree.
+func (u *Unparser) Unparse(node parlex.ParseNode) (string, error) {
+	tkns, err := u.Tokens(node)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	for i, t := range tkns {
+		if i > 0 {
+			buf.WriteString(u.Space(tkns[i-1], t))
+		}
+		buf.WriteString(t.Text)
+	}
+	return buf.String(), nil
+}
+
+// Tokens returns the tokens for the tree in order, with the missing literals
+// put back.
+func (u *Unparser) Tokens(node parlex.ParseNode) ([]Token, error) {
+	return u.tokens(node, nil)
+}
+
+func (u *Unparser) tokens(node parlex.ParseNode, tkns []Token) ([]Token, error) {
+	kind := node.Kind().String()
+	nt, isNT := u.nts[kind]
+	if !isNT {
+		if node.Children() == 0 {
+			return append(tkns, u.leaf(node)), nil
+		}
+		// not from the grammar, so there is nothing to match against
+		if v := node.Value(); v != "" {
+			tkns = append(tkns, Token{Kind: kind, Text: v})
+		}
+		var err error
+		for i := 0; i < node.Children() && err == nil; i++ {
+			tkns, err = u.tokens(node.Child(i), tkns)
+		}
+		return tkns, err
+	}
+
+	for i := u.Grammar.Productions(nt).Iter(); i.Next(); {
+		slots, ok := u.match(node, i.Production)
+		if !ok {
+			continue
+		}
+		var err error
+		for _, s := range slots {
+			if s.child < 0 {
+				tkns = append(tkns, s.Token)
+			} else if tkns, err = u.tokens(node.Child(s.child), tkns); err != nil {
+				return nil, err
+			}
+		}
+		return tkns, nil
+	}
+	return nil, fmt.Errorf("Cannot Unparse: no production of %s matches %s", kind, childKinds(node))
+}
+
+func (u *Unparser) leaf(node parlex.ParseNode) Token {
+	t := Token{
+		Kind: node.Kind().String(),
+		Text: node.Value(),
+	}
+	if l, ok := u.Literals[t.Kind]; ok && t.Text == "" {
+		t.Text = l
+	}
+	return t
+}
+
+// slot is either a child by index or a token that was put back, in which case
+// child is -1.
+type slot struct {
+	child int
+	Token
+}
+
+// match finds how the children of the node fit the production.
+func (u *Unparser) match(node parlex.ParseNode, prod parlex.Production) ([]slot, bool) {
+	syms := make([]string, prod.Symbols())
+	for i := range syms {
+		syms[i] = prod.Symbol(i).String()
+	}
+	slots := make([]slot, 0, len(syms))
+	var try func(s, c int, valUsed bool) bool
+	try = func(s, c int, valUsed bool) bool {
+		if s == len(syms) {
+			return c == node.Children()
+		}
+		sym := syms[s]
+		if c < node.Children() && node.Child(c).Kind().String() == sym {
+			slots = append(slots, slot{child: c})
+			if try(s+1, c+1, valUsed) {
+				return true
+			}
+			slots = slots[:len(slots)-1]
+		}
+		if _, isNT := u.nts[sym]; isNT {
+			return false
+		}
+		if l, ok := u.Literals[sym]; ok {
+			slots = append(slots, slot{child: -1, Token: Token{Kind: sym, Text: l}})
+		} else if !valUsed && node.Value() != "" {
+			valUsed = true
+			slots = append(slots, slot{child: -1, Token: Token{Kind: sym, Text: node.Value()}})
+		} else {
+			return false
+		}
+		if try(s+1, c, valUsed) {
+			return true
+		}
+		slots = slots[:len(slots)-1]
+		return false
+	}
+	if !try(0, 0, false) {
+		return nil, false
+	}
+	return slots, true
+}
+
+func childKinds(node parlex.ParseNode) string {
+	kinds := make([]string, node.Children())
+	for i := range kinds {
+		kinds[i] = node.Child(i).Kind().String()
+	}
+	return "(" + strings.Join(kinds, " ") + ")"
+}
@@ -0,0 +1,88 @@
+package unparse
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/grammar"
+	"github.com/adamcolton/parlex/lexer/simplelexer"
+	"github.com/adamcolton/parlex/parser/packrat"
+	"github.com/adamcolton/parlex/tree"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var lxr = parlex.MustLexer(simplelexer.New(`
+    lp /\(/
+    rp /\)/
+    op /[+\-\*\/]/
+    int /\d+/
+    space /\s+/ -
+  `))
+
+var grmr = parlex.MustGrammar(grammar.New(`
+    E -> E op T
+      -> T
+    T -> lp E rp
+      -> int
+  `))
+
+var literals = map[string]string{
+	"lp": "(",
+	"rp": ")",
+}
+
+func TestUnparse(t *testing.T) {
+	rdcr := tree.Reducer{
+		"E": tree.If(tree.ChildCount(3), tree.PromoteChildValue(1), nil),
+		"T": tree.RemoveAll("lp", "rp"),
+	}
+	u := New(grmr, literals)
+
+	tests := map[string]string{
+		"1":             "1",
+		"1+2":           "1 + 2",
+		"1 + (2*3)":     "1 + ( 2 * 3 )",
+		"((1)) - 2 / 3": "( ( 1 ) ) - 2 / 3",
+	}
+	for src, expected := range tests {
+		t.Run(src, func(t *testing.T) {
+			pn, err := packrat.New(grmr).ParseErr(lxr.Lex(src))
+			if !assert.NoError(t, err) {
+				return
+			}
+			str, err := u.Unparse(pn)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, str)
+
+			str, err = u.Unparse(rdcr.Reduce(pn))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, str)
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	u := New(grmr, literals)
+	u.Spacer = Rules{
+		Default: " ",
+		After:   map[string]string{"lp": ""},
+		Before:  map[string]string{"rp": ""},
+	}
+	pn, err := packrat.New(grmr).ParseErr(lxr.Lex("1+((2)*3)"))
+	assert.NoError(t, err)
+	pn = tree.Reducer{"T": tree.RemoveAll("lp", "rp")}.Reduce(pn)
+	str, err := u.Unparse(pn)
+	assert.NoError(t, err)
+	assert.Equal(t, "1 + ((2) * 3)", str)
+}
+
+func TestUnparseError(t *testing.T) {
+	pn, err := tree.New(`
+    E {
+      int: "1"
+      int: "2"
+    }
+  `)
+	assert.NoError(t, err)
+	_, err = New(grmr, literals).Unparse(pn)
+	assert.EqualError(t, err, "Cannot Unparse: no production of E matches (int int)")
+}
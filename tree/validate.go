@@ -0,0 +1,137 @@
+package tree
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/lexeme"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"sort"
+)
+
+// Validate checks the Reducer against a grammar and the lexer that produces its
+// terminals. A key that is not a symbol in either is reported, which is usually
+// a typo. Each reduction is also run against a node built from every production
+// of its symbol and if it does not change any of them, it is reported; that
+// happens when a reduction refers to a child index or kind that the grammar
+// cannot produce. The lexer can be nil, otherwise if it has a Symbols method
+// those symbols are included.
+//
+// A pattern key is tested against every symbol it is used for and is reported
+// if it is not used for any.
+//
+// The nodes a reduction is tested against are built from the grammar and their
+// children have not been reduced, so a reduction that relies on the children
+// being reduced first may be reported.
+func (r Reducer) Validate(g parlex.Grammar, l parlex.Lexer) []error {
+	nts := make(map[string]parlex.Symbol)
+	for _, nt := range g.NonTerminals() {
+		nts[nt.String()] = nt
+	}
+	symbols := make(map[string]bool)
+	for _, nt := range g.NonTerminals() {
+		symbols[nt.String()] = true
+		for i := g.Productions(nt).Iter(); i.Next(); {
+			for j := i.Iter(); j.Next(); {
+				symbols[j.Symbol.String()] = true
+			}
+		}
+	}
+	if s, ok := l.(interface{ Symbols() []parlex.Symbol }); ok {
+		for _, sym := range s.Symbols() {
+			symbols[sym.String()] = true
+		}
+	}
+
+	keys := r.Keys()
+
+	var errs []error
+	patterns := r.patterns()
+	for _, k := range keys {
+		// the symbols the key is used for
+		var kinds []string
+		if isPattern(k) {
+			for sym := range symbols {
+				if used, _ := r.key(sym, patterns); used == k {
+					kinds = append(kinds, sym)
+				}
+			}
+			if kinds == nil {
+				errs = append(errs, fmt.Errorf("Unused Pattern: %s", k))
+				continue
+			}
+			sort.Strings(kinds)
+		} else if !symbols[k] {
+			errs = append(errs, fmt.Errorf("Unknown Symbol: %s", k))
+			continue
+		} else {
+			kinds = []string{k}
+		}
+		reduction := r[k]
+		if reduction == nil {
+			continue
+		}
+		var probes []*PN
+		for _, kind := range kinds {
+			if nt, ok := nts[kind]; ok {
+				for i := g.Productions(nt).Iter(); i.Next(); {
+					probes = append(probes, probe(g, nts, kind, i.Production))
+				}
+			} else {
+				probes = append(probes, &PN{Lexeme: lexeme.New(stringsymbol.Symbol(kind)).Set(kind)})
+			}
+		}
+		if !hasEffect(reduction, probes) {
+			errs = append(errs, fmt.Errorf("No Effect: the reduction for %s does not change any production", k))
+		}
+	}
+	return errs
+}
+
+// probe builds a node for a production. Terminal children have their kind as
+// their value and non-terminal children are given the children of their first
+// production.
+func probe(g parlex.Grammar, nts map[string]parlex.Symbol, kind string, prod parlex.Production) *PN {
+	pn := &PN{
+		Lexeme: lexeme.New(stringsymbol.Symbol(kind)),
+	}
+	for i := prod.Iter(); i.Next(); {
+		str := i.Symbol.String()
+		c := &PN{
+			Lexeme: lexeme.New(stringsymbol.Symbol(str)).Set(str),
+			P:      pn,
+		}
+		if nt, ok := nts[str]; ok {
+			c.Lexeme = lexeme.New(nt)
+			if prods := g.Productions(nt); prods.Productions() > 0 {
+				for j := prods.Production(0).Iter(); j.Next(); {
+					gc := j.Symbol.String()
+					c.C = append(c.C, &PN{
+						Lexeme: lexeme.New(stringsymbol.Symbol(gc)).Set(gc),
+						P:      c,
+					})
+				}
+			}
+		}
+		pn.C = append(pn.C, c)
+	}
+	return pn
+}
+
+// hasEffect returns true if the reduction changes any of the nodes. A reduction
+// that panics is counted as having an effect because the probe may not look
+// like the nodes it expects.
+func hasEffect(reduction Reduction, probes []*PN) (effect bool) {
+	defer func() {
+		if recover() != nil {
+			effect = true
+		}
+	}()
+	for _, pn := range probes {
+		before := pn.String()
+		reduction(pn)
+		if pn.String() != before {
+			return true
+		}
+	}
+	return false
+}
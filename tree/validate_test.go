@@ -0,0 +1,95 @@
+package tree
+
+import (
+	"github.com/adamcolton/parlex"
+	"github.com/adamcolton/parlex/symbol/stringsymbol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testGrammar struct {
+	nts   []parlex.Symbol
+	prods map[string]stringsymbol.Productions
+}
+
+func (g testGrammar) NonTerminals() []parlex.Symbol { return g.nts }
+
+func (g testGrammar) Productions(symbol parlex.Symbol) parlex.Productions {
+	return g.prods[symbol.String()]
+}
+
+type testLexer []parlex.Symbol
+
+func (testLexer) Lex(string) []parlex.Lexeme { return nil }
+
+func (l testLexer) Symbols() []parlex.Symbol { return l }
+
+func TestValidate(t *testing.T) {
+	g := testGrammar{
+		nts: []parlex.Symbol{stringsymbol.Symbol("E"), stringsymbol.Symbol("P")},
+		prods: map[string]stringsymbol.Productions{
+			"E": {
+				{"E", "op", "E"},
+				{"P"},
+				{"int"},
+			},
+			"P": {
+				{"lp", "E", "rp"},
+			},
+		},
+	}
+	lxr := testLexer{stringsymbol.Symbol("space")}
+
+	r := Reducer{
+		"E":     PromoteSingleChild,
+		"P":     RemoveChildren(0, -1).PromoteSingleChild(),
+		"space": nil,
+	}
+	assert.Len(t, r.Validate(g, lxr), 0)
+
+	r = Reducer{
+		"Expr":  PromoteSingleChild,
+		"E":     RemoveChild(3),
+		"P":     RemoveAll("comma"),
+		"int":   PromoteGrandChildren,
+		"space": nil,
+		"op":    If(ChildIs(0, "lp"), RemoveChild(0), nil),
+	}
+	errs := r.Validate(g, nil)
+	if assert.Len(t, errs, 6) {
+		assert.EqualError(t, errs[0], "No Effect: the reduction for E does not change any production")
+		assert.EqualError(t, errs[1], "Unknown Symbol: Expr")
+		assert.EqualError(t, errs[2], "No Effect: the reduction for P does not change any production")
+		assert.EqualError(t, errs[3], "No Effect: the reduction for int does not change any production")
+		assert.EqualError(t, errs[4], "No Effect: the reduction for op does not change any production")
+		assert.EqualError(t, errs[5], "Unknown Symbol: space")
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	g := testGrammar{
+		nts: []parlex.Symbol{stringsymbol.Symbol("E"), stringsymbol.Symbol("P")},
+		prods: map[string]stringsymbol.Productions{
+			"E": {
+				{"E", "op", "E"},
+				{"P"},
+			},
+			"P": {
+				{"lp", "E", "rp"},
+			},
+		},
+	}
+
+	r := Reducer{
+		"E":    PromoteSingleChild,
+		"P":    nil,
+		"[EP]": RemoveAll("comma"),
+		"r*":   nil,
+		"x*":   RemoveChild(0),
+	}
+	errs := r.Validate(g, nil)
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], "Unused Pattern: [EP]")
+		assert.EqualError(t, errs[1], "Unused Pattern: x*")
+	}
+}
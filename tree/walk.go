@@ -0,0 +1,99 @@
+package tree
+
+import (
+	"fmt"
+	"github.com/adamcolton/parlex"
+)
+
+// Visitor is used by Walk. Enter is called before a node's children are
+// visited and Exit is called after. If Enter returns false, the children are
+// skipped but Exit is still called.
+type Visitor interface {
+	Enter(node parlex.ParseNode) bool
+	Exit(node parlex.ParseNode)
+}
+
+// Walk does a depth first traversal of the tree calling the visitor as each
+// node is entered and exited.
+func Walk(node parlex.ParseNode, v Visitor) {
+	if node == nil {
+		return
+	}
+	if v.Enter(node) {
+		for i := 0; i < node.Children(); i++ {
+			Walk(node.Child(i), v)
+		}
+	}
+	v.Exit(node)
+}
+
+// Listener is a Visitor that calls the callbacks registered for a node's kind.
+// Nodes with no registered callback are entered.
+type Listener struct {
+	Enters map[string]func(node parlex.ParseNode) bool
+	Exits  map[string]func(node parlex.ParseNode)
+}
+
+// NewListener returns an empty Listener.
+func NewListener() *Listener {
+	return &Listener{
+		Enters: make(map[string]func(parlex.ParseNode) bool),
+		Exits:  make(map[string]func(parlex.ParseNode)),
+	}
+}
+
+// OnEnter registers a callback for when a node of the given kind is entered.
+// It returns the Listener so calls can be chained.
+func (l *Listener) OnEnter(kind string, fn func(node parlex.ParseNode) bool) *Listener {
+	l.Enters[kind] = fn
+	return l
+}
+
+// OnExit registers a callback for when a node of the given kind is exited. It
+// returns the Listener so calls can be chained.
+func (l *Listener) OnExit(kind string, fn func(node parlex.ParseNode)) *Listener {
+	l.Exits[kind] = fn
+	return l
+}
+
+// Enter fulfills Visitor.
+func (l *Listener) Enter(node parlex.ParseNode) bool {
+	if fn, ok := l.Enters[node.Kind().String()]; ok {
+		return fn(node)
+	}
+	return true
+}
+
+// Exit fulfills Visitor.
+func (l *Listener) Exit(node parlex.ParseNode) {
+	if fn, ok := l.Exits[node.Kind().String()]; ok {
+		fn(node)
+	}
+}
+
+// Handler handles a node, generally evaluating it by calling Dispatch.Call on
+// it's children.
+type Handler func(node parlex.ParseNode) (interface{}, error)
+
+// Dispatch routes nodes to handlers by kind. It replaces a switch on
+// Kind().String().
+type Dispatch map[string]Handler
+
+// Add a handler.
+func (d Dispatch) Add(kind string, handler Handler) {
+	d[kind] = handler
+}
+
+// Call passes the node to the handler for it's kind. If there is no handler, an
+// error is returned.
+func (d Dispatch) Call(node parlex.ParseNode) (interface{}, error) {
+	if node == nil {
+		return nil, fmt.Errorf("No Handler: nil node")
+	}
+	kind := node.Kind().String()
+	h, ok := d[kind]
+	if !ok {
+		return nil, fmt.Errorf("No Handler: %s", kind)
+	}
+	return h(node)
+}
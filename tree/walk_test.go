@@ -0,0 +1,89 @@
+package tree
+
+import (
+	"errors"
+	"github.com/adamcolton/parlex"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+var walkTree = `
+  E {
+    E {
+      int: "1"
+    }
+    op: "+"
+    P {
+      E {
+        int: "2"
+      }
+    }
+  }
+`
+
+func TestWalk(t *testing.T) {
+	pn, err := New(walkTree)
+	assert.NoError(t, err)
+
+	var events []string
+	l := NewListener().
+		OnEnter("E", func(node parlex.ParseNode) bool {
+			events = append(events, "enter E")
+			return true
+		}).
+		OnExit("E", func(node parlex.ParseNode) {
+			events = append(events, "exit E")
+		}).
+		OnEnter("P", func(node parlex.ParseNode) bool {
+			events = append(events, "skip P")
+			return false
+		}).
+		OnExit("int", func(node parlex.ParseNode) {
+			events = append(events, node.Value())
+		})
+	Walk(pn, l)
+
+	expected := []string{"enter E", "enter E", "1", "exit E", "skip P", "exit E"}
+	assert.Equal(t, expected, events)
+}
+
+func TestDispatch(t *testing.T) {
+	pn, err := New(walkTree)
+	assert.NoError(t, err)
+
+	d := Dispatch{}
+	d.Add("int", func(node parlex.ParseNode) (interface{}, error) {
+		return strconv.Atoi(node.Value())
+	})
+	d.Add("E", func(node parlex.ParseNode) (interface{}, error) {
+		if node.Children() == 1 {
+			return d.Call(node.Child(0))
+		}
+		a, err := d.Call(node.Child(0))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.Call(node.Child(2))
+		if err != nil {
+			return nil, err
+		}
+		return a.(int) + b.(int), nil
+	})
+
+	_, err = d.Call(pn)
+	assert.Equal(t, "No Handler: P", err.Error())
+
+	d.Add("P", func(node parlex.ParseNode) (interface{}, error) {
+		return d.Call(node.Child(0))
+	})
+	v, err := d.Call(pn)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+
+	d.Add("int", func(node parlex.ParseNode) (interface{}, error) {
+		return nil, errors.New("bad int")
+	})
+	_, err = d.Call(pn)
+	assert.Equal(t, "bad int", err.Error())
+}